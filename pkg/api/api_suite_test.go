@@ -33,5 +33,43 @@ func TestApi(t *testing.T) {
 	RunMockResponderTests()
 	RunModelTests()
 	RunNextImageTests()
+	RunFinishedScanClientJobTests()
+	RunAuthTests()
+	RunAuditTests()
+	RunSourceStatsTests()
+	RunTLSTests()
+	RunListenerTests()
+	RunOpenAPITests()
+	RunAPIErrorTests()
+	RunVersionTests()
+	RunRenamedRouteTests()
+	RunGzipTests()
+	RunRateLimitTests()
+	RunScanResultsStreamTests()
+	RunCORSTests()
+	RunPodValidationTests()
+	RunLegacyFieldTests()
+	RunShutdownTests()
+	RunRequestLogTests()
+	RunBulkImagesTests()
+	RunPodListTests()
+	RunPodDetailTests()
+	RunDeletePodTests()
+	RunPodsDeletionTests()
+	RunPodUpsertTests()
+	RunSetHubsTests()
+	RunHubLoginTests()
+	RunResponseTests()
+	RunHealthTests()
+	RunRouteMetricsTests()
+	RunHubCircuitBreakerTests()
+	RunServerLimitsTests()
+	RunETagTests()
+	RunFindingsExportTests()
+	RunImageRequeueTests()
+	RunImageNameTests()
+	RunScanQueueStatsTests()
+	RunModelQueryTests()
+	RunScanResultsQueryTests()
 	RunSpecs(t, "api suite")
 }
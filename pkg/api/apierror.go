@@ -0,0 +1,199 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrorCode is a stable, machine-readable identifier for a kind of API error.
+// Clients should switch on these rather than on messages or status codes.
+type ErrorCode string
+
+// these values are part of the API: don't change them
+const (
+	ErrorCodeBadRequest       ErrorCode = "BAD_REQUEST"
+	ErrorCodeValidation       ErrorCode = "VALIDATION_FAILED"
+	ErrorCodeNotFound         ErrorCode = "NOT_FOUND"
+	ErrorCodeAmbiguous        ErrorCode = "AMBIGUOUS"
+	ErrorCodeInvalidState     ErrorCode = "INVALID_STATE"
+	ErrorCodeUnauthorized     ErrorCode = "UNAUTHORIZED"
+	ErrorCodeCapacityExceeded ErrorCode = "CAPACITY_EXCEEDED"
+	ErrorCodeRateLimited      ErrorCode = "RATE_LIMITED"
+	ErrorCodeTooLarge         ErrorCode = "PAYLOAD_TOO_LARGE"
+	ErrorCodeStopped          ErrorCode = "STOPPED"
+	ErrorCodeInternal         ErrorCode = "INTERNAL"
+	ErrorCodeStaleLease       ErrorCode = "STALE_LEASE"
+)
+
+// ErrorResponse is the body of every API error.
+type ErrorResponse struct {
+	Code    ErrorCode   `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+	// RequestID matches the error up with perceptor's logs
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// ValidationError means a request was well-formed, but had invalid contents.
+// Fields, if set, lists the problem with each invalid field.
+type ValidationError struct {
+	Message string
+	Fields  []FieldError
+}
+
+// NewValidationError .....
+func NewValidationError(format string, args ...interface{}) *ValidationError {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
+
+func (err *ValidationError) Error() string {
+	return err.Message
+}
+
+// CapacityError means perceptor can't take on the request right now; the
+// client may retry later.
+type CapacityError struct {
+	Message string
+}
+
+func (err *CapacityError) Error() string {
+	return err.Message
+}
+
+// StoppedError means perceptor is shutting down, and won't handle the request.
+type StoppedError struct{}
+
+func (err *StoppedError) Error() string {
+	return "perceptor is stopped"
+}
+
+// UnauthorizedError .....
+type UnauthorizedError struct{}
+
+func (err *UnauthorizedError) Error() string {
+	return "unauthorized"
+}
+
+// NewErrorResponse maps `err` to an error code and HTTP status.  Errors
+// which aren't one of the typed API errors get `defaultStatusCode`.
+func NewErrorResponse(err error, defaultStatusCode int) (*ErrorResponse, int) {
+	response := &ErrorResponse{Message: err.Error()}
+	statusCode := defaultStatusCode
+	switch e := err.(type) {
+	case *ValidationError:
+		response.Code, statusCode = ErrorCodeValidation, http.StatusBadRequest
+		if len(e.Fields) > 0 {
+			response.Details = map[string]interface{}{"fields": e.Fields}
+		}
+	case *ImageNotFoundError:
+		response.Code, statusCode = ErrorCodeNotFound, http.StatusNotFound
+		response.Details = map[string]string{"sha": e.ShaPrefix}
+	case *PodNotFoundError:
+		response.Code, statusCode = ErrorCodeNotFound, http.StatusNotFound
+		response.Details = map[string]string{"pod": e.QualifiedName}
+	case *ImageNameNotFoundError:
+		response.Code, statusCode = ErrorCodeNotFound, http.StatusNotFound
+		response.Details = map[string]string{"name": e.Name}
+	case *HubNotFoundError:
+		response.Code, statusCode = ErrorCodeNotFound, http.StatusNotFound
+		response.Details = map[string]string{"host": e.Host}
+	case *AmbiguousShaError:
+		response.Code, statusCode = ErrorCodeAmbiguous, http.StatusConflict
+		response.Details = map[string]interface{}{"sha": e.ShaPrefix, "candidates": e.Candidates}
+	case *ScanLeaseError:
+		response.Code, statusCode = ErrorCodeStaleLease, http.StatusConflict
+		response.Details = map[string]string{"sha": e.Sha, "leaseID": e.LeaseID, "reason": e.Reason}
+	case *ImageStateError:
+		response.Code, statusCode = ErrorCodeInvalidState, http.StatusConflict
+		response.Details = map[string]string{"sha": e.Sha, "scanStatus": e.ScanStatus}
+	case *UnauthorizedError:
+		response.Code, statusCode = ErrorCodeUnauthorized, http.StatusUnauthorized
+	case *CapacityError:
+		response.Code, statusCode = ErrorCodeCapacityExceeded, http.StatusServiceUnavailable
+	case *RateLimitedError:
+		response.Code, statusCode = ErrorCodeRateLimited, http.StatusTooManyRequests
+		response.Details = map[string]interface{}{"class": e.Class.String(), "retryAfterSeconds": e.RetryAfter.Seconds()}
+	case *RequestTooLargeError:
+		response.Code, statusCode = ErrorCodeTooLarge, http.StatusRequestEntityTooLarge
+		response.Details = map[string]int64{"limitBytes": e.Limit}
+	case *StoppedError:
+		response.Code, statusCode = ErrorCodeStopped, http.StatusServiceUnavailable
+	default:
+		response.Code = errorCodeForStatus(statusCode)
+	}
+	return response, statusCode
+}
+
+func errorCodeForStatus(statusCode int) ErrorCode {
+	switch {
+	case statusCode == http.StatusNotFound:
+		return ErrorCodeNotFound
+	case statusCode >= 400 && statusCode < 500:
+		return ErrorCodeBadRequest
+	default:
+		return ErrorCodeInternal
+	}
+}
+
+// WriteError writes `err` as an ErrorResponse, returning the status code used.
+//
+// If `legacyText` is set, clients which don't accept JSON get the old
+// plain-text message instead; the code is still sent in a header.  This is
+// only meant to last for a deprecation period.
+func WriteError(w http.ResponseWriter, r *http.Request, err error, defaultStatusCode int, legacyText bool) int {
+	response, statusCode := NewErrorResponse(err, defaultStatusCode)
+	response.RequestID = RequestIDOfRequest(r)
+	if info := requestInfoOfRequest(r); info != nil {
+		info.errorCode = response.Code
+	}
+	header := w.Header()
+	header.Set("X-Perceptor-Error-Code", string(response.Code))
+	if legacyText && !acceptsJSON(r) {
+		header.Set("Warning", `299 - "plain-text errors are deprecated; send 'Accept: application/json'"`)
+		http.Error(w, response.Message, statusCode)
+		return statusCode
+	}
+	jsonBytes, marshalErr := json.Marshal(response)
+	if marshalErr != nil {
+		RequestLogger(r).Errorf("unable to marshal error response: %s", marshalErr.Error())
+		http.Error(w, response.Message, statusCode)
+		return statusCode
+	}
+	header.Set(http.CanonicalHeaderKey("content-type"), "application/json")
+	header.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCode)
+	fmt.Fprintln(w, string(jsonBytes))
+	return statusCode
+}
+
+func acceptsJSON(r *http.Request) bool {
+	for _, accept := range r.Header["Accept"] {
+		if strings.Contains(accept, "application/json") {
+			return true
+		}
+	}
+	return false
+}
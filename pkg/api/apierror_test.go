@@ -0,0 +1,122 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunAPIErrorTests() {
+	Describe("API errors", func() {
+		var mux *http.ServeMux
+		BeforeEach(func() {
+			responder := NewMockResponder()
+			Expect(responder.AddImage(Image{Repository: "repo", Tag: "tag", Sha: "abc1"})).To(BeNil())
+			Expect(responder.AddImage(Image{Repository: "repo", Tag: "tag", Sha: "abc2"})).To(BeNil())
+			mux = http.NewServeMux()
+			setupHandlers(mux, responder)
+		})
+		serve := func(method string, path string, body string) (*httptest.ResponseRecorder, *ErrorResponse) {
+			request := httptest.NewRequest(method, path, strings.NewReader(body))
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, request)
+			Expect(recorder.Header().Get("content-type")).To(Equal("application/json"))
+			var response ErrorResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(BeNil())
+			Expect(response.Message).NotTo(Equal(""))
+			Expect(recorder.Header().Get("X-Perceptor-Error-Code")).To(Equal(string(response.Code)))
+			return recorder, &response
+		}
+
+		It("reports unknown routes and methods as not found", func() {
+			recorder, response := serve("DELETE", "/model", "")
+			Expect(recorder.Code).To(Equal(404))
+			Expect(response.Code).To(Equal(ErrorCodeNotFound))
+		})
+
+		It("reports malformed bodies as bad requests", func() {
+			recorder, response := serve("POST", "/pod", "{not json")
+			Expect(recorder.Code).To(Equal(400))
+			Expect(response.Code).To(Equal(ErrorCodeBadRequest))
+		})
+
+		It("reports invalid values as validation failures", func() {
+			recorder, response := serve("POST", "/concurrentscanlimit", `{"Limit": -1}`)
+			Expect(recorder.Code).To(Equal(400))
+			Expect(response.Code).To(Equal(ErrorCodeValidation))
+		})
+
+		It("reports unknown images as not found, with details", func() {
+			recorder, response := serve("GET", "/image/def", "")
+			Expect(recorder.Code).To(Equal(404))
+			Expect(response.Code).To(Equal(ErrorCodeNotFound))
+			Expect(response.Details).To(Equal(map[string]interface{}{"sha": "def"}))
+		})
+
+		It("reports ambiguous sha prefixes as conflicts, with the candidates", func() {
+			recorder, response := serve("GET", "/image/abc", "")
+			Expect(recorder.Code).To(Equal(409))
+			Expect(response.Code).To(Equal(ErrorCodeAmbiguous))
+			Expect(response.Details).To(Equal(map[string]interface{}{"sha": "abc", "candidates": []interface{}{"abc1", "abc2"}}))
+		})
+	})
+
+	Describe("WriteError", func() {
+		write := func(err error, statusCode int, legacyText bool, accept string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest("GET", "/model", nil)
+			if accept != "" {
+				request.Header.Set("Accept", accept)
+			}
+			recorder := httptest.NewRecorder()
+			WriteError(recorder, request, err, statusCode, legacyText)
+			return recorder
+		}
+
+		It("maps typed errors to their own status codes", func() {
+			Expect(write(&StoppedError{}, 500, false, "").Code).To(Equal(503))
+			Expect(write(&CapacityError{Message: "full"}, 500, false, "").Code).To(Equal(503))
+			Expect(write(&UnauthorizedError{}, 500, false, "").Code).To(Equal(401))
+			Expect(write(fmt.Errorf("oops"), 500, false, "").Code).To(Equal(500))
+		})
+
+		It("sends plain text to legacy clients, if enabled", func() {
+			recorder := write(&StoppedError{}, 500, true, "")
+			Expect(recorder.Code).To(Equal(503))
+			Expect(recorder.Header().Get("content-type")).To(HavePrefix("text/plain"))
+			Expect(recorder.Header().Get("X-Perceptor-Error-Code")).To(Equal(string(ErrorCodeStopped)))
+			Expect(recorder.Header().Get("Warning")).NotTo(Equal(""))
+			Expect(recorder.Body.String()).To(Equal("perceptor is stopped\n"))
+		})
+
+		It("sends JSON to clients which accept it, even in legacy mode", func() {
+			recorder := write(&StoppedError{}, 500, true, "application/json")
+			Expect(recorder.Header().Get("content-type")).To(Equal("application/json"))
+		})
+	})
+}
@@ -0,0 +1,325 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultAuditLogSize is the number of audit entries kept in memory, by default.
+const DefaultAuditLogSize = 1000
+
+// DefaultAuditPageSize is the number of entries per page of GET /audit, by default.
+const DefaultAuditPageSize = 100
+
+const (
+	// only this much of a request body is kept for its payload summary
+	auditMaxBodyBytes = 64 * 1024
+	// longer arrays and strings in payloads are summarized
+	auditMaxArrayItems   = 10
+	auditMaxStringLength = 256
+	auditRedacted        = "REDACTED"
+)
+
+// auditSecretKeys are the substrings of payload keys whose values are redacted.
+var auditSecretKeys = []string{"password", "token", "secret", "credential", "apikey"}
+
+// AuditEntry records one mutating request.
+type AuditEntry struct {
+	ID        int64
+	Time      time.Time
+	RequestID string `json:",omitempty"`
+	Method    string
+	Route     string
+	Path      string
+	// Identity is who authenticated, if anyone: a client certificate's
+	// subject, or a fingerprint of a bearer token
+	Identity string `json:",omitempty"`
+	Source   string
+	// Payload summarizes the request body, with secrets redacted
+	Payload interface{} `json:",omitempty"`
+	Status  int
+}
+
+// AuditLog is a bounded record of the most recent mutating requests,
+// optionally mirrored to an append-only file of newline-delimited JSON.
+type AuditLog struct {
+	mutex   sync.Mutex
+	entries []*AuditEntry
+	next    int
+	count   int
+	nextID  int64
+	file    *os.File
+}
+
+// NewAuditLog creates an audit log holding up to `capacity` entries.  If
+// `path` is non-empty, entries are also appended to that file.
+func NewAuditLog(capacity int, path string) (*AuditLog, error) {
+	auditLog := &AuditLog{entries: make([]*AuditEntry, capacity), nextID: 1}
+	if path != "" {
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		auditLog.file = file
+	}
+	return auditLog, nil
+}
+
+func (auditLog *AuditLog) record(entry *AuditEntry) {
+	auditLog.mutex.Lock()
+	defer auditLog.mutex.Unlock()
+	entry.ID = auditLog.nextID
+	auditLog.nextID++
+	if len(auditLog.entries) > 0 {
+		auditLog.entries[auditLog.next] = entry
+		auditLog.next = (auditLog.next + 1) % len(auditLog.entries)
+		if auditLog.count < len(auditLog.entries) {
+			auditLog.count++
+		}
+	}
+	if auditLog.file != nil {
+		jsonBytes, err := json.Marshal(entry)
+		if err != nil {
+			log.Errorf("unable to marshal audit entry for request %s: %s", entry.RequestID, err.Error())
+			return
+		}
+		_, err = auditLog.file.Write(append(jsonBytes, '\n'))
+		if err != nil {
+			log.Errorf("unable to write audit entry for request %s: %s", entry.RequestID, err.Error())
+		}
+	}
+}
+
+// AuditQuery pages through the audit log, newest entry first.
+type AuditQuery struct {
+	// Limit is the maximum number of entries per page; 0 means the default
+	Limit int
+	// Continue is the token from the previous page
+	Continue string
+}
+
+// ParseAuditQuery reads the `limit` and `continue` query parameters.
+func ParseAuditQuery(values url.Values) (*AuditQuery, error) {
+	query := &AuditQuery{Continue: values.Get("continue")}
+	if limit := values.Get("limit"); limit != "" {
+		var err error
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 0 {
+			return nil, fmt.Errorf("invalid limit %s", limit)
+		}
+	}
+	if query.Continue != "" {
+		if id, err := strconv.ParseInt(query.Continue, 10, 64); err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid continue token %s", query.Continue)
+		}
+	}
+	return query, nil
+}
+
+// AuditPage is a page of audit entries, newest first.
+type AuditPage struct {
+	Entries []*AuditEntry
+	// Continue, if set, fetches the next page of older entries
+	Continue string `json:",omitempty"`
+}
+
+// Page returns the page of entries which `query` asks for.  Entries which
+// have been evicted since the previous page are skipped.
+func (auditLog *AuditLog) Page(query *AuditQuery) *AuditPage {
+	auditLog.mutex.Lock()
+	defer auditLog.mutex.Unlock()
+	limit := query.Limit
+	if limit == 0 {
+		limit = DefaultAuditPageSize
+	}
+	// entries have consecutive IDs, so the newest wanted one can be found
+	// by its offset from the newest entry
+	maxID := auditLog.nextID - 1
+	if query.Continue != "" {
+		continueID, _ := strconv.ParseInt(query.Continue, 10, 64)
+		if continueID < maxID {
+			maxID = continueID
+		}
+	}
+	page := &AuditPage{Entries: []*AuditEntry{}}
+	for i := 0; i < auditLog.count; i++ {
+		index := (auditLog.next - 1 - i + 2*len(auditLog.entries)) % len(auditLog.entries)
+		entry := auditLog.entries[index]
+		if entry.ID > maxID {
+			continue
+		}
+		if len(page.Entries) == limit {
+			page.Continue = strconv.FormatInt(entry.ID, 10)
+			break
+		}
+		page.Entries = append(page.Entries, entry)
+	}
+	return page
+}
+
+// auditIdentityOfRequest returns who authenticated a request, or "" if no one did.
+func auditIdentityOfRequest(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return "cert:" + r.TLS.PeerCertificates[0].Subject.String()
+	}
+	if info := requestInfoOfRequest(r); info != nil && info.identity != "" {
+		return info.identity
+	}
+	return ""
+}
+
+// tokenFingerprint identifies a bearer token without revealing it.
+func tokenFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:])[:12]
+}
+
+// auditBody keeps the start of a request body as the handler reads it.
+type auditBody struct {
+	io.ReadCloser
+	data []byte
+	size int64
+}
+
+func (ab *auditBody) Read(p []byte) (int, error) {
+	n, err := ab.ReadCloser.Read(p)
+	if room := auditMaxBodyBytes - len(ab.data); room > 0 {
+		if room > n {
+			room = n
+		}
+		ab.data = append(ab.data, p[:room]...)
+	}
+	ab.size += int64(n)
+	return n, err
+}
+
+// summarizePayload describes a request body: JSON is kept with secrets
+// redacted and long arrays and strings cut short, and anything else as text.
+func summarizePayload(data []byte, size int64) interface{} {
+	if size == 0 {
+		return nil
+	}
+	if size > int64(len(data)) {
+		return fmt.Sprintf("%d bytes", size)
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return summarizePayloadValue(string(data))
+	}
+	return summarizePayloadValue(value)
+}
+
+func summarizePayloadValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		summary := map[string]interface{}{}
+		for key, item := range v {
+			if isAuditSecretKey(key) {
+				summary[key] = auditRedacted
+			} else {
+				summary[key] = summarizePayloadValue(item)
+			}
+		}
+		return summary
+	case []interface{}:
+		if len(v) > auditMaxArrayItems {
+			return fmt.Sprintf("%d items", len(v))
+		}
+		summary := make([]interface{}, len(v))
+		for i, item := range v {
+			summary[i] = summarizePayloadValue(item)
+		}
+		return summary
+	case string:
+		if len(v) > auditMaxStringLength {
+			return v[:auditMaxStringLength] + "..."
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func isAuditSecretKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, secret := range auditSecretKeys {
+		if strings.Contains(lower, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditHandler records every mutating request to `auditLog`, once `handler`
+// has responded, so that rejected and failed requests are recorded too.
+// Scanners' requests are the scan protocol rather than changes anyone makes,
+// and aren't recorded; the model's journal covers their effects.  It must
+// be wrapped by a RequestLogHandler, for request IDs and routes.
+func AuditHandler(handler http.Handler, auditLog *AuditLog) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if RouteClassOfRequest(r) != RouteClassMutating {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		body := &auditBody{ReadCloser: r.Body}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = body
+		}
+		recorder := &statusRecorder{ResponseWriter: w}
+		handler.ServeHTTP(recorder, r)
+
+		status := recorder.statusCode
+		if status == 0 {
+			status = http.StatusOK
+		}
+		_, route := UnversionedPath(r.URL.Path)
+		if info := requestInfoOfRequest(r); info != nil && info.route != "" {
+			route = info.route
+		}
+		auditLog.record(&AuditEntry{
+			Time:      start,
+			RequestID: RequestIDOfRequest(r),
+			Method:    r.Method,
+			Route:     route,
+			Path:      r.URL.Path,
+			Identity:  auditIdentityOfRequest(r),
+			Source:    SourceOfRequest(r),
+			Payload:   summarizePayload(body.data, body.size),
+			Status:    status,
+		})
+	})
+}
@@ -0,0 +1,168 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunAuditTests() {
+	Describe("audit log", func() {
+		var auditLog *AuditLog
+		var handler http.Handler
+		BeforeEach(func() {
+			var err error
+			auditLog, err = NewAuditLog(10, "")
+			Expect(err).To(BeNil())
+			responder := NewMockResponder()
+			responder.AuditLog = auditLog
+			mux := http.NewServeMux()
+			setupHandlers(mux, responder)
+			authenticated := NewTokenAuthenticator([]string{"secret-token"}, []string{}).Wrap(mux)
+			handler = RequestLogHandler(AuditHandler(authenticated, auditLog), map[string]int{})
+		})
+		serve := func(method string, path string, body string, token string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest(method, path, strings.NewReader(body))
+			SetAuthToken(request, token)
+			request.Header.Set(SourceHeader, "tester")
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			return recorder
+		}
+		entries := func() []*AuditEntry {
+			return auditLog.Page(&AuditQuery{}).Entries
+		}
+
+		It("records mutating requests, with secrets redacted", func() {
+			recorder := serve("POST", "/api/v1/pod", `{"Name":"pod1","Namespace":"ns1","Labels":{"apiToken":"hunter2","app":"web"}}`, "secret-token")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(entries()).To(HaveLen(1))
+			entry := entries()[0]
+			Expect(entry.ID).To(Equal(int64(1)))
+			Expect(entry.RequestID).To(Equal(recorder.Header().Get(RequestIDHeader)))
+			Expect(entry.Method).To(Equal("POST"))
+			Expect(entry.Route).To(Equal("/pod"))
+			Expect(entry.Path).To(Equal("/api/v1/pod"))
+			Expect(entry.Identity).To(Equal(tokenFingerprint("secret-token")))
+			Expect(entry.Identity).NotTo(ContainSubstring("secret"))
+			Expect(entry.Source).To(Equal("tester"))
+			Expect(entry.Status).To(Equal(http.StatusOK))
+			Expect(entry.Payload).To(Equal(map[string]interface{}{
+				"Name":      "pod1",
+				"Namespace": "ns1",
+				"Labels":    map[string]interface{}{"apiToken": auditRedacted, "app": "web"},
+			}))
+		})
+
+		It("records rejected and failed requests", func() {
+			Expect(serve("DELETE", "/api/v1/pod", "ns1/pod1", "").Code).To(Equal(http.StatusUnauthorized))
+			Expect(serve("DELETE", "/api/v1/pod", "ns1/pod1", "secret-token").Code).To(Equal(http.StatusNotFound))
+			recorded := entries()
+			Expect(recorded).To(HaveLen(2))
+			Expect(recorded[0].Status).To(Equal(http.StatusNotFound))
+			Expect(recorded[0].Identity).To(Equal(tokenFingerprint("secret-token")))
+			Expect(recorded[0].Payload).To(Equal("ns1/pod1"))
+			Expect(recorded[1].Status).To(Equal(http.StatusUnauthorized))
+			Expect(recorded[1].Identity).To(Equal(""))
+			// the handler never read the body
+			Expect(recorded[1].Payload).To(BeNil())
+		})
+
+		It("doesn't record reads or the scan protocol", func() {
+			serve("GET", "/api/v1/model", "", "secret-token")
+			serve("POST", "/api/v1/nextimage", "", "secret-token")
+			Expect(entries()).To(BeEmpty())
+		})
+
+		It("summarizes long arrays and big bodies", func() {
+			body := []byte(`{"Hubs":[1,2,3,4,5,6,7,8,9,10,11]}`)
+			Expect(summarizePayload(body, int64(len(body)))).To(Equal(map[string]interface{}{"Hubs": "11 items"}))
+			Expect(summarizePayload([]byte(`{"Pods":[`), 100000)).To(Equal("100000 bytes"))
+			Expect(summarizePayload(nil, 0)).To(BeNil())
+		})
+
+		It("pages through entries newest first, skipping evicted ones", func() {
+			small, err := NewAuditLog(3, "")
+			Expect(err).To(BeNil())
+			for i := 0; i < 5; i++ {
+				small.record(&AuditEntry{Method: "POST"})
+			}
+			ids := func(page *AuditPage) []int64 {
+				result := []int64{}
+				for _, entry := range page.Entries {
+					result = append(result, entry.ID)
+				}
+				return result
+			}
+			page := small.Page(&AuditQuery{Limit: 2})
+			Expect(ids(page)).To(Equal([]int64{5, 4}))
+			Expect(page.Continue).To(Equal("3"))
+			page = small.Page(&AuditQuery{Limit: 2, Continue: page.Continue})
+			Expect(ids(page)).To(Equal([]int64{3}))
+			Expect(page.Continue).To(Equal(""))
+			Expect(ids(small.Page(&AuditQuery{Continue: "1"}))).To(BeEmpty())
+		})
+
+		It("serves GET /audit, and rejects bad paging parameters", func() {
+			serve("POST", "/api/v1/pod", `{"Name":"pod1","Namespace":"ns1"}`, "secret-token")
+			Expect(serve("GET", "/api/v1/audit", "", "").Code).To(Equal(http.StatusUnauthorized))
+			recorder := serve("GET", "/api/v1/audit?limit=1", "", "secret-token")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var page AuditPage
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &page)).To(BeNil())
+			Expect(page.Entries).To(HaveLen(1))
+			Expect(page.Entries[0].Route).To(Equal("/pod"))
+			Expect(page.Continue).To(Equal(""))
+			Expect(serve("GET", "/api/v1/audit?continue=abc", "", "secret-token").Code).To(Equal(http.StatusBadRequest))
+			Expect(serve("GET", "/api/v1/audit?limit=-1", "", "secret-token").Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("appends entries to a file", func() {
+			dir, err := ioutil.TempDir("", "audit")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "audit.log")
+			fileLog, err := NewAuditLog(0, path)
+			Expect(err).To(BeNil())
+			fileLog.record(&AuditEntry{Method: "POST", Route: "/sethubs"})
+			fileLog.record(&AuditEntry{Method: "PUT", Route: "/concurrentscanlimit"})
+			Expect(fileLog.Page(&AuditQuery{}).Entries).To(BeEmpty())
+			contents, err := ioutil.ReadFile(path)
+			Expect(err).To(BeNil())
+			lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+			Expect(lines).To(HaveLen(2))
+			var entry AuditEntry
+			Expect(json.Unmarshal([]byte(lines[1]), &entry)).To(BeNil())
+			Expect(entry.ID).To(Equal(int64(2)))
+			Expect(entry.Route).To(Equal("/concurrentscanlimit"))
+		})
+	})
+}
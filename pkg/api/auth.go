@@ -0,0 +1,114 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	authorizationHeader = "Authorization"
+	bearerPrefix        = "Bearer "
+)
+
+// alwaysAuthenticatedRoutes can't be exempted from authentication.
+var alwaysAuthenticatedRoutes = map[string]bool{
+	"/sethubs": true,
+	"/audit":   true,
+}
+
+// alwaysAuthenticatedRequests are destructive requests, which are
+// authenticated even if their route is exempt for other methods.
+var alwaysAuthenticatedRequests = map[string]bool{
+	"DELETE /pods": true,
+}
+
+// TokenAuthenticator requires a bearer token on every request, other than
+// those for exempt paths.  Accepting several tokens allows them to be rotated
+// without downtime.
+type TokenAuthenticator struct {
+	tokens      []string
+	exemptPaths map[string]bool
+}
+
+// NewTokenAuthenticator .....
+func NewTokenAuthenticator(tokens []string, exemptPaths []string) *TokenAuthenticator {
+	exempt := map[string]bool{}
+	for _, path := range exemptPaths {
+		if alwaysAuthenticatedRoutes[canonicalRoute(path)] {
+			log.Warnf("not exempting %s from authentication", path)
+			continue
+		}
+		exempt[path] = true
+	}
+	return &TokenAuthenticator{tokens: tokens, exemptPaths: exempt}
+}
+
+// IsAuthorized also notes which token a request carried, for the audit log.
+func (ta *TokenAuthenticator) IsAuthorized(r *http.Request) bool {
+	route := canonicalRoute(r.URL.Path)
+	exempt := ta.exemptPaths[r.URL.Path] || ta.exemptPaths[route]
+	if exempt && !alwaysAuthenticatedRequests[r.Method+" "+route] {
+		return true
+	}
+	header := r.Header.Get(authorizationHeader)
+	if !strings.HasPrefix(header, bearerPrefix) {
+		return false
+	}
+	token := []byte(strings.TrimPrefix(header, bearerPrefix))
+	for _, validToken := range ta.tokens {
+		if subtle.ConstantTimeCompare(token, []byte(validToken)) == 1 {
+			if info := requestInfoOfRequest(r); info != nil {
+				info.identity = tokenFingerprint(validToken)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Wrap rejects unauthorized requests with a 401, and passes the rest on to `handler`.
+func (ta *TokenAuthenticator) Wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ta.IsAuthorized(r) {
+			RequestLogger(r).Warnf("rejecting unauthorized %s request to %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+			recordUnauthorizedRequest(r)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			WriteError(w, r, &UnauthorizedError{}, http.StatusUnauthorized, false)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// SetAuthToken adds `token` to an outgoing request to perceptor.  It does
+// nothing if `token` is empty.
+func SetAuthToken(r *http.Request, token string) {
+	if token == "" {
+		return
+	}
+	r.Header.Set(authorizationHeader, bearerPrefix+token)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	dto "github.com/prometheus/client_model/go"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunAuthTests() {
+	Describe("token authentication", func() {
+		okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+		})
+		serve := func(ta *TokenAuthenticator, path string, token string) int {
+			request := httptest.NewRequest("GET", path, nil)
+			SetAuthToken(request, token)
+			recorder := httptest.NewRecorder()
+			ta.Wrap(okHandler).ServeHTTP(recorder, request)
+			return recorder.Code
+		}
+
+		It("rejects requests without a token", func() {
+			ta := NewTokenAuthenticator([]string{"abc"}, []string{})
+			Expect(serve(ta, "/model", "")).To(Equal(401))
+		})
+
+		It("rejects requests with the wrong token", func() {
+			ta := NewTokenAuthenticator([]string{"abc"}, []string{})
+			Expect(serve(ta, "/model", "abd")).To(Equal(401))
+		})
+
+		It("accepts any of the configured tokens", func() {
+			ta := NewTokenAuthenticator([]string{"old", "new"}, []string{})
+			Expect(serve(ta, "/model", "old")).To(Equal(200))
+			Expect(serve(ta, "/model", "new")).To(Equal(200))
+			// after rotation, the old token stops working
+			ta = NewTokenAuthenticator([]string{"new"}, []string{})
+			Expect(serve(ta, "/model", "old")).To(Equal(401))
+		})
+
+		It("lets through requests to exempt paths", func() {
+			ta := NewTokenAuthenticator([]string{"abc"}, []string{"/metrics"})
+			Expect(serve(ta, "/metrics", "")).To(Equal(200))
+			Expect(serve(ta, "/pod", "")).To(Equal(401))
+		})
+
+		It("doesn't let /sethubs be exempted", func() {
+			ta := NewTokenAuthenticator([]string{"abc"}, []string{"/sethubs", "/api/v1/sethubs"})
+			Expect(serve(ta, "/sethubs", "")).To(Equal(401))
+			Expect(serve(ta, "/api/v1/sethubs", "")).To(Equal(401))
+			Expect(serve(ta, "/api/v1/sethubs", "abc")).To(Equal(200))
+		})
+
+		It("doesn't let /audit be exempted", func() {
+			ta := NewTokenAuthenticator([]string{"abc"}, []string{"/audit"})
+			Expect(serve(ta, "/api/v1/audit", "")).To(Equal(401))
+			Expect(serve(ta, "/api/v1/audit", "abc")).To(Equal(200))
+		})
+
+		It("doesn't exempt bulk pod deletion along with listing pods", func() {
+			ta := NewTokenAuthenticator([]string{"abc"}, []string{"/pods"})
+			Expect(serve(ta, "/api/v1/pods", "")).To(Equal(200))
+			request := httptest.NewRequest("DELETE", "/api/v1/pods?namespace=ns1", nil)
+			recorder := httptest.NewRecorder()
+			ta.Wrap(okHandler).ServeHTTP(recorder, request)
+			Expect(recorder.Code).To(Equal(401))
+		})
+
+		It("labels unauthorized requests by route template rather than path", func() {
+			unauthorized := func(route string) float64 {
+				metric := &dto.Metric{}
+				Expect(unauthorizedRequestCounter.With(map[string]string{"route": route, "method": "GET"}).Write(metric)).To(BeNil())
+				return metric.GetCounter().GetValue()
+			}
+			ta := NewTokenAuthenticator([]string{"abc"}, []string{})
+			images, others := unauthorized("/image/{sha}"), unauthorized(otherRoute)
+			Expect(serve(ta, "/api/v1/image/sha256:abc", "")).To(Equal(401))
+			Expect(serve(ta, "/image/sha256:def", "")).To(Equal(401))
+			Expect(serve(ta, "/wp-login.php", "")).To(Equal(401))
+			Expect(serve(ta, "/api/v9/model", "")).To(Equal(401))
+			Expect(unauthorized("/image/{sha}")).To(Equal(images + 2))
+			Expect(unauthorized(otherRoute)).To(Equal(others + 2))
+		})
+	})
+}
@@ -0,0 +1,99 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import "fmt"
+
+// ImageOutcome is what happened to one image of a bulk request.
+type ImageOutcome string
+
+// .....
+const (
+	ImageOutcomeAdded        ImageOutcome = "added"
+	ImageOutcomeAlreadyKnown ImageOutcome = "alreadyKnown"
+	ImageOutcomeRejected     ImageOutcome = "rejected"
+)
+
+// BulkImageResult is the outcome for the image at Index of a bulk request.
+type BulkImageResult struct {
+	Index    int
+	Sha      string
+	Outcome  ImageOutcome
+	Problems []FieldError `json:",omitempty"`
+}
+
+// BulkImagesResult reports on every image of a bulk request, in order, so
+// that a perceiver can reconcile its view with perceptor's.
+type BulkImagesResult struct {
+	Added        int
+	AlreadyKnown int
+	Rejected     int
+	Results      []*BulkImageResult
+}
+
+// NewBulkImagesResult counts up the outcomes of `results`.
+func NewBulkImagesResult(results []*BulkImageResult) *BulkImagesResult {
+	bulkResult := &BulkImagesResult{Results: results}
+	for _, result := range results {
+		switch result.Outcome {
+		case ImageOutcomeAdded:
+			bulkResult.Added++
+		case ImageOutcomeAlreadyKnown:
+			bulkResult.AlreadyKnown++
+		case ImageOutcomeRejected:
+			bulkResult.Rejected++
+		}
+	}
+	return bulkResult
+}
+
+// ValidateImage returns the problems with an image, with fields named
+// relative to `field`.
+func ValidateImage(field string, image Image) []FieldError {
+	pv := &podValidator{}
+	pv.image(field, image)
+	return pv.problems
+}
+
+// ValidateImages checks a batch of images, returning one result per image;
+// the outcome of valid images is left empty.  Unless acceptValid is set, any
+// invalid image rejects the whole batch with a *ValidationError.
+func ValidateImages(images []Image, acceptValid bool) ([]*BulkImageResult, error) {
+	results := make([]*BulkImageResult, len(images))
+	allProblems := []FieldError{}
+	for i, image := range images {
+		results[i] = &BulkImageResult{Index: i, Sha: image.Sha}
+		problems := ValidateImage(fmt.Sprintf("[%d]", i), image)
+		if len(problems) > 0 {
+			results[i].Outcome = ImageOutcomeRejected
+			results[i].Problems = problems
+			allProblems = append(allProblems, problems...)
+		}
+	}
+	if len(allProblems) > 0 && !acceptValid {
+		return nil, &ValidationError{
+			Message: fmt.Sprintf("invalid images: %d problem(s)", len(allProblems)),
+			Fields:  allProblems,
+		}
+	}
+	return results, nil
+}
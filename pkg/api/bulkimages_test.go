@@ -0,0 +1,108 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunBulkImagesTests() {
+	Describe("POST /images", func() {
+		var responder *MockResponder
+		var mux *http.ServeMux
+		sha := func(c string) string { return strings.Repeat(c, 64) }
+		BeforeEach(func() {
+			responder = NewMockResponder()
+			Expect(responder.AddImage(Image{Repository: "repo", Sha: sha("a")})).To(BeNil())
+			mux = http.NewServeMux()
+			setupHandlers(mux, responder)
+		})
+		post := func(query string, images []Image) *httptest.ResponseRecorder {
+			body, err := json.Marshal(images)
+			Expect(err).To(BeNil())
+			request := httptest.NewRequest("POST", "/api/v1/images"+query, strings.NewReader(string(body)))
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, request)
+			return recorder
+		}
+		outcomes := func(recorder *httptest.ResponseRecorder) (*BulkImagesResult, []ImageOutcome) {
+			Expect(recorder.Code).To(Equal(200))
+			var result BulkImagesResult
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &result)).To(BeNil())
+			outcomes := []ImageOutcome{}
+			for _, imageResult := range result.Results {
+				outcomes = append(outcomes, imageResult.Outcome)
+			}
+			return &result, outcomes
+		}
+
+		It("reports which images were new", func() {
+			result, imageOutcomes := outcomes(post("", []Image{
+				{Repository: "repo", Sha: sha("a")},
+				{Repository: "repo", Sha: sha("b")},
+				{Repository: "repo", Sha: sha("b")},
+			}))
+			Expect(imageOutcomes).To(Equal([]ImageOutcome{ImageOutcomeAlreadyKnown, ImageOutcomeAdded, ImageOutcomeAlreadyKnown}))
+			Expect(result.Added).To(Equal(1))
+			Expect(result.AlreadyKnown).To(Equal(2))
+			Expect(responder.Images).To(HaveLen(2))
+		})
+
+		It("rejects the whole batch if any image is invalid", func() {
+			recorder := post("", []Image{
+				{Repository: "repo", Sha: sha("b")},
+				{Repository: "repo", Sha: "abc"},
+				{Sha: sha("c")},
+			})
+			Expect(recorder.Code).To(Equal(400))
+			var response ErrorResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(BeNil())
+			Expect(response.Code).To(Equal(ErrorCodeValidation))
+			Expect(response.Details).To(Equal(map[string]interface{}{"fields": []interface{}{
+				map[string]interface{}{"field": "[1].Sha", "problem": `must be 64 lowercase hex characters, found "abc"`},
+				map[string]interface{}{"field": "[2].Repository", "problem": "is required"},
+			}}))
+			Expect(responder.Images).To(HaveLen(1))
+		})
+
+		It("adds the valid images, if asked to", func() {
+			result, imageOutcomes := outcomes(post("?acceptValid=true", []Image{
+				{Repository: "repo", Sha: sha("b")},
+				{Repository: "repo", Sha: "abc"},
+			}))
+			Expect(imageOutcomes).To(Equal([]ImageOutcome{ImageOutcomeAdded, ImageOutcomeRejected}))
+			Expect(result.Rejected).To(Equal(1))
+			Expect(result.Results[1].Problems).To(HaveLen(1))
+			Expect(responder.Images).To(HaveLen(2))
+		})
+
+		It("rejects an invalid acceptValid flag", func() {
+			Expect(post("?acceptValid=maybe", []Image{}).Code).To(Equal(400))
+		})
+	})
+}
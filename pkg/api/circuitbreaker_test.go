@@ -0,0 +1,92 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// recordingResponder remembers who asked for circuit breaker resets.
+type recordingResponder struct {
+	*MockResponder
+	sources    []string
+	requestIDs []string
+}
+
+func (rr *recordingResponder) ResetHubCircuitBreaker(host string, source string, requestID string) (*CircuitBreakerReset, error) {
+	rr.sources = append(rr.sources, source)
+	rr.requestIDs = append(rr.requestIDs, requestID)
+	return rr.MockResponder.ResetHubCircuitBreaker(host, source, requestID)
+}
+
+func RunHubCircuitBreakerTests() {
+	Describe("/hub/{host}/circuitbreaker", func() {
+		var responder *recordingResponder
+		BeforeEach(func() {
+			responder = &recordingResponder{MockResponder: NewMockResponder()}
+			responder.HubHosts["hub1.example.com"] = true
+		})
+		serve := func(method string, path string) *httptest.ResponseRecorder {
+			mux := http.NewServeMux()
+			setupHandlers(mux, responder)
+			request := httptest.NewRequest(method, path, nil)
+			request.Header.Set(SourceHeader, "ops-dashboard")
+			recorder := httptest.NewRecorder()
+			RequestLogHandler(mux, nil).ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		It("describes the circuit breaker", func() {
+			recorder := serve("GET", "/api/v1/hub/hub1.example.com/circuitbreaker")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var breaker ModelCircuitBreaker
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &breaker)).To(BeNil())
+			Expect(breaker.State).To(Equal("CircuitBreakerStateEnabled"))
+		})
+
+		It("resets the circuit breaker, passing on who asked", func() {
+			recorder := serve("POST", "/api/v1/hub/hub1.example.com/circuitbreaker/reset")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var reset CircuitBreakerReset
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &reset)).To(BeNil())
+			Expect(reset.Host).To(Equal("hub1.example.com"))
+			Expect(reset.Before).NotTo(BeNil())
+			Expect(reset.After).NotTo(BeNil())
+			Expect(responder.sources).To(Equal([]string{"ops-dashboard"}))
+			Expect(responder.requestIDs).To(Equal([]string{recorder.Header().Get(RequestIDHeader)}))
+		})
+
+		It("returns 404 for an unknown host", func() {
+			Expect(serve("GET", "/api/v1/hub/hub2.example.com/circuitbreaker").Code).To(Equal(http.StatusNotFound))
+			recorder := serve("POST", "/api/v1/hub/hub2.example.com/circuitbreaker/reset")
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			var errorResponse ErrorResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &errorResponse)).To(BeNil())
+			Expect(errorResponse.Details).To(Equal(map[string]interface{}{"host": "hub2.example.com"}))
+		})
+	})
+}
@@ -0,0 +1,247 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// .....
+const (
+	DefaultTimeout      = 30 * time.Second
+	DefaultMaxRetries   = 3
+	DefaultRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff     = 30 * time.Second
+	apiVersion          = "v1"
+)
+
+// Config .....
+type Config struct {
+	// BaseURL is perceptor's address, such as http://perceptor:3001
+	BaseURL string
+	// Token, if set, is sent as a bearer token
+	Token     string
+	TLSConfig *tls.Config
+	// Timeout bounds each attempt of a request; it defaults to DefaultTimeout
+	Timeout time.Duration
+	// MaxRetries is how many times an idempotent request is retried after a
+	// network error, a 5xx or a 429.  0 means DefaultMaxRetries, and a
+	// negative number turns retries off.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry; it doubles after
+	// each one.  It defaults to DefaultRetryBackoff.
+	RetryBackoff time.Duration
+}
+
+// Client makes typed calls to perceptor's HTTP API.  It's safe for
+// concurrent use.
+type Client struct {
+	baseURL      *url.URL
+	token        string
+	httpClient   *http.Client
+	timeout      time.Duration
+	maxRetries   int
+	retryBackoff time.Duration
+	sleep        func(time.Duration)
+	// prefix is nil until the API version has been negotiated
+	mutex  sync.Mutex
+	prefix *string
+}
+
+// NewClient .....
+func NewClient(config *Config) (*Client, error) {
+	baseURL, err := url.Parse(strings.TrimSuffix(config.BaseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL %s: %s", config.BaseURL, err.Error())
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %s: scheme must be http or https", config.BaseURL)
+	}
+	client := &Client{
+		baseURL:      baseURL,
+		token:        config.Token,
+		httpClient:   &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: config.TLSConfig}},
+		timeout:      config.Timeout,
+		maxRetries:   config.MaxRetries,
+		retryBackoff: config.RetryBackoff,
+		sleep:        time.Sleep,
+	}
+	if client.timeout <= 0 {
+		client.timeout = DefaultTimeout
+	}
+	if client.maxRetries == 0 {
+		client.maxRetries = DefaultMaxRetries
+	} else if client.maxRetries < 0 {
+		client.maxRetries = 0
+	}
+	if client.retryBackoff <= 0 {
+		client.retryBackoff = DefaultRetryBackoff
+	}
+	return client, nil
+}
+
+// request is one call to the API, which may be attempted several times.
+type request struct {
+	method string
+	route  string
+	query  url.Values
+	// body is sent as plain text if it's a string, and as JSON otherwise
+	body       interface{}
+	idempotent bool
+	// timeout, if set, overrides the client's, for long polls
+	timeout time.Duration
+	// result, if set, has the response body decoded into it
+	result interface{}
+}
+
+// pathPrefix negotiates the API version on first use.  Servers which
+// predate versioning 404 on /api/v1, and are called on their unversioned
+// paths instead.
+func (client *Client) pathPrefix() (string, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if client.prefix != nil {
+		return *client.prefix, nil
+	}
+	var prefix string
+	response, _, err := client.send(&request{method: "GET", route: api.VersionedPath(apiVersion, "/healthz")}, "")
+	switch {
+	case response != nil && response.Header.Get(api.APIVersionHeader) == apiVersion:
+		prefix = api.VersionedPath(apiVersion, "")
+	case IsNotFound(err):
+		log.Warnf("perceptor at %s doesn't support API %s; using unversioned paths", client.baseURL, apiVersion)
+		prefix = ""
+	case err != nil:
+		return "", fmt.Errorf("unable to negotiate API version with perceptor at %s: %s", client.baseURL, err.Error())
+	default:
+		return "", fmt.Errorf("unable to negotiate API version with perceptor at %s: no %s header", client.baseURL, api.APIVersionHeader)
+	}
+	client.prefix = &prefix
+	return prefix, nil
+}
+
+// do makes the request, retrying it with exponential backoff if it's
+// idempotent.  It returns the status code of the last attempt.
+func (client *Client) do(req *request) (int, error) {
+	prefix, err := client.pathPrefix()
+	if err != nil {
+		return 0, err
+	}
+	attempts := 1
+	if req.idempotent {
+		attempts += client.maxRetries
+	}
+	backoff := client.retryBackoff
+	for attempt := 1; ; attempt++ {
+		response, body, err := client.send(req, prefix)
+		statusCode := 0
+		if response != nil {
+			statusCode = response.StatusCode
+		}
+		if err == nil && req.result != nil && len(body) > 0 {
+			if err = json.Unmarshal(body, req.result); err != nil {
+				return statusCode, fmt.Errorf("unable to unmarshal response to %s %s: %s", req.method, req.route, err.Error())
+			}
+		}
+		if err == nil || attempt >= attempts || !isRetryable(err) {
+			return statusCode, err
+		}
+		wait := backoff
+		if apiErr, ok := err.(*Error); ok && apiErr.RetryAfter > wait {
+			wait = apiErr.RetryAfter
+		}
+		if wait > maxRetryBackoff {
+			wait = maxRetryBackoff
+		}
+		log.Debugf("retrying %s %s in %s after attempt %d failed: %s", req.method, req.route, wait, attempt, err.Error())
+		client.sleep(wait)
+		backoff *= 2
+	}
+}
+
+// send makes one attempt at the request.  Responses other than 2xx are
+// returned along with an *Error.
+func (client *Client) send(req *request, prefix string) (*http.Response, []byte, error) {
+	var bodyReader io.Reader
+	contentType := ""
+	switch body := req.body.(type) {
+	case nil:
+	case string:
+		bodyReader = strings.NewReader(body)
+		contentType = "text/plain"
+	default:
+		jsonBytes, err := json.Marshal(body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to marshal body of %s %s: %s", req.method, req.route, err.Error())
+		}
+		bodyReader = bytes.NewReader(jsonBytes)
+		contentType = "application/json"
+	}
+	u := *client.baseURL
+	u.Path = client.baseURL.Path + prefix + req.route
+	u.RawQuery = req.query.Encode()
+
+	timeout := client.timeout
+	if req.timeout > 0 {
+		timeout = req.timeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	httpRequest, err := http.NewRequestWithContext(ctx, req.method, u.String(), bodyReader)
+	if err != nil {
+		return nil, nil, err
+	}
+	httpRequest.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		httpRequest.Header.Set("Content-Type", contentType)
+	}
+	if client.token != "" {
+		httpRequest.Header.Set("Authorization", "Bearer "+client.token)
+	}
+	response, err := client.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer response.Body.Close()
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return response, nil, fmt.Errorf("unable to read response to %s %s: %s", req.method, req.route, err.Error())
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response, responseBody, newError(req, response, responseBody)
+	}
+	return response, responseBody, nil
+}
@@ -0,0 +1,184 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// requestLog records the path of every request a test server receives.
+type requestLog struct {
+	mutex sync.Mutex
+	paths []string
+}
+
+func (rl *requestLog) wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rl.mutex.Lock()
+		rl.paths = append(rl.paths, r.Method+" "+r.URL.Path)
+		rl.mutex.Unlock()
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func (rl *requestLog) Paths() []string {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	return append([]string{}, rl.paths...)
+}
+
+func RunClientTests() {
+	Describe("perceptor client", func() {
+		// the real handlers can only be registered once per process
+		responder := api.NewMockResponder()
+		api.SetupHTTPServer(responder)
+		sha := func(c string) string { return strings.Repeat(c, 64) }
+		var server *httptest.Server
+		var requests *requestLog
+		var sleeps []time.Duration
+		serve := func(handler http.Handler) {
+			requests = &requestLog{}
+			server = httptest.NewServer(requests.wrap(handler))
+		}
+		newClient := func(config *Config) *Client {
+			config.BaseURL = server.URL
+			client, err := NewClient(config)
+			Expect(err).To(BeNil())
+			sleeps = []time.Duration{}
+			client.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+			return client
+		}
+		BeforeEach(func() {
+			responder.Pods = map[string]*api.Pod{}
+			responder.Images = map[string]api.ImageInfo{}
+		})
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("rejects invalid base URLs", func() {
+			serve(http.DefaultServeMux)
+			_, err := NewClient(&Config{BaseURL: "perceptor:3001"})
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("manages pods", func() {
+			serve(http.DefaultServeMux)
+			client := newClient(&Config{})
+			pod := api.Pod{Name: "pod1", Namespace: "ns1", UID: "uid1", Containers: []api.Container{{Name: "c1", Image: api.Image{Repository: "repo", Sha: sha("a")}}}}
+			Expect(client.AddPod(pod)).To(BeNil())
+			pod.Containers = append(pod.Containers, api.Container{Name: "c2", Image: api.Image{Repository: "repo", Sha: sha("b")}})
+			result, err := client.UpdatePod(pod)
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(&api.PodUpsertResult{Outcome: api.PodUpsertOutcomeUpdated, EnqueuedImages: []string{sha("b")}}))
+			scanResults, err := client.GetScanResults(ScanResultsOptions{Namespace: "ns1"})
+			Expect(err).To(BeNil())
+			Expect(scanResults.Pods).To(HaveLen(1))
+
+			deferred, err := client.DeletePod("ns1/pod1")
+			Expect(err).To(BeNil())
+			Expect(deferred).To(BeFalse())
+			_, err = client.DeletePod("ns1/pod1")
+			Expect(IsNotFound(err)).To(BeTrue())
+			Expect(err.(*Error).Response.Code).To(Equal(api.ErrorCodeNotFound))
+
+			Expect(client.AllPods([]api.Pod{pod})).To(BeNil())
+			Expect(responder.Pods).To(HaveKey("ns1/pod1"))
+			Expect(requests.Paths()[0]).To(Equal("GET /api/v1/healthz"))
+			Expect(requests.Paths()[1]).To(Equal("POST /api/v1/pod"))
+		})
+
+		It("manages images and scans", func() {
+			serve(http.DefaultServeMux)
+			client := newClient(&Config{})
+			Expect(client.AddImage(api.Image{Repository: "repo", Sha: sha("a")})).To(BeNil())
+			result, err := client.AddImages([]api.Image{{Repository: "repo", Sha: sha("a")}, {Repository: "repo", Sha: sha("b")}}, false)
+			Expect(err).To(BeNil())
+			Expect(result.Added).To(Equal(1))
+			Expect(result.AlreadyKnown).To(Equal(1))
+			Expect(responder.Images).To(HaveLen(2))
+
+			nextImage, err := client.NextImage(time.Second)
+			Expect(err).To(BeNil())
+			Expect(nextImage.ImageSpec).NotTo(BeNil())
+			Expect(client.AcknowledgeLease(nextImage.ImageSpec, "scanner-1")).To(BeNil())
+			Expect(client.FinishScan(api.FinishedScanClientJob{ImageSpec: *nextImage.ImageSpec})).To(BeNil())
+		})
+
+		It("falls back to unversioned paths on servers which predate versioning", func() {
+			serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasPrefix(r.URL.Path, "/api/") {
+					http.NotFound(w, r)
+					return
+				}
+				http.DefaultServeMux.ServeHTTP(w, r)
+			}))
+			client := newClient(&Config{})
+			_, err := client.GetModel()
+			Expect(err).To(BeNil())
+			Expect(requests.Paths()).To(Equal([]string{"GET /api/v1/healthz", "GET /model"}))
+		})
+
+		It("sends the auth token", func() {
+			serve(api.NewTokenAuthenticator([]string{"secret"}, nil).Wrap(http.DefaultServeMux))
+			_, err := newClient(&Config{}).GetModel()
+			Expect(err).NotTo(BeNil())
+			_, err = newClient(&Config{Token: "secret"}).GetModel()
+			Expect(err).To(BeNil())
+		})
+
+		It("retries idempotent calls with backoff, but not others", func() {
+			failures := 0
+			serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v1/healthz" && failures > 0 {
+					failures--
+					w.Header().Set("Retry-After", "2")
+					api.WriteError(w, r, &api.CapacityError{}, http.StatusServiceUnavailable, false)
+					return
+				}
+				http.DefaultServeMux.ServeHTTP(w, r)
+			}))
+			client := newClient(&Config{RetryBackoff: time.Second})
+			failures = 3
+			_, err := client.GetModel()
+			Expect(err).To(BeNil())
+			Expect(sleeps).To(Equal([]time.Duration{2 * time.Second, 2 * time.Second, 4 * time.Second}))
+
+			failures = 4
+			_, err = client.GetModel()
+			Expect(err.(*Error).StatusCode).To(Equal(http.StatusServiceUnavailable))
+
+			failures = 1
+			sleeps = []time.Duration{}
+			Expect(client.FinishScan(api.FinishedScanClientJob{})).NotTo(BeNil())
+			Expect(sleeps).To(BeEmpty())
+		})
+	})
+}
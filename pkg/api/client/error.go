@@ -0,0 +1,82 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+// Error is a response from perceptor other than a 2xx.
+type Error struct {
+	Method     string
+	Route      string
+	StatusCode int
+	// Response is the structured error body; for a plain-text body, only
+	// its Message is set.
+	Response *api.ErrorResponse
+	// RetryAfter is how long the server asked the client to wait, if at all
+	RetryAfter time.Duration
+}
+
+func newError(req *request, response *http.Response, body []byte) *Error {
+	err := &Error{Method: req.method, Route: req.route, StatusCode: response.StatusCode}
+	var errorResponse api.ErrorResponse
+	if json.Unmarshal(body, &errorResponse) == nil && errorResponse.Code != "" {
+		err.Response = &errorResponse
+	} else {
+		err.Response = &api.ErrorResponse{Code: api.ErrorCode(response.Header.Get("X-Perceptor-Error-Code")), Message: string(body)}
+	}
+	if seconds, parseErr := strconv.Atoi(response.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+		err.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return err
+}
+
+func (err *Error) Error() string {
+	message := fmt.Sprintf("%s %s failed with status %d: %s", err.Method, err.Route, err.StatusCode, err.Response.Message)
+	if err.Response.RequestID != "" {
+		message += fmt.Sprintf(" (request %s)", err.Response.RequestID)
+	}
+	return message
+}
+
+// IsNotFound returns whether `err` is a 404 from perceptor.
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*Error)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// isRetryable is true of network errors, and of responses which say the
+// server is overloaded or failed, rather than that the request was bad.
+func isRetryable(err error) bool {
+	apiErr, ok := err.(*Error)
+	if !ok {
+		return true
+	}
+	return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+}
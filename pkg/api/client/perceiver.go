@@ -0,0 +1,123 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+// Re-adding a pod or an image leaves perceptor's model as it was, so those
+// calls are retried along with the GETs, PUTs and DELETEs.
+
+// GetModel .....
+func (client *Client) GetModel() (*api.Model, error) {
+	var model api.Model
+	_, err := client.do(&request{method: "GET", route: "/model", idempotent: true, result: &model})
+	if err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+// AddPod .....
+func (client *Client) AddPod(pod api.Pod) error {
+	_, err := client.do(&request{method: "POST", route: "/pod", body: pod, idempotent: true})
+	return err
+}
+
+// UpdatePod creates the pod if perceptor doesn't know about it, and
+// otherwise updates it.
+func (client *Client) UpdatePod(pod api.Pod) (*api.PodUpsertResult, error) {
+	var result api.PodUpsertResult
+	_, err := client.do(&request{method: "PUT", route: "/pod", body: pod, idempotent: true, result: &result})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DeletePod returns an error satisfying IsNotFound if perceptor didn't know
+// about the pod, and `deferred` if perceptor accepted the deletion but
+// hasn't applied it yet.
+func (client *Client) DeletePod(qualifiedName string) (deferred bool, err error) {
+	statusCode, err := client.do(&request{method: "DELETE", route: "/pod", body: qualifiedName, idempotent: true})
+	if err != nil {
+		return false, err
+	}
+	return statusCode == http.StatusAccepted, nil
+}
+
+// AllPods replaces every pod perceptor knows about with `pods`.
+func (client *Client) AllPods(pods []api.Pod) error {
+	_, err := client.do(&request{method: "PUT", route: "/allpods", body: api.NewAllPods(pods), idempotent: true})
+	return err
+}
+
+// AddImage .....
+func (client *Client) AddImage(image api.Image) error {
+	_, err := client.do(&request{method: "POST", route: "/image", body: image, idempotent: true})
+	return err
+}
+
+// AddImages adds a batch of images.  Unless `acceptValid` is set, a batch
+// with any invalid image is rejected as a whole.
+func (client *Client) AddImages(images []api.Image, acceptValid bool) (*api.BulkImagesResult, error) {
+	var result api.BulkImagesResult
+	query := url.Values{}
+	if acceptValid {
+		query.Set("acceptValid", strconv.FormatBool(acceptValid))
+	}
+	_, err := client.do(&request{method: "POST", route: "/images", query: query, body: images, idempotent: true, result: &result})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ScanResultsOptions narrows down GetScanResults; its zero value matches
+// everything.
+type ScanResultsOptions struct {
+	Namespace     string
+	PodNamePrefix string
+	// LabelSelector uses the kubernetes syntax, such as "app=web,tier!=db"
+	LabelSelector string
+}
+
+// GetScanResults .....
+func (client *Client) GetScanResults(options ScanResultsOptions) (*api.ScanResults, error) {
+	query := url.Values{}
+	for key, value := range map[string]string{"namespace": options.Namespace, "podPrefix": options.PodNamePrefix, "labelSelector": options.LabelSelector} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	var scanResults api.ScanResults
+	_, err := client.do(&request{method: "GET", route: "/scanresults", query: query, idempotent: true, result: &scanResults})
+	if err != nil {
+		return nil, err
+	}
+	return &scanResults, nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package client
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+// Handing out an image, acknowledging its lease and finishing a scan all
+// change the scan queue, so none of them is retried.
+
+// NextImage asks for an image to scan.  If `wait` is positive and no image
+// is available, perceptor holds the request for up to `wait` (capped at
+// api.MaxNextImageWait) until one is.  ImageSpec is nil if there's nothing
+// to scan.
+func (client *Client) NextImage(wait time.Duration) (*api.NextImage, error) {
+	query := url.Values{}
+	timeout := time.Duration(0)
+	if wait > 0 {
+		query.Set("wait", wait.String())
+		timeout = client.timeout + wait
+	}
+	var nextImage api.NextImage
+	_, err := client.do(&request{method: "POST", route: "/nextimage", query: query, timeout: timeout, result: &nextImage})
+	if err != nil {
+		return nil, err
+	}
+	return &nextImage, nil
+}
+
+// AcknowledgeLease tells perceptor that the image `imageSpec` was handed out
+// with is being scanned, so that it isn't handed to another scan client once
+// the lease's TTL is up.
+func (client *Client) AcknowledgeLease(imageSpec *api.ImageSpec, scanner string) error {
+	ack := api.ScanLeaseAcknowledgement{Sha: imageSpec.Sha, LeaseID: imageSpec.LeaseID, Scanner: scanner}
+	_, err := client.do(&request{method: "POST", route: "/nextimage/ack", body: ack})
+	return err
+}
+
+// FinishScan reports the outcome of a scan client job.
+func (client *Client) FinishScan(job api.FinishedScanClientJob) error {
+	_, err := client.do(&request{method: "POST", route: "/finishedscan", body: job})
+	return err
+}
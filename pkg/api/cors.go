@@ -0,0 +1,129 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSPolicy says which browser origins may call the API.  Only read-only
+// requests are allowed, unless AllowMutatingRoutes is set.
+type CORSPolicy struct {
+	// AllowedOrigins are exact origins, such as "https://ui.example.com",
+	// wildcard subdomains, such as "https://*.example.com", or "*".
+	AllowedOrigins      []string
+	AllowedHeaders      []string
+	AllowMutatingRoutes bool
+	MaxAgeSeconds       int
+}
+
+var readOnlyMethods = []string{"GET", "HEAD"}
+var mutatingMethods = []string{"POST", "PUT", "DELETE"}
+
+// exposedHeaders are the response headers which browser code may read.
+var exposedHeaders = []string{"X-Perceptor-Error-Code", APIVersionHeader, "Retry-After", "Deprecation", "Link", RequestIDHeader}
+
+// allowsOrigin matches `origin` against the exact and wildcard origins.
+func (policy *CORSPolicy) allowsOrigin(origin string) bool {
+	for _, allowed := range policy.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		// "https://*.example.com" => scheme "https://", domain ".example.com"
+		wildcard := strings.Index(allowed, "://*.")
+		if wildcard < 0 {
+			continue
+		}
+		scheme := allowed[:wildcard+len("://")]
+		domain := allowed[wildcard+len("://*"):]
+		if strings.HasPrefix(origin, scheme) && strings.HasSuffix(origin, domain) && len(origin) > len(scheme)+len(domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func (policy *CORSPolicy) allowedMethods() []string {
+	if policy.AllowMutatingRoutes {
+		return append(append([]string{}, readOnlyMethods...), mutatingMethods...)
+	}
+	return readOnlyMethods
+}
+
+func (policy *CORSPolicy) allowsMethod(method string) bool {
+	for _, allowed := range policy.allowedMethods() {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+// CORSHandler adds CORS headers for allowed origins, and answers preflight
+// requests itself.  Requests without an Origin header aren't affected.  A
+// nil policy disables CORS.
+func CORSHandler(handler http.Handler, policy *CORSPolicy) http.Handler {
+	if policy == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		requestedMethod := r.Header.Get("Access-Control-Request-Method")
+		if r.Method == "OPTIONS" && requestedMethod != "" {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			if !policy.allowsOrigin(origin) || !policy.allowsMethod(requestedMethod) {
+				recordCORSRejection(r)
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			header.Set("Access-Control-Allow-Origin", origin)
+			header.Set("Access-Control-Allow-Methods", strings.Join(policy.allowedMethods(), ", "))
+			if len(policy.AllowedHeaders) > 0 {
+				header.Set("Access-Control-Allow-Headers", strings.Join(policy.AllowedHeaders, ", "))
+			}
+			if policy.MaxAgeSeconds > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(policy.MaxAgeSeconds))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if policy.allowsOrigin(origin) && policy.allowsMethod(r.Method) {
+			header.Set("Access-Control-Allow-Origin", origin)
+			header.Set("Access-Control-Expose-Headers", strings.Join(exposedHeaders, ", "))
+		} else {
+			recordCORSRejection(r)
+		}
+		// without the allow header, the browser won't let the page see the
+		// response; the request is still served, as it would be for a
+		// non-browser client
+		handler.ServeHTTP(w, r)
+	})
+}
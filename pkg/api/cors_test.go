@@ -0,0 +1,105 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunCORSTests() {
+	Describe("CORS", func() {
+		okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+		})
+		policy := &CORSPolicy{
+			AllowedOrigins: []string{"https://status.example.com", "https://*.ui.example.com"},
+			AllowedHeaders: []string{"Authorization", "Content-Type"},
+			MaxAgeSeconds:  600,
+		}
+		serve := func(policy *CORSPolicy, method string, origin string, requestedMethod string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest(method, "/model", nil)
+			if origin != "" {
+				request.Header.Set("Origin", origin)
+			}
+			if requestedMethod != "" {
+				request.Header.Set("Access-Control-Request-Method", requestedMethod)
+			}
+			recorder := httptest.NewRecorder()
+			CORSHandler(okHandler, policy).ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		It("answers preflight requests from allowed origins", func() {
+			recorder := serve(policy, "OPTIONS", "https://status.example.com", "GET")
+			Expect(recorder.Code).To(Equal(204))
+			Expect(recorder.Header().Get("Access-Control-Allow-Origin")).To(Equal("https://status.example.com"))
+			Expect(recorder.Header().Get("Access-Control-Allow-Methods")).To(Equal("GET, HEAD"))
+			Expect(recorder.Header().Get("Access-Control-Allow-Headers")).To(Equal("Authorization, Content-Type"))
+			Expect(recorder.Header().Get("Access-Control-Max-Age")).To(Equal("600"))
+		})
+
+		It("matches wildcard subdomains", func() {
+			Expect(serve(policy, "OPTIONS", "https://a.ui.example.com", "GET").Code).To(Equal(204))
+			Expect(serve(policy, "OPTIONS", "https://a.b.ui.example.com", "GET").Code).To(Equal(204))
+			Expect(serve(policy, "OPTIONS", "https://ui.example.com", "GET").Code).To(Equal(403))
+			Expect(serve(policy, "OPTIONS", "http://a.ui.example.com", "GET").Code).To(Equal(403))
+			Expect(serve(policy, "OPTIONS", "https://a.ui.example.com.evil.com", "GET").Code).To(Equal(403))
+		})
+
+		It("rejects preflight requests from disallowed origins", func() {
+			recorder := serve(policy, "OPTIONS", "https://evil.com", "GET")
+			Expect(recorder.Code).To(Equal(403))
+			Expect(recorder.Header().Get("Access-Control-Allow-Origin")).To(Equal(""))
+		})
+
+		It("doesn't allow disallowed origins to read responses", func() {
+			recorder := serve(policy, "GET", "https://evil.com", "")
+			Expect(recorder.Code).To(Equal(200))
+			Expect(recorder.Header().Get("Access-Control-Allow-Origin")).To(Equal(""))
+			recorder = serve(policy, "GET", "https://status.example.com", "")
+			Expect(recorder.Header().Get("Access-Control-Allow-Origin")).To(Equal("https://status.example.com"))
+			Expect(recorder.Header().Get("Access-Control-Expose-Headers")).To(ContainSubstring("X-Perceptor-Error-Code"))
+			Expect(recorder.Header()["Vary"]).To(ContainElement("Origin"))
+		})
+
+		It("only allows mutating routes if opted in", func() {
+			Expect(serve(policy, "OPTIONS", "https://status.example.com", "PUT").Code).To(Equal(403))
+			Expect(serve(policy, "POST", "https://status.example.com", "").Header().Get("Access-Control-Allow-Origin")).To(Equal(""))
+			mutating := &CORSPolicy{AllowedOrigins: []string{"*"}, AllowMutatingRoutes: true}
+			recorder := serve(mutating, "OPTIONS", "https://status.example.com", "PUT")
+			Expect(recorder.Code).To(Equal(204))
+			Expect(recorder.Header().Get("Access-Control-Allow-Methods")).To(Equal("GET, HEAD, POST, PUT, DELETE"))
+		})
+
+		It("is off by default", func() {
+			recorder := serve(nil, "OPTIONS", "https://status.example.com", "GET")
+			// the request goes straight to the handler
+			Expect(recorder.Code).To(Equal(200))
+			Expect(recorder.Header().Get("Access-Control-Allow-Origin")).To(Equal(""))
+			Expect(serve(nil, "GET", "https://status.example.com", "").Header().Get("Access-Control-Allow-Origin")).To(Equal(""))
+		})
+	})
+}
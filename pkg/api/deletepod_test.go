@@ -0,0 +1,81 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// deferringResponder accepts every deletion but applies none of them
+// straight away, as the model does when its action queue is full.
+type deferringResponder struct {
+	*MockResponder
+}
+
+func (dr *deferringResponder) DeletePod(qualifiedName string) (bool, error) {
+	return true, nil
+}
+
+func RunDeletePodTests() {
+	Describe("DELETE /pod", func() {
+		var responder *MockResponder
+		BeforeEach(func() {
+			responder = NewMockResponder()
+			Expect(responder.AddPod(Pod{Name: "pod1", Namespace: "ns1"})).To(BeNil())
+		})
+		deletePod := func(responder Responder, qualifiedName string) *httptest.ResponseRecorder {
+			mux := http.NewServeMux()
+			setupHandlers(mux, responder)
+			request := httptest.NewRequest("DELETE", "/api/v1/pod", strings.NewReader(qualifiedName))
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		It("returns 200 when the pod was removed", func() {
+			recorder := deletePod(responder, "ns1/pod1")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(responder.Pods).To(BeEmpty())
+		})
+
+		It("returns 404 with an error body when the pod isn't present", func() {
+			Expect(deletePod(responder, "ns1/pod1").Code).To(Equal(http.StatusOK))
+			recorder := deletePod(responder, "ns1/pod1")
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			var errorResponse ErrorResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &errorResponse)).To(BeNil())
+			Expect(errorResponse.Code).To(Equal(ErrorCodeNotFound))
+			Expect(errorResponse.Details).To(Equal(map[string]interface{}{"pod": "ns1/pod1"}))
+		})
+
+		It("returns 202 when the deletion was deferred", func() {
+			recorder := deletePod(&deferringResponder{MockResponder: responder}, "ns1/pod1")
+			Expect(recorder.Code).To(Equal(http.StatusAccepted))
+		})
+	})
+}
@@ -0,0 +1,69 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+)
+
+// entityTag identifies a response built from the model at `generation`.
+// The query and representation are hashed in, along with `extra` -- any
+// parts of the response which can change without the generation changing.
+func entityTag(r *http.Request, generation int64, extra ...interface{}) (string, error) {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%s\n%t\n", r.URL.Query().Encode(), wantsYAML(r))
+	for _, value := range extra {
+		jsonBytes, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		hash.Write(jsonBytes)
+	}
+	return fmt.Sprintf(`"%d-%x"`, generation, hash.Sum64()), nil
+}
+
+// matchesETag implements the weak comparison If-None-Match calls for.
+func matchesETag(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNotModified sets the response's ETag.  If the client already has
+// that version, it sends a 304 with no body, and returns true.
+func writeNotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch == "" || !matchesETag(ifNoneMatch, etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
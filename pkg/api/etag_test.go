@@ -0,0 +1,93 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunETagTests() {
+	Describe("ETags", func() {
+		var responder *MockResponder
+		var handler http.Handler
+		BeforeEach(func() {
+			responder = NewMockResponder()
+			mux := http.NewServeMux()
+			setupHandlers(mux, responder)
+			handler = GzipHandler(mux, 1)
+		})
+		get := func(path string, ifNoneMatch string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest("GET", path, nil)
+			request.Header.Set("Accept-Encoding", "gzip")
+			if ifNoneMatch != "" {
+				request.Header.Set("If-None-Match", ifNoneMatch)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		It("answers a matching If-None-Match on scan results with a 304 and no body", func() {
+			first := get("/api/v1/scanresults", "")
+			Expect(first.Code).To(Equal(http.StatusOK))
+			etag := first.Header().Get("ETag")
+			Expect(etag).ToNot(BeEmpty())
+			second := get("/api/v1/scanresults", etag)
+			Expect(second.Code).To(Equal(http.StatusNotModified))
+			Expect(second.Body.Len()).To(Equal(0))
+			Expect(second.Header().Get("ETag")).To(Equal(etag))
+			Expect(get("/api/v1/scanresults", `"other", W/`+etag).Code).To(Equal(http.StatusNotModified))
+			Expect(get("/api/v1/scanresults", "*").Code).To(Equal(http.StatusNotModified))
+		})
+
+		It("changes the ETag when the model changes, but not otherwise", func() {
+			etag := get("/api/v1/scanresults", "").Header().Get("ETag")
+			responder.GetConfig()
+			responder.GetMetricsSummary()
+			Expect(get("/api/v1/scanresults", etag).Code).To(Equal(http.StatusNotModified))
+			Expect(responder.AddPod(Pod{Name: "pod1", Namespace: "ns1", UID: "uid1", Containers: []Container{}})).To(BeNil())
+			changed := get("/api/v1/scanresults", etag)
+			Expect(changed.Code).To(Equal(http.StatusOK))
+			Expect(changed.Header().Get("ETag")).ToNot(Equal(etag))
+		})
+
+		It("gives each query and representation its own ETag", func() {
+			etag := get("/api/v1/scanresults", "").Header().Get("ETag")
+			Expect(get("/api/v1/scanresults?namespace=ns1", "").Header().Get("ETag")).ToNot(Equal(etag))
+			Expect(get("/api/v1/scanresults?format=yaml", etag).Code).To(Equal(http.StatusOK))
+		})
+
+		It("sends an ETag for the model", func() {
+			mux := http.NewServeMux()
+			setupHandlers(mux, &fixtureModelResponder{MockResponder: responder})
+			handler = mux
+			etag := get("/api/v1/model", "").Header().Get("ETag")
+			Expect(etag).ToNot(BeEmpty())
+			Expect(get("/api/v1/model", etag).Code).To(Equal(http.StatusNotModified))
+			Expect(get("/api/v1/model?include=pods", etag).Code).To(Equal(http.StatusOK))
+		})
+	})
+}
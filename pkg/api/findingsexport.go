@@ -0,0 +1,160 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// FindingsExportFormat .....
+type FindingsExportFormat string
+
+// .....
+const (
+	FindingsExportFormatCSV    FindingsExportFormat = "csv"
+	FindingsExportFormatNDJSON FindingsExportFormat = "ndjson"
+)
+
+var findingsExportContentTypes = map[FindingsExportFormat]string{
+	FindingsExportFormatCSV:    "text/csv; charset=utf-8",
+	FindingsExportFormatNDJSON: "application/x-ndjson",
+}
+
+// FindingsExportQuery selects the pods to export findings for, and the file
+// format to export them in.
+type FindingsExportQuery struct {
+	Namespace     string
+	PodNamePrefix string
+	LabelSelector []*LabelRequirement
+	Format        FindingsExportFormat
+}
+
+// ParseFindingsExportQuery reads the `namespace`, `podPrefix`,
+// `labelSelector` and `format` query parameters; the format defaults to csv.
+func ParseFindingsExportQuery(values url.Values) (*FindingsExportQuery, error) {
+	filter, err := ParseScanResultsQuery(values)
+	if err != nil {
+		return nil, err
+	}
+	query := &FindingsExportQuery{
+		Namespace:     filter.Namespace,
+		PodNamePrefix: filter.PodNamePrefix,
+		LabelSelector: filter.LabelSelector,
+		Format:        FindingsExportFormatCSV,
+	}
+	if format := values.Get("format"); format != "" {
+		query.Format = FindingsExportFormat(format)
+		if _, ok := findingsExportContentTypes[query.Format]; !ok {
+			return nil, &ValidationError{
+				Message: fmt.Sprintf("invalid format %s", format),
+				Fields:  []FieldError{{Field: "format", Problem: "must be csv or ndjson"}},
+			}
+		}
+	}
+	return query, nil
+}
+
+// Filter .....
+func (query *FindingsExportQuery) Filter() *ScanResultsQuery {
+	return &ScanResultsQuery{Namespace: query.Namespace, PodNamePrefix: query.PodNamePrefix, LabelSelector: query.LabelSelector}
+}
+
+// Finding is one row of a findings export: a scanned image of a pod.
+// Counts are of vulnerable components, by risk.
+type Finding struct {
+	Pod          string
+	Namespace    string
+	Image        string
+	Sha          string
+	Critical     int
+	High         int
+	Medium       int
+	Low          int
+	PolicyStatus string
+	LastScanned  string
+}
+
+var findingsCSVHeader = []string{"pod", "namespace", "image", "sha", "critical", "high", "medium", "low", "policyStatus", "lastScanned"}
+
+// csvText stops spreadsheets from treating a value as a formula.
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func (finding *Finding) csvRecord() []string {
+	return []string{
+		csvText(finding.Pod),
+		csvText(finding.Namespace),
+		csvText(finding.Image),
+		csvText(finding.Sha),
+		strconv.Itoa(finding.Critical),
+		strconv.Itoa(finding.High),
+		strconv.Itoa(finding.Medium),
+		strconv.Itoa(finding.Low),
+		csvText(finding.PolicyStatus),
+		csvText(finding.LastScanned),
+	}
+}
+
+// writeFindingsExport streams findings to the client as they're produced.
+// Once the first row is written, errors can't be reported in the response,
+// so they cut it off instead.
+func writeFindingsExport(w http.ResponseWriter, r *http.Request, responder Responder, query *FindingsExportQuery) {
+	header := w.Header()
+	header.Set(http.CanonicalHeaderKey("content-type"), findingsExportContentTypes[query.Format])
+	header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="findings.%s"`, query.Format))
+	var emit func(*Finding) error
+	var flush func() error
+	switch query.Format {
+	case FindingsExportFormatNDJSON:
+		encoder := json.NewEncoder(w)
+		emit = func(finding *Finding) error { return encoder.Encode(finding) }
+		flush = func() error { return nil }
+	default:
+		csvWriter := csv.NewWriter(w)
+		emit = func(finding *Finding) error { return csvWriter.Write(finding.csvRecord()) }
+		flush = func() error {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+		if err := csvWriter.Write(findingsCSVHeader); err != nil {
+			RequestLogger(r).Warnf("unable to write findings export: %s", err.Error())
+			return
+		}
+	}
+	err := responder.ExportFindings(query, emit)
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		RequestLogger(r).Warnf("findings export cut off: %s", err.Error())
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunFindingsExportTests() {
+	Describe("GET /export/findings", func() {
+		var mux *http.ServeMux
+		BeforeEach(func() {
+			responder := NewMockResponder()
+			responder.Pods["ns1/pod1"] = &Pod{Name: "pod1", Namespace: "ns1", Containers: []Container{
+				{Name: "c1", Image: Image{Repository: "=cmd|' /C calc'!A0", Tag: "a,\"b\"", Sha: "sha1"}},
+			}}
+			responder.Pods["ns2/pod2"] = &Pod{Name: "pod2", Namespace: "ns2", Containers: []Container{
+				{Name: "c2", Image: Image{Repository: "nginx", Tag: "1.15", Sha: "sha2"}},
+			}}
+			responder.Images["sha1"] = ImageInfo{Image: Image{Sha: "sha1"}, Vulnerabilities: 3, OverallStatus: "IN_VIOLATION"}
+			responder.Images["sha2"] = ImageInfo{Image: Image{Sha: "sha2"}, Vulnerabilities: 0, OverallStatus: "NOT_IN_VIOLATION"}
+			mux = http.NewServeMux()
+			setupHandlers(mux, responder)
+		})
+		get := func(path string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+			return recorder
+		}
+
+		It("exports CSV by default, as an attachment, with fields escaped", func() {
+			recorder := get("/api/v1/export/findings")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("text/csv; charset=utf-8"))
+			Expect(recorder.Header().Get("Content-Disposition")).To(Equal(`attachment; filename="findings.csv"`))
+			records, err := csv.NewReader(recorder.Body).ReadAll()
+			Expect(err).To(BeNil())
+			Expect(records).To(Equal([][]string{
+				{"pod", "namespace", "image", "sha", "critical", "high", "medium", "low", "policyStatus", "lastScanned"},
+				{"pod1", "ns1", "'=cmd|' /C calc'!A0:a,\"b\"", "sha1", "0", "3", "0", "0", "IN_VIOLATION", ""},
+				{"pod2", "ns2", "nginx:1.15", "sha2", "0", "0", "0", "0", "NOT_IN_VIOLATION", ""},
+			}))
+		})
+
+		It("exports NDJSON, filtered like scan results", func() {
+			recorder := get("/api/v1/export/findings?format=ndjson&namespace=ns2")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/x-ndjson"))
+			Expect(recorder.Header().Get("Content-Disposition")).To(Equal(`attachment; filename="findings.ndjson"`))
+			lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n")
+			Expect(lines).To(HaveLen(1))
+			var finding Finding
+			Expect(json.Unmarshal([]byte(lines[0]), &finding)).To(BeNil())
+			Expect(finding).To(Equal(Finding{Pod: "pod2", Namespace: "ns2", Image: "nginx:1.15", Sha: "sha2", PolicyStatus: "NOT_IN_VIOLATION"}))
+		})
+
+		It("rejects unknown formats", func() {
+			recorder := get("/api/v1/export/findings?format=xlsx")
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			var response ErrorResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(BeNil())
+			Expect(response.Code).To(Equal(ErrorCodeValidation))
+		})
+	})
+}
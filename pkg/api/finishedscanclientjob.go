@@ -25,4 +25,78 @@ package api
 type FinishedScanClientJob struct {
 	ImageSpec ImageSpec
 	Err       string
+	// Scanner optionally identifies the scan client which ran the job
+	Scanner string
+	// FailureCategory optionally classifies Err; see ScanFailureCategoryPullFailed
+	// and friends
+	FailureCategory string
+	// Timings is nil if the scan client didn't measure the job
+	Timings *ScanClientJobTimings
+	// DryRun is set by scan clients which only simulated the scan, for load
+	// testing; perceptor never treats such jobs as real scans
+	DryRun bool
+	// Failure optionally details Err
+	Failure *ScanClientFailure
+	// the versions of the scanners which ran the job, if the scan client
+	// knows them
+	ScanCliVersion          string
+	SignatureScannerVersion string
+}
+
+// Perceptor keeps at most this much of a failed job's stderr, whatever the
+// scan client sends.
+const (
+	MaxScanClientFailureStderrLines      = 50
+	MaxScanClientFailureStderrLineLength = 500
+)
+
+// ScanClientFailure details why a scan client job failed.  Scan clients
+// should scrub Stderr of credentials before sending it.
+type ScanClientFailure struct {
+	// Category is one of ScanFailureCategoryPullFailed and friends
+	Category string
+	// Stage is the step of the job which failed, such as pulling the image
+	Stage string
+	// ExitCode is scan.cli's, and nil if it never ran
+	ExitCode *int
+	// Stderr holds the last lines written to stderr, oldest first
+	Stderr  []string
+	Scanner string
+	// ExpectedDigest and ActualDigest are set for DigestMismatch failures
+	ExpectedDigest string
+	ActualDigest   string
+	// ImageSizeBytes and MaxImageSizeBytes are set for ImageTooLarge failures
+	ImageSizeBytes    int64
+	MaxImageSizeBytes int64
+}
+
+// Capped returns a copy of the failure holding no more than the last
+// MaxScanClientFailureStderrLines lines of stderr, each cut to
+// MaxScanClientFailureStderrLineLength bytes.
+func (failure *ScanClientFailure) Capped() *ScanClientFailure {
+	capped := *failure
+	stderr := failure.Stderr
+	if len(stderr) > MaxScanClientFailureStderrLines {
+		stderr = stderr[len(stderr)-MaxScanClientFailureStderrLines:]
+	}
+	capped.Stderr = make([]string, len(stderr))
+	for i, line := range stderr {
+		if len(line) > MaxScanClientFailureStderrLineLength {
+			line = line[:MaxScanClientFailureStderrLineLength]
+		}
+		capped.Stderr[i] = line
+	}
+	return &capped
+}
+
+// ScanClientJobTimings describes where a scan client job's time and scratch
+// disk went.  Zero means the scan client couldn't measure it, for instance
+// the upload duration if scan.cli didn't report it.
+type ScanClientJobTimings struct {
+	PullSeconds      float64
+	ScanSeconds      float64
+	UploadSeconds    float64
+	TotalSeconds     float64
+	TarSizeBytes     int64
+	PeakScratchBytes int64
 }
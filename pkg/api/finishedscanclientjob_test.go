@@ -0,0 +1,66 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunFinishedScanClientJobTests() {
+	Describe("FinishedScanClientJob", func() {
+		It("unmarshals failure details", func() {
+			jsonString := `{"ImageSpec":{"Sha":"abc"},"Err":"exit status 1","Failure":{"Category":"CliCrashed","Stage":"signatureScan","ExitCode":3,"Stderr":["java.lang.OutOfMemoryError"]}}`
+			var job FinishedScanClientJob
+			Expect(json.Unmarshal([]byte(jsonString), &job)).To(BeNil())
+			Expect(job.Failure.Category).To(Equal(ScanFailureCategoryCliCrashed))
+			Expect(*job.Failure.ExitCode).To(Equal(3))
+			Expect(job.Failure.Stderr).To(Equal([]string{"java.lang.OutOfMemoryError"}))
+		})
+
+		It("caps failure stderr to its last lines", func() {
+			stderr := []string{}
+			for i := 0; i < MaxScanClientFailureStderrLines+5; i++ {
+				stderr = append(stderr, fmt.Sprintf("line %d", i))
+			}
+			stderr = append(stderr, strings.Repeat("x", MaxScanClientFailureStderrLineLength+1))
+			failure := &ScanClientFailure{Category: ScanFailureCategoryUploadFailed, Stderr: stderr}
+			capped := failure.Capped()
+			Expect(capped.Category).To(Equal(ScanFailureCategoryUploadFailed))
+			Expect(capped.Stderr).To(HaveLen(MaxScanClientFailureStderrLines))
+			Expect(capped.Stderr[0]).To(Equal("line 6"))
+			Expect(capped.Stderr[MaxScanClientFailureStderrLines-1]).To(HaveLen(MaxScanClientFailureStderrLineLength))
+			Expect(failure.Stderr).To(HaveLen(MaxScanClientFailureStderrLines + 6))
+		})
+
+		It("classifies failure categories", func() {
+			Expect(IsTerminalScanFailureCategory(ScanFailureCategoryImageUnsupported)).To(BeTrue())
+			Expect(IsTerminalScanFailureCategory(ScanFailureCategoryUploadFailed)).To(BeFalse())
+			Expect(IsTerminalScanFailureCategory("SomethingNew")).To(BeFalse())
+			Expect(IsKnownScanFailureCategory("SomethingNew")).To(BeFalse())
+		})
+	})
+}
@@ -0,0 +1,169 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultGzipMinSize is the smallest response worth compressing; below
+// this, the gzip header and CPU cost outweigh the savings.
+const DefaultGzipMinSize = 1024
+
+// GzipHandler compresses responses of at least `minSize` bytes for clients
+// which accept gzip.  Responses which the wrapped handler has already
+// encoded -- such as prometheus metrics -- are passed through untouched.
+func GzipHandler(handler http.Handler, minSize int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize, statusCode: http.StatusOK}
+		defer gw.close()
+		handler.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip parses Accept-Encoding, honoring q=0 as a refusal.
+func acceptsGzip(r *http.Request) bool {
+	accepted := false
+	for _, header := range r.Header["Accept-Encoding"] {
+		for _, part := range strings.Split(header, ",") {
+			fields := strings.Split(part, ";")
+			coding := strings.ToLower(strings.TrimSpace(fields[0]))
+			if coding != "gzip" && coding != "*" {
+				continue
+			}
+			q := 1.0
+			for _, param := range fields[1:] {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					if value, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+						q = value
+					}
+				}
+			}
+			if coding == "gzip" {
+				// an explicit gzip entry overrides any wildcard
+				return q > 0
+			}
+			accepted = q > 0
+		}
+	}
+	return accepted
+}
+
+// gzipResponseWriter buffers the start of a response, until it knows
+// whether the response is big enough to compress.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize     int
+	statusCode  int
+	buffer      bytes.Buffer
+	decided     bool
+	gzipWriter  *gzip.Writer
+	wroteHeader bool
+}
+
+func (gw *gzipResponseWriter) WriteHeader(statusCode int) {
+	if gw.wroteHeader {
+		return
+	}
+	gw.wroteHeader = true
+	gw.statusCode = statusCode
+}
+
+func (gw *gzipResponseWriter) Write(data []byte) (int, error) {
+	gw.wroteHeader = true
+	if gw.decided {
+		if gw.gzipWriter != nil {
+			return gw.gzipWriter.Write(data)
+		}
+		return gw.ResponseWriter.Write(data)
+	}
+	gw.buffer.Write(data)
+	if gw.buffer.Len() >= gw.minSize {
+		if err := gw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// Flush sends what's been buffered; a response flushed before it reaches
+// the threshold is treated as a stream, and isn't compressed.
+func (gw *gzipResponseWriter) Flush() {
+	if !gw.decided {
+		gw.decide(false)
+	}
+	if gw.gzipWriter != nil {
+		gw.gzipWriter.Flush()
+	}
+	if flusher, ok := gw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (gw *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
+
+func (gw *gzipResponseWriter) decide(compress bool) error {
+	gw.decided = true
+	header := gw.Header()
+	if header.Get("Content-Encoding") != "" || gw.statusCode == http.StatusNoContent || gw.statusCode == http.StatusNotModified {
+		compress = false
+	}
+	if compress {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		if header.Get("Content-Type") == "" {
+			// otherwise, http would sniff the compressed bytes
+			header.Set("Content-Type", http.DetectContentType(gw.buffer.Bytes()))
+		}
+		gw.ResponseWriter.WriteHeader(gw.statusCode)
+		gw.gzipWriter = gzip.NewWriter(gw.ResponseWriter)
+		_, err := gw.gzipWriter.Write(gw.buffer.Bytes())
+		gw.buffer.Reset()
+		return err
+	}
+	gw.ResponseWriter.WriteHeader(gw.statusCode)
+	_, err := gw.ResponseWriter.Write(gw.buffer.Bytes())
+	gw.buffer.Reset()
+	return err
+}
+
+func (gw *gzipResponseWriter) close() {
+	if !gw.decided {
+		gw.decide(false)
+	}
+	if gw.gzipWriter != nil {
+		gw.gzipWriter.Close()
+	}
+}
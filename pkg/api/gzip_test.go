@@ -0,0 +1,136 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func writeString(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	})
+}
+
+func serveGzip(handler http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest("GET", "/model", nil)
+	if acceptEncoding != "" {
+		request.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	recorder := httptest.NewRecorder()
+	GzipHandler(handler, 100).ServeHTTP(recorder, request)
+	return recorder
+}
+
+func RunGzipTests() {
+	Describe("gzip", func() {
+		large := strings.Repeat("abcdefgh", 100)
+
+		It("compresses large responses for clients which accept gzip", func() {
+			recorder := serveGzip(writeString(large), "gzip, deflate")
+			Expect(recorder.Header().Get("Content-Encoding")).To(Equal("gzip"))
+			Expect(recorder.Header().Get("Vary")).To(Equal("Accept-Encoding"))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(recorder.Body.Len()).To(BeNumerically("<", len(large)))
+			reader, err := gzip.NewReader(recorder.Body)
+			Expect(err).To(BeNil())
+			body, err := ioutil.ReadAll(reader)
+			Expect(err).To(BeNil())
+			Expect(string(body)).To(Equal(large))
+		})
+
+		It("leaves small responses alone", func() {
+			recorder := serveGzip(writeString("{}"), "gzip")
+			Expect(recorder.Header().Get("Content-Encoding")).To(Equal(""))
+			Expect(recorder.Header().Get("Vary")).To(Equal("Accept-Encoding"))
+			Expect(recorder.Body.String()).To(Equal("{}"))
+		})
+
+		It("falls back to identity for clients which don't accept gzip", func() {
+			for _, acceptEncoding := range []string{"", "identity", "gzip;q=0", "*;q=1, gzip;q=0", "deflate"} {
+				recorder := serveGzip(writeString(large), acceptEncoding)
+				Expect(recorder.Header().Get("Content-Encoding")).To(Equal(""), acceptEncoding)
+				Expect(recorder.Body.String()).To(Equal(large), acceptEncoding)
+			}
+			Expect(serveGzip(writeString(large), "*").Header().Get("Content-Encoding")).To(Equal("gzip"))
+		})
+
+		It("doesn't compress responses which are already encoded", func() {
+			encoded := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "gzip")
+				fmt.Fprint(w, large)
+			})
+			recorder := serveGzip(encoded, "gzip")
+			Expect(recorder.Header().Get("Content-Encoding")).To(Equal("gzip"))
+			Expect(recorder.Body.String()).To(Equal(large))
+		})
+
+		It("keeps the status code", func() {
+			failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(500)
+				fmt.Fprint(w, large)
+			})
+			recorder := serveGzip(failing, "gzip")
+			Expect(recorder.Code).To(Equal(500))
+			Expect(recorder.Header().Get("Content-Encoding")).To(Equal("gzip"))
+			recorder = serveGzip(failing, "")
+			Expect(recorder.Code).To(Equal(500))
+		})
+	})
+}
+
+// BenchmarkGzipModel measures the cost of compressing a ~10MB model.
+func BenchmarkGzipModel(b *testing.B) {
+	model := &CoreModel{Images: map[string]*ModelImageInfo{}}
+	for i := 0; len(model.Images) < 22000; i++ {
+		sha := fmt.Sprintf("%064d", i)
+		model.Images[sha] = &ModelImageInfo{
+			ImageSha:   sha,
+			RepoTags:   []*ModelRepoTag{{Repository: fmt.Sprintf("docker.io/library/image-%d", i), Tag: "latest"}},
+			ScanStatus: "ScanStatusComplete",
+			Priority:   i % 3,
+		}
+	}
+	jsonBytes, err := json.MarshalIndent(model, "", "  ")
+	if err != nil {
+		b.Fatal(err)
+	}
+	handler := writeString(string(jsonBytes))
+	b.SetBytes(int64(len(jsonBytes)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		request := httptest.NewRequest("GET", "/model", nil)
+		request.Header.Set("Accept-Encoding", "gzip")
+		GzipHandler(handler, DefaultGzipMinSize).ServeHTTP(httptest.NewRecorder(), request)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// HealthCheck is the result of checking a single component.
+type HealthCheck struct {
+	Name    string
+	Healthy bool
+	Message string `json:",omitempty"`
+}
+
+// HubHealth describes a hub client's connectivity, as of its most recent
+// action.  The times since the last login and sync are nil if there hasn't
+// been one.
+type HubHealth struct {
+	Host                string
+	Status              string
+	CircuitBreakerState string
+	HasFetchedScans     bool
+	TimeSinceLastLogin  *ModelTime
+	TimeSinceLastSync   *ModelTime
+	RecentErrors        int
+	RecentErrorsWindow  *ModelTime
+}
+
+// Health is healthy only if all of its checks are.  Hubs is informational.
+type Health struct {
+	Healthy bool
+	Checks  []*HealthCheck
+	Hubs    []*HubHealth `json:",omitempty"`
+}
+
+// ReadinessQuery is parsed from the query string of GET /readyz.
+// FailIfAllHubsDown makes perceptor unready when no hub client is up.
+type ReadinessQuery struct {
+	FailIfAllHubsDown bool
+}
+
+// ParseReadinessQuery .....
+func ParseReadinessQuery(values url.Values) (*ReadinessQuery, error) {
+	query := &ReadinessQuery{}
+	if value := values.Get("failIfAllHubsDown"); value != "" {
+		failIfAllHubsDown, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, NewValidationError("invalid failIfAllHubsDown %q: expected true or false", value)
+		}
+		query.FailIfAllHubsDown = failIfAllHubsDown
+	}
+	return query, nil
+}
+
+// NewHealth .....
+func NewHealth(checks []*HealthCheck) *Health {
+	healthy := true
+	for _, check := range checks {
+		if !check.Healthy {
+			healthy = false
+		}
+	}
+	return &Health{Healthy: healthy, Checks: checks}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunHealthTests() {
+	Describe("readiness query", func() {
+		It("defaults to not failing when all hubs are down", func() {
+			query, err := ParseReadinessQuery(url.Values{})
+			Expect(err).To(BeNil())
+			Expect(query.FailIfAllHubsDown).To(BeFalse())
+		})
+
+		It("parses failIfAllHubsDown", func() {
+			query, err := ParseReadinessQuery(url.Values{"failIfAllHubsDown": {"true"}})
+			Expect(err).To(BeNil())
+			Expect(query.FailIfAllHubsDown).To(BeTrue())
+		})
+
+		It("rejects an invalid failIfAllHubsDown with a 400", func() {
+			mux := http.NewServeMux()
+			setupHandlers(mux, NewMockResponder())
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/readyz?failIfAllHubsDown=sometimes", nil))
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+}
@@ -0,0 +1,53 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import "fmt"
+
+// HubLoginOutcome describes how an on-demand hub login went.
+type HubLoginOutcome string
+
+// .....
+const (
+	HubLoginOutcomeSuccess     HubLoginOutcome = "success"
+	HubLoginOutcomeAuthFailure HubLoginOutcome = "authFailure"
+	HubLoginOutcomeUnreachable HubLoginOutcome = "unreachable"
+)
+
+// HubLoginResult is the response to POST /hub/{host}/login.  Status is the
+// hub client's status after the login attempt.
+type HubLoginResult struct {
+	Host           string
+	Outcome        HubLoginOutcome
+	Error          string `json:",omitempty"`
+	Status         string
+	CircuitBreaker *ModelCircuitBreaker
+}
+
+// HubNotFoundError is returned when there's no hub client for a host.
+type HubNotFoundError struct {
+	Host string
+}
+
+func (err *HubNotFoundError) Error() string {
+	return fmt.Sprintf("no hub found with host %s", err.Host)
+}
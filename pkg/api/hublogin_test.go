@@ -0,0 +1,71 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunHubLoginTests() {
+	Describe("POST /hub/{host}/login", func() {
+		var responder *MockResponder
+		BeforeEach(func() {
+			responder = NewMockResponder()
+			responder.HubHosts["hub1.example.com"] = true
+		})
+		serve := func(method string, path string) *httptest.ResponseRecorder {
+			mux := http.NewServeMux()
+			setupHandlers(mux, responder)
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+			return recorder
+		}
+
+		It("reports the outcome of the login", func() {
+			recorder := serve("POST", "/api/v1/hub/hub1.example.com/login")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var result HubLoginResult
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &result)).To(BeNil())
+			Expect(result.Host).To(Equal("hub1.example.com"))
+			Expect(result.Outcome).To(Equal(HubLoginOutcomeSuccess))
+		})
+
+		It("returns 404 with an error body for an unknown host", func() {
+			recorder := serve("POST", "/api/v1/hub/hub2.example.com/login")
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			var errorResponse ErrorResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &errorResponse)).To(BeNil())
+			Expect(errorResponse.Code).To(Equal(ErrorCodeNotFound))
+			Expect(errorResponse.Details).To(Equal(map[string]interface{}{"host": "hub2.example.com"}))
+		})
+
+		It("doesn't handle other methods or paths", func() {
+			Expect(serve("GET", "/api/v1/hub/hub1.example.com/login").Code).To(Equal(http.StatusNotFound))
+			Expect(serve("POST", "/api/v1/hub/hub1.example.com").Code).To(Equal(http.StatusNotFound))
+		})
+	})
+}
@@ -0,0 +1,79 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ImageDetail describes everything perceptor knows about one image.
+type ImageDetail struct {
+	Sha                    string
+	RepoTags               []*ModelRepoTag
+	Priority               int
+	ScanStatus             string
+	TimeOfLastStatusChange string
+	TimeOfLastRefresh      string
+	Transitions            []*ModelImageTransition
+	// QueuePosition is the number of images ahead in the scan queue, and nil
+	// if the image isn't queued
+	QueuePosition *int
+	// HubURL is set while a hub is scanning the image
+	HubURL         string
+	HubScanName    string
+	FailureHistory []*ModelScanFailure
+	// ScanSummary is nil until the image has scan results
+	ScanSummary *ScannedImage
+	// Pods are the qualified names of the pods referencing the image
+	Pods []string
+	// ScanClient is nil until a scan client reports which scanner versions ran on the image
+	ScanClient *ImageScanClient
+}
+
+// ImageScanClient describes the scan client whose job on an image finished
+// most recently, and the versions of the scanners it ran.
+type ImageScanClient struct {
+	Scanner                 string
+	ScanCliVersion          string
+	SignatureScannerVersion string
+	FinishedAt              string
+}
+
+// ImageNotFoundError .....
+type ImageNotFoundError struct {
+	ShaPrefix string
+}
+
+func (err *ImageNotFoundError) Error() string {
+	return fmt.Sprintf("no image found with sha %s", err.ShaPrefix)
+}
+
+// AmbiguousShaError is returned when a sha prefix matches multiple images.
+type AmbiguousShaError struct {
+	ShaPrefix  string
+	Candidates []string
+}
+
+func (err *AmbiguousShaError) Error() string {
+	return fmt.Sprintf("sha prefix %s is ambiguous; candidates: %s", err.ShaPrefix, strings.Join(err.Candidates, ", "))
+}
@@ -0,0 +1,73 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ImagesByName are the images which have been seen under a repository and tag.
+// A tag can point at different digests over time, so there may be several.
+type ImagesByName struct {
+	Repository string
+	Tag        string
+	// Images are ordered by when they were last seen under the name, most
+	// recent first
+	Images []*NamedImage
+}
+
+// NamedImage is one image seen under a repository and tag.
+type NamedImage struct {
+	Sha                    string
+	ScanStatus             string
+	TimeOfLastStatusChange string
+	// FirstSeen and LastSeen are when the image was first and most recently
+	// observed under the name
+	FirstSeen string
+	LastSeen  string
+	// ScanSummary is nil until the image has scan results
+	ScanSummary *ScannedImage
+}
+
+// ImageNameNotFoundError is returned when no image has been seen under a name.
+type ImageNameNotFoundError struct {
+	Name string
+}
+
+func (err *ImageNameNotFoundError) Error() string {
+	return fmt.Sprintf("no image found with name %s", err.Name)
+}
+
+// ParseImageName splits `name` into a repository and a tag, which defaults to
+// "latest" as it does for docker.  A colon before the last slash belongs to a
+// registry port, not a tag.
+func ParseImageName(name string) (string, string, error) {
+	repository, tag := name, "latest"
+	if ix := strings.LastIndex(name, ":"); ix > strings.LastIndex(name, "/") {
+		repository, tag = name[:ix], name[ix+1:]
+	}
+	if repository == "" || tag == "" {
+		return "", "", NewValidationError("invalid image name %q", name)
+	}
+	return repository, tag, nil
+}
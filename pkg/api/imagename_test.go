@@ -0,0 +1,84 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunImageNameTests() {
+	Describe("GET /image?name=", func() {
+		var mux *http.ServeMux
+		BeforeEach(func() {
+			responder := NewMockResponder()
+			responder.Images["sha1abc"] = ImageInfo{Image: Image{Sha: "sha1abc", Repository: "registry:5000/app", Tag: "1.4.2"}}
+			responder.Images["sha2abc"] = ImageInfo{Image: Image{Sha: "sha2abc", Repository: "registry:5000/app", Tag: "1.4.2"}}
+			responder.Images["sha3abc"] = ImageInfo{Image: Image{Sha: "sha3abc", Repository: "app", Tag: "latest"}}
+			mux = http.NewServeMux()
+			setupHandlers(mux, responder)
+		})
+		get := func(path string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+			return recorder
+		}
+
+		It("lists every image seen under the name", func() {
+			recorder := get("/api/v1/image?name=registry:5000/app:1.4.2")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var images ImagesByName
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &images)).To(BeNil())
+			Expect(images.Repository).To(Equal("registry:5000/app"))
+			Expect(images.Tag).To(Equal("1.4.2"))
+			Expect(len(images.Images)).To(Equal(2))
+			Expect(images.Images[0].Sha).To(Equal("sha1abc"))
+			Expect(images.Images[1].Sha).To(Equal("sha2abc"))
+		})
+
+		It("defaults the tag to latest", func() {
+			recorder := get("/api/v1/image?name=app")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var images ImagesByName
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &images)).To(BeNil())
+			Expect(len(images.Images)).To(Equal(1))
+			Expect(images.Images[0].Sha).To(Equal("sha3abc"))
+		})
+
+		It("returns 404 for a name which has never been seen", func() {
+			recorder := get("/api/v1/image?name=registry:5000/app:9.9")
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			var response ErrorResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(BeNil())
+			Expect(response.Details).To(Equal(map[string]interface{}{"name": "registry:5000/app:9.9"}))
+		})
+
+		It("rejects a missing or malformed name", func() {
+			Expect(get("/api/v1/image").Code).To(Equal(http.StatusBadRequest))
+			Expect(get("/api/v1/image?name=app:").Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+}
@@ -0,0 +1,43 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import "fmt"
+
+// ImageRequeueResult describes an image which was put back in the scan queue.
+type ImageRequeueResult struct {
+	Sha                string
+	PreviousScanStatus string
+	// QueuePosition is the number of images ahead in the scan queue
+	QueuePosition int
+}
+
+// ImageStateError means an image's scan status doesn't allow the request.
+type ImageStateError struct {
+	Sha        string
+	ScanStatus string
+	Message    string
+}
+
+func (err *ImageStateError) Error() string {
+	return fmt.Sprintf("image %s is in state %s: %s", err.Sha, err.ScanStatus, err.Message)
+}
@@ -0,0 +1,77 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunImageRequeueTests() {
+	Describe("POST /image/{sha}/requeue", func() {
+		var mux *http.ServeMux
+		BeforeEach(func() {
+			responder := NewMockResponder()
+			responder.Images["sha1abc"] = ImageInfo{Image: Image{Sha: "sha1abc"}}
+			responder.Images["sha2abc"] = ImageInfo{Image: Image{Sha: "sha2abc"}, OverallStatus: "NOT_IN_VIOLATION"}
+			mux = http.NewServeMux()
+			setupHandlers(mux, responder)
+		})
+		post := func(path string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest("POST", path, nil))
+			return recorder
+		}
+
+		It("requeues an image being scanned and reports its queue position", func() {
+			recorder := post("/api/v1/image/sha1/requeue")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var result ImageRequeueResult
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &result)).To(BeNil())
+			Expect(result).To(Equal(ImageRequeueResult{Sha: "sha1abc", PreviousScanStatus: "ScanStatusRunningScanClient", QueuePosition: 0}))
+		})
+
+		It("refuses to requeue an image which isn't being scanned", func() {
+			recorder := post("/api/v1/image/sha2/requeue")
+			Expect(recorder.Code).To(Equal(http.StatusConflict))
+			var response ErrorResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(BeNil())
+			Expect(response.Code).To(Equal(ErrorCodeInvalidState))
+			Expect(response.Details).To(Equal(map[string]interface{}{"sha": "sha2abc", "scanStatus": "ScanStatusComplete"}))
+		})
+
+		It("returns 404 for an unknown image", func() {
+			recorder := post("/api/v1/image/sha9/requeue")
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("only accepts POST", func() {
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/image/sha1/requeue", nil))
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+}
@@ -31,4 +31,8 @@ type ImageSpec struct {
 	HubProjectVersionName string
 	HubScanName           string
 	Priority              int
+	// LeaseID must be acknowledged within LeaseTTLSeconds, and presented
+	// when the scan client finishes
+	LeaseID         string
+	LeaseTTLSeconds int
 }
@@ -0,0 +1,123 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Older perceivers sent images with their repository in a `Name` field,
+// which is still accepted: see Image.UnmarshalJSON.  Pod, container and
+// bulk image payloads pick this up through the images they contain.
+const legacyImageRepositoryField = "Name"
+
+// legacyWarningInterval is how often a source sending legacy field names is
+// warned about, at most.
+const legacyWarningInterval = 10 * time.Minute
+
+var legacyWarnings = &legacyWarningLimiter{lastWarned: map[string]time.Time{}, now: time.Now}
+
+// UnmarshalJSON accepts the legacy field names as well as the current ones.
+// Current names take precedence, and images are always written with the
+// current names.
+func (image *Image) UnmarshalJSON(data []byte) error {
+	type current Image
+	var fields struct {
+		current
+		Name string
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*image = Image(fields.current)
+	if image.Repository == "" {
+		image.Repository = fields.Name
+	}
+	return nil
+}
+
+// usesLegacyFieldNames reports whether a decoded payload contains an image
+// in the legacy form: an object with a sha and a name, but no repository.
+func usesLegacyFieldNames(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := map[string]bool{}
+		for key, child := range v {
+			keys[strings.ToLower(key)] = true
+			if usesLegacyFieldNames(child) {
+				return true
+			}
+		}
+		return keys["sha"] && keys[strings.ToLower(legacyImageRepositoryField)] && !keys["repository"]
+	case []interface{}:
+		for _, child := range v {
+			if usesLegacyFieldNames(child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// noteLegacyFieldNames warns about requests whose pod or image payload
+// `body` uses legacy field names, and marks them for the SourceStats to count.
+func noteLegacyFieldNames(r *http.Request, body []byte) {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil || !usesLegacyFieldNames(value) {
+		return
+	}
+	source := SourceOfRequest(r)
+	route := routeOfPath(r.URL.Path)
+	if info := requestInfoOfRequest(r); info != nil {
+		info.legacyRoute = route
+	}
+	if legacyWarnings.allow(source) {
+		RequestLogger(r).Warnf("%s sent a %s request to %s using the deprecated image field %s; it should send Repository instead", source, r.Method, route, legacyImageRepositoryField)
+	}
+}
+
+// legacyWarningLimiter allows one warning per source per legacyWarningInterval.
+type legacyWarningLimiter struct {
+	mutex      sync.Mutex
+	lastWarned map[string]time.Time
+	now        func() time.Time
+}
+
+func (lwl *legacyWarningLimiter) allow(source string) bool {
+	lwl.mutex.Lock()
+	defer lwl.mutex.Unlock()
+	now := lwl.now()
+	for name, warned := range lwl.lastWarned {
+		if now.Sub(warned) >= legacyWarningInterval {
+			delete(lwl.lastWarned, name)
+		}
+	}
+	if _, ok := lwl.lastWarned[source]; ok {
+		return false
+	}
+	lwl.lastWarned[source] = now
+	return true
+}
@@ -0,0 +1,120 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func RunLegacyFieldTests() {
+	Describe("legacy field names", func() {
+		sha := strings.Repeat("a", 64)
+		currentPod := `{"Name":"pod1","UID":"uid1","Namespace":"ns1","Containers":[{"Name":"cont1","Image":{"Repository":"app","Tag":"1.4.2","Sha":"` + sha + `"}}]}`
+		legacyPod := `{"Name":"pod1","UID":"uid1","Namespace":"ns1","Containers":[{"Name":"cont1","Image":{"Name":"app","Tag":"1.4.2","Sha":"` + sha + `"}}]}`
+		legacyCount := func(source string, route string) float64 {
+			metric := &dto.Metric{}
+			Expect(legacyPayloadCounter.With(map[string]string{"source": source, "route": route}).Write(metric)).To(BeNil())
+			return metric.GetCounter().GetValue()
+		}
+
+		It("decodes both forms of a pod to the same structure", func() {
+			var current, legacy Pod
+			Expect(json.Unmarshal([]byte(currentPod), &current)).To(BeNil())
+			Expect(json.Unmarshal([]byte(legacyPod), &legacy)).To(BeNil())
+			Expect(legacy).To(Equal(current))
+			Expect(current.Containers[0].Image).To(Equal(Image{Repository: "app", Tag: "1.4.2", Sha: sha}))
+			_, err := ValidatePod(legacy)
+			Expect(err).To(BeNil())
+		})
+
+		It("decodes both forms of bulk images to the same structure", func() {
+			var current, legacy AllImages
+			Expect(json.Unmarshal([]byte(`{"Images":[{"Repository":"app","Sha":"`+sha+`","Priority":2}]}`), &current)).To(BeNil())
+			Expect(json.Unmarshal([]byte(`{"Images":[{"Name":"app","Sha":"`+sha+`","Priority":2}]}`), &legacy)).To(BeNil())
+			Expect(legacy).To(Equal(current))
+			Expect(*legacy.Images[0].Priority).To(Equal(2))
+		})
+
+		It("prefers the current name when both are given", func() {
+			var image Image
+			Expect(json.Unmarshal([]byte(`{"Repository":"app","Name":"old-app","Sha":"`+sha+`"}`), &image)).To(BeNil())
+			Expect(image.Repository).To(Equal("app"))
+		})
+
+		It("always encodes the current names", func() {
+			var image Image
+			Expect(json.Unmarshal([]byte(`{"Name":"app","Sha":"`+sha+`"}`), &image)).To(BeNil())
+			jsonBytes, err := json.Marshal(image)
+			Expect(err).To(BeNil())
+			Expect(string(jsonBytes)).To(Equal(`{"Repository":"app","Tag":"","Sha":"` + sha + `","Priority":null}`))
+		})
+
+		It("only detects images in the legacy form", func() {
+			var value interface{}
+			Expect(json.Unmarshal([]byte(currentPod), &value)).To(BeNil())
+			Expect(usesLegacyFieldNames(value)).To(BeFalse())
+			Expect(json.Unmarshal([]byte(legacyPod), &value)).To(BeNil())
+			Expect(usesLegacyFieldNames(value)).To(BeTrue())
+			Expect(json.Unmarshal([]byte(`[{"name":"app","sha":"`+sha+`"}]`), &value)).To(BeNil())
+			Expect(usesLegacyFieldNames(value)).To(BeTrue())
+		})
+
+		It("counts legacy requests by source, capping the sources", func() {
+			responder := NewMockResponder()
+			mux := http.NewServeMux()
+			setupHandlers(mux, responder)
+			handler := RequestLogHandler(SourceStatsHandler(mux, NewSourceStats(1)), nil)
+			post := func(source string, body string) int {
+				request := httptest.NewRequest("POST", "/api/v1/pod", strings.NewReader(body))
+				request.Header.Set(SourceHeader, source)
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, request)
+				return recorder.Code
+			}
+			before, untracked := legacyCount("old-perceiver", "/pod"), legacyCount(UntrackedSources, "/pod")
+			Expect(post("old-perceiver", legacyPod)).To(Equal(http.StatusOK))
+			Expect(responder.Pods["ns1/pod1"].Containers[0].Image.Repository).To(Equal("app"))
+			Expect(post("old-perceiver", currentPod)).To(Equal(http.StatusOK))
+			Expect(legacyCount("old-perceiver", "/pod")).To(Equal(before + 1))
+			Expect(post("another-perceiver", legacyPod)).To(Equal(http.StatusOK))
+			Expect(legacyCount(UntrackedSources, "/pod")).To(Equal(untracked + 1))
+		})
+
+		It("warns about each source at most once an interval", func() {
+			now := time.Now()
+			limiter := &legacyWarningLimiter{lastWarned: map[string]time.Time{}, now: func() time.Time { return now }}
+			Expect(limiter.allow("perceiver-1")).To(BeTrue())
+			Expect(limiter.allow("perceiver-1")).To(BeFalse())
+			Expect(limiter.allow("perceiver-2")).To(BeTrue())
+			now = now.Add(legacyWarningInterval)
+			Expect(limiter.allow("perceiver-1")).To(BeTrue())
+		})
+	})
+}
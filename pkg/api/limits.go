@@ -0,0 +1,140 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Bodies of bulk routes are the whole cluster's pods or images, so they get
+// a bigger limit than the rest.
+const (
+	DefaultMaxBodyBytes     int64 = 1 << 20
+	DefaultMaxBulkBodyBytes int64 = 64 << 20
+)
+
+var bulkRoutes = map[string]bool{
+	"/allpods":   true,
+	"/allimages": true,
+	"/images":    true,
+}
+
+// BodyLimits caps the size of request bodies, in bytes.  Unset limits get
+// the defaults.
+type BodyLimits struct {
+	Default int64
+	Bulk    int64
+}
+
+func (bl BodyLimits) limitFor(r *http.Request) int64 {
+	if bulkRoutes[canonicalRoute(r.URL.Path)] {
+		if bl.Bulk > 0 {
+			return bl.Bulk
+		}
+		return DefaultMaxBulkBodyBytes
+	}
+	if bl.Default > 0 {
+		return bl.Default
+	}
+	return DefaultMaxBodyBytes
+}
+
+// RequestTooLargeError means a request body was over its route's limit.
+type RequestTooLargeError struct {
+	Limit int64
+}
+
+func (err *RequestTooLargeError) Error() string {
+	return fmt.Sprintf("request body is larger than the limit of %d bytes", err.Limit)
+}
+
+// limitedBody reports bodies over the limit as a RequestTooLargeError, so
+// that handlers which pass read errors to Responder.Error send a 413.
+type limitedBody struct {
+	io.ReadCloser
+	limit int64
+}
+
+func (lb *limitedBody) Read(data []byte) (int, error) {
+	n, err := lb.ReadCloser.Read(data)
+	if _, ok := err.(*http.MaxBytesError); ok {
+		return n, &RequestTooLargeError{Limit: lb.limit}
+	}
+	return n, err
+}
+
+// BodyLimitHandler rejects requests whose Content-Length is over the limit
+// with a 413, and cuts off bodies which turn out to be bigger than they said.
+func BodyLimitHandler(handler http.Handler, limits BodyLimits) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := limits.limitFor(r)
+		if r.ContentLength > limit {
+			RequestLogger(r).Warnf("rejecting %d byte %s request to %s from %s", r.ContentLength, r.Method, r.URL.Path, SourceOfRequest(r))
+			WriteError(w, r, &RequestTooLargeError{Limit: limit}, http.StatusRequestEntityTooLarge, false)
+			return
+		}
+		r.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, limit), limit: limit}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Default server timeouts.  Writes must be allowed to outlast a scanner
+// waiting for its next image.
+const (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultReadTimeout       = time.Minute
+	DefaultWriteTimeout      = MaxNextImageWait + 30*time.Second
+	DefaultIdleTimeout       = 2 * time.Minute
+)
+
+// ServerTimeouts bound how long a client may take to send a request, and
+// how long perceptor may take to answer it.  Unset timeouts get the
+// defaults.  ReadHeader and Read cut off slow clients which dribble out
+// partial requests.
+type ServerTimeouts struct {
+	ReadHeader time.Duration
+	Read       time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+}
+
+func orDefault(timeout time.Duration, defaultTimeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return defaultTimeout
+	}
+	return timeout
+}
+
+// NewServer .....
+func NewServer(addr string, handler http.Handler, timeouts ServerTimeouts) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: orDefault(timeouts.ReadHeader, DefaultReadHeaderTimeout),
+		ReadTimeout:       orDefault(timeouts.Read, DefaultReadTimeout),
+		WriteTimeout:      orDefault(timeouts.Write, DefaultWriteTimeout),
+		IdleTimeout:       orDefault(timeouts.Idle, DefaultIdleTimeout),
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// throttledReader sends one byte per period.
+type throttledReader struct {
+	data   string
+	period time.Duration
+}
+
+func (tr *throttledReader) Read(data []byte) (int, error) {
+	if len(tr.data) == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(tr.period)
+	data[0] = tr.data[0]
+	tr.data = tr.data[1:]
+	return 1, nil
+}
+
+func RunServerLimitsTests() {
+	Describe("body limits", func() {
+		var handler http.Handler
+		BeforeEach(func() {
+			mux := http.NewServeMux()
+			setupHandlers(mux, NewMockResponder())
+			handler = BodyLimitHandler(mux, BodyLimits{Default: 100, Bulk: 1000})
+		})
+		serve := func(method string, path string, body string, chunked bool) *httptest.ResponseRecorder {
+			request := httptest.NewRequest(method, path, strings.NewReader(body))
+			if chunked {
+				request.ContentLength = -1
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			return recorder
+		}
+		expectTooLarge := func(recorder *httptest.ResponseRecorder, limit int64) {
+			Expect(recorder.Code).To(Equal(http.StatusRequestEntityTooLarge))
+			var response ErrorResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(BeNil())
+			Expect(response.Code).To(Equal(ErrorCodeTooLarge))
+			Expect(response.Details).To(Equal(map[string]interface{}{"limitBytes": float64(limit)}))
+		}
+		pod := `{"Name":"pod1","Namespace":"ns1","UID":"uid1","Containers":[]}`
+
+		It("rejects bodies whose Content-Length is over the limit", func() {
+			expectTooLarge(serve("POST", "/api/v1/pod", pod+strings.Repeat(" ", 100), false), 100)
+		})
+
+		It("cuts off bodies which are bigger than they said", func() {
+			expectTooLarge(serve("POST", "/api/v1/pod", pod+strings.Repeat(" ", 100), true), 100)
+		})
+
+		It("gives bulk routes a bigger limit", func() {
+			Expect(serve("PUT", "/api/v1/allpods", `{"Pods":[`+pod+`]}`+strings.Repeat(" ", 500), true).Code).To(Equal(http.StatusOK))
+			expectTooLarge(serve("PUT", "/api/v1/allpods", `{"Pods":[`+pod+`]}`+strings.Repeat(" ", 1000), true), 1000)
+		})
+
+		It("lets bodies under the limit through", func() {
+			Expect(serve("POST", "/api/v1/pod", pod, false).Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("server timeouts", func() {
+		var server *httptest.Server
+		BeforeEach(func() {
+			mux := http.NewServeMux()
+			setupHandlers(mux, NewMockResponder())
+			server = httptest.NewUnstartedServer(mux)
+			server.Config = NewServer("", mux, ServerTimeouts{ReadHeader: 200 * time.Millisecond, Read: 300 * time.Millisecond})
+			server.Start()
+		})
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("cuts off clients which dribble out their headers", func() {
+			conn, err := net.Dial("tcp", server.Listener.Addr().String())
+			Expect(err).To(BeNil())
+			defer conn.Close()
+			start := time.Now()
+			fmt.Fprint(conn, "GET /api/v1/model HTTP/1.1\r\nHost: perceptor\r\n")
+			closed := make(chan struct{})
+			go func() {
+				ioutil.ReadAll(conn)
+				close(closed)
+			}()
+			for i := 0; i < 20; i++ {
+				select {
+				case <-closed:
+					// closed or reset, depending on what was unread
+					Expect(time.Since(start)).To(BeNumerically("<", 2*time.Second))
+					return
+				case <-time.After(50 * time.Millisecond):
+					fmt.Fprint(conn, "X")
+				}
+			}
+			Fail("connection wasn't closed")
+		})
+
+		It("cuts off clients which dribble out their bodies", func() {
+			body := &throttledReader{data: `{"Name":"pod1","Namespace":"ns1","UID":"uid1","Containers":[]}`, period: 50 * time.Millisecond}
+			request, err := http.NewRequest("POST", server.URL+"/api/v1/pod", body)
+			Expect(err).To(BeNil())
+			request.ContentLength = int64(len(body.data))
+			start := time.Now()
+			response, err := http.DefaultClient.Do(request)
+			if err == nil {
+				defer response.Body.Close()
+				Expect(response.StatusCode).To(Equal(http.StatusBadRequest))
+			}
+			Expect(time.Since(start)).To(BeNumerically("<", 2*time.Second))
+		})
+
+		It("serves clients which aren't slow", func() {
+			response, err := http.Get(server.URL + "/api/v1/model")
+			Expect(err).To(BeNil())
+			defer response.Body.Close()
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+		})
+	})
+}
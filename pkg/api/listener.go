@@ -0,0 +1,182 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ListenConfig says where the API server listens, and which protocols it speaks.
+type ListenConfig struct {
+	// Address is the host or IP to bind, such as the pod IP or localhost;
+	// empty binds every interface
+	Address string
+	Port    int
+	// HTTP2 is negotiated with ALPN over TLS, and spoken with prior
+	// knowledge (h2c) over cleartext; HTTP/1.1 is always served
+	HTTP2 bool
+}
+
+// Addr is the host:port to bind.
+func (lc ListenConfig) Addr() string {
+	return net.JoinHostPort(lc.Address, strconv.Itoa(lc.Port))
+}
+
+// APIServer serves a handler on an address which can be moved while it's
+// running, without disturbing anything behind the handler.
+type APIServer struct {
+	handler   http.Handler
+	timeouts  ServerTimeouts
+	tlsConfig *tls.Config
+	drain     time.Duration
+	mutex     sync.Mutex
+	listen    ListenConfig
+	server    *http.Server
+	port      int
+	// retiring are the servers being drained after a move
+	retiring sync.WaitGroup
+}
+
+// NewAPIServer creates a server for `handler`.  A nil `tlsConfig` serves
+// cleartext HTTP.  After a move, the old listener gets `drain` for its
+// in-flight requests to finish.
+func NewAPIServer(handler http.Handler, timeouts ServerTimeouts, tlsConfig *tls.Config, drain time.Duration) *APIServer {
+	return &APIServer{handler: handler, timeouts: timeouts, tlsConfig: tlsConfig, drain: drain}
+}
+
+// Start binds the address synchronously, so that an unbindable address is
+// reported straight away, and then serves in the background.
+func (as *APIServer) Start(listen ListenConfig) error {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	if as.server != nil {
+		return fmt.Errorf("API server already started on %s", as.server.Addr)
+	}
+	return as.serve(listen)
+}
+
+// Restart moves the server to `listen`, if it's changed.  The new listener
+// is bound before the old one is drained, so if binding it fails, the
+// server carries on where it was.
+func (as *APIServer) Restart(listen ListenConfig) error {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	if as.server == nil {
+		return fmt.Errorf("API server not started")
+	}
+	if listen == as.listen {
+		return nil
+	}
+	old := as.server
+	if err := as.serve(listen); err != nil {
+		return err
+	}
+	log.Infof("moved API server from %s to %s", old.Addr, as.server.Addr)
+	as.retiring.Add(1)
+	go func() {
+		defer as.retiring.Done()
+		if err := Shutdown(old, as.drain); err != nil {
+			log.Errorf("unable to shut down HTTP server %s gracefully: %s", old.Addr, err.Error())
+		}
+	}()
+	return nil
+}
+
+// serve must be called with the mutex held.
+func (as *APIServer) serve(listen ListenConfig) error {
+	listener, err := net.Listen("tcp", listen.Addr())
+	if err != nil {
+		return fmt.Errorf("unable to listen on %s: %s", listen.Addr(), err.Error())
+	}
+	server := NewServer(listener.Addr().String(), as.handler, as.timeouts)
+	server.Protocols = new(http.Protocols)
+	server.Protocols.SetHTTP1(true)
+	if as.tlsConfig != nil {
+		server.TLSConfig = as.tlsConfig
+		server.Protocols.SetHTTP2(listen.HTTP2)
+	} else {
+		server.Protocols.SetUnencryptedHTTP2(listen.HTTP2)
+	}
+	as.listen = listen
+	as.server = server
+	as.port = listener.Addr().(*net.TCPAddr).Port
+	go func() {
+		if as.tlsConfig != nil {
+			log.Infof("starting HTTPS server on %s, HTTP/2 %t", server.Addr, listen.HTTP2)
+			logServerStopped("HTTPS server "+server.Addr, server.ServeTLS(listener, "", ""))
+		} else {
+			log.Infof("starting HTTP server on %s, HTTP/2 %t", server.Addr, listen.HTTP2)
+			logServerStopped("HTTP server "+server.Addr, server.Serve(listener))
+		}
+	}()
+	return nil
+}
+
+// Addr is the address the server is listening on, with the port filled in
+// if port 0 was asked for.
+func (as *APIServer) Addr() string {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	if as.server == nil {
+		return ""
+	}
+	return as.server.Addr
+}
+
+// RedirectToHTTPS redirects every request to the same path on the server's
+// current port, so that it follows the server when it moves.
+func (as *APIServer) RedirectToHTTPS() http.Handler {
+	return redirectToHTTPS(func() int {
+		as.mutex.Lock()
+		defer as.mutex.Unlock()
+		return as.port
+	})
+}
+
+// Shutdown drains the server, along with any it's been moved away from.
+func (as *APIServer) Shutdown(drain time.Duration) error {
+	as.mutex.Lock()
+	server := as.server
+	as.mutex.Unlock()
+	var err error
+	if server != nil {
+		err = Shutdown(server, drain)
+	}
+	as.retiring.Wait()
+	return err
+}
+
+func logServerStopped(name string, err error) {
+	if err == http.ErrServerClosed {
+		log.Infof("%s stopped accepting connections", name)
+	} else {
+		log.Errorf("%s stopped: %v", name, err)
+	}
+}
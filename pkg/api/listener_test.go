@@ -0,0 +1,154 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunListenerTests() {
+	Describe("API server", func() {
+		protoHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, r.Proto)
+		})
+		localhost := ListenConfig{Address: "127.0.0.1"}
+		get := func(client *http.Client, url string) (string, error) {
+			resp, err := client.Get(url)
+			if err != nil {
+				return "", err
+			}
+			defer resp.Body.Close()
+			return resp.Proto, nil
+		}
+		freePort := func() int {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).To(BeNil())
+			defer listener.Close()
+			return listener.Addr().(*net.TCPAddr).Port
+		}
+		h2cClient := func() *http.Client {
+			transport := &http.Transport{Protocols: new(http.Protocols)}
+			transport.Protocols.SetUnencryptedHTTP2(true)
+			return &http.Client{Transport: transport, Timeout: 5 * time.Second}
+		}
+
+		It("binds the configured address, and fails fast if it can't", func() {
+			server := NewAPIServer(protoHandler, ServerTimeouts{}, nil, time.Second)
+			Expect(server.Start(localhost)).To(BeNil())
+			defer server.Shutdown(time.Second)
+			Expect(server.Addr()).To(HavePrefix("127.0.0.1:"))
+			Expect(get(http.DefaultClient, "http://"+server.Addr())).To(Equal("HTTP/1.1"))
+
+			taken := NewAPIServer(protoHandler, ServerTimeouts{}, nil, time.Second)
+			err := taken.Start(ListenConfig{Address: "127.0.0.1", Port: server.port})
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("unable to listen on " + server.Addr()))
+		})
+
+		It("speaks h2c only if HTTP/2 is on", func() {
+			server := NewAPIServer(protoHandler, ServerTimeouts{}, nil, time.Second)
+			Expect(server.Start(ListenConfig{Address: "127.0.0.1", HTTP2: true})).To(BeNil())
+			defer server.Shutdown(time.Second)
+			Expect(get(h2cClient(), "http://"+server.Addr())).To(Equal("HTTP/2.0"))
+			Expect(get(http.DefaultClient, "http://"+server.Addr())).To(Equal("HTTP/1.1"))
+
+			http1Only := NewAPIServer(protoHandler, ServerTimeouts{}, nil, time.Second)
+			Expect(http1Only.Start(localhost)).To(BeNil())
+			defer http1Only.Shutdown(time.Second)
+			_, err := get(h2cClient(), "http://"+http1Only.Addr())
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("negotiates HTTP/2 with ALPN over TLS, if it's on", func() {
+			certPEM, keyPEM := selfSignedPair("server")
+			getCertificate, err := StaticCertificate(certPEM, keyPEM)
+			Expect(err).To(BeNil())
+			roots := x509.NewCertPool()
+			Expect(roots.AppendCertsFromPEM([]byte(certPEM))).To(BeTrue())
+			tlsClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}, ForceAttemptHTTP2: true}}
+			for http2, proto := range map[bool]string{true: "HTTP/2.0", false: "HTTP/1.1"} {
+				tlsConfig, err := NewServerTLSConfig(getCertificate, "")
+				Expect(err).To(BeNil())
+				server := NewAPIServer(protoHandler, ServerTimeouts{}, tlsConfig, time.Second)
+				Expect(server.Start(ListenConfig{Address: "127.0.0.1", HTTP2: http2})).To(BeNil())
+				Expect(get(tlsClient, "https://"+strings.Replace(server.Addr(), "127.0.0.1", "localhost", 1))).To(Equal(proto))
+				Expect(server.Shutdown(time.Second)).To(BeNil())
+			}
+		})
+
+		It("moves to a new port without cutting off in-flight requests", func() {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/slow" {
+					close(started)
+					<-release
+				}
+				fmt.Fprint(w, "ok")
+			})
+			server := NewAPIServer(handler, ServerTimeouts{}, nil, 5*time.Second)
+			Expect(server.Start(localhost)).To(BeNil())
+			oldAddr := server.Addr()
+			redirect := server.RedirectToHTTPS()
+			inFlight := make(chan error)
+			go func() {
+				_, err := http.Get("http://" + oldAddr + "/slow")
+				inFlight <- err
+			}()
+			<-started
+
+			moved := ListenConfig{Address: "127.0.0.1", Port: freePort()}
+			Expect(server.Restart(moved)).To(BeNil())
+			Expect(server.Addr()).To(Equal(moved.Addr()))
+			Expect(get(http.DefaultClient, "http://"+server.Addr())).To(Equal("HTTP/1.1"))
+			close(release)
+			Expect(<-inFlight).To(BeNil())
+			Eventually(func() error {
+				_, err := get(http.DefaultClient, "http://"+oldAddr)
+				return err
+			}).ShouldNot(BeNil())
+
+			recorder := httptest.NewRecorder()
+			redirect.ServeHTTP(recorder, httptest.NewRequest("GET", "http://example.com/model", nil))
+			Expect(recorder.Header().Get("Location")).To(Equal(fmt.Sprintf("https://example.com:%d/model", moved.Port)))
+
+			// staying put is a no-op, and an unbindable address leaves the server where it was
+			addr := server.Addr()
+			Expect(server.Restart(moved)).To(BeNil())
+			Expect(server.Addr()).To(Equal(addr))
+			Expect(server.Restart(ListenConfig{Address: "192.0.2.1"})).NotTo(BeNil())
+			Expect(server.Addr()).To(Equal(addr))
+			Expect(get(http.DefaultClient, "http://"+addr)).To(Equal("HTTP/1.1"))
+			Expect(server.Shutdown(time.Second)).To(BeNil())
+		})
+	})
+}
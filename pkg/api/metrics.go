@@ -0,0 +1,185 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/blackducksoftware/perceptor/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metricsRegistry = util.NewMetricsRegistry()
+
+func init() {
+	setupMetrics()
+	setupRouteMetrics()
+}
+
+// ConfigureMetrics moves the api package's metrics to the registry and
+// namespace in `config`.  It must be called before anything records metrics.
+func ConfigureMetrics(config *util.MetricsConfig) {
+	metricsRegistry.Configure(config)
+	setupMetrics()
+	setupRouteMetrics()
+}
+
+var unauthorizedRequestCounter *prometheus.CounterVec
+var versionedRequestCounter *prometheus.CounterVec
+var throttledRequestCounter *prometheus.CounterVec
+var corsRejectionCounter *prometheus.CounterVec
+var deprecatedRouteRequestCounter *prometheus.CounterVec
+var sourceRequestCounter *prometheus.CounterVec
+var sourceIngestedBytesCounter *prometheus.CounterVec
+var sourceValidationFailureCounter *prometheus.CounterVec
+var legacyPayloadCounter *prometheus.CounterVec
+
+func recordUnauthorizedRequest(request *http.Request) {
+	unauthorizedRequestCounter.With(prometheus.Labels{"route": routeOfPath(request.URL.Path), "method": request.Method}).Inc()
+}
+
+func recordVersionedRequest(version string, route string, method string, deprecated bool) {
+	deprecatedLabel := "false"
+	if deprecated {
+		deprecatedLabel = "true"
+	}
+	versionedRequestCounter.With(prometheus.Labels{"version": version, "route": route, "method": method, "deprecated": deprecatedLabel}).Inc()
+}
+
+func recordThrottledRequest(source string, class RouteClass) {
+	throttledRequestCounter.With(prometheus.Labels{"client": source, "class": class.String()}).Inc()
+}
+
+// forgetThrottledClientMetrics drops the series of a client which hasn't
+// been throttled for a while.
+func forgetThrottledClientMetrics(client string) {
+	for _, class := range []RouteClass{RouteClassRead, RouteClassMutating, RouteClassScanner} {
+		throttledRequestCounter.Delete(prometheus.Labels{"client": client, "class": class.String()})
+	}
+}
+
+func recordCORSRejection(request *http.Request) {
+	corsRejectionCounter.With(prometheus.Labels{"method": request.Method}).Inc()
+}
+
+func recordDeprecatedRouteRequest(route string, successor string, method string) {
+	deprecatedRouteRequestCounter.With(prometheus.Labels{"route": route, "successor": successor, "method": method}).Inc()
+}
+
+func recordSourceRequest(source string, class RouteClass, bytesIngested int64, validationFailure bool) {
+	sourceRequestCounter.With(prometheus.Labels{"source": source, "class": class.String()}).Inc()
+	sourceIngestedBytesCounter.With(prometheus.Labels{"source": source}).Add(float64(bytesIngested))
+	if validationFailure {
+		sourceValidationFailureCounter.With(prometheus.Labels{"source": source}).Inc()
+	}
+}
+
+func recordLegacyPayload(source string, route string) {
+	legacyPayloadCounter.With(prometheus.Labels{"source": source, "route": route}).Inc()
+}
+
+// forgetSourceMetrics drops the series of a source which is no longer
+// tracked, so that sources coming and going don't grow the metrics forever.
+func forgetSourceMetrics(source string, legacyRoutes map[string]bool) {
+	for _, class := range []RouteClass{RouteClassRead, RouteClassMutating, RouteClassScanner} {
+		sourceRequestCounter.Delete(prometheus.Labels{"source": source, "class": class.String()})
+	}
+	sourceIngestedBytesCounter.Delete(prometheus.Labels{"source": source})
+	sourceValidationFailureCounter.Delete(prometheus.Labels{"source": source})
+	for route := range legacyRoutes {
+		legacyPayloadCounter.Delete(prometheus.Labels{"source": source, "route": route})
+	}
+}
+
+func setupMetrics() {
+	unauthorizedRequestCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "api",
+		Name:      "unauthorized_requests",
+		Help:      "HTTP requests rejected for missing or invalid bearer tokens, by route template",
+	}, []string{"route", "method"})
+	metricsRegistry.Register(unauthorizedRequestCounter)
+
+	versionedRequestCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "api",
+		Name:      "versioned_requests",
+		Help:      "HTTP requests by API version and route; deprecated requests used an unversioned path",
+	}, []string{"version", "route", "method", "deprecated"})
+	metricsRegistry.Register(versionedRequestCounter)
+
+	throttledRequestCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "api",
+		Name:      "throttled_requests",
+		Help:      "HTTP requests rejected by the rate limiter, by client and route class; clients beyond the tracked limit are counted as (untracked)",
+	}, []string{"client", "class"})
+	metricsRegistry.Register(throttledRequestCounter)
+
+	corsRejectionCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "api",
+		Name:      "cors_rejections",
+		Help:      "cross-origin requests from origins, or with methods, which aren't allowed",
+	}, []string{"method"})
+	metricsRegistry.Register(corsRejectionCounter)
+
+	deprecatedRouteRequestCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "api",
+		Name:      "deprecated_route_requests",
+		Help:      "HTTP requests to the old paths of renamed routes, which can be removed once this stops growing",
+	}, []string{"route", "successor", "method"})
+	metricsRegistry.Register(deprecatedRouteRequestCounter)
+
+	sourceRequestCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "api",
+		Name:      "source_requests",
+		Help:      "HTTP requests by source and route class; sources beyond the tracked limit are counted as (untracked)",
+	}, []string{"source", "class"})
+	metricsRegistry.Register(sourceRequestCounter)
+
+	sourceIngestedBytesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "api",
+		Name:      "source_ingested_bytes",
+		Help:      "bytes of request bodies read, by source",
+	}, []string{"source"})
+	metricsRegistry.Register(sourceIngestedBytesCounter)
+
+	sourceValidationFailureCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "api",
+		Name:      "source_validation_failures",
+		Help:      "HTTP requests rejected as invalid, by source",
+	}, []string{"source"})
+	metricsRegistry.Register(sourceValidationFailureCounter)
+
+	legacyPayloadCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "api",
+		Name:      "legacy_payloads",
+		Help:      "pod and image requests using deprecated field names, by source and route; sources beyond the tracked limit are counted as (untracked)",
+	}, []string{"source", "route"})
+	metricsRegistry.Register(legacyPayloadCounter)
+}
@@ -0,0 +1,54 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+// MetricsSummary is a curated JSON view of perceptor's prometheus metrics,
+// for status pages which can't aggregate the prometheus text format.  Its
+// fields are stable: new ones may be added, but existing ones won't change.
+type MetricsSummary struct {
+	// Hubs is keyed by hub host
+	Hubs map[string]*MetricsSummaryHub
+	// ScanQueueDepth and the in-progress counts come from the periodically
+	// refreshed image status gauges
+	ScanQueueDepth          int
+	ScanClientsInProgress   int
+	HubScansInProgress      int
+	ReducerQueueDepth       int
+	OldestUnscannedImageAge *ModelTime `json:",omitempty"`
+	// Window is the period, ending now, which the counts below cover.  It's
+	// an hour, except shortly after startup.
+	Window         *ModelTime
+	ScansCompleted int
+	ScansFailed    int
+	// ReducerActionsPerMinute is keyed by action name
+	ReducerActionsPerMinute map[string]float64
+}
+
+// MetricsSummaryHub .....
+type MetricsSummaryHub struct {
+	CircuitBreakerState string
+	// ScanStages counts the hub's scans in each stage
+	ScanStages map[string]int
+	// RequestsSucceeded and RequestsFailed cover the summary's Window
+	RequestsSucceeded int
+	RequestsFailed    int
+}
@@ -22,18 +22,32 @@ under the License.
 package api
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"net/http"
+	"sort"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
 // MockResponder .....
 type MockResponder struct {
-	Pods             map[string]*Pod
-	Images           map[string]ImageInfo
-	NextImageCounter int
+	Pods                map[string]*Pod
+	Images              map[string]ImageInfo
+	NextImageCounter    int
+	ConcurrentScanLimit int
+	HubHosts            map[string]bool
+	// Generation is bumped by every mutating call, like the model's
+	Generation int64
+	// AuditLog, if set, is served by GetAuditLog
+	AuditLog *AuditLog
+	// SourceStats, if set, is served by GetSourceStats
+	SourceStats *SourceStats
+	// ScanLeases maps shas to the lease AcknowledgeScanLease accepts for them
+	ScanLeases map[string]string
 }
 
 // NewMockResponder .....
@@ -42,6 +56,8 @@ func NewMockResponder() *MockResponder {
 		Pods:             map[string]*Pod{},
 		Images:           map[string]ImageInfo{},
 		NextImageCounter: 0,
+		HubHosts:         map[string]bool{},
+		ScanLeases:       map[string]string{},
 	}
 }
 
@@ -86,8 +102,61 @@ func (mr *MockResponder) GetModel() Model {
 
 // perceiver
 
+// GetImage .....
+func (mr *MockResponder) GetImage(shaPrefix string) (*ImageDetail, error) {
+	candidates := []string{}
+	for sha := range mr.Images {
+		if strings.HasPrefix(sha, shaPrefix) {
+			candidates = append(candidates, sha)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, &ImageNotFoundError{ShaPrefix: shaPrefix}
+	case 1:
+		imageInfo := mr.Images[candidates[0]]
+		return &ImageDetail{
+			Sha:      imageInfo.Image.Sha,
+			RepoTags: []*ModelRepoTag{{Repository: imageInfo.Image.Repository, Tag: imageInfo.Image.Tag}},
+		}, nil
+	default:
+		sort.Strings(candidates)
+		return nil, &AmbiguousShaError{ShaPrefix: shaPrefix, Candidates: candidates}
+	}
+}
+
+// GetImagesByName .....
+func (mr *MockResponder) GetImagesByName(repository string, tag string) (*ImagesByName, error) {
+	images := &ImagesByName{Repository: repository, Tag: tag, Images: []*NamedImage{}}
+	for sha, imageInfo := range mr.Images {
+		if imageInfo.Image.Repository == repository && imageInfo.Image.Tag == tag {
+			images.Images = append(images.Images, &NamedImage{Sha: sha, ScanStatus: modelScanStatusComplete})
+		}
+	}
+	if len(images.Images) == 0 {
+		return nil, &ImageNameNotFoundError{Name: repository + ":" + tag}
+	}
+	sort.Slice(images.Images, func(i, j int) bool { return images.Images[i].Sha < images.Images[j].Sha })
+	return images, nil
+}
+
+// RequeueImage treats images with an overall status as scanned, and others
+// as being scanned.
+func (mr *MockResponder) RequeueImage(shaPrefix string, source string) (*ImageRequeueResult, error) {
+	detail, err := mr.GetImage(shaPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if mr.Images[detail.Sha].OverallStatus != "" {
+		return nil, &ImageStateError{Sha: detail.Sha, ScanStatus: "ScanStatusComplete", Message: "only images which are being scanned, or whose scans failed terminally, can be requeued"}
+	}
+	mr.Generation++
+	return &ImageRequeueResult{Sha: detail.Sha, PreviousScanStatus: "ScanStatusRunningScanClient", QueuePosition: 0}, nil
+}
+
 // AddPod .....
 func (mr *MockResponder) AddPod(pod Pod) error {
+	mr.Generation++
 	log.Infof("add pod: %+v", pod)
 	qualifiedName := fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
 	_, ok := mr.Pods[qualifiedName]
@@ -106,22 +175,64 @@ func (mr *MockResponder) AddPod(pod Pod) error {
 }
 
 // UpdatePod .....
-func (mr *MockResponder) UpdatePod(pod Pod) error {
+func (mr *MockResponder) UpdatePod(pod Pod) (*PodUpsertResult, error) {
+	mr.Generation++
 	log.Infof("update pod: %+v", pod)
 	qualifiedName := fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
+	result := &PodUpsertResult{Outcome: PodUpsertOutcomeCreated, EnqueuedImages: []string{}}
+	if oldPod, ok := mr.Pods[qualifiedName]; ok {
+		result.Outcome = PodUpsertOutcomeUpdated
+		if oldPod.UID != pod.UID {
+			result.Outcome = PodUpsertOutcomeReplaced
+		}
+	}
 	mr.Pods[qualifiedName] = &pod
-	return nil
+	for _, cont := range pod.Containers {
+		if _, ok := mr.Images[cont.Image.Sha]; ok {
+			continue
+		}
+		if err := mr.AddImage(cont.Image); err != nil {
+			return nil, err
+		}
+		result.EnqueuedImages = append(result.EnqueuedImages, cont.Image.Sha)
+	}
+	return result, nil
 }
 
 // DeletePod .....
-func (mr *MockResponder) DeletePod(qualifiedName string) {
+func (mr *MockResponder) DeletePod(qualifiedName string) (bool, error) {
+	mr.Generation++
 	log.Infof("delete pod: %s", qualifiedName)
+	if _, ok := mr.Pods[qualifiedName]; !ok {
+		return false, &PodNotFoundError{QualifiedName: qualifiedName}
+	}
 	delete(mr.Pods, qualifiedName)
+	return false, nil
+}
+
+// DeletePods .....
+func (mr *MockResponder) DeletePods(query *PodsDeletionQuery) (*PodsDeletion, error) {
+	log.Infof("delete pods: %+v", query)
+	deletion := &PodsDeletion{Namespace: query.Namespace, DryRun: query.DryRun, Pods: []string{}}
+	for qualifiedName, pod := range mr.Pods {
+		if pod.Namespace == query.Namespace {
+			deletion.Pods = append(deletion.Pods, qualifiedName)
+		}
+	}
+	sort.Strings(deletion.Pods)
+	deletion.Deleted = len(deletion.Pods)
+	if !query.DryRun && deletion.Deleted > 0 {
+		mr.Generation++
+		for _, qualifiedName := range deletion.Pods {
+			delete(mr.Pods, qualifiedName)
+		}
+	}
+	return deletion, nil
 }
 
 // GetScanResults .....
-func (mr *MockResponder) GetScanResults() ScanResults {
-	log.Info("get scan results")
+func (mr *MockResponder) GetScanResults(query *ScanResultsQuery) ScanResults {
+	log.Infof("get scan results: %+v", query)
 	scannedPods := []ScannedPod{}
 	scannedImages := []ScannedImage{}
 	for _, pod := range mr.Pods {
@@ -143,13 +254,65 @@ func (mr *MockResponder) GetScanResults() ScanResults {
 			Vulnerabilities:  imageInfo.Vulnerabilities})
 	}
 	return ScanResults{
-		Pods:   scannedPods,
-		Images: scannedImages,
+		Pods:       scannedPods,
+		Images:     scannedImages,
+		Generation: mr.Generation,
 	}
 }
 
+// SubscribeScanResults returns a subscription which never delivers events.
+func (mr *MockResponder) SubscribeScanResults(lastEventID string) (*ScanResultsSubscription, error) {
+	events := make(chan *ScanResultsEvent)
+	closed := false
+	return &ScanResultsSubscription{
+		Events: events,
+		Lagged: func() bool { return false },
+		Close: func() {
+			if !closed {
+				closed = true
+				close(events)
+			}
+		},
+	}, nil
+}
+
+// ExportFindings reports each known image of each matching pod, with its
+// vulnerabilities counted as high risks.
+func (mr *MockResponder) ExportFindings(query *FindingsExportQuery, emit func(*Finding) error) error {
+	filter := query.Filter()
+	names := []string{}
+	for name, pod := range mr.Pods {
+		if (filter.Namespace == "" || pod.Namespace == filter.Namespace) && filter.MatchesPod(pod.Name, pod.Labels) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pod := mr.Pods[name]
+		for _, container := range pod.Containers {
+			imageInfo, ok := mr.Images[container.Image.Sha]
+			if !ok {
+				continue
+			}
+			finding := &Finding{
+				Pod:          pod.Name,
+				Namespace:    pod.Namespace,
+				Image:        fmt.Sprintf("%s:%s", container.Image.Repository, container.Image.Tag),
+				Sha:          container.Image.Sha,
+				High:         imageInfo.Vulnerabilities,
+				PolicyStatus: imageInfo.OverallStatus,
+			}
+			if err := emit(finding); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // AddImage .....
 func (mr *MockResponder) AddImage(image Image) error {
+	mr.Generation++
 	_, ok := mr.Images[image.Sha]
 	if ok {
 		return nil
@@ -173,8 +336,82 @@ func (mr *MockResponder) AddImage(image Image) error {
 	return nil
 }
 
+// GetPods treats every image as scanned.
+func (mr *MockResponder) GetPods(query *PodsQuery) (*PodList, error) {
+	images := map[string]*ModelImageInfo{}
+	for sha := range mr.Images {
+		images[sha] = &ModelImageInfo{ImageSha: sha, ScanStatus: modelScanStatusComplete}
+	}
+	return ListPods(&CoreModel{Pods: mr.Pods, Images: images}, query)
+}
+
+// GetPod .....
+func (mr *MockResponder) GetPod(namespace string, name string) (*PodDetail, error) {
+	qualifiedName := fmt.Sprintf("%s/%s", namespace, name)
+	pod, ok := mr.Pods[qualifiedName]
+	if !ok {
+		return nil, &PodNotFoundError{QualifiedName: qualifiedName}
+	}
+	detail := &PodDetail{
+		QualifiedName: qualifiedName,
+		Namespace:     pod.Namespace,
+		Name:          pod.Name,
+		UID:           pod.UID,
+		Labels:        pod.Labels,
+		Status:        PodStatusComplete,
+		Containers:    []*PodContainerDetail{},
+		OverallStatus: "NOT_IN_VIOLATION",
+	}
+	for _, cont := range pod.Containers {
+		imageInfo := mr.Images[cont.Image.Sha]
+		detail.Containers = append(detail.Containers, &PodContainerDetail{
+			Name:       cont.Name,
+			Repository: cont.Image.Repository,
+			Tag:        cont.Image.Tag,
+			Sha:        cont.Image.Sha,
+			ScanStatus: modelScanStatusComplete,
+			ScanSummary: &ScannedImage{
+				Repository:       cont.Image.Repository,
+				Tag:              cont.Image.Tag,
+				Sha:              cont.Image.Sha,
+				PolicyViolations: imageInfo.PolicyViolations,
+				Vulnerabilities:  imageInfo.Vulnerabilities,
+				OverallStatus:    imageInfo.OverallStatus,
+				ComponentsURL:    imageInfo.ComponentsURL},
+		})
+		detail.PolicyViolations += imageInfo.PolicyViolations
+		detail.Vulnerabilities += imageInfo.Vulnerabilities
+		if imageInfo.OverallStatus != "" && imageInfo.OverallStatus != "NOT_IN_VIOLATION" {
+			detail.OverallStatus = imageInfo.OverallStatus
+		}
+	}
+	return detail, nil
+}
+
+// AddImages .....
+func (mr *MockResponder) AddImages(images []Image, acceptValid bool) (*BulkImagesResult, error) {
+	mr.Generation++
+	results, err := ValidateImages(images, acceptValid)
+	if err != nil {
+		return nil, err
+	}
+	for i, image := range images {
+		if results[i].Outcome == ImageOutcomeRejected {
+			continue
+		}
+		if _, ok := mr.Images[image.Sha]; ok {
+			results[i].Outcome = ImageOutcomeAlreadyKnown
+		} else {
+			results[i].Outcome = ImageOutcomeAdded
+			mr.AddImage(image)
+		}
+	}
+	return NewBulkImagesResult(results), nil
+}
+
 // UpdateAllPods .....
 func (mr *MockResponder) UpdateAllPods(allPods AllPods) error {
+	mr.Generation++
 	log.Infof("update all pods: %+v", allPods)
 	mr.Pods = map[string]*Pod{}
 	for _, pod := range allPods.Pods {
@@ -185,6 +422,7 @@ func (mr *MockResponder) UpdateAllPods(allPods AllPods) error {
 
 // UpdateAllImages .....
 func (mr *MockResponder) UpdateAllImages(allImages AllImages) error {
+	mr.Generation++
 	log.Infof("update all images: %+v", allImages)
 	mr.Images = map[string]ImageInfo{}
 	for _, image := range allImages.Images {
@@ -195,8 +433,8 @@ func (mr *MockResponder) UpdateAllImages(allImages AllImages) error {
 
 // scanner
 
-// GetNextImage .....
-func (mr *MockResponder) GetNextImage() NextImage {
+// GetNextImage always has an image, so it never waits.
+func (mr *MockResponder) GetNextImage(ctx context.Context, query *NextImageQuery) NextImage {
 	mr.NextImageCounter++
 	imageSpec := ImageSpec{
 		HubProjectName:        fmt.Sprintf("mock-perceptor-%d", mr.NextImageCounter),
@@ -204,16 +442,69 @@ func (mr *MockResponder) GetNextImage() NextImage {
 		HubScanName:           fmt.Sprintf("mock-perceptor-scan-name-%d", mr.NextImageCounter),
 		Repository:            "abc/def/ghi",
 		Tag:                   "latest",
-		Sha:                   "123abc456def"}
+		Sha:                   "123abc456def",
+		LeaseID:               fmt.Sprintf("mock-perceptor-lease-%d", mr.NextImageCounter),
+		LeaseTTLSeconds:       60}
+	mr.ScanLeases[imageSpec.Sha] = imageSpec.LeaseID
 	return NextImage{ImageSpec: &imageSpec}
 }
 
 // PostFinishScan .....
 func (mr *MockResponder) PostFinishScan(job FinishedScanClientJob) error {
+	mr.Generation++
 	log.Infof("finished scan job: %+v", job)
 	return nil
 }
 
+// AcknowledgeScanLease .....
+func (mr *MockResponder) AcknowledgeScanLease(ack ScanLeaseAcknowledgement) error {
+	if leaseID, ok := mr.ScanLeases[ack.Sha]; !ok {
+		return &ScanLeaseError{Sha: ack.Sha, LeaseID: ack.LeaseID, Reason: ScanLeaseReasonUnknown}
+	} else if leaseID != ack.LeaseID {
+		return &ScanLeaseError{Sha: ack.Sha, LeaseID: ack.LeaseID, Reason: ScanLeaseReasonStale}
+	}
+	log.Infof("acknowledged scan lease: %+v", ack)
+	return nil
+}
+
+// GetConfig .....
+func (mr *MockResponder) GetConfig() *RuntimeConfig {
+	return &RuntimeConfig{
+		Features: map[string]bool{"mockMode": true},
+		Settings: map[string]*RuntimeSetting{
+			"ConcurrentScanLimit": {Value: mr.ConcurrentScanLimit, Source: "config"},
+		},
+	}
+}
+
+// SetConcurrentScanLimit .....
+func (mr *MockResponder) SetConcurrentScanLimit(limit SetConcurrentScanLimit) error {
+	if limit.Limit < 0 {
+		return NewValidationError("invalid concurrent scan limit %d", limit.Limit)
+	}
+	mr.ConcurrentScanLimit = limit.Limit
+	return nil
+}
+
+// GetConcurrentScanLimit .....
+func (mr *MockResponder) GetConcurrentScanLimit() ConcurrentScanLimit {
+	return ConcurrentScanLimit{Limit: mr.ConcurrentScanLimit}
+}
+
+// GetScanQueueStats .....
+func (mr *MockResponder) GetScanQueueStats() *ScanQueueStats {
+	return NewScanQueueStats(&CoreModel{}, mr.GetConcurrentScanLimit(), time.Now())
+}
+
+// GetMetricsSummary .....
+func (mr *MockResponder) GetMetricsSummary() (*MetricsSummary, error) {
+	return &MetricsSummary{
+		Hubs:                    map[string]*MetricsSummaryHub{},
+		Window:                  NewModelTime(0),
+		ReducerActionsPerMinute: map[string]float64{},
+	}, nil
+}
+
 // internal use
 
 // PostCommand ...
@@ -221,14 +512,96 @@ func (mr *MockResponder) PostCommand(command *PostCommand) {
 	// TODO
 }
 
+// hubs
+
+// SetHubs .....
+func (mr *MockResponder) SetHubs(request *SetHubsRequest) (*SetHubsResult, error) {
+	if err := ValidateSetHubsRequest(request); err != nil {
+		return nil, err
+	}
+	result := NewSetHubsResult()
+	hosts := map[string]bool{}
+	for _, spec := range request.Hubs {
+		hosts[spec.Host] = true
+		if mr.HubHosts[spec.Host] {
+			result.Unchanged = append(result.Unchanged, spec.Host)
+		} else {
+			result.Added = append(result.Added, spec.Host)
+		}
+	}
+	for host := range mr.HubHosts {
+		if !hosts[host] {
+			result.Removed = append(result.Removed, host)
+		}
+	}
+	mr.HubHosts = hosts
+	return result, nil
+}
+
+// LoginHub .....
+func (mr *MockResponder) LoginHub(host string) (*HubLoginResult, error) {
+	if !mr.HubHosts[host] {
+		return nil, &HubNotFoundError{Host: host}
+	}
+	return &HubLoginResult{Host: host, Outcome: HubLoginOutcomeSuccess, Status: "ClientStatusUp"}, nil
+}
+
+// GetHubCircuitBreaker .....
+func (mr *MockResponder) GetHubCircuitBreaker(host string) (*ModelCircuitBreaker, error) {
+	if !mr.HubHosts[host] {
+		return nil, &HubNotFoundError{Host: host}
+	}
+	return &ModelCircuitBreaker{State: "CircuitBreakerStateEnabled"}, nil
+}
+
+// ResetHubCircuitBreaker .....
+func (mr *MockResponder) ResetHubCircuitBreaker(host string, source string, requestID string) (*CircuitBreakerReset, error) {
+	if !mr.HubHosts[host] {
+		return nil, &HubNotFoundError{Host: host}
+	}
+	log.Infof("reset circuit breaker for hub %s on behalf of %s, request %s", host, source, requestID)
+	breaker := &ModelCircuitBreaker{State: "CircuitBreakerStateEnabled"}
+	return &CircuitBreakerReset{Host: host, Before: breaker, After: breaker}, nil
+}
+
+// audit
+
+// GetAuditLog .....
+func (mr *MockResponder) GetAuditLog(query *AuditQuery) (*AuditPage, error) {
+	if mr.AuditLog == nil {
+		return &AuditPage{Entries: []*AuditEntry{}}, nil
+	}
+	return mr.AuditLog.Page(query), nil
+}
+
+// GetSourceStats .....
+func (mr *MockResponder) GetSourceStats() (*SourceStatsReport, error) {
+	if mr.SourceStats == nil {
+		return &SourceStatsReport{Sources: []*SourceTraffic{}}, nil
+	}
+	return mr.SourceStats.Report(), nil
+}
+
+// health
+
+// GetLiveness .....
+func (mr *MockResponder) GetLiveness() *Health {
+	return NewHealth([]*HealthCheck{})
+}
+
+// GetReadiness .....
+func (mr *MockResponder) GetReadiness(query *ReadinessQuery) *Health {
+	return NewHealth([]*HealthCheck{})
+}
+
 // errors
 
 // NotFound .....
 func (mr *MockResponder) NotFound(w http.ResponseWriter, r *http.Request) {
-	http.NotFound(w, r)
+	WriteError(w, r, fmt.Errorf("%s %s not found", r.Method, r.URL.Path), http.StatusNotFound, false)
 }
 
 // Error .....
 func (mr *MockResponder) Error(w http.ResponseWriter, r *http.Request, err error, statusCode int) {
-	http.Error(w, err.Error(), statusCode)
+	WriteError(w, r, err, statusCode, false)
 }
@@ -61,7 +61,7 @@ func RunMockResponderTests() {
 				UID:       "uid1",
 			})
 			Expect(err).To(BeNil())
-			scanResults := mr.GetScanResults()
+			scanResults := mr.GetScanResults(&ScanResultsQuery{})
 			sort.Slice(scanResults.Images, func(i int, j int) bool {
 				return scanResults.Images[i].Sha < scanResults.Images[j].Sha
 			})
@@ -31,6 +31,8 @@ type Model struct {
 	CoreModel *CoreModel
 	Config    *ModelConfig
 	Scheduler *ModelScanScheduler
+	// Continue, if set, fetches the next page of pods and images
+	Continue string `json:",omitempty"`
 }
 
 // ModelScanScheduler ...
@@ -45,6 +47,24 @@ type CoreModel struct {
 	Images           map[string]*ModelImageInfo
 	ImageScanQueue   []map[string]interface{}
 	ImageTransitions []*ModelImageTransition
+	Journal          []*ModelJournalEntry
+	// NamespaceScanLimits and NamespaceScansInFlight are keyed by namespace
+	NamespaceScanLimits    map[string]int
+	NamespaceScansInFlight map[string]int
+	// Generation changes whenever the model does
+	Generation int64
+	// ScanQueue is only used to compute scan queue statistics
+	ScanQueue *ModelScanQueue `json:"-"`
+}
+
+// ModelJournalEntry .....
+type ModelJournalEntry struct {
+	Action  string
+	Time    string
+	Sha     string
+	PodName string
+	Outcome string
+	Err     string
 }
 
 // ModelImageTransition .....
@@ -64,6 +84,8 @@ type ModelHubConfig struct {
 	Port                int
 	ConcurrentScanLimit int
 	TotalScanLimit      int
+	// NamespaceConcurrentScanLimits is keyed by namespace
+	NamespaceConcurrentScanLimits map[string]int
 }
 
 // ModelConfig .....
@@ -99,6 +121,7 @@ type ModelTimings struct {
 	StalledScanClientTimeout  ModelTime
 	ModelMetricsPause         ModelTime
 	UnknownImagePause         ModelTime
+	ScanLeaseTTL              ModelTime
 }
 
 // ModelImageInfo .....
@@ -109,6 +132,22 @@ type ModelImageInfo struct {
 	ImageSha               string
 	RepoTags               []*ModelRepoTag
 	Priority               int
+	FailureHistory         []*ModelScanFailure
+}
+
+// ModelScanFailure .....
+type ModelScanFailure struct {
+	Stage   string
+	Err     string
+	Scanner string
+	Time    string
+	// Reason is "manual" for scans requeued through the API, and empty
+	// for failures reported by scanners and hubs
+	Reason string
+	// Category is the scan client's classification of the failure, if any
+	Category string
+	// Detail is what the scan client reported about the failure, if anything
+	Detail *ScanClientFailure
 }
 
 // ModelRepoTag ...
@@ -139,6 +178,20 @@ type ModelHub struct {
 	Status         string
 	CircuitBreaker *ModelCircuitBreaker
 	Host           string
+	// how the hub's regular jobs are running, by timer name
+	Timers map[string]*ModelTimer
+}
+
+// ModelTimer describes how a regular job is running
+type ModelTimer struct {
+	Delay            ModelTime
+	Runs             int
+	SkippedTicks     int
+	CoalescedTicks   int
+	Panics           int
+	LastRunStart     *time.Time
+	LastRunDuration  ModelTime
+	LastRunCompleted bool
 }
 
 // ModelCodeLocation ...
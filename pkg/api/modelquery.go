@@ -0,0 +1,197 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ModelQuery selects which sections of the model to return, and pages
+// through its pods and images.
+type ModelQuery struct {
+	// Limit is the maximum number of pods, and of images, per page; 0 means no limit
+	Limit int
+	// Continue is the token from the previous page
+	Continue string
+	// Include is the set of sections to return; nil means all of them
+	Include map[string]bool
+}
+
+var modelSections = map[string]bool{
+	"pods":        true,
+	"images":      true,
+	"queue":       true,
+	"transitions": true,
+	"journal":     true,
+	"hubs":        true,
+	"config":      true,
+	"scheduler":   true,
+}
+
+// ParseModelQuery reads the `limit`, `continue` and `include` query parameters.
+func ParseModelQuery(values url.Values) (*ModelQuery, error) {
+	query := &ModelQuery{Continue: values.Get("continue")}
+	if limit := values.Get("limit"); limit != "" {
+		var err error
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 0 {
+			return nil, fmt.Errorf("invalid limit %s", limit)
+		}
+	}
+	if include := values.Get("include"); include != "" {
+		query.Include = map[string]bool{}
+		for _, section := range strings.Split(include, ",") {
+			if !modelSections[section] {
+				return nil, fmt.Errorf("invalid section %s", section)
+			}
+			query.Include[section] = true
+		}
+	}
+	return query, nil
+}
+
+func (query *ModelQuery) includes(section string) bool {
+	return query.Include == nil || query.Include[section]
+}
+
+type continueToken struct {
+	generation  int64
+	podOffset   int
+	imageOffset int
+}
+
+func (token *continueToken) String() string {
+	raw := fmt.Sprintf("%d:%d:%d", token.generation, token.podOffset, token.imageOffset)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseContinueToken(encoded string) (*continueToken, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid continue token %s", encoded)
+	}
+	token := &continueToken{}
+	if _, err := fmt.Sscanf(string(raw), "%d:%d:%d", &token.generation, &token.podOffset, &token.imageOffset); err != nil {
+		return nil, fmt.Errorf("invalid continue token %s", encoded)
+	}
+	return token, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// page returns the keys from `offset` on, up to `limit` of them, and the
+// offset of the next page -- or -1 if there's nothing left.
+func page(keys []string, offset int, limit int) ([]string, int) {
+	if offset > len(keys) {
+		offset = len(keys)
+	}
+	end := len(keys)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	if end == len(keys) {
+		return keys[offset:end], -1
+	}
+	return keys[offset:end], end
+}
+
+// Apply returns the requested page and sections of `model`, without modifying it.
+// Pods and images are ordered by name and by sha.  The continue token records
+// the model's generation, so that clients can compare it to the generation of
+// later pages to find out whether the model changed in the middle.
+func (query *ModelQuery) Apply(model Model) (*Model, error) {
+	token := &continueToken{}
+	if query.Continue != "" {
+		var err error
+		token, err = parseContinueToken(query.Continue)
+		if err != nil {
+			return nil, err
+		}
+	}
+	result := &Model{}
+	if query.includes("hubs") {
+		result.Hubs = model.Hubs
+	}
+	if query.includes("config") {
+		result.Config = model.Config
+	}
+	if query.includes("scheduler") {
+		result.Scheduler = model.Scheduler
+	}
+	if model.CoreModel == nil {
+		return result, nil
+	}
+	coreModel := &CoreModel{Generation: model.CoreModel.Generation}
+	result.CoreModel = coreModel
+	if query.includes("queue") {
+		coreModel.ImageScanQueue = model.CoreModel.ImageScanQueue
+		coreModel.NamespaceScanLimits = model.CoreModel.NamespaceScanLimits
+		coreModel.NamespaceScansInFlight = model.CoreModel.NamespaceScansInFlight
+	}
+	if query.includes("transitions") {
+		coreModel.ImageTransitions = model.CoreModel.ImageTransitions
+	}
+	if query.includes("journal") {
+		coreModel.Journal = model.CoreModel.Journal
+	}
+	nextToken := &continueToken{generation: model.CoreModel.Generation, podOffset: -1, imageOffset: -1}
+	if query.includes("pods") && token.podOffset >= 0 {
+		names := map[string]bool{}
+		for name := range model.CoreModel.Pods {
+			names[name] = true
+		}
+		var pageNames []string
+		pageNames, nextToken.podOffset = page(sortedKeys(names), token.podOffset, query.Limit)
+		coreModel.Pods = map[string]*Pod{}
+		for _, name := range pageNames {
+			coreModel.Pods[name] = model.CoreModel.Pods[name]
+		}
+	}
+	if query.includes("images") && token.imageOffset >= 0 {
+		shas := map[string]bool{}
+		for sha := range model.CoreModel.Images {
+			shas[sha] = true
+		}
+		var pageShas []string
+		pageShas, nextToken.imageOffset = page(sortedKeys(shas), token.imageOffset, query.Limit)
+		coreModel.Images = map[string]*ModelImageInfo{}
+		for _, sha := range pageShas {
+			coreModel.Images[sha] = model.CoreModel.Images[sha]
+		}
+	}
+	if nextToken.podOffset >= 0 || nextToken.imageOffset >= 0 {
+		result.Continue = nextToken.String()
+	}
+	return result, nil
+}
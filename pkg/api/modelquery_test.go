@@ -0,0 +1,116 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func modelWithPodsAndImages(podCount int, imageCount int) Model {
+	coreModel := &CoreModel{
+		Pods:       map[string]*Pod{},
+		Images:     map[string]*ModelImageInfo{},
+		Generation: 7,
+	}
+	for i := 0; i < podCount; i++ {
+		name := fmt.Sprintf("ns/pod%d", i)
+		coreModel.Pods[name] = &Pod{Name: name}
+	}
+	for i := 0; i < imageCount; i++ {
+		sha := fmt.Sprintf("sha%d", i)
+		coreModel.Images[sha] = &ModelImageInfo{ImageSha: sha}
+	}
+	return Model{CoreModel: coreModel, Hubs: map[string]*ModelHub{}, Config: &ModelConfig{}}
+}
+
+func RunModelQueryTests() {
+	Describe("model query", func() {
+		parse := func(rawQuery string) *ModelQuery {
+			values, err := url.ParseQuery(rawQuery)
+			Expect(err).To(BeNil())
+			query, err := ParseModelQuery(values)
+			Expect(err).To(BeNil())
+			return query
+		}
+
+		It("returns everything by default", func() {
+			model := modelWithPodsAndImages(3, 2)
+			result, err := parse("").Apply(model)
+			Expect(err).To(BeNil())
+			Expect(result.CoreModel.Pods).To(Equal(model.CoreModel.Pods))
+			Expect(result.CoreModel.Images).To(Equal(model.CoreModel.Images))
+			Expect(result.Hubs).ToNot(BeNil())
+			Expect(result.Continue).To(Equal(""))
+		})
+
+		It("pages through pods and images in a stable order", func() {
+			model := modelWithPodsAndImages(5, 3)
+			pods := []string{}
+			images := []string{}
+			token := ""
+			for pages := 0; ; pages++ {
+				Expect(pages).To(BeNumerically("<", 3))
+				result, err := parse("limit=2&continue=" + token).Apply(model)
+				Expect(err).To(BeNil())
+				Expect(result.CoreModel.Generation).To(Equal(int64(7)))
+				Expect(len(result.CoreModel.Pods)).To(BeNumerically("<=", 2))
+				for name := range result.CoreModel.Pods {
+					pods = append(pods, name)
+				}
+				for sha := range result.CoreModel.Images {
+					images = append(images, sha)
+				}
+				token = result.Continue
+				if token == "" {
+					break
+				}
+			}
+			Expect(pods).To(ConsistOf("ns/pod0", "ns/pod1", "ns/pod2", "ns/pod3", "ns/pod4"))
+			Expect(images).To(ConsistOf("sha0", "sha1", "sha2"))
+			// paging doesn't modify the snapshot
+			Expect(len(model.CoreModel.Pods)).To(Equal(5))
+		})
+
+		It("returns only the included sections", func() {
+			result, err := parse("include=pods,config").Apply(modelWithPodsAndImages(2, 2))
+			Expect(err).To(BeNil())
+			Expect(len(result.CoreModel.Pods)).To(Equal(2))
+			Expect(result.CoreModel.Images).To(BeNil())
+			Expect(result.Hubs).To(BeNil())
+			Expect(result.Config).ToNot(BeNil())
+		})
+
+		It("rejects invalid parameters", func() {
+			for _, rawQuery := range []string{"limit=-1", "limit=abc", "include=pods,nope"} {
+				values, _ := url.ParseQuery(rawQuery)
+				_, err := ParseModelQuery(values)
+				Expect(err).ToNot(BeNil(), rawQuery)
+			}
+			_, err := parse("continue=!!!").Apply(modelWithPodsAndImages(1, 1))
+			Expect(err).ToNot(BeNil())
+		})
+	})
+}
@@ -23,6 +23,11 @@ package api
 
 import (
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -38,4 +43,64 @@ func RunNextImageTests() {
 			Expect(nextImage.ImageSpec.Sha).To(Equal("04bb619150cd99cfb21e76429c7a5c2f4545775b07456cb6b9c866c8aff9f9e5"))
 		})
 	})
+
+	Describe("ParseNextImageQuery", func() {
+		It("doesn't wait by default", func() {
+			query, err := ParseNextImageQuery(url.Values{})
+			Expect(err).To(BeNil())
+			Expect(query.Wait).To(Equal(time.Duration(0)))
+		})
+
+		It("caps the wait", func() {
+			query, err := ParseNextImageQuery(url.Values{"wait": {"30s"}})
+			Expect(err).To(BeNil())
+			Expect(query.Wait).To(Equal(30 * time.Second))
+			query, err = ParseNextImageQuery(url.Values{"wait": {"1h"}})
+			Expect(err).To(BeNil())
+			Expect(query.Wait).To(Equal(MaxNextImageWait))
+		})
+
+		It("rejects invalid waits", func() {
+			for _, wait := range []string{"soon", "-1s", "30"} {
+				_, err := ParseNextImageQuery(url.Values{"wait": {wait}})
+				Expect(err).To(BeAssignableToTypeOf(&ValidationError{}), wait)
+			}
+			mux := http.NewServeMux()
+			setupHandlers(mux, NewMockResponder())
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest("POST", "/api/v1/nextimage?wait=soon", nil))
+			Expect(recorder.Code).To(Equal(400))
+		})
+	})
+	Describe("POST /nextimage/ack", func() {
+		var mux *http.ServeMux
+		BeforeEach(func() {
+			responder := NewMockResponder()
+			responder.ScanLeases["sha1"] = "lease-2"
+			mux = http.NewServeMux()
+			setupHandlers(mux, responder)
+		})
+		post := func(body string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest("POST", "/api/v1/nextimage/ack", strings.NewReader(body)))
+			return recorder
+		}
+
+		It("acknowledges the current lease", func() {
+			Expect(post(`{"Sha":"sha1","LeaseID":"lease-2","Scanner":"scanner-1"}`).Code).To(Equal(http.StatusOK))
+		})
+
+		It("rejects stale leases with a conflict", func() {
+			recorder := post(`{"Sha":"sha1","LeaseID":"lease-1"}`)
+			Expect(recorder.Code).To(Equal(http.StatusConflict))
+			var response ErrorResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(BeNil())
+			Expect(response.Code).To(Equal(ErrorCodeStaleLease))
+			Expect(response.Details).To(Equal(map[string]interface{}{"sha": "sha1", "leaseID": "lease-1", "reason": ScanLeaseReasonStale}))
+		})
+
+		It("rejects malformed acknowledgments", func() {
+			Expect(post(`{"Sha":`).Code).To(Equal(http.StatusBadRequest))
+		})
+	})
 }
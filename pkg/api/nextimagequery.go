@@ -0,0 +1,56 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"net/url"
+	"time"
+)
+
+// MaxNextImageWait caps how long a scanner may wait for an image.
+const MaxNextImageWait = time.Minute
+
+// NextImageQuery .....
+type NextImageQuery struct {
+	// Wait, if set, is how long to wait for an image when none is available
+	// right away.  Otherwise, the response is immediate.
+	Wait time.Duration
+}
+
+// ParseNextImageQuery reads the `wait` query parameter, a duration such as
+// "30s"; it's capped at MaxNextImageWait.
+func ParseNextImageQuery(values url.Values) (*NextImageQuery, error) {
+	query := &NextImageQuery{}
+	value := values.Get("wait")
+	if value == "" {
+		return query, nil
+	}
+	wait, err := time.ParseDuration(value)
+	if err != nil || wait < 0 {
+		return nil, NewValidationError("invalid wait %q: expected a non-negative duration such as 30s", value)
+	}
+	if wait > MaxNextImageWait {
+		wait = MaxNextImageWait
+	}
+	query.Wait = wait
+	return query, nil
+}
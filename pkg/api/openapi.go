@@ -0,0 +1,499 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// operation describes one route of the HTTP API.  responderMethod is the
+// Responder method which handles it; request and response are the types of
+// the JSON bodies, and are nil when there is no body.  If the path or query
+// parameters are parsed into a value which is passed to the responder,
+// params is its type.  Path parameters appear in path as `{name}`.
+type operation struct {
+	path            string
+	method          string
+	responderMethod string
+	summary         string
+	request         reflect.Type
+	response        reflect.Type
+	params          reflect.Type
+	parameters      []string
+	// streaming operations send a stream of Server-Sent Events, each holding
+	// a response, rather than a single response
+	streaming bool
+	// yaml operations also send their response as YAML, if asked to with
+	// `?format=yaml` or an Accept header
+	yaml bool
+	// downloads send their response as a file in one of these formats,
+	// one row per response
+	downloads []string
+	// etag operations send an ETag, and answer a matching If-None-Match
+	// with a 304 and no body
+	etag bool
+}
+
+func typeOf(value interface{}) reflect.Type {
+	return reflect.TypeOf(value)
+}
+
+var operations = []*operation{
+	{
+		path:            "/model",
+		method:          "GET",
+		responderMethod: "GetModel",
+		yaml:            true,
+		etag:            true,
+		summary:         "dump the model",
+		response:        typeOf(Model{}),
+		parameters:      []string{"limit", "continue", "include"},
+	},
+	{
+		path:            "/image",
+		method:          "GET",
+		responderMethod: "GetImagesByName",
+		yaml:            true,
+		summary:         "list the images seen under the repository and tag given by name, such as registry/app:1.4.2, most recently seen first",
+		response:        typeOf(ImagesByName{}),
+		params:          typeOf(""),
+		parameters:      []string{"name"},
+	},
+	{
+		path:            "/image/{sha}",
+		method:          "GET",
+		responderMethod: "GetImage",
+		yaml:            true,
+		summary:         "describe the image with the given sha, or unambiguous sha prefix",
+		response:        typeOf(ImageDetail{}),
+		params:          typeOf(""),
+	},
+	{
+		path:            "/image/{sha}/requeue",
+		method:          "POST",
+		responderMethod: "RequeueImage",
+		summary:         "put an image which is being scanned back in the scan queue, for when its scanner is known to have died, or after a terminal scan failure has been dealt with",
+		response:        typeOf(ImageRequeueResult{}),
+		params:          typeOf(""),
+	},
+	{
+		path:            "/pod/{namespace}/{name}",
+		method:          "GET",
+		responderMethod: "GetPod",
+		yaml:            true,
+		summary:         "describe the pod with the given namespace and name, and the scan status of its images",
+		response:        typeOf(PodDetail{}),
+		params:          typeOf(""),
+	},
+	{
+		path:            "/config",
+		method:          "GET",
+		responderMethod: "GetConfig",
+		yaml:            true,
+		summary:         "describe the configuration in effect, including settings changed at runtime",
+		response:        typeOf(RuntimeConfig{}),
+	},
+	{
+		path:            "/metrics/summary",
+		method:          "GET",
+		responderMethod: "GetMetricsSummary",
+		summary:         "summarize the prometheus metrics as JSON: hub states, queue depths, in-progress scans, and scan and reducer activity over the last hour",
+		response:        typeOf(MetricsSummary{}),
+	},
+	{
+		path:            "/audit",
+		method:          "GET",
+		responderMethod: "GetAuditLog",
+		yaml:            true,
+		summary:         "page through the record of mutating requests, newest first; always requires authentication",
+		response:        typeOf(AuditPage{}),
+		params:          typeOf(AuditQuery{}),
+		parameters:      []string{"limit", "continue"},
+	},
+	{
+		path:            "/stats/sources",
+		method:          "GET",
+		responderMethod: "GetSourceStats",
+		yaml:            true,
+		summary:         "count the requests, bytes ingested and validation failures of each source over the last 5 minutes and hour, busiest first",
+		response:        typeOf(SourceStatsReport{}),
+	},
+	{
+		path:            "/healthz",
+		method:          "GET",
+		responderMethod: "GetLiveness",
+		summary:         "liveness check",
+		response:        typeOf(Health{}),
+	},
+	{
+		path:            "/readyz",
+		method:          "GET",
+		responderMethod: "GetReadiness",
+		summary:         "readiness check, describing each hub's connectivity; with failIfAllHubsDown, unready if no hub client is up",
+		response:        typeOf(Health{}),
+		params:          typeOf(ReadinessQuery{}),
+		parameters:      []string{"failIfAllHubsDown"},
+	},
+	{
+		path:            "/pod",
+		method:          "POST",
+		responderMethod: "AddPod",
+		summary:         "add a pod",
+		request:         typeOf(Pod{}),
+	},
+	{
+		path:            "/pod",
+		method:          "PUT",
+		responderMethod: "UpdatePod",
+		summary:         "create or update a pod, reporting which, and which of its images are new",
+		request:         typeOf(Pod{}),
+		response:        typeOf(PodUpsertResult{}),
+	},
+	{
+		path:            "/pod",
+		method:          "DELETE",
+		responderMethod: "DeletePod",
+		summary:         "delete a pod by its qualified name, passed as the plain text body; 404 if it isn't present, 202 if the deletion was deferred",
+		request:         typeOf(""),
+	},
+	{
+		path:            "/pods",
+		method:          "DELETE",
+		responderMethod: "DeletePods",
+		summary:         "delete every pod in a namespace, reporting which; always requires authentication",
+		response:        typeOf(PodsDeletion{}),
+		params:          typeOf(PodsDeletionQuery{}),
+		parameters:      []string{"namespace", "dryRun"},
+	},
+	{
+		path:            "/allpods",
+		method:          "PUT",
+		responderMethod: "UpdateAllPods",
+		summary:         "replace all pods",
+		request:         typeOf(AllPods{}),
+	},
+	{
+		path:            "/allimages",
+		method:          "PUT",
+		responderMethod: "UpdateAllImages",
+		summary:         "replace all images",
+		request:         typeOf(AllImages{}),
+	},
+	{
+		path:            "/image",
+		method:          "POST",
+		responderMethod: "AddImage",
+		summary:         "add an image",
+		request:         typeOf(Image{}),
+	},
+	{
+		path:            "/images",
+		method:          "POST",
+		responderMethod: "AddImages",
+		summary:         "add a batch of images; an invalid image rejects the whole batch, unless acceptValid is set",
+		request:         typeOf([]Image{}),
+		response:        typeOf(BulkImagesResult{}),
+		params:          typeOf(false),
+		parameters:      []string{"acceptValid"},
+	},
+	{
+		path:            "/pods",
+		method:          "GET",
+		responderMethod: "GetPods",
+		yaml:            true,
+		summary:         "list pods with a rollup of their images' scan statuses, ordered by namespace and name; filtered like /scanresults, and paged like /model",
+		response:        typeOf(PodList{}),
+		params:          typeOf(PodsQuery{}),
+		parameters:      []string{"namespace", "podPrefix", "labelSelector", "limit", "continue"},
+	},
+	{
+		path:            "/scanresults",
+		method:          "GET",
+		responderMethod: "GetScanResults",
+		yaml:            true,
+		etag:            true,
+		summary:         "get the scan results of pods and images, optionally filtered by namespace, pod name prefix and label selector",
+		response:        typeOf(ScanResults{}),
+		params:          typeOf(ScanResultsQuery{}),
+		parameters:      []string{"namespace", "podPrefix", "labelSelector"},
+	},
+	{
+		path:            "/scanqueue/stats",
+		method:          "GET",
+		responderMethod: "GetScanQueueStats",
+		summary:         "summarize the scan queue: depth by priority, oldest queued image, and the dispatch rate and average wait over the last hour",
+		response:        typeOf(ScanQueueStats{}),
+	},
+	{
+		path:            "/export/findings",
+		method:          "GET",
+		responderMethod: "ExportFindings",
+		summary:         "download the findings of each scanned image of each pod as CSV or NDJSON, filtered like /scanresults",
+		response:        typeOf(Finding{}),
+		params:          typeOf(FindingsExportQuery{}),
+		parameters:      []string{"namespace", "podPrefix", "labelSelector", "format"},
+		downloads:       []string{"text/csv", "application/x-ndjson"},
+	},
+	{
+		path:            "/stream/scanresults",
+		method:          "GET",
+		responderMethod: "SubscribeScanResults",
+		summary:         "stream changes to pod and image scan results as Server-Sent Events; resume with Last-Event-ID",
+		response:        typeOf(ScanResultsEvent{}),
+		params:          typeOf(""),
+		parameters:      []string{"lastEventId"},
+		streaming:       true,
+	},
+	{
+		path:            "/sethubs",
+		method:          "POST",
+		responderMethod: "SetHubs",
+		summary:         "replace the set of hubs, reporting which were added, removed, unchanged or failed; always requires a token when auth is enabled",
+		request:         typeOf(SetHubsRequest{}),
+		response:        typeOf(SetHubsResult{}),
+	},
+	{
+		path:            "/hub/{host}/login",
+		method:          "POST",
+		responderMethod: "LoginHub",
+		summary:         "log in to the hub now, resetting its login backoff; 404 if there's no hub client for the host",
+		response:        typeOf(HubLoginResult{}),
+		params:          typeOf(""),
+	},
+	{
+		path:            "/hub/{host}/circuitbreaker",
+		method:          "GET",
+		responderMethod: "GetHubCircuitBreaker",
+		summary:         "describe the hub's circuit breaker, including when it will next allow a request",
+		response:        typeOf(ModelCircuitBreaker{}),
+		params:          typeOf(""),
+	},
+	{
+		path:            "/hub/{host}/circuitbreaker/reset",
+		method:          "POST",
+		responderMethod: "ResetHubCircuitBreaker",
+		summary:         "reset the hub's circuit breaker, returning its state before and after",
+		response:        typeOf(CircuitBreakerReset{}),
+		params:          typeOf(""),
+	},
+	{
+		path:            "/command",
+		method:          "POST",
+		responderMethod: "PostCommand",
+		summary:         "issue a command",
+		request:         typeOf(PostCommand{}),
+	},
+	{
+		path:            "/nextimage",
+		method:          "POST",
+		responderMethod: "GetNextImage",
+		summary:         "get the next image to scan, leased to the caller; with wait, hold the request until an image is available, for up to a minute",
+		response:        typeOf(NextImage{}),
+		params:          typeOf(NextImageQuery{}),
+		parameters:      []string{"wait"},
+	},
+	{
+		path:            "/concurrentscanlimit",
+		method:          "POST",
+		responderMethod: "SetConcurrentScanLimit",
+		summary:         "set the per-hub concurrent scan limit; 0 stops new scans from being dispatched",
+		request:         typeOf(SetConcurrentScanLimit{}),
+	},
+	{
+		path:            "/concurrentscanlimit",
+		method:          "GET",
+		responderMethod: "GetConcurrentScanLimit",
+		summary:         "get the per-hub concurrent scan limit, and the number of scans in progress",
+		response:        typeOf(ConcurrentScanLimit{}),
+	},
+	{
+		path:            "/finishedscan",
+		method:          "POST",
+		responderMethod: "PostFinishScan",
+		summary:         "report a finished scan client job, presenting the lease from its ImageSpec; images whose failures are categorized as terminal are not requeued; 409 if the lease is no longer held",
+		request:         typeOf(FinishedScanClientJob{}),
+	},
+	{
+		path:            "/nextimage/ack",
+		method:          "POST",
+		responderMethod: "AcknowledgeScanLease",
+		summary:         "acknowledge receiving the next image, within the TTL of its lease, or it's requeued; 409 if the lease is no longer held",
+		request:         typeOf(ScanLeaseAcknowledgement{}),
+	},
+}
+
+// OpenAPISpec generates an OpenAPI v3 description of the HTTP API.
+func OpenAPISpec() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	paths := map[string]interface{}{}
+	for _, op := range operations {
+		pathItem, ok := paths[op.path].(map[string]interface{})
+		if !ok {
+			pathItem = map[string]interface{}{}
+			paths[op.path] = pathItem
+		}
+		response := map[string]interface{}{"description": "success"}
+		if op.streaming {
+			response["content"] = map[string]interface{}{"text/event-stream": map[string]interface{}{"schema": schemaOf(op.response, schemas)}}
+		} else if len(op.downloads) > 0 {
+			content := map[string]interface{}{}
+			for _, contentType := range op.downloads {
+				content[contentType] = map[string]interface{}{"schema": schemaOf(op.response, schemas)}
+			}
+			response["content"] = content
+		} else if op.response != nil {
+			response["content"] = mediaType(op.response, schemas)
+		}
+		if op.yaml {
+			content := response["content"].(map[string]interface{})
+			content[ContentTypeYAML] = map[string]interface{}{"schema": schemaOf(op.response, schemas)}
+		}
+		responses := map[string]interface{}{
+			"200": response,
+			"default": map[string]interface{}{
+				"description": "error",
+				"content":     mediaType(typeOf(ErrorResponse{}), schemas),
+			},
+		}
+		spec := map[string]interface{}{
+			"operationId": op.responderMethod,
+			"summary":     op.summary,
+			"responses":   responses,
+		}
+		parameters := []interface{}{}
+		if op.etag {
+			response["headers"] = map[string]interface{}{"ETag": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}}
+			responses["304"] = map[string]interface{}{"description": "not modified since the If-None-Match ETag"}
+			parameters = append(parameters, map[string]interface{}{
+				"name":   "If-None-Match",
+				"in":     "header",
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, match := range pathParameterRegexp.FindAllStringSubmatch(op.path, -1) {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     match[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+		queryParameters := op.parameters
+		if op.yaml {
+			queryParameters = append(append([]string{}, op.parameters...), "format")
+		}
+		for _, name := range queryParameters {
+			parameters = append(parameters, map[string]interface{}{
+				"name":   name,
+				"in":     "query",
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		if len(parameters) > 0 {
+			spec["parameters"] = parameters
+		}
+		if op.request != nil {
+			spec["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  mediaType(op.request, schemas),
+			}
+		}
+		pathItem[strings.ToLower(op.method)] = spec
+	}
+	return map[string]interface{}{
+		"openapi":    "3.0.0",
+		"info":       map[string]interface{}{"title": "perceptor", "version": "1.0"},
+		"servers":    []interface{}{map[string]interface{}{"url": VersionedPath(legacyAPIVersion, "")}},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+func mediaType(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	contentType := "application/json"
+	if t.Kind() == reflect.String {
+		contentType = "text/plain"
+	}
+	return map[string]interface{}{contentType: map[string]interface{}{"schema": schemaOf(t, schemas)}}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+var pathParameterRegexp = regexp.MustCompile(`\{([^}]+)\}`)
+
+// schemaOf adds named structs to `schemas`, and refers to them by name.
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), schemas)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		if _, ok := schemas[t.Name()]; !ok {
+			// placeholder, in case of recursive types
+			schemas[t.Name()] = map[string]interface{}{}
+			schemas[t.Name()] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	default: // interface{}: anything goes
+		return map[string]interface{}{}
+	}
+}
+
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			tagName := strings.Split(tag, ",")[0]
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		properties[name] = schemaOf(field.Type, schemas)
+	}
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
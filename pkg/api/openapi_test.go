@@ -0,0 +1,134 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+func derefType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+func RunOpenAPITests() {
+	Describe("OpenAPI spec", func() {
+		It("covers every responder method, with matching request and response types", func() {
+			responderType := reflect.TypeOf((*Responder)(nil)).Elem()
+			for i := 0; i < responderType.NumMethod(); i++ {
+				method := responderType.Method(i)
+				if method.Name == "NotFound" || method.Name == "Error" {
+					continue
+				}
+				var op *operation
+				for _, candidate := range operations {
+					if candidate.responderMethod == method.Name {
+						op = candidate
+					}
+				}
+				Expect(op).ToNot(BeNil(), "no operation for responder method %s", method.Name)
+				// a leading context is the request's, not part of the request
+				firstIn := 0
+				if method.Type.NumIn() > 0 && method.Type.In(0) == contextType {
+					firstIn = 1
+				}
+				if method.Type.NumIn() > firstIn {
+					input := derefType(method.Type.In(firstIn))
+					Expect(input == op.request || input == op.params).To(BeTrue(), "request type of %s", method.Name)
+				} else {
+					Expect(op.request).To(BeNil(), "request type of %s", method.Name)
+					Expect(op.params).To(BeNil(), "params type of %s", method.Name)
+				}
+				if op.params != nil && op.params.Kind() == reflect.Struct {
+					Expect(len(op.parameters)).To(Equal(op.params.NumField()), "query parameters of %s", method.Name)
+				}
+				if op.streaming {
+					// the events of the subscription
+					events, ok := derefType(method.Type.Out(0)).FieldByName("Events")
+					Expect(ok).To(BeTrue(), "events of %s", method.Name)
+					Expect(op.response).To(Equal(derefType(events.Type.Elem())), "response type of %s", method.Name)
+				} else if len(op.downloads) > 0 {
+					// the rows passed to the callback
+					emit := method.Type.In(method.Type.NumIn() - 1)
+					Expect(op.response).To(Equal(derefType(emit.In(0))), "response type of %s", method.Name)
+				} else if op.response != nil {
+					Expect(method.Type.NumOut()).To(BeNumerically(">", 0))
+					Expect(op.response).To(Equal(derefType(method.Type.Out(0))), "response type of %s", method.Name)
+				}
+			}
+		})
+
+		It("only describes routes which the server handles", func() {
+			responder := NewMockResponder()
+			Expect(responder.AddImage(Image{Repository: "repo", Tag: "tag", Sha: "abc"})).To(BeNil())
+			SetupHTTPServer(responder)
+			for _, op := range operations {
+				path := pathParameterRegexp.ReplaceAllString(op.path, "abc")
+				request := httptest.NewRequest(op.method, path, nil)
+				if op.streaming {
+					// disconnect straight away
+					ctx, cancel := context.WithCancel(request.Context())
+					cancel()
+					request = request.WithContext(ctx)
+				}
+				recorder := httptest.NewRecorder()
+				http.DefaultServeMux.ServeHTTP(recorder, request)
+				if recorder.Code == 404 {
+					// a missing resource, rather than a missing route, says which one
+					var errorResponse ErrorResponse
+					Expect(json.Unmarshal(recorder.Body.Bytes(), &errorResponse)).To(BeNil())
+					Expect(errorResponse.Details).ToNot(BeNil(), "%s %s", op.method, op.path)
+				}
+			}
+			request := httptest.NewRequest("GET", "/swagger.json", nil)
+			recorder := httptest.NewRecorder()
+			http.DefaultServeMux.ServeHTTP(recorder, request)
+			Expect(recorder.Code).To(Equal(200))
+			var spec map[string]interface{}
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &spec)).To(BeNil())
+			Expect(spec["openapi"]).To(Equal("3.0.0"))
+		})
+
+		It("describes nested types", func() {
+			spec := OpenAPISpec()
+			schemas := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+			pod := schemas["Pod"].(map[string]interface{})["properties"].(map[string]interface{})
+			Expect(pod["Containers"]).To(Equal(map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"$ref": "#/components/schemas/Container"},
+			}))
+			Expect(schemas).To(HaveKey("Image"))
+			Expect(schemas).To(HaveKey("ModelImageInfo"))
+		})
+	})
+}
@@ -21,12 +21,15 @@ under the License.
 
 package api
 
+import "fmt"
+
 // Pod .....
 type Pod struct {
 	Name       string
 	UID        string
 	Namespace  string
 	Containers []Container
+	Labels     map[string]string
 }
 
 // NewPod .....
@@ -38,3 +41,12 @@ func NewPod(name string, uid string, namespace string, containers []Container) *
 		Containers: containers,
 	}
 }
+
+// PodNotFoundError is returned when a pod can't be found by its qualified name.
+type PodNotFoundError struct {
+	QualifiedName string
+}
+
+func (err *PodNotFoundError) Error() string {
+	return fmt.Sprintf("no pod found named %s", err.QualifiedName)
+}
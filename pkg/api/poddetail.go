@@ -0,0 +1,92 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// PodDetail describes one pod, and the scan status of each of its containers'
+// images.
+type PodDetail struct {
+	QualifiedName string
+	Namespace     string
+	Name          string
+	UID           string
+	Labels        map[string]string
+	Status        PodStatus
+	Containers    []*PodContainerDetail
+	// PolicyViolations, Vulnerabilities and OverallStatus roll up the scan
+	// results of the pod's images, and are only set once they're all scanned
+	PolicyViolations int
+	Vulnerabilities  int
+	OverallStatus    string
+	TimeAdded        string
+	// TimeOfLastStatusChange is the most recent time that one of the pod's
+	// images changed scan status, or TimeAdded if that's later
+	TimeOfLastStatusChange string
+}
+
+// PodContainerDetail is a container of a pod, and its image's scan status.
+type PodContainerDetail struct {
+	Name       string
+	Repository string
+	Tag        string
+	Sha        string
+	ScanStatus string
+	// ScanSummary is nil until the image has scan results
+	ScanSummary *ScannedImage
+}
+
+// RollUpPodStatus is the overall status of a pod with `images` distinct
+// images, of which `completeImages` are scanned and `failedImages` last
+// failed to be.
+func RollUpPodStatus(images int, completeImages int, failedImages int) PodStatus {
+	switch {
+	case completeImages == images:
+		return PodStatusComplete
+	case failedImages > 0:
+		return PodStatusFailed
+	default:
+		return PodStatusPending
+	}
+}
+
+// parsePodPath splits the escaped path `{namespace}/{name}` of a pod, so that
+// an escaped slash stays part of the segment it's in.
+func parsePodPath(escapedPath string) (namespace string, name string, err error) {
+	segments := strings.Split(escapedPath, "/")
+	if len(segments) != 2 || segments[0] == "" || segments[1] == "" {
+		return "", "", fmt.Errorf("expected a path of the form /pod/{namespace}/{name}, got /pod/%s", escapedPath)
+	}
+	namespace, err = url.PathUnescape(segments[0])
+	if err != nil {
+		return "", "", fmt.Errorf("invalid namespace %s: %s", segments[0], err.Error())
+	}
+	name, err = url.PathUnescape(segments[1])
+	if err != nil {
+		return "", "", fmt.Errorf("invalid pod name %s: %s", segments[1], err.Error())
+	}
+	return namespace, name, nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunPodDetailTests() {
+	Describe("GET /pod/{namespace}/{name}", func() {
+		var mux *http.ServeMux
+		BeforeEach(func() {
+			responder := NewMockResponder()
+			for _, pod := range []*Pod{
+				{Name: "pod1", Namespace: "ns1", UID: "uid1", Containers: []Container{{Name: "c1", Image: Image{Repository: "nginx", Tag: "1.15", Sha: "sha1"}}}},
+				{Name: "pod/one", Namespace: "ns 1", UID: "uid2"},
+				{Name: "pöd", Namespace: "ns1", UID: "uid3"},
+			} {
+				responder.Pods[pod.Namespace+"/"+pod.Name] = pod
+			}
+			responder.Images["sha1"] = ImageInfo{Image: Image{Repository: "nginx", Tag: "1.15", Sha: "sha1"}, Vulnerabilities: 3, OverallStatus: "IN_VIOLATION"}
+			mux = http.NewServeMux()
+			setupHandlers(mux, responder)
+		})
+		get := func(path string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+			return recorder
+		}
+		getDetail := func(path string) *PodDetail {
+			recorder := get(path)
+			Expect(recorder.Code).To(Equal(http.StatusOK), recorder.Body.String())
+			var detail PodDetail
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &detail)).To(BeNil())
+			return &detail
+		}
+
+		It("describes the pod and its containers' images", func() {
+			detail := getDetail("/api/v1/pod/ns1/pod1")
+			Expect(detail.QualifiedName).To(Equal("ns1/pod1"))
+			Expect(detail.UID).To(Equal("uid1"))
+			Expect(detail.Status).To(Equal(PodStatusComplete))
+			Expect(detail.Vulnerabilities).To(Equal(3))
+			Expect(detail.OverallStatus).To(Equal("IN_VIOLATION"))
+			Expect(detail.Containers).To(HaveLen(1))
+			Expect(detail.Containers[0].Name).To(Equal("c1"))
+			Expect(detail.Containers[0].Sha).To(Equal("sha1"))
+			Expect(detail.Containers[0].ScanSummary.Vulnerabilities).To(Equal(3))
+		})
+
+		It("unescapes each segment separately", func() {
+			Expect(getDetail("/api/v1/pod/ns%201/pod%2Fone").QualifiedName).To(Equal("ns 1/pod/one"))
+			Expect(getDetail("/api/v1/pod/ns1/p%C3%B6d").Name).To(Equal("pöd"))
+		})
+
+		It("unescapes the legacy, unversioned path too", func() {
+			Expect(getDetail("/pod/ns%201/pod%2Fone").QualifiedName).To(Equal("ns 1/pod/one"))
+		})
+
+		It("returns a structured 404 for an unknown pod", func() {
+			recorder := get("/api/v1/pod/ns1/pod2")
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			var response ErrorResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(BeNil())
+			Expect(response.Code).To(Equal(ErrorCodeNotFound))
+			Expect(response.Details).To(Equal(map[string]interface{}{"pod": "ns1/pod2"}))
+		})
+
+		It("rejects paths without exactly a namespace and a name", func() {
+			for _, path := range []string{"/api/v1/pod/ns1", "/api/v1/pod/ns1/", "/api/v1/pod/ns%201/pod/one"} {
+				Expect(get(path).Code).To(Equal(http.StatusBadRequest), path)
+			}
+		})
+	})
+}
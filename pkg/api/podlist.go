@@ -0,0 +1,172 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// the scan statuses of ModelImageInfo which pods are rolled up from
+const (
+	modelScanStatusInQueue  = "ScanStatusInQueue"
+	modelScanStatusComplete = "ScanStatusComplete"
+)
+
+// PodStatus is the overall scan status of a pod's images.
+type PodStatus string
+
+// .....
+const (
+	// PodStatusComplete means every image has been scanned
+	PodStatusComplete PodStatus = "complete"
+	// PodStatusFailed means some image isn't scanned, and its last attempt failed
+	PodStatusFailed PodStatus = "failed"
+	// PodStatusPending means some images are waiting for, or in, a scan
+	PodStatusPending PodStatus = "pending"
+)
+
+// PodsQuery filters pods in the same way as ScanResultsQuery, and pages
+// through them in the same way as ModelQuery.
+type PodsQuery struct {
+	Namespace     string
+	PodNamePrefix string
+	LabelSelector []*LabelRequirement
+	// Limit is the maximum number of pods per page; 0 means no limit
+	Limit int
+	// Continue is the token from the previous page
+	Continue string
+}
+
+// ParsePodsQuery reads the `namespace`, `podPrefix`, `labelSelector`,
+// `limit` and `continue` query parameters.
+func ParsePodsQuery(values url.Values) (*PodsQuery, error) {
+	filter, err := ParseScanResultsQuery(values)
+	if err != nil {
+		return nil, err
+	}
+	query := &PodsQuery{
+		Namespace:     filter.Namespace,
+		PodNamePrefix: filter.PodNamePrefix,
+		LabelSelector: filter.LabelSelector,
+		Continue:      values.Get("continue"),
+	}
+	if limit := values.Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 0 {
+			return nil, fmt.Errorf("invalid limit %s", limit)
+		}
+	}
+	return query, nil
+}
+
+func (query *PodsQuery) filter() *ScanResultsQuery {
+	return &ScanResultsQuery{Namespace: query.Namespace, PodNamePrefix: query.PodNamePrefix, LabelSelector: query.LabelSelector}
+}
+
+// PodSummary rolls up the scan statuses of a pod's distinct images.  Images
+// which are neither complete, queued nor failed are being scanned, or
+// haven't been looked at yet.
+type PodSummary struct {
+	QualifiedName  string
+	Namespace      string
+	Name           string
+	Status         PodStatus
+	Images         int
+	CompleteImages int
+	QueuedImages   int
+	FailedImages   int
+}
+
+// PodList is a page of pods, ordered by namespace and then name.
+type PodList struct {
+	Pods       []*PodSummary
+	Generation int64
+	// Continue, if set, fetches the next page of pods
+	Continue string `json:",omitempty"`
+}
+
+func summarizePod(qualifiedName string, pod *Pod, images map[string]*ModelImageInfo) *PodSummary {
+	summary := &PodSummary{QualifiedName: qualifiedName, Namespace: pod.Namespace, Name: pod.Name}
+	shas := map[string]bool{}
+	for _, container := range pod.Containers {
+		shas[container.Image.Sha] = true
+	}
+	summary.Images = len(shas)
+	for sha := range shas {
+		image, ok := images[sha]
+		switch {
+		case !ok:
+		case image.ScanStatus == modelScanStatusComplete:
+			summary.CompleteImages++
+		case len(image.FailureHistory) > 0:
+			summary.FailedImages++
+		case image.ScanStatus == modelScanStatusInQueue:
+			summary.QueuedImages++
+		}
+	}
+	summary.Status = RollUpPodStatus(summary.Images, summary.CompleteImages, summary.FailedImages)
+	return summary
+}
+
+// ListPods returns the page of pods in `coreModel` which `query` asks for.
+func ListPods(coreModel *CoreModel, query *PodsQuery) (*PodList, error) {
+	token := &continueToken{}
+	if query.Continue != "" {
+		var err error
+		token, err = parseContinueToken(query.Continue)
+		if err != nil {
+			return nil, err
+		}
+		if token.podOffset < 0 {
+			return nil, fmt.Errorf("invalid continue token %s", query.Continue)
+		}
+	}
+	filter := query.filter()
+	matches := []string{}
+	for qualifiedName, pod := range coreModel.Pods {
+		if filter.Namespace != "" && pod.Namespace != filter.Namespace {
+			continue
+		}
+		if filter.MatchesPod(pod.Name, pod.Labels) {
+			matches = append(matches, qualifiedName)
+		}
+	}
+	sort.Slice(matches, func(i int, j int) bool {
+		podI, podJ := coreModel.Pods[matches[i]], coreModel.Pods[matches[j]]
+		if podI.Namespace != podJ.Namespace {
+			return podI.Namespace < podJ.Namespace
+		}
+		return podI.Name < podJ.Name
+	})
+	pageNames, nextOffset := page(matches, token.podOffset, query.Limit)
+	list := &PodList{Pods: []*PodSummary{}, Generation: coreModel.Generation}
+	for _, qualifiedName := range pageNames {
+		list.Pods = append(list.Pods, summarizePod(qualifiedName, coreModel.Pods[qualifiedName], coreModel.Images))
+	}
+	if nextOffset >= 0 {
+		list.Continue = (&continueToken{generation: coreModel.Generation, podOffset: nextOffset, imageOffset: -1}).String()
+	}
+	return list, nil
+}
@@ -0,0 +1,104 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunPodListTests() {
+	Describe("ListPods", func() {
+		container := func(sha string) Container {
+			return Container{Name: "c-" + sha, Image: Image{Sha: sha, Repository: "repo"}}
+		}
+		coreModel := &CoreModel{
+			Generation: 7,
+			Pods: map[string]*Pod{
+				"b/web":    {Name: "web", Namespace: "b", Containers: []Container{container("done")}, Labels: map[string]string{"app": "web"}},
+				"a/web":    {Name: "web", Namespace: "a", Containers: []Container{container("done"), container("queued"), container("queued")}, Labels: map[string]string{"app": "web"}},
+				"a-b/db":   {Name: "db", Namespace: "a-b", Containers: []Container{container("done"), container("failed")}},
+				"a/worker": {Name: "worker", Namespace: "a", Containers: []Container{}},
+			},
+			Images: map[string]*ModelImageInfo{
+				"done":   {ImageSha: "done", ScanStatus: "ScanStatusComplete"},
+				"queued": {ImageSha: "queued", ScanStatus: "ScanStatusInQueue"},
+				"failed": {ImageSha: "failed", ScanStatus: "ScanStatusInQueue", FailureHistory: []*ModelScanFailure{{Err: "oops"}}},
+			},
+		}
+		list := func(values url.Values) *PodList {
+			query, err := ParsePodsQuery(values)
+			Expect(err).To(BeNil())
+			pods, err := ListPods(coreModel, query)
+			Expect(err).To(BeNil())
+			return pods
+		}
+		names := func(pods *PodList) []string {
+			names := []string{}
+			for _, pod := range pods.Pods {
+				names = append(names, pod.QualifiedName)
+			}
+			return names
+		}
+
+		It("orders pods by namespace, then name, and rolls up their images", func() {
+			pods := list(url.Values{})
+			Expect(names(pods)).To(Equal([]string{"a/web", "a/worker", "a-b/db", "b/web"}))
+			Expect(pods.Generation).To(Equal(int64(7)))
+			Expect(pods.Continue).To(Equal(""))
+			Expect(*pods.Pods[0]).To(Equal(PodSummary{QualifiedName: "a/web", Namespace: "a", Name: "web", Status: PodStatusPending, Images: 2, CompleteImages: 1, QueuedImages: 1}))
+			Expect(pods.Pods[1].Status).To(Equal(PodStatusComplete))
+			Expect(*pods.Pods[2]).To(Equal(PodSummary{QualifiedName: "a-b/db", Namespace: "a-b", Name: "db", Status: PodStatusFailed, Images: 2, CompleteImages: 1, FailedImages: 1}))
+			Expect(pods.Pods[3].Status).To(Equal(PodStatusComplete))
+		})
+
+		It("filters like the scan results", func() {
+			Expect(names(list(url.Values{"namespace": {"a"}}))).To(Equal([]string{"a/web", "a/worker"}))
+			Expect(names(list(url.Values{"podPrefix": {"w"}}))).To(Equal([]string{"a/web", "a/worker", "b/web"}))
+			Expect(names(list(url.Values{"labelSelector": {"app=web"}}))).To(Equal([]string{"a/web", "b/web"}))
+		})
+
+		It("pages through the pods", func() {
+			all := []string{}
+			values := url.Values{"limit": {"3"}}
+			for pages := 0; ; pages++ {
+				Expect(pages).To(BeNumerically("<", 2))
+				pods := list(values)
+				all = append(all, names(pods)...)
+				if pods.Continue == "" {
+					break
+				}
+				values.Set("continue", pods.Continue)
+			}
+			Expect(all).To(Equal(names(list(url.Values{}))))
+		})
+
+		It("rejects invalid queries", func() {
+			_, err := ParsePodsQuery(url.Values{"limit": {"-1"}})
+			Expect(err).NotTo(BeNil())
+			_, err = ListPods(coreModel, &PodsQuery{Continue: "nope"})
+			Expect(err).NotTo(BeNil())
+		})
+	})
+}
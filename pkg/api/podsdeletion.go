@@ -0,0 +1,63 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// PodsDeletionQuery selects the pods removed by a bulk deletion.
+type PodsDeletionQuery struct {
+	Namespace string
+	// DryRun lists the pods which would be removed, without removing them
+	DryRun bool
+}
+
+// ParsePodsDeletionQuery requires a valid namespace, so that a bulk deletion
+// can't accidentally remove every pod.
+func ParsePodsDeletionQuery(values url.Values) (*PodsDeletionQuery, error) {
+	query := &PodsDeletionQuery{Namespace: values.Get("namespace")}
+	pv := &podValidator{}
+	pv.dns1123Label("namespace", query.Namespace)
+	if value := values.Get("dryRun"); value != "" {
+		dryRun, err := strconv.ParseBool(value)
+		if err != nil {
+			pv.problem("dryRun", "must be a boolean")
+		}
+		query.DryRun = dryRun
+	}
+	if len(pv.problems) > 0 {
+		return nil, &ValidationError{Message: "invalid pods deletion query", Fields: pv.problems}
+	}
+	return query, nil
+}
+
+// PodsDeletion reports the pods removed from a namespace, or which would
+// have been for a dry run.
+type PodsDeletion struct {
+	Namespace string
+	DryRun    bool
+	Deleted   int
+	// Pods are the qualified names of the removed pods, sorted
+	Pods []string
+}
@@ -0,0 +1,85 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunPodsDeletionTests() {
+	Describe("DELETE /pods", func() {
+		var responder *MockResponder
+		var mux *http.ServeMux
+		BeforeEach(func() {
+			responder = NewMockResponder()
+			Expect(responder.AddPod(Pod{Name: "pod1", Namespace: "ns1"})).To(BeNil())
+			Expect(responder.AddPod(Pod{Name: "pod2", Namespace: "ns1"})).To(BeNil())
+			Expect(responder.AddPod(Pod{Name: "pod3", Namespace: "ns2"})).To(BeNil())
+			mux = http.NewServeMux()
+			setupHandlers(mux, responder)
+		})
+		deletePods := func(query string) (*httptest.ResponseRecorder, *PodsDeletion) {
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest("DELETE", "/api/v1/pods"+query, nil))
+			if recorder.Code != http.StatusOK {
+				return recorder, nil
+			}
+			var deletion PodsDeletion
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &deletion)).To(BeNil())
+			return recorder, &deletion
+		}
+
+		It("removes every pod in the namespace", func() {
+			_, deletion := deletePods("?namespace=ns1")
+			Expect(deletion).To(Equal(&PodsDeletion{Namespace: "ns1", Deleted: 2, Pods: []string{"ns1/pod1", "ns1/pod2"}}))
+			Expect(responder.Pods).To(HaveLen(1))
+			Expect(responder.Pods).To(HaveKey("ns2/pod3"))
+		})
+
+		It("only lists the pods for a dry run", func() {
+			_, deletion := deletePods("?namespace=ns1&dryRun=true")
+			Expect(deletion).To(Equal(&PodsDeletion{Namespace: "ns1", DryRun: true, Deleted: 2, Pods: []string{"ns1/pod1", "ns1/pod2"}}))
+			Expect(responder.Pods).To(HaveLen(3))
+		})
+
+		It("succeeds for a namespace without pods", func() {
+			_, deletion := deletePods("?namespace=ns9")
+			Expect(deletion.Deleted).To(Equal(0))
+		})
+
+		It("rejects a missing or invalid namespace", func() {
+			for _, query := range []string{"", "?namespace=NS1", "?namespace=ns1&dryRun=maybe"} {
+				recorder, _ := deletePods(query)
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest), query)
+				var response ErrorResponse
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(BeNil())
+				Expect(response.Code).To(Equal(ErrorCodeValidation))
+			}
+			Expect(responder.Pods).To(HaveLen(3))
+		})
+	})
+}
@@ -0,0 +1,43 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+// PodUpsertOutcome is what a PUT /pod did to perceptor's record of the pod.
+type PodUpsertOutcome string
+
+// .....
+const (
+	PodUpsertOutcomeCreated PodUpsertOutcome = "created"
+	PodUpsertOutcomeUpdated PodUpsertOutcome = "updated"
+	// PodUpsertOutcomeReplaced means a pod of the same name, but a different
+	// UID, was dropped in favor of the new one.
+	PodUpsertOutcomeReplaced PodUpsertOutcome = "replaced"
+)
+
+// PodUpsertResult reports on a PUT /pod, so that a perceiver resyncing its
+// pods can check that perceptor agrees with it.
+type PodUpsertResult struct {
+	Outcome PodUpsertOutcome
+	// EnqueuedImages are the shas of the pod's images which perceptor
+	// didn't know about before, and has now started tracking.
+	EnqueuedImages []string
+}
@@ -0,0 +1,76 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunPodUpsertTests() {
+	Describe("PUT /pod", func() {
+		var responder *MockResponder
+		var mux *http.ServeMux
+		BeforeEach(func() {
+			responder = NewMockResponder()
+			Expect(responder.AddImage(Image{Repository: "repo", Sha: "sha1"})).To(BeNil())
+			mux = http.NewServeMux()
+			setupHandlers(mux, responder)
+		})
+		put := func(pod Pod) *PodUpsertResult {
+			body, err := json.Marshal(pod)
+			Expect(err).To(BeNil())
+			request := httptest.NewRequest("PUT", "/api/v1/pod", strings.NewReader(string(body)))
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, request)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var result PodUpsertResult
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &result)).To(BeNil())
+			return &result
+		}
+		container := func(sha string) Container {
+			return Container{Name: sha, Image: Image{Repository: "repo", Sha: sha}}
+		}
+
+		It("creates unknown pods, and updates known ones", func() {
+			pod := Pod{Name: "pod1", Namespace: "ns1", UID: "uid1", Containers: []Container{container("sha1"), container("sha2")}}
+			Expect(put(pod)).To(Equal(&PodUpsertResult{Outcome: PodUpsertOutcomeCreated, EnqueuedImages: []string{"sha2"}}))
+			Expect(responder.Pods).To(HaveKey("ns1/pod1"))
+			pod.Containers = append(pod.Containers, container("sha3"))
+			Expect(put(pod)).To(Equal(&PodUpsertResult{Outcome: PodUpsertOutcomeUpdated, EnqueuedImages: []string{"sha3"}}))
+			Expect(responder.Pods["ns1/pod1"].Containers).To(HaveLen(3))
+		})
+
+		It("replaces a pod whose UID has changed", func() {
+			pod := Pod{Name: "pod1", Namespace: "ns1", UID: "uid1", Containers: []Container{container("sha1")}}
+			put(pod)
+			pod.UID = "uid2"
+			Expect(put(pod)).To(Equal(&PodUpsertResult{Outcome: PodUpsertOutcomeReplaced, EnqueuedImages: []string{}}))
+			Expect(responder.Pods["ns1/pod1"].UID).To(Equal("uid2"))
+		})
+	})
+}
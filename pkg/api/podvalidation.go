@@ -0,0 +1,128 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const (
+	maxDNS1123LabelLength     = 63
+	maxDNS1123SubdomainLength = 253
+	dns1123LabelPattern       = `[a-z0-9]([-a-z0-9]*[a-z0-9])?`
+)
+
+var (
+	shaRegexp              = regexp.MustCompile(`^[a-f0-9]{64}$`)
+	dns1123LabelRegexp     = regexp.MustCompile(`^` + dns1123LabelPattern + `$`)
+	dns1123SubdomainRegexp = regexp.MustCompile(`^` + dns1123LabelPattern + `(\.` + dns1123LabelPattern + `)*$`)
+)
+
+// FieldError is a problem with one field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Problem string `json:"problem"`
+}
+
+type podValidator struct {
+	problems []FieldError
+	warnings []string
+}
+
+func (pv *podValidator) problem(field string, format string, args ...interface{}) {
+	pv.problems = append(pv.problems, FieldError{Field: field, Problem: fmt.Sprintf(format, args...)})
+}
+
+func (pv *podValidator) dns1123Label(field string, value string) {
+	switch {
+	case value == "":
+		pv.problem(field, "is required")
+	case len(value) > maxDNS1123LabelLength:
+		pv.problem(field, "must be at most %d characters", maxDNS1123LabelLength)
+	case !dns1123LabelRegexp.MatchString(value):
+		pv.problem(field, "must be a DNS-1123 label: lowercase alphanumerics and '-', starting and ending with an alphanumeric")
+	}
+}
+
+func (pv *podValidator) dns1123Subdomain(field string, value string) {
+	switch {
+	case value == "":
+		pv.problem(field, "is required")
+	case len(value) > maxDNS1123SubdomainLength:
+		pv.problem(field, "must be at most %d characters", maxDNS1123SubdomainLength)
+	case !dns1123SubdomainRegexp.MatchString(value):
+		pv.problem(field, "must be a DNS-1123 subdomain: lowercase alphanumerics, '-' and '.'")
+	}
+}
+
+func (pv *podValidator) image(field string, image Image) {
+	if image.Sha == "" && image.Repository == "" {
+		pv.problem(field, "has neither sha nor repository")
+		return
+	}
+	if !shaRegexp.MatchString(image.Sha) {
+		pv.problem(field+".Sha", "must be 64 lowercase hex characters, found %q", image.Sha)
+	}
+	if image.Repository == "" {
+		pv.problem(field+".Repository", "is required")
+	}
+	if image.Priority != nil && *image.Priority < 0 {
+		pv.problem(field+".Priority", "must not be negative")
+	}
+}
+
+// ValidatePod checks a pod before it's allowed anywhere near the model.  It
+// returns warnings for pods which are valid, but unusual, and a
+// *ValidationError listing every problem for invalid pods.
+func ValidatePod(pod Pod) ([]string, error) {
+	pv := &podValidator{}
+	pv.dns1123Subdomain("Name", pod.Name)
+	pv.dns1123Label("Namespace", pod.Namespace)
+	for key := range pod.Labels {
+		if key == "" {
+			pv.problem("Labels", "keys must not be empty")
+		}
+	}
+	if len(pod.Containers) == 0 {
+		pv.warnings = append(pv.warnings, fmt.Sprintf("pod %s/%s has no containers", pod.Namespace, pod.Name))
+	}
+	containerNames := map[string]bool{}
+	for i, container := range pod.Containers {
+		field := fmt.Sprintf("Containers[%d]", i)
+		pv.dns1123Label(field+".Name", container.Name)
+		if container.Name != "" {
+			if containerNames[container.Name] {
+				pv.problem(field+".Name", "duplicate container name %s", container.Name)
+			}
+			containerNames[container.Name] = true
+		}
+		pv.image(field+".Image", container.Image)
+	}
+	if len(pv.problems) > 0 {
+		return pv.warnings, &ValidationError{
+			Message: fmt.Sprintf("invalid pod %s/%s: %d problem(s)", pod.Namespace, pod.Name, len(pv.problems)),
+			Fields:  pv.problems,
+		}
+	}
+	return pv.warnings, nil
+}
@@ -0,0 +1,137 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunPodValidationTests() {
+	Describe("ValidatePod", func() {
+		sha := strings.Repeat("ab", 32)
+		validPod := func() Pod {
+			return Pod{
+				Name:      "pod-1.abc",
+				Namespace: "ns1",
+				Labels:    map[string]string{"app": "web"},
+				Containers: []Container{
+					{Name: "c1", Image: Image{Sha: sha, Repository: "docker.io/repo", Tag: "1.0"}},
+				},
+			}
+		}
+		fieldsOf := func(err error) []string {
+			Expect(err).To(BeAssignableToTypeOf(&ValidationError{}))
+			fields := []string{}
+			for _, field := range err.(*ValidationError).Fields {
+				fields = append(fields, field.Field)
+			}
+			return fields
+		}
+
+		It("accepts a valid pod", func() {
+			warnings, err := ValidatePod(validPod())
+			Expect(err).To(BeNil())
+			Expect(warnings).To(BeEmpty())
+		})
+
+		It("accepts a pod with no containers, with a warning", func() {
+			pod := validPod()
+			pod.Containers = nil
+			warnings, err := ValidatePod(pod)
+			Expect(err).To(BeNil())
+			Expect(warnings).To(HaveLen(1))
+		})
+
+		It("requires DNS-1123 names and namespaces", func() {
+			pod := validPod()
+			pod.Name = "Pod_1"
+			pod.Namespace = ""
+			_, err := ValidatePod(pod)
+			Expect(fieldsOf(err)).To(Equal([]string{"Name", "Namespace"}))
+
+			pod.Name = strings.Repeat("a", maxDNS1123SubdomainLength+1)
+			pod.Namespace = "a.b"
+			_, err = ValidatePod(pod)
+			Expect(fieldsOf(err)).To(Equal([]string{"Name", "Namespace"}))
+		})
+
+		It("rejects empty label keys", func() {
+			pod := validPod()
+			pod.Labels[""] = "x"
+			_, err := ValidatePod(pod)
+			Expect(fieldsOf(err)).To(Equal([]string{"Labels"}))
+		})
+
+		It("rejects bad and duplicate container names", func() {
+			pod := validPod()
+			pod.Containers = append(pod.Containers, pod.Containers[0], Container{Name: "-c", Image: pod.Containers[0].Image})
+			_, err := ValidatePod(pod)
+			Expect(fieldsOf(err)).To(Equal([]string{"Containers[1].Name", "Containers[2].Name"}))
+		})
+
+		It("rejects bad images", func() {
+			negative := -1
+			pod := validPod()
+			pod.Containers = []Container{
+				{Name: "c1", Image: Image{}},
+				{Name: "c2", Image: Image{Sha: strings.ToUpper(sha), Repository: "repo"}},
+				{Name: "c3", Image: Image{Sha: sha}},
+				{Name: "c4", Image: Image{Sha: sha, Repository: "repo", Priority: &negative}},
+			}
+			_, err := ValidatePod(pod)
+			Expect(fieldsOf(err)).To(Equal([]string{
+				"Containers[0].Image",
+				"Containers[1].Image.Sha",
+				"Containers[2].Image.Repository",
+				"Containers[3].Image.Priority",
+			}))
+		})
+
+		It("reports every field problem in a 400", func() {
+			pod := validPod()
+			pod.Namespace = "NS"
+			pod.Containers[0].Image.Sha = "abc"
+			_, err := ValidatePod(pod)
+			request := httptest.NewRequest("POST", "/pod", nil)
+			recorder := httptest.NewRecorder()
+			Expect(WriteError(recorder, request, err, http.StatusInternalServerError, false)).To(Equal(400))
+			var response struct {
+				Code    ErrorCode
+				Details struct {
+					Fields []FieldError
+				}
+			}
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(BeNil())
+			Expect(response.Code).To(Equal(ErrorCodeValidation))
+			Expect(response.Details.Fields).To(HaveLen(2))
+			Expect(response.Details.Fields[0].Field).To(Equal("Namespace"))
+			Expect(response.Details.Fields[1].Field).To(Equal("Containers[0].Image.Sha"))
+			Expect(response.Details.Fields[1].Problem).NotTo(Equal(""))
+		})
+	})
+}
@@ -0,0 +1,196 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/blackducksoftware/perceptor/pkg/util"
+)
+
+// SourceHeader lets a client identify itself, so that several clients behind
+// one address are rate-limited separately.
+const SourceHeader = "X-Perceptor-Source"
+
+// SourceOfRequest returns the client's self-reported identity, falling back
+// to its remote address.
+func SourceOfRequest(r *http.Request) string {
+	if source := r.Header.Get(SourceHeader); source != "" {
+		return source
+	}
+	return remoteHost(r)
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimitKey is the request's remote address, subdivided by its source
+// header.  The header is chosen by the client, so it never lets a client
+// escape the limits of its address.
+func rateLimitKey(r *http.Request) string {
+	host := remoteHost(r)
+	if source := r.Header.Get(SourceHeader); source != "" {
+		return host + " " + source
+	}
+	return host
+}
+
+// RouteClass groups routes which share a rate limit.
+type RouteClass int
+
+// .....
+const (
+	RouteClassRead     RouteClass = iota
+	RouteClassMutating RouteClass = iota
+	RouteClassScanner  RouteClass = iota
+)
+
+func (rc RouteClass) String() string {
+	switch rc {
+	case RouteClassRead:
+		return "read"
+	case RouteClassMutating:
+		return "mutating"
+	case RouteClassScanner:
+		return "scanner"
+	}
+	panic(fmt.Errorf("invalid RouteClass value: %d", rc))
+}
+
+// scannerRoutes have their own limits, so that a misbehaving perceiver can't
+// hold up scanning.
+var scannerRoutes = map[string]bool{
+	"/nextimage":     true,
+	"/nextimage/ack": true,
+	"/finishedscan":  true,
+}
+
+// RouteClassOfRequest .....
+func RouteClassOfRequest(r *http.Request) RouteClass {
+	if scannerRoutes[canonicalRoute(r.URL.Path)] {
+		return RouteClassScanner
+	}
+	if r.Method == "GET" || r.Method == "HEAD" || r.Method == "OPTIONS" {
+		return RouteClassRead
+	}
+	return RouteClassMutating
+}
+
+// RateLimit allows a sustained rate, with bursts of up to Burst requests.
+type RateLimit struct {
+	RequestsPerSecond float64
+	Burst             int
+}
+
+// RateLimitedError means a client has used up its requests for now.
+type RateLimitedError struct {
+	Class      RouteClass
+	RetryAfter time.Duration
+}
+
+func (err *RateLimitedError) Error() string {
+	return fmt.Sprintf("too many %s requests; retry after %s", err.Class, err.RetryAfter)
+}
+
+// rateLimiterIdleTimeout is how long a client can go without requests
+// before its buckets are dropped.
+const rateLimiterIdleTimeout = 10 * time.Minute
+
+// rateLimiterSourcesPerAddress is how many sources' worth of requests an
+// address is allowed in all, however many sources it claims to have.
+const rateLimiterSourcesPerAddress = 10
+
+// throttledClientIdleTimeout is how long a client can go without being
+// throttled before its throttled_requests series are dropped.
+const throttledClientIdleTimeout = time.Hour
+
+// RateLimiter keeps a token bucket per client and route class, where a client
+// is a remote address and the source it names, and a larger one per address.
+// Buckets of clients which have been idle for a while are dropped.
+type RateLimiter struct {
+	limiters        map[RouteClass]*util.KeyedRateLimiter
+	addressLimiters map[RouteClass]*util.KeyedRateLimiter
+	// throttled caps the clients with their own throttled_requests series
+	throttled *util.CappedLabelSet
+	now       func() time.Time
+}
+
+// NewRateLimiter .....  Route classes missing from `limits` aren't limited.
+func NewRateLimiter(limits map[RouteClass]RateLimit) *RateLimiter {
+	rl := &RateLimiter{
+		limiters:        map[RouteClass]*util.KeyedRateLimiter{},
+		addressLimiters: map[RouteClass]*util.KeyedRateLimiter{},
+		now:             time.Now,
+	}
+	// read rl.now on every call, so that tests can swap the clock out
+	now := func() time.Time { return rl.now() }
+	rl.throttled = util.NewCappedLabelSetWithClock(DefaultMaxTrackedSources, UntrackedSources, throttledClientIdleTimeout, forgetThrottledClientMetrics, now)
+	for class, limit := range limits {
+		rl.limiters[class] = util.NewKeyedRateLimiterWithClock(limit.RequestsPerSecond, limit.Burst, rateLimiterIdleTimeout, now)
+		rl.addressLimiters[class] = util.NewKeyedRateLimiterWithClock(limit.RequestsPerSecond*rateLimiterSourcesPerAddress, limit.Burst*rateLimiterSourcesPerAddress, rateLimiterIdleTimeout, now)
+	}
+	return rl
+}
+
+// Allow returns nil, or a RateLimitedError if the request should be rejected.
+func (rl *RateLimiter) Allow(r *http.Request) error {
+	class := RouteClassOfRequest(r)
+	limiter, ok := rl.limiters[class]
+	if !ok {
+		return nil
+	}
+	if wait := limiter.Take(rateLimitKey(r)); wait > 0 {
+		return &RateLimitedError{Class: class, RetryAfter: wait}
+	}
+	if wait := rl.addressLimiters[class].Take(remoteHost(r)); wait > 0 {
+		return &RateLimitedError{Class: class, RetryAfter: wait}
+	}
+	return nil
+}
+
+// Wrap rejects requests over the limit with a 429 and a Retry-After header.
+func (rl *RateLimiter) Wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := rl.Allow(r)
+		if err == nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		source := SourceOfRequest(r)
+		rateLimitedErr := err.(*RateLimitedError)
+		RequestLogger(r).Warnf("rate limiting %s request to %s from %s at %s", r.Method, r.URL.Path, source, r.RemoteAddr)
+		recordThrottledRequest(rl.throttled.Label(source), rateLimitedErr.Class)
+		retryAfter := int(math.Ceil(rateLimitedErr.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		WriteError(w, r, err, http.StatusTooManyRequests, false)
+	})
+}
@@ -0,0 +1,148 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunRateLimitTests() {
+	Describe("rate limiting", func() {
+		var now time.Time
+		var limiter *RateLimiter
+		okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+		})
+		serve := func(method string, path string, source string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest(method, path, nil)
+			if source != "" {
+				request.Header.Set(SourceHeader, source)
+			}
+			recorder := httptest.NewRecorder()
+			limiter.Wrap(okHandler).ServeHTTP(recorder, request)
+			return recorder
+		}
+		BeforeEach(func() {
+			now = time.Now()
+			limiter = NewRateLimiter(map[RouteClass]RateLimit{
+				RouteClassMutating: {RequestsPerSecond: 1, Burst: 2},
+				RouteClassScanner:  {RequestsPerSecond: 10, Burst: 10},
+			})
+			limiter.now = func() time.Time { return now }
+		})
+
+		It("classifies routes", func() {
+			Expect(RouteClassOfRequest(httptest.NewRequest("PUT", "/allpods", nil))).To(Equal(RouteClassMutating))
+			Expect(RouteClassOfRequest(httptest.NewRequest("DELETE", "/api/v1/pod", nil))).To(Equal(RouteClassMutating))
+			Expect(RouteClassOfRequest(httptest.NewRequest("GET", "/model", nil))).To(Equal(RouteClassRead))
+			Expect(RouteClassOfRequest(httptest.NewRequest("POST", "/nextimage", nil))).To(Equal(RouteClassScanner))
+			Expect(RouteClassOfRequest(httptest.NewRequest("POST", "/api/v1/finishedscan", nil))).To(Equal(RouteClassScanner))
+		})
+
+		It("allows a burst, then rejects with 429 and Retry-After", func() {
+			Expect(serve("PUT", "/allpods", "perceiver").Code).To(Equal(200))
+			Expect(serve("PUT", "/allpods", "perceiver").Code).To(Equal(200))
+			recorder := serve("PUT", "/allpods", "perceiver")
+			Expect(recorder.Code).To(Equal(429))
+			Expect(recorder.Header().Get("Retry-After")).To(Equal("1"))
+			var response ErrorResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(BeNil())
+			Expect(response.Code).To(Equal(ErrorCodeRateLimited))
+		})
+
+		It("refills at the sustained rate", func() {
+			serve("POST", "/pod", "perceiver")
+			serve("POST", "/pod", "perceiver")
+			Expect(serve("POST", "/pod", "perceiver").Code).To(Equal(429))
+			now = now.Add(time.Second)
+			Expect(serve("POST", "/pod", "perceiver").Code).To(Equal(200))
+			Expect(serve("POST", "/pod", "perceiver").Code).To(Equal(429))
+		})
+
+		It("limits each client separately", func() {
+			serve("POST", "/pod", "perceiver-1")
+			serve("POST", "/pod", "perceiver-1")
+			Expect(serve("POST", "/pod", "perceiver-1").Code).To(Equal(429))
+			Expect(serve("POST", "/pod", "perceiver-2").Code).To(Equal(200))
+			// without a source header, the remote address is used
+			Expect(serve("POST", "/pod", "").Code).To(Equal(200))
+		})
+
+		It("doesn't let a misbehaving perceiver throttle scanning or reads", func() {
+			for i := 0; i < 5; i++ {
+				serve("PUT", "/allpods", "shared-host")
+			}
+			Expect(serve("PUT", "/allpods", "shared-host").Code).To(Equal(429))
+			Expect(serve("POST", "/nextimage", "shared-host").Code).To(Equal(200))
+			Expect(serve("POST", "/finishedscan", "shared-host").Code).To(Equal(200))
+			// no limit was configured for reads
+			for i := 0; i < 20; i++ {
+				Expect(serve("GET", "/model", "shared-host").Code).To(Equal(200))
+			}
+		})
+
+		It("doesn't let a client escape its address's limit by naming new sources", func() {
+			allowed := 0
+			for i := 0; i < 3*rateLimiterSourcesPerAddress; i++ {
+				if serve("POST", "/pod", fmt.Sprintf("perceiver-%d", i)).Code == 200 {
+					allowed++
+				}
+			}
+			// each source has a burst of 2
+			Expect(allowed).To(Equal(2 * rateLimiterSourcesPerAddress))
+			request := httptest.NewRequest("POST", "/pod", nil)
+			request.RemoteAddr = "192.0.2.2:1234"
+			request.Header.Set(SourceHeader, "perceiver-0")
+			recorder := httptest.NewRecorder()
+			limiter.Wrap(okHandler).ServeHTTP(recorder, request)
+			Expect(recorder.Code).To(Equal(200))
+		})
+
+		It("caps the clients labeled in the throttled requests metric", func() {
+			for i := 0; i < DefaultMaxTrackedSources+10; i++ {
+				limiter.throttled.Label(fmt.Sprintf("perceiver-%d", i))
+			}
+			Expect(limiter.throttled.Label("perceiver-new")).To(Equal(UntrackedSources))
+			Expect(limiter.throttled.Len()).To(Equal(DefaultMaxTrackedSources + 1))
+			// idle clients make room for new ones
+			now = now.Add(throttledClientIdleTimeout + time.Second)
+			Expect(limiter.throttled.Label("perceiver-new")).To(Equal("perceiver-new"))
+		})
+
+		It("forgets idle clients", func() {
+			serve("POST", "/pod", "perceiver")
+			mutating := limiter.limiters[RouteClassMutating]
+			Expect(mutating.Len()).To(Equal(1))
+			now = now.Add(mutating.IdleTimeout() + time.Second)
+			serve("POST", "/pod", "other-perceiver")
+			// perceiver's bucket was dropped, leaving only other-perceiver's
+			Expect(mutating.Len()).To(Equal(1))
+		})
+	})
+}
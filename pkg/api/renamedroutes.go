@@ -0,0 +1,100 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RenamedRoute keeps a route reachable under its old path for a deprecation
+// window after it's been renamed, so that clients can move over one at a
+// time.  Requests to the old path are handled exactly as if they had used
+// the new one, and are marked with Deprecation and Sunset headers.
+// Middleware which looks routes up by name, such as authentication, goes
+// through canonicalRoute, so tables like alwaysAuthenticatedRoutes only list
+// the new name.
+type RenamedRoute struct {
+	Old string
+	New string
+	// Sunset is when the old path is due to stop being served
+	Sunset time.Time
+}
+
+// v1RenamedRoutes lists the routes of version 1 of the API which have been
+// renamed, and whose old paths are still served.
+var v1RenamedRoutes = []*RenamedRoute{}
+
+// withoutRenamedRoutes copies `versions`, leaving out the old paths in
+// `removed`; this lets old paths be turned off by configuration before
+// they're taken out of the code.
+func withoutRenamedRoutes(versions []*apiVersion, removed []string) []*apiVersion {
+	if len(removed) == 0 {
+		return versions
+	}
+	removedPaths := map[string]bool{}
+	for _, path := range removed {
+		removedPaths[path] = true
+	}
+	copied := []*apiVersion{}
+	for _, version := range versions {
+		kept := *version
+		kept.renamed = []*RenamedRoute{}
+		for _, renamed := range version.renamed {
+			if !removedPaths[renamed.Old] {
+				kept.renamed = append(kept.renamed, renamed)
+			}
+		}
+		copied = append(copied, &kept)
+	}
+	return copied
+}
+
+// renamedRouteHandler rewrites requests for the old path of `renamed` to the
+// new one, and marks their responses as deprecated.  Legacy, unversioned
+// requests get their Link header from versionHandler.
+func renamedRouteHandler(version string, renamed *RenamedRoute, legacy bool, handler http.Handler) http.Handler {
+	oldPrefix, newPrefix := VersionedPath(version, renamed.Old), VersionedPath(version, renamed.New)
+	if legacy {
+		oldPrefix, newPrefix = renamed.Old, renamed.New
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("Deprecation", "true")
+		header.Set("Sunset", renamed.Sunset.UTC().Format(http.TimeFormat))
+		if !legacy {
+			header.Add("Link", "<"+VersionedPath(version, renamed.New)+`>; rel="successor-version"`)
+		}
+		recordDeprecatedRouteRequest(renamed.Old, renamed.New, r.Method)
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = newPrefix + strings.TrimPrefix(r.URL.Path, oldPrefix)
+		if r.URL.RawPath != "" {
+			r2.URL.RawPath = newPrefix + strings.TrimPrefix(r.URL.RawPath, oldPrefix)
+		}
+		handler.ServeHTTP(w, r2)
+	})
+}
@@ -0,0 +1,138 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunRenamedRouteTests() {
+	Describe("renamed routes", func() {
+		sunset := time.Date(2027, time.March, 1, 0, 0, 0, 0, time.UTC)
+		versions := []*apiVersion{{name: "v1", handlers: v1Handlers, renamed: []*RenamedRoute{
+			{Old: "/dump", New: "/model", Sunset: sunset},
+			{Old: "/picture/", New: "/image/", Sunset: sunset},
+		}}}
+		var mux *http.ServeMux
+		mount := func(removed ...string) {
+			responder := NewMockResponder()
+			Expect(responder.AddImage(Image{Repository: "repo", Tag: "tag", Sha: "abc"})).To(BeNil())
+			mux = http.NewServeMux()
+			mountAPIVersions(mux, responder, withoutRenamedRoutes(versions, removed))
+		}
+		serve := func(path string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+			return recorder
+		}
+		deprecatedRequests := func(route string, successor string) float64 {
+			metric := &dto.Metric{}
+			counter := deprecatedRouteRequestCounter.With(prometheus.Labels{"route": route, "successor": successor, "method": "GET"})
+			Expect(counter.Write(metric)).To(BeNil())
+			return metric.GetCounter().GetValue()
+		}
+
+		It("serves the old path exactly like the new one, marking only the old one deprecated", func() {
+			mount()
+			for old, renamed := range map[string]string{
+				"/api/v1/dump":        "/api/v1/model",
+				"/api/v1/picture/abc": "/api/v1/image/abc",
+				"/api/v1/picture/xyz": "/api/v1/image/xyz",
+			} {
+				oldResponse, newResponse := serve(old), serve(renamed)
+				Expect(oldResponse.Code).To(Equal(newResponse.Code), old)
+				Expect(oldResponse.Body.String()).To(Equal(newResponse.Body.String()), old)
+				Expect(oldResponse.Header().Get("Content-Type")).To(Equal(newResponse.Header().Get("Content-Type")), old)
+				Expect(oldResponse.Header().Get("Deprecation")).To(Equal("true"), old)
+				Expect(oldResponse.Header().Get("Sunset")).To(Equal("Mon, 01 Mar 2027 00:00:00 GMT"), old)
+				Expect(oldResponse.Header()["Link"]).To(HaveLen(1), old)
+				Expect(newResponse.Header().Get("Deprecation")).To(Equal(""), renamed)
+				Expect(newResponse.Header().Get("Sunset")).To(Equal(""), renamed)
+				Expect(newResponse.Header().Get("Link")).To(Equal(""), renamed)
+			}
+			Expect(serve("/api/v1/dump").Header().Get("Link")).To(Equal(`</api/v1/model>; rel="successor-version"`))
+		})
+
+		It("serves the old legacy path, linking to the new versioned one", func() {
+			mount()
+			recorder := serve("/picture/abc")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(Equal(serve("/api/v1/image/abc").Body.String()))
+			Expect(recorder.Header().Get("Sunset")).To(Equal("Mon, 01 Mar 2027 00:00:00 GMT"))
+			Expect(recorder.Header()["Link"]).To(Equal([]string{`</api/v1/image/abc>; rel="successor-version"`}))
+		})
+
+		It("counts requests to old paths", func() {
+			mount()
+			before := deprecatedRequests("/dump", "/model")
+			serve("/api/v1/dump")
+			serve("/dump")
+			serve("/api/v1/model")
+			Expect(deprecatedRequests("/dump", "/model") - before).To(Equal(float64(2)))
+		})
+
+		It("stops serving old paths which have been removed", func() {
+			mount("/dump")
+			Expect(serve("/api/v1/dump").Code).To(Equal(http.StatusNotFound))
+			Expect(serve("/dump").Code).To(Equal(http.StatusNotFound))
+			Expect(serve("/api/v1/picture/abc").Code).To(Equal(http.StatusOK))
+			Expect(serve("/api/v1/model").Code).To(Equal(http.StatusOK))
+		})
+
+		It("applies auth and rate limit classes to old paths as to the new ones", func() {
+			saved := apiRoutes
+			defer func() { apiRoutes = saved }()
+			apiRoutes = routesOfVersions([]*apiVersion{{name: "v1", handlers: v1Handlers, renamed: []*RenamedRoute{
+				{Old: "/hubs", New: "/sethubs", Sunset: sunset},
+				{Old: "/next", New: "/nextimage", Sunset: sunset},
+				{Old: "/picture/", New: "/image/", Sunset: sunset},
+			}}})
+			okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(200)
+			})
+			ta := NewTokenAuthenticator([]string{"abc"}, []string{"/hubs", "/api/v1/hubs"})
+			for _, path := range []string{"/hubs", "/api/v1/hubs"} {
+				request := httptest.NewRequest("POST", path, nil)
+				recorder := httptest.NewRecorder()
+				ta.Wrap(okHandler).ServeHTTP(recorder, request)
+				Expect(recorder.Code).To(Equal(http.StatusUnauthorized), path)
+			}
+			Expect(RouteClassOfRequest(httptest.NewRequest("POST", "/api/v1/next", nil))).To(Equal(RouteClassScanner))
+			Expect(canonicalRoute("/api/v1/picture/abc")).To(Equal("/image/abc"))
+			Expect(canonicalRoute("/api/v1/image/abc")).To(Equal("/image/abc"))
+		})
+
+		It("must rename to a route with a handler", func() {
+			Expect(func() {
+				mountAPIVersions(http.NewServeMux(), NewMockResponder(), []*apiVersion{{name: "v1", handlers: v1Handlers, renamed: []*RenamedRoute{{Old: "/a", New: "/b"}}}})
+			}).To(Panic())
+		})
+	})
+}
@@ -0,0 +1,194 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader carries the ID of a request, so that a client's logs can
+// be matched up with perceptor's.  Clients may choose the ID; otherwise, one
+// is generated.
+const RequestIDHeader = "X-Request-ID"
+
+// DefaultNextImageLogSampleRate is how many successful /nextimage requests
+// there are per logged one, by default; scanners poll it constantly.
+const DefaultNextImageLogSampleRate = 100
+
+var requestIDRegexp = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// routeTemplates names the routes which match more than one path.
+var routeTemplates = map[string]string{
+	"/image/": "/image/{sha}",
+	"/hub/":   "/hub/{host}",
+}
+
+// requestInfo is filled in as a request makes its way through the handlers.
+type requestInfo struct {
+	id    string
+	route string
+	// identity is set by the TokenAuthenticator
+	identity string
+	// errorCode is set when an error response is written
+	errorCode ErrorCode
+	// legacyRoute is the route of a payload which used legacy field names
+	legacyRoute string
+}
+
+type requestInfoContextKey struct{}
+
+func requestInfoOfRequest(r *http.Request) *requestInfo {
+	info, _ := r.Context().Value(requestInfoContextKey{}).(*requestInfo)
+	return info
+}
+
+// RequestIDOfRequest returns the ID of a request, or "" if it didn't go
+// through a RequestLogHandler.
+func RequestIDOfRequest(r *http.Request) string {
+	if info := requestInfoOfRequest(r); info != nil {
+		return info.id
+	}
+	return ""
+}
+
+// RequestLogger returns a logger which tags every line with the request's ID.
+func RequestLogger(r *http.Request) *log.Entry {
+	if id := RequestIDOfRequest(r); id != "" {
+		return log.WithField("requestID", id)
+	}
+	return log.NewEntry(log.StandardLogger())
+}
+
+func setRouteTemplate(r *http.Request, route string) {
+	if info := requestInfoOfRequest(r); info != nil {
+		if template, ok := routeTemplates[route]; ok {
+			route = template
+		}
+		info.route = route
+	}
+}
+
+func newRequestID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(bytes)
+}
+
+// statusRecorder remembers the status and size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	bytes      int
+}
+
+func (sr *statusRecorder) WriteHeader(statusCode int) {
+	if sr.statusCode == 0 {
+		sr.statusCode = statusCode
+	}
+	sr.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sr *statusRecorder) Write(data []byte) (int, error) {
+	if sr.statusCode == 0 {
+		sr.statusCode = http.StatusOK
+	}
+	n, err := sr.ResponseWriter.Write(data)
+	sr.bytes += n
+	return n, err
+}
+
+func (sr *statusRecorder) Flush() {
+	if flusher, ok := sr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// RequestLogHandler gives every request an ID, which is returned in the
+// response headers, and logs one line per request.  Successful requests to
+// the routes in `sampleRates` are only logged one time in N; errors always are.
+func RequestLogHandler(handler http.Handler, sampleRates map[string]int) http.Handler {
+	counters := map[string]*uint64{}
+	for route := range sampleRates {
+		counters[route] = new(uint64)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(RequestIDHeader)
+		if !requestIDRegexp.MatchString(id) {
+			id = newRequestID()
+		}
+		// share the info of a RouteMetricsHandler outside this one
+		info := requestInfoOfRequest(r)
+		if info == nil {
+			info = &requestInfo{}
+			r = r.WithContext(context.WithValue(r.Context(), requestInfoContextKey{}, info))
+		}
+		info.id = id
+		w.Header().Set(RequestIDHeader, id)
+		recorder := &statusRecorder{ResponseWriter: w}
+		handler.ServeHTTP(recorder, r)
+
+		status := recorder.statusCode
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := info.route
+		if route == "" {
+			// rejected before reaching a route, e.g. by the auth middleware
+			_, route = UnversionedPath(r.URL.Path)
+		}
+		if counter, ok := counters[route]; ok && status < 400 {
+			if (atomic.AddUint64(counter, 1)-1)%uint64(sampleRates[route]) != 0 {
+				return
+			}
+		}
+		entry := log.WithFields(log.Fields{
+			"requestID":  id,
+			"method":     r.Method,
+			"route":      route,
+			"status":     status,
+			"bytes":      recorder.bytes,
+			"durationMs": float64(time.Since(start)) / float64(time.Millisecond),
+			"source":     SourceOfRequest(r),
+		})
+		if status >= 500 {
+			entry.Warn("handled request")
+		} else {
+			entry.Info("handled request")
+		}
+	})
+}
@@ -0,0 +1,142 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+)
+
+// entryRecorder is a logrus hook which keeps every entry.
+type entryRecorder struct {
+	mutex   sync.Mutex
+	entries []*log.Entry
+}
+
+func (er *entryRecorder) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (er *entryRecorder) Fire(entry *log.Entry) error {
+	er.mutex.Lock()
+	defer er.mutex.Unlock()
+	er.entries = append(er.entries, entry)
+	return nil
+}
+
+func (er *entryRecorder) withMessage(message string) []*log.Entry {
+	er.mutex.Lock()
+	defer er.mutex.Unlock()
+	entries := []*log.Entry{}
+	for _, entry := range er.entries {
+		if entry.Message == message {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func RunRequestLogTests() {
+	Describe("RequestLogHandler", func() {
+		var recorder *entryRecorder
+		var savedHooks log.LevelHooks
+		var handler http.Handler
+		BeforeEach(func() {
+			recorder = &entryRecorder{}
+			savedHooks = log.StandardLogger().Hooks
+			log.StandardLogger().Hooks = log.LevelHooks{}
+			log.AddHook(recorder)
+			mux := http.NewServeMux()
+			setupHandlers(mux, NewMockResponder())
+			handler = RequestLogHandler(mux, map[string]int{"/nextimage": 3})
+		})
+		AfterEach(func() {
+			log.StandardLogger().Hooks = savedHooks
+		})
+		serve := func(method string, path string, requestID string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest(method, path, nil)
+			request.Header.Set(SourceHeader, "perceiver-1")
+			if requestID != "" {
+				request.Header.Set(RequestIDHeader, requestID)
+			}
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, request)
+			return response
+		}
+
+		It("propagates a client's request ID", func() {
+			response := serve("GET", "/api/v1/model", "abc-123")
+			Expect(response.Header().Get(RequestIDHeader)).To(Equal("abc-123"))
+			entries := recorder.withMessage("handled request")
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Data).To(HaveKeyWithValue("requestID", "abc-123"))
+			Expect(entries[0].Data).To(HaveKeyWithValue("method", "GET"))
+			Expect(entries[0].Data).To(HaveKeyWithValue("route", "/model"))
+			Expect(entries[0].Data).To(HaveKeyWithValue("status", 200))
+			Expect(entries[0].Data).To(HaveKeyWithValue("source", "perceiver-1"))
+			Expect(entries[0].Data).To(HaveKey("durationMs"))
+		})
+
+		It("generates IDs for requests without a valid one", func() {
+			first := serve("GET", "/model", "").Header().Get(RequestIDHeader)
+			second := serve("GET", "/model", "bad id\n").Header().Get(RequestIDHeader)
+			Expect(first).To(HaveLen(32))
+			Expect(second).To(HaveLen(32))
+			Expect(first).NotTo(Equal(second))
+		})
+
+		It("logs route templates, not paths", func() {
+			serve("GET", "/api/v1/image/abc", "")
+			entries := recorder.withMessage("handled request")
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Data).To(HaveKeyWithValue("route", "/image/{sha}"))
+			Expect(entries[0].Data).To(HaveKeyWithValue("status", 404))
+		})
+
+		It("includes the request ID in error responses and error logs", func() {
+			response := serve("POST", "/pod", "req-1")
+			Expect(response.Code).To(Equal(400))
+			var errorResponse ErrorResponse
+			Expect(json.Unmarshal(response.Body.Bytes(), &errorResponse)).To(BeNil())
+			Expect(errorResponse.RequestID).To(Equal("req-1"))
+			recorder.mutex.Lock()
+			defer recorder.mutex.Unlock()
+			Expect(len(recorder.entries)).To(BeNumerically(">", 1))
+			for _, entry := range recorder.entries {
+				Expect(entry.Data).To(HaveKeyWithValue("requestID", "req-1"))
+			}
+		})
+
+		It("samples successful next image requests", func() {
+			for i := 0; i < 7; i++ {
+				serve("POST", "/nextimage", "")
+			}
+			Expect(recorder.withMessage("handled request")).To(HaveLen(3))
+		})
+	})
+}
@@ -22,29 +22,63 @@ under the License.
 package api
 
 import (
+	"context"
 	"net/http"
 )
 
 // Responder .....
 type Responder interface {
 	GetModel() Model
+	GetImage(shaPrefix string) (*ImageDetail, error)
+	RequeueImage(shaPrefix string, source string) (*ImageRequeueResult, error)
+	GetConfig() *RuntimeConfig
 
 	// perceiver
 	AddPod(pod Pod) error
-	UpdatePod(pod Pod) error
-	DeletePod(qualifiedName string)
-	GetScanResults() ScanResults
+	UpdatePod(pod Pod) (*PodUpsertResult, error)
+	DeletePod(qualifiedName string) (deferred bool, err error)
+	DeletePods(query *PodsDeletionQuery) (*PodsDeletion, error)
+	GetScanResults(query *ScanResultsQuery) ScanResults
+	GetPods(query *PodsQuery) (*PodList, error)
+	GetPod(namespace string, name string) (*PodDetail, error)
+	SubscribeScanResults(lastEventID string) (*ScanResultsSubscription, error)
+	// ExportFindings passes findings to `emit` one at a time, stopping at
+	// the first error.
+	ExportFindings(query *FindingsExportQuery, emit func(*Finding) error) error
 	AddImage(image Image) error
+	GetImagesByName(repository string, tag string) (*ImagesByName, error)
+	AddImages(images []Image, acceptValid bool) (*BulkImagesResult, error)
 	UpdateAllPods(allPods AllPods) error
 	UpdateAllImages(allImages AllImages) error
 
 	// scanner
-	GetNextImage() NextImage
+	GetNextImage(ctx context.Context, query *NextImageQuery) NextImage
 	PostFinishScan(job FinishedScanClientJob) error
+	AcknowledgeScanLease(ack ScanLeaseAcknowledgement) error
+	SetConcurrentScanLimit(limit SetConcurrentScanLimit) error
+	GetConcurrentScanLimit() ConcurrentScanLimit
+	GetScanQueueStats() *ScanQueueStats
+
+	// hubs
+	SetHubs(request *SetHubsRequest) (*SetHubsResult, error)
+	LoginHub(host string) (*HubLoginResult, error)
+	GetHubCircuitBreaker(host string) (*ModelCircuitBreaker, error)
+	ResetHubCircuitBreaker(host string, source string, requestID string) (*CircuitBreakerReset, error)
 
 	// internal use
 	PostCommand(commands *PostCommand)
 
+	// metrics
+	GetMetricsSummary() (*MetricsSummary, error)
+
+	// audit
+	GetAuditLog(query *AuditQuery) (*AuditPage, error)
+	GetSourceStats() (*SourceStatsReport, error)
+
+	// health
+	GetLiveness() *Health
+	GetReadiness(query *ReadinessQuery) *Health
+
 	// errors
 	NotFound(w http.ResponseWriter, r *http.Request)
 	Error(w http.ResponseWriter, r *http.Request, err error, statusCode int)
@@ -0,0 +1,143 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// .....
+const (
+	ContentTypeJSON = "application/json"
+	ContentTypeYAML = "application/x-yaml"
+)
+
+// yamlMediaTypes are the Accept header media types which ask for YAML.
+var yamlMediaTypes = map[string]bool{
+	ContentTypeYAML:    true,
+	"application/yaml": true,
+	"text/yaml":        true,
+}
+
+// wantsYAML is true for requests with `?format=yaml`, or which accept YAML;
+// `?format=json` overrides the Accept header.
+func wantsYAML(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "yaml"
+	}
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && yamlMediaTypes[mediaType] {
+			return true
+		}
+	}
+	return false
+}
+
+// writeResponse writes `value` as indented JSON, or as YAML for requests
+// which ask for it.
+func writeResponse(w http.ResponseWriter, r *http.Request, responder Responder, value interface{}) {
+	contentType := ContentTypeJSON
+	var body []byte
+	var err error
+	if wantsYAML(r) {
+		contentType = ContentTypeYAML
+		body, err = marshalYAML(value)
+	} else {
+		body, err = json.MarshalIndent(value, "", "  ")
+	}
+	if err != nil {
+		responder.Error(w, r, err, 500)
+		return
+	}
+	header := w.Header()
+	header.Set(http.CanonicalHeaderKey("content-type"), contentType)
+	fmt.Fprint(w, string(body))
+}
+
+// marshalYAML goes by way of JSON, so that the YAML has the same field names,
+// field order and custom encodings (such as ModelTime) as the JSON does.
+func marshalYAML(value interface{}) ([]byte, error) {
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	decoder.UseNumber()
+	yamlValue, err := decodeYAMLValue(decoder)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(yamlValue)
+}
+
+// decodeYAMLValue reads the next JSON value from `decoder`, turning objects
+// into yaml.MapSlices to preserve the order of their keys.
+func decodeYAMLValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		if t == '{' {
+			object := yaml.MapSlice{}
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeYAMLValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+				object = append(object, yaml.MapItem{Key: key, Value: value})
+			}
+			_, err = decoder.Token()
+			return object, err
+		}
+		array := []interface{}{}
+		for decoder.More() {
+			value, err := decodeYAMLValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err = decoder.Token()
+		return array, err
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		return t.Float64()
+	default:
+		return token, nil
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// fixtureModelResponder serves a model with a bit of everything in it.
+type fixtureModelResponder struct {
+	*MockResponder
+}
+
+func (fr *fixtureModelResponder) GetModel() Model {
+	nextCheckTime := time.Date(2018, 6, 1, 12, 30, 0, 0, time.UTC)
+	return Model{
+		Hubs: map[string]*ModelHub{
+			"hub1": {
+				Host:   "hub1",
+				Status: "ClientStatusUp",
+				Errors: []string{},
+				CircuitBreaker: &ModelCircuitBreaker{
+					State:              "CircuitBreakerStateDisabled",
+					NextCheckTime:      &nextCheckTime,
+					MaxBackoffDuration: *NewModelTime(time.Hour),
+				},
+			},
+		},
+		CoreModel: &CoreModel{
+			Pods: map[string]*Pod{
+				"ns1/pod1": {Name: "pod1", Namespace: "ns1", Labels: map[string]string{"app": "web"}},
+			},
+			Images: map[string]*ModelImageInfo{
+				"abc": {ScanStatus: "Complete", ImageSha: "abc", Priority: 3, RepoTags: []*ModelRepoTag{{Repository: "nginx", Tag: "1.15"}}},
+			},
+			ImageScanQueue:      []map[string]interface{}{{"Key": "abc", "Priority": 3}},
+			NamespaceScanLimits: map[string]int{"ns1": 2},
+			Generation:          12345678901,
+		},
+		Scheduler: &ModelScanScheduler{ConcurrentScanLimit: 2, TotalScanLimit: 5},
+	}
+}
+
+// jsonCompatible turns what yaml.v2 decodes into what encoding/json would
+// have decoded from the same document.
+func jsonCompatible(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		object := map[string]interface{}{}
+		for key, val := range v {
+			object[fmt.Sprintf("%v", key)] = jsonCompatible(val)
+		}
+		return object
+	case []interface{}:
+		array := make([]interface{}, len(v))
+		for i, val := range v {
+			array[i] = jsonCompatible(val)
+		}
+		return array
+	case int:
+		return float64(v)
+	default:
+		return value
+	}
+}
+
+func RunResponseTests() {
+	Describe("YAML responses", func() {
+		get := func(path string, accept string) *httptest.ResponseRecorder {
+			mux := http.NewServeMux()
+			setupHandlers(mux, &fixtureModelResponder{MockResponder: NewMockResponder()})
+			request := httptest.NewRequest("GET", path, nil)
+			if accept != "" {
+				request.Header.Set("Accept", accept)
+			}
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, request)
+			return recorder
+		}
+		decodeJSON := func(recorder *httptest.ResponseRecorder) interface{} {
+			Expect(recorder.Header().Get("Content-Type")).To(Equal(ContentTypeJSON))
+			var value interface{}
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &value)).To(BeNil())
+			return value
+		}
+		decodeYAML := func(recorder *httptest.ResponseRecorder) interface{} {
+			Expect(recorder.Header().Get("Content-Type")).To(Equal(ContentTypeYAML))
+			var value interface{}
+			Expect(yaml.Unmarshal(recorder.Body.Bytes(), &value)).To(BeNil())
+			return jsonCompatible(value)
+		}
+
+		It("keeps JSON as the default", func() {
+			recorder := get("/api/v1/model", "")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			decodeJSON(recorder)
+			decodeJSON(get("/api/v1/model", "text/html, */*"))
+		})
+
+		It("sends the same content as YAML, when asked by the Accept header", func() {
+			expected := decodeJSON(get("/api/v1/model", ""))
+			Expect(decodeYAML(get("/api/v1/model", "application/x-yaml"))).To(Equal(expected))
+			Expect(decodeYAML(get("/api/v1/model", "application/json;q=0.5, application/yaml"))).To(Equal(expected))
+		})
+
+		It("sends YAML for ?format=yaml, which overrides the Accept header", func() {
+			expected := decodeJSON(get("/api/v1/model", ""))
+			Expect(decodeYAML(get("/api/v1/model?format=yaml", ""))).To(Equal(expected))
+			decodeJSON(get("/api/v1/model?format=json", "application/x-yaml"))
+		})
+
+		It("keeps JSON's field names and order", func() {
+			body := get("/api/v1/model?format=yaml", "").Body.String()
+			Expect(body).To(HavePrefix("Hubs:\n"))
+			Expect(body).To(ContainSubstring("MaxBackoffDuration:\n"))
+			Expect(body).To(ContainSubstring("Generation: 12345678901\n"))
+			Expect(body).To(ContainSubstring("State: CircuitBreakerStateDisabled\n      NextCheckTime: \"2018-06-01T12:30:00Z\"\n"))
+		})
+
+		It("applies to the other read-only routes", func() {
+			for _, path := range []string{"/api/v1/config", "/api/v1/pods", "/api/v1/scanresults"} {
+				expected := decodeJSON(get(path, ""))
+				Expect(decodeYAML(get(path+"?format=yaml", ""))).To(Equal(expected))
+			}
+		})
+	})
+}
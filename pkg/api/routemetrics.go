@@ -0,0 +1,103 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RouteMetricsHandler counts and times every request, labeling them with the
+// template of their route rather than the raw path, to keep the number of
+// label values bounded.  It must be the outermost handler, so that requests
+// rejected by other middleware, e.g. for auth or rate limits, are counted.
+func RouteMetricsHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		template := routeOfPath(r.URL.Path)
+		inFlight := routeRequestsInFlight.With(prometheus.Labels{"route": template})
+		inFlight.Inc()
+		defer inFlight.Dec()
+		info := requestInfoOfRequest(r)
+		if info == nil {
+			// the handlers may still refine the route
+			info = &requestInfo{}
+			r = r.WithContext(context.WithValue(r.Context(), requestInfoContextKey{}, info))
+		}
+		recorder := &statusRecorder{ResponseWriter: w}
+		handler.ServeHTTP(recorder, r)
+		finalRoute := info.route
+		if finalRoute == "" {
+			finalRoute = template
+		}
+		status := recorder.statusCode
+		if status == 0 {
+			status = http.StatusOK
+		}
+		recordRouteRequest(finalRoute, r.Method, status, time.Since(start))
+	})
+}
+
+func statusClass(status int) string {
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+var routeRequestCounter *prometheus.CounterVec
+var routeRequestDuration *prometheus.HistogramVec
+var routeRequestsInFlight *prometheus.GaugeVec
+
+func recordRouteRequest(route string, method string, status int, duration time.Duration) {
+	labels := prometheus.Labels{"route": route, "method": method, "status": statusClass(status)}
+	routeRequestCounter.With(labels).Inc()
+	routeRequestDuration.With(labels).Observe(duration.Seconds())
+}
+
+func setupRouteMetrics() {
+	routeRequestCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "api",
+		Name:      "route_requests",
+		Help:      "HTTP requests by route template, method and status class",
+	}, []string{"route", "method", "status"})
+	metricsRegistry.Register(routeRequestCounter)
+
+	routeRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "api",
+		Name:      "route_request_duration_seconds",
+		Help:      "HTTP request latency by route template, method and status class",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+	metricsRegistry.Register(routeRequestDuration)
+
+	routeRequestsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "api",
+		Name:      "route_requests_in_flight",
+		Help:      "HTTP requests currently being handled, by route template",
+	}, []string{"route"})
+	metricsRegistry.Register(routeRequestsInFlight)
+}
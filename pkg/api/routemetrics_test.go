@@ -0,0 +1,145 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// gatheredMetric finds the metric of the family `name` with exactly `labels`.
+func gatheredMetric(name string, labels map[string]string) *dto.Metric {
+	families, err := prometheus.DefaultGatherer.Gather()
+	Expect(err).To(BeNil())
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			found := map[string]string{}
+			for _, pair := range metric.GetLabel() {
+				found[pair.GetName()] = pair.GetValue()
+			}
+			if len(found) == len(labels) {
+				matches := true
+				for key, value := range labels {
+					if found[key] != value {
+						matches = false
+					}
+				}
+				if matches {
+					return metric
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func routeRequestCount(labels map[string]string) float64 {
+	if metric := gatheredMetric("perceptor_api_route_requests", labels); metric != nil {
+		return metric.GetCounter().GetValue()
+	}
+	return 0
+}
+
+func routeRequestLatencyCount(labels map[string]string) uint64 {
+	if metric := gatheredMetric("perceptor_api_route_request_duration_seconds", labels); metric != nil {
+		return metric.GetHistogram().GetSampleCount()
+	}
+	return 0
+}
+
+func RunRouteMetricsTests() {
+	Describe("per-route metrics", func() {
+		serve := func(method string, path string) int {
+			mux := http.NewServeMux()
+			setupHandlers(mux, NewMockResponder())
+			recorder := httptest.NewRecorder()
+			RouteMetricsHandler(mux).ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+			return recorder.Code
+		}
+
+		It("labels requests by route template, method and status class", func() {
+			model := map[string]string{"route": "/model", "method": "GET", "status": "2xx"}
+			image := map[string]string{"route": "/image/{sha}", "method": "GET", "status": "4xx"}
+			hubLogin := map[string]string{"route": "/hub/{host}/login", "method": "POST", "status": "4xx"}
+			modelCount, imageCount, hubLoginCount := routeRequestCount(model), routeRequestCount(image), routeRequestCount(hubLogin)
+			modelLatencies := routeRequestLatencyCount(model)
+
+			Expect(serve("GET", "/api/v1/model")).To(Equal(200))
+			Expect(serve("GET", "/model")).To(Equal(200))
+			Expect(serve("GET", "/api/v1/image/abc")).To(Equal(404))
+			Expect(serve("GET", "/image/def")).To(Equal(404))
+			Expect(serve("POST", "/api/v1/hub/hub1/login")).To(Equal(404))
+
+			Expect(routeRequestCount(model)).To(Equal(modelCount + 2))
+			Expect(routeRequestLatencyCount(model)).To(Equal(modelLatencies + 2))
+			Expect(routeRequestCount(image)).To(Equal(imageCount + 2))
+			Expect(routeRequestCount(hubLogin)).To(Equal(hubLoginCount + 1))
+			Expect(gatheredMetric("perceptor_api_route_requests", map[string]string{"route": "/image/abc", "method": "GET", "status": "4xx"})).To(BeNil())
+		})
+
+		It("counts requests rejected by the middleware under their routes", func() {
+			mux := http.NewServeMux()
+			setupHandlers(mux, NewMockResponder())
+			limiter := NewRateLimiter(map[RouteClass]RateLimit{RouteClassRead: {RequestsPerSecond: 0, Burst: 1}})
+			authenticator := NewTokenAuthenticator([]string{"abc"}, []string{})
+			handler := RouteMetricsHandler(RequestLogHandler(limiter.Wrap(authenticator.Wrap(mux)), nil))
+			serveWith := func(path string, token string) int {
+				request := httptest.NewRequest("GET", path, nil)
+				SetAuthToken(request, token)
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, request)
+				return recorder.Code
+			}
+			rejected := map[string]string{"route": "/model", "method": "GET", "status": "4xx"}
+			others := map[string]string{"route": otherRoute, "method": "GET", "status": "4xx"}
+			rejectedCount, othersCount := routeRequestCount(rejected), routeRequestCount(others)
+
+			Expect(serveWith("/api/v1/model", "")).To(Equal(401))
+			Expect(serveWith("/api/v1/model", "abc")).To(Equal(429))
+			Expect(serveWith("/wp-login.php", "abc")).To(Equal(429))
+			Expect(routeRequestCount(rejected)).To(Equal(rejectedCount + 2))
+			Expect(routeRequestCount(others)).To(Equal(othersCount + 1))
+		})
+
+		It("tracks requests in flight", func() {
+			Expect(serve("GET", "/api/v1/model")).To(Equal(200))
+			metric := gatheredMetric("perceptor_api_route_requests_in_flight", map[string]string{"route": "/model"})
+			Expect(metric).NotTo(BeNil())
+			Expect(metric.GetGauge().GetValue()).To(Equal(float64(0)))
+		})
+
+		It("groups statuses into classes", func() {
+			Expect(statusClass(200)).To(Equal("2xx"))
+			Expect(statusClass(204)).To(Equal("2xx"))
+			Expect(statusClass(429)).To(Equal("4xx"))
+			Expect(statusClass(503)).To(Equal("5xx"))
+		})
+	})
+}
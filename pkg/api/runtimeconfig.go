@@ -0,0 +1,67 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import "time"
+
+// RuntimeConfig is the configuration perceptor is actually running with: the
+// loaded configuration, with defaults filled in and secrets left out, plus
+// the settings which have been changed since.
+type RuntimeConfig struct {
+	Hub      *RuntimeHubConfig
+	Timings  *ModelTimings
+	Port     int
+	LogLevel string
+	// ListenAddress is empty if the API listens on every interface
+	ListenAddress string
+	// RateLimits is keyed by route class, and is empty if rate limiting is off
+	RateLimits map[string]RateLimit
+	// CompressionMinSizeBytes is 0 if compression is off
+	CompressionMinSizeBytes int
+	CORS                    *CORSPolicy
+	UnauthenticatedPaths    []string
+	ShutdownDrain           *ModelTime
+	// Features lists which optional behaviors are turned on
+	Features map[string]bool
+	// Settings are the values which can change at runtime, keyed by name
+	Settings map[string]*RuntimeSetting
+}
+
+// RuntimeHubConfig .....
+type RuntimeHubConfig struct {
+	Hosts                         []string
+	User                          string
+	PasswordEnvVar                string
+	Port                          int
+	ConcurrentScanLimit           int
+	TotalScanLimit                int
+	NamespaceConcurrentScanLimits map[string]int
+}
+
+// RuntimeSetting is the current value of a setting, and when and how it was
+// last changed; Source is "config" for values which haven't changed since
+// startup, "config reload" or "api".
+type RuntimeSetting struct {
+	Value     interface{}
+	ChangedAt time.Time
+	Source    string
+}
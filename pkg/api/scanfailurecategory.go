@@ -0,0 +1,63 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+// Scan clients may classify a failed job with one of these categories.
+// Retrying a scan which failed for a terminal reason is pointless, so the
+// image isn't requeued until someone requeues it through the API.
+const (
+	ScanFailureCategoryPullFailed       = "PullFailed"
+	ScanFailureCategoryDiskFull         = "DiskFull"
+	ScanFailureCategoryCliCrashed       = "CliCrashed"
+	ScanFailureCategoryUploadFailed     = "UploadFailed"
+	ScanFailureCategoryUnauthorized     = "Unauthorized"
+	ScanFailureCategoryImageUnsupported = "ImageUnsupported"
+	// the pulled image isn't the one whose sha was handed out
+	ScanFailureCategoryDigestMismatch = "DigestMismatch"
+	// the image is larger than the scanner is configured to scan
+	ScanFailureCategoryImageTooLarge = "ImageTooLarge"
+)
+
+// scanFailureCategories maps each known category to whether it's terminal.
+var scanFailureCategories = map[string]bool{
+	ScanFailureCategoryPullFailed:       false,
+	ScanFailureCategoryDiskFull:         false,
+	ScanFailureCategoryCliCrashed:       false,
+	ScanFailureCategoryUploadFailed:     false,
+	ScanFailureCategoryUnauthorized:     true,
+	ScanFailureCategoryImageUnsupported: true,
+	ScanFailureCategoryDigestMismatch:   true,
+	ScanFailureCategoryImageTooLarge:    true,
+}
+
+// IsKnownScanFailureCategory .....
+func IsKnownScanFailureCategory(category string) bool {
+	_, ok := scanFailureCategories[category]
+	return ok
+}
+
+// IsTerminalScanFailureCategory returns whether a scan which failed with
+// `category` shouldn't be retried.  Uncategorized failures, and categories
+// from newer scan clients, are retryable.
+func IsTerminalScanFailureCategory(category string) bool {
+	return scanFailureCategories[category]
+}
@@ -0,0 +1,56 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import "fmt"
+
+// ScanLeaseAcknowledgement is sent by a scan client once it has received an
+// image, before the lease in its ImageSpec expires.
+type ScanLeaseAcknowledgement struct {
+	Sha     string
+	LeaseID string
+	// Scanner optionally identifies the scan client
+	Scanner string
+}
+
+// Reasons for rejecting a scan lease.
+const (
+	// ScanLeaseReasonUnknown means the image isn't leased to any scan client:
+	// its lease expired and it was requeued, or its scan was already finished
+	ScanLeaseReasonUnknown = "unknown"
+	// ScanLeaseReasonStale means the image has been leased to another scan client since
+	ScanLeaseReasonStale = "stale"
+	// ScanLeaseReasonExpired means the lease wasn't acknowledged in time
+	ScanLeaseReasonExpired = "expired"
+)
+
+// ScanLeaseError is returned to a scan client presenting a lease which it no
+// longer holds, so that it knows its work is a duplicate and can be dropped.
+type ScanLeaseError struct {
+	Sha     string
+	LeaseID string
+	Reason  string
+}
+
+func (err *ScanLeaseError) Error() string {
+	return fmt.Sprintf("lease %q of image %s is %s", err.LeaseID, err.Sha, err.Reason)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import "time"
+
+// ScanQueueStatsWindow is how far back the dispatch rate and wait time look.
+const ScanQueueStatsWindow = time.Hour
+
+// ModelScanQueue is the raw material of scan queue statistics.
+type ModelScanQueue struct {
+	Entries []*ModelScanQueueEntry
+	// Dispatches are the most recent images handed out to scan clients,
+	// oldest first
+	Dispatches []*ModelScanDispatch
+}
+
+// ModelScanQueueEntry is an image waiting in the scan queue.
+type ModelScanQueueEntry struct {
+	Sha      string
+	Priority int
+	QueuedAt time.Time
+}
+
+// ModelScanDispatch is an image which was handed out to a scan client.
+type ModelScanDispatch struct {
+	Sha          string
+	QueuedAt     time.Time
+	DispatchedAt time.Time
+}
+
+// ScanQueueStats summarizes the scan queue for capacity planning.
+type ScanQueueStats struct {
+	Depth int
+	// DepthByPriority counts the queued images of each priority
+	DepthByPriority map[int]int
+	OldestQueuedSha string     `json:",omitempty"`
+	OldestQueuedAge *ModelTime `json:",omitempty"`
+	// Window is the period that Dispatches, DispatchesPerMinute and
+	// AverageWait cover, ending now
+	Window              *ModelTime
+	Dispatches          int
+	DispatchesPerMinute float64
+	AverageWait         *ModelTime `json:",omitempty"`
+	ConcurrentScanLimit int
+	InProgressScans     int
+	Generation          int64
+}
+
+// NewScanQueueStats computes the statistics as of `now`, in time linear in
+// the length of the queue and of the dispatch history.
+func NewScanQueueStats(coreModel *CoreModel, limit ConcurrentScanLimit, now time.Time) *ScanQueueStats {
+	stats := &ScanQueueStats{
+		DepthByPriority:     map[int]int{},
+		Window:              NewModelTime(ScanQueueStatsWindow),
+		ConcurrentScanLimit: limit.Limit,
+		InProgressScans:     limit.InProgressScans,
+		Generation:          coreModel.Generation,
+	}
+	if coreModel.ScanQueue == nil {
+		return stats
+	}
+	var oldest *ModelScanQueueEntry
+	for _, entry := range coreModel.ScanQueue.Entries {
+		stats.Depth++
+		stats.DepthByPriority[entry.Priority]++
+		if oldest == nil || entry.QueuedAt.Before(oldest.QueuedAt) {
+			oldest = entry
+		}
+	}
+	if oldest != nil {
+		stats.OldestQueuedSha = oldest.Sha
+		stats.OldestQueuedAge = NewModelTime(now.Sub(oldest.QueuedAt))
+	}
+	windowStart := now.Add(-ScanQueueStatsWindow)
+	totalWait := time.Duration(0)
+	for _, dispatch := range coreModel.ScanQueue.Dispatches {
+		if dispatch.DispatchedAt.Before(windowStart) {
+			continue
+		}
+		stats.Dispatches++
+		totalWait += dispatch.DispatchedAt.Sub(dispatch.QueuedAt)
+	}
+	stats.DispatchesPerMinute = float64(stats.Dispatches) / ScanQueueStatsWindow.Minutes()
+	if stats.Dispatches > 0 {
+		stats.AverageWait = NewModelTime(totalWait / time.Duration(stats.Dispatches))
+	}
+	return stats
+}
@@ -0,0 +1,69 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunScanQueueStatsTests() {
+	Describe("scan queue stats", func() {
+		now := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+		ago := func(d time.Duration) time.Time { return now.Add(-d) }
+
+		It("handles an empty model", func() {
+			stats := NewScanQueueStats(&CoreModel{Generation: 4}, ConcurrentScanLimit{Limit: 2}, now)
+			Expect(stats.Depth).To(Equal(0))
+			Expect(stats.OldestQueuedAge).To(BeNil())
+			Expect(stats.AverageWait).To(BeNil())
+			Expect(stats.ConcurrentScanLimit).To(Equal(2))
+			Expect(stats.Generation).To(Equal(int64(4)))
+		})
+
+		It("summarizes the queue and the last hour's dispatches", func() {
+			coreModel := &CoreModel{ScanQueue: &ModelScanQueue{
+				Entries: []*ModelScanQueueEntry{
+					{Sha: "a", Priority: 1, QueuedAt: ago(time.Minute)},
+					{Sha: "b", Priority: 1, QueuedAt: ago(10 * time.Minute)},
+					{Sha: "c", Priority: -1, QueuedAt: ago(5 * time.Minute)},
+				},
+				Dispatches: []*ModelScanDispatch{
+					{Sha: "d", QueuedAt: ago(3 * time.Hour), DispatchedAt: ago(2 * time.Hour)},
+					{Sha: "e", QueuedAt: ago(50 * time.Minute), DispatchedAt: ago(40 * time.Minute)},
+					{Sha: "f", QueuedAt: ago(30 * time.Minute), DispatchedAt: ago(10 * time.Minute)},
+				},
+			}}
+			stats := NewScanQueueStats(coreModel, ConcurrentScanLimit{Limit: 2, InProgressScans: 1}, now)
+			Expect(stats.Depth).To(Equal(3))
+			Expect(stats.DepthByPriority).To(Equal(map[int]int{1: 2, -1: 1}))
+			Expect(stats.OldestQueuedSha).To(Equal("b"))
+			Expect(stats.OldestQueuedAge.Minutes).To(Equal(10.0))
+			Expect(stats.Dispatches).To(Equal(2))
+			Expect(stats.DispatchesPerMinute).To(BeNumerically("~", 2.0/60))
+			Expect(stats.AverageWait.Minutes).To(Equal(15.0))
+			Expect(stats.InProgressScans).To(Equal(1))
+		})
+	})
+}
@@ -25,6 +25,8 @@ package api
 type ScanResults struct {
 	Pods   []ScannedPod
 	Images []ScannedImage
+	// Generation is the model's generation when the results were taken
+	Generation int64
 }
 
 // NewScanResults .....
@@ -0,0 +1,143 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// LabelOperator .....
+type LabelOperator int
+
+// .....
+const (
+	LabelOperatorEquals       LabelOperator = iota
+	LabelOperatorNotEquals    LabelOperator = iota
+	LabelOperatorExists       LabelOperator = iota
+	LabelOperatorDoesNotExist LabelOperator = iota
+)
+
+// LabelRequirement is a single clause of a label selector
+type LabelRequirement struct {
+	Key      string
+	Operator LabelOperator
+	Value    string
+}
+
+// Matches .....
+func (req *LabelRequirement) Matches(labels map[string]string) bool {
+	value, ok := labels[req.Key]
+	switch req.Operator {
+	case LabelOperatorEquals:
+		return ok && value == req.Value
+	case LabelOperatorNotEquals:
+		return !ok || value != req.Value
+	case LabelOperatorExists:
+		return ok
+	default: // LabelOperatorDoesNotExist
+		return !ok
+	}
+}
+
+var labelKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_./]*[A-Za-z0-9])?$`)
+var labelValueRegexp = regexp.MustCompile(`^([A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?)?$`)
+
+// ParseLabelSelector parses a comma-separated list of kubernetes-style
+// equality requirements: `key=value`, `key==value`, `key!=value`, `key`
+// and `!key`.
+func ParseLabelSelector(selector string) ([]*LabelRequirement, error) {
+	requirements := []*LabelRequirement{}
+	if selector == "" {
+		return requirements, nil
+	}
+	for _, clause := range strings.Split(selector, ",") {
+		clause = strings.TrimSpace(clause)
+		req := &LabelRequirement{}
+		switch {
+		case strings.Contains(clause, "!="):
+			parts := strings.SplitN(clause, "!=", 2)
+			req.Key, req.Operator, req.Value = parts[0], LabelOperatorNotEquals, parts[1]
+		case strings.Contains(clause, "=="):
+			parts := strings.SplitN(clause, "==", 2)
+			req.Key, req.Operator, req.Value = parts[0], LabelOperatorEquals, parts[1]
+		case strings.Contains(clause, "="):
+			parts := strings.SplitN(clause, "=", 2)
+			req.Key, req.Operator, req.Value = parts[0], LabelOperatorEquals, parts[1]
+		case strings.HasPrefix(clause, "!"):
+			req.Key, req.Operator = strings.TrimPrefix(clause, "!"), LabelOperatorDoesNotExist
+		default:
+			req.Key, req.Operator = clause, LabelOperatorExists
+		}
+		req.Key = strings.TrimSpace(req.Key)
+		req.Value = strings.TrimSpace(req.Value)
+		if !labelKeyRegexp.MatchString(req.Key) {
+			return nil, fmt.Errorf("invalid label selector %s: invalid key '%s'", selector, req.Key)
+		}
+		if !labelValueRegexp.MatchString(req.Value) {
+			return nil, fmt.Errorf("invalid label selector %s: invalid value '%s'", selector, req.Value)
+		}
+		requirements = append(requirements, req)
+	}
+	return requirements, nil
+}
+
+// ScanResultsQuery restricts scan results to matching pods and their images.
+// The zero value matches everything, including images without pods.
+type ScanResultsQuery struct {
+	Namespace     string
+	PodNamePrefix string
+	LabelSelector []*LabelRequirement
+}
+
+// ParseScanResultsQuery reads the `namespace`, `podPrefix` and `labelSelector` query parameters.
+func ParseScanResultsQuery(values url.Values) (*ScanResultsQuery, error) {
+	labelSelector, err := ParseLabelSelector(values.Get("labelSelector"))
+	if err != nil {
+		return nil, err
+	}
+	return &ScanResultsQuery{
+		Namespace:     values.Get("namespace"),
+		PodNamePrefix: values.Get("podPrefix"),
+		LabelSelector: labelSelector,
+	}, nil
+}
+
+// IsEmpty is true if the query doesn't filter anything out.
+func (query *ScanResultsQuery) IsEmpty() bool {
+	return query.Namespace == "" && query.PodNamePrefix == "" && len(query.LabelSelector) == 0
+}
+
+// MatchesPod ignores the namespace, which is expected to be handled by the caller.
+func (query *ScanResultsQuery) MatchesPod(name string, labels map[string]string) bool {
+	if !strings.HasPrefix(name, query.PodNamePrefix) {
+		return false
+	}
+	for _, req := range query.LabelSelector {
+		if !req.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
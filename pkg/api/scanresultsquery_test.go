@@ -0,0 +1,63 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunScanResultsQueryTests() {
+	Describe("scan results query", func() {
+		It("parses label selectors", func() {
+			selector, err := ParseLabelSelector("app=web, tier==front,env!=prod,canary,!legacy")
+			Expect(err).To(BeNil())
+			Expect(selector).To(Equal([]*LabelRequirement{
+				{Key: "app", Operator: LabelOperatorEquals, Value: "web"},
+				{Key: "tier", Operator: LabelOperatorEquals, Value: "front"},
+				{Key: "env", Operator: LabelOperatorNotEquals, Value: "prod"},
+				{Key: "canary", Operator: LabelOperatorExists},
+				{Key: "legacy", Operator: LabelOperatorDoesNotExist},
+			}))
+		})
+
+		It("rejects invalid label selectors", func() {
+			for _, selector := range []string{"=web", "app=we b", "app,", "a*b"} {
+				_, err := ParseLabelSelector(selector)
+				Expect(err).ToNot(BeNil(), selector)
+			}
+		})
+
+		It("matches pods", func() {
+			values := url.Values{"podPrefix": {"web-"}, "labelSelector": {"app=web,!legacy"}}
+			query, err := ParseScanResultsQuery(values)
+			Expect(err).To(BeNil())
+			Expect(query.IsEmpty()).To(BeFalse())
+			Expect(query.MatchesPod("web-1", map[string]string{"app": "web"})).To(BeTrue())
+			Expect(query.MatchesPod("db-1", map[string]string{"app": "web"})).To(BeFalse())
+			Expect(query.MatchesPod("web-2", map[string]string{"app": "web", "legacy": "true"})).To(BeFalse())
+			Expect(query.MatchesPod("web-3", nil)).To(BeFalse())
+		})
+	})
+}
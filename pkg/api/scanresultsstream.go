@@ -0,0 +1,122 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ScanResultsEvent is pushed to scan result streams whenever the results of
+// an image, or the overall status of a pod, change.  Exactly one of Pod and
+// Image is set.
+type ScanResultsEvent struct {
+	ID    uint64
+	Time  string
+	Pod   *ScannedPod   `json:",omitempty"`
+	Image *ScannedImage `json:",omitempty"`
+}
+
+// ScanResultsSubscription delivers events, oldest first, starting after the
+// event ID a client resumed from.
+type ScanResultsSubscription struct {
+	Events <-chan *ScanResultsEvent
+	// Reset is true if some events after the requested ID were no longer
+	// retained; the client should fetch /scanresults again.
+	Reset bool
+	// Lagged returns true once Events has been closed because the client fell
+	// too far behind.
+	Lagged func() bool
+	// Close must be called when the client goes away.
+	Close func()
+}
+
+const (
+	sseKeepalivePeriod = 15 * time.Second
+	sseRetryMillis     = 2000
+)
+
+// writeScanResultsStream sends a subscription's events as Server-Sent Events,
+// until the client disconnects or falls behind.
+func writeScanResultsStream(w http.ResponseWriter, r *http.Request, subscription *ScanResultsSubscription) {
+	defer subscription.Close()
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		RequestLogger(r).Errorf("unable to stream scan results: response writer can't flush")
+		return
+	}
+	// streams outlive the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		RequestLogger(r).Warnf("unable to clear write deadline for scan results stream: %s", err.Error())
+	}
+	header := w.Header()
+	header.Set(http.CanonicalHeaderKey("content-type"), "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetryMillis)
+	if subscription.Reset {
+		writeSSE(w, "", "reset", map[string]string{"message": "some events were missed; fetch /scanresults again"})
+	}
+	flusher.Flush()
+	keepalive := time.NewTicker(sseKeepalivePeriod)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case event, ok := <-subscription.Events:
+			if !ok {
+				if subscription.Lagged() {
+					RequestLogger(r).Warnf("disconnecting slow scan results stream client %s", SourceOfRequest(r))
+					writeSSE(w, "", "overflow", map[string]string{"message": "client fell too far behind; reconnect and fetch /scanresults again"})
+					flusher.Flush()
+				}
+				return
+			}
+			eventType := "image"
+			if event.Pod != nil {
+				eventType = "pod"
+			}
+			writeSSE(w, fmt.Sprintf("%d", event.ID), eventType, event)
+			flusher.Flush()
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, id string, eventType string, data interface{}) {
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		log.Errorf("unable to marshal %s event: %s", eventType, err.Error())
+		return
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, jsonBytes)
+}
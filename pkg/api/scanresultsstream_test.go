@@ -0,0 +1,166 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// streamingResponder replays its events to each subscriber, after the
+// requested ID, like the core model's change log does.
+type streamingResponder struct {
+	*MockResponder
+	mutex        sync.Mutex
+	events       []*ScanResultsEvent
+	lastEventIDs []string
+	lagged       bool
+}
+
+func (sr *streamingResponder) SubscribeScanResults(lastEventID string) (*ScanResultsSubscription, error) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+	sr.lastEventIDs = append(sr.lastEventIDs, lastEventID)
+	afterID := uint64(0)
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			return nil, NewValidationError("invalid event ID %s", lastEventID)
+		}
+		afterID = id
+	}
+	events := make(chan *ScanResultsEvent, len(sr.events))
+	for _, event := range sr.events {
+		if event.ID > afterID {
+			events <- event
+		}
+	}
+	close(events)
+	lagged := sr.lagged
+	return &ScanResultsSubscription{
+		Events: events,
+		Lagged: func() bool { return lagged },
+		Close:  func() {},
+	}, nil
+}
+
+// sseEvent is what an example consumer parses out of the stream.
+type sseEvent struct {
+	id        string
+	eventType string
+	data      string
+}
+
+// readSSE is an example consumer: it parses events until the stream ends.
+func readSSE(url string, lastEventID string) []*sseEvent {
+	request, err := http.NewRequest("GET", url, nil)
+	Expect(err).To(BeNil())
+	if lastEventID != "" {
+		request.Header.Set("Last-Event-ID", lastEventID)
+	}
+	response, err := http.DefaultClient.Do(request)
+	Expect(err).To(BeNil())
+	defer response.Body.Close()
+	Expect(response.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+	events := []*sseEvent{}
+	current := &sseEvent{}
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if current.eventType != "" {
+				events = append(events, current)
+			}
+			current = &sseEvent{}
+		case strings.HasPrefix(line, "id: "):
+			current.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			current.eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	return events
+}
+
+func RunScanResultsStreamTests() {
+	Describe("scan results stream", func() {
+		var responder *streamingResponder
+		var server *httptest.Server
+		BeforeEach(func() {
+			responder = &streamingResponder{MockResponder: NewMockResponder()}
+			for i, sha := range []string{"a", "b", "c"} {
+				responder.events = append(responder.events, &ScanResultsEvent{ID: uint64(i + 1), Image: &ScannedImage{Sha: sha}})
+			}
+			responder.events = append(responder.events, &ScanResultsEvent{ID: 4, Pod: &ScannedPod{Namespace: "ns", Name: "pod"}})
+			mux := http.NewServeMux()
+			setupHandlers(mux, responder)
+			server = httptest.NewServer(mux)
+		})
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("sends events in order, and resumes after Last-Event-ID", func() {
+			events := readSSE(server.URL+"/api/v1/stream/scanresults", "")
+			Expect(len(events)).To(Equal(4))
+			for i, event := range events[:3] {
+				Expect(event.eventType).To(Equal("image"))
+				Expect(event.id).To(Equal(strconv.Itoa(i + 1)))
+				var scanEvent ScanResultsEvent
+				Expect(json.Unmarshal([]byte(event.data), &scanEvent)).To(BeNil())
+				Expect(scanEvent.Image.Sha).To(Equal([]string{"a", "b", "c"}[i]))
+			}
+			Expect(events[3].eventType).To(Equal("pod"))
+
+			// reconnect from the second event
+			resumed := readSSE(server.URL+"/stream/scanresults", events[1].id)
+			Expect(len(resumed)).To(Equal(2))
+			Expect(resumed[0].id).To(Equal("3"))
+			Expect(resumed[1].id).To(Equal("4"))
+			Expect(responder.lastEventIDs).To(Equal([]string{"", "2"}))
+		})
+
+		It("accepts the last event ID as a query parameter", func() {
+			resumed := readSSE(server.URL+"/stream/scanresults?lastEventId=3", "")
+			Expect(len(resumed)).To(Equal(1))
+			Expect(resumed[0].id).To(Equal("4"))
+		})
+
+		It("tells slow clients why they're disconnected", func() {
+			responder.lagged = true
+			events := readSSE(server.URL+"/stream/scanresults", "")
+			Expect(len(events)).To(Equal(5))
+			Expect(events[4].eventType).To(Equal("overflow"))
+			Expect(events[4].id).To(Equal(""))
+		})
+	})
+}
@@ -26,16 +26,83 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-
-	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
 )
 
-// SetupHTTPServer .....
-func SetupHTTPServer(responder Responder) {
+// SetupHTTPServer registers every version of the API.  `removedRoutes` are
+// old paths of renamed routes which are no longer to be served.
+func SetupHTTPServer(responder Responder, removedRoutes ...string) {
+	setupHandlers(http.DefaultServeMux, responder, removedRoutes...)
+}
+
+// v1Handlers maps the routes of version 1 of the API, relative to its prefix.
+func v1Handlers(responder Responder) map[string]http.HandlerFunc {
+	handlers := map[string]http.HandlerFunc{}
+
 	// state of the program
-	http.HandleFunc("/model", func(w http.ResponseWriter, r *http.Request) {
+	handlers["/model"] = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			query, err := ParseModelQuery(r.URL.Query())
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			model, err := query.Apply(responder.GetModel())
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			if model.CoreModel != nil {
+				// hubs, config and scheduler can change without the core model doing so
+				etag, err := entityTag(r, model.CoreModel.Generation, model.Hubs, model.Config, model.Scheduler)
+				if err != nil {
+					responder.Error(w, r, err, 500)
+					return
+				}
+				if writeNotModified(w, r, etag) {
+					return
+				}
+			}
+			writeResponse(w, r, responder, model)
+		} else {
+			responder.NotFound(w, r)
+		}
+	}
+
+	// configuration perceptor is running with
+	handlers["/config"] = func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "GET" {
-			jsonBytes, err := json.MarshalIndent(responder.GetModel(), "", "  ")
+			writeResponse(w, r, responder, responder.GetConfig())
+		} else {
+			responder.NotFound(w, r)
+		}
+	}
+
+	// description of the API
+	handlers["/swagger.json"] = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			jsonBytes, err := json.MarshalIndent(OpenAPISpec(), "", "  ")
+			if err != nil {
+				responder.Error(w, r, err, 500)
+				return
+			}
+			header := w.Header()
+			header.Set(http.CanonicalHeaderKey("content-type"), "application/json")
+			fmt.Fprint(w, string(jsonBytes))
+		} else {
+			responder.NotFound(w, r)
+		}
+	}
+
+	handlers["/metrics/summary"] = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			summary, err := responder.GetMetricsSummary()
+			if err != nil {
+				responder.Error(w, r, err, 500)
+				return
+			}
+			jsonBytes, err := json.MarshalIndent(summary, "", "  ")
 			if err != nil {
 				responder.Error(w, r, err, 500)
 				return
@@ -46,26 +113,81 @@ func SetupHTTPServer(responder Responder) {
 		} else {
 			responder.NotFound(w, r)
 		}
-	})
+	}
+
+	handlers["/audit"] = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			query, err := ParseAuditQuery(r.URL.Query())
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			page, err := responder.GetAuditLog(query)
+			if err != nil {
+				responder.Error(w, r, err, 500)
+				return
+			}
+			writeResponse(w, r, responder, page)
+		} else {
+			responder.NotFound(w, r)
+		}
+	}
+	handlers["/stats/sources"] = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			report, err := responder.GetSourceStats()
+			if err != nil {
+				responder.Error(w, r, err, 500)
+				return
+			}
+			writeResponse(w, r, responder, report)
+		} else {
+			responder.NotFound(w, r)
+		}
+	}
+	// for kubernetes probes
+	handlers["/healthz"] = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			writeHealth(w, r, responder, responder.GetLiveness())
+		} else {
+			responder.NotFound(w, r)
+		}
+	}
+	handlers["/readyz"] = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			query, err := ParseReadinessQuery(r.URL.Query())
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			writeHealth(w, r, responder, responder.GetReadiness(query))
+		} else {
+			responder.NotFound(w, r)
+		}
+	}
 
 	// for receiving data from perceiver
-	http.HandleFunc("/pod", func(w http.ResponseWriter, r *http.Request) {
+	handlers["/pod"] = func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case "POST":
 			body, err := ioutil.ReadAll(r.Body)
 			if err != nil {
-				log.Errorf("unable to read body for pod POST: %s", err.Error())
+				RequestLogger(r).Errorf("unable to read body for pod POST: %s", err.Error())
 				responder.Error(w, r, err, 400)
 				return
 			}
 			var pod Pod
 			err = json.Unmarshal(body, &pod)
 			if err != nil {
-				log.Errorf("unable to ummarshal JSON for pod POST: %s", err.Error())
+				RequestLogger(r).Errorf("unable to ummarshal JSON for pod POST: %s", err.Error())
+				responder.Error(w, r, err, 400)
+				return
+			}
+			noteLegacyFieldNames(r, body)
+			err = responder.AddPod(pod)
+			if err != nil {
 				responder.Error(w, r, err, 400)
 				return
 			}
-			responder.AddPod(pod)
 			fmt.Fprint(w, "")
 		case "PUT":
 			body, err := ioutil.ReadAll(r.Body)
@@ -79,21 +201,58 @@ func SetupHTTPServer(responder Responder) {
 				responder.Error(w, r, err, 400)
 				return
 			}
-			responder.UpdatePod(pod)
-			fmt.Fprint(w, "")
+			noteLegacyFieldNames(r, body)
+			result, err := responder.UpdatePod(pod)
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			jsonBytes, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				responder.Error(w, r, err, 500)
+				return
+			}
+			header := w.Header()
+			header.Set(http.CanonicalHeaderKey("content-type"), "application/json")
+			fmt.Fprint(w, string(jsonBytes))
 		case "DELETE":
 			body, err := ioutil.ReadAll(r.Body)
 			if err != nil {
 				responder.Error(w, r, err, 400)
 				return
 			}
-			responder.DeletePod(string(body))
+			deferred, err := responder.DeletePod(string(body))
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			if deferred {
+				w.WriteHeader(http.StatusAccepted)
+			}
 			fmt.Fprint(w, "")
 		default:
 			responder.NotFound(w, r)
 		}
-	})
-	http.HandleFunc("/allpods", func(w http.ResponseWriter, r *http.Request) {
+	}
+	handlers["/pod/"] = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			setRouteTemplate(r, "/pod/{namespace}/{name}")
+			namespace, name, err := parsePodPath(strings.TrimPrefix(r.URL.EscapedPath(), "/pod/"))
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			detail, err := responder.GetPod(namespace, name)
+			if err != nil {
+				responder.Error(w, r, err, 500)
+				return
+			}
+			writeResponse(w, r, responder, detail)
+		} else {
+			responder.NotFound(w, r)
+		}
+	}
+	handlers["/allpods"] = func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "PUT" {
 			body, err := ioutil.ReadAll(r.Body)
 			if err != nil {
@@ -106,12 +265,17 @@ func SetupHTTPServer(responder Responder) {
 				responder.Error(w, r, err, 400)
 				return
 			}
-			responder.UpdateAllPods(allPods)
+			noteLegacyFieldNames(r, body)
+			err = responder.UpdateAllPods(allPods)
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
 		} else {
 			responder.NotFound(w, r)
 		}
-	})
-	http.HandleFunc("/allimages", func(w http.ResponseWriter, r *http.Request) {
+	}
+	handlers["/allimages"] = func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "PUT" {
 			body, err := ioutil.ReadAll(r.Body)
 			if err != nil {
@@ -124,12 +288,46 @@ func SetupHTTPServer(responder Responder) {
 				responder.Error(w, r, err, 400)
 				return
 			}
-			responder.UpdateAllImages(allImages)
+			noteLegacyFieldNames(r, body)
+			err = responder.UpdateAllImages(allImages)
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
 		} else {
 			responder.NotFound(w, r)
 		}
-	})
-	http.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+	}
+	handlers["/image/"] = func(w http.ResponseWriter, r *http.Request) {
+		shaPrefix := strings.TrimPrefix(r.URL.Path, "/image/")
+		switch {
+		case r.Method == "GET":
+			detail, err := responder.GetImage(shaPrefix)
+			if err != nil {
+				responder.Error(w, r, err, 500)
+				return
+			}
+			writeResponse(w, r, responder, detail)
+		case r.Method == "POST" && strings.HasSuffix(shaPrefix, "/requeue"):
+			setRouteTemplate(r, "/image/{sha}/requeue")
+			result, err := responder.RequeueImage(strings.TrimSuffix(shaPrefix, "/requeue"), SourceOfRequest(r))
+			if err != nil {
+				responder.Error(w, r, err, 500)
+				return
+			}
+			jsonBytes, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				responder.Error(w, r, err, 500)
+				return
+			}
+			header := w.Header()
+			header.Set(http.CanonicalHeaderKey("content-type"), "application/json")
+			fmt.Fprint(w, string(jsonBytes))
+		default:
+			responder.NotFound(w, r)
+		}
+	}
+	handlers["/image"] = func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "POST" {
 			body, err := ioutil.ReadAll(r.Body)
 			if err != nil {
@@ -142,17 +340,173 @@ func SetupHTTPServer(responder Responder) {
 				responder.Error(w, r, err, 400)
 				return
 			}
-			responder.AddImage(image)
+			noteLegacyFieldNames(r, body)
+			err = responder.AddImage(image)
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+		} else if r.Method == "GET" {
+			repository, tag, err := ParseImageName(r.URL.Query().Get("name"))
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			images, err := responder.GetImagesByName(repository, tag)
+			if err != nil {
+				responder.Error(w, r, err, 500)
+				return
+			}
+			writeResponse(w, r, responder, images)
+		} else {
+			responder.NotFound(w, r)
+		}
+	}
+
+	handlers["/images"] = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			acceptValid := false
+			if value := r.URL.Query().Get("acceptValid"); value != "" {
+				var err error
+				acceptValid, err = strconv.ParseBool(value)
+				if err != nil {
+					responder.Error(w, r, NewValidationError("invalid acceptValid %q", value), 400)
+					return
+				}
+			}
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			var images []Image
+			err = json.Unmarshal(body, &images)
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			noteLegacyFieldNames(r, body)
+			result, err := responder.AddImages(images, acceptValid)
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			jsonBytes, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				responder.Error(w, r, err, 500)
+				return
+			}
+			header := w.Header()
+			header.Set(http.CanonicalHeaderKey("content-type"), "application/json")
+			fmt.Fprint(w, string(jsonBytes))
 		} else {
 			responder.NotFound(w, r)
 		}
-	})
+	}
 
 	// for providing data to perceiver
-	http.HandleFunc("/scanresults", func(w http.ResponseWriter, r *http.Request) {
+	handlers["/pods"] = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			query, err := ParsePodsQuery(r.URL.Query())
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			pods, err := responder.GetPods(query)
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			writeResponse(w, r, responder, pods)
+		} else if r.Method == "DELETE" {
+			query, err := ParsePodsDeletionQuery(r.URL.Query())
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			deletion, err := responder.DeletePods(query)
+			if err != nil {
+				responder.Error(w, r, err, 500)
+				return
+			}
+			writeResponse(w, r, responder, deletion)
+		} else {
+			responder.NotFound(w, r)
+		}
+	}
+	handlers["/scanresults"] = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			query, err := ParseScanResultsQuery(r.URL.Query())
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			scanResults := responder.GetScanResults(query)
+			etag, err := entityTag(r, scanResults.Generation)
+			if err != nil {
+				responder.Error(w, r, err, 500)
+				return
+			}
+			if writeNotModified(w, r, etag) {
+				return
+			}
+			writeResponse(w, r, responder, scanResults)
+		} else {
+			responder.NotFound(w, r)
+		}
+	}
+
+	handlers["/export/findings"] = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			query, err := ParseFindingsExportQuery(r.URL.Query())
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			writeFindingsExport(w, r, responder, query)
+		} else {
+			responder.NotFound(w, r)
+		}
+	}
+
+	handlers["/stream/scanresults"] = func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "GET" {
-			scanResults := responder.GetScanResults()
-			jsonBytes, err := json.MarshalIndent(scanResults, "", "  ")
+			lastEventID := r.Header.Get("Last-Event-ID")
+			if lastEventID == "" {
+				// for EventSource polyfills which can't set headers
+				lastEventID = r.URL.Query().Get("lastEventId")
+			}
+			subscription, err := responder.SubscribeScanResults(lastEventID)
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			writeScanResultsStream(w, r, subscription)
+		} else {
+			responder.NotFound(w, r)
+		}
+	}
+
+	// for managing hub clients
+	handlers["/sethubs"] = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			var request SetHubsRequest
+			err = json.Unmarshal(body, &request)
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			result, err := responder.SetHubs(&request)
+			if err != nil {
+				responder.Error(w, r, err, 500)
+				return
+			}
+			jsonBytes, err := json.MarshalIndent(result, "", "  ")
 			if err != nil {
 				responder.Error(w, r, err, 500)
 				return
@@ -163,10 +517,44 @@ func SetupHTTPServer(responder Responder) {
 		} else {
 			responder.NotFound(w, r)
 		}
-	})
+	}
+	handlers["/hub/"] = func(w http.ResponseWriter, r *http.Request) {
+		path := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/hub/"), "/", 2)
+		if len(path) != 2 {
+			responder.NotFound(w, r)
+			return
+		}
+		host, subroute := path[0], path[1]
+		var result interface{}
+		var err error
+		switch {
+		case r.Method == "POST" && subroute == "login":
+			result, err = responder.LoginHub(host)
+		case r.Method == "GET" && subroute == "circuitbreaker":
+			result, err = responder.GetHubCircuitBreaker(host)
+		case r.Method == "POST" && subroute == "circuitbreaker/reset":
+			result, err = responder.ResetHubCircuitBreaker(host, SourceOfRequest(r), RequestIDOfRequest(r))
+		default:
+			responder.NotFound(w, r)
+			return
+		}
+		setRouteTemplate(r, "/hub/{host}/"+subroute)
+		if err != nil {
+			responder.Error(w, r, err, 500)
+			return
+		}
+		jsonBytes, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			responder.Error(w, r, err, 500)
+			return
+		}
+		header := w.Header()
+		header.Set(http.CanonicalHeaderKey("content-type"), "application/json")
+		fmt.Fprint(w, string(jsonBytes))
+	}
 
 	// for handling messages
-	http.HandleFunc("/command", func(w http.ResponseWriter, r *http.Request) {
+	handlers["/command"] = func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "POST" {
 			body, err := ioutil.ReadAll(r.Body)
 			if err != nil {
@@ -183,12 +571,17 @@ func SetupHTTPServer(responder Responder) {
 		} else {
 			responder.NotFound(w, r)
 		}
-	})
+	}
 
 	// for providing data to scanners
-	http.HandleFunc("/nextimage", func(w http.ResponseWriter, r *http.Request) {
+	handlers["/nextimage"] = func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "POST" {
-			nextImage := responder.GetNextImage()
+			query, err := ParseNextImageQuery(r.URL.Query())
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			nextImage := responder.GetNextImage(r.Context(), query)
 			jsonBytes, err := json.MarshalIndent(nextImage, "", "  ")
 			if err != nil {
 				responder.Error(w, r, err, 500)
@@ -200,9 +593,82 @@ func SetupHTTPServer(responder Responder) {
 		} else {
 			responder.NotFound(w, r)
 		}
-	})
+	}
+
+	handlers["/nextimage/ack"] = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			var ack ScanLeaseAcknowledgement
+			err = json.Unmarshal(body, &ack)
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			err = responder.AcknowledgeScanLease(ack)
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			fmt.Fprint(w, "")
+		} else {
+			responder.NotFound(w, r)
+		}
+	}
+
+	handlers["/concurrentscanlimit"] = func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			jsonBytes, err := json.MarshalIndent(responder.GetConcurrentScanLimit(), "", "  ")
+			if err != nil {
+				responder.Error(w, r, err, 500)
+				return
+			}
+			header := w.Header()
+			header.Set(http.CanonicalHeaderKey("content-type"), "application/json")
+			fmt.Fprint(w, string(jsonBytes))
+		case "POST":
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			var limit SetConcurrentScanLimit
+			err = json.Unmarshal(body, &limit)
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			err = responder.SetConcurrentScanLimit(limit)
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
+			fmt.Fprint(w, "")
+		default:
+			responder.NotFound(w, r)
+		}
+	}
+
+	handlers["/scanqueue/stats"] = func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			jsonBytes, err := json.MarshalIndent(responder.GetScanQueueStats(), "", "  ")
+			if err != nil {
+				responder.Error(w, r, err, 500)
+				return
+			}
+			header := w.Header()
+			header.Set(http.CanonicalHeaderKey("content-type"), "application/json")
+			fmt.Fprint(w, string(jsonBytes))
+		} else {
+			responder.NotFound(w, r)
+		}
+	}
 
-	http.HandleFunc("/finishedscan", func(w http.ResponseWriter, r *http.Request) {
+	handlers["/finishedscan"] = func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "POST" {
 			body, err := ioutil.ReadAll(r.Body)
 			if err != nil {
@@ -215,10 +681,30 @@ func SetupHTTPServer(responder Responder) {
 				responder.Error(w, r, err, 400)
 				return
 			}
-			responder.PostFinishScan(scanResults)
+			err = responder.PostFinishScan(scanResults)
+			if err != nil {
+				responder.Error(w, r, err, 400)
+				return
+			}
 			fmt.Fprint(w, "")
 		} else {
 			responder.NotFound(w, r)
 		}
-	})
+	}
+
+	return handlers
+}
+
+func writeHealth(w http.ResponseWriter, r *http.Request, responder Responder, health *Health) {
+	jsonBytes, err := json.MarshalIndent(health, "", "  ")
+	if err != nil {
+		responder.Error(w, r, err, 500)
+		return
+	}
+	header := w.Header()
+	header.Set(http.CanonicalHeaderKey("content-type"), "application/json")
+	if !health.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	fmt.Fprint(w, string(jsonBytes))
 }
@@ -0,0 +1,95 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+const maxPort = 65535
+
+// HubSpec describes one hub of a POST /sethubs request.  Port, User and
+// PasswordEnvVar are optional, and fall back to perceptor's hub config.
+type HubSpec struct {
+	Host           string
+	Port           int    `json:",omitempty"`
+	User           string `json:",omitempty"`
+	PasswordEnvVar string `json:",omitempty"`
+}
+
+// SetHubsRequest replaces the set of hubs perceptor talks to.  Hubs which
+// are already present keep their clients, and aren't reconfigured.
+type SetHubsRequest struct {
+	Hubs []HubSpec
+}
+
+// SetHubsFailure is a hub whose client couldn't be created.
+type SetHubsFailure struct {
+	Host  string
+	Error string
+}
+
+// SetHubsResult reports what a SetHubsRequest changed.
+type SetHubsResult struct {
+	Added     []string
+	Removed   []string
+	Unchanged []string
+	Failed    []SetHubsFailure
+}
+
+// NewSetHubsResult .....
+func NewSetHubsResult() *SetHubsResult {
+	return &SetHubsResult{Added: []string{}, Removed: []string{}, Unchanged: []string{}, Failed: []SetHubsFailure{}}
+}
+
+// ValidateSetHubsRequest returns a *ValidationError listing every problem
+// with `request`, before any hubs are touched.
+func ValidateSetHubsRequest(request *SetHubsRequest) error {
+	pv := &podValidator{}
+	if request.Hubs == nil {
+		pv.problem("Hubs", "is required; use an empty list to remove every hub")
+	}
+	hosts := map[string]bool{}
+	for i, hub := range request.Hubs {
+		field := fmt.Sprintf("Hubs[%d]", i)
+		if strings.Contains(hub.Host, "://") {
+			pv.problem(field+".Host", "must be a host name, not a URL, found %q", hub.Host)
+		} else {
+			pv.dns1123Subdomain(field+".Host", hub.Host)
+		}
+		if hosts[hub.Host] {
+			pv.problem(field+".Host", "duplicate host %s", hub.Host)
+		}
+		hosts[hub.Host] = true
+		if hub.Port < 0 || hub.Port > maxPort {
+			pv.problem(field+".Port", "must be between 1 and %d, or omitted", maxPort)
+		}
+	}
+	if len(pv.problems) > 0 {
+		return &ValidationError{
+			Message: fmt.Sprintf("invalid hubs: %d problem(s)", len(pv.problems)),
+			Fields:  pv.problems,
+		}
+	}
+	return nil
+}
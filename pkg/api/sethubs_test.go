@@ -0,0 +1,102 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunSetHubsTests() {
+	Describe("ValidateSetHubsRequest", func() {
+		fields := func(err error) []string {
+			names := []string{}
+			for _, field := range err.(*ValidationError).Fields {
+				names = append(names, field.Field)
+			}
+			return names
+		}
+
+		It("accepts hosts, with or without their own config", func() {
+			Expect(ValidateSetHubsRequest(&SetHubsRequest{Hubs: []HubSpec{
+				{Host: "hub1.example.com"},
+				{Host: "10.0.0.12", Port: 8443, User: "scanner", PasswordEnvVar: "HUB2_PASSWORD"},
+			}})).To(BeNil())
+			Expect(ValidateSetHubsRequest(&SetHubsRequest{Hubs: []HubSpec{}})).To(BeNil())
+		})
+
+		It("lists every problem", func() {
+			err := ValidateSetHubsRequest(&SetHubsRequest{Hubs: []HubSpec{
+				{Host: "https://hub1.example.com"},
+				{Host: ""},
+				{Host: "hub3.example.com", Port: 70000},
+				{Host: "hub3.example.com"},
+			}})
+			Expect(fields(err)).To(Equal([]string{"Hubs[0].Host", "Hubs[1].Host", "Hubs[2].Port", "Hubs[3].Host"}))
+		})
+
+		It("requires the list of hubs", func() {
+			Expect(fields(ValidateSetHubsRequest(&SetHubsRequest{}))).To(Equal([]string{"Hubs"}))
+		})
+	})
+
+	Describe("POST /sethubs", func() {
+		var responder *MockResponder
+		BeforeEach(func() {
+			responder = NewMockResponder()
+			responder.HubHosts["hub1.example.com"] = true
+			responder.HubHosts["hub2.example.com"] = true
+		})
+		setHubs := func(body string) *httptest.ResponseRecorder {
+			mux := http.NewServeMux()
+			setupHandlers(mux, responder)
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest("POST", "/api/v1/sethubs", strings.NewReader(body)))
+			return recorder
+		}
+
+		It("reports what changed", func() {
+			recorder := setHubs(`{"Hubs": [{"Host": "hub1.example.com"}, {"Host": "hub3.example.com", "Port": 8443}]}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var result SetHubsResult
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &result)).To(BeNil())
+			Expect(result.Added).To(Equal([]string{"hub3.example.com"}))
+			Expect(result.Removed).To(Equal([]string{"hub2.example.com"}))
+			Expect(result.Unchanged).To(Equal([]string{"hub1.example.com"}))
+			Expect(result.Failed).To(BeEmpty())
+		})
+
+		It("rejects invalid requests without touching the hubs", func() {
+			recorder := setHubs(`{"Hubs": [{"Host": "hub1.example.com"}, {"Host": "https://hub3.example.com"}]}`)
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			var errorResponse ErrorResponse
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &errorResponse)).To(BeNil())
+			Expect(errorResponse.Code).To(Equal(ErrorCodeValidation))
+			Expect(responder.HubHosts).To(HaveLen(2))
+		})
+	})
+}
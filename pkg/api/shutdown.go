@@ -0,0 +1,53 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultShutdownDrain is how long in-flight requests get to finish, by default.
+const DefaultShutdownDrain = 30 * time.Second
+
+// Shutdown stops server from accepting new connections, then waits up to
+// drain for in-flight requests to finish.  Any requests still running after
+// that are cut off, and an error is returned.
+func Shutdown(server *http.Server, drain time.Duration) error {
+	log.Infof("draining HTTP server %s for up to %s", server.Addr, drain)
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	if err == nil {
+		log.Infof("HTTP server %s drained", server.Addr)
+		return nil
+	}
+	closeErr := server.Close()
+	if closeErr != nil {
+		log.Errorf("unable to close HTTP server %s: %s", server.Addr, closeErr.Error())
+	}
+	return fmt.Errorf("HTTP server %s not drained within %s: %s", server.Addr, drain, err.Error())
+}
@@ -0,0 +1,109 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunShutdownTests() {
+	Describe("Shutdown", func() {
+		var server *http.Server
+		var url string
+		var started chan struct{}
+		var release chan struct{}
+		BeforeEach(func() {
+			started = make(chan struct{}, 1)
+			release = make(chan struct{})
+			mux := http.NewServeMux()
+			mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+				started <- struct{}{}
+				<-release
+				fmt.Fprint(w, "finished")
+			})
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).To(BeNil())
+			url = "http://" + listener.Addr().String()
+			server = &http.Server{Addr: listener.Addr().String(), Handler: mux}
+			go server.Serve(listener)
+		})
+		AfterEach(func() {
+			server.Close()
+		})
+		slowRequest := func() <-chan string {
+			body := make(chan string, 1)
+			go func() {
+				response, err := http.Get(url + "/slow")
+				if err != nil {
+					body <- err.Error()
+					return
+				}
+				defer response.Body.Close()
+				bytes, _ := ioutil.ReadAll(response.Body)
+				body <- string(bytes)
+			}()
+			Eventually(started).Should(Receive())
+			return body
+		}
+		shutdown := func(drain time.Duration) <-chan error {
+			result := make(chan error, 1)
+			go func() {
+				result <- Shutdown(server, drain)
+			}()
+			return result
+		}
+
+		It("lets in-flight requests finish, while refusing new ones", func() {
+			body := slowRequest()
+			result := shutdown(5 * time.Second)
+			Eventually(func() error {
+				conn, err := net.DialTimeout("tcp", server.Addr, time.Second)
+				if err == nil {
+					conn.Close()
+				}
+				return err
+			}).ShouldNot(BeNil())
+			Consistently(result, 100*time.Millisecond).ShouldNot(Receive())
+
+			close(release)
+			Eventually(body).Should(Receive(Equal("finished")))
+			Eventually(result).Should(Receive(BeNil()))
+		})
+
+		It("cuts off requests which outlast the drain period", func() {
+			body := slowRequest()
+			result := shutdown(100 * time.Millisecond)
+			var err error
+			Eventually(result).Should(Receive(&err))
+			Expect(err).NotTo(BeNil())
+			Eventually(body).Should(Receive(Not(Equal("finished"))))
+			close(release)
+		})
+	})
+}
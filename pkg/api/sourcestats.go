@@ -0,0 +1,225 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxTrackedSources caps the number of sources with their own
+	// statistics and metric labels.
+	DefaultMaxTrackedSources = 100
+	// UntrackedSources is the source that the traffic of sources beyond the
+	// cap is attributed to.
+	UntrackedSources = "(untracked)"
+
+	sourceStatsBucketWidth = time.Minute
+	sourceStatsBuckets     = 60
+)
+
+// TrafficWindow is the traffic from a source over a period.
+type TrafficWindow struct {
+	// Requests is keyed by route class
+	Requests           map[string]int
+	BytesIngested      int64
+	ValidationFailures int
+}
+
+// SourceTraffic is the traffic from a source over the last 5 minutes and hour.
+type SourceTraffic struct {
+	Source          string
+	LastFiveMinutes *TrafficWindow
+	LastHour        *TrafficWindow
+}
+
+// SourceStatsReport is ordered by requests over the last hour, busiest first.
+type SourceStatsReport struct {
+	Sources []*SourceTraffic
+}
+
+type trafficCounts struct {
+	requests           map[RouteClass]int
+	bytesIngested      int64
+	validationFailures int
+}
+
+// sourceTraffic counts a source's traffic in one-minute buckets, covering
+// the last hour.
+type sourceTraffic struct {
+	buckets  [sourceStatsBuckets]trafficCounts
+	minutes  [sourceStatsBuckets]int64
+	lastSeen time.Time
+	// legacyRoutes are the routes the source has sent legacy payloads to
+	legacyRoutes map[string]bool
+}
+
+func (st *sourceTraffic) bucket(minute int64) *trafficCounts {
+	ix := minute % sourceStatsBuckets
+	if st.minutes[ix] != minute || st.buckets[ix].requests == nil {
+		st.minutes[ix] = minute
+		st.buckets[ix] = trafficCounts{requests: map[RouteClass]int{}}
+	}
+	return &st.buckets[ix]
+}
+
+func (st *sourceTraffic) window(minute int64, minutes int64) *TrafficWindow {
+	window := &TrafficWindow{Requests: map[string]int{}}
+	for ix, bucketMinute := range st.minutes {
+		bucket := st.buckets[ix]
+		if bucket.requests == nil || bucketMinute <= minute-minutes || bucketMinute > minute {
+			continue
+		}
+		for class, count := range bucket.requests {
+			window.Requests[class.String()] += count
+		}
+		window.BytesIngested += bucket.bytesIngested
+		window.ValidationFailures += bucket.validationFailures
+	}
+	return window
+}
+
+// SourceStats keeps rolling counts of the requests from each source.  Once
+// `maxSources` sources are tracked, the traffic of new ones is attributed to
+// UntrackedSources; sources which have been idle for an hour are dropped.
+// It is concurrent-safe.
+type SourceStats struct {
+	mutex      sync.Mutex
+	maxSources int
+	sources    map[string]*sourceTraffic
+	lastSweep  time.Time
+	now        func() time.Time
+}
+
+// NewSourceStats .....
+func NewSourceStats(maxSources int) *SourceStats {
+	return &SourceStats{
+		maxSources: maxSources,
+		sources:    map[string]*sourceTraffic{},
+		now:        time.Now,
+	}
+}
+
+// record counts a request; `legacyRoute` is set if its payload used legacy
+// field names.
+func (ss *SourceStats) record(source string, class RouteClass, bytesIngested int64, validationFailure bool, legacyRoute string) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	now := ss.now()
+	if now.Sub(ss.lastSweep) > sourceStatsBucketWidth*sourceStatsBuckets {
+		for name, traffic := range ss.sources {
+			if now.Sub(traffic.lastSeen) > sourceStatsBucketWidth*sourceStatsBuckets {
+				delete(ss.sources, name)
+				forgetSourceMetrics(name, traffic.legacyRoutes)
+			}
+		}
+		ss.lastSweep = now
+	}
+	traffic, ok := ss.sources[source]
+	if !ok {
+		if len(ss.sources) >= ss.maxSources && source != UntrackedSources {
+			source = UntrackedSources
+			traffic, ok = ss.sources[source]
+		}
+		if !ok {
+			traffic = &sourceTraffic{legacyRoutes: map[string]bool{}}
+			ss.sources[source] = traffic
+		}
+	}
+	traffic.lastSeen = now
+	bucket := traffic.bucket(now.Unix() / int64(sourceStatsBucketWidth/time.Second))
+	bucket.requests[class]++
+	bucket.bytesIngested += bytesIngested
+	if validationFailure {
+		bucket.validationFailures++
+	}
+	recordSourceRequest(source, class, bytesIngested, validationFailure)
+	if legacyRoute != "" {
+		traffic.legacyRoutes[legacyRoute] = true
+		recordLegacyPayload(source, legacyRoute)
+	}
+}
+
+// Report .....
+func (ss *SourceStats) Report() *SourceStatsReport {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+	minute := ss.now().Unix() / int64(sourceStatsBucketWidth/time.Second)
+	report := &SourceStatsReport{Sources: []*SourceTraffic{}}
+	totals := map[string]int{}
+	for name, traffic := range ss.sources {
+		source := &SourceTraffic{
+			Source:          name,
+			LastFiveMinutes: traffic.window(minute, 5),
+			LastHour:        traffic.window(minute, sourceStatsBuckets),
+		}
+		for _, count := range source.LastHour.Requests {
+			totals[name] += count
+		}
+		report.Sources = append(report.Sources, source)
+	}
+	sort.Slice(report.Sources, func(i, j int) bool {
+		left, right := report.Sources[i].Source, report.Sources[j].Source
+		if totals[left] != totals[right] {
+			return totals[left] > totals[right]
+		}
+		return left < right
+	})
+	return report
+}
+
+// countingBody counts the bytes of a request body which a handler reads.
+type countingBody struct {
+	io.ReadCloser
+	bytes int64
+}
+
+func (cb *countingBody) Read(p []byte) (int, error) {
+	n, err := cb.ReadCloser.Read(p)
+	cb.bytes += int64(n)
+	return n, err
+}
+
+// SourceStatsHandler records every request in `stats`, including those
+// which are rejected, since they're load all the same.  It must be wrapped
+// by a RequestLogHandler, to learn which requests failed validation or used
+// legacy field names.
+func SourceStatsHandler(handler http.Handler, stats *SourceStats) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := &countingBody{ReadCloser: r.Body}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = body
+		}
+		handler.ServeHTTP(w, r)
+		validationFailure := false
+		legacyRoute := ""
+		if info := requestInfoOfRequest(r); info != nil {
+			validationFailure = info.errorCode == ErrorCodeValidation
+			legacyRoute = info.legacyRoute
+		}
+		stats.record(SourceOfRequest(r), RouteClassOfRequest(r), body.bytes, validationFailure, legacyRoute)
+	})
+}
@@ -0,0 +1,145 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func RunSourceStatsTests() {
+	Describe("per-source traffic statistics", func() {
+		var now time.Time
+		var stats *SourceStats
+		var handler http.Handler
+		BeforeEach(func() {
+			now = time.Date(2018, 6, 1, 12, 0, 30, 0, time.UTC)
+			stats = NewSourceStats(2)
+			stats.now = func() time.Time { return now }
+			responder := NewMockResponder()
+			mux := http.NewServeMux()
+			setupHandlers(mux, responder)
+			handler = RequestLogHandler(SourceStatsHandler(mux, stats), nil)
+		})
+		serve := func(method string, path string, source string, body string) {
+			request := httptest.NewRequest(method, path, strings.NewReader(body))
+			request.Header.Set(SourceHeader, source)
+			handler.ServeHTTP(httptest.NewRecorder(), request)
+		}
+		trafficOf := func(source string) *SourceTraffic {
+			for _, traffic := range stats.Report().Sources {
+				if traffic.Source == source {
+					return traffic
+				}
+			}
+			return nil
+		}
+
+		It("counts requests by route class, bytes ingested and validation failures", func() {
+			serve("POST", "/api/v1/pod", "perceiver-1", `{"Name":"pod1","Namespace":"ns1"}`)
+			serve("DELETE", "/api/v1/pods?namespace=NS1", "perceiver-1", "")
+			serve("GET", "/api/v1/model", "perceiver-1", "")
+			traffic := trafficOf("perceiver-1")
+			Expect(traffic.LastFiveMinutes).To(Equal(&TrafficWindow{
+				Requests:           map[string]int{"mutating": 2, "read": 1},
+				BytesIngested:      33,
+				ValidationFailures: 1,
+			}))
+			Expect(traffic.LastHour).To(Equal(traffic.LastFiveMinutes))
+		})
+
+		It("rolls requests out of the windows", func() {
+			serve("GET", "/api/v1/model", "perceiver-1", "")
+			now = now.Add(10 * time.Minute)
+			serve("GET", "/api/v1/model", "perceiver-1", "")
+			traffic := trafficOf("perceiver-1")
+			Expect(traffic.LastFiveMinutes.Requests).To(Equal(map[string]int{"read": 1}))
+			Expect(traffic.LastHour.Requests).To(Equal(map[string]int{"read": 2}))
+			now = now.Add(55 * time.Minute)
+			traffic = trafficOf("perceiver-1")
+			Expect(traffic.LastHour.Requests).To(Equal(map[string]int{"read": 1}))
+			now = now.Add(5 * time.Minute)
+			traffic = trafficOf("perceiver-1")
+			Expect(traffic.LastHour.Requests).To(Equal(map[string]int{}))
+		})
+
+		It("orders sources busiest first, and caps how many are tracked", func() {
+			serve("GET", "/api/v1/model", "perceiver-1", "")
+			serve("GET", "/api/v1/model", "perceiver-2", "")
+			serve("GET", "/api/v1/model", "perceiver-2", "")
+			serve("GET", "/api/v1/model", "perceiver-3", "")
+			report := stats.Report()
+			Expect(len(report.Sources)).To(Equal(3))
+			Expect(report.Sources[0].Source).To(Equal("perceiver-2"))
+			Expect(trafficOf("perceiver-3")).To(BeNil())
+			Expect(trafficOf(UntrackedSources).LastHour.Requests).To(Equal(map[string]int{"read": 1}))
+		})
+
+		It("drops idle sources along with their metrics", func() {
+			serve("GET", "/api/v1/model", "idle-perceiver", "")
+			metric := &dto.Metric{}
+			Expect(sourceRequestCounter.With(map[string]string{"source": "idle-perceiver", "class": "read"}).Write(metric)).To(BeNil())
+			Expect(metric.GetCounter().GetValue()).To(BeNumerically(">=", 1))
+			now = now.Add(2 * time.Hour)
+			serve("GET", "/api/v1/model", "perceiver-1", "")
+			Expect(trafficOf("idle-perceiver")).To(BeNil())
+			metric = &dto.Metric{}
+			Expect(sourceRequestCounter.With(map[string]string{"source": "idle-perceiver", "class": "read"}).Write(metric)).To(BeNil())
+			Expect(metric.GetCounter().GetValue()).To(Equal(float64(0)))
+		})
+
+		It("drops the legacy payload series of idle sources", func() {
+			legacyPod := `{"Name":"pod1","UID":"uid1","Namespace":"ns1","Containers":[{"Name":"cont1","Image":{"Name":"app","Tag":"1","Sha":"` + strings.Repeat("a", 64) + `"}}]}`
+			legacyCount := func() float64 {
+				metric := &dto.Metric{}
+				Expect(legacyPayloadCounter.With(map[string]string{"source": "idle-perceiver", "route": "/pod"}).Write(metric)).To(BeNil())
+				return metric.GetCounter().GetValue()
+			}
+			serve("POST", "/api/v1/pod", "idle-perceiver", legacyPod)
+			Expect(legacyCount()).To(BeNumerically(">=", 1))
+			now = now.Add(2 * time.Hour)
+			serve("GET", "/api/v1/model", "perceiver-1", "")
+			Expect(legacyCount()).To(Equal(float64(0)))
+		})
+
+		It("is served at GET /stats/sources", func() {
+			responder := NewMockResponder()
+			responder.SourceStats = stats
+			mux := http.NewServeMux()
+			setupHandlers(mux, responder)
+			serve("GET", "/api/v1/model", "perceiver-1", "")
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/stats/sources", nil))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			var report SourceStatsReport
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &report)).To(BeNil())
+			Expect(report.Sources[0].Source).To(Equal("perceiver-1"))
+		})
+	})
+}
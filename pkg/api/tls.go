@@ -0,0 +1,158 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CertificateReloader serves a TLS certificate from a cert/key file pair,
+// picking up new files whenever they change -- for example, when
+// cert-manager rotates them -- or when Reload is called.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+	mutex    sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+// NewCertificateReloader fails if the initial cert/key pair can't be loaded.
+func NewCertificateReloader(certFile string, keyFile string) (*CertificateReloader, error) {
+	cr := &CertificateReloader{certFile: certFile, keyFile: keyFile}
+	if err := cr.Reload(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// Reload reads the cert/key pair from disk.  On failure, the previously
+// loaded certificate continues to be served.
+func (cr *CertificateReloader) Reload() error {
+	modTime, err := cr.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("unable to load certificate %s and key %s: %s", cr.certFile, cr.keyFile, err.Error())
+	}
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	cr.cert = &cert
+	cr.modTime = modTime
+	log.Infof("loaded TLS certificate from %s", cr.certFile)
+	return nil
+}
+
+func (cr *CertificateReloader) latestModTime() (time.Time, error) {
+	latest := time.Time{}
+	for _, path := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (cr *CertificateReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	modTime, err := cr.latestModTime()
+	cr.mutex.RLock()
+	changed := err == nil && modTime.After(cr.modTime)
+	cr.mutex.RUnlock()
+	if changed {
+		if err := cr.Reload(); err != nil {
+			log.Errorf("unable to reload TLS certificate: %s", err.Error())
+		}
+	}
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+	return cr.cert, nil
+}
+
+// NewServerTLSConfig builds the TLS configuration for the API server.  If
+// `clientCAFile` is non-empty, clients must present a certificate signed by
+// one of the CAs it contains.
+func NewServerTLSConfig(getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error), clientCAFile string) (*tls.Config, error) {
+	config := &tls.Config{
+		GetCertificate: getCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		caBytes, err := ioutil.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read client CA bundle %s: %s", clientCAFile, err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certificates found in client CA bundle %s", clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// StaticCertificate is a tls.Config.GetCertificate implementation which
+// always serves the same PEM-encoded cert/key pair.
+func StaticCertificate(certPEM string, keyPEM string) (func(*tls.ClientHelloInfo) (*tls.Certificate, error), error) {
+	cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse PEM certificate and key: %s", err.Error())
+	}
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return &cert, nil
+	}, nil
+}
+
+// RedirectToHTTPS redirects every request to the same path on `httpsPort`.
+func RedirectToHTTPS(httpsPort int) http.Handler {
+	return redirectToHTTPS(func() int { return httpsPort })
+}
+
+func redirectToHTTPS(httpsPort func() int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		url := *r.URL
+		url.Scheme = "https"
+		url.Host = net.JoinHostPort(host, fmt.Sprintf("%d", httpsPort()))
+		http.Redirect(w, r, url.String(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,172 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// selfSignedPair returns a PEM-encoded certificate and key, valid for localhost.
+func selfSignedPair(commonName string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).To(BeNil())
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).To(BeNil())
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	Expect(err).To(BeNil())
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	return string(certPEM), string(keyPEM)
+}
+
+func writeFile(dir string, name string, contents string) string {
+	path := filepath.Join(dir, name)
+	Expect(ioutil.WriteFile(path, []byte(contents), 0600)).To(BeNil())
+	return path
+}
+
+func RunTLSTests() {
+	Describe("TLS", func() {
+		var dir string
+		okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+		})
+		// returns the server, and a URL for it which uses a host name -- so that
+		// SNI is sent, and the certificate from `config` is used rather than
+		// httptest's default certificate.
+		startServer := func(config *tls.Config) (*httptest.Server, string) {
+			server := httptest.NewUnstartedServer(okHandler)
+			server.TLS = config
+			server.StartTLS()
+			return server, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+		}
+		client := func(serverCertPEM string, clientCert *tls.Certificate) *http.Client {
+			roots := x509.NewCertPool()
+			Expect(roots.AppendCertsFromPEM([]byte(serverCertPEM))).To(BeTrue())
+			tlsConfig := &tls.Config{RootCAs: roots}
+			if clientCert != nil {
+				tlsConfig.Certificates = []tls.Certificate{*clientCert}
+			}
+			return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		}
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "perceptor-tls")
+			Expect(err).To(BeNil())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("serves requests with a certificate from disk", func() {
+			certPEM, keyPEM := selfSignedPair("server")
+			reloader, err := NewCertificateReloader(writeFile(dir, "tls.crt", certPEM), writeFile(dir, "tls.key", keyPEM))
+			Expect(err).To(BeNil())
+			config, err := NewServerTLSConfig(reloader.GetCertificate, "")
+			Expect(err).To(BeNil())
+			server, url := startServer(config)
+			defer server.Close()
+			resp, err := client(certPEM, nil).Get(url)
+			Expect(err).To(BeNil())
+			Expect(resp.StatusCode).To(Equal(200))
+		})
+
+		It("picks up a rotated certificate", func() {
+			certPEM, keyPEM := selfSignedPair("server")
+			certFile := writeFile(dir, "tls.crt", certPEM)
+			keyFile := writeFile(dir, "tls.key", keyPEM)
+			reloader, err := NewCertificateReloader(certFile, keyFile)
+			Expect(err).To(BeNil())
+			newCertPEM, newKeyPEM := selfSignedPair("rotated")
+			writeFile(dir, "tls.crt", newCertPEM)
+			writeFile(dir, "tls.key", newKeyPEM)
+			later := time.Now().Add(time.Minute)
+			Expect(os.Chtimes(certFile, later, later)).To(BeNil())
+			Expect(os.Chtimes(keyFile, later, later)).To(BeNil())
+			cert, err := reloader.GetCertificate(nil)
+			Expect(err).To(BeNil())
+			leaf, err := x509.ParseCertificate(cert.Certificate[0])
+			Expect(err).To(BeNil())
+			Expect(leaf.Subject.CommonName).To(Equal("rotated"))
+		})
+
+		It("requires client certificates signed by the configured CA", func() {
+			serverCertPEM, serverKeyPEM := selfSignedPair("server")
+			clientCertPEM, clientKeyPEM := selfSignedPair("client")
+			getCertificate, err := StaticCertificate(serverCertPEM, serverKeyPEM)
+			Expect(err).To(BeNil())
+			config, err := NewServerTLSConfig(getCertificate, writeFile(dir, "ca.crt", clientCertPEM))
+			Expect(err).To(BeNil())
+			server, url := startServer(config)
+			defer server.Close()
+
+			_, err = client(serverCertPEM, nil).Get(url)
+			Expect(err).ToNot(BeNil())
+
+			clientCert, err := tls.X509KeyPair([]byte(clientCertPEM), []byte(clientKeyPEM))
+			Expect(err).To(BeNil())
+			resp, err := client(serverCertPEM, &clientCert).Get(url)
+			Expect(err).To(BeNil())
+			Expect(resp.StatusCode).To(Equal(200))
+		})
+
+		It("redirects plain HTTP to HTTPS", func() {
+			request := httptest.NewRequest("GET", "http://perceptor:3001/model?x=1", nil)
+			recorder := httptest.NewRecorder()
+			RedirectToHTTPS(3443).ServeHTTP(recorder, request)
+			Expect(recorder.Code).To(Equal(http.StatusPermanentRedirect))
+			Expect(recorder.Header().Get("Location")).To(Equal("https://perceptor:3443/model?x=1"))
+		})
+	})
+}
@@ -0,0 +1,203 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// APIVersionHeader tells clients which version of the API handled a request.
+const APIVersionHeader = "X-Perceptor-API-Version"
+
+const apiPrefix = "/api/"
+
+// apiVersion is one version of the HTTP API.  Each version registers its own
+// handlers, so that a new version can change payloads without disturbing
+// clients of an older one.
+type apiVersion struct {
+	name     string
+	handlers func(responder Responder) map[string]http.HandlerFunc
+	// renamed are routes which are also served under their old paths
+	renamed []*RenamedRoute
+}
+
+// apiVersions lists every supported version, oldest first.
+var apiVersions = []*apiVersion{
+	{name: "v1", handlers: v1Handlers, renamed: v1RenamedRoutes},
+}
+
+// legacyAPIVersion also serves the unversioned paths, which are deprecated.
+const legacyAPIVersion = "v1"
+
+type apiVersionContextKey struct{}
+
+// VersionedPath returns the path of `route` under `version` of the API.
+func VersionedPath(version string, route string) string {
+	return apiPrefix + version + route
+}
+
+// UnversionedPath splits a leading /api/<version> from `path`.  The version
+// is empty for legacy, unversioned paths.
+func UnversionedPath(path string) (version string, route string) {
+	if !strings.HasPrefix(path, apiPrefix) {
+		return "", path
+	}
+	rest := strings.TrimPrefix(path, apiPrefix)
+	slash := strings.Index(rest, "/")
+	if slash < 0 {
+		return rest, "/"
+	}
+	return rest[:slash], rest[slash:]
+}
+
+// otherRoute stands in for paths which aren't routes of any version of the
+// API, so that metrics labeled by route stay bounded.
+const otherRoute = "other"
+
+// apiRoutes maps the paths of each version, and of the legacy routes under
+// "", to the routes serving them; old paths of renamed routes map to the new.
+// It's built in init, since handlers look routes up.
+var apiRoutes map[string]map[string]string
+
+func init() {
+	apiRoutes = routesOfVersions(apiVersions)
+}
+
+func routesOfVersions(versions []*apiVersion) map[string]map[string]string {
+	routes := map[string]map[string]string{}
+	for _, version := range versions {
+		versionRoutes := map[string]string{}
+		for route := range version.handlers(nil) {
+			versionRoutes[route] = route
+		}
+		for _, renamed := range version.renamed {
+			versionRoutes[renamed.Old] = renamed.New
+		}
+		routes[version.name] = versionRoutes
+		if version.name == legacyAPIVersion {
+			routes[""] = versionRoutes
+		}
+	}
+	return routes
+}
+
+// routeOfPath returns the template of the route which would serve `path`,
+// matching subtree routes the way http.ServeMux does, or otherRoute.
+func routeOfPath(path string) string {
+	version, route := UnversionedPath(path)
+	routes, ok := apiRoutes[version]
+	if !ok {
+		return otherRoute
+	}
+	match, ok := routes[route]
+	for i := strings.LastIndex(route, "/"); !ok && i >= 0; i = strings.LastIndex(route[:i], "/") {
+		match, ok = routes[route[:i+1]]
+	}
+	if !ok {
+		return otherRoute
+	}
+	if template, ok := routeTemplates[match]; ok {
+		return template
+	}
+	return match
+}
+
+// canonicalRoute returns the route of `path` relative to its version, with
+// the old paths of renamed routes replaced by the new ones.  Middleware which
+// looks routes up by name uses it, so that its tables only need the new names.
+func canonicalRoute(path string) string {
+	version, route := UnversionedPath(path)
+	routes := apiRoutes[version]
+	if renamed, ok := routes[route]; ok {
+		return renamed
+	}
+	for i := strings.LastIndex(route, "/"); i >= 0; i = strings.LastIndex(route[:i], "/") {
+		if renamed, ok := routes[route[:i+1]]; ok {
+			return renamed + route[i+1:]
+		}
+	}
+	return route
+}
+
+// APIVersionOfRequest returns the version of the API a request was routed
+// to, or "" if it wasn't routed by version.
+func APIVersionOfRequest(r *http.Request) string {
+	version, _ := r.Context().Value(apiVersionContextKey{}).(string)
+	return version
+}
+
+func setupHandlers(mux *http.ServeMux, responder Responder, removedRoutes ...string) {
+	mountAPIVersions(mux, responder, withoutRenamedRoutes(apiVersions, removedRoutes))
+}
+
+func mountAPIVersions(mux *http.ServeMux, responder Responder, versions []*apiVersion) {
+	for _, version := range versions {
+		handlers := version.handlers(responder)
+		for route, handler := range handlers {
+			mux.Handle(VersionedPath(version.name, route), versionHandler(version.name, route, false, handler))
+			if version.name == legacyAPIVersion {
+				mux.Handle(route, versionHandler(version.name, route, true, handler))
+			}
+		}
+		for _, renamed := range version.renamed {
+			handler, ok := handlers[renamed.New]
+			if !ok {
+				panic(fmt.Errorf("route %s of API %s was renamed to %s, which has no handler", renamed.Old, version.name, renamed.New))
+			}
+			mux.Handle(VersionedPath(version.name, renamed.Old), renamedRouteHandler(version.name, renamed, false, versionHandler(version.name, renamed.New, false, handler)))
+			if version.name == legacyAPIVersion {
+				mux.Handle(renamed.Old, renamedRouteHandler(version.name, renamed, true, versionHandler(version.name, renamed.New, true, handler)))
+			}
+		}
+	}
+}
+
+// versionHandler hands `handler` requests whose paths are relative to the
+// version's prefix, and records which version served them.
+func versionHandler(version string, route string, deprecated bool, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set(APIVersionHeader, version)
+		recordVersionedRequest(version, route, r.Method, deprecated)
+		setRouteTemplate(r, route)
+		_, unversioned := UnversionedPath(r.URL.Path)
+		// keep the escaping of the path, for routes whose segments may
+		// contain escaped slashes
+		_, rawUnversioned := UnversionedPath(r.URL.RawPath)
+		if deprecated {
+			unversioned = r.URL.Path
+			rawUnversioned = r.URL.RawPath
+			header.Set("Deprecation", "true")
+			header.Add("Link", "<"+VersionedPath(version, unversioned)+`>; rel="successor-version"`)
+		}
+		r2 := r.WithContext(context.WithValue(r.Context(), apiVersionContextKey{}, version))
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = unversioned
+		r2.URL.RawPath = rawUnversioned
+		handler.ServeHTTP(w, r2)
+	})
+}
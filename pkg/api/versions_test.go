@@ -0,0 +1,148 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunVersionTests() {
+	Describe("API versions", func() {
+		echoHandlers := func(version string) func(responder Responder) map[string]http.HandlerFunc {
+			return func(responder Responder) map[string]http.HandlerFunc {
+				echo := func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprintf(w, "%s %s %s", version, APIVersionOfRequest(r), r.URL.Path)
+				}
+				return map[string]http.HandlerFunc{"/model": echo, "/image/": echo}
+			}
+		}
+		serve := func(mux http.Handler, method string, path string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+			return recorder
+		}
+		versionedRequests := func(version string, route string, deprecated string) float64 {
+			metric := &dto.Metric{}
+			counter := versionedRequestCounter.With(prometheus.Labels{"version": version, "route": route, "method": "GET", "deprecated": deprecated})
+			Expect(counter.Write(metric)).To(BeNil())
+			return metric.GetCounter().GetValue()
+		}
+
+		It("splits versioned paths", func() {
+			for path, expected := range map[string][2]string{
+				"/model":              {"", "/model"},
+				"/api/v1/model":       {"v1", "/model"},
+				"/api/v2/image/abc":   {"v2", "/image/abc"},
+				"/api/v1":             {"v1", "/"},
+				"/apiary/model":       {"", "/apiary/model"},
+				"/api/v1/":            {"v1", "/"},
+				"/api/v1/scanresults": {"v1", "/scanresults"},
+			} {
+				version, route := UnversionedPath(path)
+				Expect([2]string{version, route}).To(Equal(expected), path)
+			}
+		})
+
+		It("routes each path form to the right version's handlers", func() {
+			mux := http.NewServeMux()
+			mountAPIVersions(mux, NewMockResponder(), []*apiVersion{
+				{name: "v1", handlers: echoHandlers("v1")},
+				{name: "v2", handlers: echoHandlers("v2")},
+			})
+			matrix := []struct {
+				path       string
+				body       string
+				deprecated bool
+			}{
+				{path: "/model", body: "v1 v1 /model", deprecated: true},
+				{path: "/api/v1/model", body: "v1 v1 /model"},
+				{path: "/api/v2/model", body: "v2 v2 /model"},
+				{path: "/image/abc", body: "v1 v1 /image/abc", deprecated: true},
+				{path: "/api/v1/image/abc", body: "v1 v1 /image/abc"},
+				{path: "/api/v2/image/abc", body: "v2 v2 /image/abc"},
+			}
+			for _, row := range matrix {
+				recorder := serve(mux, "GET", row.path)
+				Expect(recorder.Code).To(Equal(200), row.path)
+				Expect(recorder.Body.String()).To(Equal(row.body), row.path)
+				if row.deprecated {
+					Expect(recorder.Header().Get("Deprecation")).To(Equal("true"), row.path)
+					Expect(recorder.Header().Get("Link")).To(Equal(`<`+VersionedPath("v1", row.path)+`>; rel="successor-version"`), row.path)
+				} else {
+					Expect(recorder.Header().Get("Deprecation")).To(Equal(""), row.path)
+				}
+			}
+			// only the legacy version gets unversioned aliases
+			Expect(serve(mux, "GET", "/api/v3/model").Code).To(Equal(404))
+		})
+
+		It("serves every v1 operation from both path forms", func() {
+			responder := NewMockResponder()
+			Expect(responder.AddImage(Image{Repository: "repo", Tag: "tag", Sha: "abc"})).To(BeNil())
+			mux := http.NewServeMux()
+			setupHandlers(mux, responder)
+			for _, op := range operations {
+				if op.method != "GET" || op.streaming {
+					continue
+				}
+				route := strings.Replace(op.path, "{sha}", "abc", 1)
+				legacy := serve(mux, op.method, route)
+				versioned := serve(mux, op.method, VersionedPath("v1", route))
+				Expect(versioned.Code).To(Equal(legacy.Code), route)
+				Expect(versioned.Header().Get(APIVersionHeader)).To(Equal("v1"), route)
+				Expect(legacy.Header().Get(APIVersionHeader)).To(Equal("v1"), route)
+				Expect(versioned.Header().Get("content-type")).To(Equal(legacy.Header().Get("content-type")), route)
+			}
+		})
+
+		It("labels metrics by version, and by whether the path was deprecated", func() {
+			mux := http.NewServeMux()
+			mountAPIVersions(mux, NewMockResponder(), []*apiVersion{{name: "v1", handlers: echoHandlers("v1")}})
+			legacyBefore := versionedRequests("v1", "/model", "true")
+			versionedBefore := versionedRequests("v1", "/model", "false")
+			serve(mux, "GET", "/model")
+			serve(mux, "GET", "/api/v1/model")
+			serve(mux, "GET", "/api/v1/model")
+			Expect(versionedRequests("v1", "/model", "true") - legacyBefore).To(Equal(float64(1)))
+			Expect(versionedRequests("v1", "/model", "false") - versionedBefore).To(Equal(float64(2)))
+		})
+
+		It("applies auth and its exemptions to both path forms", func() {
+			mux := http.NewServeMux()
+			mountAPIVersions(mux, NewMockResponder(), []*apiVersion{{name: "v1", handlers: echoHandlers("v1")}})
+			handler := NewTokenAuthenticator([]string{"abc"}, []string{"/image/abc"}).Wrap(mux)
+			Expect(serve(handler, "GET", "/model").Code).To(Equal(401))
+			Expect(serve(handler, "GET", "/api/v1/model").Code).To(Equal(401))
+			Expect(serve(handler, "GET", "/image/abc").Code).To(Equal(200))
+			Expect(serve(handler, "GET", "/api/v1/image/abc").Code).To(Equal(200))
+		})
+	})
+}
@@ -32,7 +32,7 @@ import (
 func APIImageToCoreImage(apiImage api.Image) (*model.Image, error) {
 	sha, err := model.NewDockerImageSha(apiImage.Sha)
 	if err != nil {
-		return nil, err
+		return nil, &api.ValidationError{Message: err.Error()}
 	}
 	priority := 0
 	if apiImage.Priority != nil {
@@ -63,5 +63,7 @@ func APIPodToCorePod(apiPod api.Pod) (*model.Pod, error) {
 		}
 		containers = append(containers, *container)
 	}
-	return model.NewPod(apiPod.Name, apiPod.UID, apiPod.Namespace, containers), nil
+	pod := model.NewPod(apiPod.Name, apiPod.UID, apiPod.Namespace, containers)
+	pod.Labels = apiPod.Labels
+	return pod, nil
 }
@@ -23,9 +23,13 @@ package core
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/blackducksoftware/perceptor/pkg/api"
+	m "github.com/blackducksoftware/perceptor/pkg/core/model"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -37,6 +41,9 @@ type HubConfig struct {
 	Port                int
 	ConcurrentScanLimit int
 	TotalScanLimit      int
+	// NamespaceConcurrentScanLimits optionally caps the number of concurrent
+	// scans of images from each namespace; namespaces not listed are unlimited.
+	NamespaceConcurrentScanLimits map[string]int
 }
 
 // Timings ...
@@ -46,6 +53,7 @@ type Timings struct {
 	ModelMetricsPauseSeconds       int
 	UnknownImagePauseMilliseconds  int
 	HubClientTimeoutMilliseconds   int
+	ScanLeaseTTLSeconds            int
 }
 
 // ClientTimeout ...
@@ -68,6 +76,15 @@ func (t *Timings) ModelMetricsPause() time.Duration {
 	return time.Duration(t.ModelMetricsPauseSeconds) * time.Second
 }
 
+// ScanLeaseTTL is how long scan clients have to acknowledge the images they're
+// handed; defaults to a minute.
+func (t *Timings) ScanLeaseTTL() time.Duration {
+	if t.ScanLeaseTTLSeconds <= 0 {
+		return m.DefaultScanLeaseTTL
+	}
+	return time.Duration(t.ScanLeaseTTLSeconds) * time.Second
+}
+
 // UnknownImagePause ...
 func (t *Timings) UnknownImagePause() time.Duration {
 	return time.Duration(t.UnknownImagePauseMilliseconds) * time.Millisecond
@@ -78,6 +95,233 @@ type PerceptorConfig struct {
 	Timings     *Timings
 	UseMockMode bool
 	Port        int
+	// ListenAddress is the host or IP the API binds, such as the pod IP, or
+	// localhost behind a sidecar; empty binds every interface.  Reloading
+	// the config with a different address or port moves the listener.
+	ListenAddress string
+	// DisableHTTP2 serves only HTTP/1.1.  Otherwise HTTP/2 is negotiated
+	// with ALPN over TLS, and spoken with prior knowledge (h2c) without it.
+	DisableHTTP2 bool
+	// JournalSize is the number of recent model actions to keep in memory;
+	// 0 disables the journal.
+	JournalSize int
+	// JournalPath, if set, is a file that journal entries are appended to.
+	JournalPath string
+	// AuditLogSize is the number of recent mutating requests to keep in
+	// memory for GET /audit; defaults to 1000.
+	AuditLogSize int
+	// AuditLogPath, if set, is a file that audit entries are appended to.
+	AuditLogPath string
+	// MaxTrackedSources caps the number of sources with their own traffic
+	// statistics and metric labels; defaults to 100.
+	MaxTrackedSources int
+	// AuthTokensEnvVar, if set, names an environment variable holding a
+	// comma-separated list of bearer tokens; every HTTP request must carry
+	// one of them, except those to UnauthenticatedPaths.  These are given
+	// without an /api/<version> prefix, and cover every version of the API.
+	AuthTokensEnvVar     string
+	UnauthenticatedPaths []string
+	// TLS, if set, serves the API over HTTPS
+	TLS *TLSConfig
+	// Health configures the /healthz and /readyz checks
+	Health *HealthConfig
+	// LegacyTextErrors sends plain-text error bodies to clients which don't
+	// accept JSON.  It's deprecated, and will be removed.
+	LegacyTextErrors bool
+	// Compression configures gzip for API responses; it's on by default.
+	Compression *CompressionConfig
+	// RateLimits configures per-client rate limits; they're on by default.
+	RateLimits *RateLimitConfig
+	// CORS, if set, lets browser pages from other origins call the API
+	CORS *api.CORSPolicy
+	// ShutdownDrainSeconds is how long in-flight requests get to finish on
+	// SIGTERM; defaults to 30 seconds.
+	ShutdownDrainSeconds int
+	// NextImageLogSampleRate logs one in N successful /nextimage requests,
+	// which scanners poll constantly; defaults to 100, and 1 logs them all.
+	NextImageLogSampleRate int
+	// ServerLimits caps request bodies, and sets the HTTP server's timeouts
+	ServerLimits *ServerLimitsConfig
+	// RemovedRoutes are old paths of renamed routes, such as "/sethubs",
+	// which are no longer to be served, ahead of their removal from the code.
+	RemovedRoutes []string
+}
+
+func (config *Config) requestLogSampleRates() map[string]int {
+	rate := api.DefaultNextImageLogSampleRate
+	if config.Perceptor != nil && config.Perceptor.NextImageLogSampleRate > 0 {
+		rate = config.Perceptor.NextImageLogSampleRate
+	}
+	return map[string]int{"/nextimage": rate}
+}
+
+func (config *Config) listenConfig() api.ListenConfig {
+	if config.Perceptor == nil {
+		return api.ListenConfig{HTTP2: true}
+	}
+	return api.ListenConfig{
+		Address: config.Perceptor.ListenAddress,
+		Port:    config.Perceptor.Port,
+		HTTP2:   !config.Perceptor.DisableHTTP2,
+	}
+}
+
+func (config *Config) auditLog() (*api.AuditLog, error) {
+	if config.Perceptor == nil {
+		return api.NewAuditLog(api.DefaultAuditLogSize, "")
+	}
+	size := config.Perceptor.AuditLogSize
+	if size <= 0 {
+		size = api.DefaultAuditLogSize
+	}
+	return api.NewAuditLog(size, config.Perceptor.AuditLogPath)
+}
+
+func (config *Config) maxTrackedSources() int {
+	if config.Perceptor == nil || config.Perceptor.MaxTrackedSources <= 0 {
+		return api.DefaultMaxTrackedSources
+	}
+	return config.Perceptor.MaxTrackedSources
+}
+
+func (config *Config) shutdownDrain() time.Duration {
+	if config.Perceptor == nil || config.Perceptor.ShutdownDrainSeconds <= 0 {
+		return api.DefaultShutdownDrain
+	}
+	return time.Duration(config.Perceptor.ShutdownDrainSeconds) * time.Second
+}
+
+// RateLimitConfig sets the limit for each class of routes.  Unset limits
+// get generous defaults; scanners get their own, so that perceivers can't
+// starve them.
+type RateLimitConfig struct {
+	Disabled bool
+	Mutating *api.RateLimit
+	Read     *api.RateLimit
+	Scanner  *api.RateLimit
+}
+
+func (config *Config) rateLimitConfig() *RateLimitConfig {
+	if config.Perceptor == nil || config.Perceptor.RateLimits == nil {
+		return &RateLimitConfig{}
+	}
+	return config.Perceptor.RateLimits
+}
+
+func (rlc *RateLimitConfig) limits() map[api.RouteClass]api.RateLimit {
+	limits := map[api.RouteClass]api.RateLimit{
+		api.RouteClassMutating: {RequestsPerSecond: 10, Burst: 50},
+		api.RouteClassRead:     {RequestsPerSecond: 50, Burst: 200},
+		api.RouteClassScanner:  {RequestsPerSecond: 100, Burst: 500},
+	}
+	if rlc.Mutating != nil {
+		limits[api.RouteClassMutating] = *rlc.Mutating
+	}
+	if rlc.Read != nil {
+		limits[api.RouteClassRead] = *rlc.Read
+	}
+	if rlc.Scanner != nil {
+		limits[api.RouteClassScanner] = *rlc.Scanner
+	}
+	return limits
+}
+
+// ServerLimitsConfig ...  Unset values get defaults: bodies are capped at
+// 1MB, except for /allpods, /allimages and /images, which get 64MB.
+type ServerLimitsConfig struct {
+	MaxBodyBytes     int64
+	MaxBulkBodyBytes int64
+	// ReadHeaderTimeoutSeconds and ReadTimeoutSeconds cut off clients which
+	// are slow to send their requests.
+	ReadHeaderTimeoutSeconds int
+	ReadTimeoutSeconds       int
+	// WriteTimeoutSeconds must be longer than scanners wait on /nextimage.
+	WriteTimeoutSeconds int
+	IdleTimeoutSeconds  int
+}
+
+func (config *Config) serverLimitsConfig() *ServerLimitsConfig {
+	if config.Perceptor == nil || config.Perceptor.ServerLimits == nil {
+		return &ServerLimitsConfig{}
+	}
+	return config.Perceptor.ServerLimits
+}
+
+func (slc *ServerLimitsConfig) bodyLimits() api.BodyLimits {
+	return api.BodyLimits{Default: slc.MaxBodyBytes, Bulk: slc.MaxBulkBodyBytes}
+}
+
+func (slc *ServerLimitsConfig) timeouts() api.ServerTimeouts {
+	return api.ServerTimeouts{
+		ReadHeader: time.Duration(slc.ReadHeaderTimeoutSeconds) * time.Second,
+		Read:       time.Duration(slc.ReadTimeoutSeconds) * time.Second,
+		Write:      time.Duration(slc.WriteTimeoutSeconds) * time.Second,
+		Idle:       time.Duration(slc.IdleTimeoutSeconds) * time.Second,
+	}
+}
+
+// CompressionConfig ...
+type CompressionConfig struct {
+	Disabled bool
+	// MinSizeBytes is the smallest response to compress; defaults to 1024.
+	MinSizeBytes int
+}
+
+func (config *Config) compressionConfig() *CompressionConfig {
+	if config.Perceptor == nil || config.Perceptor.Compression == nil {
+		return &CompressionConfig{}
+	}
+	return config.Perceptor.Compression
+}
+
+func (cc *CompressionConfig) minSize() int {
+	if cc.MinSizeBytes <= 0 {
+		return api.DefaultGzipMinSize
+	}
+	return cc.MinSizeBytes
+}
+
+// HealthConfig ...
+type HealthConfig struct {
+	// ReducerTimeoutMilliseconds is how long the model may take to respond
+	// before perceptor is considered not alive; defaults to 5 seconds.
+	ReducerTimeoutMilliseconds int
+	// SkipHubClientCheck makes perceptor ready without any hub clients
+	SkipHubClientCheck bool
+	// SkipHubSyncCheck makes perceptor ready before the hubs' scans are fetched
+	SkipHubSyncCheck bool
+	// HubSyncDeadlineSeconds, if set, makes perceptor ready in degraded mode
+	// if the hubs' scans still haven't been fetched this long after startup.
+	HubSyncDeadlineSeconds int
+}
+
+func (config *Config) healthConfig() *HealthConfig {
+	if config.Perceptor == nil || config.Perceptor.Health == nil {
+		return &HealthConfig{}
+	}
+	return config.Perceptor.Health
+}
+
+func (hc *HealthConfig) reducerTimeout() time.Duration {
+	if hc.ReducerTimeoutMilliseconds <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(hc.ReducerTimeoutMilliseconds) * time.Millisecond
+}
+
+// TLSConfig configures HTTPS for the API.  Either CertFile and KeyFile, or
+// CertPEM and KeyPEM, must be set; certificates loaded from files are
+// reloaded when the files change, or on SIGHUP.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	CertPEM  string
+	KeyPEM   string
+	// ClientCAFile, if set, is a CA bundle used to require client certificates
+	ClientCAFile string
+	// HTTPRedirectPort, if set, serves redirects to HTTPS on that port.
+	// Otherwise, plain HTTP requests are refused.
+	HTTPRedirectPort int
 }
 
 // Config ...
@@ -90,12 +334,13 @@ type Config struct {
 func (config *Config) model() *api.ModelConfig {
 	return &api.ModelConfig{
 		Hub: &api.ModelHubConfig{
-			ClientTimeout:       *api.NewModelTime(config.Perceptor.Timings.ClientTimeout()),
-			ConcurrentScanLimit: config.Hub.ConcurrentScanLimit,
-			PasswordEnvVar:      config.Hub.PasswordEnvVar,
-			Port:                config.Hub.Port,
-			TotalScanLimit:      config.Hub.TotalScanLimit,
-			User:                config.Hub.User,
+			ClientTimeout:                 *api.NewModelTime(config.Perceptor.Timings.ClientTimeout()),
+			ConcurrentScanLimit:           config.Hub.ConcurrentScanLimit,
+			NamespaceConcurrentScanLimits: config.Hub.NamespaceConcurrentScanLimits,
+			PasswordEnvVar:                config.Hub.PasswordEnvVar,
+			Port:                          config.Hub.Port,
+			TotalScanLimit:                config.Hub.TotalScanLimit,
+			User:                          config.Hub.User,
 		},
 		LogLevel: config.LogLevel,
 		Port:     config.Perceptor.Port,
@@ -104,10 +349,33 @@ func (config *Config) model() *api.ModelConfig {
 			ModelMetricsPause:         *api.NewModelTime(config.Perceptor.Timings.ModelMetricsPause()),
 			StalledScanClientTimeout:  *api.NewModelTime(config.Perceptor.Timings.StalledScanClientTimeout()),
 			UnknownImagePause:         *api.NewModelTime(config.Perceptor.Timings.UnknownImagePause()),
+			ScanLeaseTTL:              *api.NewModelTime(config.Perceptor.Timings.ScanLeaseTTL()),
 		},
 	}
 }
 
+// GetAuthTokens returns nil if authentication is disabled.
+func (config *Config) GetAuthTokens() ([]string, error) {
+	if config.Perceptor.AuthTokensEnvVar == "" {
+		return nil, nil
+	}
+	value, ok := os.LookupEnv(config.Perceptor.AuthTokensEnvVar)
+	if !ok {
+		return nil, fmt.Errorf("cannot find auth tokens: environment variable %s not found", config.Perceptor.AuthTokensEnvVar)
+	}
+	tokens := []string{}
+	for _, token := range strings.Split(value, ",") {
+		token = strings.TrimSpace(token)
+		if token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("environment variable %s contains no auth tokens", config.Perceptor.AuthTokensEnvVar)
+	}
+	return tokens, nil
+}
+
 // GetLogLevel .....
 func (config *Config) GetLogLevel() (log.Level, error) {
 	return log.ParseLevel(config.LogLevel)
@@ -68,6 +68,7 @@ func (cm *ConfigManager) GetConfig() (*Config, error) {
 		viper.BindEnv("Timings_ModelMetricsPauseSeconds")
 		viper.BindEnv("Timings_StalledScanClientTimeoutHours")
 		viper.BindEnv("Timings_UnknownImagePauseMilliseconds")
+		viper.BindEnv("Timings_ScanLeaseTTLSeconds")
 
 		viper.BindEnv("Hub_Hosts")
 		viper.BindEnv("Hub_User")
@@ -79,6 +80,8 @@ func (cm *ConfigManager) GetConfig() (*Config, error) {
 
 		viper.BindEnv("LogLevel")
 		viper.BindEnv("Port")
+		viper.BindEnv("ListenAddress")
+		viper.BindEnv("DisableHTTP2")
 		viper.BindEnv("UseMockMode")
 
 		viper.AutomaticEnv()
@@ -0,0 +1,177 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package core
+
+import (
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+// names of the settings which can change at runtime
+const (
+	settingConcurrentScanLimit           = "ConcurrentScanLimit"
+	settingHubHosts                      = "HubHosts"
+	settingLogLevel                      = "LogLevel"
+	settingNamespaceConcurrentScanLimits = "NamespaceConcurrentScanLimits"
+)
+
+// sources of setting changes
+const (
+	settingSourceConfig       = "config"
+	settingSourceConfigReload = "config reload"
+	settingSourceAPI          = "api"
+)
+
+// ConfigRegistry tracks the configuration perceptor started with, and the
+// settings which have been changed since.  It's updated as changes are
+// applied, so that it reflects what perceptor is doing, rather than what the
+// config file says.
+type ConfigRegistry struct {
+	mutex    sync.Mutex
+	config   *Config
+	settings map[string]*api.RuntimeSetting
+	now      func() time.Time
+}
+
+// NewConfigRegistry .....
+func NewConfigRegistry(config *Config) *ConfigRegistry {
+	cr := &ConfigRegistry{config: config, settings: map[string]*api.RuntimeSetting{}, now: time.Now}
+	for name, value := range reloadableSettings(config) {
+		cr.Set(name, value, settingSourceConfig)
+	}
+	cr.Set(settingLogLevel, config.LogLevel, settingSourceConfig)
+	if config.Hub != nil {
+		cr.Set(settingConcurrentScanLimit, config.Hub.ConcurrentScanLimit, settingSourceConfig)
+	}
+	return cr
+}
+
+// reloadableSettings are the settings that Perceptor.UpdateConfig always
+// applies; the log level is only applied if it's valid.
+func reloadableSettings(config *Config) map[string]interface{} {
+	settings := map[string]interface{}{}
+	if config.Hub != nil {
+		settings[settingHubHosts] = redactHosts(config.Hub.Hosts)
+		settings[settingNamespaceConcurrentScanLimits] = config.Hub.NamespaceConcurrentScanLimits
+	}
+	return settings
+}
+
+// Set records a setting's new value; it's a no-op if the value didn't change.
+func (cr *ConfigRegistry) Set(name string, value interface{}, source string) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	if setting, ok := cr.settings[name]; ok && reflect.DeepEqual(setting.Value, value) {
+		return
+	}
+	cr.settings[name] = &api.RuntimeSetting{Value: value, ChangedAt: cr.now(), Source: source}
+}
+
+// DidReloadConfig records the settings applied from a reloaded config file.
+func (cr *ConfigRegistry) DidReloadConfig(config *Config) {
+	for name, value := range reloadableSettings(config) {
+		cr.Set(name, value, settingSourceConfigReload)
+	}
+}
+
+// RuntimeConfig .....
+func (cr *ConfigRegistry) RuntimeConfig() *api.RuntimeConfig {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	config := cr.config
+	runtimeConfig := &api.RuntimeConfig{
+		RateLimits:    map[string]api.RateLimit{},
+		ShutdownDrain: api.NewModelTime(config.shutdownDrain()),
+		Features:      map[string]bool{},
+		Settings:      map[string]*api.RuntimeSetting{},
+	}
+	if hub := config.Hub; hub != nil {
+		runtimeConfig.Hub = &api.RuntimeHubConfig{
+			User:           hub.User,
+			PasswordEnvVar: hub.PasswordEnvVar,
+			Port:           hub.Port,
+			TotalScanLimit: hub.TotalScanLimit,
+		}
+	}
+	if rateLimits := config.rateLimitConfig(); !rateLimits.Disabled {
+		for class, limit := range rateLimits.limits() {
+			runtimeConfig.RateLimits[class.String()] = limit
+		}
+	}
+	if compression := config.compressionConfig(); !compression.Disabled {
+		runtimeConfig.CompressionMinSizeBytes = compression.minSize()
+	}
+	if perceptorConfig := config.Perceptor; perceptorConfig != nil {
+		if timings := perceptorConfig.Timings; timings != nil {
+			runtimeConfig.Timings = &api.ModelTimings{
+				CheckForStalledScansPause: *api.NewModelTime(timings.CheckForStalledScansPause()),
+				ModelMetricsPause:         *api.NewModelTime(timings.ModelMetricsPause()),
+				StalledScanClientTimeout:  *api.NewModelTime(timings.StalledScanClientTimeout()),
+				UnknownImagePause:         *api.NewModelTime(timings.UnknownImagePause()),
+				ScanLeaseTTL:              *api.NewModelTime(timings.ScanLeaseTTL()),
+			}
+		}
+		runtimeConfig.Port = perceptorConfig.Port
+		runtimeConfig.ListenAddress = perceptorConfig.ListenAddress
+		runtimeConfig.CORS = perceptorConfig.CORS
+		runtimeConfig.UnauthenticatedPaths = perceptorConfig.UnauthenticatedPaths
+		runtimeConfig.Features["mockMode"] = perceptorConfig.UseMockMode
+		runtimeConfig.Features["auth"] = perceptorConfig.AuthTokensEnvVar != ""
+		runtimeConfig.Features["tls"] = perceptorConfig.TLS != nil
+		runtimeConfig.Features["clientCertificates"] = perceptorConfig.TLS != nil && perceptorConfig.TLS.ClientCAFile != ""
+		runtimeConfig.Features["journal"] = perceptorConfig.JournalSize > 0 || perceptorConfig.JournalPath != ""
+		runtimeConfig.Features["legacyTextErrors"] = perceptorConfig.LegacyTextErrors
+		runtimeConfig.Features["http2"] = !perceptorConfig.DisableHTTP2
+	}
+	runtimeConfig.Features["rateLimits"] = len(runtimeConfig.RateLimits) > 0
+	runtimeConfig.Features["compression"] = runtimeConfig.CompressionMinSizeBytes > 0
+	runtimeConfig.Features["cors"] = runtimeConfig.CORS != nil
+	for name, setting := range cr.settings {
+		copied := *setting
+		runtimeConfig.Settings[name] = &copied
+	}
+	// the settings are more up to date than the config perceptor started with
+	runtimeConfig.LogLevel = cr.settings[settingLogLevel].Value.(string)
+	if runtimeConfig.Hub != nil {
+		runtimeConfig.Hub.Hosts = cr.settings[settingHubHosts].Value.([]string)
+		runtimeConfig.Hub.NamespaceConcurrentScanLimits = cr.settings[settingNamespaceConcurrentScanLimits].Value.(map[string]int)
+		runtimeConfig.Hub.ConcurrentScanLimit = cr.settings[settingConcurrentScanLimit].Value.(int)
+	}
+	return runtimeConfig
+}
+
+// redactHosts drops any credentials embedded in hub URLs.
+func redactHosts(hosts []string) []string {
+	redacted := make([]string, len(hosts))
+	for i, host := range hosts {
+		redacted[i] = host
+		if hostURL, err := url.Parse(host); err == nil && hostURL.User != nil {
+			hostURL.User = url.User("redacted")
+			redacted[i] = hostURL.String()
+		}
+	}
+	return redacted
+}
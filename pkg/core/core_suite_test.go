@@ -34,5 +34,10 @@ func TestModel(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunTestPerceptor()
 	RunTestMetrics()
+	RunConfigRegistryTests()
+	RunPodValidationTests()
+	RunMetricsSummaryTests()
+	RunHubManagerTests()
+	RunFindingsExportTests()
 	RunSpecs(t, "core suite")
 }
@@ -22,9 +22,13 @@ under the License.
 package core
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	"github.com/blackducksoftware/perceptor/pkg/api"
 
@@ -90,6 +94,60 @@ func RunPerceptor(configPath string) {
 		panic(err)
 	}
 
+	log.Infof("instantiated perceptor: %+v", perceptor)
+	api.SetupHTTPServer(perceptor, config.Perceptor.RemovedRoutes...)
+
+	var handler http.Handler = http.DefaultServeMux
+	authTokens, err := config.GetAuthTokens()
+	if err != nil {
+		log.Errorf("unable to load auth tokens: %s", err.Error())
+		panic(err)
+	}
+	if authTokens != nil {
+		log.Infof("requiring bearer tokens for HTTP requests, except for %v", config.Perceptor.UnauthenticatedPaths)
+		handler = api.NewTokenAuthenticator(authTokens, config.Perceptor.UnauthenticatedPaths).Wrap(handler)
+	}
+	if rateLimits := config.rateLimitConfig(); !rateLimits.Disabled {
+		handler = api.NewRateLimiter(rateLimits.limits()).Wrap(handler)
+	}
+	if compression := config.compressionConfig(); !compression.Disabled {
+		handler = api.GzipHandler(handler, compression.minSize())
+	}
+	if config.Perceptor.CORS != nil {
+		log.Infof("allowing cross-origin requests from %v", config.Perceptor.CORS.AllowedOrigins)
+	}
+	handler = api.CORSHandler(handler, config.Perceptor.CORS)
+	serverLimits := config.serverLimitsConfig()
+	handler = api.BodyLimitHandler(handler, serverLimits.bodyLimits())
+	handler = api.AuditHandler(handler, perceptor.AuditLog())
+	handler = api.SourceStatsHandler(handler, perceptor.SourceStats())
+	handler = api.RequestLogHandler(handler, config.requestLogSampleRates())
+	handler = api.RouteMetricsHandler(handler)
+
+	var tlsConfig *tls.Config
+	if config.Perceptor.TLS != nil {
+		tlsConfig, err = setupTLS(config.Perceptor.TLS, stop)
+		if err != nil {
+			log.Errorf("unable to set up TLS: %s", err.Error())
+			panic(err)
+		}
+	}
+	listen := config.listenConfig()
+	server := api.NewAPIServer(handler, serverLimits.timeouts(), tlsConfig, config.shutdownDrain())
+	if err := server.Start(listen); err != nil {
+		log.Errorf("unable to start API server: %s", err.Error())
+		panic(err)
+	}
+	servers := []*api.APIServer{server}
+	if config.Perceptor.TLS != nil && config.Perceptor.TLS.HTTPRedirectPort != 0 {
+		redirectServer := api.NewAPIServer(server.RedirectToHTTPS(), serverLimits.timeouts(), nil, config.shutdownDrain())
+		if err := redirectServer.Start(api.ListenConfig{Address: listen.Address, Port: config.Perceptor.TLS.HTTPRedirectPort}); err != nil {
+			log.Errorf("unable to start HTTP redirect server: %s", err.Error())
+			panic(err)
+		}
+		servers = append(servers, redirectServer)
+	}
+
 	go func() {
 		updateConfig := configManager.DidReadConfig()
 		for {
@@ -98,17 +156,64 @@ func RunPerceptor(configPath string) {
 				return
 			case newConfig := <-updateConfig:
 				perceptor.UpdateConfig(newConfig)
+				if err := server.Restart(newConfig.listenConfig()); err != nil {
+					log.Errorf("unable to move API server, still listening on %s: %s", server.Addr(), err.Error())
+				}
 			}
 		}
 	}()
 
-	log.Infof("instantiated perceptor: %+v", perceptor)
-	api.SetupHTTPServer(perceptor)
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, syscall.SIGTERM, syscall.SIGINT)
+	log.Infof("received signal %s, shutting down", <-shutdown)
+	signal.Stop(shutdown)
+
+	// stop advertising readiness first, then drain the servers, and only then
+	// stop everything else -- so in-flight scan results aren't cut off
+	perceptor.BeginShutdown()
+	var wg sync.WaitGroup
+	for _, s := range servers {
+		wg.Add(1)
+		go func(s *api.APIServer) {
+			defer wg.Done()
+			if err := s.Shutdown(config.shutdownDrain()); err != nil {
+				log.Errorf("unable to shut down HTTP server gracefully: %s", err.Error())
+			}
+		}(s)
+	}
+	wg.Wait()
+	perceptor.Stop()
+	close(stop)
+	log.Info("stopped")
+}
 
-	addr := fmt.Sprintf(":%d", config.Perceptor.Port)
+func setupTLS(config *TLSConfig, stop <-chan struct{}) (*tls.Config, error) {
+	if config.CertFile == "" && config.KeyFile == "" {
+		getCertificate, err := api.StaticCertificate(config.CertPEM, config.KeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		return api.NewServerTLSConfig(getCertificate, config.ClientCAFile)
+	}
+	reloader, err := api.NewCertificateReloader(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
 	go func() {
-		log.Infof("starting HTTP server on port %d", config.Perceptor.Port)
-		http.ListenAndServe(addr, nil)
+		for {
+			select {
+			case <-stop:
+				signal.Stop(sighup)
+				return
+			case <-sighup:
+				log.Infof("received SIGHUP, reloading TLS certificate")
+				if err := reloader.Reload(); err != nil {
+					log.Errorf("unable to reload TLS certificate: %s", err.Error())
+				}
+			}
+		}
 	}()
-	<-stop
+	return api.NewServerTLSConfig(reloader.GetCertificate, config.ClientCAFile)
 }
@@ -0,0 +1,93 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	"github.com/blackducksoftware/perceptor/pkg/core/model"
+	"github.com/blackducksoftware/perceptor/pkg/hub"
+)
+
+// exportFindings goes through the matching pods of a core model snapshot in
+// order, building and emitting one finding at a time, so that big clusters
+// don't need the whole report in memory.  Images which haven't been
+// scanned yet are left out.
+func exportFindings(coreModel *api.CoreModel, filter *api.ScanResultsQuery, emit func(*api.Finding) error) error {
+	names := []string{}
+	for name, pod := range coreModel.Pods {
+		if filter.Namespace != "" && pod.Namespace != filter.Namespace {
+			continue
+		}
+		if filter.MatchesPod(pod.Name, pod.Labels) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pod := coreModel.Pods[name]
+		shas := map[string]bool{}
+		for _, container := range pod.Containers {
+			if shas[container.Image.Sha] {
+				continue
+			}
+			shas[container.Image.Sha] = true
+			finding := podImageFinding(pod, container.Image, coreModel.Images[container.Image.Sha])
+			if finding == nil {
+				continue
+			}
+			if err := emit(finding); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func podImageFinding(pod *api.Pod, image api.Image, imageInfo *api.ModelImageInfo) *api.Finding {
+	if imageInfo == nil || imageInfo.ScanStatus != model.ScanStatusComplete.String() {
+		return nil
+	}
+	scanResults, ok := imageInfo.ScanResults.(*hub.ScanResults)
+	if !ok || scanResults == nil {
+		return nil
+	}
+	imageName := image.Repository
+	if image.Tag != "" {
+		imageName = fmt.Sprintf("%s:%s", image.Repository, image.Tag)
+	}
+	riskProfile := scanResults.RiskProfile
+	return &api.Finding{
+		Pod:          pod.Name,
+		Namespace:    pod.Namespace,
+		Image:        imageName,
+		Sha:          image.Sha,
+		Critical:     riskProfile.VulnerabilityCount(hub.RiskProfileStatusCritical),
+		High:         riskProfile.VulnerabilityCount(hub.RiskProfileStatusHigh),
+		Medium:       riskProfile.VulnerabilityCount(hub.RiskProfileStatusMedium),
+		Low:          riskProfile.VulnerabilityCount(hub.RiskProfileStatusLow),
+		PolicyStatus: scanResults.OverallStatus().String(),
+		LastScanned:  scanResults.LastScanTime(),
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	"github.com/blackducksoftware/perceptor/pkg/core/model"
+	"github.com/blackducksoftware/perceptor/pkg/hub"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func findingsScanResults(critical int, high int) *hub.ScanResults {
+	return &hub.ScanResults{
+		RiskProfile: hub.RiskProfile{Categories: map[hub.RiskProfileCategory]hub.RiskProfileStatusCounts{
+			hub.RiskProfileCategoryVulnerability: {StatusCounts: map[hub.RiskProfileStatus]int{
+				hub.RiskProfileStatusCritical: critical,
+				hub.RiskProfileStatusHigh:     high,
+				hub.RiskProfileStatusLow:      7,
+			}},
+		}},
+		PolicyStatus: hub.PolicyStatus{OverallStatus: hub.PolicyStatusTypeInViolation},
+		ScanSummaries: []hub.ScanSummary{
+			{UpdatedAt: "2018-06-01T12:00:00.000Z"},
+			{UpdatedAt: "2018-06-02T12:00:00.000Z"},
+		},
+	}
+}
+
+func findingsPod(namespace string, name string, shas ...string) *api.Pod {
+	pod := &api.Pod{Name: name, Namespace: namespace, Labels: map[string]string{"app": name}}
+	for _, sha := range shas {
+		pod.Containers = append(pod.Containers, api.Container{Name: sha, Image: api.Image{Repository: "repo/" + sha, Tag: "1.0", Sha: sha}})
+	}
+	return pod
+}
+
+func collectFindings(coreModel *api.CoreModel, filter *api.ScanResultsQuery) []*api.Finding {
+	findings := []*api.Finding{}
+	Expect(exportFindings(coreModel, filter, func(finding *api.Finding) error {
+		findings = append(findings, finding)
+		return nil
+	})).To(BeNil())
+	return findings
+}
+
+func RunFindingsExportTests() {
+	Describe("findings export", func() {
+		complete := model.ScanStatusComplete.String()
+
+		It("reports each scanned image of each matching pod, in order", func() {
+			coreModel := &api.CoreModel{
+				Pods: map[string]*api.Pod{
+					"ns2/pod3": findingsPod("ns2", "pod3", "sha1"),
+					"ns1/pod2": findingsPod("ns1", "pod2", "sha2", "sha1", "sha2"),
+					"ns1/pod1": findingsPod("ns1", "pod1", "sha3"),
+				},
+				Images: map[string]*api.ModelImageInfo{
+					"sha1": {ScanStatus: complete, ScanResults: findingsScanResults(1, 2)},
+					"sha2": {ScanStatus: complete, ScanResults: findingsScanResults(0, 4)},
+					"sha3": {ScanStatus: model.ScanStatusInQueue.String()},
+				},
+			}
+			findings := collectFindings(coreModel, &api.ScanResultsQuery{Namespace: "ns1"})
+			Expect(findings).To(Equal([]*api.Finding{
+				{Pod: "pod2", Namespace: "ns1", Image: "repo/sha2:1.0", Sha: "sha2", Critical: 0, High: 4, Low: 7, PolicyStatus: "IN_VIOLATION", LastScanned: "2018-06-02T12:00:00.000Z"},
+				{Pod: "pod2", Namespace: "ns1", Image: "repo/sha1:1.0", Sha: "sha1", Critical: 1, High: 2, Low: 7, PolicyStatus: "IN_VIOLATION", LastScanned: "2018-06-02T12:00:00.000Z"},
+			}))
+			Expect(collectFindings(coreModel, &api.ScanResultsQuery{})).To(HaveLen(3))
+		})
+
+		It("exports a large model without building the whole report", func() {
+			rows := 50000
+			coreModel := &api.CoreModel{Pods: map[string]*api.Pod{}, Images: map[string]*api.ModelImageInfo{}}
+			for i := 0; i < rows; i++ {
+				sha := fmt.Sprintf("sha%d", i)
+				pod := findingsPod(fmt.Sprintf("ns%d", i%100), fmt.Sprintf("pod%d", i), sha)
+				coreModel.Pods[pod.Namespace+"/"+pod.Name] = pod
+				coreModel.Images[sha] = &api.ModelImageInfo{ScanStatus: complete, ScanResults: findingsScanResults(i%3, i%5)}
+			}
+			var before, during runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			emitted := 0
+			err := exportFindings(coreModel, &api.ScanResultsQuery{}, func(finding *api.Finding) error {
+				emitted++
+				if emitted == rows/2 {
+					runtime.GC()
+					runtime.ReadMemStats(&during)
+				}
+				return nil
+			})
+			Expect(err).To(BeNil())
+			Expect(emitted).To(Equal(rows))
+			// the sorted pod names are all that's kept, at 16 bytes each
+			Expect(int64(during.HeapAlloc) - int64(before.HeapAlloc)).To(BeNumerically("<", 4<<20))
+		})
+
+		It("stops at the first error", func() {
+			coreModel := &api.CoreModel{
+				Pods:   map[string]*api.Pod{"ns1/pod1": findingsPod("ns1", "pod1", "sha1", "sha2")},
+				Images: map[string]*api.ModelImageInfo{"sha1": {ScanStatus: complete, ScanResults: findingsScanResults(0, 0)}, "sha2": {ScanStatus: complete, ScanResults: findingsScanResults(0, 0)}},
+			}
+			emitted := 0
+			err := exportFindings(coreModel, &api.ScanResultsQuery{}, func(finding *api.Finding) error {
+				emitted++
+				return fmt.Errorf("client went away")
+			})
+			Expect(err).To(MatchError("client went away"))
+			Expect(emitted).To(Equal(1))
+		})
+	})
+}
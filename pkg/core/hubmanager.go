@@ -23,31 +23,60 @@ package core
 
 import (
 	"fmt"
+	"os"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/blackducksoftware/hub-client-go/hubclient"
+	"github.com/blackducksoftware/perceptor/pkg/api"
 	"github.com/blackducksoftware/perceptor/pkg/hub"
 	log "github.com/sirupsen/logrus"
 )
 
-type hubClientCreator func(host string) (*hub.Hub, error)
+type hubClientCreator func(spec api.HubSpec) (*hub.Hub, error)
 
-func createMockHubClient(hubURL string) (*hub.Hub, error) {
+func createMockHubClient(spec api.HubSpec) (*hub.Hub, error) {
 	mockRawClient := hub.NewMockRawClient(false, []string{})
-	return hub.NewHub("mock-username", "mock-password", hubURL, mockRawClient, hub.DefaultTimings), nil
+	return hub.NewHub("mock-username", "mock-password", spec.Host, mockRawClient, hub.DefaultTimings), nil
 }
 
+// createHubClient uses the given username, password and port for hubs which
+// don't specify their own.
 func createHubClient(username string, password string, port int, httpTimeout time.Duration) hubClientCreator {
-	return func(host string) (*hub.Hub, error) {
-		baseURL := fmt.Sprintf("https://%s:%d", host, port)
+	return func(spec api.HubSpec) (*hub.Hub, error) {
+		hubUsername, hubPassword, hubPort := username, password, port
+		if spec.User != "" {
+			hubUsername = spec.User
+		}
+		if spec.PasswordEnvVar != "" {
+			var ok bool
+			hubPassword, ok = os.LookupEnv(spec.PasswordEnvVar)
+			if !ok {
+				return nil, fmt.Errorf("unable to get Hub password: environment variable %s not set", spec.PasswordEnvVar)
+			}
+		}
+		if spec.Port != 0 {
+			hubPort = spec.Port
+		}
+		baseURL := fmt.Sprintf("https://%s:%d", spec.Host, hubPort)
 		rawClient, err := hubclient.NewWithSession(baseURL, hubclient.HubClientDebugTimings, httpTimeout)
 		if err != nil {
 			return nil, err
 		}
-		return hub.NewHub(username, password, host, rawClient, hub.DefaultTimings), nil
+		return hub.NewHub(hubUsername, hubPassword, spec.Host, rawClient, hub.DefaultTimings), nil
 	}
 }
 
+// hubSpecs describes hubs which use perceptor's hub config.
+func hubSpecs(hosts []string) []api.HubSpec {
+	specs := make([]api.HubSpec, len(hosts))
+	for i, host := range hosts {
+		specs[i] = api.HubSpec{Host: host}
+	}
+	return specs
+}
+
 // Update is a wrapper around hub.Update which also tracks which Hub was the source.
 type Update struct {
 	HubURL string
@@ -56,7 +85,7 @@ type Update struct {
 
 // HubManagerInterface ...
 type HubManagerInterface interface {
-	SetHubs(hubURLs []string)
+	SetHubs(hubs []api.HubSpec) *api.SetHubsResult
 	HubClients() map[string]*hub.Hub
 	StartScanClient(hubURL string, scanName string) error
 	FinishScanClient(hubURL string, scanName string, err error) error
@@ -67,6 +96,8 @@ type HubManagerInterface interface {
 // HubManager ...
 type HubManager struct {
 	newHub hubClientCreator
+	// setHubsMutex keeps overlapping SetHubs calls from interleaving
+	setHubsMutex sync.Mutex
 	//
 	stop    <-chan struct{}
 	updates chan *Update
@@ -88,42 +119,50 @@ func NewHubManager(newHub hubClientCreator, stop <-chan struct{}) *HubManager {
 		didFetchCodeLocations: make(chan []string)}
 }
 
-// SetHubs ...
-func (hm *HubManager) SetHubs(hubURLs []string) {
+// SetHubs creates clients for the new hubs, and stops and removes the clients
+// of hubs which aren't listed.  Hubs which already have a client are left
+// alone, even if their spec has changed.
+func (hm *HubManager) SetHubs(hubs []api.HubSpec) *api.SetHubsResult {
+	hm.setHubsMutex.Lock()
+	defer hm.setHubsMutex.Unlock()
+	result := api.NewSetHubsResult()
 	newHubURLs := map[string]bool{}
-	for _, hubURL := range hubURLs {
-		newHubURLs[hubURL] = true
-	}
-	hubsToCreate := map[string]bool{}
-	for hubURL := range newHubURLs {
-		if _, ok := hm.hubs[hubURL]; !ok {
-			hubsToCreate[hubURL] = true
-		}
+	for _, spec := range hubs {
+		newHubURLs[spec.Host] = true
 	}
 	// 1. create new hubs
 	// TODO handle retries and failures intelligently
-	go func() {
-		for hubURL := range hubsToCreate {
-			err := hm.create(hubURL)
-			if err != nil {
-				log.Errorf("unable to create Hub client for %s: %s", hubURL, err.Error())
-			}
+	for _, spec := range hubs {
+		if _, ok := hm.hubs[spec.Host]; ok {
+			result.Unchanged = append(result.Unchanged, spec.Host)
+			continue
 		}
-	}()
+		err := hm.create(spec)
+		if err != nil {
+			log.Errorf("unable to create Hub client for %s: %s", spec.Host, err.Error())
+			result.Failed = append(result.Failed, api.SetHubsFailure{Host: spec.Host, Error: err.Error()})
+		} else {
+			result.Added = append(result.Added, spec.Host)
+		}
+	}
 	// 2. delete removed hubs
 	for hubURL, hub := range hm.hubs {
 		if _, ok := newHubURLs[hubURL]; !ok {
 			hub.Stop()
 			delete(hm.hubs, hubURL)
+			result.Removed = append(result.Removed, hubURL)
 		}
 	}
+	sort.Strings(result.Removed)
+	return result
 }
 
-func (hm *HubManager) create(hubURL string) error {
+func (hm *HubManager) create(spec api.HubSpec) error {
+	hubURL := spec.Host
 	if _, ok := hm.hubs[hubURL]; ok {
 		return fmt.Errorf("cannot create hub %s: already exists", hubURL)
 	}
-	hubClient, err := hm.newHub(hubURL)
+	hubClient, err := hm.newHub(spec)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,83 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	"github.com/blackducksoftware/perceptor/pkg/hub"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunHubManagerTests() {
+	Describe("HubManager.SetHubs", func() {
+		var stop chan struct{}
+		var manager *HubManager
+		BeforeEach(func() {
+			stop = make(chan struct{})
+			manager = NewHubManager(func(spec api.HubSpec) (*hub.Hub, error) {
+				if spec.Host == "broken" {
+					return nil, fmt.Errorf("planned failure")
+				}
+				return createMockHubClient(spec)
+			}, stop)
+		})
+		AfterEach(func() {
+			for _, hub := range manager.HubClients() {
+				hub.Stop()
+			}
+			close(stop)
+		})
+
+		It("reports added, removed, unchanged and failed hubs", func() {
+			result := manager.SetHubs(hubSpecs([]string{"hub1", "hub2"}))
+			Expect(result.Added).To(Equal([]string{"hub1", "hub2"}))
+			Expect(result.Unchanged).To(BeEmpty())
+
+			result = manager.SetHubs(hubSpecs([]string{"hub2", "hub3", "broken"}))
+			Expect(result.Added).To(Equal([]string{"hub3"}))
+			Expect(result.Removed).To(Equal([]string{"hub1"}))
+			Expect(result.Unchanged).To(Equal([]string{"hub2"}))
+			Expect(result.Failed).To(Equal([]api.SetHubsFailure{{Host: "broken", Error: "planned failure"}}))
+			Expect(manager.HubClients()).To(HaveLen(2))
+			Expect(manager.HubClients()).To(HaveKey("hub2"))
+			Expect(manager.HubClients()).To(HaveKey("hub3"))
+		})
+
+		It("serializes overlapping calls", func() {
+			done := make(chan *api.SetHubsResult)
+			for i := 0; i < 10; i++ {
+				go func() {
+					done <- manager.SetHubs(hubSpecs([]string{"hub1"}))
+				}()
+			}
+			added := 0
+			for i := 0; i < 10; i++ {
+				added += len((<-done).Added)
+			}
+			Expect(added).To(Equal(1))
+			Expect(manager.HubClients()).To(HaveLen(1))
+		})
+	})
+}
@@ -25,10 +25,32 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/blackducksoftware/perceptor/pkg/api"
 	model "github.com/blackducksoftware/perceptor/pkg/core/model"
+	"github.com/blackducksoftware/perceptor/pkg/hub"
+	"github.com/blackducksoftware/perceptor/pkg/util"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+var metricsRegistry = util.NewMetricsRegistry()
+
+func init() {
+	setupMetrics()
+}
+
+// ConfigureMetrics moves the metrics of the core and every package it uses
+// to the registry and namespace in `config`, so that perceptor can be
+// embedded in a program with metrics of its own.  It must be called before
+// anything records metrics.
+func ConfigureMetrics(config *util.MetricsConfig) {
+	util.ConfigureMetrics(config)
+	hub.ConfigureMetrics(config)
+	api.ConfigureMetrics(config)
+	model.ConfigureMetrics(config)
+	metricsRegistry.Configure(config)
+	setupMetrics()
+}
+
 const (
 	statusLabel           = "status"
 	vulnerabilitiesLabel  = "vulnerability_count"
@@ -48,6 +70,10 @@ var imageVulnerabilitiesGauge *prometheus.GaugeVec
 
 var eventCounter *prometheus.CounterVec
 
+var scanClientJobDuration *prometheus.HistogramVec
+var scanClientJobBytes *prometheus.HistogramVec
+var scanStageMedianAgeGauge *prometheus.GaugeVec
+
 // prometheus' terminology is so confusing ... a histogram isn't a histogram.  sometimes.
 var statusHistogram *prometheus.GaugeVec
 
@@ -61,7 +87,8 @@ func recordModelMetrics(modelMetrics *model.Metrics) {
 		model.ScanStatusInQueue,
 		model.ScanStatusRunningScanClient,
 		model.ScanStatusRunningHubScan,
-		model.ScanStatusComplete}
+		model.ScanStatusComplete,
+		model.ScanStatusFailed}
 	for _, key := range keys {
 		val := modelMetrics.ScanStatusCounts[key]
 		status := fmt.Sprintf("image_status_%s", key.String())
@@ -107,10 +134,40 @@ func recordModelMetrics(modelMetrics *model.Metrics) {
 		imagePolicyViolationsGauge.With(prometheus.Labels{policyViolationsLabel: value}).Set(float64(count))
 	}
 
+	for stage, age := range modelMetrics.MedianStageAges {
+		scanStageMedianAgeGauge.With(prometheus.Labels{"stage": stage}).Set(age.Seconds())
+	}
+
 	// TODO
 	// number of images without a pod pointing to them
 }
 
+func recordConcurrentScanLimit(limit int) {
+	statusGauge.With(prometheus.Labels{"name": "concurrent_scan_limit"}).Set(float64(limit))
+	recordEvent("scanScheduler", "set concurrent scan limit")
+}
+
+func recordScanClientJobTimings(timings *api.ScanClientJobTimings) {
+	size := model.ImageSizeClass(timings.TarSizeBytes)
+	phases := map[string]float64{
+		"pull":   timings.PullSeconds,
+		"scan":   timings.ScanSeconds,
+		"upload": timings.UploadSeconds,
+		"total":  timings.TotalSeconds,
+	}
+	for phase, seconds := range phases {
+		if seconds > 0 {
+			scanClientJobDuration.With(prometheus.Labels{"phase": phase, "size": size}).Observe(seconds)
+		}
+	}
+	if timings.TarSizeBytes > 0 {
+		scanClientJobBytes.With(prometheus.Labels{"name": "tar_size"}).Observe(float64(timings.TarSizeBytes))
+	}
+	if timings.PeakScratchBytes > 0 {
+		scanClientJobBytes.With(prometheus.Labels{"name": "peak_scratch"}).Observe(float64(timings.PeakScratchBytes))
+	}
+}
+
 // successful http requests received
 
 func recordAddPod() {
@@ -125,6 +182,14 @@ func recordDeletePod() {
 	handledHTTPRequest.With(prometheus.Labels{"path": "pod", "method": "DELETE", "code": "200"}).Inc()
 }
 
+func recordDeletePods() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "pods", "method": "DELETE", "code": "200"}).Inc()
+}
+
+func recordAddImages() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "images", "method": "POST", "code": "200"}).Inc()
+}
+
 func recordAddImage() {
 	handledHTTPRequest.With(prometheus.Labels{"path": "image", "method": "POST", "code": "200"}).Inc()
 }
@@ -145,10 +210,94 @@ func recordPostFinishedScan() {
 	handledHTTPRequest.With(prometheus.Labels{"path": "finishedscan", "method": "POST", "code": "200"}).Inc()
 }
 
+func recordAcknowledgeScanLease() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "nextimage/ack", "method": "POST", "code": "200"}).Inc()
+}
+
 func recordGetScanResults() {
 	handledHTTPRequest.With(prometheus.Labels{"path": "scanresults", "method": "GET", "code": "200"}).Inc()
 }
 
+func recordExportFindings() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "export/findings", "method": "GET", "code": "200"}).Inc()
+}
+
+func recordPodValidationWarning() {
+	recordEvent("api", "pod validation warning")
+}
+
+func recordPodValidationFailure() {
+	recordEvent("api", "pod validation failure")
+}
+
+func recordSubscribeScanResults() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "stream/scanresults", "method": "GET", "code": "200"}).Inc()
+}
+
+func recordGetConfig() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "config", "method": "GET", "code": "200"}).Inc()
+}
+
+func recordGetPods() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "pods", "method": "GET", "code": "200"}).Inc()
+}
+
+func recordGetAuditLog() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "audit", "method": "GET", "code": "200"}).Inc()
+}
+
+func recordGetImagesByName() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "image", "method": "GET", "code": "200"}).Inc()
+}
+
+func recordGetSourceStats() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "stats/sources", "method": "GET", "code": "200"}).Inc()
+}
+
+func recordGetPod() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "pod", "method": "GET", "code": "200"}).Inc()
+}
+
+func recordGetMetricsSummary() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "metrics/summary", "method": "GET", "code": "200"}).Inc()
+}
+
+func recordGetScanQueueStats() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "scanqueue/stats", "method": "GET", "code": "200"}).Inc()
+}
+
+func recordRequeueImage() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "image/requeue", "method": "POST", "code": "200"}).Inc()
+}
+
+func recordGetImage() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "image", "method": "GET", "code": "200"}).Inc()
+}
+
+func recordSetHubs() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "sethubs", "method": "POST", "code": "200"}).Inc()
+}
+
+func recordLoginHub() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "hub/login", "method": "POST", "code": "200"}).Inc()
+}
+
+func recordGetHubCircuitBreaker() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "hub/circuitbreaker", "method": "GET", "code": "200"}).Inc()
+}
+
+func recordResetHubCircuitBreaker() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "hub/circuitbreaker/reset", "method": "POST", "code": "200"}).Inc()
+}
+
+func recordSetConcurrentScanLimit() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "concurrentscanlimit", "method": "POST", "code": "200"}).Inc()
+}
+
+func recordGetConcurrentScanLimit() {
+	handledHTTPRequest.With(prometheus.Labels{"path": "concurrentscanlimit", "method": "GET", "code": "200"}).Inc()
+}
+
 // unsuccessful http requests received
 
 func recordHTTPNotFound(request *http.Request) {
@@ -164,85 +313,111 @@ func recordHTTPError(request *http.Request, err error, statusCode int) {
 	handledHTTPRequest.With(prometheus.Labels{"path": path, "method": method, "code": statusCodeString}).Inc()
 }
 
-func init() {
+func setupMetrics() {
 	statusHistogram = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "status_histogram",
 		Help:      "a histogram of statuses for perceptor core's current state",
 	}, []string{"name", "count"})
-	prometheus.MustRegister(statusHistogram)
+	metricsRegistry.Register(statusHistogram)
 
 	handledHTTPRequest = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace:   "perceptor",
+		Namespace:   metricsRegistry.Namespace(),
 		Subsystem:   "core",
 		Name:        "http_handled_status_codes",
 		Help:        "status codes for HTTP requests handled by perceptor core",
 		ConstLabels: map[string]string{},
 	}, []string{"path", "method", "code"})
-	prometheus.MustRegister(handledHTTPRequest)
+	metricsRegistry.Register(handledHTTPRequest)
 
 	podStatusGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "pod_status",
 		Help:      "buckets of pod status ('Unknown' means not yet scanned)",
 	}, []string{statusLabel})
-	prometheus.MustRegister(podStatusGauge)
+	metricsRegistry.Register(podStatusGauge)
 
 	podVulnerabilitiesGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "pod_vulnerabilities",
 		Help:      "buckets of pod vulnerability counts (-1 means not yet scanned)",
 	}, []string{vulnerabilitiesLabel})
-	prometheus.MustRegister(podVulnerabilitiesGauge)
+	metricsRegistry.Register(podVulnerabilitiesGauge)
 
 	podPolicyViolationsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "pod_policy_violations",
 		Help:      "buckets of pod policy violation counts (-1 means not yet scanned)",
 	}, []string{policyViolationsLabel})
-	prometheus.MustRegister(podPolicyViolationsGauge)
+	metricsRegistry.Register(podPolicyViolationsGauge)
 
 	imageStatusGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "image_status",
 		Help:      "buckets of image status ('Unknown' means not yet scanned)",
 	}, []string{statusLabel})
-	prometheus.MustRegister(imageStatusGauge)
+	metricsRegistry.Register(imageStatusGauge)
 
 	imageVulnerabilitiesGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "image_vulnerabilities",
 		Help:      "buckets of image vulnerability counts (-1 means not yet scanned)",
 	}, []string{vulnerabilitiesLabel})
-	prometheus.MustRegister(imageVulnerabilitiesGauge)
+	metricsRegistry.Register(imageVulnerabilitiesGauge)
 
 	statusGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "status_gauge",
 		Help:      "a gauge of statuses for perceptor core's current state",
 	}, []string{"name"})
-	prometheus.MustRegister(statusGauge)
+	metricsRegistry.Register(statusGauge)
 
 	imagePolicyViolationsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "image_policy_violations",
 		Help:      "buckets of image policy violation counts (-1 means not yet scanned)",
 	}, []string{policyViolationsLabel})
-	prometheus.MustRegister(imagePolicyViolationsGauge)
+	metricsRegistry.Register(imagePolicyViolationsGauge)
 
 	eventCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "event_counter",
 		Help:      "various events happening in perceptor core",
 	}, []string{"subsystem", "name"})
-	prometheus.MustRegister(eventCounter)
+	metricsRegistry.Register(eventCounter)
+
+	scanClientJobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "scan_client_job_duration_seconds",
+		Help:      "durations of the phases of scan client jobs, as reported by scan clients, by image size class",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 16),
+	}, []string{"phase", "size"})
+	metricsRegistry.Register(scanClientJobDuration)
+
+	scanClientJobBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "scan_client_job_bytes",
+		Help:      "image tarball sizes and peak scratch disk usage of scan client jobs, as reported by scan clients",
+		Buckets:   prometheus.ExponentialBuckets(1<<20, 4, 10),
+	}, []string{"name"})
+	metricsRegistry.Register(scanClientJobBytes)
+
+	scanStageMedianAgeGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "scan_stage_median_age_seconds",
+		Help:      "the median time the images currently in each scan pipeline stage have been there, in seconds",
+	}, []string{"stage"})
+	metricsRegistry.Register(scanStageMedianAgeGauge)
 }
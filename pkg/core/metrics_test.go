@@ -28,8 +28,12 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 
+	"github.com/blackducksoftware/perceptor/pkg/api"
 	m "github.com/blackducksoftware/perceptor/pkg/core/model"
+	"github.com/blackducksoftware/perceptor/pkg/util"
 )
 
 func RunTestMetrics() {
@@ -62,5 +66,48 @@ func RunTestMetrics() {
 			recordEvent("um", "found hub")
 			Expect(1).To(Equal(1))
 		})
+
+		It("should record scan client job timings by image size class", func() {
+			observations := func(phase string, size string) uint64 {
+				metric := &dto.Metric{}
+				Expect(scanClientJobDuration.With(prometheus.Labels{"phase": phase, "size": size}).(prometheus.Histogram).Write(metric)).To(BeNil())
+				return metric.GetHistogram().GetSampleCount()
+			}
+			pulls := observations("pull", "under_1GB")
+			uploads := observations("upload", "under_1GB")
+			recordScanClientJobTimings(&api.ScanClientJobTimings{PullSeconds: 12, ScanSeconds: 40, TotalSeconds: 55, TarSizeBytes: 300 << 20})
+			Expect(observations("pull", "under_1GB")).To(Equal(pulls + 1))
+			Expect(observations("upload", "under_1GB")).To(Equal(uploads))
+
+			Expect(m.ImageSizeClass(0)).To(Equal("unknown"))
+			Expect(m.ImageSizeClass(5 << 20)).To(Equal("under_100MB"))
+			Expect(m.ImageSizeClass(40 << 30)).To(Equal("over_10GB"))
+		})
+
+		It("should move every package's metrics to an injected registry and namespace", func() {
+			custom := prometheus.NewRegistry()
+			ConfigureMetrics(&util.MetricsConfig{Registerer: custom, Namespace: "embedded"})
+			defer ConfigureMetrics(nil)
+			// setting up again mustn't double-register
+			ConfigureMetrics(&util.MetricsConfig{Registerer: custom, Namespace: "embedded"})
+
+			recordEvent("metrics test", "configured")
+			m.NewModel().GetMetrics()
+			families, err := custom.Gather()
+			Expect(err).To(BeNil())
+			names := map[string]bool{}
+			for _, family := range families {
+				Expect(family.GetName()).To(HavePrefix("embedded_"))
+				names[family.GetName()] = true
+			}
+			Expect(names).To(HaveKey("embedded_core_event_counter"))
+			Expect(names).To(HaveKey("embedded_core_reducer_message"))
+
+			defaultFamilies, err := prometheus.DefaultGatherer.Gather()
+			Expect(err).To(BeNil())
+			for _, family := range defaultFamilies {
+				Expect(family.GetName()).NotTo(Equal("perceptor_core_reducer_message"))
+			}
+		})
 	})
 }
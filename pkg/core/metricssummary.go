@@ -0,0 +1,260 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package core
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	m "github.com/blackducksoftware/perceptor/pkg/core/model"
+	"github.com/blackducksoftware/perceptor/pkg/hub"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	metricsSampleInterval = time.Minute
+	metricsSummaryWindow  = time.Hour
+)
+
+// the prometheus metrics which the summary is assembled from
+const (
+	coreStatusGaugeMetric        = "perceptor_core_status_gauge"
+	modelStatusGaugeMetric       = "perceptor_core_model_status_gauge"
+	imageStateTransitionsMetric  = "perceptor_core_model_image_state_transitions"
+	reducerMessageMetric         = "perceptor_core_reducer_message"
+	hubCircuitBreakerStateMetric = "perceptor_core_hub_circuit_breaker_state"
+	hubScanStageGaugeMetric      = "perceptor_core_hub_scan_stage_gauge"
+	hubRequestsMetric            = "perceptor_core_http_hub_requests"
+	reducerQueueDepthStatusName  = "number_of_messages_in_reducer_queue"
+	imageStatusNamePrefix        = "image_status_"
+	hubScanStageNamePrefix       = "scan_stage_"
+)
+
+// counterTotals are the values of the counters which the summary reports
+// over its window.
+type counterTotals struct {
+	time           time.Time
+	scansCompleted float64
+	scansFailed    float64
+	actions        map[string]float64
+	// hubRequests is keyed by host, then by success
+	hubRequests map[string]map[bool]float64
+}
+
+// metricsSummarizer builds the metrics summary out of a prometheus registry,
+// so that it can't drift from what's scraped.  Counters only say what has
+// happened since startup, so it samples them every metricsSampleInterval to
+// report on the last metricsSummaryWindow.
+type metricsSummarizer struct {
+	gatherer prometheus.Gatherer
+	mutex    sync.Mutex
+	// samples are oldest first
+	samples []*counterTotals
+}
+
+func newMetricsSummarizer(gatherer prometheus.Gatherer) *metricsSummarizer {
+	return &metricsSummarizer{gatherer: gatherer, samples: []*counterTotals{}}
+}
+
+// run samples the counters until `stop` is closed.
+func (ms *metricsSummarizer) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(metricsSampleInterval)
+	defer ticker.Stop()
+	for {
+		if err := ms.sample(time.Now()); err != nil {
+			recordEvent("metricsSummarizer", "unable to gather metrics")
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (ms *metricsSummarizer) sample(now time.Time) error {
+	families, err := ms.gather()
+	if err != nil {
+		return err
+	}
+	totals := newCounterTotals(families, now)
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.samples = append(ms.samples, totals)
+	cutoff := now.Add(-metricsSummaryWindow)
+	for ms.samples[0].time.Before(cutoff) {
+		ms.samples = ms.samples[1:]
+	}
+	return nil
+}
+
+// baseline is the oldest sample within the window, or nil if there isn't one.
+func (ms *metricsSummarizer) baseline(now time.Time) *counterTotals {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	cutoff := now.Add(-metricsSummaryWindow)
+	for _, sample := range ms.samples {
+		if !sample.time.Before(cutoff) {
+			return sample
+		}
+	}
+	return nil
+}
+
+func (ms *metricsSummarizer) gather() (map[string]*dto.MetricFamily, error) {
+	families, err := ms.gatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]*dto.MetricFamily{}
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+	return byName, nil
+}
+
+// summary reports on the window ending at `now`.  `scanQueue` supplies the
+// age of the oldest unscanned image, which no metric tracks.
+func (ms *metricsSummarizer) summary(now time.Time, scanQueue *api.ScanQueueStats) (*api.MetricsSummary, error) {
+	families, err := ms.gather()
+	if err != nil {
+		return nil, err
+	}
+	summary := &api.MetricsSummary{
+		Hubs:                    map[string]*api.MetricsSummaryHub{},
+		ReducerActionsPerMinute: map[string]float64{},
+		Window:                  api.NewModelTime(0),
+	}
+	if scanQueue != nil {
+		summary.OldestUnscannedImageAge = scanQueue.OldestQueuedAge
+	}
+	statusNames := map[string]*int{
+		imageStatusNamePrefix + m.ScanStatusInQueue.String():           &summary.ScanQueueDepth,
+		imageStatusNamePrefix + m.ScanStatusRunningScanClient.String(): &summary.ScanClientsInProgress,
+		imageStatusNamePrefix + m.ScanStatusRunningHubScan.String():    &summary.HubScansInProgress,
+	}
+	for _, metric := range families[coreStatusGaugeMetric].GetMetric() {
+		if count, ok := statusNames[labelValue(metric, "name")]; ok {
+			*count = int(metric.GetGauge().GetValue())
+		}
+	}
+	for _, metric := range families[modelStatusGaugeMetric].GetMetric() {
+		if labelValue(metric, "name") == reducerQueueDepthStatusName {
+			summary.ReducerQueueDepth = int(metric.GetGauge().GetValue())
+		}
+	}
+	hubSummary := func(host string) *api.MetricsSummaryHub {
+		hubSummary, ok := summary.Hubs[host]
+		if !ok {
+			hubSummary = &api.MetricsSummaryHub{ScanStages: map[string]int{}}
+			summary.Hubs[host] = hubSummary
+		}
+		return hubSummary
+	}
+	for _, metric := range families[hubCircuitBreakerStateMetric].GetMetric() {
+		hubSummary(labelValue(metric, "host")).CircuitBreakerState = circuitBreakerStateName(metric.GetGauge().GetValue())
+	}
+	for _, metric := range families[hubScanStageGaugeMetric].GetMetric() {
+		stage := strings.TrimPrefix(labelValue(metric, "name"), hubScanStageNamePrefix)
+		hubSummary(labelValue(metric, "host")).ScanStages[stage] = int(metric.GetGauge().GetValue())
+	}
+
+	totals := newCounterTotals(families, now)
+	for host := range totals.hubRequests {
+		hubSummary(host)
+	}
+	baseline := ms.baseline(now)
+	if baseline == nil {
+		return summary, nil
+	}
+	window := now.Sub(baseline.time)
+	summary.Window = api.NewModelTime(window)
+	summary.ScansCompleted = int(totals.scansCompleted - baseline.scansCompleted)
+	summary.ScansFailed = int(totals.scansFailed - baseline.scansFailed)
+	for host, requests := range totals.hubRequests {
+		hubSummary := summary.Hubs[host]
+		hubSummary.RequestsSucceeded = int(requests[true] - baseline.hubRequests[host][true])
+		hubSummary.RequestsFailed = int(requests[false] - baseline.hubRequests[host][false])
+	}
+	if window > 0 {
+		for action, count := range totals.actions {
+			summary.ReducerActionsPerMinute[action] = (count - baseline.actions[action]) / window.Minutes()
+		}
+	}
+	return summary, nil
+}
+
+func newCounterTotals(families map[string]*dto.MetricFamily, now time.Time) *counterTotals {
+	totals := &counterTotals{
+		time:        now,
+		actions:     map[string]float64{},
+		hubRequests: map[string]map[bool]float64{},
+	}
+	running := map[string]bool{
+		m.ScanStatusRunningScanClient.String(): true,
+		m.ScanStatusRunningHubScan.String():    true,
+	}
+	for _, metric := range families[imageStateTransitionsMetric].GetMetric() {
+		if labelValue(metric, "legal") != "true" || !running[labelValue(metric, "from")] {
+			continue
+		}
+		switch labelValue(metric, "to") {
+		case m.ScanStatusComplete.String():
+			totals.scansCompleted += metric.GetCounter().GetValue()
+		case m.ScanStatusInQueue.String(), m.ScanStatusFailed.String():
+			totals.scansFailed += metric.GetCounter().GetValue()
+		}
+	}
+	for _, metric := range families[reducerMessageMetric].GetMetric() {
+		totals.actions[labelValue(metric, "message")] += metric.GetCounter().GetValue()
+	}
+	for _, metric := range families[hubRequestsMetric].GetMetric() {
+		host := labelValue(metric, "host")
+		if _, ok := totals.hubRequests[host]; !ok {
+			totals.hubRequests[host] = map[bool]float64{}
+		}
+		totals.hubRequests[host][labelValue(metric, "isSuccess") == "true"] += metric.GetCounter().GetValue()
+	}
+	return totals
+}
+
+func circuitBreakerStateName(value float64) string {
+	state := hub.CircuitBreakerState(value)
+	switch state {
+	case hub.CircuitBreakerStateDisabled, hub.CircuitBreakerStateEnabled, hub.CircuitBreakerStateChecking:
+		return state.String()
+	default:
+		return "unknown"
+	}
+}
+
+func labelValue(metric *dto.Metric, name string) string {
+	for _, label := range metric.GetLabel() {
+		if label.GetName() == name {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
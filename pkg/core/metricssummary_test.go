@@ -0,0 +1,114 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package core
+
+import (
+	"time"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	"github.com/prometheus/client_golang/prometheus"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// summaryMetrics registers look-alikes of the metrics which the summary is
+// assembled from, so that tests don't depend on the global registry.
+type summaryMetrics struct {
+	registry       *prometheus.Registry
+	status         *prometheus.GaugeVec
+	transitions    *prometheus.CounterVec
+	reducer        *prometheus.CounterVec
+	circuitBreaker *prometheus.GaugeVec
+	hubRequests    *prometheus.CounterVec
+}
+
+func newSummaryMetrics() *summaryMetrics {
+	sm := &summaryMetrics{
+		registry:       prometheus.NewRegistry(),
+		status:         prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: coreStatusGaugeMetric, Help: "status"}, []string{"name"}),
+		transitions:    prometheus.NewCounterVec(prometheus.CounterOpts{Name: imageStateTransitionsMetric, Help: "transitions"}, []string{"from", "to", "legal"}),
+		reducer:        prometheus.NewCounterVec(prometheus.CounterOpts{Name: reducerMessageMetric, Help: "reducer"}, []string{"message"}),
+		circuitBreaker: prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: hubCircuitBreakerStateMetric, Help: "circuit breaker"}, []string{"host"}),
+		hubRequests:    prometheus.NewCounterVec(prometheus.CounterOpts{Name: hubRequestsMetric, Help: "hub requests"}, []string{"host", "name", "isSuccess"}),
+	}
+	sm.registry.MustRegister(sm.status, sm.transitions, sm.reducer, sm.circuitBreaker, sm.hubRequests)
+	return sm
+}
+
+func (sm *summaryMetrics) scanned(from string, to string, count float64) {
+	sm.transitions.With(prometheus.Labels{"from": from, "to": to, "legal": "true"}).Add(count)
+}
+
+func RunMetricsSummaryTests() {
+	Describe("metrics summary", func() {
+		start := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+		It("reports gauges as they are", func() {
+			sm := newSummaryMetrics()
+			sm.status.With(prometheus.Labels{"name": "image_status_ScanStatusInQueue"}).Set(7)
+			sm.status.With(prometheus.Labels{"name": "image_status_ScanStatusRunningScanClient"}).Set(2)
+			sm.circuitBreaker.With(prometheus.Labels{"host": "hub1"}).Set(2)
+			summarizer := newMetricsSummarizer(sm.registry)
+			summary, err := summarizer.summary(start, &api.ScanQueueStats{OldestQueuedAge: api.NewModelTime(time.Minute)})
+			Expect(err).To(BeNil())
+			Expect(summary.ScanQueueDepth).To(Equal(7))
+			Expect(summary.ScanClientsInProgress).To(Equal(2))
+			Expect(summary.HubScansInProgress).To(Equal(0))
+			Expect(summary.Hubs["hub1"].CircuitBreakerState).To(Equal("CircuitBreakerStateChecking"))
+			Expect(summary.OldestUnscannedImageAge.Minutes).To(Equal(1.0))
+			Expect(summary.Window.Seconds).To(Equal(0.0))
+		})
+
+		It("reports counters over the last hour", func() {
+			sm := newSummaryMetrics()
+			summarizer := newMetricsSummarizer(sm.registry)
+			sm.scanned("ScanStatusRunningHubScan", "ScanStatusComplete", 5)
+			Expect(summarizer.sample(start)).To(BeNil())
+			sm.scanned("ScanStatusRunningHubScan", "ScanStatusComplete", 1)
+			Expect(summarizer.sample(start.Add(30 * time.Minute))).To(BeNil())
+			sm.scanned("ScanStatusRunningHubScan", "ScanStatusComplete", 2)
+			sm.scanned("ScanStatusRunningScanClient", "ScanStatusComplete", 1)
+			sm.scanned("ScanStatusRunningScanClient", "ScanStatusInQueue", 3)
+			sm.scanned("ScanStatusUnknown", "ScanStatusComplete", 10)
+			sm.reducer.With(prometheus.Labels{"message": "addPod"}).Add(60)
+			sm.hubRequests.With(prometheus.Labels{"host": "hub1", "name": "login", "isSuccess": "false"}).Inc()
+
+			summary, err := summarizer.summary(start.Add(80*time.Minute), nil)
+			Expect(err).To(BeNil())
+			// measured from the sample at 30 minutes
+			Expect(summary.Window.Minutes).To(Equal(50.0))
+			Expect(summary.ScansCompleted).To(Equal(3))
+			Expect(summary.ScansFailed).To(Equal(3))
+			Expect(summary.ReducerActionsPerMinute["addPod"]).To(BeNumerically("~", 60.0/50))
+			Expect(summary.Hubs["hub1"].RequestsFailed).To(Equal(1))
+		})
+
+		It("drops samples once they're out of the window", func() {
+			summarizer := newMetricsSummarizer(newSummaryMetrics().registry)
+			for i := 0; i <= 180; i++ {
+				Expect(summarizer.sample(start.Add(time.Duration(i) * time.Minute))).To(BeNil())
+			}
+			Expect(summarizer.samples).To(HaveLen(61))
+		})
+	})
+}
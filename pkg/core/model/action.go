@@ -21,7 +21,62 @@ under the License.
 
 package model
 
+import (
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
 type action struct {
-	name  string
-	apply func() error
+	name string
+	// journal holds the arguments of journaled actions, and is nil for others
+	journal *JournalEntry
+	// mutates is whether the action may change the model, and so its
+	// generation.  Every journaled action does, as do a few unjournaled ones,
+	// such as configuration which is reapplied on startup instead of replayed.
+	mutates bool
+	apply   func() error
+	// enqueuedAt is when the sender started to enqueue the action
+	enqueuedAt time.Time
+	// done, if set, receives the action's error once it's been applied --
+	// including when it panics -- so that a caller waiting on it can't hang.
+	// It must be buffered, so that the reducer never blocks on it.
+	done chan error
+}
+
+const (
+	maxPanicPayloadBytes = 2048
+)
+
+// applyAction runs an action, turning a panic into an error so that a single
+// bad action can't take down the reducer loop, and then notifies its caller.
+func applyAction(nextAction *action) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("panic while processing action %s: %v\n%s\npayload: %s", nextAction.name, r, debug.Stack(), actionPayload(nextAction))
+			recordActionPanic(nextAction.name)
+			err = fmt.Errorf("panic while processing action %s: %v", nextAction.name, r)
+		}
+		if nextAction.done != nil {
+			nextAction.done <- err
+		}
+	}()
+	return nextAction.apply()
+}
+
+func actionPayload(nextAction *action) string {
+	if nextAction.journal == nil {
+		return "none"
+	}
+	bytes, err := json.Marshal(nextAction.journal)
+	if err != nil {
+		return fmt.Sprintf("unable to serialize payload: %v", err)
+	}
+	if len(bytes) > maxPanicPayloadBytes {
+		return fmt.Sprintf("%s... (truncated from %d bytes)", bytes[:maxPanicPayloadBytes], len(bytes))
+	}
+	return string(bytes)
 }
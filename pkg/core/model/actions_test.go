@@ -25,6 +25,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/blackducksoftware/perceptor/pkg/api"
 	"github.com/blackducksoftware/perceptor/pkg/hub"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -63,6 +64,8 @@ func checkModelEquality(m1 *Model, m2 *Model) {
 	Expect(ji1).To(Equal(ji2))
 	Expect(m1.ImageScanQueue).To(Equal(m2.ImageScanQueue))
 	Expect(m1.Pods).To(Equal(m2.Pods))
+	Expect(m1.podsByNamespace).To(Equal(m2.podsByNamespace))
+	Expect(m1.namespacesByImage).To(Equal(m2.namespacesByImage))
 }
 
 func createNewModel1() *Model {
@@ -109,6 +112,7 @@ func RunActionTests() {
 			imageInfo := NewImageInfo(testSha, &RepoTag{Repository: "image1", Tag: ""}, 1)
 			imageInfo.ScanStatus = ScanStatusUnknown
 			imageInfo.TimeOfLastStatusChange = actual.Images[testSha].TimeOfLastStatusChange
+			imageInfo.Timeline = actual.Images[testSha].Timeline
 			expected.Images[testSha] = imageInfo
 			//
 			checkModelEquality(actual, &expected)
@@ -124,9 +128,11 @@ func RunActionTests() {
 			//  - all new images get added to hub check queue
 			expected := *NewModel()
 			expected.Pods[testPod.QualifiedName()] = testPod
+			expected.indexPod(testPod)
 			imageInfo := NewImageInfo(testSha, &RepoTag{Repository: "image1", Tag: ""}, 1)
 			imageInfo.ScanStatus = ScanStatusUnknown
 			imageInfo.TimeOfLastStatusChange = actual.Images[testSha].TimeOfLastStatusChange
+			imageInfo.Timeline = actual.Images[testSha].Timeline
 			expected.Images[testSha] = imageInfo
 			//
 			checkModelEquality(actual, &expected)
@@ -144,6 +150,7 @@ func RunActionTests() {
 			actual := createNewModel1()
 			Expect(actual.allPods([]Pod{})).To(BeNil())
 			Expect(len(actual.Pods)).To(Equal(0))
+			Expect(len(actual.podsByNamespace)).To(Equal(0))
 		})
 	})
 	Describe("FinishScanClient", func() {
@@ -152,7 +159,7 @@ func RunActionTests() {
 			image := *NewImage("abc", "4.0", DockerImageSha("23bcf2dae3"), -1)
 			model.setImageScanStatus(image.Sha, ScanStatusInQueue)
 			model.setImageScanStatus(image.Sha, ScanStatusRunningScanClient)
-			err := model.finishRunningScanClient(&image, fmt.Errorf("oops, unable to run scan client"))
+			err := model.finishRunningScanClient(&image, "", "", fmt.Errorf("oops, unable to run scan client"))
 			Expect(err).ToNot(BeNil())
 		})
 	})
@@ -190,6 +197,7 @@ func RunActionTests() {
 			expected.addImage(image1)
 			expected.setImageScanStatus(image1.Sha, ScanStatusInQueue)
 			expected.Images[sha1].TimeOfLastStatusChange = model.Images[sha1].TimeOfLastStatusChange
+			expected.Images[sha1].Timeline = model.Images[sha1].Timeline
 
 			Expect(*nextImage).To(Equal(image1))
 			checkModelEquality(model, expected)
@@ -200,7 +208,7 @@ func RunActionTests() {
 	})
 	Describe("test get full scan results", func() {
 		model := createNewModel1()
-		scanResults, err := scanResults(model)
+		scanResults, err := scanResults(model, &api.ScanResultsQuery{})
 		It("should produce the right number of pods, images, data, and policy violations", func() {
 			Expect(err).To(BeNil())
 			Expect(len(scanResults.Pods)).To(Equal(1))
@@ -209,6 +217,50 @@ func RunActionTests() {
 			Expect(scanResults.Images[0].PolicyViolations).To(Equal(3))
 		})
 	})
+	Describe("test get filtered scan results", func() {
+		model := createNewModel2()
+		labeledPod := *NewPod("pod5", "pod5uid", "ns3", []Container{cont3})
+		labeledPod.Labels = map[string]string{"app": "web"}
+		model.addPod(labeledPod)
+		filtered := func(query *api.ScanResultsQuery) ([]string, []string) {
+			results, err := scanResults(model, query)
+			Expect(err).To(BeNil())
+			pods := []string{}
+			for _, pod := range results.Pods {
+				pods = append(pods, pod.Name)
+			}
+			images := []string{}
+			for _, image := range results.Images {
+				images = append(images, image.Sha)
+			}
+			return pods, images
+		}
+		It("should filter by namespace", func() {
+			pods, images := filtered(&api.ScanResultsQuery{Namespace: "ns1"})
+			Expect(pods).To(ConsistOf("pod2"))
+			Expect(images).To(ConsistOf("sha1"))
+		})
+		It("should filter by pod name prefix", func() {
+			pods, images := filtered(&api.ScanResultsQuery{PodNamePrefix: "pod3"})
+			Expect(pods).To(ConsistOf("pod3"))
+			Expect(images).To(ConsistOf("sha3"))
+		})
+		It("should filter by label", func() {
+			selector, err := api.ParseLabelSelector("app=web")
+			Expect(err).To(BeNil())
+			pods, _ := filtered(&api.ScanResultsQuery{LabelSelector: selector})
+			Expect(pods).To(ConsistOf("pod5"))
+			selector, err = api.ParseLabelSelector("app!=web")
+			Expect(err).To(BeNil())
+			pods, _ = filtered(&api.ScanResultsQuery{Namespace: "ns3", LabelSelector: selector})
+			Expect(pods).To(ConsistOf("pod3"))
+		})
+		It("should return empty lists when nothing matches", func() {
+			pods, images := filtered(&api.ScanResultsQuery{Namespace: "missing"})
+			Expect(pods).To(Equal([]string{}))
+			Expect(images).To(Equal([]string{}))
+		})
+	})
 
 	Describe("test pod overall status", func() {
 		model := createNewModel2()
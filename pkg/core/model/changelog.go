@@ -0,0 +1,224 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+const (
+	defaultChangeLogCapacity = 1000
+)
+
+// ChangeLog retains the most recent scan result changes, and fans them out
+// to subscribers.  Publishing never blocks: a subscriber whose buffer fills
+// up is disconnected.
+//
+// The published scans are only touched from the reducer goroutine; the
+// events and subscribers are shared with subscribers, under the mutex.
+type ChangeLog struct {
+	mutex       sync.Mutex
+	capacity    int
+	events      []*api.ScanResultsEvent
+	lastID      uint64
+	subscribers map[*changeSubscriber]bool
+	// the last published scan of each pod and image
+	podScans   map[string]Scan
+	imageScans map[DockerImageSha]Scan
+}
+
+type changeSubscriber struct {
+	events chan *api.ScanResultsEvent
+	lagged bool
+}
+
+// NewChangeLog creates a change log which retains up to `capacity` events.
+func NewChangeLog(capacity int) *ChangeLog {
+	return &ChangeLog{
+		capacity:    capacity,
+		events:      []*api.ScanResultsEvent{},
+		subscribers: map[*changeSubscriber]bool{},
+		podScans:    map[string]Scan{},
+		imageScans:  map[DockerImageSha]Scan{},
+	}
+}
+
+func (cl *ChangeLog) publish(event *api.ScanResultsEvent) {
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+	cl.lastID++
+	event.ID = cl.lastID
+	event.Time = time.Now().String()
+	cl.events = append(cl.events, event)
+	if len(cl.events) > cl.capacity {
+		cl.events = cl.events[len(cl.events)-cl.capacity:]
+	}
+	for subscriber := range cl.subscribers {
+		cl.send(subscriber, event)
+	}
+}
+
+// send must be called with the mutex held.
+func (cl *ChangeLog) send(subscriber *changeSubscriber, event *api.ScanResultsEvent) {
+	select {
+	case subscriber.events <- event:
+	default:
+		subscriber.lagged = true
+		close(subscriber.events)
+		delete(cl.subscribers, subscriber)
+		recordChangeLogSubscriberLagged()
+	}
+}
+
+// Subscribe starts delivering events after `lastEventID`, or only new events
+// if it's empty.  Up to `bufferSize` undelivered events are buffered.
+func (cl *ChangeLog) Subscribe(lastEventID string, bufferSize int) (*api.ScanResultsSubscription, error) {
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+	subscriber := &changeSubscriber{events: make(chan *api.ScanResultsEvent, bufferSize)}
+	cl.subscribers[subscriber] = true
+	reset := false
+	if lastEventID != "" {
+		afterID, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			delete(cl.subscribers, subscriber)
+			return nil, api.NewValidationError("invalid event ID %s", lastEventID)
+		}
+		if afterID > cl.lastID {
+			// the client saw events from before a restart
+			reset = true
+			afterID = 0
+		}
+		if afterID < cl.lastID && (len(cl.events) == 0 || afterID+1 < cl.events[0].ID) {
+			reset = true
+		}
+		for _, event := range cl.events {
+			if event.ID > afterID && cl.subscribers[subscriber] {
+				cl.send(subscriber, event)
+			}
+		}
+	}
+	return &api.ScanResultsSubscription{
+		Events: subscriber.events,
+		Reset:  reset,
+		Lagged: func() bool {
+			cl.mutex.Lock()
+			defer cl.mutex.Unlock()
+			return subscriber.lagged
+		},
+		Close: func() {
+			cl.mutex.Lock()
+			defer cl.mutex.Unlock()
+			if cl.subscribers[subscriber] {
+				delete(cl.subscribers, subscriber)
+				close(subscriber.events)
+			}
+		},
+	}, nil
+}
+
+// publishScanResultChanges compares the pods and images touched by an action
+// with what was last published, and publishes the differences.
+func (model *Model) publishScanResultChanges(entry *JournalEntry) {
+	cl := model.changeLog
+	podNames := map[string]bool{}
+	if entry.PodName != "" {
+		podNames[entry.PodName] = true
+	}
+	if entry.Pod != nil {
+		podNames[entry.Pod.QualifiedName()] = true
+	}
+	if entry.Namespace != "" {
+		for podName := range cl.podScans {
+			if strings.HasPrefix(podName, entry.Namespace+"/") {
+				podNames[podName] = true
+			}
+		}
+	}
+	if entry.Pods != nil {
+		for podName := range model.Pods {
+			podNames[podName] = true
+		}
+		for podName := range cl.podScans {
+			podNames[podName] = true
+		}
+	}
+	if entry.ScanResults != nil {
+		sha := DockerImageSha(entry.Sha)
+		if imageInfo, ok := model.Images[sha]; ok {
+			imageScan, err := scanResultsForImage(model, sha)
+			if err == nil && imageScan != nil {
+				if published, ok := cl.imageScans[sha]; !ok || published != *imageScan {
+					cl.imageScans[sha] = *imageScan
+					image := imageInfo.Image()
+					cl.publish(&api.ScanResultsEvent{Image: &api.ScannedImage{
+						Repository:       image.Repository,
+						Tag:              image.Tag,
+						Sha:              string(sha),
+						PolicyViolations: imageScan.PolicyViolations,
+						Vulnerabilities:  imageScan.Vulnerabilities,
+						OverallStatus:    imageScan.OverallStatus.String(),
+						ComponentsURL:    imageInfo.ScanResults.ComponentsHref,
+					}})
+				}
+			}
+		}
+		for podName, pod := range model.Pods {
+			if pod.hasImageSha(sha) {
+				podNames[podName] = true
+			}
+		}
+	}
+	sortedPodNames := []string{}
+	for podName := range podNames {
+		sortedPodNames = append(sortedPodNames, podName)
+	}
+	sort.Strings(sortedPodNames)
+	for _, podName := range sortedPodNames {
+		pod, ok := model.Pods[podName]
+		if !ok {
+			delete(cl.podScans, podName)
+			continue
+		}
+		podScan, err := scanResultsForPod(model, podName)
+		if err != nil || podScan == nil {
+			continue
+		}
+		if published, ok := cl.podScans[podName]; ok && published == *podScan {
+			continue
+		}
+		cl.podScans[podName] = *podScan
+		cl.publish(&api.ScanResultsEvent{Pod: &api.ScannedPod{
+			Namespace:        pod.Namespace,
+			Name:             pod.Name,
+			PolicyViolations: podScan.PolicyViolations,
+			Vulnerabilities:  podScan.Vulnerabilities,
+			OverallStatus:    podScan.OverallStatus.String(),
+		}})
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	"github.com/blackducksoftware/perceptor/pkg/hub"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunChangeLogTests() {
+	Describe("change log", func() {
+		imageEvent := func(sha string) *api.ScanResultsEvent {
+			return &api.ScanResultsEvent{Image: &api.ScannedImage{Sha: sha}}
+		}
+		receive := func(subscription *api.ScanResultsSubscription, count int) []string {
+			shas := []string{}
+			for i := 0; i < count; i++ {
+				event := <-subscription.Events
+				shas = append(shas, fmt.Sprintf("%d:%s", event.ID, event.Image.Sha))
+			}
+			return shas
+		}
+
+		It("delivers new events in order", func() {
+			cl := NewChangeLog(10)
+			subscription, err := cl.Subscribe("", 10)
+			Expect(err).To(BeNil())
+			cl.publish(imageEvent("a"))
+			cl.publish(imageEvent("b"))
+			Expect(receive(subscription, 2)).To(Equal([]string{"1:a", "2:b"}))
+			Expect(subscription.Reset).To(BeFalse())
+		})
+
+		It("replays retained events after the last event ID", func() {
+			cl := NewChangeLog(10)
+			for _, sha := range []string{"a", "b", "c"} {
+				cl.publish(imageEvent(sha))
+			}
+			subscription, err := cl.Subscribe("1", 10)
+			Expect(err).To(BeNil())
+			cl.publish(imageEvent("d"))
+			Expect(receive(subscription, 3)).To(Equal([]string{"2:b", "3:c", "4:d"}))
+			Expect(subscription.Reset).To(BeFalse())
+		})
+
+		It("signals a reset when resuming from outside the retained window", func() {
+			cl := NewChangeLog(2)
+			for _, sha := range []string{"a", "b", "c", "d"} {
+				cl.publish(imageEvent(sha))
+			}
+			subscription, err := cl.Subscribe("1", 10)
+			Expect(err).To(BeNil())
+			Expect(subscription.Reset).To(BeTrue())
+			Expect(receive(subscription, 2)).To(Equal([]string{"3:c", "4:d"}))
+			// IDs from before a restart
+			subscription, err = cl.Subscribe("100", 10)
+			Expect(err).To(BeNil())
+			Expect(subscription.Reset).To(BeTrue())
+			// the most recent ID is fine
+			subscription, err = cl.Subscribe("4", 10)
+			Expect(err).To(BeNil())
+			Expect(subscription.Reset).To(BeFalse())
+		})
+
+		It("rejects invalid event IDs", func() {
+			_, err := NewChangeLog(2).Subscribe("abc", 10)
+			Expect(err).To(BeAssignableToTypeOf(&api.ValidationError{}))
+		})
+
+		It("disconnects subscribers which fall behind", func() {
+			cl := NewChangeLog(10)
+			slow, err := cl.Subscribe("", 1)
+			Expect(err).To(BeNil())
+			fast, err := cl.Subscribe("", 10)
+			Expect(err).To(BeNil())
+			cl.publish(imageEvent("a"))
+			cl.publish(imageEvent("b"))
+			Expect(receive(slow, 1)).To(Equal([]string{"1:a"}))
+			_, ok := <-slow.Events
+			Expect(ok).To(BeFalse())
+			Expect(slow.Lagged()).To(BeTrue())
+			Expect(receive(fast, 2)).To(Equal([]string{"1:a", "2:b"}))
+			Expect(fast.Lagged()).To(BeFalse())
+			fast.Close()
+			_, ok = <-fast.Events
+			Expect(ok).To(BeFalse())
+			Expect(fast.Lagged()).To(BeFalse())
+		})
+
+		It("publishes image and pod changes from model actions", func() {
+			model := NewModel()
+			subscription, err := model.SubscribeScanResults("", 10)
+			Expect(err).To(BeNil())
+			model.AddPod(pod2)
+			// as when results are first fetched from the hub
+			results := &hub.ScanResults{
+				ScanSummaries: []hub.ScanSummary{{Status: hub.ScanSummaryStatusSuccess}},
+				PolicyStatus: hub.PolicyStatus{
+					OverallStatus:                hub.PolicyStatusTypeInViolation,
+					ComponentVersionStatusCounts: map[hub.PolicyStatusType]int{hub.PolicyStatusTypeInViolation: 3}}}
+			model.ScanDidFinish(sha1, results)
+			var event *api.ScanResultsEvent
+			Eventually(subscription.Events).Should(Receive(&event))
+			Expect(event.Image).NotTo(BeNil())
+			Expect(event.Image.Sha).To(Equal(string(sha1)))
+			Expect(event.Image.PolicyViolations).To(Equal(3))
+			Eventually(subscription.Events).Should(Receive(&event))
+			Expect(event.Pod).NotTo(BeNil())
+			Expect(event.Pod.Name).To(Equal(pod2.Name))
+			Expect(event.Pod.OverallStatus).To(Equal("IN_VIOLATION"))
+			// unchanged results aren't published again
+			model.ScanDidFinish(sha1, results)
+			model.UpdatePod(pod2)
+			// wait for the reducer to catch up
+			Expect(model.GetGeneration()).To(BeNumerically(">", 0))
+			Expect(len(subscription.Events)).To(Equal(0))
+		})
+	})
+}
@@ -0,0 +1,116 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import "sync"
+
+// deferredDelete is a pod deletion waiting for room in the action queue.
+type deferredDelete struct {
+	action    *action
+	podName   string
+	cancelled bool
+}
+
+// deferredDeletes holds the pod deletions which were accepted while the
+// action queue was full.  A single goroutine hands them to the reducer in the
+// order they were accepted.  An upsert of a pod cancels its pending deletion,
+// which would otherwise remove the newer pod.  It is concurrent-safe.
+type deferredDeletes struct {
+	mutex sync.Mutex
+	// queue holds the deletions which haven't been sent to the reducer yet
+	queue []*deferredDelete
+	// pending holds the latest uncancelled deletion of each pod, until the
+	// reducer applies it
+	pending map[string]*deferredDelete
+	wake    chan struct{}
+}
+
+func newDeferredDeletes() *deferredDeletes {
+	return &deferredDeletes{pending: map[string]*deferredDelete{}, wake: make(chan struct{}, 1)}
+}
+
+func (dd *deferredDeletes) add(deletion *deferredDelete) {
+	dd.mutex.Lock()
+	defer dd.mutex.Unlock()
+	dd.queue = append(dd.queue, deletion)
+	dd.pending[deletion.podName] = deletion
+	select {
+	case dd.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run sends the deletions to the reducer, blocking while its queue is full.
+func (dd *deferredDeletes) run(send func(a *action)) {
+	for range dd.wake {
+		for {
+			dd.mutex.Lock()
+			if len(dd.queue) == 0 {
+				dd.mutex.Unlock()
+				break
+			}
+			deletion := dd.queue[0]
+			dd.queue = dd.queue[1:]
+			dd.mutex.Unlock()
+			send(deletion.action)
+		}
+	}
+}
+
+// cancel cancels the pending deletion of `podName`, if there is one.
+func (dd *deferredDeletes) cancel(podName string) {
+	dd.mutex.Lock()
+	defer dd.mutex.Unlock()
+	if deletion, ok := dd.pending[podName]; ok {
+		deletion.cancelled = true
+		delete(dd.pending, podName)
+	}
+}
+
+// cancelAll cancels every pending deletion.
+func (dd *deferredDeletes) cancelAll() {
+	dd.mutex.Lock()
+	defer dd.mutex.Unlock()
+	for podName, deletion := range dd.pending {
+		deletion.cancelled = true
+		delete(dd.pending, podName)
+	}
+}
+
+// take is called by the reducer as it applies a deletion, and returns false
+// if the deletion was cancelled.
+func (dd *deferredDeletes) take(deletion *deferredDelete) bool {
+	dd.mutex.Lock()
+	defer dd.mutex.Unlock()
+	if dd.pending[deletion.podName] == deletion {
+		delete(dd.pending, deletion.podName)
+	}
+	return !deletion.cancelled
+}
+
+// unsent returns the number of deletions which haven't been sent to the
+// reducer yet.
+func (dd *deferredDeletes) unsent() int {
+	dd.mutex.Lock()
+	defer dd.mutex.Unlock()
+	return len(dd.queue)
+}
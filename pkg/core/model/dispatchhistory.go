@@ -0,0 +1,75 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"time"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+const (
+	// enough to cover ScanQueueStatsWindow at up to 16 dispatches a minute
+	defaultDispatchHistoryCapacity = 1000
+)
+
+// ScanDispatch records an image being handed out to a scan client.
+type ScanDispatch struct {
+	Sha          DockerImageSha
+	QueuedAt     time.Time
+	DispatchedAt time.Time
+}
+
+// dispatchHistory is a ring of the most recent dispatches.  It's only
+// touched from the reducer goroutine.
+type dispatchHistory struct {
+	records []*ScanDispatch
+	// next is where the next record goes, once the ring is full
+	next int
+}
+
+func newDispatchHistory(capacity int) *dispatchHistory {
+	return &dispatchHistory{records: make([]*ScanDispatch, 0, capacity)}
+}
+
+func (dh *dispatchHistory) add(dispatch *ScanDispatch) {
+	if len(dh.records) < cap(dh.records) {
+		dh.records = append(dh.records, dispatch)
+		return
+	}
+	dh.records[dh.next] = dispatch
+	dh.next = (dh.next + 1) % len(dh.records)
+}
+
+// apiDispatches returns the dispatches oldest first.
+func (dh *dispatchHistory) apiDispatches() []*api.ModelScanDispatch {
+	dispatches := make([]*api.ModelScanDispatch, 0, len(dh.records))
+	for i := range dh.records {
+		record := dh.records[(dh.next+i)%len(dh.records)]
+		dispatches = append(dispatches, &api.ModelScanDispatch{
+			Sha:          string(record.Sha),
+			QueuedAt:     record.QueuedAt,
+			DispatchedAt: record.DispatchedAt,
+		})
+	}
+	return dispatches
+}
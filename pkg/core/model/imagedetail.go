@@ -0,0 +1,127 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+// findImageByShaPrefix returns the image whose sha starts with `shaPrefix`,
+// or an error if zero or several images match.  An exact match always wins.
+func findImageByShaPrefix(model *Model, shaPrefix string) (DockerImageSha, *ImageInfo, error) {
+	if shaPrefix == "" {
+		return "", nil, &api.ImageNotFoundError{ShaPrefix: shaPrefix}
+	}
+	if imageInfo, ok := model.Images[DockerImageSha(shaPrefix)]; ok {
+		return DockerImageSha(shaPrefix), imageInfo, nil
+	}
+	candidates := []string{}
+	for sha := range model.Images {
+		if strings.HasPrefix(string(sha), shaPrefix) {
+			candidates = append(candidates, string(sha))
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return "", nil, &api.ImageNotFoundError{ShaPrefix: shaPrefix}
+	case 1:
+		sha := DockerImageSha(candidates[0])
+		return sha, model.Images[sha], nil
+	default:
+		sort.Strings(candidates)
+		return "", nil, &api.AmbiguousShaError{ShaPrefix: shaPrefix, Candidates: candidates}
+	}
+}
+
+func imageDetail(model *Model, shaPrefix string) (*api.ImageDetail, error) {
+	sha, imageInfo, err := findImageByShaPrefix(model, shaPrefix)
+	if err != nil {
+		return nil, err
+	}
+	transitions := []*api.ModelImageTransition{}
+	for _, it := range model.ImageTransitions {
+		if it.Sha == sha {
+			transitions = append(transitions, coreImageTransitionToAPIImageTransition(it))
+		}
+	}
+	var queuePosition *int
+	if model.ImageScanQueue.HasKey(string(sha)) {
+		rank, err := model.ImageScanQueue.Rank(string(sha))
+		if err != nil {
+			return nil, err
+		}
+		queuePosition = &rank
+	}
+	image := imageInfo.Image()
+	var scanSummary *api.ScannedImage
+	if imageInfo.ScanResults != nil {
+		scanSummary = &api.ScannedImage{
+			Repository:       image.Repository,
+			Tag:              image.Tag,
+			Sha:              string(sha),
+			PolicyViolations: imageInfo.ScanResults.PolicyViolationCount(),
+			Vulnerabilities:  imageInfo.ScanResults.VulnerabilityCount(),
+			OverallStatus:    imageInfo.ScanResults.OverallStatus().String(),
+			ComponentsURL:    imageInfo.ScanResults.ComponentsHref}
+	}
+	pods := []string{}
+	for podName, pod := range model.Pods {
+		for _, cont := range pod.Containers {
+			if cont.Image.Sha == sha {
+				pods = append(pods, podName)
+				break
+			}
+		}
+	}
+	sort.Strings(pods)
+	timeOfLastRefresh := ""
+	if !imageInfo.TimeOfLastRefresh.IsZero() {
+		timeOfLastRefresh = imageInfo.TimeOfLastRefresh.String()
+	}
+	var scanClient *api.ImageScanClient
+	if record := imageInfo.ScanClient; record != nil {
+		scanClient = &api.ImageScanClient{
+			Scanner:                 record.Scanner,
+			ScanCliVersion:          record.ScanCliVersion,
+			SignatureScannerVersion: record.SignatureScannerVersion,
+			FinishedAt:              record.FinishedAt.String(),
+		}
+	}
+	return &api.ImageDetail{
+		Sha:                    string(sha),
+		RepoTags:               coreRepoTagsToAPIRepoTags(imageInfo.RepoTags),
+		Priority:               imageInfo.Priority,
+		ScanStatus:             imageInfo.ScanStatus.String(),
+		TimeOfLastStatusChange: imageInfo.TimeOfLastStatusChange.String(),
+		TimeOfLastRefresh:      timeOfLastRefresh,
+		Transitions:            transitions,
+		QueuePosition:          queuePosition,
+		HubScanName:            image.HubScanName(),
+		FailureHistory:         coreScanFailuresToAPIScanFailures(imageInfo.FailureHistory),
+		ScanSummary:            scanSummary,
+		Pods:                   pods,
+		ScanClient:             scanClient,
+	}, nil
+}
@@ -0,0 +1,135 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"time"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func RunImageDetailTests() {
+	Describe("image detail", func() {
+		It("should find an image by its full sha", func() {
+			model := createNewModel1()
+			detail, err := imageDetail(model, string(sha1))
+			Expect(err).To(BeNil())
+			Expect(detail.Sha).To(Equal(string(sha1)))
+			Expect(detail.ScanStatus).To(Equal(ScanStatusComplete.String()))
+			Expect(detail.Pods).To(Equal([]string{pod1.QualifiedName(), pod2.QualifiedName()}))
+			Expect(detail.HubScanName).To(Equal(image1.HubScanName()))
+			Expect(detail.QueuePosition).To(BeNil())
+			Expect(detail.ScanSummary).NotTo(BeNil())
+			Expect(detail.ScanSummary.OverallStatus).To(Equal("IN_VIOLATION"))
+			Expect(detail.ScanSummary.PolicyViolations).To(Equal(3))
+		})
+
+		It("should find an image by an unambiguous prefix", func() {
+			model := NewModel()
+			Expect(model.addImage(image1)).To(BeNil())
+			detail, err := imageDetail(model, "sh")
+			Expect(err).To(BeNil())
+			Expect(detail.Sha).To(Equal(string(sha1)))
+			Expect(detail.ScanSummary).To(BeNil())
+			Expect(detail.Pods).To(Equal([]string{}))
+		})
+
+		It("should report the scanner versions which last ran on the image", func() {
+			versionChanges := func() float64 {
+				metric := &dto.Metric{}
+				Expect(scannerVersionChangeCounter.Write(metric)).To(BeNil())
+				return metric.GetCounter().GetValue()
+			}
+			before := versionChanges()
+			model := NewModel()
+			Expect(model.addImage(image1)).To(BeNil())
+			Expect(model.addImage(image2)).To(BeNil())
+			finishedAt := time.Date(2018, 5, 1, 12, 0, 0, 0, time.UTC)
+			Expect(model.recordScanClient(sha1, &ScanClientRecord{Scanner: "scanner-1", ScanCliVersion: "4.7.0", SignatureScannerVersion: "2.1", FinishedAt: finishedAt})).To(BeNil())
+			detail, err := imageDetail(model, string(sha1))
+			Expect(err).To(BeNil())
+			Expect(detail.ScanClient).To(Equal(&api.ImageScanClient{Scanner: "scanner-1", ScanCliVersion: "4.7.0", SignatureScannerVersion: "2.1", FinishedAt: finishedAt.String()}))
+			Expect(versionChanges()).To(Equal(before))
+
+			Expect(model.recordScanClient(sha2, &ScanClientRecord{Scanner: "scanner-1", ScanCliVersion: "4.8.0"})).To(BeNil())
+			Expect(versionChanges()).To(Equal(before + 1))
+			Expect(model.recordScanClient("missing", &ScanClientRecord{})).NotTo(BeNil())
+		})
+
+		It("should return a not found error for an unknown sha", func() {
+			model := createNewModel1()
+			_, err := imageDetail(model, "not-a-sha")
+			Expect(err).To(Equal(&api.ImageNotFoundError{ShaPrefix: "not-a-sha"}))
+		})
+
+		It("should return an ambiguous sha error when several images match", func() {
+			model := createNewModel1()
+			_, err := imageDetail(model, "sha")
+			Expect(err).To(Equal(&api.AmbiguousShaError{ShaPrefix: "sha", Candidates: []string{string(sha1), string(sha2)}}))
+		})
+
+		It("should report the queue position and transitions", func() {
+			model := NewModel()
+			Expect(model.addImage(image1)).To(BeNil())
+			Expect(model.addImage(image2)).To(BeNil())
+			Expect(model.setImageScanStatus(sha1, ScanStatusInQueue)).To(BeNil())
+			Expect(model.setImageScanStatus(sha2, ScanStatusInQueue)).To(BeNil())
+			detail, err := imageDetail(model, string(sha1))
+			Expect(err).To(BeNil())
+			Expect(detail.QueuePosition).NotTo(BeNil())
+			Expect(*detail.QueuePosition).To(Equal(1))
+			Expect(len(detail.Transitions)).To(Equal(1))
+			Expect(detail.Transitions[0].To).To(Equal(ScanStatusInQueue.String()))
+		})
+
+		It("should resolve a repository and tag to every image seen under it", func() {
+			model := NewModel()
+			Expect(model.addImage(image1)).To(BeNil())
+			Expect(model.addImage(*NewImage("image1", "1", sha2, 1))).To(BeNil())
+			Expect(model.addImage(*NewImage("image3", "3", sha2, 1))).To(BeNil())
+			images, err := model.imagesNamed("image1", "1")
+			Expect(err).To(BeNil())
+			Expect(len(images.Images)).To(Equal(2))
+			Expect(images.Images[0].Sha).To(Equal(string(sha2)))
+			Expect(images.Images[1].Sha).To(Equal(string(sha1)))
+			Expect(images.Images[1].ScanStatus).To(Equal(ScanStatusUnknown.String()))
+			// re-adding an image keeps its original name
+			Expect(model.Images[sha2].RepoTags).To(Equal([]*RepoTag{{Repository: "image1", Tag: "1"}, {Repository: "image3", Tag: "3"}}))
+			Expect(model.addImage(image1)).To(BeNil())
+			Expect(model.Images[sha1].RepoTags).To(HaveLen(1))
+			images, err = model.imagesNamed("image1", "1")
+			Expect(err).To(BeNil())
+			Expect(images.Images[0].Sha).To(Equal(string(sha1)))
+			Expect(images.Images[0].FirstSeen).NotTo(Equal(images.Images[0].LastSeen))
+			// deleting an image drops it from every name it was seen under
+			Expect(model.deleteImage(sha2)).To(BeNil())
+			_, err = model.imagesNamed("image3", "3")
+			Expect(err).To(Equal(&api.ImageNameNotFoundError{Name: "image3:3"}))
+			images, err = model.imagesNamed("image1", "1")
+			Expect(err).To(BeNil())
+			Expect(len(images.Images)).To(Equal(1))
+		})
+	})
+}
@@ -38,15 +38,28 @@ type ImageInfo struct {
 	ImageSha               DockerImageSha
 	RepoTags               []*RepoTag
 	Priority               int
+	// FailureHistory holds the most recent failures, oldest first.  It survives
+	// requeues, and is only discarded along with the image.
+	FailureHistory []*ScanFailure
+	// Lease is held by the scan client while the image is in RunningScanClient
+	Lease *ScanLease
+	// ScanClient is nil until a scan client reports which scanner versions ran on the image
+	ScanClient *ScanClientRecord
+	// Timeline is for the image's current pass through the scan pipeline
+	Timeline ImageTimeline
+	// SizeBytes is the image's tarball size as last reported by a scan
+	// client, and 0 if unknown
+	SizeBytes int64
 }
 
 // NewImageInfo .....
 func NewImageInfo(sha DockerImageSha, repoTag *RepoTag, priority int) *ImageInfo {
 	imageInfo := &ImageInfo{
-		ScanResults: nil,
-		ImageSha:    sha,
-		RepoTags:    []*RepoTag{repoTag},
-		Priority:    priority,
+		ScanResults:    nil,
+		ImageSha:       sha,
+		RepoTags:       []*RepoTag{repoTag},
+		Priority:       priority,
+		FailureHistory: []*ScanFailure{},
 	}
 	imageInfo.setScanStatus(ScanStatusUnknown)
 	return imageInfo
@@ -55,6 +68,7 @@ func NewImageInfo(sha DockerImageSha, repoTag *RepoTag, priority int) *ImageInfo
 func (imageInfo *ImageInfo) setScanStatus(newStatus ScanStatus) {
 	imageInfo.ScanStatus = newStatus
 	imageInfo.TimeOfLastStatusChange = time.Now()
+	imageInfo.Timeline.record(newStatus, imageInfo.TimeOfLastStatusChange)
 }
 
 // SetPriority ...
@@ -70,6 +84,15 @@ func (imageInfo *ImageInfo) SetScanResults(results *hub.ScanResults) {
 	imageInfo.TimeOfLastRefresh = time.Now()
 }
 
+// AddScanFailure records a failure, dropping the oldest one if the
+// history is full.
+func (imageInfo *ImageInfo) AddScanFailure(failure *ScanFailure) {
+	imageInfo.FailureHistory = append(imageInfo.FailureHistory, failure)
+	if len(imageInfo.FailureHistory) > maxScanFailureHistory {
+		imageInfo.FailureHistory = imageInfo.FailureHistory[len(imageInfo.FailureHistory)-maxScanFailureHistory:]
+	}
+}
+
 // TimeInCurrentScanStatus .....
 func (imageInfo *ImageInfo) TimeInCurrentScanStatus() time.Duration {
 	return time.Now().Sub(imageInfo.TimeOfLastStatusChange)
@@ -98,7 +121,7 @@ func (imageInfo *ImageInfo) FirstRepoTag() *RepoTag {
 
 func arrayContains(array []*RepoTag, value *RepoTag) bool {
 	for _, item := range array {
-		if item == value {
+		if *item == *value {
 			return true
 		}
 	}
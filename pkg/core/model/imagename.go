@@ -0,0 +1,108 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"sort"
+	"time"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+// nameSighting is when an image was seen under a repository and tag.
+type nameSighting struct {
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// indexImageName records that `image` was just seen under its repository and tag.
+func (model *Model) indexImageName(image Image) {
+	name := RepoTag{Repository: image.Repository, Tag: image.Tag}
+	images, ok := model.imagesByName[name]
+	if !ok {
+		images = map[DockerImageSha]*nameSighting{}
+		model.imagesByName[name] = images
+	}
+	now := time.Now()
+	if sighting, ok := images[image.Sha]; ok {
+		sighting.LastSeen = now
+	} else {
+		images[image.Sha] = &nameSighting{FirstSeen: now, LastSeen: now}
+	}
+}
+
+// unindexImageNames drops `sha` from the names of the image, before it's deleted.
+func (model *Model) unindexImageNames(sha DockerImageSha) {
+	imageInfo, ok := model.Images[sha]
+	if !ok {
+		return
+	}
+	for _, repoTag := range imageInfo.RepoTags {
+		images := model.imagesByName[*repoTag]
+		delete(images, sha)
+		if len(images) == 0 {
+			delete(model.imagesByName, *repoTag)
+		}
+	}
+}
+
+func (model *Model) imagesNamed(repository string, tag string) (*api.ImagesByName, error) {
+	sightings, ok := model.imagesByName[RepoTag{Repository: repository, Tag: tag}]
+	if !ok {
+		return nil, &api.ImageNameNotFoundError{Name: repository + ":" + tag}
+	}
+	shas := []DockerImageSha{}
+	for sha := range sightings {
+		shas = append(shas, sha)
+	}
+	sort.Slice(shas, func(i, j int) bool {
+		left, right := sightings[shas[i]].LastSeen, sightings[shas[j]].LastSeen
+		if left.Equal(right) {
+			return shas[i] < shas[j]
+		}
+		return left.After(right)
+	})
+	result := &api.ImagesByName{Repository: repository, Tag: tag, Images: []*api.NamedImage{}}
+	for _, sha := range shas {
+		imageInfo := model.unsafeGet(sha)
+		sighting := sightings[sha]
+		image := &api.NamedImage{
+			Sha:                    string(sha),
+			ScanStatus:             imageInfo.ScanStatus.String(),
+			TimeOfLastStatusChange: imageInfo.TimeOfLastStatusChange.String(),
+			FirstSeen:              sighting.FirstSeen.String(),
+			LastSeen:               sighting.LastSeen.String(),
+		}
+		if imageInfo.ScanResults != nil {
+			image.ScanSummary = &api.ScannedImage{
+				Repository:       repository,
+				Tag:              tag,
+				Sha:              string(sha),
+				PolicyViolations: imageInfo.ScanResults.PolicyViolationCount(),
+				Vulnerabilities:  imageInfo.ScanResults.VulnerabilityCount(),
+				OverallStatus:    imageInfo.ScanResults.OverallStatus().String(),
+				ComponentsURL:    imageInfo.ScanResults.ComponentsHref}
+		}
+		result.Images = append(result.Images, image)
+	}
+	return result, nil
+}
@@ -0,0 +1,160 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	"github.com/blackducksoftware/perceptor/pkg/hub"
+	log "github.com/sirupsen/logrus"
+)
+
+// JournalEntry records a single reducer action.  Entries only hold model
+// data -- pods, images and scan results -- and never any hub credentials.
+type JournalEntry struct {
+	Action  string
+	Time    time.Time
+	Sha     string `json:",omitempty"`
+	PodName string `json:",omitempty"`
+	Err     string `json:",omitempty"`
+	// arguments of the action, used for replay
+	Pod         *Pod             `json:",omitempty"`
+	Pods        []Pod            `json:",omitempty"`
+	Image       *Image           `json:",omitempty"`
+	Images      []Image          `json:",omitempty"`
+	ScanResults *hub.ScanResults `json:",omitempty"`
+	Scanner     string           `json:",omitempty"`
+	ScanErr     string           `json:",omitempty"`
+	// Namespace is set for actions on every pod in a namespace
+	Namespace string `json:",omitempty"`
+	// LeaseID is the scan lease granted, acknowledged or presented
+	LeaseID string `json:",omitempty"`
+	// FailureCategory classifies ScanErr, if the scan client did
+	FailureCategory string `json:",omitempty"`
+	// FailureDetail details ScanErr, if the scan client did
+	FailureDetail *api.ScanClientFailure `json:",omitempty"`
+	// ScanClient is the scan client and scanner versions recorded for Sha
+	ScanClient *ScanClientRecord `json:",omitempty"`
+	// Requester is who manually requeued Sha
+	Requester string `json:",omitempty"`
+}
+
+// Outcome is "ok" if the action succeeded, and "error" otherwise.
+func (entry *JournalEntry) Outcome() string {
+	if entry.Err != "" {
+		return "error"
+	}
+	return "ok"
+}
+
+// Journal is a bounded record of the most recent reducer actions, optionally
+// mirrored to an append-only file of newline-delimited JSON.
+// It is not concurrent-safe: it must only be used from the reducer goroutine.
+type Journal struct {
+	entries []*JournalEntry
+	next    int
+	count   int
+	file    *os.File
+}
+
+// NewJournal creates a journal holding up to `capacity` entries.  If `path`
+// is non-empty, entries are also appended to that file.
+func NewJournal(capacity int, path string) (*Journal, error) {
+	journal := &Journal{entries: make([]*JournalEntry, capacity)}
+	if path != "" {
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		journal.file = file
+	}
+	return journal, nil
+}
+
+func (journal *Journal) record(actionName string, entry *JournalEntry, err error) {
+	entry.Action = actionName
+	entry.Time = time.Now()
+	if err != nil {
+		entry.Err = err.Error()
+	}
+	if entry.Pod != nil && entry.PodName == "" {
+		entry.PodName = entry.Pod.QualifiedName()
+	}
+	if entry.Image != nil && entry.Sha == "" {
+		entry.Sha = string(entry.Image.Sha)
+	}
+	if len(journal.entries) > 0 {
+		journal.entries[journal.next] = entry
+		journal.next = (journal.next + 1) % len(journal.entries)
+		if journal.count < len(journal.entries) {
+			journal.count++
+		}
+	}
+	if journal.file != nil {
+		jsonBytes, err := json.Marshal(entry)
+		if err != nil {
+			log.Errorf("unable to marshal journal entry for action %s: %s", entry.Action, err.Error())
+			return
+		}
+		_, err = journal.file.Write(append(jsonBytes, '\n'))
+		if err != nil {
+			log.Errorf("unable to write journal entry for action %s: %s", entry.Action, err.Error())
+		}
+	}
+}
+
+// Entries returns the retained entries, oldest first.
+func (journal *Journal) Entries() []*JournalEntry {
+	entries := make([]*JournalEntry, journal.count)
+	start := journal.next - journal.count
+	if start < 0 {
+		start += len(journal.entries)
+	}
+	for i := 0; i < journal.count; i++ {
+		entries[i] = journal.entries[(start+i)%len(journal.entries)]
+	}
+	return entries
+}
+
+// ReadJournal parses newline-delimited JSON entries, as written to a journal file.
+func ReadJournal(reader io.Reader) ([]*JournalEntry, error) {
+	entries := []*JournalEntry{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, scanner.Err()
+}
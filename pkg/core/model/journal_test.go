@@ -0,0 +1,213 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/blackducksoftware/perceptor/pkg/hub"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// replayJournal feeds journaled actions through a fresh reducer, reproducing
+// the state of the model that produced the journal.
+func replayJournal(entries []*JournalEntry) *Model {
+	model := NewModel()
+	for _, entry := range entries {
+		switch entry.Action {
+		case "addPod", "updatePod":
+			model.addPod(*entry.Pod)
+		case "deletePod":
+			model.deletePod(entry.PodName)
+		case "deleteNamespacePods":
+			model.deleteNamespacePods(entry.Namespace)
+		case "allPods":
+			model.allPods(entry.Pods)
+		case "addImage":
+			model.addImage(*entry.Image)
+		case "allImages":
+			model.allImages(entry.Images)
+		case "addImages":
+			model.addImages(entry.Images)
+		case "finishScanJob":
+			var scanErr error
+			if entry.FailureCategory != "" || entry.FailureDetail != nil {
+				scanErr = &ScanClientError{Message: entry.ScanErr, Category: entry.FailureCategory, Detail: entry.FailureDetail}
+			} else if entry.ScanErr != "" {
+				scanErr = fmt.Errorf("%s", entry.ScanErr)
+			}
+			model.finishRunningScanClient(entry.Image, entry.Scanner, entry.LeaseID, scanErr)
+		case "recordScanClient":
+			model.recordScanClient(DockerImageSha(entry.Sha), entry.ScanClient)
+		case "finishDryRunScanJob":
+			model.finishDryRunScanClient(entry.Image, entry.Scanner, entry.LeaseID)
+		case "scanDidFinish":
+			model.scanDidFinish(DockerImageSha(entry.Sha), entry.ScanResults)
+		case "startScanClient":
+			model.startScanClient(DockerImageSha(entry.Sha), entry.LeaseID)
+		case "acknowledgeScanLease":
+			model.acknowledgeScanLease(DockerImageSha(entry.Sha), entry.LeaseID, entry.Time)
+		case "expireScanLeases":
+			model.expireScanLeases(entry.Time)
+		case "requeueImage":
+			model.requeueImage(entry.Sha, entry.Requester)
+		default:
+			Fail(fmt.Sprintf("unable to replay journal entry of type %s", entry.Action))
+		}
+	}
+	return model
+}
+
+func checkReplayedModel(expected *Model, actual *Model) {
+	Expect(actual.Pods).To(Equal(expected.Pods))
+	Expect(namespaceIndex(actual)).To(Equal(namespaceIndex(expected)))
+	Expect(len(actual.Images)).To(Equal(len(expected.Images)))
+	for sha, imageInfo := range expected.Images {
+		Expect(actual.Images[sha].ScanStatus).To(Equal(imageInfo.ScanStatus))
+		Expect(actual.Images[sha].Priority).To(Equal(imageInfo.Priority))
+		Expect(len(actual.Images[sha].FailureHistory)).To(Equal(len(imageInfo.FailureHistory)))
+	}
+	Expect(sortedValues(actual.ImageScanQueue)).To(Equal(sortedValues(expected.ImageScanQueue)))
+}
+
+func driveJournaledModel(model *Model) {
+	model.AddPod(pod1)
+	model.AddPod(pod3)
+	model.AddImage(image3)
+	model.DeletePod(pod3.QualifiedName())
+	model.DeletePod("missing/pod")
+	model.ScanDidFinish(sha1, nil)
+	model.ScanDidFinish(sha2, nil)
+	lease2, err := model.StartScanClient(sha2)
+	Expect(err).To(BeNil())
+	Expect(model.FinishScanJob(&image2, "scanner-1", lease2.ID, fmt.Errorf("planned failure"))).To(BeNil())
+	lease1, err := model.StartScanClient(sha1)
+	Expect(err).To(BeNil())
+	Expect(model.AcknowledgeScanLease(sha1, lease1.ID)).To(BeNil())
+	Expect(model.FinishScanJob(&image1, "scanner-1", lease1.ID, nil)).To(BeNil())
+	model.ScanDidFinish(sha1, &hub.ScanResults{
+		ScanSummaries: []hub.ScanSummary{{Status: hub.ScanSummaryStatusSuccess}},
+	})
+	model.ScanDidFinish(sha3, nil)
+	_, err = model.StartScanClient(sha3)
+	Expect(err).To(BeNil())
+	_, err = model.RequeueImage(string(sha3), "ops")
+	Expect(err).To(BeNil())
+	// ambiguous, so not journaled
+	_, err = model.RequeueImage("sha", "ops")
+	Expect(err).NotTo(BeNil())
+}
+
+func RunJournalTests() {
+	Describe("Journal", func() {
+		It("should keep only the most recent entries", func() {
+			journal, err := NewJournal(3, "")
+			Expect(err).To(BeNil())
+			for i := 0; i < 5; i++ {
+				journal.record(fmt.Sprintf("action%d", i), &JournalEntry{}, nil)
+			}
+			entries := journal.Entries()
+			Expect(len(entries)).To(Equal(3))
+			Expect(entries[0].Action).To(Equal("action2"))
+			Expect(entries[2].Action).To(Equal("action4"))
+		})
+
+		It("should record identifiers and outcomes", func() {
+			journal, err := NewJournal(10, "")
+			Expect(err).To(BeNil())
+			model := NewModelWithJournal(journal)
+			model.AddPod(pod1)
+			model.DeletePod("missing/pod")
+			entries := model.GetJournal()
+			Expect(len(entries)).To(Equal(2))
+			Expect(entries[0].Action).To(Equal("addPod"))
+			Expect(entries[0].PodName).To(Equal(pod1.QualifiedName()))
+			Expect(entries[0].Outcome()).To(Equal("ok"))
+			Expect(entries[1].Action).To(Equal("deletePod"))
+			Expect(entries[1].Outcome()).To(Equal("error"))
+			Expect(len(model.GetModel().Journal)).To(Equal(2))
+		})
+
+		It("should not journal anything when disabled", func() {
+			model := NewModel()
+			model.AddPod(pod1)
+			Expect(model.GetJournal()).To(BeNil())
+			Expect(model.GetModel().Journal).To(BeNil())
+		})
+
+		It("should reproduce the model state by replaying the journal", func() {
+			journal, err := NewJournal(100, "")
+			Expect(err).To(BeNil())
+			model := NewModelWithJournal(journal)
+			driveJournaledModel(model)
+			entries := model.GetJournal()
+			checkReplayedModel(model, replayJournal(entries))
+		})
+
+		It("should journal manual requeues under the resolved sha, with the requester", func() {
+			journal, err := NewJournal(100, "")
+			Expect(err).To(BeNil())
+			model := NewModelWithJournal(journal)
+			image := *NewImage("image4", "4", DockerImageSha("sha256:4abcdef"), 4)
+			model.AddImage(image)
+			model.ScanDidFinish(image.Sha, nil)
+			_, err = model.StartScanClient(image.Sha)
+			Expect(err).To(BeNil())
+			_, err = model.RequeueImage("sha256:4ab", "ops")
+			Expect(err).To(BeNil())
+
+			entries := model.GetJournal()
+			last := entries[len(entries)-1]
+			Expect(last.Action).To(Equal("requeueImage"))
+			Expect(last.Sha).To(Equal("sha256:4abcdef"))
+			Expect(last.Requester).To(Equal("ops"))
+			replayed := replayJournal(entries)
+			checkReplayedModel(model, replayed)
+			history := replayed.Images[image.Sha].FailureHistory
+			Expect(history[len(history)-1].Err).To(Equal("requeued by ops"))
+		})
+
+		It("should append entries to a file which can be read back and replayed", func() {
+			file, err := ioutil.TempFile("", "perceptor-journal")
+			Expect(err).To(BeNil())
+			file.Close()
+			defer os.Remove(file.Name())
+
+			journal, err := NewJournal(0, file.Name())
+			Expect(err).To(BeNil())
+			model := NewModelWithJournal(journal)
+			driveJournaledModel(model)
+			Expect(model.GetJournal()).To(Equal([]*JournalEntry{}))
+
+			contents, err := ioutil.ReadFile(file.Name())
+			Expect(err).To(BeNil())
+			entries, err := ReadJournal(bytes.NewReader(contents))
+			Expect(err).To(BeNil())
+			Expect(len(entries)).To(Equal(16))
+			checkReplayedModel(model, replayJournal(entries))
+		})
+	})
+}
@@ -0,0 +1,150 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"sort"
+	"time"
+)
+
+// Scan pipeline stages, as used in the lifecycle metrics
+const (
+	scanStageQueue      = "queue"
+	scanStageScanClient = "scan_client"
+	scanStageHubScan    = "hub_scan"
+	scanStageTotal      = "total"
+)
+
+// ImageTimeline records when an image reached each stage of its current
+// pass through the scan pipeline.  Zero times are for stages it hasn't
+// reached, or skipped.
+type ImageTimeline struct {
+	QueuedAt             time.Time
+	DispatchedAt         time.Time
+	ScanClientFinishedAt time.Time
+	FinishedAt           time.Time
+}
+
+// record notes that the image entered `status` at `now`.  Going back into
+// the queue starts a new pass.
+func (timeline *ImageTimeline) record(status ScanStatus, now time.Time) {
+	switch status {
+	case ScanStatusInQueue:
+		*timeline = ImageTimeline{QueuedAt: now}
+	case ScanStatusRunningScanClient:
+		timeline.DispatchedAt = now
+	case ScanStatusRunningHubScan:
+		timeline.ScanClientFinishedAt = now
+	case ScanStatusComplete, ScanStatusFailed:
+		timeline.FinishedAt = now
+	}
+}
+
+// StageDurations returns how long the image spent in each stage it went
+// through: each stage lasts until the next one was reached, or the pass
+// finished.  The total runs from entering the queue to finishing.
+func (timeline *ImageTimeline) StageDurations() map[string]time.Duration {
+	stages := []struct {
+		name  string
+		start time.Time
+	}{
+		{scanStageQueue, timeline.QueuedAt},
+		{scanStageScanClient, timeline.DispatchedAt},
+		{scanStageHubScan, timeline.ScanClientFinishedAt},
+		{"", timeline.FinishedAt},
+	}
+	durations := map[string]time.Duration{}
+	for i, stage := range stages[:len(stages)-1] {
+		if stage.start.IsZero() {
+			continue
+		}
+		for _, next := range stages[i+1:] {
+			if !next.start.IsZero() {
+				durations[stage.name] = next.start.Sub(stage.start)
+				break
+			}
+		}
+	}
+	if !timeline.QueuedAt.IsZero() && !timeline.FinishedAt.IsZero() {
+		durations[scanStageTotal] = timeline.FinishedAt.Sub(timeline.QueuedAt)
+	}
+	return durations
+}
+
+// scanStageOfStatus maps the statuses in which an image can get stuck to
+// their stages.
+var scanStageOfStatus = map[ScanStatus]string{
+	ScanStatusInQueue:           scanStageQueue,
+	ScanStatusRunningScanClient: scanStageScanClient,
+	ScanStatusRunningHubScan:    scanStageHubScan,
+}
+
+// recordScanLifecycle observes the stage durations of an image which has
+// just finished a pass through the pipeline.
+func recordScanLifecycle(imageInfo *ImageInfo) {
+	size := ImageSizeClass(imageInfo.SizeBytes)
+	for stage, duration := range imageInfo.Timeline.StageDurations() {
+		recordScanStageDuration(stage, size, duration)
+	}
+}
+
+// medianStageAges returns, for each stage, the median time the images in it
+// have been there; 0 if it's empty.
+func medianStageAges(images map[DockerImageSha]*ImageInfo, now time.Time) map[string]time.Duration {
+	ages := map[string][]time.Duration{}
+	for _, imageInfo := range images {
+		if stage, ok := scanStageOfStatus[imageInfo.ScanStatus]; ok {
+			ages[stage] = append(ages[stage], now.Sub(imageInfo.TimeOfLastStatusChange))
+		}
+	}
+	medians := map[string]time.Duration{}
+	for _, stage := range scanStageOfStatus {
+		medians[stage] = 0
+	}
+	for stage, stageAges := range ages {
+		sort.Slice(stageAges, func(i, j int) bool { return stageAges[i] < stageAges[j] })
+		middle := len(stageAges) / 2
+		if len(stageAges)%2 == 1 {
+			medians[stage] = stageAges[middle]
+		} else {
+			medians[stage] = (stageAges[middle-1] + stageAges[middle]) / 2
+		}
+	}
+	return medians
+}
+
+// ImageSizeClass buckets images coarsely by tarball size, keeping the
+// cardinality of size-labeled metrics low.
+func ImageSizeClass(tarSizeBytes int64) string {
+	switch {
+	case tarSizeBytes <= 0:
+		return "unknown"
+	case tarSizeBytes < 100<<20:
+		return "under_100MB"
+	case tarSizeBytes < 1<<30:
+		return "under_1GB"
+	case tarSizeBytes < 10<<30:
+		return "under_10GB"
+	default:
+		return "over_10GB"
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// stageObservations returns the histogram's cumulative bucket counts for
+// `stage` and `size`, by upper bound.
+func stageObservations(stage string, size string) map[float64]uint64 {
+	metric := &dto.Metric{}
+	Expect(scanStageDurationHistogram.With(prometheus.Labels{"stage": stage, "size": size}).(prometheus.Histogram).Write(metric)).To(BeNil())
+	counts := map[float64]uint64{}
+	for _, bucket := range metric.GetHistogram().GetBucket() {
+		counts[bucket.GetUpperBound()] = bucket.GetCumulativeCount()
+	}
+	return counts
+}
+
+// newObservations returns how many observations went into each of the
+// `bounds` buckets since `before`.
+func newObservations(before map[float64]uint64, after map[float64]uint64, bounds ...float64) []uint64 {
+	counts := make([]uint64, len(bounds))
+	for i, bound := range bounds {
+		counts[i] = after[bound] - before[bound]
+	}
+	return counts
+}
+
+func RunLifecycleTests() {
+	Describe("Scan lifecycle", func() {
+		start := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+		at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+
+		It("should split a pass into stages", func() {
+			timeline := &ImageTimeline{}
+			timeline.record(ScanStatusInQueue, at(0))
+			timeline.record(ScanStatusRunningScanClient, at(3))
+			timeline.record(ScanStatusRunningHubScan, at(100))
+			timeline.record(ScanStatusComplete, at(1000))
+			Expect(timeline.StageDurations()).To(Equal(map[string]time.Duration{
+				"queue":       3 * time.Second,
+				"scan_client": 97 * time.Second,
+				"hub_scan":    900 * time.Second,
+				"total":       1000 * time.Second,
+			}))
+		})
+
+		It("should end a stage at the next one reached, and start over on a requeue", func() {
+			timeline := &ImageTimeline{}
+			timeline.record(ScanStatusInQueue, at(0))
+			timeline.record(ScanStatusRunningScanClient, at(10))
+			timeline.record(ScanStatusFailed, at(25))
+			Expect(timeline.StageDurations()).To(Equal(map[string]time.Duration{
+				"queue":       10 * time.Second,
+				"scan_client": 15 * time.Second,
+				"total":       25 * time.Second,
+			}))
+
+			timeline.record(ScanStatusInQueue, at(60))
+			timeline.record(ScanStatusComplete, at(62))
+			Expect(timeline.StageDurations()).To(Equal(map[string]time.Duration{
+				"queue": 2 * time.Second,
+				"total": 2 * time.Second,
+			}))
+		})
+
+		It("should observe every stage into size-labeled buckets", func() {
+			queueBefore := stageObservations("queue", "under_1GB")
+			scanClientBefore := stageObservations("scan_client", "under_1GB")
+			hubScanBefore := stageObservations("hub_scan", "under_1GB")
+			totalBefore := stageObservations("total", "under_1GB")
+
+			imageInfo := NewImageInfo(sha1, &RepoTag{Repository: "image1", Tag: "1"}, 1)
+			imageInfo.SizeBytes = 300 << 20
+			imageInfo.Timeline = ImageTimeline{QueuedAt: at(0), DispatchedAt: at(3), ScanClientFinishedAt: at(100), FinishedAt: at(1000)}
+			recordScanLifecycle(imageInfo)
+
+			Expect(newObservations(queueBefore, stageObservations("queue", "under_1GB"), 2, 4)).To(Equal([]uint64{0, 1}))
+			Expect(newObservations(scanClientBefore, stageObservations("scan_client", "under_1GB"), 64, 128)).To(Equal([]uint64{0, 1}))
+			Expect(newObservations(hubScanBefore, stageObservations("hub_scan", "under_1GB"), 512, 1024)).To(Equal([]uint64{0, 1}))
+			Expect(newObservations(totalBefore, stageObservations("total", "under_1GB"), 512, 1024)).To(Equal([]uint64{0, 1}))
+		})
+
+		It("should observe an image's stages when it finishes scanning", func() {
+			totalBefore := stageObservations("total", "unknown")
+			hubScanBefore := stageObservations("hub_scan", "unknown")
+			model := NewModel()
+			Expect(model.addImage(image1)).To(BeNil())
+			if model.Images[sha1].ScanStatus != ScanStatusInQueue {
+				Expect(model.setImageScanStatus(sha1, ScanStatusInQueue)).To(BeNil())
+			}
+			Expect(model.startScanClient(sha1, "lease-1")).To(BeNil())
+			Expect(model.finishRunningScanClient(&image1, "scanner-1", "lease-1", nil)).To(BeNil())
+			Expect(newObservations(totalBefore, stageObservations("total", "unknown"), 1)).To(Equal([]uint64{0}))
+
+			Expect(model.setImageScanStatus(sha1, ScanStatusComplete)).To(BeNil())
+			Expect(newObservations(totalBefore, stageObservations("total", "unknown"), 1)).To(Equal([]uint64{1}))
+			Expect(newObservations(hubScanBefore, stageObservations("hub_scan", "unknown"), 1)).To(Equal([]uint64{1}))
+		})
+
+		It("should find the median age of the images in each stage", func() {
+			now := at(1000)
+			images := map[DockerImageSha]*ImageInfo{}
+			add := func(sha string, status ScanStatus, since int) {
+				images[DockerImageSha(sha)] = &ImageInfo{ScanStatus: status, TimeOfLastStatusChange: at(since)}
+			}
+			add("a", ScanStatusInQueue, 990)
+			add("b", ScanStatusInQueue, 900)
+			add("c", ScanStatusInQueue, 0)
+			add("d", ScanStatusRunningScanClient, 940)
+			add("e", ScanStatusRunningScanClient, 980)
+			add("f", ScanStatusComplete, 0)
+			Expect(medianStageAges(images, now)).To(Equal(map[string]time.Duration{
+				"queue":       100 * time.Second,
+				"scan_client": 40 * time.Second,
+				"hub_scan":    0,
+			}))
+		})
+
+		It("should bucket image sizes coarsely", func() {
+			Expect(ImageSizeClass(0)).To(Equal("unknown"))
+			Expect(ImageSizeClass(300 << 20)).To(Equal("under_1GB"))
+			Expect(ImageSizeClass(2 << 30)).To(Equal("under_10GB"))
+		})
+	})
+}
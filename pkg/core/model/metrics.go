@@ -25,12 +25,28 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	"github.com/blackducksoftware/perceptor/pkg/util"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+var metricsRegistry = util.NewMetricsRegistry()
+
+func init() {
+	setupMetrics()
+}
+
+// ConfigureMetrics moves the model package's metrics to the registry and
+// namespace in `config`.  It must be called before anything records metrics.
+func ConfigureMetrics(config *util.MetricsConfig) {
+	metricsRegistry.Configure(config)
+	setupMetrics()
+}
+
 var eventsCounter *prometheus.CounterVec
 var stateTransitionCounter *prometheus.CounterVec
 var actionErrorCounter *prometheus.CounterVec
+var actionPanicCounter *prometheus.CounterVec
 
 // var errorCounter *prometheus.CounterVec
 
@@ -38,11 +54,25 @@ var statusGauge *prometheus.GaugeVec
 var reducerActivityCounter *prometheus.CounterVec
 var reducerMessageCounter *prometheus.CounterVec
 var setImagePriorityCounter *prometheus.CounterVec
+var imageRequeueCounter *prometheus.CounterVec
+var scanLeaseExpiredCounter prometheus.Counter
+var scanLeaseRejectedCounter *prometheus.CounterVec
+var dryRunScanCounter prometheus.Counter
+var scanClientFailureCounter *prometheus.CounterVec
+var scannerVersionChangeCounter prometheus.Counter
+var scanStageDurationHistogram *prometheus.HistogramVec
+
+// requeueReasonFailed is for scans which a scanner or hub reported as failed
+const requeueReasonFailed = "failed"
 
 func recordActionError(action string) {
 	actionErrorCounter.With(prometheus.Labels{"action": action}).Inc()
 }
 
+func recordActionPanic(action string) {
+	actionPanicCounter.With(prometheus.Labels{"action": action}).Inc()
+}
+
 // TODO
 // func recordError(action string, name string) {
 // 	errorCounter.With(prometheus.Labels{"action": action, "name": name}).Inc()
@@ -54,6 +84,37 @@ func recordSetImagePriority(from int, to int) {
 		"to":   fmt.Sprintf("%d", to)}).Inc()
 }
 
+func recordImageRequeue(reason string, stage ScanFailureStage) {
+	imageRequeueCounter.With(prometheus.Labels{"reason": reason, "stage": stage.String()}).Inc()
+}
+
+func recordScanLeaseExpired() {
+	scanLeaseExpiredCounter.Inc()
+}
+
+func recordScanLeaseRejected(reason string) {
+	scanLeaseRejectedCounter.With(prometheus.Labels{"reason": reason}).Inc()
+}
+
+// recordScanClientFailure counts categories which perceptor doesn't know,
+// e.g. from newer scan clients, as "other", to keep the label bounded.
+func recordScanClientFailure(category string) {
+	if category == "" {
+		category = "uncategorized"
+	} else if !api.IsKnownScanFailureCategory(category) {
+		category = "other"
+	}
+	scanClientFailureCounter.With(prometheus.Labels{"category": category}).Inc()
+}
+
+func recordScannerVersionChange() {
+	scannerVersionChangeCounter.Inc()
+}
+
+func recordDryRunScan() {
+	dryRunScanCounter.Inc()
+}
+
 func recordStateTransition(from ScanStatus, to ScanStatus, isLegal bool) {
 	stateTransitionCounter.With(prometheus.Labels{
 		"from":  from.String(),
@@ -61,10 +122,18 @@ func recordStateTransition(from ScanStatus, to ScanStatus, isLegal bool) {
 		"legal": fmt.Sprintf("%t", isLegal)}).Inc()
 }
 
+func recordScanStageDuration(stage string, size string, duration time.Duration) {
+	scanStageDurationHistogram.With(prometheus.Labels{"stage": stage, "size": size}).Observe(duration.Seconds())
+}
+
 func recordEvent(event string) {
 	eventsCounter.With(prometheus.Labels{"event": event}).Inc()
 }
 
+func recordChangeLogSubscriberLagged() {
+	recordEvent("change log subscriber lagged")
+}
+
 // reducer loop
 
 func recordReducerActivity(isActive bool, duration time.Duration) {
@@ -83,69 +152,134 @@ func recordMessageType(message string) {
 	reducerMessageCounter.With(prometheus.Labels{"message": message}).Inc()
 }
 
-func init() {
+func setupMetrics() {
 	stateTransitionCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace:   "perceptor",
+		Namespace:   metricsRegistry.Namespace(),
 		Subsystem:   "core",
 		Name:        "model_image_state_transitions",
 		Help:        "state transitions for images in the perceptor model",
 		ConstLabels: map[string]string{},
 	}, []string{"from", "to", "legal"})
-	prometheus.MustRegister(stateTransitionCounter)
+	metricsRegistry.Register(stateTransitionCounter)
 
 	eventsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "events",
 		Help:      "counters for events happening in the core",
 	}, []string{"event"})
-	prometheus.MustRegister(eventsCounter)
+	metricsRegistry.Register(eventsCounter)
 
 	// errorCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
-	// 	Namespace: "perceptor",
+	// 	Namespace: metricsRegistry.Namespace(),
 	// 	Subsystem: "core",
 	// 	Name:      "model_errors_counter",
 	// 	Help:      "records errors encounted in the model package",
 	// }, []string{"action", "name"})
-	// prometheus.MustRegister(errorCounter)
+	// metricsRegistry.Register(errorCounter)
 
 	actionErrorCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "action_errors_counter",
 		Help:      "records errors encounted during model action processing",
 	}, []string{"action"})
-	prometheus.MustRegister(actionErrorCounter)
+	metricsRegistry.Register(actionErrorCounter)
+
+	actionPanicCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "action_panics_counter",
+		Help:      "records panics recovered from during model action processing",
+	}, []string{"action"})
+	metricsRegistry.Register(actionPanicCounter)
 
 	setImagePriorityCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "set_image_priority",
 		Help:      "records image priority changes",
 	}, []string{"from", "to"})
-	prometheus.MustRegister(setImagePriorityCounter)
+	metricsRegistry.Register(setImagePriorityCounter)
+
+	imageRequeueCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "image_requeues",
+		Help:      "images put back in the scan queue part way through a scan, by reason: failed, manual or leaseExpired",
+	}, []string{"reason", "stage"})
+	metricsRegistry.Register(imageRequeueCounter)
+
+	scanLeaseExpiredCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "scan_lease_expirations",
+		Help:      "images requeued because their scan clients didn't acknowledge them in time",
+	})
+	metricsRegistry.Register(scanLeaseExpiredCounter)
+
+	scanLeaseRejectedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "scan_lease_rejections",
+		Help:      "acknowledgments and finished scans rejected for presenting a lease which is unknown, stale or expired",
+	}, []string{"reason"})
+	metricsRegistry.Register(scanLeaseRejectedCounter)
+
+	dryRunScanCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "dry_run_scans",
+		Help:      "scan client jobs which only simulated scanning, and whose images were put back in the scan queue",
+	})
+	metricsRegistry.Register(dryRunScanCounter)
+
+	scanClientFailureCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "scan_client_failures",
+		Help:      "failed scan client jobs, by the category the scan client reported; unknown categories are counted as other",
+	}, []string{"category"})
+	metricsRegistry.Register(scanClientFailureCounter)
+
+	scannerVersionChangeCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "scanner_version_changes",
+		Help:      "times a scanner reported a different scan.cli version than for its previous job",
+	})
+	metricsRegistry.Register(scannerVersionChangeCounter)
+
+	scanStageDurationHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "scan_stage_duration_seconds",
+		Help:      "how long images which finished a pass through the scan pipeline spent in each stage, and in total, in seconds",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 18),
+	}, []string{"stage", "size"})
+	metricsRegistry.Register(scanStageDurationHistogram)
 
 	statusGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "model_status_gauge",
 		Help:      "a gauge of statuses for perceptor core model's current state",
 	}, []string{"name"})
-	prometheus.MustRegister(statusGauge)
+	metricsRegistry.Register(statusGauge)
 
 	reducerActivityCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "reducer_activity",
 		Help:      "activity of the reducer -- how much time it's been idle and active, in seconds",
 	}, []string{"state"})
-	prometheus.MustRegister(reducerActivityCounter)
+	metricsRegistry.Register(reducerActivityCounter)
 
 	reducerMessageCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "reducer_message",
 		Help:      "count of the message types processed by the reducer",
 	}, []string{"message"})
-	prometheus.MustRegister(reducerMessageCounter)
+	metricsRegistry.Register(reducerMessageCounter)
 }
@@ -35,6 +35,9 @@ import (
 
 const (
 	actionChannelSize = 100
+	// slowEnqueueThreshold is how long a sender can be blocked on a full
+	// action queue before it counts as slow
+	slowEnqueueThreshold = time.Second
 )
 
 // Model is the root of the core model
@@ -45,18 +48,69 @@ type Model struct {
 	ImageScanQueue   *util.PriorityQueue
 	ImageTransitions []*ImageTransition
 	//
-	actions chan *action
+	actions   chan *action
+	queue     *util.QueueMonitor
+	journal   *Journal
+	changeLog *ChangeLog
+	// imageQueued is notified whenever an action leaves images in the scan queue
+	imageQueued *signal
+	// deferredDeletes are pod deletions accepted while the action queue was full
+	deferredDeletes *deferredDeletes
+	// generation counts the actions which may have changed the model
+	generation int64
+	// podsByNamespace is a map of namespace to the qualified names of its pods
+	podsByNamespace map[string]map[string]bool
+	// namespacesByImage counts, for each image, the pods in each namespace
+	// which reference it
+	namespacesByImage map[DockerImageSha]map[string]int
+	// podsAddedAt is a map of qualified name to when the pod was first added
+	podsAddedAt map[string]time.Time
+	// imagesByName records, for each repository and tag, when each image was
+	// seen under it
+	imagesByName map[RepoTag]map[DockerImageSha]*nameSighting
+	// per-namespace scan limits, and which namespace each running scan is charged to
+	namespaceScanLimits    map[string]int
+	namespaceScansInFlight map[string]int
+	scanNamespaces         map[DockerImageSha]string
+	// dispatches are the images most recently handed out to scan clients
+	dispatches *dispatchHistory
+	// scanLeaseTTL is how long scan clients have to acknowledge their leases
+	scanLeaseTTL time.Duration
+	// scannerCliVersions is the scan.cli version each scanner last reported
+	scannerCliVersions map[string]string
 }
 
 // NewModel .....
 func NewModel() *Model {
+	return NewModelWithJournal(nil)
+}
+
+// NewModelWithJournal creates a model which records its actions to `journal`.
+// A nil journal disables journaling.
+func NewModelWithJournal(journal *Journal) *Model {
 	model := &Model{
-		Pods:             make(map[string]Pod),
-		Images:           make(map[DockerImageSha]*ImageInfo),
-		ImageScanQueue:   util.NewPriorityQueue(),
-		ImageTransitions: []*ImageTransition{},
-		actions:          make(chan *action, actionChannelSize),
+		Pods:                   make(map[string]Pod),
+		podsByNamespace:        make(map[string]map[string]bool),
+		namespacesByImage:      make(map[DockerImageSha]map[string]int),
+		podsAddedAt:            make(map[string]time.Time),
+		imagesByName:           make(map[RepoTag]map[DockerImageSha]*nameSighting),
+		Images:                 make(map[DockerImageSha]*ImageInfo),
+		ImageScanQueue:         util.NewPriorityQueue(),
+		ImageTransitions:       []*ImageTransition{},
+		actions:                make(chan *action, actionChannelSize),
+		queue:                  util.NewQueueMonitor("core", slowEnqueueThreshold),
+		journal:                journal,
+		changeLog:              NewChangeLog(defaultChangeLogCapacity),
+		imageQueued:            newSignal(),
+		deferredDeletes:        newDeferredDeletes(),
+		namespaceScanLimits:    make(map[string]int),
+		namespaceScansInFlight: make(map[string]int),
+		scanNamespaces:         make(map[DockerImageSha]string),
+		dispatches:             newDispatchHistory(defaultDispatchHistoryCapacity),
+		scanLeaseTTL:           DefaultScanLeaseTTL,
+		scannerCliVersions:     make(map[string]string),
 	}
+	go model.deferredDeletes.run(model.send)
 	go func() {
 		stop := time.Now()
 		for {
@@ -64,6 +118,7 @@ func NewModel() *Model {
 			case nextAction := <-model.actions:
 				actionName := nextAction.name
 				log.Debugf("processing model action of type %s", actionName)
+				model.queue.DidDequeue(nextAction.enqueuedAt)
 
 				// metrics: how many messages are waiting?
 				recordNumberOfMessagesInQueue(len(model.actions))
@@ -76,11 +131,25 @@ func NewModel() *Model {
 				recordReducerActivity(false, start.Sub(stop))
 
 				// actually do the work
-				err := nextAction.apply()
+				err := applyAction(nextAction)
 				if err != nil {
 					log.Errorf("problem processing action %s: %v", actionName, err)
 					recordActionError(actionName)
 				}
+				if nextAction.mutates {
+					model.generation++
+					if model.ImageScanQueue.Size() > 0 {
+						model.imageQueued.notify()
+					}
+				}
+				if nextAction.journal != nil {
+					if err == nil {
+						model.publishScanResultChanges(nextAction.journal)
+					}
+					if model.journal != nil {
+						model.journal.record(actionName, nextAction.journal, err)
+					}
+				}
 
 				// metrics: how long did the work take?
 				stop = time.Now()
@@ -91,145 +160,442 @@ func NewModel() *Model {
 	return model
 }
 
+// enqueue sends an action to the reducer, blocking while its queue is full.
+// Actions with a journal entry mutate the model, those without only read it.
+func (model *Model) enqueue(name string, journal *JournalEntry, apply func() error) {
+	a := &action{name: name, journal: journal, mutates: journal != nil, apply: apply}
+	a.enqueuedAt = model.queue.WillEnqueue()
+	model.send(a)
+}
+
+// enqueueConfig sends an unjournaled action which changes the model.
+func (model *Model) enqueueConfig(name string, apply func() error) {
+	a := &action{name: name, mutates: true, apply: apply}
+	a.enqueuedAt = model.queue.WillEnqueue()
+	model.send(a)
+}
+
+// call enqueues an action and waits for the reducer to apply it, returning
+// the action's error -- or an error describing its panic.
+func (model *Model) call(name string, journal *JournalEntry, apply func() error) error {
+	a := &action{name: name, journal: journal, mutates: journal != nil, apply: apply, done: make(chan error, 1)}
+	a.enqueuedAt = model.queue.WillEnqueue()
+	model.send(a)
+	return <-a.done
+}
+
+// send is enqueue for an action which has already been through WillEnqueue.
+func (model *Model) send(a *action) {
+	model.actions <- a
+	model.queue.DidEnqueue(a.enqueuedAt)
+}
+
 // Public API
 
 // AddPod ...
 func (model *Model) AddPod(pod Pod) {
-	model.actions <- &action{"addPod", func() error {
+	model.deferredDeletes.cancel(pod.QualifiedName())
+	model.enqueue("addPod", &JournalEntry{Pod: &pod}, func() error {
 		return model.addPod(pod)
-	}}
+	})
 }
 
-// UpdatePod ...
-func (model *Model) UpdatePod(pod Pod) {
-	model.actions <- &action{"updatePod", func() error {
-		return model.addPod(pod)
-	}}
+// UpdatePod creates or updates a pod, waiting for the reducer so that it can
+// report which it did, and which of the pod's images are new to the model.
+func (model *Model) UpdatePod(pod Pod) (api.PodUpsertOutcome, []DockerImageSha) {
+	var outcome api.PodUpsertOutcome
+	var added []DockerImageSha
+	model.deferredDeletes.cancel(pod.QualifiedName())
+	model.call("updatePod", &JournalEntry{Pod: &pod}, func() error {
+		var err error
+		outcome, added, err = model.upsertPod(pod)
+		return err
+	})
+	return outcome, added
 }
 
-// DeletePod removes the record of a pod, but does not touch its images
-func (model *Model) DeletePod(podName string) {
-	model.actions <- &action{"deletePod", func() error {
+// DeletePod removes the record of a pod, but does not touch its images.
+// If the action queue is full, the deletion is handed off to be applied
+// later, in order with other deferred deletions, and `deferred` is true;
+// otherwise it waits for the reducer, and returns an error if the pod wasn't
+// present.  Adding or updating the pod before a deferred deletion is applied
+// cancels it.
+func (model *Model) DeletePod(podName string) (deferred bool, err error) {
+	// buffered, so that a deferred deletion doesn't block the reducer
+	a := &action{name: "deletePod", journal: &JournalEntry{PodName: podName}, mutates: true, done: make(chan error, 1), apply: func() error {
 		return model.deletePod(podName)
 	}}
+	a.enqueuedAt = model.queue.WillEnqueue()
+	select {
+	case model.actions <- a:
+		model.queue.DidEnqueue(a.enqueuedAt)
+		return false, <-a.done
+	default:
+		deletion := &deferredDelete{action: a, podName: podName}
+		a.apply = func() error {
+			if !model.deferredDeletes.take(deletion) {
+				a.journal = nil
+				a.mutates = false
+				return nil
+			}
+			return model.deletePod(podName)
+		}
+		model.deferredDeletes.add(deletion)
+		return true, nil
+	}
 }
 
-// SetPods ...
+// DeleteNamespacePods removes every pod in `namespace` in a single action,
+// returning their sorted qualified names.  A dry run only lists them.
+func (model *Model) DeleteNamespacePods(namespace string, dryRun bool) ([]string, error) {
+	var podNames []string
+	var journal *JournalEntry
+	if !dryRun {
+		journal = &JournalEntry{Namespace: namespace}
+	}
+	err := model.call("deleteNamespacePods", journal, func() error {
+		var err error
+		if dryRun {
+			podNames = model.podNamesInNamespace(namespace)
+		} else {
+			podNames, err = model.deleteNamespacePods(namespace)
+		}
+		return err
+	})
+	return podNames, err
+}
+
+// SetPods replaces every pod, cancelling any deferred deletions.
 func (model *Model) SetPods(pods []Pod) {
-	model.actions <- &action{"allPods", func() error {
+	model.deferredDeletes.cancelAll()
+	model.enqueue("allPods", &JournalEntry{Pods: pods}, func() error {
 		return model.allPods(pods)
-	}}
+	})
 }
 
 // AddImage ...
 func (model *Model) AddImage(image Image) {
-	model.actions <- &action{"addImage", func() error {
+	model.enqueue("addImage", &JournalEntry{Image: &image}, func() error {
 		return model.addImage(image)
-	}}
+	})
+}
+
+// AddImages adds a batch of images in a single action, and returns whether
+// each was new to the model.
+func (model *Model) AddImages(images []Image) []bool {
+	var added []bool
+	model.call("addImages", &JournalEntry{Images: images}, func() error {
+		var err error
+		added, err = model.addImages(images)
+		return err
+	})
+	return added
 }
 
 // SetImages ...
 func (model *Model) SetImages(images []Image) {
-	model.actions <- &action{"allImages", func() error {
+	model.enqueue("allImages", &JournalEntry{Images: images}, func() error {
 		return model.allImages(images)
-	}}
+	})
 }
 
 // FinishScanJob should be called when the scan client has finished.
-func (model *Model) FinishScanJob(image *Image, err error) {
-	log.Infof("finish scan job: %+v, %v", image, err)
-	model.actions <- &action{"finishScanJob", func() error {
-		return model.finishRunningScanClient(image, err)
-	}}
+// scanner identifies the scan client that ran the job; it may be empty.
+func (model *Model) FinishScanJob(image *Image, scanner string, leaseID string, err error) error {
+	log.Infof("finish scan job: %+v, %s, lease %s, %v", image, scanner, leaseID, err)
+	return model.call("finishScanJob", &JournalEntry{Image: image, Scanner: scanner, LeaseID: leaseID, ScanErr: errorString(err), FailureCategory: scanFailureCategory(err), FailureDetail: scanFailureDetail(err)}, func() error {
+		return model.finishRunningScanClient(image, scanner, leaseID, err)
+	})
+}
+
+// SetImageSize records the tarball size a scan client reported for an image,
+// for labeling its lifecycle metrics.
+func (model *Model) SetImageSize(sha DockerImageSha, sizeBytes int64) {
+	model.enqueue("setImageSize", nil, func() error {
+		imageInfo, ok := model.Images[sha]
+		if !ok {
+			return fmt.Errorf("unable to set size of image %s: not found", sha)
+		}
+		imageInfo.SizeBytes = sizeBytes
+		return nil
+	})
+}
+
+// RecordScanClient notes which scan client, and which versions of its
+// scanners, last finished a job on an image.
+func (model *Model) RecordScanClient(sha DockerImageSha, scanner string, scanCliVersion string, signatureScannerVersion string) {
+	record := &ScanClientRecord{Scanner: scanner, ScanCliVersion: scanCliVersion, SignatureScannerVersion: signatureScannerVersion, FinishedAt: time.Now()}
+	model.enqueue("recordScanClient", &JournalEntry{Sha: string(sha), ScanClient: record}, func() error {
+		return model.recordScanClient(sha, record)
+	})
+}
+
+// FinishDryRunScanJob puts an image which a dry-run scan client pretended to
+// scan back in the scan queue: nothing was uploaded to the hub, so there are
+// no scan results to wait for.
+func (model *Model) FinishDryRunScanJob(image *Image, scanner string, leaseID string) error {
+	log.Infof("finish dry run scan job: %+v, %s, lease %s", image, scanner, leaseID)
+	return model.call("finishDryRunScanJob", &JournalEntry{Image: image, Scanner: scanner, LeaseID: leaseID}, func() error {
+		return model.finishDryRunScanClient(image, scanner, leaseID)
+	})
 }
 
 // ScanDidFinish should be called when:
 // - the Hub scan finishes
 // - upon startup, when scan results are first fetched
 func (model *Model) ScanDidFinish(sha DockerImageSha, scanResults *hub.ScanResults) {
-	model.actions <- &action{"scanDidFinish", func() error {
+	model.enqueue("scanDidFinish", &JournalEntry{Sha: string(sha), ScanResults: scanResults}, func() error {
 		return model.scanDidFinish(sha, scanResults)
-	}}
+	})
 }
 
+// Read-only actions which don't return an error return their zero value if
+// the reducer fails to apply them; the failure is logged by applyAction.
+
 // GetScanResults ...
-func (model *Model) GetScanResults() api.ScanResults {
-	done := make(chan api.ScanResults)
-	model.actions <- &action{"getScanResults", func() error {
-		scanResults, err := scanResults(model)
-		go func() {
-			done <- scanResults
-		}()
+func (model *Model) GetScanResults(query *api.ScanResultsQuery) api.ScanResults {
+	var results api.ScanResults
+	model.call("getScanResults", nil, func() error {
+		var err error
+		results, err = scanResults(model, query)
 		return err
+	})
+	return results
+}
+
+// GetImageDetail looks up an image by sha or unambiguous sha prefix.
+func (model *Model) GetImageDetail(shaPrefix string) (*api.ImageDetail, error) {
+	var detail *api.ImageDetail
+	var err error
+	if callErr := model.call("getImageDetail", nil, func() error {
+		detail, err = imageDetail(model, shaPrefix)
+		return nil
+	}); callErr != nil {
+		return nil, callErr
+	}
+	return detail, err
+}
+
+// SubscribeScanResults streams scan result changes after `lastEventID`, or
+// from now on if it's empty.
+func (model *Model) SubscribeScanResults(lastEventID string, bufferSize int) (*api.ScanResultsSubscription, error) {
+	return model.changeLog.Subscribe(lastEventID, bufferSize)
+}
+
+// ImageQueued returns a channel which is closed the next time an action
+// leaves images in the scan queue -- whether or not the images are new.
+func (model *Model) ImageQueued() <-chan struct{} {
+	return model.imageQueued.wait()
+}
+
+// IsResponsive returns false if the reducer doesn't get to an action within `timeout`.
+func (model *Model) IsResponsive(timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	// buffered, so that a late reducer doesn't block
+	done := make(chan bool, 1)
+	a := &action{name: "ping", apply: func() error {
+		done <- true
+		return nil
 	}}
-	return <-done
+	a.enqueuedAt = model.queue.WillEnqueue()
+	select {
+	case model.actions <- a:
+		model.queue.DidEnqueue(a.enqueuedAt)
+	case <-deadline:
+		model.queue.AbandonEnqueue()
+		return false
+	}
+	select {
+	case <-done:
+		return true
+	case <-deadline:
+		return false
+	}
 }
 
-// GetModel ...
+// GetModel returns nil if the core model couldn't be built.
 func (model *Model) GetModel() *api.CoreModel {
-	done := make(chan *api.CoreModel)
-	model.actions <- &action{"getModel", func() error {
-		apiModel := coreModelToAPIModel(model)
-		go func() {
-			done <- apiModel
-		}()
+	var apiModel *api.CoreModel
+	model.call("getModel", nil, func() error {
+		apiModel = coreModelToAPIModel(model)
 		return nil
-	}}
-	return <-done
+	})
+	return apiModel
 }
 
 // GetImages returns images in that status
 func (model *Model) GetImages(status ScanStatus) []DockerImageSha {
-	done := make(chan []DockerImageSha)
-	model.actions <- &action{"getImages", func() error {
-		shas := model.getShas(status)
-		go func() {
-			done <- shas
-		}()
+	var shas []DockerImageSha
+	model.call("getImages", nil, func() error {
+		shas = model.getShas(status)
 		return nil
-	}}
-	return <-done
+	})
+	return shas
 }
 
 // GetMetrics calculates useful metrics for observing the progress of the model
-// over time.
+// over time.  It returns nil if they couldn't be calculated.
 func (model *Model) GetMetrics() *Metrics {
-	done := make(chan *Metrics)
-	model.actions <- &action{"getMetrics", func() error {
-		modelMetrics := metrics(model)
-		go func() {
-			done <- modelMetrics
-		}()
+	var modelMetrics *Metrics
+	model.call("getMetrics", nil, func() error {
+		modelMetrics = metrics(model)
 		return nil
-	}}
-	return <-done
+	})
+	return modelMetrics
 }
 
 // GetNextImage ...
 func (model *Model) GetNextImage() *Image {
-	done := make(chan *Image)
-	model.actions <- &action{"getNextImage", func() error {
+	var image *Image
+	model.call("getNextImage", nil, func() error {
 		log.Debugf("looking for next image to scan")
-		image, err := model.getNextImageFromScanQueue()
-		go func() {
-			done <- image
-		}()
+		var err error
+		image, err = model.getNextImageFromScanQueue()
 		return err
-	}}
-	return <-done
+	})
+	return image
+}
+
+// GetGeneration returns a number which changes whenever the model does.
+func (model *Model) GetGeneration() int64 {
+	var generation int64
+	model.call("getGeneration", nil, func() error {
+		generation = model.generation
+		return nil
+	})
+	return generation
+}
+
+// GetJournal returns the most recent journaled actions, oldest first.
+// It returns nil if journaling is disabled.
+func (model *Model) GetJournal() []*JournalEntry {
+	var entries []*JournalEntry
+	model.call("getJournal", nil, func() error {
+		if model.journal != nil {
+			entries = model.journal.Entries()
+		}
+		return nil
+	})
+	return entries
+}
+
+// SetNamespaceScanLimits sets the maximum number of concurrent scans for
+// each namespace.  Namespaces which aren't present are unlimited.
+func (model *Model) SetNamespaceScanLimits(limits map[string]int) {
+	model.enqueueConfig("setNamespaceScanLimits", func() error {
+		model.setNamespaceScanLimits(limits)
+		return nil
+	})
+}
+
+// GetNamespacePods returns the pods in `namespace` along with their scan results.
+func (model *Model) GetNamespacePods(namespace string) ([]NamespacePod, error) {
+	var pods []NamespacePod
+	err := model.call("getNamespacePods", nil, func() error {
+		var err error
+		pods, err = model.podsInNamespace(namespace)
+		return err
+	})
+	return pods, err
+}
+
+// GetPodDetail looks up a pod by namespace and name.
+func (model *Model) GetPodDetail(namespace string, name string) (*api.PodDetail, error) {
+	var detail *api.PodDetail
+	var err error
+	if callErr := model.call("getPodDetail", nil, func() error {
+		detail, err = podDetail(model, namespace, name)
+		return nil
+	}); callErr != nil {
+		return nil, callErr
+	}
+	return detail, err
+}
+
+// GetImagesByName looks up the images seen under a repository and tag.
+func (model *Model) GetImagesByName(repository string, tag string) (*api.ImagesByName, error) {
+	var images *api.ImagesByName
+	var err error
+	if callErr := model.call("getImagesByName", nil, func() error {
+		images, err = model.imagesNamed(repository, tag)
+		return nil
+	}); callErr != nil {
+		return nil, callErr
+	}
+	return images, err
+}
+
+// RequeueImage puts an image back in the scan queue, if it's being scanned
+// or its scan failed terminally.  It's journaled under the image's full sha,
+// once `shaPrefix` has been resolved.
+func (model *Model) RequeueImage(shaPrefix string, requester string) (*api.ImageRequeueResult, error) {
+	var result *api.ImageRequeueResult
+	a := &action{name: "requeueImage", done: make(chan error, 1)}
+	a.apply = func() error {
+		sha, _, err := findImageByShaPrefix(model, shaPrefix)
+		if err != nil {
+			return err
+		}
+		a.journal = &JournalEntry{Sha: string(sha), Requester: requester}
+		a.mutates = true
+		result, err = model.requeueImage(string(sha), requester)
+		return err
+	}
+	a.enqueuedAt = model.queue.WillEnqueue()
+	model.send(a)
+	return result, <-a.done
 }
 
 // StartScanClient ...
-func (model *Model) StartScanClient(sha DockerImageSha) error {
-	errCh := make(chan error)
-	model.actions <- &action{"startScanClient", func() error {
-		err := model.startScanClient(sha)
-		go func() {
-			errCh <- err
-		}()
+// StartScanClient hands an image to a scan client, returning the client's lease.
+func (model *Model) StartScanClient(sha DockerImageSha) (*ScanLease, error) {
+	leaseID := newScanLeaseID()
+	var lease ScanLease
+	err := model.call("startScanClient", &JournalEntry{Sha: string(sha), LeaseID: leaseID}, func() error {
+		err := model.startScanClient(sha, leaseID)
+		if err == nil {
+			lease = *model.Images[sha].Lease
+		}
 		return err
-	}}
-	return <-errCh
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &lease, nil
+}
+
+// AcknowledgeScanLease records that a scan client received the image it was
+// handed, so that its lease doesn't expire.
+func (model *Model) AcknowledgeScanLease(sha DockerImageSha, leaseID string) error {
+	return model.call("acknowledgeScanLease", &JournalEntry{Sha: string(sha), LeaseID: leaseID}, func() error {
+		return model.acknowledgeScanLease(sha, leaseID, time.Now())
+	})
+}
+
+// ExpireScanLeases requeues images whose leases weren't acknowledged in time.
+// It runs every few seconds, so it's only journaled -- and only changes the
+// model's generation -- when a lease actually expired; replaying the journal
+// doesn't need the ticks in between.
+func (model *Model) ExpireScanLeases() {
+	a := &action{name: "expireScanLeases"}
+	a.apply = func() error {
+		expired, err := model.expireScanLeases(time.Now())
+		if expired > 0 {
+			a.journal = &JournalEntry{}
+			a.mutates = true
+		}
+		return err
+	}
+	a.enqueuedAt = model.queue.WillEnqueue()
+	model.send(a)
+}
+
+// SetScanLeaseTTL applies to leases granted from now on.
+func (model *Model) SetScanLeaseTTL(ttl time.Duration) {
+	model.enqueue("setScanLeaseTTL", nil, func() error {
+		model.scanLeaseTTL = ttl
+		return nil
+	})
 }
 
 // Package API
@@ -241,21 +607,46 @@ func (model *Model) StartScanClient(sha DockerImageSha) error {
 // It extracts the containers and images from the pod,
 // adding them into the cache.
 func (model *Model) addPod(newPod Pod) error {
+	_, _, err := model.upsertPod(newPod)
+	return err
+}
+
+// upsertPod is the one path by which pods are added and updated.  A pod
+// whose UID differs from that of the stored pod of the same name replaces it.
+func (model *Model) upsertPod(newPod Pod) (api.PodUpsertOutcome, []DockerImageSha, error) {
 	log.Debugf("about to add pod: UID %s, qualified name %s", newPod.UID, newPod.QualifiedName())
 	if len(newPod.Containers) == 0 {
 		recordEvent("adding pod with 0 containers")
 		log.Warnf("adding pod %s with 0 containers: %+v", newPod.QualifiedName(), newPod)
 	}
+	outcome := api.PodUpsertOutcomeCreated
+	if oldPod, ok := model.Pods[newPod.QualifiedName()]; ok {
+		model.unindexPod(oldPod)
+		outcome = api.PodUpsertOutcomeUpdated
+		if oldPod.UID != newPod.UID {
+			log.Infof("replacing pod %s: UID changed from %s to %s", newPod.QualifiedName(), oldPod.UID, newPod.UID)
+			outcome = api.PodUpsertOutcomeReplaced
+		}
+	}
+	added := []DockerImageSha{}
 	errors := []error{}
 	for _, newCont := range newPod.Containers {
-		err := model.addImage(newCont.Image)
+		log.Debugf("about to add image %s, priority %d", newCont.Image.Sha, newCont.Image.Priority)
+		isNew, err := model.createImage(newCont.Image)
 		if err != nil {
 			errors = append(errors, err)
 		}
+		if isNew {
+			added = append(added, newCont.Image.Sha)
+		}
 	}
 	log.Debugf("done adding containers+images from pod %s -- %s", newPod.UID, newPod.QualifiedName())
 	model.Pods[newPod.QualifiedName()] = newPod
-	return combineErrors("adding pod images", errors)
+	model.indexPod(newPod)
+	if _, ok := model.podsAddedAt[newPod.QualifiedName()]; !ok || outcome == api.PodUpsertOutcomeReplaced {
+		model.podsAddedAt[newPod.QualifiedName()] = time.Now()
+	}
+	return outcome, added, combineErrors("adding pod images", errors)
 }
 
 // AddImage adds an image to the model, adding it to the queue for hub checking.
@@ -266,6 +657,30 @@ func (model *Model) addImage(image Image) error {
 	return err
 }
 
+func (model *Model) addImages(images []Image) ([]bool, error) {
+	added := make([]bool, len(images))
+	errors := []error{}
+	for i, image := range images {
+		var err error
+		added[i], err = model.createImage(image)
+		if err != nil {
+			errors = append(errors, err)
+		}
+	}
+	log.Debugf("added %d images in a batch of %d", countTrue(added), len(images))
+	return added, combineErrors("addImages", errors)
+}
+
+func countTrue(values []bool) int {
+	count := 0
+	for _, value := range values {
+		if value {
+			count++
+		}
+	}
+	return count
+}
+
 func (model *Model) scanDidFinish(sha DockerImageSha, scanResults *hub.ScanResults) error {
 	imageInfo, ok := model.Images[sha]
 	if !ok {
@@ -281,7 +696,7 @@ func (model *Model) scanDidFinish(sha DockerImageSha, scanResults *hub.ScanResul
 	} else if scanResults.ScanSummaryStatus() == hub.ScanSummaryStatusSuccess {
 		imageInfo.ScanResults = scanResults
 		switch imageInfo.ScanStatus {
-		case ScanStatusUnknown, ScanStatusInQueue, ScanStatusRunningScanClient, ScanStatusRunningHubScan:
+		case ScanStatusUnknown, ScanStatusInQueue, ScanStatusRunningScanClient, ScanStatusRunningHubScan, ScanStatusFailed:
 			return model.setImageScanStatus(sha, ScanStatusComplete)
 		default: // case ScanStatusComplete:
 			return nil // nothing to do
@@ -290,14 +705,16 @@ func (model *Model) scanDidFinish(sha DockerImageSha, scanResults *hub.ScanResul
 		switch imageInfo.ScanStatus {
 		case ScanStatusUnknown, ScanStatusInQueue:
 			return model.setImageScanStatus(sha, ScanStatusRunningHubScan)
-		default: // case ScanStatusRunningScanClient, ScanStatusRunningHubScan, ScanStatusComplete:
+		default: // case ScanStatusRunningScanClient, ScanStatusRunningHubScan, ScanStatusComplete, ScanStatusFailed:
 			return nil // nothing to do
 		}
 	} else { // hub.ScanSummaryStatusFailure
 		switch imageInfo.ScanStatus {
 		case ScanStatusUnknown, ScanStatusRunningHubScan:
+			imageInfo.AddScanFailure(NewScanFailure(ScanFailureStageHubScan, "hub scan failed", ""))
+			recordImageRequeue(requeueReasonFailed, ScanFailureStageHubScan)
 			return model.setImageScanStatus(sha, ScanStatusInQueue)
-		default: // case ScanStatusInQueue, ScanStatusRunningScanClient, ScanStatusComplete:
+		default: // case ScanStatusInQueue, ScanStatusRunningScanClient, ScanStatusComplete, ScanStatusFailed:
 			return fmt.Errorf("cannot handle scanDidFinish %s for image %s: cannot transition from state %s", imageInfo.ScanStatus, sha, imageInfo.ScanStatus.String())
 		}
 	}
@@ -311,6 +728,7 @@ func (model *Model) deleteImage(sha DockerImageSha) error {
 	if _, ok := model.Images[sha]; !ok {
 		return fmt.Errorf("unable to delete image %s, not found", sha)
 	}
+	model.unindexImageNames(sha)
 	delete(model.Images, sha)
 	return nil
 }
@@ -321,7 +739,10 @@ func (model *Model) leaveState(sha DockerImageSha, state ScanStatus) error {
 	switch state {
 	case ScanStatusInQueue:
 		return model.removeImageFromScanQueue(sha)
-	case ScanStatusUnknown, ScanStatusRunningScanClient, ScanStatusRunningHubScan, ScanStatusComplete:
+	case ScanStatusRunningScanClient:
+		model.Images[sha].Lease = nil
+		return nil
+	case ScanStatusUnknown, ScanStatusRunningHubScan, ScanStatusComplete, ScanStatusFailed:
 		return nil
 	default:
 		return fmt.Errorf("leaveState: invalid ScanStatus %d", state)
@@ -331,8 +752,15 @@ func (model *Model) leaveState(sha DockerImageSha, state ScanStatus) error {
 func (model *Model) enterState(sha DockerImageSha, state ScanStatus) error {
 	switch state {
 	case ScanStatusInQueue:
+		model.releaseScanNamespace(sha)
 		return model.addImageToScanQueue(sha)
-	case ScanStatusUnknown, ScanStatusRunningScanClient, ScanStatusRunningHubScan, ScanStatusComplete:
+	case ScanStatusRunningScanClient:
+		model.chargeScanNamespace(sha)
+		return nil
+	case ScanStatusUnknown, ScanStatusComplete, ScanStatusFailed:
+		model.releaseScanNamespace(sha)
+		return nil
+	case ScanStatusRunningHubScan:
 		return nil
 	default:
 		return fmt.Errorf("enterState: invalid ScanStatus %d", state)
@@ -360,6 +788,9 @@ func (model *Model) setImageScanStatusForSha(sha DockerImageSha, newScanStatus S
 		return errors.Annotatef(err, "unable to enter state %s for sha %s", newScanStatus, sha)
 	}
 	imageInfo.setScanStatus(newScanStatus)
+	if newScanStatus == ScanStatusComplete || newScanStatus == ScanStatusFailed {
+		recordScanLifecycle(imageInfo)
+	}
 
 	return nil
 }
@@ -368,7 +799,9 @@ func (model *Model) setImageScanStatusForSha(sha DockerImageSha, newScanStatus S
 func (model *Model) createImage(image Image) (bool, error) {
 	imageInfo, ok := model.Images[image.Sha]
 	added := !ok
+	model.indexImageName(image)
 	if ok {
+		imageInfo.AddRepoTag(&RepoTag{Repository: image.Repository, Tag: image.Tag})
 		newPriority, oldPriority := image.Priority, imageInfo.Priority
 		log.Debugf("not adding image %s to model, already have in cache", image.PullSpec())
 		if newPriority <= oldPriority {
@@ -448,8 +881,12 @@ func (model *Model) setImageScanStatus(sha DockerImageSha, newScanStatus ScanSta
 }
 
 // getNextImageFromScanQueue simply returns the item at the front of the scan queue,
-// non-destructively.
+// non-destructively.  If any namespaces have scan limits, images are skipped
+// unless at least one of their namespaces is under its limit.
 func (model *Model) getNextImageFromScanQueue() (*Image, error) {
+	if len(model.namespaceScanLimits) > 0 {
+		return model.getNextImageWithinNamespaceLimits()
+	}
 	first := model.ImageScanQueue.Peek()
 	switch sha := first.(type) {
 	case DockerImageSha:
@@ -462,9 +899,34 @@ func (model *Model) getNextImageFromScanQueue() (*Image, error) {
 	}
 }
 
+// getNextImageWithinNamespaceLimits walks the scan queue in order, without
+// modifying it, until it finds an eligible image.
+func (model *Model) getNextImageWithinNamespaceLimits() (*Image, error) {
+	var image *Image
+	var err error
+	model.ImageScanQueue.Walk(func(value interface{}) bool {
+		sha, ok := value.(DockerImageSha)
+		if !ok {
+			err = fmt.Errorf("expected type DockerImageSha from priority queue, got %s", reflect.TypeOf(value))
+			return false
+		}
+		if _, isEligible := model.chooseScanNamespace(model.imageNamespaces(sha)); isEligible {
+			next := model.unsafeGet(sha).Image()
+			image = &next
+			return false
+		}
+		return true
+	})
+	if err != nil || image != nil {
+		return image, err
+	}
+	recordEvent("all queued images are over their namespace scan limits")
+	return nil, nil
+}
+
 // startScanClient attempts to move `sha` from state InQueue to state RunningScanClient,
 // returning an error if the sha doesn't exist, or is not in state InQueue.
-func (model *Model) startScanClient(sha DockerImageSha) error {
+func (model *Model) startScanClient(sha DockerImageSha, leaseID string) error {
 	imageInfo, ok := model.Images[sha]
 	if !ok {
 		return fmt.Errorf("unable to start scan client for image %s, not found", sha)
@@ -472,19 +934,49 @@ func (model *Model) startScanClient(sha DockerImageSha) error {
 	if imageInfo.ScanStatus != ScanStatusInQueue {
 		return fmt.Errorf("unable to start scan client for image %s, not in state InQueue", sha)
 	}
-	return model.setImageScanStatus(sha, ScanStatusRunningScanClient)
+	queuedAt := imageInfo.TimeOfLastStatusChange
+	if err := model.setImageScanStatus(sha, ScanStatusRunningScanClient); err != nil {
+		return err
+	}
+	grantedAt := imageInfo.TimeOfLastStatusChange
+	imageInfo.Lease = &ScanLease{ID: leaseID, GrantedAt: grantedAt, ExpiresAt: grantedAt.Add(model.scanLeaseTTL)}
+	model.dispatches.add(&ScanDispatch{Sha: sha, QueuedAt: queuedAt, DispatchedAt: imageInfo.TimeOfLastStatusChange})
+	return nil
 }
 
-func (model *Model) finishRunningScanClient(image *Image, scanClientError error) error {
+func (model *Model) finishRunningScanClient(image *Image, scanner string, leaseID string, scanClientError error) error {
 	imageInfo, ok := model.Images[image.Sha]
 
 	// if we don't have this sha already, we don't need to do anything
 	if !ok {
 		return fmt.Errorf("finish running scan client -- expected to already have image %s, but did not", string(image.Sha))
 	}
+	if err := checkScanLease(imageInfo, leaseID); err != nil {
+		return err
+	}
 
 	scanStatus := ScanStatusRunningHubScan
 	if scanClientError != nil {
+		failure := NewScanFailure(ScanFailureStageScanClient, scanClientError.Error(), scanner)
+		failure.Category = scanFailureCategory(scanClientError)
+		failure.Detail = scanFailureDetail(scanClientError)
+		imageInfo.AddScanFailure(failure)
+		recordScanClientFailure(failure.Category)
+		if failure.Category == api.ScanFailureCategoryDigestMismatch {
+			expected, actual := string(image.Sha), "unknown"
+			if failure.Detail != nil {
+				expected, actual = failure.Detail.ExpectedDigest, failure.Detail.ActualDigest
+			}
+			log.Errorf("scanner %s pulled the wrong content for image %s: expected digest %s, found %s -- results would have been attributed to the wrong sha", scanner, image.Sha, expected, actual)
+		}
+		if failure.Category == api.ScanFailureCategoryImageTooLarge && failure.Detail != nil {
+			log.Warnf("skipping image %s: %d bytes is over the %d byte limit of scanner %s", image.Sha, failure.Detail.ImageSizeBytes, failure.Detail.MaxImageSizeBytes, scanner)
+		}
+		if api.IsTerminalScanFailureCategory(failure.Category) {
+			log.Warnf("scan of image %s failed terminally (%s), not requeueing: %s", image.Sha, failure.Category, failure.Err)
+			return model.setImageScanStatus(image.Sha, ScanStatusFailed)
+		}
+		recordImageRequeue(requeueReasonFailed, ScanFailureStageScanClient)
 		imageInfo.SetPriority(-1)
 		scanStatus = ScanStatusInQueue
 	}
@@ -492,6 +984,53 @@ func (model *Model) finishRunningScanClient(image *Image, scanClientError error)
 	return model.setImageScanStatus(image.Sha, scanStatus)
 }
 
+func (model *Model) finishDryRunScanClient(image *Image, scanner string, leaseID string) error {
+	imageInfo, ok := model.Images[image.Sha]
+	if !ok {
+		return fmt.Errorf("finish dry run scan client -- expected to already have image %s, but did not", string(image.Sha))
+	}
+	if err := checkScanLease(imageInfo, leaseID); err != nil {
+		return err
+	}
+	log.Debugf("dry run scan of image %s by %s finished, requeueing", image.Sha, scanner)
+	recordDryRunScan()
+	return model.setImageScanStatus(image.Sha, ScanStatusInQueue)
+}
+
+// requeueImage puts an image which is being scanned, or whose scan failed
+// terminally, back in the scan queue, keeping its priority, and records a
+// manual failure.
+func (model *Model) requeueImage(shaPrefix string, requester string) (*api.ImageRequeueResult, error) {
+	sha, imageInfo, err := findImageByShaPrefix(model, shaPrefix)
+	if err != nil {
+		return nil, err
+	}
+	var stage ScanFailureStage
+	switch imageInfo.ScanStatus {
+	case ScanStatusRunningScanClient:
+		stage = ScanFailureStageScanClient
+	case ScanStatusRunningHubScan:
+		stage = ScanFailureStageHubScan
+	case ScanStatusFailed:
+		stage = ScanFailureStageScanClient
+	default:
+		return nil, &api.ImageStateError{Sha: string(sha), ScanStatus: imageInfo.ScanStatus.String(), Message: "only images which are being scanned, or whose scans failed terminally, can be requeued"}
+	}
+	previousStatus := imageInfo.ScanStatus
+	failure := NewScanFailure(stage, fmt.Sprintf("requeued by %s", requester), "")
+	failure.Reason = ScanFailureReasonManual
+	imageInfo.AddScanFailure(failure)
+	if err := model.setImageScanStatus(sha, ScanStatusInQueue); err != nil {
+		return nil, err
+	}
+	recordImageRequeue(ScanFailureReasonManual, stage)
+	position, err := model.ImageScanQueue.Rank(string(sha))
+	if err != nil {
+		return nil, err
+	}
+	return &api.ImageRequeueResult{Sha: string(sha), PreviousScanStatus: previousStatus.String(), QueuePosition: position}, nil
+}
+
 func (model *Model) getShas(status ScanStatus) []DockerImageSha {
 	shas := []DockerImageSha{}
 	for sha, imageInfo := range model.Images {
@@ -503,16 +1042,30 @@ func (model *Model) getShas(status ScanStatus) []DockerImageSha {
 }
 
 func (model *Model) deletePod(podName string) error {
-	_, ok := model.Pods[podName]
+	pod, ok := model.Pods[podName]
 	if !ok {
-		return fmt.Errorf("unable to delete pod %s, pod not found", podName)
+		return &api.PodNotFoundError{QualifiedName: podName}
 	}
 	delete(model.Pods, podName)
+	delete(model.podsAddedAt, podName)
+	model.unindexPod(pod)
 	return nil
 }
 
+func (model *Model) deleteNamespacePods(namespace string) ([]string, error) {
+	podNames := model.podNamesInNamespace(namespace)
+	for _, podName := range podNames {
+		if err := model.deletePod(podName); err != nil {
+			return nil, err
+		}
+	}
+	return podNames, nil
+}
+
 func (model *Model) allPods(pods []Pod) error {
 	model.Pods = map[string]Pod{}
+	model.podsByNamespace = map[string]map[string]bool{}
+	model.namespacesByImage = map[DockerImageSha]map[string]int{}
 	errors := []error{}
 	for _, pod := range pods {
 		err := model.addPod(pod)
@@ -520,6 +1073,12 @@ func (model *Model) allPods(pods []Pod) error {
 			errors = append(errors, err)
 		}
 	}
+	// pods which are still present keep the time they were first added
+	for podName := range model.podsAddedAt {
+		if _, ok := model.Pods[podName]; !ok {
+			delete(model.podsAddedAt, podName)
+		}
+	}
 	return combineErrors("allPods", errors)
 }
 
@@ -32,6 +32,14 @@ func TestModel(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunActionTests()
 	RunModelTests()
+	RunChangeLogTests()
+	RunImageDetailTests()
+	RunJournalTests()
+	RunLifecycleTests()
+	RunNamespaceIndexTests()
+	RunNamespaceScanLimitTests()
+	RunScanFailureTests()
+	RunScanLeaseTests()
 	RunTestLegalScanStatusTransitions()
 	RunSpecs(t, "model suite")
 }
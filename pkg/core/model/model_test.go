@@ -26,10 +26,13 @@ import (
 	"fmt"
 	"sort"
 
+	"github.com/blackducksoftware/perceptor/pkg/api"
 	"github.com/blackducksoftware/perceptor/pkg/hub"
 	"github.com/blackducksoftware/perceptor/pkg/util"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -91,10 +94,10 @@ func RunModelTests() {
 			Expect(*image).To(Equal(image3))
 			Expect(err).To(BeNil())
 
-			Expect(model.startScanClient(image3.Sha)).To(BeNil())
+			Expect(model.startScanClient(image3.Sha, "lease-1")).To(BeNil())
 			Expect(model.Images[image3.Sha].ScanStatus).To(Equal(ScanStatusRunningScanClient))
 
-			Expect(model.finishRunningScanClient(image, fmt.Errorf("planned failure"))).To(BeNil())
+			Expect(model.finishRunningScanClient(image, "", "lease-1", fmt.Errorf("planned failure"))).To(BeNil())
 			Expect(model.Images[image3.Sha].ScanStatus).To(Equal(ScanStatusInQueue))
 			Expect(model.Images[image3.Sha].Priority).To(Equal(-1))
 
@@ -142,10 +145,11 @@ func RunModelTests() {
 				Expect(model.scanDidFinish(sha1, nil)).To(BeNil())
 				Expect(model.Images[sha1].ScanStatus).To(Equal(ScanStatusInQueue))
 				// 3. RunningScanClient
-				Expect(model.StartScanClient(sha1)).To(BeNil())
+				lease, err := model.StartScanClient(sha1)
+				Expect(err).To(BeNil())
 				Expect(model.Images[sha1].ScanStatus).To(Equal(ScanStatusRunningScanClient))
 				// 4. RunningHubScan
-				model.finishRunningScanClient(&image1, nil)
+				model.finishRunningScanClient(&image1, "", lease.ID, nil)
 				Expect(model.Images[sha1].ScanStatus).To(Equal(ScanStatusRunningHubScan))
 				// 5. Complete
 				results := &hub.ScanResults{
@@ -161,5 +165,246 @@ func RunModelTests() {
 				Expect(model.Images[sha1].ScanStatus).To(Equal(ScanStatusComplete))
 			})
 		})
+
+		Describe("Reducer panics", func() {
+			It("turns a panic into an error", func() {
+				err := applyAction(&action{name: "planned panic", apply: func() error {
+					panic("planned panic")
+				}})
+				Expect(err).ToNot(BeNil())
+			})
+
+			It("keeps processing actions after a panic", func() {
+				model := NewModel()
+				model.AddPod(pod1)
+				model.enqueue("planned panic", &JournalEntry{Pod: &pod2}, func() error {
+					var pod *Pod
+					return model.addPod(*pod)
+				})
+				model.AddPod(pod3)
+				apiModel := model.GetModel()
+				Expect(len(apiModel.Pods)).To(Equal(2))
+				Expect(namespaceIndex(model)).To(Equal(map[string][]string{
+					pod1.Namespace: {pod1.QualifiedName()},
+					pod3.Namespace: {pod3.QualifiedName()},
+				}))
+			})
+
+			It("returns to the caller of a read action which panics", func() {
+				model := NewModel()
+				model.AddImage(image1)
+				Expect(model.call("planned panic", nil, func() error {
+					panic("planned panic")
+				})).ToNot(BeNil())
+
+				model.enqueue("corrupt image", nil, func() error {
+					model.Images[image1.Sha] = nil
+					return nil
+				})
+				Expect(model.GetModel()).To(BeNil())
+				detail, err := model.GetImageDetail(string(image1.Sha))
+				Expect(detail).To(BeNil())
+				Expect(err).ToNot(BeNil())
+				Expect(model.GetGeneration()).To(Equal(int64(1)))
+			})
+		})
+
+		It("changes generation when the model changes", func() {
+			model := NewModel()
+			generation := model.GetGeneration()
+			model.GetModel()
+			Expect(model.GetGeneration()).To(Equal(generation))
+			model.AddImage(image1)
+			Expect(model.GetGeneration()).To(Equal(generation + 1))
+			Expect(model.GetModel().Generation).To(Equal(generation + 1))
+			model.SetNamespaceScanLimits(map[string]int{"ns1": 1})
+			Expect(model.GetGeneration()).To(Equal(generation + 2))
+		})
+
+		It("gives scan results the generation they were taken at", func() {
+			model := NewModel()
+			model.AddImage(image1)
+			generation := model.GetScanResults(&api.ScanResultsQuery{}).Generation
+			Expect(generation).To(Equal(model.GetGeneration()))
+			model.GetMetrics()
+			Expect(model.GetScanResults(&api.ScanResultsQuery{}).Generation).To(Equal(generation))
+			model.AddImage(image2)
+			Expect(model.GetScanResults(&api.ScanResultsQuery{}).Generation).To(Equal(generation + 1))
+		})
+
+		It("adds a batch of images in one action", func() {
+			model := NewModel()
+			model.AddImage(image1)
+			generation := model.GetGeneration()
+			added := model.AddImages([]Image{image1, image2, image3, image2})
+			Expect(added).To(Equal([]bool{false, true, true, false}))
+			Expect(model.GetGeneration()).To(Equal(generation + 1))
+			Expect(len(model.GetModel().Images)).To(Equal(3))
+		})
+
+		It("upserts pods, reporting what happened and which images are new", func() {
+			model := NewModel()
+			model.AddImage(image1)
+			pod := Pod{Name: "pod1", Namespace: "ns1", UID: "uid1", Containers: []Container{*NewContainer(image1, "c1"), *NewContainer(image2, "c2")}}
+			outcome, added := model.UpdatePod(pod)
+			Expect(outcome).To(Equal(api.PodUpsertOutcomeCreated))
+			Expect(added).To(Equal([]DockerImageSha{image2.Sha}))
+			pod.Containers = append(pod.Containers, *NewContainer(image3, "c3"))
+			outcome, added = model.UpdatePod(pod)
+			Expect(outcome).To(Equal(api.PodUpsertOutcomeUpdated))
+			Expect(added).To(Equal([]DockerImageSha{image3.Sha}))
+			pod.UID = "uid2"
+			outcome, added = model.UpdatePod(pod)
+			Expect(outcome).To(Equal(api.PodUpsertOutcomeReplaced))
+			Expect(added).To(BeEmpty())
+			Expect(model.GetModel().Pods[pod.QualifiedName()].UID).To(Equal("uid2"))
+		})
+
+		It("records dispatches to scan clients for scan queue stats", func() {
+			model := NewModel()
+			model.AddImage(image1)
+			model.AddImage(image2)
+			model.ScanDidFinish(sha1, nil)
+			model.ScanDidFinish(sha2, nil)
+			_, err := model.StartScanClient(sha1)
+			Expect(err).To(BeNil())
+			scanQueue := model.GetModel().ScanQueue
+			Expect(scanQueue.Entries).To(HaveLen(1))
+			Expect(scanQueue.Entries[0].Sha).To(Equal(string(sha2)))
+			Expect(scanQueue.Dispatches).To(HaveLen(1))
+			Expect(scanQueue.Dispatches[0].Sha).To(Equal(string(sha1)))
+			Expect(scanQueue.Dispatches[0].DispatchedAt).NotTo(BeTemporally("<", scanQueue.Dispatches[0].QueuedAt))
+		})
+
+		It("keeps only the most recent dispatches, oldest first", func() {
+			history := newDispatchHistory(3)
+			for _, sha := range []DockerImageSha{"a", "b", "c", "d", "e"} {
+				history.add(&ScanDispatch{Sha: sha})
+			}
+			shas := []string{}
+			for _, dispatch := range history.apiDispatches() {
+				shas = append(shas, dispatch.Sha)
+			}
+			Expect(shas).To(Equal([]string{"c", "d", "e"}))
+		})
+
+		It("requeues images from dry run scans without recording a failure", func() {
+			dryRuns := func() float64 {
+				metric := &dto.Metric{}
+				Expect(dryRunScanCounter.Write(metric)).To(BeNil())
+				return metric.GetCounter().GetValue()
+			}
+			before := dryRuns()
+			model := NewModel()
+			model.AddImage(image1)
+			model.ScanDidFinish(sha1, nil)
+			lease, err := model.StartScanClient(sha1)
+			Expect(err).To(BeNil())
+
+			Expect(model.FinishDryRunScanJob(&image1, "scanner-1", "not-the-lease")).To(BeAssignableToTypeOf(&api.ScanLeaseError{}))
+			Expect(model.FinishDryRunScanJob(&image1, "scanner-1", lease.ID)).To(BeNil())
+			image := model.GetModel().Images[string(sha1)]
+			Expect(image.ScanStatus).To(Equal("ScanStatusInQueue"))
+			Expect(image.Priority).To(Equal(image1.Priority))
+			Expect(image.FailureHistory).To(BeEmpty())
+			Expect(dryRuns()).To(Equal(before + 1))
+		})
+
+		It("requeues an image which is being scanned, recording why", func() {
+			manualRequeues := func() float64 {
+				metric := &dto.Metric{}
+				counter := imageRequeueCounter.With(prometheus.Labels{"reason": ScanFailureReasonManual, "stage": ScanFailureStageScanClient.String()})
+				Expect(counter.Write(metric)).To(BeNil())
+				return metric.GetCounter().GetValue()
+			}
+			before := manualRequeues()
+			model := NewModel()
+			model.AddImage(image1)
+			model.AddImage(image2)
+			model.ScanDidFinish(sha1, nil)
+			model.ScanDidFinish(sha2, nil)
+			_, err := model.StartScanClient(sha1)
+			Expect(err).To(BeNil())
+
+			result, err := model.RequeueImage(string(sha1), "ops")
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(&api.ImageRequeueResult{Sha: string(sha1), PreviousScanStatus: "ScanStatusRunningScanClient", QueuePosition: 1}))
+			Expect(manualRequeues()).To(Equal(before + 1))
+
+			image := model.GetModel().Images[string(sha1)]
+			Expect(image.ScanStatus).To(Equal("ScanStatusInQueue"))
+			failure := image.FailureHistory[len(image.FailureHistory)-1]
+			Expect(failure.Reason).To(Equal(ScanFailureReasonManual))
+			Expect(failure.Err).To(Equal("requeued by ops"))
+
+			_, err = model.RequeueImage(string(sha1), "ops")
+			Expect(err).To(Equal(&api.ImageStateError{Sha: string(sha1), ScanStatus: "ScanStatusInQueue", Message: "only images which are being scanned, or whose scans failed terminally, can be requeued"}))
+			_, err = model.RequeueImage("nope", "ops")
+			Expect(err).To(BeAssignableToTypeOf(&api.ImageNotFoundError{}))
+			Expect(manualRequeues()).To(Equal(before + 1))
+		})
+
+		It("applies deferred deletions in order, unless the pod is upserted first", func() {
+			journal, err := NewJournal(1000, "")
+			Expect(err).To(BeNil())
+			model := NewModelWithJournal(journal)
+			model.AddPod(pod1)
+			model.AddPod(pod2)
+			model.AddPod(pod3)
+			// hold up the reducer, and fill its queue
+			release := make(chan struct{})
+			model.enqueue("block", nil, func() error {
+				<-release
+				return nil
+			})
+			Eventually(func() int { return len(model.actions) }).Should(Equal(0))
+			for i := 0; i < actionChannelSize; i++ {
+				model.enqueue("noop", nil, func() error { return nil })
+			}
+			for _, pod := range []Pod{pod1, pod3, pod2} {
+				deferred, err := model.DeletePod(pod.QualifiedName())
+				Expect(deferred).To(BeTrue())
+				Expect(err).To(BeNil())
+			}
+			upserted := make(chan api.PodUpsertOutcome)
+			go func() {
+				outcome, _ := model.UpdatePod(pod1)
+				upserted <- outcome
+			}()
+			// the upsert cancels pod1's deletion before it waits for room in the queue
+			Eventually(func() int {
+				model.deferredDeletes.mutex.Lock()
+				defer model.deferredDeletes.mutex.Unlock()
+				return len(model.deferredDeletes.pending)
+			}).Should(Equal(2))
+			close(release)
+			Expect(<-upserted).To(Equal(api.PodUpsertOutcomeUpdated))
+			Eventually(model.deferredDeletes.unsent).Should(Equal(0))
+
+			pods := model.GetModel().Pods
+			Expect(pods).To(HaveKey(pod1.QualifiedName()))
+			Expect(pods).NotTo(HaveKey(pod2.QualifiedName()))
+			Expect(pods).NotTo(HaveKey(pod3.QualifiedName()))
+			deleted := []string{}
+			for _, entry := range model.GetJournal() {
+				if entry.Action == "deletePod" {
+					Expect(entry.Err).To(Equal(""))
+					deleted = append(deleted, entry.PodName)
+				}
+			}
+			Expect(deleted).To(Equal([]string{pod3.QualifiedName(), pod2.QualifiedName()}))
+		})
+
+		It("reports whether a deleted pod was present", func() {
+			model := NewModel()
+			model.AddPod(pod1)
+			deferred, err := model.DeletePod(pod1.QualifiedName())
+			Expect(deferred).To(BeFalse())
+			Expect(err).To(BeNil())
+			deferred, err = model.DeletePod(pod1.QualifiedName())
+			Expect(deferred).To(BeFalse())
+			Expect(err).To(Equal(&api.PodNotFoundError{QualifiedName: pod1.QualifiedName()}))
+			Expect(len(model.GetModel().Pods)).To(Equal(0))
+		})
 	})
 }
@@ -23,6 +23,8 @@ package model
 
 import (
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/blackducksoftware/perceptor/pkg/api" // TODO I hate how this package depends on the api package
 	"github.com/blackducksoftware/perceptor/pkg/hub"
@@ -81,11 +83,41 @@ func scanResultsForImage(model *Model, sha DockerImageSha) (*Scan, error) {
 	return imageScan, nil
 }
 
-func scanResults(model *Model) (api.ScanResults, error) {
+// podNamesForQuery uses the namespace index if the query is restricted to a namespace.
+func podNamesForQuery(model *Model, query *api.ScanResultsQuery) []string {
+	podNames := []string{}
+	if query.Namespace != "" {
+		for podName := range model.podsByNamespace[query.Namespace] {
+			podNames = append(podNames, podName)
+		}
+	} else {
+		for podName := range model.Pods {
+			podNames = append(podNames, podName)
+		}
+	}
+	sort.Strings(podNames)
+	return podNames
+}
+
+func scanResults(model *Model, query *api.ScanResultsQuery) (api.ScanResults, error) {
 	errors := []error{}
 	// pods
 	pods := []api.ScannedPod{}
-	for podName, pod := range model.Pods {
+	// the images of the matching pods, if the query filters anything out
+	var podImages map[DockerImageSha]bool
+	if !query.IsEmpty() {
+		podImages = map[DockerImageSha]bool{}
+	}
+	for _, podName := range podNamesForQuery(model, query) {
+		pod := model.Pods[podName]
+		if !query.MatchesPod(pod.Name, pod.Labels) {
+			continue
+		}
+		if podImages != nil {
+			for _, container := range pod.Containers {
+				podImages[container.Image.Sha] = true
+			}
+		}
 		podScan, err := scanResultsForPod(model, podName)
 		if err != nil {
 			errors = append(errors, fmt.Errorf("unable to retrieve scan results for Pod %s: %s", podName, err.Error()))
@@ -108,6 +140,9 @@ func scanResults(model *Model) (api.ScanResults, error) {
 		if imageInfo.ScanStatus != ScanStatusComplete {
 			continue
 		}
+		if podImages != nil && !podImages[sha] {
+			continue
+		}
 		if imageInfo.ScanResults == nil {
 			errors = append(errors, fmt.Errorf("model inconsistency: found ScanStatusComplete for image %s, but nil ScanResults (imageInfo %+v)", sha, imageInfo))
 			continue
@@ -123,8 +158,12 @@ func scanResults(model *Model) (api.ScanResults, error) {
 			ComponentsURL:    imageInfo.ScanResults.ComponentsHref}
 		images = append(images, apiImage)
 	}
+	// the same model must give the same bytes, for ETags
+	sort.Slice(images, func(i, j int) bool { return images[i].Sha < images[j].Sha })
 
-	return *api.NewScanResults(pods, images), combineErrors("scanResults", errors)
+	scanResults := api.NewScanResults(pods, images)
+	scanResults.Generation = model.generation
+	return *scanResults, combineErrors("scanResults", errors)
 }
 
 func coreContainerToAPIContainer(coreContainer Container) *api.Container {
@@ -146,6 +185,45 @@ func corePodToAPIPod(corePod Pod) *api.Pod {
 		Name:       corePod.Name,
 		Namespace:  corePod.Namespace,
 		UID:        corePod.UID,
+		Labels:     corePod.Labels,
+	}
+}
+
+func coreRepoTagsToAPIRepoTags(coreRepoTags []*RepoTag) []*api.ModelRepoTag {
+	repoTags := []*api.ModelRepoTag{}
+	for _, repoTag := range coreRepoTags {
+		repoTags = append(repoTags, &api.ModelRepoTag{Repository: repoTag.Repository, Tag: repoTag.Tag})
+	}
+	return repoTags
+}
+
+func coreScanFailuresToAPIScanFailures(coreFailures []*ScanFailure) []*api.ModelScanFailure {
+	failureHistory := []*api.ModelScanFailure{}
+	for _, failure := range coreFailures {
+		failureHistory = append(failureHistory, &api.ModelScanFailure{
+			Stage:    failure.Stage.String(),
+			Err:      failure.Err,
+			Scanner:  failure.Scanner,
+			Time:     failure.Time.String(),
+			Reason:   failure.Reason,
+			Category: failure.Category,
+			Detail:   failure.Detail,
+		})
+	}
+	return failureHistory
+}
+
+func coreImageTransitionToAPIImageTransition(it *ImageTransition) *api.ModelImageTransition {
+	errString := ""
+	if it.Err != nil {
+		errString = it.Err.Error()
+	}
+	return &api.ModelImageTransition{
+		Sha:  string(it.Sha),
+		From: it.From,
+		To:   it.To.String(),
+		Err:  errString,
+		Time: it.Time.String(),
 	}
 }
 
@@ -158,40 +236,75 @@ func coreModelToAPIModel(model *Model) *api.CoreModel {
 	// images
 	images := map[string]*api.ModelImageInfo{}
 	for imageSha, imageInfo := range model.Images {
-		repoTags := []*api.ModelRepoTag{}
-		for _, repoTag := range imageInfo.RepoTags {
-			repoTags = append(repoTags, &api.ModelRepoTag{Repository: repoTag.Repository, Tag: repoTag.Tag})
-		}
 		images[string(imageSha)] = &api.ModelImageInfo{
-			RepoTags:               repoTags,
+			RepoTags:               coreRepoTagsToAPIRepoTags(imageInfo.RepoTags),
 			ImageSha:               string(imageInfo.ImageSha),
 			ScanResults:            imageInfo.ScanResults,
 			ScanStatus:             imageInfo.ScanStatus.String(),
 			TimeOfLastStatusChange: imageInfo.TimeOfLastStatusChange.String(),
 			Priority:               imageInfo.Priority,
+			FailureHistory:         coreScanFailuresToAPIScanFailures(imageInfo.FailureHistory),
 		}
 	}
 	// image transitions
 	imageTransitions := make([]*api.ModelImageTransition, len(model.ImageTransitions))
 	for ix, it := range model.ImageTransitions {
-		errString := ""
-		if it.Err != nil {
-			errString = it.Err.Error()
+		imageTransitions[ix] = coreImageTransitionToAPIImageTransition(it)
+	}
+	// journal
+	var journal []*api.ModelJournalEntry
+	if model.journal != nil {
+		entries := model.journal.Entries()
+		journal = make([]*api.ModelJournalEntry, len(entries))
+		for ix, entry := range entries {
+			journal[ix] = &api.ModelJournalEntry{
+				Action:  entry.Action,
+				Time:    entry.Time.String(),
+				Sha:     entry.Sha,
+				PodName: entry.PodName,
+				Outcome: entry.Outcome(),
+				Err:     entry.Err,
+			}
+		}
+	}
+	// namespace scan limits
+	namespaceScanLimits := map[string]int{}
+	for namespace, limit := range model.namespaceScanLimits {
+		namespaceScanLimits[namespace] = limit
+	}
+	namespaceScansInFlight := map[string]int{}
+	for namespace, count := range model.namespaceScansInFlight {
+		namespaceScansInFlight[namespace] = count
+	}
+	// scan queue
+	scanQueue := &api.ModelScanQueue{
+		Entries:    []*api.ModelScanQueueEntry{},
+		Dispatches: model.dispatches.apiDispatches(),
+	}
+	for _, value := range model.ImageScanQueue.Values() {
+		sha, ok := value.(DockerImageSha)
+		if !ok {
+			continue
 		}
-		imageTransitions[ix] = &api.ModelImageTransition{
-			Sha:  string(it.Sha),
-			From: it.From,
-			To:   it.To.String(),
-			Err:  errString,
-			Time: it.Time.String(),
+		if imageInfo, ok := model.Images[sha]; ok {
+			scanQueue.Entries = append(scanQueue.Entries, &api.ModelScanQueueEntry{
+				Sha:      string(sha),
+				Priority: imageInfo.Priority,
+				QueuedAt: imageInfo.TimeOfLastStatusChange,
+			})
 		}
 	}
 	// return value
 	return &api.CoreModel{
-		Pods:             pods,
-		Images:           images,
-		ImageScanQueue:   model.ImageScanQueue.Dump(),
-		ImageTransitions: imageTransitions,
+		Pods:                   pods,
+		Images:                 images,
+		ImageScanQueue:         model.ImageScanQueue.Dump(),
+		ImageTransitions:       imageTransitions,
+		Journal:                journal,
+		NamespaceScanLimits:    namespaceScanLimits,
+		NamespaceScansInFlight: namespaceScansInFlight,
+		Generation:             model.generation,
+		ScanQueue:              scanQueue,
 	}
 }
 
@@ -272,5 +385,6 @@ func metrics(model *Model) *Metrics {
 		ImagePolicyViolations: imagePolicyViolations,
 		PodVulnerabilities:    podVulnerabilities,
 		ImageVulnerabilities:  imageVulnerabilities,
+		MedianStageAges:       medianStageAges(model.Images, time.Now()),
 	}
 }
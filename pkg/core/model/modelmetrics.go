@@ -21,6 +21,8 @@ under the License.
 
 package model
 
+import "time"
+
 // Metrics .....
 type Metrics struct {
 	ScanStatusCounts      map[ScanStatus]int
@@ -34,4 +36,7 @@ type Metrics struct {
 	ImagePolicyViolations map[int]int
 	PodVulnerabilities    map[int]int
 	ImageVulnerabilities  map[int]int
+	// MedianStageAges is how long the images in each scan pipeline stage
+	// have typically been there
+	MedianStageAges map[string]time.Duration
 }
@@ -0,0 +1,122 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/juju/errors"
+)
+
+// NamespacePod is a pod along with its rolled-up scan results.  Scan is nil
+// if any of the pod's images have not finished scanning.
+type NamespacePod struct {
+	Pod  Pod
+	Scan *Scan
+}
+
+func (model *Model) indexPod(pod Pod) {
+	pods, ok := model.podsByNamespace[pod.Namespace]
+	if !ok {
+		pods = map[string]bool{}
+		model.podsByNamespace[pod.Namespace] = pods
+	}
+	pods[pod.QualifiedName()] = true
+	for sha := range podImages(pod) {
+		namespaces, ok := model.namespacesByImage[sha]
+		if !ok {
+			namespaces = map[string]int{}
+			model.namespacesByImage[sha] = namespaces
+		}
+		namespaces[pod.Namespace]++
+	}
+}
+
+func (model *Model) unindexPod(pod Pod) {
+	pods, ok := model.podsByNamespace[pod.Namespace]
+	if !ok {
+		return
+	}
+	delete(pods, pod.QualifiedName())
+	if len(pods) == 0 {
+		delete(model.podsByNamespace, pod.Namespace)
+	}
+	for sha := range podImages(pod) {
+		namespaces := model.namespacesByImage[sha]
+		namespaces[pod.Namespace]--
+		if namespaces[pod.Namespace] <= 0 {
+			delete(namespaces, pod.Namespace)
+		}
+		if len(namespaces) == 0 {
+			delete(model.namespacesByImage, sha)
+		}
+	}
+}
+
+// podImages returns the distinct images of a pod's containers.
+func podImages(pod Pod) map[DockerImageSha]bool {
+	shas := map[DockerImageSha]bool{}
+	for _, cont := range pod.Containers {
+		shas[cont.Image.Sha] = true
+	}
+	return shas
+}
+
+// imageNamespaces returns the sorted namespaces of the pods referencing `sha`.
+func (model *Model) imageNamespaces(sha DockerImageSha) []string {
+	namespaces := make([]string, 0, len(model.namespacesByImage[sha]))
+	for namespace := range model.namespacesByImage[sha] {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// podNamesInNamespace returns the sorted qualified names of the pods in `namespace`.
+func (model *Model) podNamesInNamespace(namespace string) []string {
+	podNames := make([]string, 0, len(model.podsByNamespace[namespace]))
+	for podName := range model.podsByNamespace[namespace] {
+		podNames = append(podNames, podName)
+	}
+	sort.Strings(podNames)
+	return podNames
+}
+
+// podsInNamespace looks up pods through the namespace index, so that its cost
+// is proportional to the number of pods in `namespace`, not in the model.
+func (model *Model) podsInNamespace(namespace string) ([]NamespacePod, error) {
+	podNames := model.podsByNamespace[namespace]
+	pods := make([]NamespacePod, 0, len(podNames))
+	for podName := range podNames {
+		pod, ok := model.Pods[podName]
+		if !ok {
+			return nil, fmt.Errorf("model inconsistency: pod %s found in namespace index but not in pods", podName)
+		}
+		scan, err := scanResultsForPod(model, podName)
+		if err != nil {
+			return nil, errors.Annotatef(err, "unable to get scan results for pod %s", podName)
+		}
+		pods = append(pods, NamespacePod{Pod: pod, Scan: scan})
+	}
+	return pods, nil
+}
@@ -0,0 +1,181 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	"github.com/blackducksoftware/perceptor/pkg/hub"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func namespaceIndex(model *Model) map[string][]string {
+	index := map[string][]string{}
+	for namespace, pods := range model.podsByNamespace {
+		for podName := range pods {
+			index[namespace] = append(index[namespace], podName)
+		}
+	}
+	return index
+}
+
+func RunNamespaceIndexTests() {
+	Describe("namespace index", func() {
+		It("should describe a pod, keeping the time it was first added", func() {
+			model := NewModel()
+			Expect(model.addPod(pod1)).To(BeNil())
+			detail, err := podDetail(model, "ns1", "pod1")
+			Expect(err).To(BeNil())
+			Expect(detail.Status).To(Equal(api.PodStatusPending))
+			Expect(detail.OverallStatus).To(Equal(""))
+			Expect(detail.Containers).To(HaveLen(2))
+			Expect(detail.Containers[1].Sha).To(Equal(string(sha2)))
+			Expect(detail.Containers[1].ScanStatus).To(Equal("ScanStatusUnknown"))
+			Expect(detail.Containers[1].ScanSummary).To(BeNil())
+			timeAdded := detail.TimeAdded
+			Expect(timeAdded).To(Equal(model.podsAddedAt["ns1/pod1"].String()))
+
+			model.Images[sha2].AddScanFailure(NewScanFailure(ScanFailureStageScanClient, "oops", ""))
+			Expect(model.addPod(pod1)).To(BeNil())
+			Expect(model.allPods([]Pod{pod1, pod3})).To(BeNil())
+			detail, err = podDetail(model, "ns1", "pod1")
+			Expect(err).To(BeNil())
+			Expect(detail.Status).To(Equal(api.PodStatusFailed))
+			Expect(detail.TimeAdded).To(Equal(timeAdded))
+
+			Expect(model.allPods([]Pod{pod3})).To(BeNil())
+			Expect(model.podsAddedAt).To(HaveLen(1))
+			_, err = podDetail(model, "ns1", "pod1")
+			Expect(err).To(Equal(&api.PodNotFoundError{QualifiedName: "ns1/pod1"}))
+			_, err = podDetail(model, "ns1", "pod3")
+			Expect(err).To(Equal(&api.PodNotFoundError{QualifiedName: "ns1/pod3"}))
+		})
+
+		It("should track added, updated and deleted pods", func() {
+			model := NewModel()
+			Expect(model.addPod(pod1)).To(BeNil())
+			Expect(model.addPod(pod3)).To(BeNil())
+			Expect(namespaceIndex(model)).To(Equal(map[string][]string{"ns1": {"ns1/pod1"}, "ns3": {"ns3/pod3"}}))
+
+			updatedPod1 := *NewPod("pod1", "pod1uid", "ns1", []Container{cont3})
+			Expect(model.addPod(updatedPod1)).To(BeNil())
+			Expect(namespaceIndex(model)).To(Equal(map[string][]string{"ns1": {"ns1/pod1"}, "ns3": {"ns3/pod3"}}))
+
+			Expect(model.deletePod(pod3.QualifiedName())).To(BeNil())
+			Expect(namespaceIndex(model)).To(Equal(map[string][]string{"ns1": {"ns1/pod1"}}))
+			Expect(model.deletePod(pod3.QualifiedName())).NotTo(BeNil())
+			Expect(namespaceIndex(model)).To(Equal(map[string][]string{"ns1": {"ns1/pod1"}}))
+		})
+
+		It("should be rebuilt by allPods", func() {
+			model := createNewModel2()
+			Expect(model.allPods([]Pod{pod3, pod4})).To(BeNil())
+			Expect(namespaceIndex(model)).To(Equal(map[string][]string{"ns3": {"ns3/pod3"}, "ns4": {"ns4/pod4"}}))
+			Expect(model.allPods([]Pod{})).To(BeNil())
+			Expect(namespaceIndex(model)).To(Equal(map[string][]string{}))
+		})
+
+		It("should return pods with their rolled-up scan results", func() {
+			model := createNewModel2()
+			pods, err := model.podsInNamespace("ns1")
+			Expect(err).To(BeNil())
+			Expect(len(pods)).To(Equal(2))
+			scans := map[string]*Scan{}
+			for _, pod := range pods {
+				scans[pod.Pod.QualifiedName()] = pod.Scan
+			}
+			Expect(scans[pod1.QualifiedName()]).To(BeNil())
+			Expect(scans[pod2.QualifiedName()].OverallStatus).To(Equal(hub.PolicyStatusTypeInViolation))
+			Expect(scans[pod2.QualifiedName()].PolicyViolations).To(Equal(3))
+
+			pods, err = model.podsInNamespace("missing")
+			Expect(err).To(BeNil())
+			Expect(pods).To(Equal([]NamespacePod{}))
+		})
+
+		It("should be available through the public API", func() {
+			model := createNewModel2()
+			pods, err := model.GetNamespacePods("ns3")
+			Expect(err).To(BeNil())
+			Expect(len(pods)).To(Equal(1))
+			Expect(pods[0].Pod).To(Equal(pod3))
+		})
+
+		It("should delete every pod in a namespace in one action", func() {
+			model := createNewModel2()
+			podNames, err := model.DeleteNamespacePods("ns1", true)
+			Expect(err).To(BeNil())
+			Expect(podNames).To(Equal([]string{"ns1/pod1", "ns1/pod2"}))
+			Expect(model.Pods).To(HaveKey("ns1/pod1"))
+
+			podNames, err = model.DeleteNamespacePods("ns1", false)
+			Expect(err).To(BeNil())
+			Expect(podNames).To(Equal([]string{"ns1/pod1", "ns1/pod2"}))
+			Expect(namespaceIndex(model)).NotTo(HaveKey("ns1"))
+			Expect(model.Pods).NotTo(HaveKey("ns1/pod1"))
+			Expect(model.Pods).NotTo(HaveKey("ns1/pod2"))
+			Expect(model.podsAddedAt).NotTo(HaveKey("ns1/pod1"))
+
+			podNames, err = model.DeleteNamespacePods("ns1", false)
+			Expect(err).To(BeNil())
+			Expect(podNames).To(Equal([]string{}))
+		})
+	})
+}
+
+func createNamespacedModel(namespaces int, podsPerNamespace int) *Model {
+	model := NewModel()
+	for ns := 0; ns < namespaces; ns++ {
+		for p := 0; p < podsPerNamespace; p++ {
+			sha := DockerImageSha(fmt.Sprintf("sha-%d-%d", ns, p))
+			image := *NewImage("repo", "tag", sha, 1)
+			pod := *NewPod(fmt.Sprintf("pod%d", p), "uid", fmt.Sprintf("ns%d", ns), []Container{*NewContainer(image, "cont")})
+			model.addPod(pod)
+		}
+	}
+	return model
+}
+
+func benchmarkPodsInNamespace(b *testing.B, namespaces int) {
+	model := createNamespacedModel(namespaces, 50)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pods, err := model.podsInNamespace("ns0")
+		if err != nil || len(pods) != 50 {
+			b.Fatalf("expected 50 pods, got %d (%v)", len(pods), err)
+		}
+	}
+}
+
+// BenchmarkPodsInNamespace1500 queries a 50-pod namespace in a 1500-pod model.
+func BenchmarkPodsInNamespace1500(b *testing.B) {
+	benchmarkPodsInNamespace(b, 30)
+}
+
+// BenchmarkPodsInNamespace15000 queries a 50-pod namespace in a 15000-pod model;
+// it should cost about the same as BenchmarkPodsInNamespace1500.
+func BenchmarkPodsInNamespace15000(b *testing.B) {
+	benchmarkPodsInNamespace(b, 300)
+}
@@ -0,0 +1,92 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// namespaceBudget returns how many more scans `namespace` may run, and whether
+// it is limited at all.
+func (model *Model) namespaceBudget(namespace string) (int, bool) {
+	limit, ok := model.namespaceScanLimits[namespace]
+	if !ok {
+		return 0, false
+	}
+	return limit - model.namespaceScansInFlight[namespace], true
+}
+
+// chooseScanNamespace picks the namespace whose budget a scan should consume:
+// an unlimited namespace if there is one, otherwise the namespace with the most
+// remaining budget.  It returns false if every namespace is at its limit.
+// Images which aren't referenced from any pod aren't charged to any namespace.
+func (model *Model) chooseScanNamespace(namespaces []string) (string, bool) {
+	if len(namespaces) == 0 {
+		return "", true
+	}
+	chosen := ""
+	chosenBudget := 0
+	for _, namespace := range namespaces {
+		budget, isLimited := model.namespaceBudget(namespace)
+		if !isLimited {
+			return namespace, true
+		}
+		if budget > chosenBudget {
+			chosen = namespace
+			chosenBudget = budget
+		}
+	}
+	return chosen, chosen != ""
+}
+
+func (model *Model) chargeScanNamespace(sha DockerImageSha) {
+	namespaces := model.imageNamespaces(sha)
+	namespace, ok := model.chooseScanNamespace(namespaces)
+	if !ok {
+		log.Warnf("starting scan of %s even though all of its namespaces are at their scan limit", sha)
+		namespace = namespaces[0]
+	}
+	if namespace == "" {
+		return
+	}
+	model.scanNamespaces[sha] = namespace
+	model.namespaceScansInFlight[namespace]++
+}
+
+func (model *Model) releaseScanNamespace(sha DockerImageSha) {
+	namespace, ok := model.scanNamespaces[sha]
+	if !ok {
+		return
+	}
+	delete(model.scanNamespaces, sha)
+	model.namespaceScansInFlight[namespace]--
+	if model.namespaceScansInFlight[namespace] <= 0 {
+		delete(model.namespaceScansInFlight, namespace)
+	}
+}
+
+func (model *Model) setNamespaceScanLimits(limits map[string]int) {
+	model.namespaceScanLimits = map[string]int{}
+	for namespace, limit := range limits {
+		model.namespaceScanLimits[namespace] = limit
+	}
+}
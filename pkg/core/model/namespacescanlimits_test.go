@@ -0,0 +1,154 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunNamespaceScanLimitTests() {
+	Describe("namespace scan limits", func() {
+		// pod1 (ns1): image1, image2; pod3 (ns3): image3
+		limitedModel := func(limits map[string]int) *Model {
+			model := NewModel()
+			model.setNamespaceScanLimits(limits)
+			Expect(model.addPod(pod1)).To(BeNil())
+			Expect(model.addPod(pod3)).To(BeNil())
+			for _, sha := range []DockerImageSha{sha1, sha2, sha3} {
+				Expect(model.setImageScanStatus(sha, ScanStatusInQueue)).To(BeNil())
+			}
+			return model
+		}
+
+		It("should skip images whose namespaces are at their limit", func() {
+			model := limitedModel(map[string]int{"ns3": 0})
+			image, err := model.getNextImageFromScanQueue()
+			Expect(err).To(BeNil())
+			Expect(image.Sha).To(Equal(sha2))
+			// skipping doesn't lose anything from the queue
+			Expect(sortedValues(model.ImageScanQueue)).To(Equal([]interface{}{sha1, sha2, sha3}))
+		})
+
+		It("should charge and release a namespace's budget", func() {
+			model := limitedModel(map[string]int{"ns1": 1})
+			image, err := model.getNextImageFromScanQueue()
+			Expect(err).To(BeNil())
+			Expect(image.Sha).To(Equal(sha3))
+			Expect(model.startScanClient(sha3, "lease-"+string(sha3))).To(BeNil())
+			Expect(model.scanNamespaces[sha3]).To(Equal("ns3"))
+
+			image, err = model.getNextImageFromScanQueue()
+			Expect(err).To(BeNil())
+			Expect(image.Sha).To(Equal(sha2))
+			Expect(model.startScanClient(sha2, "lease-"+string(sha2))).To(BeNil())
+			Expect(model.namespaceScansInFlight).To(Equal(map[string]int{"ns1": 1, "ns3": 1}))
+
+			// ns1 is full
+			image, err = model.getNextImageFromScanQueue()
+			Expect(err).To(BeNil())
+			Expect(image).To(BeNil())
+
+			// the budget remains consumed during the hub scan
+			Expect(model.finishRunningScanClient(&image2, "", "lease-"+string(sha2), nil)).To(BeNil())
+			Expect(model.namespaceScansInFlight["ns1"]).To(Equal(1))
+
+			// ... and is released when the image goes back into the queue
+			Expect(model.setImageScanStatus(sha2, ScanStatusInQueue)).To(BeNil())
+			Expect(model.namespaceScansInFlight).To(Equal(map[string]int{"ns3": 1}))
+			image, err = model.getNextImageFromScanQueue()
+			Expect(err).To(BeNil())
+			Expect(image.Sha).To(Equal(sha2))
+		})
+
+		It("should prefer a namespace with available budget", func() {
+			model := NewModel()
+			model.setNamespaceScanLimits(map[string]int{"ns1": 1, "ns3": 2})
+			shared := *NewPod("shared", "shareduid", "ns3", []Container{cont1})
+			Expect(model.addPod(pod1)).To(BeNil())
+			Expect(model.addPod(shared)).To(BeNil())
+			Expect(model.setImageScanStatus(sha1, ScanStatusInQueue)).To(BeNil())
+			Expect(model.setImageScanStatus(sha2, ScanStatusInQueue)).To(BeNil())
+
+			Expect(model.startScanClient(sha2, "lease-"+string(sha2))).To(BeNil())
+			Expect(model.scanNamespaces[sha2]).To(Equal("ns1"))
+			image, err := model.getNextImageFromScanQueue()
+			Expect(err).To(BeNil())
+			Expect(image.Sha).To(Equal(sha1))
+			Expect(model.startScanClient(sha1, "lease-"+string(sha1))).To(BeNil())
+			Expect(model.scanNamespaces[sha1]).To(Equal("ns3"))
+		})
+
+		It("should leave the queue's order alone while skipping images", func() {
+			model := NewModel()
+			model.setNamespaceScanLimits(map[string]int{"ns3": 0})
+			images := []Image{}
+			for _, name := range []string{"a", "b", "c"} {
+				images = append(images, *NewImage(name, "1", DockerImageSha(name), 1))
+			}
+			Expect(model.addPod(*NewPod("full", "fulluid", "ns3", []Container{*NewContainer(images[0], "cont")}))).To(BeNil())
+			for _, image := range images {
+				Expect(model.addImage(image)).To(BeNil())
+				Expect(model.setImageScanStatus(image.Sha, ScanStatusInQueue)).To(BeNil())
+			}
+			queueOrder := func() []interface{} {
+				order := []interface{}{}
+				model.ImageScanQueue.Walk(func(value interface{}) bool {
+					order = append(order, value)
+					return true
+				})
+				return order
+			}
+			for i := 0; i < 3; i++ {
+				image, err := model.getNextImageFromScanQueue()
+				Expect(err).To(BeNil())
+				Expect(image.Sha).To(Equal(DockerImageSha("b")))
+				Expect(queueOrder()).To(Equal([]interface{}{DockerImageSha("a"), DockerImageSha("b"), DockerImageSha("c")}))
+			}
+		})
+
+		It("should follow pods' images as they change", func() {
+			model := NewModel()
+			Expect(model.addPod(pod1)).To(BeNil())
+			shared := *NewPod("shared", "shareduid", "ns3", []Container{cont1})
+			Expect(model.addPod(shared)).To(BeNil())
+			Expect(model.imageNamespaces(sha1)).To(Equal([]string{"ns1", "ns3"}))
+			Expect(model.imageNamespaces(sha2)).To(Equal([]string{"ns1"}))
+
+			Expect(model.addPod(*NewPod("pod1", "pod1uid", "ns1", []Container{cont3}))).To(BeNil())
+			Expect(model.imageNamespaces(sha1)).To(Equal([]string{"ns3"}))
+			Expect(model.imageNamespaces(sha2)).To(BeEmpty())
+			Expect(model.imageNamespaces(sha3)).To(Equal([]string{"ns1"}))
+
+			Expect(model.deletePod(shared.QualifiedName())).To(BeNil())
+			Expect(model.namespacesByImage).To(Equal(map[DockerImageSha]map[string]int{sha3: {"ns1": 1}}))
+		})
+
+		It("should expose in-flight counts in the API model", func() {
+			model := limitedModel(map[string]int{"ns1": 3})
+			Expect(model.startScanClient(sha1, "lease-"+string(sha1))).To(BeNil())
+			apiModel := coreModelToAPIModel(model)
+			Expect(apiModel.NamespaceScanLimits).To(Equal(map[string]int{"ns1": 3}))
+			Expect(apiModel.NamespaceScansInFlight).To(Equal(map[string]int{"ns1": 1}))
+		})
+	})
+}
@@ -29,6 +29,7 @@ type Pod struct {
 	UID        string
 	Namespace  string
 	Containers []Container
+	Labels     map[string]string
 }
 
 // QualifiedName .....
@@ -45,6 +46,15 @@ func (pod *Pod) hasImage(image Image) bool {
 	return false
 }
 
+func (pod *Pod) hasImageSha(sha DockerImageSha) bool {
+	for _, cont := range pod.Containers {
+		if cont.Image.Sha == sha {
+			return true
+		}
+	}
+	return false
+}
+
 // NewPod .....
 func NewPod(name string, uid string, namespace string, containers []Container) *Pod {
 	return &Pod{
@@ -0,0 +1,104 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	"github.com/juju/errors"
+)
+
+// podDetail looks `namespace`/`name` up through the namespace index.
+func podDetail(model *Model, namespace string, name string) (*api.PodDetail, error) {
+	qualifiedName := fmt.Sprintf("%s/%s", namespace, name)
+	if !model.podsByNamespace[namespace][qualifiedName] {
+		return nil, &api.PodNotFoundError{QualifiedName: qualifiedName}
+	}
+	pod, ok := model.Pods[qualifiedName]
+	if !ok {
+		return nil, fmt.Errorf("model inconsistency: pod %s found in namespace index but not in pods", qualifiedName)
+	}
+	addedAt := model.podsAddedAt[qualifiedName]
+	lastStatusChange := addedAt
+	containers := []*api.PodContainerDetail{}
+	shas := map[DockerImageSha]bool{}
+	completeImages, failedImages := 0, 0
+	for _, cont := range pod.Containers {
+		imageInfo, ok := model.Images[cont.Image.Sha]
+		if !ok {
+			return nil, fmt.Errorf("model inconsistency: image %s of pod %s not found", cont.Image.Sha, qualifiedName)
+		}
+		container := &api.PodContainerDetail{
+			Name:       cont.Name,
+			Repository: cont.Image.Repository,
+			Tag:        cont.Image.Tag,
+			Sha:        string(cont.Image.Sha),
+			ScanStatus: imageInfo.ScanStatus.String(),
+		}
+		if imageInfo.ScanResults != nil {
+			container.ScanSummary = &api.ScannedImage{
+				Repository:       cont.Image.Repository,
+				Tag:              cont.Image.Tag,
+				Sha:              string(cont.Image.Sha),
+				PolicyViolations: imageInfo.ScanResults.PolicyViolationCount(),
+				Vulnerabilities:  imageInfo.ScanResults.VulnerabilityCount(),
+				OverallStatus:    imageInfo.ScanResults.OverallStatus().String(),
+				ComponentsURL:    imageInfo.ScanResults.ComponentsHref}
+		}
+		containers = append(containers, container)
+		if imageInfo.TimeOfLastStatusChange.After(lastStatusChange) {
+			lastStatusChange = imageInfo.TimeOfLastStatusChange
+		}
+		if shas[cont.Image.Sha] {
+			continue
+		}
+		shas[cont.Image.Sha] = true
+		switch {
+		case imageInfo.ScanStatus == ScanStatusComplete:
+			completeImages++
+		case len(imageInfo.FailureHistory) > 0:
+			failedImages++
+		}
+	}
+	detail := &api.PodDetail{
+		QualifiedName:          qualifiedName,
+		Namespace:              pod.Namespace,
+		Name:                   pod.Name,
+		UID:                    pod.UID,
+		Labels:                 pod.Labels,
+		Status:                 api.RollUpPodStatus(len(shas), completeImages, failedImages),
+		Containers:             containers,
+		TimeAdded:              addedAt.String(),
+		TimeOfLastStatusChange: lastStatusChange.String(),
+	}
+	scan, err := scanResultsForPod(model, qualifiedName)
+	if err != nil {
+		return nil, errors.Annotatef(err, "unable to get scan results for pod %s", qualifiedName)
+	}
+	if scan != nil {
+		detail.PolicyViolations = scan.PolicyViolations
+		detail.Vulnerabilities = scan.Vulnerabilities
+		detail.OverallStatus = scan.OverallStatus.String()
+	}
+	return detail, nil
+}
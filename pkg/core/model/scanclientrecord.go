@@ -0,0 +1,56 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ScanClientRecord describes the most recent scan client job to finish on an
+// image, for telling which scanner versions produced its results.
+type ScanClientRecord struct {
+	Scanner                 string
+	ScanCliVersion          string
+	SignatureScannerVersion string
+	FinishedAt              time.Time
+}
+
+func (model *Model) recordScanClient(sha DockerImageSha, record *ScanClientRecord) error {
+	imageInfo, ok := model.Images[sha]
+	if !ok {
+		return fmt.Errorf("unable to record scan client of image %s: not found", sha)
+	}
+	imageInfo.ScanClient = record
+	if record.Scanner == "" {
+		return nil
+	}
+	previous, ok := model.scannerCliVersions[record.Scanner]
+	if ok && previous != record.ScanCliVersion {
+		log.Warnf("scanner %s changed scan.cli version from %s to %s", record.Scanner, previous, record.ScanCliVersion)
+		recordScannerVersionChange()
+	}
+	model.scannerCliVersions[record.Scanner] = record.ScanCliVersion
+	return nil
+}
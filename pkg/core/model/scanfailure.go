@@ -0,0 +1,127 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+const (
+	maxScanFailureHistory = 20
+)
+
+// ScanFailureStage describes where in the pipeline a scan failed
+type ScanFailureStage int
+
+// .....
+const (
+	ScanFailureStageScanClient ScanFailureStage = iota
+	ScanFailureStageHubScan    ScanFailureStage = iota
+)
+
+// String .....
+func (stage ScanFailureStage) String() string {
+	switch stage {
+	case ScanFailureStageScanClient:
+		return "ScanFailureStageScanClient"
+	case ScanFailureStageHubScan:
+		return "ScanFailureStageHubScan"
+	}
+	panic(fmt.Errorf("invalid ScanFailureStage value: %d", stage))
+}
+
+// MarshalJSON .....
+func (stage ScanFailureStage) MarshalJSON() ([]byte, error) {
+	jsonString := fmt.Sprintf(`"%s"`, stage.String())
+	return []byte(jsonString), nil
+}
+
+// MarshalText .....
+func (stage ScanFailureStage) MarshalText() (text []byte, err error) {
+	return []byte(stage.String()), nil
+}
+
+// ScanFailureReasonManual marks scans which were requeued through the API,
+// rather than reported as failed by a scanner or hub.
+const ScanFailureReasonManual = "manual"
+
+// ScanFailureReasonLeaseExpired marks scans which were requeued because the
+// scan client never acknowledged the image it was handed.
+const ScanFailureReasonLeaseExpired = "leaseExpired"
+
+// ScanFailure records a single failed scan attempt of an image.
+// Scanner is empty if the scanner didn't identify itself, and Reason is
+// empty unless perceptor gave up on the scan by itself.
+type ScanFailure struct {
+	Stage   ScanFailureStage
+	Err     string
+	Scanner string
+	Time    time.Time
+	Reason  string
+	// Category is the scan client's classification of the failure, if any
+	Category string
+	// Detail is stored as the scan client sent it
+	Detail *api.ScanClientFailure
+}
+
+// NewScanFailure .....
+func NewScanFailure(stage ScanFailureStage, err string, scanner string) *ScanFailure {
+	return &ScanFailure{
+		Stage:   stage,
+		Err:     err,
+		Scanner: scanner,
+		Time:    time.Now(),
+	}
+}
+
+// ScanClientError is a failure reported by a scan client which classified
+// or detailed it; see api.ScanFailureCategoryPullFailed and friends.
+type ScanClientError struct {
+	Message  string
+	Category string
+	Detail   *api.ScanClientFailure
+}
+
+func (err *ScanClientError) Error() string {
+	return err.Message
+}
+
+// scanFailureCategory returns the category of a scan client failure, or ""
+// if it wasn't classified.
+func scanFailureCategory(err error) string {
+	if scanClientError, ok := err.(*ScanClientError); ok {
+		return scanClientError.Category
+	}
+	return ""
+}
+
+// scanFailureDetail returns the details of a scan client failure, or nil if
+// the scan client sent none.
+func scanFailureDetail(err error) *api.ScanClientFailure {
+	if scanClientError, ok := err.(*ScanClientError); ok {
+		return scanClientError.Detail
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	"github.com/blackducksoftware/perceptor/pkg/hub"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func RunScanFailureTests() {
+	Describe("scan failure history", func() {
+		failedScanResults := &hub.ScanResults{
+			ScanSummaries: []hub.ScanSummary{{Status: hub.ScanSummaryStatusFailure}},
+		}
+		runScanClient := func(model *Model, image Image, scanner string, err error) {
+			if model.Images[image.Sha].ScanStatus != ScanStatusInQueue {
+				Expect(model.setImageScanStatus(image.Sha, ScanStatusInQueue)).To(BeNil())
+			}
+			Expect(model.startScanClient(image.Sha, "lease-1")).To(BeNil())
+			Expect(model.finishRunningScanClient(&image, scanner, "lease-1", err)).To(BeNil())
+		}
+
+		It("should record scan client failures", func() {
+			model := NewModel()
+			Expect(model.addImage(image1)).To(BeNil())
+			runScanClient(model, image1, "scanner-1", fmt.Errorf("planned failure"))
+			history := model.Images[sha1].FailureHistory
+			Expect(len(history)).To(Equal(1))
+			Expect(history[0].Stage).To(Equal(ScanFailureStageScanClient))
+			Expect(history[0].Err).To(Equal("planned failure"))
+			Expect(history[0].Scanner).To(Equal("scanner-1"))
+		})
+
+		It("should record hub scan failures", func() {
+			model := NewModel()
+			Expect(model.addImage(image1)).To(BeNil())
+			runScanClient(model, image1, "scanner-1", nil)
+			Expect(model.scanDidFinish(sha1, failedScanResults)).To(BeNil())
+			history := model.Images[sha1].FailureHistory
+			Expect(len(history)).To(Equal(1))
+			Expect(history[0].Stage).To(Equal(ScanFailureStageHubScan))
+			Expect(model.Images[sha1].ScanStatus).To(Equal(ScanStatusInQueue))
+		})
+
+		It("should survive requeues, and keep only the most recent failures", func() {
+			model := NewModel()
+			Expect(model.addImage(image1)).To(BeNil())
+			for i := 0; i < maxScanFailureHistory+3; i++ {
+				runScanClient(model, image1, "", fmt.Errorf("failure %d", i))
+			}
+			history := model.Images[sha1].FailureHistory
+			Expect(len(history)).To(Equal(maxScanFailureHistory))
+			Expect(history[0].Err).To(Equal("failure 3"))
+			Expect(history[maxScanFailureHistory-1].Err).To(Equal(fmt.Sprintf("failure %d", maxScanFailureHistory+2)))
+		})
+
+		It("should be discarded when the image is deleted", func() {
+			model := NewModel()
+			Expect(model.addImage(image1)).To(BeNil())
+			runScanClient(model, image1, "", fmt.Errorf("planned failure"))
+			Expect(model.removeImageFromScanQueue(sha1)).To(BeNil())
+			Expect(model.deleteImage(sha1)).To(BeNil())
+			Expect(model.addImage(image1)).To(BeNil())
+			Expect(len(model.Images[sha1].FailureHistory)).To(Equal(0))
+		})
+
+		It("should be exposed in the api model", func() {
+			model := NewModel()
+			Expect(model.addImage(image1)).To(BeNil())
+			runScanClient(model, image1, "scanner-1", fmt.Errorf("planned failure"))
+			apiModel := coreModelToAPIModel(model)
+			history := apiModel.Images[string(sha1)].FailureHistory
+			Expect(len(history)).To(Equal(1))
+			Expect(history[0].Stage).To(Equal("ScanFailureStageScanClient"))
+			Expect(history[0].Scanner).To(Equal("scanner-1"))
+		})
+
+		It("should not requeue terminal scan client failures", func() {
+			model := NewModel()
+			Expect(model.addImage(image1)).To(BeNil())
+			runScanClient(model, image1, "scanner-1", &ScanClientError{Message: "unsupported layer format", Category: api.ScanFailureCategoryImageUnsupported})
+			imageInfo := model.Images[sha1]
+			Expect(imageInfo.ScanStatus).To(Equal(ScanStatusFailed))
+			Expect(model.ImageScanQueue.HasKey(string(sha1))).To(BeFalse())
+			Expect(imageInfo.FailureHistory[0].Category).To(Equal(api.ScanFailureCategoryImageUnsupported))
+			Expect(coreModelToAPIModel(model).Images[string(sha1)].FailureHistory[0].Category).To(Equal(api.ScanFailureCategoryImageUnsupported))
+
+			result, err := model.requeueImage(string(sha1), "ops")
+			Expect(err).To(BeNil())
+			Expect(result.PreviousScanStatus).To(Equal("ScanStatusFailed"))
+			Expect(imageInfo.ScanStatus).To(Equal(ScanStatusInQueue))
+		})
+
+		It("should store scan client failure details verbatim, counting them by category", func() {
+			failures := func() float64 {
+				metric := &dto.Metric{}
+				Expect(scanClientFailureCounter.With(prometheus.Labels{"category": api.ScanFailureCategoryCliCrashed}).Write(metric)).To(BeNil())
+				return metric.GetCounter().GetValue()
+			}
+			before := failures()
+			exitCode := 137
+			detail := &api.ScanClientFailure{Category: api.ScanFailureCategoryCliCrashed, Stage: "signatureScan", ExitCode: &exitCode, Stderr: []string{"killed"}, Scanner: "scanner-1"}
+			model := NewModel()
+			Expect(model.addImage(image1)).To(BeNil())
+			runScanClient(model, image1, "scanner-1", &ScanClientError{Message: "exit status 137", Category: detail.Category, Detail: detail})
+			history := coreModelToAPIModel(model).Images[string(sha1)].FailureHistory
+			Expect(history[0].Err).To(Equal("exit status 137"))
+			Expect(history[0].Category).To(Equal(api.ScanFailureCategoryCliCrashed))
+			Expect(history[0].Detail).To(Equal(detail))
+			Expect(failures()).To(Equal(before + 1))
+		})
+
+		It("should count unknown scan client failure categories as other", func() {
+			failures := func(category string) float64 {
+				metric := &dto.Metric{}
+				Expect(scanClientFailureCounter.With(prometheus.Labels{"category": category}).Write(metric)).To(BeNil())
+				return metric.GetCounter().GetValue()
+			}
+			before := failures("other")
+			model := NewModel()
+			Expect(model.addImage(image1)).To(BeNil())
+			runScanClient(model, image1, "scanner-1", &ScanClientError{Message: "something new", Category: "SomethingNew"})
+			Expect(model.Images[sha1].FailureHistory[0].Category).To(Equal("SomethingNew"))
+			Expect(failures("other")).To(Equal(before + 1))
+			Expect(failures("SomethingNew")).To(Equal(float64(0)))
+		})
+
+		It("should not requeue images whose pulled digest didn't match", func() {
+			detail := &api.ScanClientFailure{Category: api.ScanFailureCategoryDigestMismatch, ExpectedDigest: string(sha1), ActualDigest: "sha256:other"}
+			model := NewModel()
+			Expect(model.addImage(image1)).To(BeNil())
+			runScanClient(model, image1, "scanner-1", &ScanClientError{Message: "digest mismatch", Category: detail.Category, Detail: detail})
+			Expect(model.Images[sha1].ScanStatus).To(Equal(ScanStatusFailed))
+			Expect(model.Images[sha1].FailureHistory[0].Detail.ActualDigest).To(Equal("sha256:other"))
+		})
+
+		It("should skip images which are too large to scan", func() {
+			tooLarge := func() float64 {
+				metric := &dto.Metric{}
+				Expect(scanClientFailureCounter.With(prometheus.Labels{"category": api.ScanFailureCategoryImageTooLarge}).Write(metric)).To(BeNil())
+				return metric.GetCounter().GetValue()
+			}
+			before := tooLarge()
+			detail := &api.ScanClientFailure{Category: api.ScanFailureCategoryImageTooLarge, Stage: "pull", ImageSizeBytes: 40 << 30, MaxImageSizeBytes: 10 << 30}
+			model := NewModel()
+			Expect(model.addImage(image1)).To(BeNil())
+			runScanClient(model, image1, "scanner-1", &ScanClientError{Message: "image too large", Category: detail.Category, Detail: detail})
+			Expect(model.Images[sha1].ScanStatus).To(Equal(ScanStatusFailed))
+			Expect(model.Images[sha1].FailureHistory[0].Detail.MaxImageSizeBytes).To(Equal(int64(10 << 30)))
+			Expect(tooLarge()).To(Equal(before + 1))
+		})
+
+		It("should requeue retryable and unrecognized scan client failures", func() {
+			model := NewModel()
+			Expect(model.addImage(image1)).To(BeNil())
+			for _, category := range []string{api.ScanFailureCategoryUploadFailed, "SomethingNew"} {
+				runScanClient(model, image1, "scanner-1", &ScanClientError{Message: "planned failure", Category: category})
+				Expect(model.Images[sha1].ScanStatus).To(Equal(ScanStatusInQueue))
+			}
+		})
+	})
+}
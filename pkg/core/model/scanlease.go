@@ -0,0 +1,118 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultScanLeaseTTL is how long a scan client has to acknowledge an image
+// handed to it, unless configured otherwise.
+const DefaultScanLeaseTTL = time.Minute
+
+// ScanLease is held by the scan client an image was handed to.  Unless the
+// client acknowledges it by ExpiresAt, the image goes back to the scan queue;
+// once acknowledged, it lasts until the scan client finishes.
+type ScanLease struct {
+	ID           string
+	GrantedAt    time.Time
+	ExpiresAt    time.Time
+	Acknowledged bool
+}
+
+// TTL is how long the scan client had to acknowledge the lease.
+func (lease *ScanLease) TTL() time.Duration {
+	return lease.ExpiresAt.Sub(lease.GrantedAt)
+}
+
+func newScanLeaseID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(bytes)
+}
+
+// checkScanLease returns a ScanLeaseError unless `leaseID` is the lease of
+// the scan client currently scanning `imageInfo`.
+func checkScanLease(imageInfo *ImageInfo, leaseID string) error {
+	reason := ""
+	switch {
+	case imageInfo.ScanStatus != ScanStatusRunningScanClient || imageInfo.Lease == nil:
+		reason = api.ScanLeaseReasonUnknown
+	case imageInfo.Lease.ID != leaseID:
+		reason = api.ScanLeaseReasonStale
+	default:
+		return nil
+	}
+	recordScanLeaseRejected(reason)
+	return &api.ScanLeaseError{Sha: string(imageInfo.ImageSha), LeaseID: leaseID, Reason: reason}
+}
+
+func (model *Model) acknowledgeScanLease(sha DockerImageSha, leaseID string, now time.Time) error {
+	imageInfo, ok := model.Images[sha]
+	if !ok {
+		return &api.ImageNotFoundError{ShaPrefix: string(sha)}
+	}
+	if err := checkScanLease(imageInfo, leaseID); err != nil {
+		return err
+	}
+	lease := imageInfo.Lease
+	if !lease.Acknowledged && now.After(lease.ExpiresAt) {
+		recordScanLeaseRejected(api.ScanLeaseReasonExpired)
+		return &api.ScanLeaseError{Sha: string(sha), LeaseID: leaseID, Reason: api.ScanLeaseReasonExpired}
+	}
+	lease.Acknowledged = true
+	return nil
+}
+
+// expireScanLeases puts images whose scan clients haven't acknowledged their
+// leases in time back in the scan queue, returning how many leases expired.
+func (model *Model) expireScanLeases(now time.Time) (int, error) {
+	expired := 0
+	errors := []error{}
+	for _, sha := range model.getShas(ScanStatusRunningScanClient) {
+		imageInfo := model.Images[sha]
+		lease := imageInfo.Lease
+		if lease == nil || lease.Acknowledged || !now.After(lease.ExpiresAt) {
+			continue
+		}
+		log.Warnf("lease %s of image %s expired without being acknowledged, requeueing", lease.ID, sha)
+		expired++
+		failure := NewScanFailure(ScanFailureStageScanClient, fmt.Sprintf("lease %s expired after %s without being acknowledged", lease.ID, lease.TTL()), "")
+		failure.Reason = ScanFailureReasonLeaseExpired
+		imageInfo.AddScanFailure(failure)
+		if err := model.setImageScanStatus(sha, ScanStatusInQueue); err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		recordImageRequeue(ScanFailureReasonLeaseExpired, ScanFailureStageScanClient)
+		recordScanLeaseExpired()
+	}
+	return expired, combineErrors("expireScanLeases", errors)
+}
@@ -0,0 +1,144 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import (
+	"time"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func RunScanLeaseTests() {
+	Describe("scan leases", func() {
+		counterValue := func(counter prometheus.Counter) float64 {
+			metric := &dto.Metric{}
+			Expect(counter.Write(metric)).To(BeNil())
+			return metric.GetCounter().GetValue()
+		}
+		leasedModel := func() (*Model, *ScanLease) {
+			model := NewModel()
+			Expect(model.addImage(image1)).To(BeNil())
+			Expect(model.setImageScanStatus(sha1, ScanStatusInQueue)).To(BeNil())
+			Expect(model.startScanClient(sha1, "lease-1")).To(BeNil())
+			return model, model.Images[sha1].Lease
+		}
+
+		It("should be granted with the configured TTL when a scan client starts", func() {
+			model := NewModel()
+			model.scanLeaseTTL = 30 * time.Second
+			Expect(model.addImage(image1)).To(BeNil())
+			Expect(model.setImageScanStatus(sha1, ScanStatusInQueue)).To(BeNil())
+			Expect(model.startScanClient(sha1, "lease-1")).To(BeNil())
+			lease := model.Images[sha1].Lease
+			Expect(lease.ID).To(Equal("lease-1"))
+			Expect(lease.TTL()).To(Equal(30 * time.Second))
+			Expect(lease.Acknowledged).To(BeFalse())
+		})
+
+		It("should generate distinct IDs", func() {
+			Expect(newScanLeaseID()).NotTo(Equal(newScanLeaseID()))
+		})
+
+		It("should not expire once acknowledged", func() {
+			model, lease := leasedModel()
+			Expect(model.acknowledgeScanLease(sha1, "lease-1", lease.GrantedAt)).To(BeNil())
+			Expect(model.expireScanLeases(lease.ExpiresAt.Add(time.Hour))).To(Equal(0))
+			Expect(model.Images[sha1].ScanStatus).To(Equal(ScanStatusRunningScanClient))
+		})
+
+		It("should requeue the image when not acknowledged in time", func() {
+			expirations := counterValue(scanLeaseExpiredCounter)
+			requeues := counterValue(imageRequeueCounter.With(prometheus.Labels{"reason": ScanFailureReasonLeaseExpired, "stage": ScanFailureStageScanClient.String()}))
+			model, lease := leasedModel()
+			Expect(model.expireScanLeases(lease.ExpiresAt)).To(Equal(0))
+			Expect(model.Images[sha1].ScanStatus).To(Equal(ScanStatusRunningScanClient))
+
+			Expect(model.expireScanLeases(lease.ExpiresAt.Add(time.Second))).To(Equal(1))
+			imageInfo := model.Images[sha1]
+			Expect(imageInfo.ScanStatus).To(Equal(ScanStatusInQueue))
+			Expect(imageInfo.Lease).To(BeNil())
+			Expect(imageInfo.FailureHistory).To(HaveLen(1))
+			Expect(imageInfo.FailureHistory[0].Reason).To(Equal(ScanFailureReasonLeaseExpired))
+			Expect(imageInfo.FailureHistory[0].Stage).To(Equal(ScanFailureStageScanClient))
+			Expect(model.ImageScanQueue.HasKey(string(sha1))).To(BeTrue())
+			Expect(counterValue(scanLeaseExpiredCounter)).To(Equal(expirations + 1))
+			Expect(counterValue(imageRequeueCounter.With(prometheus.Labels{"reason": ScanFailureReasonLeaseExpired, "stage": ScanFailureStageScanClient.String()}))).To(Equal(requeues + 1))
+		})
+
+		It("should reject an acknowledgment after expiry", func() {
+			model, lease := leasedModel()
+			err := model.acknowledgeScanLease(sha1, "lease-1", lease.ExpiresAt.Add(time.Second))
+			Expect(err).To(Equal(&api.ScanLeaseError{Sha: string(sha1), LeaseID: "lease-1", Reason: api.ScanLeaseReasonExpired}))
+			Expect(model.Images[sha1].Lease.Acknowledged).To(BeFalse())
+		})
+
+		It("should reject finished scans from superseded leases", func() {
+			stale := counterValue(scanLeaseRejectedCounter.With(prometheus.Labels{"reason": api.ScanLeaseReasonStale}))
+			model, lease := leasedModel()
+			Expect(model.expireScanLeases(lease.ExpiresAt.Add(time.Second))).To(Equal(1))
+			Expect(model.startScanClient(sha1, "lease-2")).To(BeNil())
+
+			err := model.finishRunningScanClient(&image1, "scanner-1", "lease-1", nil)
+			Expect(err).To(Equal(&api.ScanLeaseError{Sha: string(sha1), LeaseID: "lease-1", Reason: api.ScanLeaseReasonStale}))
+			Expect(model.Images[sha1].ScanStatus).To(Equal(ScanStatusRunningScanClient))
+			Expect(counterValue(scanLeaseRejectedCounter.With(prometheus.Labels{"reason": api.ScanLeaseReasonStale}))).To(Equal(stale + 1))
+
+			Expect(model.finishRunningScanClient(&image1, "scanner-1", "lease-2", nil)).To(BeNil())
+			Expect(model.Images[sha1].ScanStatus).To(Equal(ScanStatusRunningHubScan))
+		})
+
+		It("should reject leases for images no scan client is running on", func() {
+			model, lease := leasedModel()
+			Expect(model.expireScanLeases(lease.ExpiresAt.Add(time.Second))).To(Equal(1))
+			err := model.acknowledgeScanLease(sha1, "lease-1", lease.GrantedAt)
+			Expect(err).To(Equal(&api.ScanLeaseError{Sha: string(sha1), LeaseID: "lease-1", Reason: api.ScanLeaseReasonUnknown}))
+			err = model.finishRunningScanClient(&image1, "scanner-1", "lease-1", nil)
+			Expect(err).To(Equal(&api.ScanLeaseError{Sha: string(sha1), LeaseID: "lease-1", Reason: api.ScanLeaseReasonUnknown}))
+		})
+
+		It("should only journal checks which expire a lease", func() {
+			journal, err := NewJournal(10, "")
+			Expect(err).To(BeNil())
+			model := NewModelWithJournal(journal)
+			model.SetScanLeaseTTL(200 * time.Millisecond)
+			model.AddImage(image1)
+			model.ScanDidFinish(sha1, nil)
+			_, err = model.StartScanClient(sha1)
+			Expect(err).To(BeNil())
+			generation := model.GetGeneration()
+			model.ExpireScanLeases()
+			Expect(model.GetGeneration()).To(Equal(generation))
+			Expect(model.GetJournal()).To(HaveLen(3))
+
+			time.Sleep(250 * time.Millisecond)
+			model.ExpireScanLeases()
+			Expect(model.GetGeneration()).To(Equal(generation + 1))
+			entries := model.GetJournal()
+			Expect(entries).To(HaveLen(4))
+			Expect(entries[3].Action).To(Equal("expireScanLeases"))
+		})
+	})
+}
@@ -33,6 +33,7 @@ const (
 	ScanStatusRunningScanClient ScanStatus = iota
 	ScanStatusRunningHubScan    ScanStatus = iota
 	ScanStatusComplete          ScanStatus = iota
+	ScanStatusFailed            ScanStatus = iota
 )
 
 // String .....
@@ -48,6 +49,8 @@ func (status ScanStatus) String() string {
 		return "ScanStatusRunningHubScan"
 	case ScanStatusComplete:
 		return "ScanStatusComplete"
+	case ScanStatusFailed:
+		return "ScanStatusFailed"
 	}
 	panic(fmt.Errorf("invalid ScanStatus value: %d", status))
 }
@@ -76,6 +79,7 @@ var legalTransitions = map[ScanStatus]map[ScanStatus]bool{
 	ScanStatusRunningScanClient: {
 		ScanStatusInQueue:        true,
 		ScanStatusRunningHubScan: true,
+		ScanStatusFailed:         true,
 	},
 	ScanStatusRunningHubScan: {
 		ScanStatusInQueue:  true,
@@ -83,6 +87,11 @@ var legalTransitions = map[ScanStatus]map[ScanStatus]bool{
 	},
 	// we never expect to transition FROM complete
 	ScanStatusComplete: {},
+	// failed terminally: a manual requeue, or hub results, move it on
+	ScanStatusFailed: {
+		ScanStatusInQueue:  true,
+		ScanStatusComplete: true,
+	},
 }
 
 // IsLegalTransition .....
@@ -50,6 +50,7 @@ var cases = []transitionCase{
 	{from: ScanStatusRunningScanClient, to: ScanStatusRunningScanClient, isLegal: false},
 	{from: ScanStatusRunningScanClient, to: ScanStatusRunningHubScan, isLegal: true},
 	{from: ScanStatusRunningScanClient, to: ScanStatusComplete, isLegal: false},
+	{from: ScanStatusRunningScanClient, to: ScanStatusFailed, isLegal: true},
 
 	{from: ScanStatusRunningHubScan, to: ScanStatusUnknown, isLegal: false},
 	{from: ScanStatusRunningHubScan, to: ScanStatusInQueue, isLegal: true},
@@ -62,6 +63,14 @@ var cases = []transitionCase{
 	{from: ScanStatusComplete, to: ScanStatusRunningScanClient, isLegal: false},
 	{from: ScanStatusComplete, to: ScanStatusRunningHubScan, isLegal: false},
 	{from: ScanStatusComplete, to: ScanStatusComplete, isLegal: false},
+
+	{from: ScanStatusInQueue, to: ScanStatusFailed, isLegal: false},
+	{from: ScanStatusRunningHubScan, to: ScanStatusFailed, isLegal: false},
+	{from: ScanStatusFailed, to: ScanStatusUnknown, isLegal: false},
+	{from: ScanStatusFailed, to: ScanStatusInQueue, isLegal: true},
+	{from: ScanStatusFailed, to: ScanStatusRunningScanClient, isLegal: false},
+	{from: ScanStatusFailed, to: ScanStatusComplete, isLegal: true},
+	{from: ScanStatusFailed, to: ScanStatusFailed, isLegal: false},
 }
 
 func RunTestLegalScanStatusTransitions() {
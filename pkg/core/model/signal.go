@@ -0,0 +1,49 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package model
+
+import "sync"
+
+// signal wakes up every goroutine waiting on it, without blocking the
+// notifier: each notification closes the current channel, and replaces it.
+type signal struct {
+	mutex sync.Mutex
+	ch    chan struct{}
+}
+
+func newSignal() *signal {
+	return &signal{ch: make(chan struct{})}
+}
+
+// wait returns a channel which is closed by the next notification.
+func (s *signal) wait() <-chan struct{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.ch
+}
+
+func (s *signal) notify() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	close(s.ch)
+	s.ch = make(chan struct{})
+}
@@ -31,3 +31,10 @@ func combineErrors(message string, errs []error) error {
 	}
 	return fmt.Errorf("combined errors from %s: %+v", message, errs)
 }
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -22,8 +22,13 @@ under the License.
 package core
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	api "github.com/blackducksoftware/perceptor/pkg/api"
 	m "github.com/blackducksoftware/perceptor/pkg/core/model"
@@ -33,6 +38,9 @@ import (
 
 const (
 	actionChannelSize = 100
+	// scanResultsStreamBufferSize is how many events a stream client may fall
+	// behind before it's disconnected
+	scanResultsStreamBufferSize = 256
 )
 
 // Perceptor ties together: a cluster, scan clients, and a hub.
@@ -47,14 +55,44 @@ type Perceptor struct {
 	scanScheduler      *ScanScheduler
 	hubManager         HubManagerInterface
 	config             *Config
+	configRegistry     *ConfigRegistry
+	startTime          time.Time
+	metricsSummarizer  *metricsSummarizer
+	auditLog           *api.AuditLog
+	sourceStats        *api.SourceStats
+	// snapshot of the core model, rebuilt only when the model changes
+	snapshotMutex sync.Mutex
+	snapshot      *api.CoreModel
+	// shuttingDown is set, atomically, once the HTTP server starts draining
+	shuttingDown int32
 	// channels
-	stop           <-chan struct{}
+	stop           chan struct{}
+	stopOnce       sync.Once
 	getNextImageCh chan chan *api.ImageSpec
+	// the scan scheduler is only accessed from the next image goroutine
+	setConcurrentScanLimitCh chan int
+	getConcurrentScanLimitCh chan chan api.ConcurrentScanLimit
 }
 
 // NewPerceptor creates a Perceptor using a real hub client.
 func NewPerceptor(config *Config, timings *Timings, scanScheduler *ScanScheduler, hubManager HubManagerInterface) (*Perceptor, error) {
-	model := m.NewModel()
+	var journal *m.Journal
+	if config.Perceptor != nil && (config.Perceptor.JournalSize > 0 || config.Perceptor.JournalPath != "") {
+		var err error
+		journal, err = m.NewJournal(config.Perceptor.JournalSize, config.Perceptor.JournalPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	model := m.NewModelWithJournal(journal)
+	auditLog, err := config.auditLog()
+	if err != nil {
+		return nil, err
+	}
+	if config.Hub != nil {
+		model.SetNamespaceScanLimits(config.Hub.NamespaceConcurrentScanLimits)
+	}
+	model.SetScanLeaseTTL(timings.ScanLeaseTTL())
 
 	// 1. routine task manager
 	stop := make(chan struct{})
@@ -65,7 +103,11 @@ func NewPerceptor(config *Config, timings *Timings, scanScheduler *ScanScheduler
 			case <-stop:
 				return
 			case <-routineTaskManager.metricsCh:
-				recordModelMetrics(model.GetMetrics())
+				if modelMetrics := model.GetMetrics(); modelMetrics != nil {
+					recordModelMetrics(modelMetrics)
+				}
+			case <-routineTaskManager.scanLeasesCh:
+				model.ExpireScanLeases()
 			case <-routineTaskManager.unknownImagesCh:
 				log.Debugf("handling RTM unknown images")
 				/*
@@ -143,13 +185,20 @@ func NewPerceptor(config *Config, timings *Timings, scanScheduler *ScanScheduler
 
 	// 2. perceptor
 	perceptor := &Perceptor{
-		model:              model,
-		routineTaskManager: routineTaskManager,
-		scanScheduler:      scanScheduler,
-		hubManager:         hubManager,
-		config:             config,
-		stop:               stop,
-		getNextImageCh:     make(chan chan *api.ImageSpec),
+		model:                    model,
+		routineTaskManager:       routineTaskManager,
+		scanScheduler:            scanScheduler,
+		hubManager:               hubManager,
+		config:                   config,
+		configRegistry:           NewConfigRegistry(config),
+		metricsSummarizer:        newMetricsSummarizer(metricsRegistry.Gatherer()),
+		auditLog:                 auditLog,
+		sourceStats:              api.NewSourceStats(config.maxTrackedSources()),
+		startTime:                time.Now(),
+		stop:                     stop,
+		getNextImageCh:           make(chan chan *api.ImageSpec),
+		setConcurrentScanLimitCh: make(chan int),
+		getConcurrentScanLimitCh: make(chan chan api.ConcurrentScanLimit),
 	}
 
 	go func() {
@@ -159,10 +208,22 @@ func NewPerceptor(config *Config, timings *Timings, scanScheduler *ScanScheduler
 				return
 			case ch := <-perceptor.getNextImageCh:
 				perceptor.getNextImage(ch)
+			case limit := <-perceptor.setConcurrentScanLimitCh:
+				log.Infof("setting concurrent scan limit from %d to %d", scanScheduler.ConcurrentScanLimit, limit)
+				scanScheduler.ConcurrentScanLimit = limit
+				recordConcurrentScanLimit(limit)
+				perceptor.configRegistry.Set(settingConcurrentScanLimit, limit, settingSourceAPI)
+			case ch := <-perceptor.getConcurrentScanLimitCh:
+				ch <- api.ConcurrentScanLimit{
+					Limit:           scanScheduler.ConcurrentScanLimit,
+					InProgressScans: scanScheduler.InProgressScanCount(),
+				}
 			}
 		}
 	}()
 
+	go perceptor.metricsSummarizer.run(stop)
+
 	// 3. done
 	return perceptor, nil
 }
@@ -175,21 +236,36 @@ func (pcp *Perceptor) UpdateConfig(config *Config) {
 	} else {
 		log.Errorf("set config, but unable to dump to string: %s", err.Error())
 	}
-	pcp.hubManager.SetHubs(config.Hub.Hosts)
+	pcp.hubManager.SetHubs(hubSpecs(config.Hub.Hosts))
+	pcp.model.SetNamespaceScanLimits(config.Hub.NamespaceConcurrentScanLimits)
+	if config.Perceptor != nil && config.Perceptor.Timings != nil {
+		pcp.model.SetScanLeaseTTL(config.Perceptor.Timings.ScanLeaseTTL())
+	}
 	logLevel, err := config.GetLogLevel()
 	if err != nil {
 		log.Errorf("unable to get log level: %s", err.Error())
 	} else {
 		log.SetLevel(logLevel)
+		pcp.configRegistry.Set(settingLogLevel, config.LogLevel, settingSourceConfigReload)
 	}
+	pcp.configRegistry.DidReloadConfig(config)
 	// config.Timings
 }
 
 // Section: api.Responder implementation
 
+// GetConfig .....
+func (pcp *Perceptor) GetConfig() *api.RuntimeConfig {
+	recordGetConfig()
+	return pcp.configRegistry.RuntimeConfig()
+}
+
 // GetModel .....
 func (pcp *Perceptor) GetModel() api.Model {
-	coreModel := pcp.model.GetModel()
+	coreModel, err := pcp.coreModelSnapshot()
+	if err != nil {
+		log.Errorf("unable to get core model: %v", err)
+	}
 	hubModels := map[string]*api.ModelHub{}
 	for hubURL, hub := range pcp.hubManager.HubClients() {
 		hubModels[hubURL] = <-hub.Model()
@@ -202,9 +278,28 @@ func (pcp *Perceptor) GetModel() api.Model {
 	}
 }
 
+// coreModelSnapshot avoids rebuilding the core model -- which holds up the
+// reducer -- when a client is paging through an unchanged model.  The
+// snapshot must not be modified.
+func (pcp *Perceptor) coreModelSnapshot() (*api.CoreModel, error) {
+	generation := pcp.model.GetGeneration()
+	pcp.snapshotMutex.Lock()
+	defer pcp.snapshotMutex.Unlock()
+	if pcp.snapshot == nil || pcp.snapshot.Generation != generation {
+		pcp.snapshot = pcp.model.GetModel()
+	}
+	if pcp.snapshot == nil {
+		return nil, fmt.Errorf("unable to build core model")
+	}
+	return pcp.snapshot, nil
+}
+
 // AddPod .....
 func (pcp *Perceptor) AddPod(apiPod api.Pod) error {
 	recordAddPod()
+	if err := validatePod(apiPod); err != nil {
+		return err
+	}
 	pod, err := APIPodToCorePod(apiPod)
 	if err != nil {
 		return err
@@ -214,23 +309,57 @@ func (pcp *Perceptor) AddPod(apiPod api.Pod) error {
 	return nil
 }
 
+// validatePod rejects invalid pods before any model action is sent, and
+// records warnings for unusual ones.
+func validatePod(apiPod api.Pod) error {
+	warnings, err := api.ValidatePod(apiPod)
+	for _, warning := range warnings {
+		log.Warnf("pod validation: %s", warning)
+		recordPodValidationWarning()
+	}
+	if err != nil {
+		log.Errorf("rejecting pod: %s", err.Error())
+		recordPodValidationFailure()
+	}
+	return err
+}
+
 // DeletePod .....
-func (pcp *Perceptor) DeletePod(qualifiedName string) {
+func (pcp *Perceptor) DeletePod(qualifiedName string) (bool, error) {
 	recordDeletePod()
-	pcp.model.DeletePod(qualifiedName)
-	log.Debugf("handled delete pod %s", qualifiedName)
+	deferred, err := pcp.model.DeletePod(qualifiedName)
+	log.Debugf("handled delete pod %s: deferred %t, error %v", qualifiedName, deferred, err)
+	return deferred, err
+}
+
+// DeletePods .....
+func (pcp *Perceptor) DeletePods(query *api.PodsDeletionQuery) (*api.PodsDeletion, error) {
+	recordDeletePods()
+	podNames, err := pcp.model.DeleteNamespacePods(query.Namespace, query.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("handled delete pods in namespace %s: %d pods, dry run %t", query.Namespace, len(podNames), query.DryRun)
+	return &api.PodsDeletion{Namespace: query.Namespace, DryRun: query.DryRun, Deleted: len(podNames), Pods: podNames}, nil
 }
 
 // UpdatePod .....
-func (pcp *Perceptor) UpdatePod(apiPod api.Pod) error {
+func (pcp *Perceptor) UpdatePod(apiPod api.Pod) (*api.PodUpsertResult, error) {
 	recordUpdatePod()
+	if err := validatePod(apiPod); err != nil {
+		return nil, err
+	}
 	pod, err := APIPodToCorePod(apiPod)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	pcp.model.UpdatePod(*pod)
-	log.Debugf("handled update pod %s -- %s", pod.UID, pod.QualifiedName())
-	return nil
+	outcome, enqueued := pcp.model.UpdatePod(*pod)
+	result := &api.PodUpsertResult{Outcome: outcome, EnqueuedImages: make([]string, len(enqueued))}
+	for i, sha := range enqueued {
+		result.EnqueuedImages[i] = string(sha)
+	}
+	log.Debugf("handled update pod %s -- %s: %s, %d new images", pod.UID, pod.QualifiedName(), outcome, len(enqueued))
+	return result, nil
 }
 
 // AddImage .....
@@ -245,11 +374,48 @@ func (pcp *Perceptor) AddImage(apiImage api.Image) error {
 	return nil
 }
 
+// AddImages adds a batch of images with a single model action.
+func (pcp *Perceptor) AddImages(apiImages []api.Image, acceptValid bool) (*api.BulkImagesResult, error) {
+	recordAddImages()
+	results, err := api.ValidateImages(apiImages, acceptValid)
+	if err != nil {
+		return nil, err
+	}
+	images := []m.Image{}
+	indexes := []int{}
+	for i, apiImage := range apiImages {
+		if results[i].Outcome == api.ImageOutcomeRejected {
+			continue
+		}
+		image, err := APIImageToCoreImage(apiImage)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, *image)
+		indexes = append(indexes, i)
+	}
+	if len(images) > 0 {
+		for i, added := range pcp.model.AddImages(images) {
+			if added {
+				results[indexes[i]].Outcome = api.ImageOutcomeAdded
+			} else {
+				results[indexes[i]].Outcome = api.ImageOutcomeAlreadyKnown
+			}
+		}
+	}
+	result := api.NewBulkImagesResult(results)
+	log.Debugf("handled add images -- %d added, %d already known, %d rejected", result.Added, result.AlreadyKnown, result.Rejected)
+	return result, nil
+}
+
 // UpdateAllPods .....
 func (pcp *Perceptor) UpdateAllPods(allPods api.AllPods) error {
 	recordAllPods()
 	pods := []m.Pod{}
 	for _, apiPod := range allPods.Pods {
+		if err := validatePod(apiPod); err != nil {
+			return err
+		}
 		pod, err := APIPodToCorePod(apiPod)
 		if err != nil {
 			return err
@@ -279,12 +445,128 @@ func (pcp *Perceptor) UpdateAllImages(allImages api.AllImages) error {
 	return nil
 }
 
-// GetScanResults returns results for:
+// GetPods is served from the model snapshot.
+func (pcp *Perceptor) GetPods(query *api.PodsQuery) (*api.PodList, error) {
+	recordGetPods()
+	coreModel, err := pcp.coreModelSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return api.ListPods(coreModel, query)
+}
+
+// GetImagesByName .....
+func (pcp *Perceptor) GetImagesByName(repository string, tag string) (*api.ImagesByName, error) {
+	recordGetImagesByName()
+	return pcp.model.GetImagesByName(repository, tag)
+}
+
+// GetPod .....
+func (pcp *Perceptor) GetPod(namespace string, name string) (*api.PodDetail, error) {
+	recordGetPod()
+	return pcp.model.GetPodDetail(namespace, name)
+}
+
+// ExportFindings .....
+func (pcp *Perceptor) ExportFindings(query *api.FindingsExportQuery, emit func(*api.Finding) error) error {
+	recordExportFindings()
+	coreModel, err := pcp.coreModelSnapshot()
+	if err != nil {
+		return err
+	}
+	return exportFindings(coreModel, query.Filter(), emit)
+}
+
+// GetScanResults returns results, restricted to the pods matching `query`
+// and their images, for:
 //  - all images that have a scan status of complete
 //  - all pods for which all their images have a scan status of complete
-func (pcp *Perceptor) GetScanResults() api.ScanResults {
+func (pcp *Perceptor) GetScanResults(query *api.ScanResultsQuery) api.ScanResults {
 	recordGetScanResults()
-	return pcp.model.GetScanResults()
+	return pcp.model.GetScanResults(query)
+}
+
+// SubscribeScanResults .....
+func (pcp *Perceptor) SubscribeScanResults(lastEventID string) (*api.ScanResultsSubscription, error) {
+	recordSubscribeScanResults()
+	return pcp.model.SubscribeScanResults(lastEventID, scanResultsStreamBufferSize)
+}
+
+// RequeueImage puts an image which is being scanned back in the scan queue,
+// for when its scanner is known to have died.
+func (pcp *Perceptor) RequeueImage(shaPrefix string, source string) (*api.ImageRequeueResult, error) {
+	recordRequeueImage()
+	result, err := pcp.model.RequeueImage(shaPrefix, source)
+	if err != nil {
+		log.Warnf("unable to requeue image %s for %s: %s", shaPrefix, source, err.Error())
+		return nil, err
+	}
+	log.Infof("requeued image %s from %s for %s, at queue position %d", result.Sha, result.PreviousScanStatus, source, result.QueuePosition)
+	return result, nil
+}
+
+// GetImage .....
+func (pcp *Perceptor) GetImage(shaPrefix string) (*api.ImageDetail, error) {
+	recordGetImage()
+	detail, err := pcp.model.GetImageDetail(shaPrefix)
+	if err != nil {
+		return nil, err
+	}
+	for hubURL, hub := range pcp.hubManager.HubClients() {
+		for _, scanName := range <-hub.InProgressScans() {
+			if scanName == detail.HubScanName {
+				detail.HubURL = hubURL
+			}
+		}
+	}
+	return detail, nil
+}
+
+// SetHubs .....
+func (pcp *Perceptor) SetHubs(request *api.SetHubsRequest) (*api.SetHubsResult, error) {
+	if err := api.ValidateSetHubsRequest(request); err != nil {
+		return nil, err
+	}
+	recordSetHubs()
+	result := pcp.hubManager.SetHubs(request.Hubs)
+	hosts := []string{}
+	for host := range pcp.hubManager.HubClients() {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	pcp.configRegistry.Set(settingHubHosts, redactHosts(hosts), settingSourceAPI)
+	log.Infof("set hubs: added %v, removed %v, unchanged %v, failed %+v", result.Added, result.Removed, result.Unchanged, result.Failed)
+	return result, nil
+}
+
+// LoginHub .....
+func (pcp *Perceptor) LoginHub(host string) (*api.HubLoginResult, error) {
+	recordLoginHub()
+	hub, ok := pcp.hubManager.HubClients()[host]
+	if !ok {
+		return nil, &api.HubNotFoundError{Host: host}
+	}
+	return hub.Login(), nil
+}
+
+// GetHubCircuitBreaker .....
+func (pcp *Perceptor) GetHubCircuitBreaker(host string) (*api.ModelCircuitBreaker, error) {
+	recordGetHubCircuitBreaker()
+	hub, ok := pcp.hubManager.HubClients()[host]
+	if !ok {
+		return nil, &api.HubNotFoundError{Host: host}
+	}
+	return <-hub.CircuitBreaker(), nil
+}
+
+// ResetHubCircuitBreaker .....
+func (pcp *Perceptor) ResetHubCircuitBreaker(host string, source string, requestID string) (*api.CircuitBreakerReset, error) {
+	recordResetHubCircuitBreaker()
+	hub, ok := pcp.hubManager.HubClients()[host]
+	if !ok {
+		return nil, &api.HubNotFoundError{Host: host}
+	}
+	return hub.ResetCircuitBreakerFor(source, requestID), nil
 }
 
 func (pcp *Perceptor) getNextImage(ch chan<- *api.ImageSpec) {
@@ -307,6 +589,14 @@ func (pcp *Perceptor) getNextImage(ch chan<- *api.ImageSpec) {
 		return
 	}
 
+	// lease the image before handing it out, so that it goes back in the
+	// queue if the scan client never receives it
+	lease, err := pcp.model.StartScanClient(image.Sha)
+	if err != nil {
+		log.Errorf("get next image: unable to start scan client for image %s: %s", image.Sha, err.Error())
+		finish(nil)
+		return
+	}
 	finish(&api.ImageSpec{
 		Repository:            image.Repository,
 		Tag:                   image.Tag,
@@ -315,43 +605,206 @@ func (pcp *Perceptor) getNextImage(ch chan<- *api.ImageSpec) {
 		HubProjectName:        image.HubProjectName(),
 		HubProjectVersionName: image.HubProjectVersionName(),
 		HubScanName:           image.HubScanName(),
-		Priority:              image.Priority})
+		Priority:              image.Priority,
+		LeaseID:               lease.ID,
+		LeaseTTLSeconds:       int(lease.TTL().Seconds())})
 	log.Debugf("handle didStartScan")
-	pcp.model.StartScanClient(image.Sha)
 	pcp.hubManager.StartScanClient(hub.Host(), string(image.Sha))
 }
 
-// GetNextImage .....
-func (pcp *Perceptor) GetNextImage() api.NextImage {
+// GetNextImage hands out the next image to scan.  If there isn't one, and
+// the query asks to wait, it waits until one is handed out, the wait runs
+// out, or the client goes away.
+func (pcp *Perceptor) GetNextImage(ctx context.Context, query *api.NextImageQuery) api.NextImage {
 	recordGetNextImage()
 	log.Debugf("handling GET next image")
-	ch := make(chan *api.ImageSpec)
-	pcp.getNextImageCh <- ch
-	nextImage := *api.NewNextImage(<-ch)
-	log.Debugf("handled GET next image -- %+v", nextImage)
-	return nextImage
+	var timeout <-chan time.Time
+	if query.Wait > 0 {
+		timer := time.NewTimer(query.Wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		// get the signal first, so that images queued in the meantime aren't missed
+		imageQueued := pcp.model.ImageQueued()
+		ch := make(chan *api.ImageSpec)
+		pcp.getNextImageCh <- ch
+		nextImage := *api.NewNextImage(<-ch)
+		if nextImage.ImageSpec != nil || timeout == nil {
+			log.Debugf("handled GET next image -- %+v", nextImage)
+			return nextImage
+		}
+		select {
+		case <-imageQueued:
+		case <-timeout:
+			log.Debugf("handled GET next image -- waited %s, none found", query.Wait)
+			return nextImage
+		case <-ctx.Done():
+			log.Debugf("handled GET next image -- client went away")
+			return nextImage
+		case <-pcp.stop:
+			return nextImage
+		}
+	}
 }
 
-// PostFinishScan .....
+// PostFinishScan rejects jobs whose lease is no longer held, since another
+// scan client may be scanning the same image.
 func (pcp *Perceptor) PostFinishScan(job api.FinishedScanClientJob) error {
 	recordPostFinishedScan()
-	go func() {
-		log.Debugf("handle didFinishScanClient")
-		var scanErr error
-		if job.Err != "" {
-			scanErr = fmt.Errorf(job.Err)
+	if job.Timings != nil {
+		recordScanClientJobTimings(job.Timings)
+	}
+	log.Debugf("handle didFinishScanClient")
+	if job.DryRun {
+		return pcp.finishDryRunScan(job)
+	}
+	var scanErr error
+	switch {
+	case job.Err == "":
+	case job.FailureCategory == "" && job.Failure == nil:
+		scanErr = fmt.Errorf(job.Err)
+	default:
+		scanErr = scanClientError(job)
+	}
+	image := m.NewImage(job.ImageSpec.Repository, job.ImageSpec.Tag, m.DockerImageSha(job.ImageSpec.Sha), job.ImageSpec.Priority)
+	if sizeBytes := reportedImageSize(job); sizeBytes > 0 {
+		pcp.model.SetImageSize(image.Sha, sizeBytes)
+	}
+	if err := pcp.model.FinishScanJob(image, job.Scanner, job.ImageSpec.LeaseID, scanErr); err != nil {
+		if leaseErr, ok := err.(*api.ScanLeaseError); ok {
+			log.Warnf("rejecting finished scan job from %s: %s", job.Scanner, leaseErr.Error())
+			return leaseErr
 		}
+		log.Errorf("unable to finish scan job for image %s: %s", job.ImageSpec.Sha, err.Error())
+	}
+	if job.ScanCliVersion != "" || job.SignatureScannerVersion != "" {
+		pcp.model.RecordScanClient(image.Sha, job.Scanner, job.ScanCliVersion, job.SignatureScannerVersion)
+	}
+	go func() {
 		err := pcp.hubManager.FinishScanClient(job.ImageSpec.HubURL, job.ImageSpec.HubScanName, scanErr)
 		if err != nil {
 			log.Errorf("unable to record FinishScanClient for hub %s, image %s:", job.ImageSpec.HubURL, job.ImageSpec.HubScanName)
 		}
-		image := m.NewImage(job.ImageSpec.Repository, job.ImageSpec.Tag, m.DockerImageSha(job.ImageSpec.Sha), job.ImageSpec.Priority)
-		pcp.model.FinishScanJob(image, scanErr)
 	}()
 	log.Debugf("handled finished scan job -- %v", job)
 	return nil
 }
 
+// reportedImageSize is the image's tarball size, if the scan client got far
+// enough to measure it; 0 otherwise.
+func reportedImageSize(job api.FinishedScanClientJob) int64 {
+	if job.Timings != nil && job.Timings.TarSizeBytes > 0 {
+		return job.Timings.TarSizeBytes
+	}
+	if job.Failure != nil {
+		return job.Failure.ImageSizeBytes
+	}
+	return 0
+}
+
+// scanClientError keeps the classification and details of a failed job, which
+// end up in the image's failure history.
+func scanClientError(job api.FinishedScanClientJob) *m.ScanClientError {
+	scanErr := &m.ScanClientError{Message: job.Err, Category: job.FailureCategory}
+	if job.Failure != nil {
+		scanErr.Detail = job.Failure.Capped()
+		if scanErr.Detail.Scanner == "" {
+			scanErr.Detail.Scanner = job.Scanner
+		}
+		if scanErr.Category == "" {
+			scanErr.Category = job.Failure.Category
+		}
+	}
+	if scanErr.Category != "" && !api.IsKnownScanFailureCategory(scanErr.Category) {
+		log.Warnf("unrecognized scan failure category %s from %s, treating it as retryable", scanErr.Category, job.Scanner)
+	}
+	return scanErr
+}
+
+// errDryRunScan tells the hub manager to stop tracking the scan of an image
+// which was only pretended to be scanned.
+var errDryRunScan = fmt.Errorf("dry run scan: nothing was uploaded")
+
+func (pcp *Perceptor) finishDryRunScan(job api.FinishedScanClientJob) error {
+	image := m.NewImage(job.ImageSpec.Repository, job.ImageSpec.Tag, m.DockerImageSha(job.ImageSpec.Sha), job.ImageSpec.Priority)
+	if err := pcp.model.FinishDryRunScanJob(image, job.Scanner, job.ImageSpec.LeaseID); err != nil {
+		if leaseErr, ok := err.(*api.ScanLeaseError); ok {
+			log.Warnf("rejecting finished dry run scan job from %s: %s", job.Scanner, leaseErr.Error())
+			return leaseErr
+		}
+		log.Errorf("unable to finish dry run scan job for image %s: %s", job.ImageSpec.Sha, err.Error())
+	}
+	go func() {
+		err := pcp.hubManager.FinishScanClient(job.ImageSpec.HubURL, job.ImageSpec.HubScanName, errDryRunScan)
+		if err != nil {
+			log.Errorf("unable to record FinishScanClient for hub %s, image %s:", job.ImageSpec.HubURL, job.ImageSpec.HubScanName)
+		}
+	}()
+	log.Debugf("handled finished dry run scan job -- %v", job)
+	return nil
+}
+
+// AcknowledgeScanLease .....
+func (pcp *Perceptor) AcknowledgeScanLease(ack api.ScanLeaseAcknowledgement) error {
+	recordAcknowledgeScanLease()
+	if err := pcp.model.AcknowledgeScanLease(m.DockerImageSha(ack.Sha), ack.LeaseID); err != nil {
+		log.Warnf("rejecting scan lease acknowledgement from %s: %s", ack.Scanner, err.Error())
+		return err
+	}
+	return nil
+}
+
+// SetConcurrentScanLimit takes effect before the next image is handed out.
+func (pcp *Perceptor) SetConcurrentScanLimit(limit api.SetConcurrentScanLimit) error {
+	if limit.Limit < 0 {
+		return api.NewValidationError("invalid concurrent scan limit %d: must be at least 0", limit.Limit)
+	}
+	recordSetConcurrentScanLimit()
+	select {
+	case <-pcp.stop:
+		return &api.StoppedError{}
+	case pcp.setConcurrentScanLimitCh <- limit.Limit:
+		return nil
+	}
+}
+
+// GetConcurrentScanLimit .....
+func (pcp *Perceptor) GetConcurrentScanLimit() api.ConcurrentScanLimit {
+	recordGetConcurrentScanLimit()
+	return pcp.concurrentScanLimit()
+}
+
+func (pcp *Perceptor) concurrentScanLimit() api.ConcurrentScanLimit {
+	ch := make(chan api.ConcurrentScanLimit)
+	pcp.getConcurrentScanLimitCh <- ch
+	return <-ch
+}
+
+// GetMetricsSummary .....
+func (pcp *Perceptor) GetMetricsSummary() (*api.MetricsSummary, error) {
+	recordGetMetricsSummary()
+	now := time.Now()
+	coreModel, err := pcp.coreModelSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	scanQueue := api.NewScanQueueStats(coreModel, pcp.concurrentScanLimit(), now)
+	return pcp.metricsSummarizer.summary(now, scanQueue)
+}
+
+// GetScanQueueStats is served from the model snapshot, so that it's cheap
+// to poll.
+func (pcp *Perceptor) GetScanQueueStats() *api.ScanQueueStats {
+	recordGetScanQueueStats()
+	coreModel, err := pcp.coreModelSnapshot()
+	if err != nil {
+		log.Errorf("unable to get scan queue stats: %v", err)
+		return nil
+	}
+	return api.NewScanQueueStats(coreModel, pcp.concurrentScanLimit(), time.Now())
+}
+
 // internal use
 
 // PostCommand .....
@@ -364,18 +817,147 @@ func (pcp *Perceptor) PostCommand(command *api.PostCommand) {
 	log.Debugf("handled post command -- %+v", command)
 }
 
+// shutdown
+
+// BeginShutdown makes perceptor report itself as not ready, so that load
+// balancers stop sending it traffic while in-flight requests are drained.
+func (pcp *Perceptor) BeginShutdown() {
+	if atomic.CompareAndSwapInt32(&pcp.shuttingDown, 0, 1) {
+		log.Infof("shutting down: no longer ready")
+		recordEvent("shutdown", "begin")
+	}
+}
+
+func (pcp *Perceptor) isShuttingDown() bool {
+	return atomic.LoadInt32(&pcp.shuttingDown) == 1
+}
+
+// Stop stops perceptor's background goroutines; it's safe to call more than once.
+func (pcp *Perceptor) Stop() {
+	pcp.stopOnce.Do(func() {
+		close(pcp.stop)
+	})
+}
+
+// audit
+
+// AuditLog is where the HTTP server records mutating requests.
+func (pcp *Perceptor) AuditLog() *api.AuditLog {
+	return pcp.auditLog
+}
+
+// GetAuditLog .....
+func (pcp *Perceptor) GetAuditLog(query *api.AuditQuery) (*api.AuditPage, error) {
+	recordGetAuditLog()
+	return pcp.auditLog.Page(query), nil
+}
+
+// source statistics
+
+// SourceStats is where the HTTP server counts each source's requests.
+func (pcp *Perceptor) SourceStats() *api.SourceStats {
+	return pcp.sourceStats
+}
+
+// GetSourceStats .....
+func (pcp *Perceptor) GetSourceStats() (*api.SourceStatsReport, error) {
+	recordGetSourceStats()
+	return pcp.sourceStats.Report(), nil
+}
+
+// health
+
+// GetLiveness checks that the model's reducer loop is still processing actions.
+func (pcp *Perceptor) GetLiveness() *api.Health {
+	timeout := pcp.config.healthConfig().reducerTimeout()
+	reducer := &api.HealthCheck{Name: "reducer", Healthy: pcp.model.IsResponsive(timeout)}
+	if !reducer.Healthy {
+		reducer.Message = fmt.Sprintf("model did not respond within %s", timeout)
+	}
+	return api.NewHealth([]*api.HealthCheck{reducer})
+}
+
+// GetReadiness checks that perceptor has hub clients, and that they've fetched
+// their scans -- unless those checks are disabled -- and, if asked to, that
+// at least one of them is up.  It only looks at the hubs' cached health, so
+// it doesn't wait on them.
+func (pcp *Perceptor) GetReadiness(query *api.ReadinessQuery) *api.Health {
+	healthConfig := pcp.config.healthConfig()
+	// if we're answering, the HTTP server is up -- but may be draining
+	httpServer := &api.HealthCheck{Name: "httpServer", Healthy: !pcp.isShuttingDown()}
+	if !httpServer.Healthy {
+		httpServer.Message = "shutting down"
+	}
+	checks := []*api.HealthCheck{httpServer}
+	hubClients := pcp.hubManager.HubClients()
+	hubURLs := []string{}
+	for hubURL := range hubClients {
+		hubURLs = append(hubURLs, hubURL)
+	}
+	sort.Strings(hubURLs)
+	hubs := make([]*api.HubHealth, len(hubURLs))
+	for i, hubURL := range hubURLs {
+		hubs[i] = hubClients[hubURL].Health()
+	}
+	if !healthConfig.SkipHubClientCheck {
+		check := &api.HealthCheck{Name: "hubClients", Healthy: len(hubClients) > 0}
+		if !check.Healthy {
+			check.Message = "no hub clients have been created"
+		}
+		checks = append(checks, check)
+	}
+	if !healthConfig.SkipHubSyncCheck {
+		unsynced := []string{}
+		for _, hubHealth := range hubs {
+			if !hubHealth.HasFetchedScans {
+				unsynced = append(unsynced, hubHealth.Host)
+			}
+		}
+		check := &api.HealthCheck{Name: "hubSync", Healthy: len(unsynced) == 0}
+		if !check.Healthy {
+			check.Message = fmt.Sprintf("scans not yet fetched from hubs %v", unsynced)
+			deadline := time.Duration(healthConfig.HubSyncDeadlineSeconds) * time.Second
+			if healthConfig.HubSyncDeadlineSeconds > 0 && time.Now().Sub(pcp.startTime) > deadline {
+				check.Healthy = true
+				check.Message = fmt.Sprintf("degraded: %s after %s", check.Message, deadline)
+			}
+		}
+		checks = append(checks, check)
+	}
+	if query.FailIfAllHubsDown {
+		up := 0
+		for _, hubHealth := range hubs {
+			if hubHealth.Status == hub.ClientStatusUp.String() {
+				up++
+			}
+		}
+		check := &api.HealthCheck{Name: "hubsUp", Healthy: up > 0}
+		if !check.Healthy {
+			check.Message = fmt.Sprintf("none of the %d hub clients are up", len(hubs))
+		}
+		checks = append(checks, check)
+	}
+	health := api.NewHealth(checks)
+	health.Hubs = hubs
+	return health
+}
+
 // errors
 
 // NotFound .....
 func (pcp *Perceptor) NotFound(w http.ResponseWriter, r *http.Request) {
-	log.Errorf("HTTPResponder not found from request %+v", r)
+	api.RequestLogger(r).Errorf("HTTPResponder not found from request %+v", r)
 	recordHTTPNotFound(r)
-	http.NotFound(w, r)
+	api.WriteError(w, r, fmt.Errorf("%s %s not found", r.Method, r.URL.Path), http.StatusNotFound, pcp.legacyTextErrors())
 }
 
 // Error .....
 func (pcp *Perceptor) Error(w http.ResponseWriter, r *http.Request, err error, statusCode int) {
-	log.Errorf("HTTPResponder error %s with code %d from request %+v", err.Error(), statusCode, r)
+	statusCode = api.WriteError(w, r, err, statusCode, pcp.legacyTextErrors())
+	api.RequestLogger(r).Errorf("HTTPResponder error %s with code %d from request %+v", err.Error(), statusCode, r)
 	recordHTTPError(r, err, statusCode)
-	http.Error(w, err.Error(), statusCode)
+}
+
+func (pcp *Perceptor) legacyTextErrors() bool {
+	return pcp.config.Perceptor != nil && pcp.config.Perceptor.LegacyTextErrors
 }
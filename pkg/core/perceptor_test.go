@@ -22,7 +22,11 @@ under the License.
 package core
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"sync"
 	"time"
 
@@ -77,7 +81,8 @@ func newPerceptorPrepopulatedClients(fetchUnknownScansPause time.Duration) *Perc
 		hub2Host: {image3.Sha},
 		hub3Host: {},
 	}
-	createClient := func(hubURL string) (*hub.Hub, error) {
+	createClient := func(spec api.HubSpec) (*hub.Hub, error) {
+		hubURL := spec.Host
 		mockRawClient := hub.NewMockRawClient(false, scans[hubURL])
 		hubTimings := &hub.Timings{
 			ScanCompletionPause:    1 * time.Minute,
@@ -127,6 +132,14 @@ func makeImageSpec(image *api.Image, hub string) *api.ImageSpec {
 	}
 }
 
+// makeLeasedImageSpec is makeImageSpec for the hub and lease `next` was handed out with.
+func makeLeasedImageSpec(image *api.Image, next api.NextImage) *api.ImageSpec {
+	imageSpec := makeImageSpec(image, next.ImageSpec.HubURL)
+	imageSpec.LeaseID = next.ImageSpec.LeaseID
+	imageSpec.LeaseTTLSeconds = next.ImageSpec.LeaseTTLSeconds
+	return imageSpec
+}
+
 func RunTestPerceptor() {
 	Describe("Perceptor", func() {
 		It("should experience unblocked channel communication", func() {
@@ -140,23 +153,45 @@ func RunTestPerceptor() {
 			Expect(len(pcp.model.Images)).To(Equal(1))
 			Expect(pcp.model.Images[sha1].ScanStatus).To(Equal(m.ScanStatusUnknown))
 
-			pcp.hubManager.SetHubs([]string{"hub1"})
+			pcp.hubManager.SetHubs(hubSpecs([]string{"hub1"}))
 			time.Sleep(1 * time.Second)
 
 			Expect(pcp.model.Images[sha1].ScanStatus).To(Equal(m.ScanStatusInQueue))
-			Expect(pcp.GetNextImage()).To(Equal(nextImage))
+			next := pcp.GetNextImage(context.Background(), &api.NextImageQuery{})
+			Expect(next.ImageSpec).NotTo(BeNil())
+			Expect(next.ImageSpec.LeaseID).NotTo(BeEmpty())
+			Expect(next.ImageSpec.LeaseTTLSeconds).To(Equal(int(m.DefaultScanLeaseTTL.Seconds())))
+			imageSpec.LeaseID = next.ImageSpec.LeaseID
+			imageSpec.LeaseTTLSeconds = next.ImageSpec.LeaseTTLSeconds
+			Expect(next).To(Equal(nextImage))
+			Expect(pcp.AcknowledgeScanLease(api.ScanLeaseAcknowledgement{Sha: imageSpec.Sha, LeaseID: imageSpec.LeaseID})).To(BeNil())
 			Expect(pcp.PostFinishScan(api.FinishedScanClientJob{ImageSpec: *imageSpec, Err: ""})).To(BeNil())
 			time.Sleep(500 * time.Millisecond)
 
 			Expect(pcp.model.Images[sha1].ScanStatus).To(Equal(m.ScanStatusRunningHubScan))
 		})
 
+		It("should keep the details of failed scan client jobs", func() {
+			stderr := make([]string, api.MaxScanClientFailureStderrLines+1)
+			job := api.FinishedScanClientJob{
+				Err:     "exit status 1",
+				Scanner: "scanner-1",
+				Failure: &api.ScanClientFailure{Category: api.ScanFailureCategoryPullFailed, Stage: "pull", Stderr: stderr},
+			}
+			scanErr := scanClientError(job)
+			Expect(scanErr.Error()).To(Equal("exit status 1"))
+			Expect(scanErr.Category).To(Equal(api.ScanFailureCategoryPullFailed))
+			Expect(scanErr.Detail.Scanner).To(Equal("scanner-1"))
+			Expect(scanErr.Detail.Stderr).To(HaveLen(api.MaxScanClientFailureStderrLines))
+			Expect(job.Failure.Scanner).To(Equal(""))
+		})
+
 		It("should not assign scans when there are no hubs", func() {
 			pcp := newPerceptor(2, 5)
 			pcp.UpdateAllImages(api.AllImages{
 				Images: []api.Image{image1},
 			})
-			Expect(pcp.GetNextImage()).To(Equal(api.NextImage{}))
+			Expect(pcp.GetNextImage(context.Background(), &api.NextImageQuery{})).To(Equal(api.NextImage{}))
 		})
 
 		It("should not assign scans when the concurrent scan limit is 0", func() {
@@ -164,9 +199,39 @@ func RunTestPerceptor() {
 			pcp.UpdateAllImages(api.AllImages{
 				Images: []api.Image{image1},
 			})
-			pcp.hubManager.SetHubs([]string{"hub1", "hub2", "hub3"})
+			pcp.hubManager.SetHubs(hubSpecs([]string{"hub1", "hub2", "hub3"}))
+			time.Sleep(1 * time.Second)
+			Expect(pcp.GetNextImage(context.Background(), &api.NextImageQuery{})).To(Equal(api.NextImage{}))
+		})
+
+		It("should hold a waiting next image request until an image is queued", func() {
+			pcp := newPerceptor(2, 5)
+			pcp.hubManager.SetHubs(hubSpecs([]string{"hub1"}))
 			time.Sleep(1 * time.Second)
-			Expect(pcp.GetNextImage()).To(Equal(api.NextImage{}))
+			result := make(chan api.NextImage, 1)
+			go func() {
+				result <- pcp.GetNextImage(context.Background(), &api.NextImageQuery{Wait: 10 * time.Second})
+			}()
+			Consistently(result, 300*time.Millisecond).ShouldNot(Receive())
+			Expect(pcp.AddImage(image1)).To(BeNil())
+			var nextImage api.NextImage
+			Eventually(result, 5*time.Second).Should(Receive(&nextImage))
+			Expect(nextImage.ImageSpec.Sha).To(Equal(image1.Sha))
+		})
+
+		It("should give up waiting for a next image after the wait, or when the client goes away", func() {
+			pcp := newPerceptor(2, 5)
+			start := time.Now()
+			Expect(pcp.GetNextImage(context.Background(), &api.NextImageQuery{Wait: 200 * time.Millisecond})).To(Equal(api.NextImage{}))
+			Expect(time.Since(start)).To(BeNumerically(">=", 200*time.Millisecond))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			result := make(chan api.NextImage, 1)
+			go func() {
+				result <- pcp.GetNextImage(ctx, &api.NextImageQuery{Wait: time.Minute})
+			}()
+			cancel()
+			Eventually(result).Should(Receive(Equal(api.NextImage{})))
 		})
 
 		It("should assign scans to different hubs, not exceeding the concurrent scan limit of any hub", func() {
@@ -174,27 +239,27 @@ func RunTestPerceptor() {
 			pcp.UpdateAllImages(api.AllImages{
 				Images: []api.Image{image1, image2, image3, image4, image5},
 			})
-			pcp.hubManager.SetHubs([]string{"hub1", "hub2", "hub3"})
+			pcp.hubManager.SetHubs(hubSpecs([]string{"hub1", "hub2", "hub3"}))
 			time.Sleep(1 * time.Second)
 
 			Expect(pcp.model.ImageScanQueue.Size()).To(Equal(5))
 
-			next1 := pcp.GetNextImage()
-			Expect(next1).To(Equal(*api.NewNextImage(makeImageSpec(&image5, next1.ImageSpec.HubURL))))
+			next1 := pcp.GetNextImage(context.Background(), &api.NextImageQuery{})
+			Expect(next1).To(Equal(*api.NewNextImage(makeLeasedImageSpec(&image5, next1))))
 			time.Sleep(500 * time.Millisecond)
 			Expect(pcp.model.ImageScanQueue.Size()).To(Equal(4))
 
-			next2 := pcp.GetNextImage()
-			Expect(next2).To(Equal(*api.NewNextImage(makeImageSpec(&image4, next2.ImageSpec.HubURL))))
+			next2 := pcp.GetNextImage(context.Background(), &api.NextImageQuery{})
+			Expect(next2).To(Equal(*api.NewNextImage(makeLeasedImageSpec(&image4, next2))))
 			time.Sleep(500 * time.Millisecond)
 			Expect(pcp.model.ImageScanQueue.Size()).To(Equal(3))
 
-			next3 := pcp.GetNextImage()
-			Expect(next3).To(Equal(*api.NewNextImage(makeImageSpec(&image3, next3.ImageSpec.HubURL))))
+			next3 := pcp.GetNextImage(context.Background(), &api.NextImageQuery{})
+			Expect(next3).To(Equal(*api.NewNextImage(makeLeasedImageSpec(&image3, next3))))
 			time.Sleep(500 * time.Millisecond)
 			Expect(pcp.model.ImageScanQueue.Size()).To(Equal(2))
 
-			Expect(pcp.GetNextImage()).To(Equal(api.NextImage{}))
+			Expect(pcp.GetNextImage(context.Background(), &api.NextImageQuery{})).To(Equal(api.NextImage{}))
 			Expect(pcp.model.ImageScanQueue.Size()).To(Equal(2))
 		})
 
@@ -203,12 +268,12 @@ func RunTestPerceptor() {
 			pcp.UpdateAllImages(api.AllImages{
 				Images: []api.Image{image1, image2},
 			})
-			pcp.hubManager.SetHubs([]string{"hub1"})
+			pcp.hubManager.SetHubs(hubSpecs([]string{"hub1"}))
 			time.Sleep(1 * time.Second)
 
 			Expect(pcp.model.ImageScanQueue.Size()).To(Equal(2))
 
-			next1 := pcp.GetNextImage()
+			next1 := pcp.GetNextImage(context.Background(), &api.NextImageQuery{})
 			Expect(next1.ImageSpec.Sha).To(Equal(image2.Sha))
 			time.Sleep(500 * time.Millisecond)
 			Expect(pcp.model.ImageScanQueue.Size()).To(Equal(1))
@@ -227,7 +292,7 @@ func RunTestPerceptor() {
 			pcp.UpdateAllImages(api.AllImages{
 				Images: []api.Image{image1, image2, image3, image4, image5},
 			})
-			pcp.hubManager.SetHubs([]string{hub1Host, hub2Host, hub3Host})
+			pcp.hubManager.SetHubs(hubSpecs([]string{hub1Host, hub2Host, hub3Host}))
 			time.Sleep(1 * time.Second)
 
 			// jbs, _ := json.MarshalIndent(pcp.GetModel(), "", "  ")
@@ -246,7 +311,7 @@ func RunTestPerceptor() {
 			pcp.UpdateAllImages(api.AllImages{
 				Images: []api.Image{image1, image2, image3, image4, image5},
 			})
-			pcp.hubManager.SetHubs([]string{"hub1"})
+			pcp.hubManager.SetHubs(hubSpecs([]string{"hub1"}))
 			time.Sleep(1 * time.Second)
 
 			var i1 *api.NextImage
@@ -254,12 +319,12 @@ func RunTestPerceptor() {
 			var wg sync.WaitGroup
 			wg.Add(2)
 			go func() {
-				i := pcp.GetNextImage()
+				i := pcp.GetNextImage(context.Background(), &api.NextImageQuery{})
 				i1 = &i
 				wg.Done()
 			}()
 			go func() {
-				i := pcp.GetNextImage()
+				i := pcp.GetNextImage(context.Background(), &api.NextImageQuery{})
 				i2 = &i
 				wg.Done()
 			}()
@@ -267,4 +332,135 @@ func RunTestPerceptor() {
 			Expect(i1).NotTo(Equal(i2))
 		})
 	})
+	Describe("Perceptor concurrent scan limit", func() {
+		It("should apply a new limit to the next image", func() {
+			pcp := newPerceptor(0, 5)
+			pcp.UpdateAllImages(api.AllImages{
+				Images: []api.Image{image1, image2},
+			})
+			pcp.hubManager.SetHubs(hubSpecs([]string{"hub1"}))
+			time.Sleep(1 * time.Second)
+			Expect(pcp.GetNextImage(context.Background(), &api.NextImageQuery{})).To(Equal(api.NextImage{}))
+
+			Expect(pcp.SetConcurrentScanLimit(api.SetConcurrentScanLimit{Limit: 1})).To(BeNil())
+			Expect(pcp.GetNextImage(context.Background(), &api.NextImageQuery{}).ImageSpec).ToNot(BeNil())
+			Expect(pcp.GetConcurrentScanLimit()).To(Equal(api.ConcurrentScanLimit{Limit: 1, InProgressScans: 1}))
+			Expect(pcp.GetNextImage(context.Background(), &api.NextImageQuery{})).To(Equal(api.NextImage{}))
+		})
+
+		It("should reject negative limits", func() {
+			pcp := newPerceptor(2, 5)
+			Expect(pcp.SetConcurrentScanLimit(api.SetConcurrentScanLimit{Limit: -1})).ToNot(BeNil())
+			Expect(pcp.GetConcurrentScanLimit().Limit).To(Equal(2))
+		})
+
+		It("should serve new namespace scan limits from the model", func() {
+			pcp := newPerceptorPrepopulatedClients(time.Hour)
+			api.SetupHTTPServer(pcp)
+			get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+				request := httptest.NewRequest("GET", "/model", nil)
+				if ifNoneMatch != "" {
+					request.Header.Set("If-None-Match", ifNoneMatch)
+				}
+				recorder := httptest.NewRecorder()
+				http.DefaultServeMux.ServeHTTP(recorder, request)
+				return recorder
+			}
+			time.Sleep(500 * time.Millisecond)
+			first := get("")
+			Expect(first.Code).To(Equal(http.StatusOK))
+			Expect(get(first.Header().Get("ETag")).Code).To(Equal(http.StatusNotModified))
+
+			pcp.model.SetNamespaceScanLimits(map[string]int{"ns1": 1})
+			second := get(first.Header().Get("ETag"))
+			Expect(second.Code).To(Equal(http.StatusOK))
+			var model api.Model
+			Expect(json.Unmarshal(second.Body.Bytes(), &model)).To(BeNil())
+			Expect(model.CoreModel.NamespaceScanLimits).To(Equal(map[string]int{"ns1": 1}))
+		})
+	})
+
+	Describe("Perceptor health", func() {
+		It("should be alive while the model is responsive", func() {
+			pcp := newPerceptor(2, 5)
+			Expect(pcp.GetLiveness().Healthy).To(BeTrue())
+		})
+
+		It("should not be ready without hub clients", func() {
+			pcp := newPerceptor(2, 5)
+			readiness := pcp.GetReadiness(&api.ReadinessQuery{})
+			Expect(readiness.Healthy).To(BeFalse())
+			Expect(readiness.Checks[1].Name).To(Equal("hubClients"))
+			Expect(readiness.Checks[1].Healthy).To(BeFalse())
+		})
+
+		It("should be ready once the hubs have fetched their scans", func() {
+			pcp := newPerceptor(2, 5)
+			pcp.hubManager.SetHubs(hubSpecs([]string{"hub1"}))
+			time.Sleep(1 * time.Second)
+			Expect(pcp.GetReadiness(&api.ReadinessQuery{}).Healthy).To(BeTrue())
+		})
+
+		It("should describe each hub's connectivity", func() {
+			pcp := newPerceptor(2, 5)
+			pcp.hubManager.SetHubs(hubSpecs([]string{"hub1"}))
+			time.Sleep(1 * time.Second)
+			readiness := pcp.GetReadiness(&api.ReadinessQuery{FailIfAllHubsDown: true})
+			Expect(readiness.Healthy).To(BeTrue())
+			Expect(len(readiness.Hubs)).To(Equal(1))
+			hubHealth := readiness.Hubs[0]
+			Expect(hubHealth.Host).To(Equal("hub1"))
+			Expect(hubHealth.Status).To(Equal(hub.ClientStatusUp.String()))
+			Expect(hubHealth.CircuitBreakerState).To(Equal(hub.CircuitBreakerStateEnabled.String()))
+			Expect(hubHealth.HasFetchedScans).To(BeTrue())
+			Expect(hubHealth.TimeSinceLastLogin).NotTo(BeNil())
+			Expect(hubHealth.TimeSinceLastSync).NotTo(BeNil())
+			Expect(hubHealth.RecentErrors).To(Equal(0))
+		})
+
+		It("should only fail when all hubs are down if asked to", func() {
+			pcp := newPerceptor(2, 5)
+			pcp.config.Perceptor = &PerceptorConfig{
+				Health: &HealthConfig{SkipHubSyncCheck: true},
+			}
+			pcp.hubManager.(*HubManager).newHub = func(spec api.HubSpec) (*hub.Hub, error) {
+				return hub.NewHub("mock-username", "mock-password", spec.Host, hub.NewMockRawClient(true, []string{}), hub.DefaultTimings), nil
+			}
+			pcp.hubManager.SetHubs(hubSpecs([]string{"hub1", "hub2"}))
+			time.Sleep(1 * time.Second)
+			Expect(pcp.GetReadiness(&api.ReadinessQuery{}).Healthy).To(BeTrue())
+			readiness := pcp.GetReadiness(&api.ReadinessQuery{FailIfAllHubsDown: true})
+			Expect(readiness.Healthy).To(BeFalse())
+			Expect(readiness.Checks[len(readiness.Checks)-1].Name).To(Equal("hubsUp"))
+			Expect(readiness.Hubs[0].Status).To(Equal(hub.ClientStatusDown.String()))
+			Expect(readiness.Hubs[0].TimeSinceLastLogin).To(BeNil())
+			Expect(readiness.Hubs[0].RecentErrors).To(BeNumerically(">", 0))
+		})
+
+		It("should skip disabled readiness checks", func() {
+			pcp := newPerceptor(2, 5)
+			pcp.config.Perceptor = &PerceptorConfig{
+				Health: &HealthConfig{SkipHubClientCheck: true, SkipHubSyncCheck: true},
+			}
+			readiness := pcp.GetReadiness(&api.ReadinessQuery{})
+			Expect(readiness.Healthy).To(BeTrue())
+			Expect(len(readiness.Checks)).To(Equal(1))
+		})
+
+		It("should stop being ready as soon as shutdown begins", func() {
+			pcp := newPerceptor(2, 5)
+			pcp.config.Perceptor = &PerceptorConfig{
+				Health: &HealthConfig{SkipHubClientCheck: true, SkipHubSyncCheck: true},
+			}
+			pcp.BeginShutdown()
+			readiness := pcp.GetReadiness(&api.ReadinessQuery{})
+			Expect(readiness.Healthy).To(BeFalse())
+			Expect(readiness.Checks[0].Message).To(Equal("shutting down"))
+			Expect(pcp.GetLiveness().Healthy).To(BeTrue())
+
+			pcp.Stop()
+			pcp.Stop()
+			Expect(pcp.SetConcurrentScanLimit(api.SetConcurrentScanLimit{Limit: 3})).To(BeAssignableToTypeOf(&api.StoppedError{}))
+		})
+	})
 }
@@ -0,0 +1,93 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package core
+
+import (
+	"encoding/json"
+	"math/rand"
+	"strings"
+
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var podFuzzSeeds = []string{
+	`{"Name":"pod1","UID":"uid1","Namespace":"ns1","Labels":{"app":"web"},"Containers":[{"Name":"c1","Image":{"Repository":"repo","Tag":"1.0","Sha":"` + strings.Repeat("ab", 32) + `","Priority":2}}]}`,
+	`{"Name":"pod2","Namespace":"ns2","Containers":[]}`,
+	`{"Name":"pod3","Namespace":"ns3","Containers":[{"Name":"c1","Image":{"Repository":"repo","Sha":"` + strings.Repeat("0", 64) + `"}},{"Name":"c2","Image":{"Repository":"a/b","Sha":"` + strings.Repeat("f", 64) + `","Priority":null}}]}`,
+}
+
+// mutateJSON replaces, deletes or duplicates a few random bytes, with a bias
+// towards characters that matter to the JSON and to the validator.
+func mutateJSON(r *rand.Rand, input string) string {
+	interesting := []byte(`{}[]",:0aAf-._ ` + "\x00")
+	bytes := []byte(input)
+	for i := r.Intn(4) + 1; i > 0 && len(bytes) > 0; i-- {
+		ix := r.Intn(len(bytes))
+		switch r.Intn(3) {
+		case 0:
+			bytes[ix] = interesting[r.Intn(len(interesting))]
+		case 1:
+			bytes = append(bytes[:ix], bytes[ix+1:]...)
+		case 2:
+			bytes = append(bytes[:ix], append([]byte{bytes[ix]}, bytes[ix:]...)...)
+		}
+	}
+	return string(bytes)
+}
+
+func RunPodValidationTests() {
+	Describe("Pod validation", func() {
+		It("never accepts a decoded pod which can't be converted", func() {
+			r := rand.New(rand.NewSource(1150))
+			validCount := 0
+			for i := 0; i < 5000; i++ {
+				input := mutateJSON(r, podFuzzSeeds[r.Intn(len(podFuzzSeeds))])
+				var pod api.Pod
+				if err := json.Unmarshal([]byte(input), &pod); err != nil {
+					continue
+				}
+				if _, err := api.ValidatePod(pod); err != nil {
+					continue
+				}
+				validCount++
+				_, err := APIPodToCorePod(pod)
+				Expect(err).To(BeNil(), input)
+			}
+			Expect(validCount).To(BeNumerically(">", 0))
+		})
+
+		It("keeps invalid pods out of the model", func() {
+			pcp := newPerceptor(2, 5)
+			pod := api.Pod{Name: "pod1", Namespace: "ns1", Containers: []api.Container{{Name: "c1", Image: image2}}}
+			Expect(pcp.AddPod(pod)).To(BeAssignableToTypeOf(&api.ValidationError{}))
+			_, err := pcp.UpdatePod(pod)
+			Expect(err).To(BeAssignableToTypeOf(&api.ValidationError{}))
+			Expect(pcp.UpdateAllPods(api.AllPods{Pods: []api.Pod{pod}})).To(BeAssignableToTypeOf(&api.ValidationError{}))
+			coreModel, err := pcp.coreModelSnapshot()
+			Expect(err).To(BeNil())
+			Expect(coreModel.Pods).To(BeEmpty())
+			Expect(coreModel.Images).To(BeEmpty())
+		})
+	})
+}
@@ -39,11 +39,16 @@ type RoutineTaskManager struct {
 	modelMetricsTimer      *util.Timer
 	stalledScanClientTimer *util.Timer
 	unknownImagesTimer     *util.Timer
+	scanLeasesTimer        *util.Timer
 	// channels
 	metricsCh       chan bool
 	unknownImagesCh chan bool
+	scanLeasesCh    chan bool
 }
 
+// scanLeaseCheckPause is how often unacknowledged scan leases are expired.
+const scanLeaseCheckPause = 5 * time.Second
+
 // NewRoutineTaskManager ...
 func NewRoutineTaskManager(stop <-chan struct{}, timings *Timings) *RoutineTaskManager {
 	rtm := &RoutineTaskManager{
@@ -53,10 +58,12 @@ func NewRoutineTaskManager(stop <-chan struct{}, timings *Timings) *RoutineTaskM
 		timings:         timings,
 		metricsCh:       make(chan bool),
 		unknownImagesCh: make(chan bool),
+		scanLeasesCh:    make(chan bool),
 	}
 	rtm.stalledScanClientTimer = rtm.startCheckingForStalledScanClientScans()
 	rtm.modelMetricsTimer = rtm.startGeneratingModelMetrics()
 	rtm.unknownImagesTimer = rtm.startCheckingForUnknownImages(timings.UnknownImagePause())
+	rtm.scanLeasesTimer = rtm.startExpiringScanLeases()
 	go func() {
 		for {
 			select {
@@ -72,8 +79,12 @@ func NewRoutineTaskManager(stop <-chan struct{}, timings *Timings) *RoutineTaskM
 				}()
 			case newTimings := <-rtm.writeTimings:
 				rtm.timings = newTimings
-				rtm.stalledScanClientTimer.SetDelay(newTimings.StalledScanClientTimeout())
-				rtm.modelMetricsTimer.SetDelay(newTimings.ModelMetricsPause())
+				if err := rtm.stalledScanClientTimer.SetDelay(newTimings.StalledScanClientTimeout()); err != nil {
+					log.Errorf("unable to update stalled scan client timer: %s", err.Error())
+				}
+				if err := rtm.modelMetricsTimer.SetDelay(newTimings.ModelMetricsPause()); err != nil {
+					log.Errorf("unable to update model metrics timer: %s", err.Error())
+				}
 			}
 		}
 	}()
@@ -125,3 +136,13 @@ func (rtm *RoutineTaskManager) startCheckingForUnknownImages(pause time.Duration
 		}
 	})
 }
+
+func (rtm *RoutineTaskManager) startExpiringScanLeases() *util.Timer {
+	return util.NewRunningTimer("scanLeases", scanLeaseCheckPause, rtm.stop, false, func() {
+		select {
+		case <-rtm.stop:
+			return
+		case rtm.scanLeasesCh <- true:
+		}
+	})
+}
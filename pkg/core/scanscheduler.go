@@ -46,6 +46,15 @@ func (s *ScanScheduler) AssignImage(image *m.Image) *hub.Hub {
 	return nil
 }
 
+// InProgressScanCount is the number of scans in progress across all hubs.
+func (s *ScanScheduler) InProgressScanCount() int {
+	count := 0
+	for _, hub := range s.HubManager.HubClients() {
+		count += len(<-hub.InProgressScans())
+	}
+	return count
+}
+
 func (s *ScanScheduler) model() *api.ModelScanScheduler {
 	return &api.ModelScanScheduler{
 		ConcurrentScanLimit: s.ConcurrentScanLimit,
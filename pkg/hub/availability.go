@@ -0,0 +1,70 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package hub
+
+import (
+	"time"
+)
+
+// availability accumulates how long a hub has spent in each status, so that
+// its availability can be worked out from the metrics.  It must only be
+// used from within the hub's actions.
+type availability struct {
+	host   string
+	status ClientStatus
+	// since is the start of the time which hasn't been accounted for yet
+	since     time.Time
+	durations map[ClientStatus]time.Duration
+	now       func() time.Time
+}
+
+func newAvailability(host string, status ClientStatus, now func() time.Time) *availability {
+	recordHubStatus(host, status)
+	return &availability{
+		host:      host,
+		status:    status,
+		since:     now(),
+		durations: map[ClientStatus]time.Duration{},
+		now:       now,
+	}
+}
+
+// tick accounts for the time spent in the current status since the last
+// tick or transition, so that long steady states still show up.
+func (a *availability) tick() {
+	now := a.now()
+	elapsed := now.Sub(a.since)
+	a.since = now
+	a.durations[a.status] += elapsed
+	recordHubStatusDuration(a.host, a.status, elapsed)
+}
+
+// transition switches to `status`, doing nothing if it's the current one.
+func (a *availability) transition(status ClientStatus) {
+	if status == a.status {
+		return
+	}
+	a.tick()
+	recordHubStatusTransition(a.host, a.status, status)
+	a.status = status
+	recordHubStatus(a.host, status)
+}
@@ -0,0 +1,83 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package hub
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func counterValue(counter prometheus.Counter) float64 {
+	metric := &dto.Metric{}
+	Expect(counter.Write(metric)).To(BeNil())
+	return metric.GetCounter().GetValue()
+}
+
+func RunAvailabilityTests() {
+	Describe("Availability", func() {
+		It("should account for the time spent in each status", func() {
+			host := "availability-host"
+			now := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+			clock := func() time.Time { return now }
+			advance := func(seconds int) { now = now.Add(time.Duration(seconds) * time.Second) }
+
+			a := newAvailability(host, ClientStatusDown, clock)
+			advance(10)
+			a.transition(ClientStatusUp)
+			advance(30)
+			a.tick()
+			advance(30)
+			a.transition(ClientStatusUp)
+			a.transition(ClientStatusDown)
+			advance(5)
+			a.transition(ClientStatusUp)
+			advance(25)
+			a.tick()
+
+			Expect(a.durations).To(Equal(map[ClientStatus]time.Duration{
+				ClientStatusUp:   85 * time.Second,
+				ClientStatusDown: 15 * time.Second,
+			}))
+			Expect(counterValue(hubStatusSeconds.With(prometheus.Labels{"host": host, "status": ClientStatusUp.String()}))).To(Equal(float64(85)))
+			Expect(counterValue(hubStatusSeconds.With(prometheus.Labels{"host": host, "status": ClientStatusDown.String()}))).To(Equal(float64(15)))
+			Expect(counterValue(hubStatusTransitions.With(prometheus.Labels{"host": host, "from": ClientStatusDown.String(), "to": ClientStatusUp.String()}))).To(Equal(float64(2)))
+			Expect(counterValue(hubStatusTransitions.With(prometheus.Labels{"host": host, "from": ClientStatusUp.String(), "to": ClientStatusDown.String()}))).To(Equal(float64(1)))
+
+			metric := &dto.Metric{}
+			Expect(hubStatusGauge.With(prometheus.Labels{"host": host}).Write(metric)).To(BeNil())
+			Expect(metric.GetGauge().GetValue()).To(Equal(float64(ClientStatusUp)))
+		})
+
+		It("should follow a hub's logins", func() {
+			rawClient, hub := newClient(true)
+			Eventually(func() string { return hub.Health().Status }).Should(Equal(ClientStatusUp.String()))
+			rawClient.SetShouldFail(true)
+			hub.Login()
+			wentDown := hubStatusTransitions.With(prometheus.Labels{"host": "host1", "from": ClientStatusUp.String(), "to": ClientStatusDown.String()})
+			Expect(counterValue(wentDown)).To(BeNumerically(">=", 1))
+		})
+	})
+}
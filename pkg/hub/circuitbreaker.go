@@ -23,19 +23,31 @@ package hub
 
 import (
 	"fmt"
-	"math"
+	"sync"
 	"time"
 
 	"github.com/blackducksoftware/perceptor/pkg/api"
+	"github.com/blackducksoftware/perceptor/pkg/util"
+)
+
+const (
+	// circuitBreakerInitialBackoff is the wait before the first check after
+	// the circuit breaker gets disabled; it doubles after every failed check
+	circuitBreakerInitialBackoff = 2 * time.Second
 )
 
 // CircuitBreaker .....
+//
+// A CircuitBreaker is shared between the hub's action loop and its timer
+// goroutines, so all of its state is guarded by mu.
 type CircuitBreaker struct {
+	mu                  sync.Mutex
 	state               CircuitBreakerState
 	nextCheckTime       *time.Time
 	maxBackoffDuration  time.Duration
 	consecutiveFailures int
 	host                string
+	backoff             *util.Backoff
 }
 
 // NewCircuitBreaker .....
@@ -45,6 +57,7 @@ func NewCircuitBreaker(host string, maxBackoffDuration time.Duration) *CircuitBr
 		maxBackoffDuration:  maxBackoffDuration,
 		consecutiveFailures: 0,
 		host:                host,
+		backoff:             util.NewBackoff(circuitBreakerInitialBackoff, maxBackoffDuration, 2, false),
 	}
 	cb.setState(CircuitBreakerStateEnabled)
 	return cb
@@ -52,6 +65,8 @@ func NewCircuitBreaker(host string, maxBackoffDuration time.Duration) *CircuitBr
 
 // Model dumps the current state of the circuit breaker
 func (cb *CircuitBreaker) Model() *api.ModelCircuitBreaker {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
 	return &api.ModelCircuitBreaker{
 		State:               cb.state.String(),
 		ConsecutiveFailures: cb.consecutiveFailures,
@@ -63,9 +78,12 @@ func (cb *CircuitBreaker) Model() *api.ModelCircuitBreaker {
 // Reset reenables the circuit breaker regardless of its current state,
 // and clears out ConsecutiveFailures and NextCheckTime
 func (cb *CircuitBreaker) Reset() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
 	cb.setState(CircuitBreakerStateEnabled)
 	cb.consecutiveFailures = 0
 	cb.nextCheckTime = nil
+	cb.backoff.Reset()
 }
 
 func (cb *CircuitBreaker) setState(state CircuitBreakerState) {
@@ -74,8 +92,21 @@ func (cb *CircuitBreaker) setState(state CircuitBreakerState) {
 	cb.state = state
 }
 
+// State returns the current state of the circuit breaker
+func (cb *CircuitBreaker) State() CircuitBreakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
 // IsEnabled .....
 func (cb *CircuitBreaker) IsEnabled() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.isEnabled()
+}
+
+func (cb *CircuitBreaker) isEnabled() bool {
 	return cb.state != CircuitBreakerStateDisabled
 }
 
@@ -84,19 +115,24 @@ func (cb *CircuitBreaker) IsEnabled() bool {
 // 2. increments a metric of the circuit breaker state
 // 3. returns whether the circuit breaker is enabled
 func (cb *CircuitBreaker) isAbleToIssueRequest() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
 	if cb.state == CircuitBreakerStateDisabled && time.Now().After(*cb.nextCheckTime) {
 		cb.setState(CircuitBreakerStateChecking)
 	}
-	isEnabled := cb.IsEnabled()
+	isEnabled := cb.isEnabled()
 	recordCircuitBreakerIsEnabled(cb.host, isEnabled)
 	return isEnabled
 }
 
 func (cb *CircuitBreaker) failure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
 	switch cb.state {
 	case CircuitBreakerStateEnabled:
 		cb.setState(CircuitBreakerStateDisabled)
 		cb.consecutiveFailures = 1
+		cb.backoff.Reset()
 		cb.setNextCheckTime()
 	case CircuitBreakerStateDisabled:
 		break
@@ -108,6 +144,8 @@ func (cb *CircuitBreaker) failure() {
 }
 
 func (cb *CircuitBreaker) success() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
 	switch cb.state {
 	case CircuitBreakerStateEnabled:
 		break
@@ -117,13 +155,12 @@ func (cb *CircuitBreaker) success() {
 		cb.setState(CircuitBreakerStateEnabled)
 		cb.consecutiveFailures = 0
 		cb.nextCheckTime = nil
+		cb.backoff.Reset()
 	}
 }
 
 func (cb *CircuitBreaker) setNextCheckTime() {
-	nextExponentialSeconds := math.Pow(2, float64(cb.consecutiveFailures))
-	nextCheckDuration := MinDuration(cb.maxBackoffDuration, time.Duration(nextExponentialSeconds)*time.Second)
-	nextCheckTime := time.Now().Add(nextCheckDuration)
+	nextCheckTime := time.Now().Add(cb.backoff.Next())
 	cb.nextCheckTime = &nextCheckTime
 }
 
@@ -131,6 +168,8 @@ func (cb *CircuitBreaker) setNextCheckTime() {
 //  - checks whether it's enabled
 //  - runs 'request'
 //  - looks at the result of 'request', disabling itself on failure
+// The lock is not held while 'request' runs, so a slow request doesn't
+// block Reset or Model.
 func (cb *CircuitBreaker) IssueRequest(description string, request func() error) error {
 	if !cb.isAbleToIssueRequest() {
 		return fmt.Errorf("unable to issue request %s, circuit breaker is disabled", description)
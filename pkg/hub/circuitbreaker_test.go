@@ -126,3 +126,30 @@ func TestCircuitBreakerConsecutiveFailures(t *testing.T) {
 	// assertEqual(t, "consecutive failures", cb.consecutiveFailures, 3)
 	// assertEqual(t, "is enabled", cb.IsEnabled(), false)
 }
+
+// TestCircuitBreakerConcurrentReset .....
+func TestCircuitBreakerConcurrentReset(t *testing.T) {
+	cb := NewCircuitBreaker("testhost", 10*time.Minute)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			cb.IssueRequest("abc", func() error {
+				if i%2 == 0 {
+					return fmt.Errorf("planned failure")
+				}
+				return nil
+			})
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		cb.Reset()
+		cb.Model()
+		cb.State()
+	}
+	<-done
+	cb.Reset()
+	if cb.State() != CircuitBreakerStateEnabled {
+		t.Errorf("expected CircuitBreakerStateEnabled, found %s", cb.State())
+	}
+}
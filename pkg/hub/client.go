@@ -22,18 +22,70 @@ under the License.
 package hub
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/blackducksoftware/hub-client-go/hubapi"
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	"github.com/blackducksoftware/perceptor/pkg/util"
 	"github.com/juju/errors"
 	log "github.com/sirupsen/logrus"
 )
 
-// Client combines a raw hub client with a circuit breaker
+const (
+	// hubRequestsPerSecond and hubRequestBurst throttle the requests a
+	// client makes, so that bursts of scan fetches don't swamp the hub
+	hubRequestsPerSecond = 20
+	hubRequestBurst      = 40
+)
+
+var (
+	// ErrServerUnavailable is the cause of requests which the hub answered
+	// with a 5xx
+	ErrServerUnavailable = fmt.Errorf("hub server unavailable")
+	// ErrTimeout is the cause of requests which timed out
+	ErrTimeout = fmt.Errorf("hub request timed out")
+)
+
+// hubRetryPolicy retries requests which failed for reasons likely to clear
+// up by themselves, before the circuit breaker counts them as a failure.
+var hubRetryPolicy = &util.RetryPolicy{
+	Backoff:     util.NewBackoff(250*time.Millisecond, 2*time.Second, 2, true),
+	MaxAttempts: 3,
+	IsRetryable: util.RetryOn(ErrServerUnavailable, ErrTimeout),
+}
+
+// unexpectedStatusRegexp matches the hub client library's errors for
+// responses with the wrong status code.
+var unexpectedStatusRegexp = regexp.MustCompile(`got a (\d{3}) response`)
+
+// classifyError gives errors from the raw client a cause of ErrTimeout or
+// ErrServerUnavailable, where they apply.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if netErr, ok := errors.Cause(err).(net.Error); ok && netErr.Timeout() {
+		return errors.Wrapf(err, ErrTimeout, "%s", err.Error())
+	}
+	if match := unexpectedStatusRegexp.FindStringSubmatch(err.Error()); match != nil {
+		if statusCode, _ := strconv.Atoi(match[1]); statusCode >= 500 {
+			return errors.Wrapf(err, ErrServerUnavailable, "%s", err.Error())
+		}
+	}
+	return err
+}
+
+// Client combines a raw hub client with a circuit breaker and a rate limiter
 type Client struct {
 	rawClient      RawClientInterface
 	circuitBreaker *CircuitBreaker
+	limiter        *util.RateLimiter
 	host           string
 	username       string
 	password       string
@@ -44,6 +96,7 @@ func NewClient(username string, password string, host string, rawClient RawClien
 	return &Client{
 		rawClient:      rawClient,
 		circuitBreaker: NewCircuitBreaker(host, maxHubExponentialBackoffDuration),
+		limiter:        util.NewRateLimiter(hubRequestsPerSecond, hubRequestBurst),
 		username:       username,
 		password:       password,
 		host:           host,
@@ -74,6 +127,26 @@ func (client *Client) SetTimeout(timeout time.Duration) {
 	client.rawClient.SetTimeout(timeout)
 }
 
+// issueRequest issues the request through the circuit breaker, retrying
+// timeouts and 5xxs, and waiting for the rate limiter before each attempt.
+// Against an unresponsive hub, a call can take three client timeouts plus
+// up to 750ms of backoff, not counting the rate limiter.  Errors of requests
+// which weren't retried are returned as they are.
+func (client *Client) issueRequest(description string, request func() error) error {
+	return client.circuitBreaker.IssueRequest(description, func() error {
+		err := util.RetryWithPolicy(context.Background(), hubRetryPolicy, func() error {
+			if err := client.limiter.Wait(context.Background()); err != nil {
+				return errors.Trace(err)
+			}
+			return classifyError(request())
+		})
+		if retryErr, ok := err.(*util.RetryError); ok && retryErr.Attempts == 1 {
+			return retryErr.Err
+		}
+		return err
+	})
+}
+
 // login ignores the circuit breaker, just in case the circuit breaker
 // is closed because the calls were failing due to being unauthenticated.
 // Or maybe TODO we need to distinguish between different types of
@@ -87,6 +160,20 @@ func (client *Client) login() error {
 	return errors.Trace(err)
 }
 
+// loginOutcome distinguishes failing to reach the hub from the hub turning
+// down the login.
+func loginOutcome(err error) api.HubLoginOutcome {
+	if err == nil {
+		return api.HubLoginOutcomeSuccess
+	}
+	switch errors.Cause(err).(type) {
+	case *url.Error, net.Error:
+		return api.HubLoginOutcomeUnreachable
+	default:
+		return api.HubLoginOutcomeAuthFailure
+	}
+}
+
 // FetchScan finds ScanResults by starting from a code location,
 // and following links from there.
 // It returns:
@@ -235,7 +322,7 @@ func (client *Client) fetchScanResultsUsingCodeLocation(codeLocation hubapi.Code
 func (client *Client) listAllProjects() (*hubapi.ProjectList, error) {
 	var list *hubapi.ProjectList
 	var fetchError error
-	err := client.circuitBreaker.IssueRequest("allProjects", func() error {
+	err := client.issueRequest("allProjects", func() error {
 		limit := 2000000
 		list, fetchError = client.rawClient.ListProjects(&hubapi.GetListOptions{Limit: &limit})
 		return fetchError
@@ -250,7 +337,7 @@ func (client *Client) listAllProjects() (*hubapi.ProjectList, error) {
 func (client *Client) listAllCodeLocations() (*hubapi.CodeLocationList, error) {
 	var list *hubapi.CodeLocationList
 	var fetchError error
-	err := client.circuitBreaker.IssueRequest("allCodeLocations", func() error {
+	err := client.issueRequest("allCodeLocations", func() error {
 		limit := 2000000
 		list, fetchError = client.rawClient.ListAllCodeLocations(&hubapi.GetListOptions{Limit: &limit})
 		if fetchError != nil {
@@ -268,7 +355,7 @@ func (client *Client) listAllCodeLocations() (*hubapi.CodeLocationList, error) {
 func (client *Client) listCodeLocations(codeLocationName string) (*hubapi.CodeLocationList, error) {
 	var list *hubapi.CodeLocationList
 	var fetchError error
-	err := client.circuitBreaker.IssueRequest("codeLocations", func() error {
+	err := client.issueRequest("codeLocations", func() error {
 		queryString := fmt.Sprintf("name:%s", codeLocationName)
 		list, fetchError = client.rawClient.ListAllCodeLocations(&hubapi.GetListOptions{Q: &queryString})
 		return fetchError
@@ -283,7 +370,7 @@ func (client *Client) listCodeLocations(codeLocationName string) (*hubapi.CodeLo
 func (client *Client) getProjectVersion(link hubapi.ResourceLink) (*hubapi.ProjectVersion, error) {
 	var pv *hubapi.ProjectVersion
 	var fetchError error
-	err := client.circuitBreaker.IssueRequest("projectVersion", func() error {
+	err := client.issueRequest("projectVersion", func() error {
 		pv, fetchError = client.rawClient.GetProjectVersion(link)
 		return fetchError
 	})
@@ -297,7 +384,7 @@ func (client *Client) getProjectVersion(link hubapi.ResourceLink) (*hubapi.Proje
 func (client *Client) getProject(link hubapi.ResourceLink) (*hubapi.Project, error) {
 	var val *hubapi.Project
 	var fetchError error
-	err := client.circuitBreaker.IssueRequest("project", func() error {
+	err := client.issueRequest("project", func() error {
 		val, fetchError = client.rawClient.GetProject(link)
 		return fetchError
 	})
@@ -311,7 +398,7 @@ func (client *Client) getProject(link hubapi.ResourceLink) (*hubapi.Project, err
 func (client *Client) getProjectVersionRiskProfile(link hubapi.ResourceLink) (*hubapi.ProjectVersionRiskProfile, error) {
 	var val *hubapi.ProjectVersionRiskProfile
 	var fetchError error
-	err := client.circuitBreaker.IssueRequest("projectVersionRiskProfile", func() error {
+	err := client.issueRequest("projectVersionRiskProfile", func() error {
 		val, fetchError = client.rawClient.GetProjectVersionRiskProfile(link)
 		return fetchError
 	})
@@ -325,7 +412,7 @@ func (client *Client) getProjectVersionRiskProfile(link hubapi.ResourceLink) (*h
 func (client *Client) getProjectVersionPolicyStatus(link hubapi.ResourceLink) (*hubapi.ProjectVersionPolicyStatus, error) {
 	var val *hubapi.ProjectVersionPolicyStatus
 	var fetchError error
-	err := client.circuitBreaker.IssueRequest("projectVersionPolicyStatus", func() error {
+	err := client.issueRequest("projectVersionPolicyStatus", func() error {
 		val, fetchError = client.rawClient.GetProjectVersionPolicyStatus(link)
 		return fetchError
 	})
@@ -339,7 +426,7 @@ func (client *Client) getProjectVersionPolicyStatus(link hubapi.ResourceLink) (*
 func (client *Client) listScanSummaries(link hubapi.ResourceLink) (*hubapi.ScanSummaryList, error) {
 	var val *hubapi.ScanSummaryList
 	var fetchError error
-	err := client.circuitBreaker.IssueRequest("scanSummaries", func() error {
+	err := client.issueRequest("scanSummaries", func() error {
 		val, fetchError = client.rawClient.ListScanSummaries(link)
 		return fetchError
 	})
@@ -352,7 +439,7 @@ func (client *Client) listScanSummaries(link hubapi.ResourceLink) (*hubapi.ScanS
 // DeleteProjectVersion ...
 func (client *Client) deleteProjectVersion(projectVersionHRef string) error {
 	var fetchError error
-	err := client.circuitBreaker.IssueRequest("deleteVersion", func() error {
+	err := client.issueRequest("deleteVersion", func() error {
 		fetchError = client.rawClient.DeleteProjectVersion(projectVersionHRef)
 		return fetchError
 	})
@@ -365,7 +452,7 @@ func (client *Client) deleteProjectVersion(projectVersionHRef string) error {
 // DeleteCodeLocation ...
 func (client *Client) deleteCodeLocation(codeLocationHRef string) error {
 	var fetchError error
-	err := client.circuitBreaker.IssueRequest("deleteCodeLocation", func() error {
+	err := client.issueRequest("deleteCodeLocation", func() error {
 		fetchError = client.rawClient.DeleteCodeLocation(codeLocationHRef)
 		return fetchError
 	})
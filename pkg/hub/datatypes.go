@@ -195,6 +195,11 @@ func (rp *RiskProfile) HighRiskVulnerabilityCount() int {
 	return vulnerabilities.HighRiskVulnerabilityCount()
 }
 
+// VulnerabilityCount is the number of vulnerable components with `status`.
+func (rp *RiskProfile) VulnerabilityCount(status RiskProfileStatus) int {
+	return rp.Categories[RiskProfileCategoryVulnerability].StatusCounts[status]
+}
+
 // RiskProfileCategory .....
 type RiskProfileCategory int
 
@@ -270,6 +275,8 @@ const (
 	RiskProfileStatusLow     RiskProfileStatus = iota
 	RiskProfileStatusOK      RiskProfileStatus = iota
 	RiskProfileStatusUnknown RiskProfileStatus = iota
+	// newer hubs split critical risks out of high ones
+	RiskProfileStatusCritical RiskProfileStatus = iota
 )
 
 // String .....
@@ -285,6 +292,8 @@ func (r RiskProfileStatus) String() string {
 		return "OK"
 	case RiskProfileStatusUnknown:
 		return "UNKNOWN"
+	case RiskProfileStatusCritical:
+		return "CRITICAL"
 	default:
 		panic(fmt.Errorf("invalid RiskProfileStatus value: %d", r))
 	}
@@ -438,6 +447,18 @@ func (scan *ScanResults) PolicyViolationCount() int {
 	return scan.PolicyStatus.ViolationCount()
 }
 
+// LastScanTime is when the most recent scan summary was updated, or
+// "" if there are none.
+func (scan *ScanResults) LastScanTime() string {
+	lastScanTime := ""
+	for _, scanSummary := range scan.ScanSummaries {
+		if scanSummary.UpdatedAt > lastScanTime {
+			lastScanTime = scanSummary.UpdatedAt
+		}
+	}
+	return lastScanTime
+}
+
 // OverallStatus .....
 func (scan *ScanResults) OverallStatus() PolicyStatusType {
 	return scan.PolicyStatus.OverallStatus
@@ -23,6 +23,7 @@ package hub
 
 import (
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/blackducksoftware/hub-client-go/hubapi"
@@ -33,59 +34,87 @@ import (
 
 const (
 	maxHubExponentialBackoffDuration = 1 * time.Hour
+	// recentErrorsWindow is how far back errors count towards a hub's health
+	recentErrorsWindow = 15 * time.Minute
+	// maxRetainedErrors is how many of the most recent errors are kept
+	maxRetainedErrors = 1000
+	// fetchTimerJitter spreads out the timers which fetch from the hub, so
+	// that hubs started together don't all get hit at the same moment
+	fetchTimerJitter = 0.2
+	// slowEnqueueThreshold is how long a sender can be blocked on the
+	// action channel before it counts as slow
+	slowEnqueueThreshold = time.Second
+	// resetSourceIdleTimeout is how long the series of a source which asked
+	// for a circuit breaker reset is kept; resets are rare, so it's long
+	resetSourceIdleTimeout = 24 * time.Hour
 )
 
 type clientAction struct {
 	name  string
 	apply func() error
+	// enqueuedAt is when the sender started to enqueue the action
+	enqueuedAt time.Time
 }
 
 // Hub .....
 type Hub struct {
 	client *Client
 	// basic hub info
-	host   string
-	status ClientStatus
+	host         string
+	status       ClientStatus
+	availability *availability
 	// data
 	hasFetchedScans bool
 	scans           map[string]*Scan
-	errors          []error
+	errors          *util.RingBuffer
+	// resetSources caps the sources with their own circuit breaker reset series
+	resetSources *util.CappedLabelSet
+	// health, refreshed after every action, so it can be read without one
+	lastLoginTime *time.Time
+	lastSyncTime  *time.Time
+	health        atomic.Value
 	// timers
-	getMetricsTimer              *util.Timer
-	loginTimer                   *util.Timer
-	refreshScansTimer            *util.Timer
-	fetchAllScansTimer           *util.Timer
-	fetchScansTimer              *util.Timer
-	checkScansForCompletionTimer *util.Timer
+	getMetricsTimer *util.Timer
+	loginTimer      *util.Timer
+	// scanTimers only run while the client is logged in
+	scanTimers *util.TimerGroup
 	// public channels
 	publishUpdatesCh chan Update
 	// channels
 	stop    chan struct{}
 	actions chan *clientAction
+	queue   *util.QueueMonitor
 }
 
 // NewHub returns a new Hub.  It will not be logged in.
 func NewHub(username string, password string, host string, rawClient RawClientInterface, timings *Timings) *Hub {
 	hub := &Hub{
-		client: NewClient(username, password, host, rawClient),
-		host:   host,
-		status: ClientStatusDown,
+		client:       NewClient(username, password, host, rawClient),
+		host:         host,
+		status:       ClientStatusDown,
+		availability: newAvailability(host, ClientStatusDown, time.Now),
 		//
 		hasFetchedScans: false,
 		scans:           map[string]*Scan{},
-		errors:          []error{},
+		errors:          util.NewRingBuffer(maxRetainedErrors),
+		resetSources: util.NewCappedLabelSet(api.DefaultMaxTrackedSources, api.UntrackedSources, resetSourceIdleTimeout, func(source string) {
+			forgetCircuitBreakerResetSource(host, source)
+		}),
 		//
 		publishUpdatesCh: make(chan Update),
 		//
 		stop:    make(chan struct{}),
-		actions: make(chan *clientAction)}
+		actions: make(chan *clientAction),
+		queue:   util.NewQueueMonitor(fmt.Sprintf("hub:%s", host), slowEnqueueThreshold)}
+	hub.updateHealth()
 	// timers
 	hub.getMetricsTimer = hub.startGetMetricsTimer(timings.GetMetricsPause)
-	hub.checkScansForCompletionTimer = hub.startCheckScansForCompletionTimer(timings.ScanCompletionPause)
-	hub.fetchScansTimer = hub.startFetchUnknownScansTimer(timings.FetchUnknownScansPause)
-	hub.fetchAllScansTimer = hub.startFetchAllScansTimer(timings.FetchAllScansPause)
+	hub.scanTimers = util.NewTimerGroup(hub.stop)
+	hub.recordError(hub.scanTimers.Add("checkScansForCompletion", hub.startCheckScansForCompletionTimer(timings.ScanCompletionPause)))
+	hub.recordError(hub.scanTimers.Add("fetchUnknownScans", hub.startFetchUnknownScansTimer(timings.FetchUnknownScansPause)))
+	hub.recordError(hub.scanTimers.Add("fetchAllScans", hub.startFetchAllScansTimer(timings.FetchAllScansPause)))
+	hub.recordError(hub.scanTimers.Add("refreshScans", hub.startRefreshScansTimer(timings.RefreshScanThreshold)))
 	hub.loginTimer = hub.startLoginTimer(timings.LoginPause)
-	hub.refreshScansTimer = hub.startRefreshScansTimer(timings.RefreshScanThreshold)
 	// action processing
 	go func() {
 		for {
@@ -93,13 +122,18 @@ func NewHub(username string, password string, host string, rawClient RawClientIn
 			case <-hub.stop:
 				return
 			case action := <-hub.actions:
-				// TODO what other logging, metrics, etc. would help here?
+				hub.queue.DidDequeue(action.enqueuedAt)
+				// hub_action_duration_seconds covers the event and error
+				// counters, which keep emitting so that dashboards don't break
 				recordEvent(hub.host, action.name)
+				start := time.Now()
 				err := action.apply()
+				recordAction(hub.host, action.name, err, time.Now().Sub(start))
 				if err != nil {
 					log.Errorf("while processing action %s: %s", action.name, err.Error())
 					recordError(hub.host, action.name)
 				}
+				hub.updateHealth()
 			}
 		}
 	}()
@@ -108,6 +142,13 @@ func NewHub(username string, password string, host string, rawClient RawClientIn
 
 // Private methods
 
+// enqueue sends an action to the action loop, blocking until it's picked up.
+func (hub *Hub) enqueue(name string, apply func() error) {
+	action := &clientAction{name: name, apply: apply, enqueuedAt: hub.queue.WillEnqueue()}
+	hub.actions <- action
+	hub.queue.DidEnqueue(action.enqueuedAt)
+}
+
 func (hub *Hub) publish(update Update) {
 	go func() {
 		select {
@@ -120,33 +161,67 @@ func (hub *Hub) publish(update Update) {
 
 func (hub *Hub) getStateMetrics() {
 	ch := make(chan *clientStateMetrics)
-	hub.actions <- &clientAction{"getClientStateMetrics", func() error {
+	hub.enqueue("getClientStateMetrics", func() error {
+		hub.availability.tick()
 		scanStageCounts := map[ScanStage]int{}
 		for _, scan := range hub.scans {
 			scanStageCounts[scan.Stage]++
 		}
 		ch <- &clientStateMetrics{
-			errorsCount:     len(hub.errors),
+			errorsCount:     hub.errors.Len(),
 			scanStageCounts: scanStageCounts,
 		}
 		return nil
-	}}
+	})
 	recordClientState(hub.host, <-ch)
 }
 
 func (hub *Hub) recordError(err error) {
 	if err != nil {
-		hub.errors = append(hub.errors, err)
+		hub.errors.AppendWithTime(err, time.Now())
 	}
-	if len(hub.errors) > 1000 {
-		hub.errors = hub.errors[500:]
+}
+
+// recentErrorsCount counts the retained errors from within recentErrorsWindow.
+func (hub *Hub) recentErrorsCount(now time.Time) int {
+	cutoff := now.Add(-recentErrorsWindow)
+	count := 0
+	for _, entry := range hub.errors.Snapshot() {
+		if !entry.Time.Before(cutoff) {
+			count++
+		}
 	}
+	return count
+}
+
+// healthSnapshot is what's needed to answer a health check.
+type healthSnapshot struct {
+	status              ClientStatus
+	circuitBreakerState CircuitBreakerState
+	hasFetchedScans     bool
+	lastLoginTime       *time.Time
+	lastSyncTime        *time.Time
+	recentErrors        int
+}
+
+// updateHealth must only be called from within the action loop, or before
+// it starts.
+func (hub *Hub) updateHealth() {
+	hub.health.Store(&healthSnapshot{
+		status:              hub.status,
+		circuitBreakerState: hub.client.circuitBreaker.State(),
+		hasFetchedScans:     hub.hasFetchedScans,
+		lastLoginTime:       hub.lastLoginTime,
+		lastSyncTime:        hub.lastSyncTime,
+		recentErrors:        hub.recentErrorsCount(time.Now()),
+	})
 }
 
 func (hub *Hub) apiModel() *api.ModelHub {
-	errors := make([]string, len(hub.errors))
-	for ix, err := range hub.errors {
-		errors[ix] = err.Error()
+	retainedErrors := hub.errors.Values()
+	errors := make([]string, len(retainedErrors))
+	for ix, err := range retainedErrors {
+		errors[ix] = err.(error).Error()
 	}
 	codeLocations := map[string]*api.ModelCodeLocation{}
 	for name, scan := range hub.scans {
@@ -168,6 +243,35 @@ func (hub *Hub) apiModel() *api.ModelHub {
 		CodeLocations:             codeLocations,
 		CircuitBreaker:            hub.client.circuitBreaker.Model(),
 		Host:                      hub.host,
+		Timers:                    hub.timersModel(),
+	}
+}
+
+func (hub *Hub) timersModel() map[string]*api.ModelTimer {
+	timers := map[string]*api.ModelTimer{
+		"getMetrics": apiTimerModel(hub.getMetricsTimer),
+		"login":      apiTimerModel(hub.loginTimer),
+	}
+	for _, name := range hub.scanTimers.Names() {
+		timers[name] = apiTimerModel(hub.scanTimers.Get(name))
+	}
+	return timers
+}
+
+func apiTimerModel(timer *util.Timer) *api.ModelTimer {
+	if timer == nil {
+		return nil
+	}
+	stats := timer.Stats()
+	return &api.ModelTimer{
+		Delay:            *api.NewModelTime(timer.Delay()),
+		Runs:             stats.Runs,
+		SkippedTicks:     stats.SkippedTicks,
+		CoalescedTicks:   stats.CoalescedTicks,
+		Panics:           stats.Panics,
+		LastRunStart:     stats.LastRunStart,
+		LastRunDuration:  *api.NewModelTime(stats.LastRunDuration),
+		LastRunCompleted: stats.LastRunCompleted,
 	}
 }
 
@@ -175,29 +279,59 @@ func (hub *Hub) apiModel() *api.ModelHub {
 
 func (hub *Hub) startRefreshScansTimer(pause time.Duration) *util.Timer {
 	name := fmt.Sprintf("refresh-scans-%s", hub.host)
-	return util.NewTimer(name, pause, hub.stop, func() {
+	return util.NewTimer(name, pause, hub.scanTimers.Done(), func() {
 		// TODO implement
 	})
 }
 
-func (hub *Hub) didLogin(err error) {
-	hub.actions <- &clientAction{"didLogin", func() error {
+// recordTimerErrors records errors from pausing or resuming the scan timers,
+// except for timers having been stopped, which is expected while shutting
+// down.
+func (hub *Hub) recordTimerErrors(err error) {
+	groupErr, ok := err.(*util.TimerGroupError)
+	if !ok {
 		hub.recordError(err)
-		if err != nil && hub.status == ClientStatusUp {
-			hub.status = ClientStatusDown
-			hub.recordError(hub.checkScansForCompletionTimer.Pause())
-			hub.recordError(hub.fetchScansTimer.Pause())
-			hub.recordError(hub.fetchAllScansTimer.Pause())
-			hub.recordError(hub.refreshScansTimer.Pause())
-		} else if err == nil && hub.status == ClientStatusDown {
-			hub.status = ClientStatusUp
-			hub.recordError(hub.checkScansForCompletionTimer.Resume(true))
-			hub.recordError(hub.fetchScansTimer.Resume(true))
-			hub.recordError(hub.fetchAllScansTimer.Resume(true))
-			hub.recordError(hub.refreshScansTimer.Resume(true))
+		return
+	}
+	for name, timerErr := range groupErr.Errors {
+		if timerErr == util.ErrTimerStopped {
+			log.Debugf("ignoring stopped timer %s for hub %s", name, hub.host)
+			continue
 		}
+		hub.recordError(fmt.Errorf("timer %s: %s", name, timerErr.Error()))
+	}
+}
+
+// applyLogin updates the status, and pauses or resumes the timers which need
+// a logged-in client.  It must only be called from within an action.
+func (hub *Hub) applyLogin(err error) {
+	hub.recordError(err)
+	if err == nil {
+		now := time.Now()
+		hub.lastLoginTime = &now
+	}
+	if err != nil && hub.status == ClientStatusUp {
+		hub.setStatus(ClientStatusDown)
+		hub.recordTimerErrors(hub.scanTimers.PauseAll())
+	} else if err == nil && hub.status == ClientStatusDown {
+		hub.setStatus(ClientStatusUp)
+		hub.recordTimerErrors(hub.scanTimers.ResumeAll(true))
+	}
+	// so that an on-demand login's caller sees the new health right away
+	hub.updateHealth()
+}
+
+// setStatus must only be called from within an action.
+func (hub *Hub) setStatus(status ClientStatus) {
+	hub.status = status
+	hub.availability.transition(status)
+}
+
+func (hub *Hub) didLogin(err error) {
+	hub.enqueue("didLogin", func() error {
+		hub.applyLogin(err)
 		return nil
-	}}
+	})
 }
 
 func (hub *Hub) startLoginTimer(pause time.Duration) *util.Timer {
@@ -210,10 +344,12 @@ func (hub *Hub) startLoginTimer(pause time.Duration) *util.Timer {
 }
 
 func (hub *Hub) didFetchScans(cls *hubapi.CodeLocationList, err error) {
-	hub.actions <- &clientAction{"didFetchScans", func() error {
+	hub.enqueue("didFetchScans", func() error {
 		hub.recordError(err)
 		if err == nil {
+			now := time.Now()
 			hub.hasFetchedScans = true
+			hub.lastSyncTime = &now
 			for _, cl := range cls.Items {
 				if _, ok := hub.scans[cl.Name]; !ok {
 					hub.scans[cl.Name] = &Scan{Stage: ScanStageUnknown, ScanResults: nil}
@@ -221,12 +357,12 @@ func (hub *Hub) didFetchScans(cls *hubapi.CodeLocationList, err error) {
 			}
 		}
 		return nil
-	}}
+	})
 }
 
 func (hub *Hub) startFetchAllScansTimer(pause time.Duration) *util.Timer {
 	name := fmt.Sprintf("fetchScans-%s", hub.host)
-	return util.NewTimer(name, pause, hub.stop, func() {
+	return util.NewJitteredTimer(name, pause, fetchTimerJitter, hub.scanTimers.Done(), func() {
 		log.Debugf("starting to fetch all scans")
 		cls, err := hub.client.listAllCodeLocations()
 		hub.didFetchScans(cls, err)
@@ -235,7 +371,7 @@ func (hub *Hub) startFetchAllScansTimer(pause time.Duration) *util.Timer {
 
 func (hub *Hub) getUnknownScans() []string {
 	ch := make(chan []string)
-	hub.actions <- &clientAction{"getUnknownScans", func() error {
+	hub.enqueue("getUnknownScans", func() error {
 		unknownScans := []string{}
 		for name, scan := range hub.scans {
 			if scan.Stage == ScanStageUnknown {
@@ -244,12 +380,12 @@ func (hub *Hub) getUnknownScans() []string {
 		}
 		ch <- unknownScans
 		return nil
-	}}
+	})
 	return <-ch
 }
 
 func (hub *Hub) didFetchScanResults(scanResults *ScanResults) {
-	hub.actions <- &clientAction{"didFetchScanResults", func() error {
+	hub.enqueue("didFetchScanResults", func() error {
 		scan, ok := hub.scans[scanResults.CodeLocationName]
 		if !ok {
 			scan = &Scan{
@@ -271,12 +407,12 @@ func (hub *Hub) didFetchScanResults(scanResults *ScanResults) {
 		update := &DidFindScan{Name: scanResults.CodeLocationName, Results: scanResults}
 		hub.publish(update)
 		return nil
-	}}
+	})
 }
 
 func (hub *Hub) startFetchUnknownScansTimer(pause time.Duration) *util.Timer {
 	name := fmt.Sprintf("fetchUnknownScans-%s", hub.host)
-	return util.NewTimer(name, pause, hub.stop, func() {
+	return util.NewJitteredTimer(name, pause, fetchTimerJitter, hub.scanTimers.Done(), func() {
 		log.Debugf("starting to fetch unknown scans")
 		unknownScans := hub.getUnknownScans()
 		log.Debugf("found %d unknown code locations", len(unknownScans))
@@ -305,7 +441,7 @@ func (hub *Hub) startGetMetricsTimer(pause time.Duration) *util.Timer {
 }
 
 func (hub *Hub) scanDidFinish(scanResults *ScanResults) {
-	hub.actions <- &clientAction{"scanDidFinish", func() error {
+	hub.enqueue("scanDidFinish", func() error {
 		scanName := scanResults.CodeLocationName
 		scan, ok := hub.scans[scanName]
 		if !ok {
@@ -318,12 +454,12 @@ func (hub *Hub) scanDidFinish(scanResults *ScanResults) {
 		update := &DidFinishScan{Name: scanResults.CodeLocationName, Results: scanResults}
 		hub.publish(update)
 		return nil
-	}}
+	})
 }
 
 func (hub *Hub) startCheckScansForCompletionTimer(pause time.Duration) *util.Timer {
 	name := fmt.Sprintf("checkScansForCompletion-%s", hub.host)
-	return util.NewTimer(name, pause, hub.stop, func() {
+	return util.NewJitteredTimer(name, pause, fetchTimerJitter, hub.scanTimers.Done(), func() {
 		var scanNames []string
 		select {
 		case scanNames = <-hub.InProgressScans():
@@ -355,15 +491,15 @@ func (hub *Hub) startCheckScansForCompletionTimer(pause time.Duration) *util.Tim
 
 // StartScanClient ...
 func (hub *Hub) StartScanClient(scanName string) {
-	hub.actions <- &clientAction{"startScanClient", func() error {
+	hub.enqueue("startScanClient", func() error {
 		hub.scans[scanName] = &Scan{Stage: ScanStageScanClient}
 		return nil
-	}}
+	})
 }
 
 // FinishScanClient ...
 func (hub *Hub) FinishScanClient(scanName string, scanErr error) {
-	hub.actions <- &clientAction{"finishScanClient", func() error {
+	hub.enqueue("finishScanClient", func() error {
 		scan, ok := hub.scans[scanName]
 		if !ok {
 			return fmt.Errorf("unable to handle finishScanClient for %s: not found", scanName)
@@ -377,13 +513,13 @@ func (hub *Hub) FinishScanClient(scanName string, scanErr error) {
 			scan.Stage = ScanStageFailure
 		}
 		return nil
-	}}
+	})
 }
 
 // ScansCount ...
 func (hub *Hub) ScansCount() <-chan int {
 	ch := make(chan int)
-	hub.actions <- &clientAction{"getScansCount", func() error {
+	hub.enqueue("getScansCount", func() error {
 		count := 0
 		for _, cl := range hub.scans {
 			if cl.Stage != ScanStageFailure {
@@ -392,14 +528,14 @@ func (hub *Hub) ScansCount() <-chan int {
 		}
 		ch <- count
 		return nil
-	}}
+	})
 	return ch
 }
 
 // InProgressScans ...
 func (hub *Hub) InProgressScans() <-chan []string {
 	ch := make(chan []string)
-	hub.actions <- &clientAction{"getInProgressScans", func() error {
+	hub.enqueue("getInProgressScans", func() error {
 		scans := []string{}
 		for scanName, scan := range hub.scans {
 			if scan.Stage == ScanStageHubScan || scan.Stage == ScanStageScanClient {
@@ -408,21 +544,21 @@ func (hub *Hub) InProgressScans() <-chan []string {
 		}
 		ch <- scans
 		return nil
-	}}
+	})
 	return ch
 }
 
 // ScanResults ...
 func (hub *Hub) ScanResults() <-chan map[string]*Scan {
 	ch := make(chan map[string]*Scan)
-	hub.actions <- &clientAction{"getScanResults", func() error {
+	hub.enqueue("getScanResults", func() error {
 		allScanResults := map[string]*Scan{}
 		for name, scan := range hub.scans {
 			allScanResults[name] = &Scan{Stage: scan.Stage, ScanResults: scan.ScanResults}
 		}
 		ch <- allScanResults
 		return nil
-	}}
+	})
 	return ch
 }
 
@@ -455,22 +591,91 @@ func (hub *Hub) ResetCircuitBreaker() {
 	hub.client.resetCircuitBreaker()
 }
 
+// ResetCircuitBreakerFor resets the circuit breaker on behalf of `source`, and
+// returns its state from before and after the reset.
+func (hub *Hub) ResetCircuitBreakerFor(source string, requestID string) *api.CircuitBreakerReset {
+	ch := make(chan *api.CircuitBreakerReset)
+	hub.enqueue("resetCircuitBreaker", func() error {
+		log.WithField("requestID", requestID).Infof("resetting circuit breaker for hub %s on behalf of %s", hub.host, source)
+		recordCircuitBreakerReset(hub.host, hub.resetSources.Label(source))
+		before := hub.client.circuitBreaker.Model()
+		hub.client.resetCircuitBreaker()
+		ch <- &api.CircuitBreakerReset{Host: hub.host, Before: before, After: hub.client.circuitBreaker.Model()}
+		return nil
+	})
+	return <-ch
+}
+
+// CircuitBreaker ...
+func (hub *Hub) CircuitBreaker() <-chan *api.ModelCircuitBreaker {
+	ch := make(chan *api.ModelCircuitBreaker)
+	hub.enqueue("getCircuitBreaker", func() error {
+		ch <- hub.client.circuitBreaker.Model()
+		return nil
+	})
+	return ch
+}
+
+// Login logs in to the hub right away, instead of waiting for the login timer.
+// A successful login also resets the circuit breaker, so that requests go
+// back to their normal cadence without waiting out the backoff.
+func (hub *Hub) Login() *api.HubLoginResult {
+	err := hub.client.login()
+	ch := make(chan *api.HubLoginResult)
+	hub.enqueue("login", func() error {
+		hub.applyLogin(err)
+		result := &api.HubLoginResult{Host: hub.host, Outcome: loginOutcome(err)}
+		if err == nil {
+			hub.client.resetCircuitBreaker()
+		} else {
+			result.Error = err.Error()
+		}
+		result.Status = hub.status.String()
+		result.CircuitBreaker = hub.client.circuitBreaker.Model()
+		ch <- result
+		return nil
+	})
+	return <-ch
+}
+
 // Model ...
 func (hub *Hub) Model() <-chan *api.ModelHub {
 	ch := make(chan *api.ModelHub)
-	hub.actions <- &clientAction{"getModel", func() error {
+	hub.enqueue("getModel", func() error {
 		ch <- hub.apiModel()
 		return nil
-	}}
+	})
 	return ch
 }
 
+// Health describes the hub's connectivity as of its most recent action.  It
+// doesn't wait on the hub, so it's safe to call from health checks.
+func (hub *Hub) Health() *api.HubHealth {
+	snapshot := hub.health.Load().(*healthSnapshot)
+	now := time.Now()
+	health := &api.HubHealth{
+		Host:                hub.host,
+		Status:              snapshot.status.String(),
+		CircuitBreakerState: snapshot.circuitBreakerState.String(),
+		HasFetchedScans:     snapshot.hasFetchedScans,
+		RecentErrors:        snapshot.recentErrors,
+		RecentErrorsWindow:  api.NewModelTime(recentErrorsWindow),
+	}
+	if snapshot.lastLoginTime != nil {
+		health.TimeSinceLastLogin = api.NewModelTime(now.Sub(*snapshot.lastLoginTime))
+	}
+	if snapshot.lastSyncTime != nil {
+		health.TimeSinceLastSync = api.NewModelTime(now.Sub(*snapshot.lastSyncTime))
+	}
+	return health
+}
+
 // HasFetchedScans ...
 func (hub *Hub) HasFetchedScans() <-chan bool {
 	ch := make(chan bool)
-	hub.actions <- &clientAction{"hasFetchedScans", func() error {
+	hub.enqueue("hasFetchedScans", func() error {
 		ch <- hub.hasFetchedScans
 		return nil
-	}}
+	})
 	return ch
 }
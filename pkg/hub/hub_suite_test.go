@@ -33,5 +33,6 @@ func TestHub(t *testing.T) {
 	RegisterFailHandler(Fail)
 	log.SetLevel(log.DebugLevel)
 	RunClientTests()
+	RunAvailabilityTests()
 	RunSpecs(t, "hub suite")
 }
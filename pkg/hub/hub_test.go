@@ -22,11 +22,17 @@ under the License.
 package hub
 
 import (
+	"context"
 	"fmt"
+	"net/url"
 	"time"
 
+	"github.com/blackducksoftware/perceptor/pkg/api"
+	"github.com/juju/errors"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 func newClient(ignoreEvents bool) (*MockRawClient, *Hub) {
@@ -88,5 +94,171 @@ func RunClientTests() {
 			// Expect(<-client.CodeLocations()).To(Equal(map[string]ScanStage{"c": ScanStageComplete, "abc": ScanStageComplete, "a": ScanStageComplete, "b": ScanStageComplete}))
 			// Expect(<-client.InProgressScans()).To(Equal([]string{}))
 		})
+
+		It("should log in on demand and reset the circuit breaker", func() {
+			rawClient, client := newClient(true)
+			time.Sleep(250 * time.Millisecond)
+
+			rawClient.SetShouldFail(true)
+			result := client.Login()
+			Expect(result.Outcome).To(Equal(api.HubLoginOutcomeAuthFailure))
+			Expect(result.Error).NotTo(Equal(""))
+			Expect(result.Status).To(Equal(ClientStatusDown.String()))
+
+			client.client.circuitBreaker.failure()
+			rawClient.SetShouldFail(false)
+			result = client.Login()
+			Expect(result.Outcome).To(Equal(api.HubLoginOutcomeSuccess))
+			Expect(result.Error).To(Equal(""))
+			Expect(result.Status).To(Equal(ClientStatusUp.String()))
+			Expect(result.CircuitBreaker.State).To(Equal(CircuitBreakerStateEnabled.String()))
+			Expect(result.CircuitBreaker.ConsecutiveFailures).To(Equal(0))
+		})
+
+		It("should reset the circuit breaker, reporting its state before and after", func() {
+			_, client := newClient(true)
+			client.client.circuitBreaker.failure()
+			Expect((<-client.CircuitBreaker()).State).To(Equal(CircuitBreakerStateDisabled.String()))
+
+			reset := client.ResetCircuitBreakerFor("ops-dashboard", "abc123")
+			Expect(reset.Host).To(Equal("host1"))
+			Expect(reset.Before.State).To(Equal(CircuitBreakerStateDisabled.String()))
+			Expect(reset.Before.ConsecutiveFailures).To(Equal(1))
+			Expect(reset.Before.NextCheckTime).NotTo(BeNil())
+			Expect(reset.After.State).To(Equal(CircuitBreakerStateEnabled.String()))
+			Expect(reset.After.ConsecutiveFailures).To(Equal(0))
+			Expect(reset.After.NextCheckTime).To(BeNil())
+		})
+
+		It("should cap the sources labeled in circuit breaker reset metrics", func() {
+			_, client := newClient(true)
+			for i := 0; i < api.DefaultMaxTrackedSources; i++ {
+				client.ResetCircuitBreakerFor(fmt.Sprintf("source-%d", i), "")
+			}
+			resets := func(source string) float64 {
+				metric := &dto.Metric{}
+				Expect(circuitBreakerResets.With(prometheus.Labels{"host": "host1", "source": source}).Write(metric)).To(BeNil())
+				return metric.GetCounter().GetValue()
+			}
+			before := resets(api.UntrackedSources)
+			client.ResetCircuitBreakerFor("one-too-many", "")
+			Expect(resets(api.UntrackedSources)).To(Equal(before + 1))
+			Expect(resets("one-too-many")).To(Equal(float64(0)))
+		})
+
+		It("should report how its timers are running", func() {
+			_, client := newClient(true)
+			time.Sleep(1 * time.Second)
+			model := <-client.Model()
+			Expect(model.Timers).To(HaveKey("fetchAllScans"))
+			fetchAllScans := model.Timers["fetchAllScans"]
+			Expect(fetchAllScans.Delay.Milliseconds).To(Equal(float64(500)))
+			Expect(fetchAllScans.Runs).To(BeNumerically(">=", 1))
+			Expect(fetchAllScans.LastRunStart).NotTo(BeNil())
+			Expect(model.Timers["login"].Runs).To(BeNumerically(">=", 1))
+		})
+
+		It("should report its health without waiting on an action", func() {
+			rawClient, client := newClient(true)
+			time.Sleep(1 * time.Second)
+			health := client.Health()
+			Expect(health.Status).To(Equal(ClientStatusUp.String()))
+			Expect(health.HasFetchedScans).To(BeTrue())
+			Expect(health.TimeSinceLastLogin).NotTo(BeNil())
+			Expect(health.TimeSinceLastSync).NotTo(BeNil())
+			Expect(health.RecentErrors).To(Equal(0))
+
+			rawClient.SetShouldFail(true)
+			client.Login()
+			health = client.Health()
+			Expect(health.Status).To(Equal(ClientStatusDown.String()))
+			Expect(health.RecentErrors).To(BeNumerically(">=", 1))
+		})
+
+		It("should classify login failures", func() {
+			Expect(loginOutcome(nil)).To(Equal(api.HubLoginOutcomeSuccess))
+			Expect(loginOutcome(fmt.Errorf("got a 401 response instead of a 204"))).To(Equal(api.HubLoginOutcomeAuthFailure))
+			unreachable := &url.Error{Op: "Post", URL: "https://hub", Err: fmt.Errorf("connection refused")}
+			Expect(loginOutcome(errors.Trace(unreachable))).To(Equal(api.HubLoginOutcomeUnreachable))
+		})
+
+		It("should time its actions, keeping the action label bounded", func() {
+			_, client := newClient(true)
+			client.Login()
+			ch := make(chan prometheus.Metric, 1000)
+			actionDuration.Collect(ch)
+			close(ch)
+			seen := 0
+			for metric := range ch {
+				m := &dto.Metric{}
+				Expect(metric.Write(m)).To(BeNil())
+				for _, label := range m.GetLabel() {
+					switch label.GetName() {
+					case "action":
+						Expect(actionNames[label.GetValue()] || label.GetValue() == otherActionName).To(BeTrue())
+					case "outcome":
+						Expect([]string{actionOutcomeOK, actionOutcomeError, actionOutcomeTimeout}).To(ContainElement(label.GetValue()))
+					}
+				}
+				seen++
+			}
+			Expect(seen).To(BeNumerically(">=", 1))
+
+			observations := func(action string, outcome string) uint64 {
+				m := &dto.Metric{}
+				Expect(actionDuration.With(prometheus.Labels{"host": "action-host", "action": action, "outcome": outcome}).(prometheus.Histogram).Write(m)).To(BeNil())
+				return m.GetHistogram().GetSampleCount()
+			}
+			recordAction("action-host", "login", nil, time.Millisecond)
+			recordAction("action-host", "login", fmt.Errorf("planned failure"), time.Millisecond)
+			recordAction("action-host", "madeUpAction", errors.Trace(classifyError(&url.Error{Op: "Get", URL: "https://hub", Err: context.DeadlineExceeded})), time.Millisecond)
+			Expect(observations("login", actionOutcomeOK)).To(Equal(uint64(1)))
+			Expect(observations("login", actionOutcomeError)).To(Equal(uint64(1)))
+			Expect(observations(otherActionName, actionOutcomeTimeout)).To(Equal(uint64(1)))
+		})
+
+		It("should classify timeouts and 5xxs as transient", func() {
+			Expect(classifyError(nil)).To(BeNil())
+			timeout := &url.Error{Op: "Get", URL: "https://hub", Err: context.DeadlineExceeded}
+			Expect(errors.Cause(classifyError(errors.Trace(timeout)))).To(Equal(ErrTimeout))
+			unavailable := classifyError(fmt.Errorf("got a 503 response instead of a 200"))
+			Expect(errors.Cause(unavailable)).To(Equal(ErrServerUnavailable))
+			Expect(unavailable.Error()).To(ContainSubstring("got a 503 response"))
+			notFound := fmt.Errorf("got a 404 response instead of a 200")
+			Expect(classifyError(notFound)).To(Equal(notFound))
+		})
+
+		It("should retry transient failures before tripping the circuit breaker", func() {
+			client := NewClient("sysadmin", "password", "host1", NewMockRawClient(false, []string{}))
+			calls := 0
+			err := client.issueRequest("flaky", func() error {
+				calls++
+				if calls < 3 {
+					return fmt.Errorf("got a 502 response instead of a 200")
+				}
+				return nil
+			})
+			Expect(err).To(BeNil())
+			Expect(calls).To(Equal(3))
+			Expect(client.circuitBreaker.IsEnabled()).To(BeTrue())
+
+			calls = 0
+			err = client.issueRequest("missing", func() error {
+				calls++
+				return fmt.Errorf("got a 404 response instead of a 200")
+			})
+			Expect(err).To(MatchError("got a 404 response instead of a 200"))
+			Expect(calls).To(Equal(1))
+			Expect(client.circuitBreaker.IsEnabled()).To(BeFalse())
+		})
+
+		It("should say how many attempts were made at requests which were retried", func() {
+			client := NewClient("sysadmin", "password", "host1", NewMockRawClient(false, []string{}))
+			err := client.issueRequest("down", func() error {
+				return fmt.Errorf("got a 503 response instead of a 200")
+			})
+			Expect(err.Error()).To(HavePrefix("failed after 3 attempts: got a 503 response"))
+			Expect(errors.Cause(err)).To(Equal(ErrServerUnavailable))
+		})
 	})
 }
@@ -29,6 +29,8 @@ import (
 
 func parseHubRiskProfileStatus(hubName string) (RiskProfileStatus, error) {
 	switch hubName {
+	case "CRITICAL":
+		return RiskProfileStatusCritical, nil
 	case "HIGH":
 		return RiskProfileStatusHigh, nil
 	case "MEDIUM":
@@ -25,9 +25,54 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/blackducksoftware/perceptor/pkg/util"
+	"github.com/juju/errors"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+var metricsRegistry = util.NewMetricsRegistry()
+
+func init() {
+	setupMetrics()
+}
+
+// ConfigureMetrics moves the hub package's metrics to the registry and
+// namespace in `config`.  It must be called before anything records metrics.
+func ConfigureMetrics(config *util.MetricsConfig) {
+	metricsRegistry.Configure(config)
+	setupMetrics()
+}
+
+// actionNames are the hub's actions; anything else is recorded as
+// otherActionName, so that the action label stays bounded
+var actionNames = map[string]bool{
+	"didFetchScanResults":   true,
+	"didFetchScans":         true,
+	"didLogin":              true,
+	"finishScanClient":      true,
+	"getCircuitBreaker":     true,
+	"getClientStateMetrics": true,
+	"getInProgressScans":    true,
+	"getModel":              true,
+	"getScanResults":        true,
+	"getScansCount":         true,
+	"getUnknownScans":       true,
+	"hasFetchedScans":       true,
+	"login":                 true,
+	"resetCircuitBreaker":   true,
+	"scanDidFinish":         true,
+	"startScanClient":       true,
+}
+
+const otherActionName = "other"
+
+// Action outcomes
+const (
+	actionOutcomeOK      = "ok"
+	actionOutcomeError   = "error"
+	actionOutcomeTimeout = "timeout"
+)
+
 var hubResponse *prometheus.CounterVec
 var hubData *prometheus.CounterVec
 var hubResponseTime *prometheus.HistogramVec
@@ -37,6 +82,11 @@ var circuitBreakerTransitions *prometheus.CounterVec
 var scanStageGauge *prometheus.GaugeVec
 var eventCounter *prometheus.CounterVec
 var errorCounter *prometheus.CounterVec
+var circuitBreakerResets *prometheus.CounterVec
+var actionDuration *prometheus.HistogramVec
+var hubStatusGauge *prometheus.GaugeVec
+var hubStatusSeconds *prometheus.CounterVec
+var hubStatusTransitions *prometheus.CounterVec
 
 func recordHubResponse(host string, name string, isSuccessful bool) {
 	isSuccessString := fmt.Sprintf("%t", isSuccessful)
@@ -82,6 +132,24 @@ func recordClientState(host string, metrics *clientStateMetrics) {
 	// TODO errors?
 }
 
+func actionOutcome(err error) string {
+	switch {
+	case err == nil:
+		return actionOutcomeOK
+	case errors.Cause(err) == ErrTimeout:
+		return actionOutcomeTimeout
+	default:
+		return actionOutcomeError
+	}
+}
+
+func recordAction(host string, name string, err error, duration time.Duration) {
+	if !actionNames[name] {
+		name = otherActionName
+	}
+	actionDuration.With(prometheus.Labels{"host": host, "action": name, "outcome": actionOutcome(err)}).Observe(duration.Seconds())
+}
+
 func recordEvent(host string, event string) {
 	eventCounter.With(prometheus.Labels{"host": host, "event": event}).Inc()
 }
@@ -90,81 +158,142 @@ func recordError(host string, name string) {
 	errorCounter.With(prometheus.Labels{"host": host, "name": name}).Inc()
 }
 
-func init() {
+func recordCircuitBreakerReset(host string, source string) {
+	circuitBreakerResets.With(prometheus.Labels{"host": host, "source": source}).Inc()
+}
+
+func forgetCircuitBreakerResetSource(host string, source string) {
+	circuitBreakerResets.Delete(prometheus.Labels{"host": host, "source": source})
+}
+
+func recordHubStatus(host string, status ClientStatus) {
+	hubStatusGauge.With(prometheus.Labels{"host": host}).Set(float64(status))
+}
+
+func recordHubStatusDuration(host string, status ClientStatus, duration time.Duration) {
+	hubStatusSeconds.With(prometheus.Labels{"host": host, "status": status.String()}).Add(duration.Seconds())
+}
+
+func recordHubStatusTransition(host string, from ClientStatus, to ClientStatus) {
+	hubStatusTransitions.With(prometheus.Labels{"host": host, "from": from.String(), "to": to.String()}).Inc()
+}
+
+func setupMetrics() {
 	hubResponse = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace:   "perceptor",
+		Namespace:   metricsRegistry.Namespace(),
 		Subsystem:   "core",
 		Name:        "http_hub_requests",
 		Help:        "names and status codes for HTTP requests issued by perceptor to the hub",
 		ConstLabels: map[string]string{},
 	}, []string{"host", "name", "isSuccess"})
-	prometheus.MustRegister(hubResponse)
+	metricsRegistry.Register(hubResponse)
 
 	hubData = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace:   "perceptor",
+		Namespace:   metricsRegistry.Namespace(),
 		Subsystem:   "core",
 		Name:        "hub_data_integrity",
 		Help:        "tracks hub data integrity: whether data fetched from the hub meets Perceptor's expectations",
 		ConstLabels: map[string]string{},
 	}, []string{"host", "name", "okay"})
-	prometheus.MustRegister(hubData)
+	metricsRegistry.Register(hubData)
 
 	hubResponseTime = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "hub_response_time",
 		Help:      "tracks the response times of Hub requests in milliseconds",
 		Buckets:   prometheus.ExponentialBuckets(1, 2, 20),
 	}, []string{"host", "name"})
-	prometheus.MustRegister(hubResponseTime)
+	metricsRegistry.Register(hubResponseTime)
 
 	circuitBreakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "hub_circuit_breaker_state",
 		Help:      "tracks the state of the circuit breaker; 0 = disabled; 1 = enabled; 2 = checking;",
 	}, []string{"host"})
-	prometheus.MustRegister(circuitBreakerState)
+	metricsRegistry.Register(circuitBreakerState)
 
 	hubRequestIsCircuitBreakerEnabled = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace:   "perceptor",
+		Namespace:   metricsRegistry.Namespace(),
 		Subsystem:   "core",
 		Name:        "hub_request_is_circuit_breaker_enabled",
 		Help:        "tracks whether the circuit breaker is enabled or disabled when a Hub http request is issued",
 		ConstLabels: map[string]string{},
 	}, []string{"host", "isEnabled"})
-	prometheus.MustRegister(hubRequestIsCircuitBreakerEnabled)
+	metricsRegistry.Register(hubRequestIsCircuitBreakerEnabled)
 
 	circuitBreakerTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace:   "perceptor",
+		Namespace:   metricsRegistry.Namespace(),
 		Subsystem:   "core",
 		Name:        "hub_circuit_breaker_transitions",
 		Help:        "tracks circuit breaker state transitions",
 		ConstLabels: map[string]string{},
 	}, []string{"host", "from", "to"})
-	prometheus.MustRegister(circuitBreakerTransitions)
+	metricsRegistry.Register(circuitBreakerTransitions)
 
 	scanStageGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "hub_scan_stage_gauge",
 		Help:      "a gauge of scan stages for the hub client",
 	}, []string{"host", "name"})
-	prometheus.MustRegister(scanStageGauge)
+	metricsRegistry.Register(scanStageGauge)
 
 	eventCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "hub_event_counter",
 		Help:      "a counter of interesting events happening to each client",
 	}, []string{"host", "event"})
-	prometheus.MustRegister(eventCounter)
+	metricsRegistry.Register(eventCounter)
 
 	errorCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace: "perceptor",
+		Namespace: metricsRegistry.Namespace(),
 		Subsystem: "core",
 		Name:      "hub_error_counter",
 		Help:      "a counter of errors happening within clients",
 	}, []string{"host", "name"})
-	prometheus.MustRegister(errorCounter)
+	metricsRegistry.Register(errorCounter)
+
+	circuitBreakerResets = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "hub_circuit_breaker_resets",
+		Help:      "a counter of circuit breaker resets requested over the API, by who asked for them; sources beyond the tracked limit are counted as (untracked)",
+	}, []string{"host", "source"})
+	metricsRegistry.Register(circuitBreakerResets)
+
+	actionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "hub_action_duration_seconds",
+		Help:      "how long each hub client action took, in seconds, by outcome",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 20),
+	}, []string{"host", "action", "outcome"})
+	metricsRegistry.Register(actionDuration)
+
+	hubStatusGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "hub_status",
+		Help:      "the current status of each hub client; 0 = error; 1 = up; 2 = down;",
+	}, []string{"host"})
+	metricsRegistry.Register(hubStatusGauge)
+
+	hubStatusSeconds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "hub_status_seconds",
+		Help:      "seconds each hub client has spent in each status",
+	}, []string{"host", "status"})
+	metricsRegistry.Register(hubStatusSeconds)
+
+	hubStatusTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "hub_status_transitions",
+		Help:      "tracks hub client status transitions",
+	}, []string{"host", "from", "to"})
+	metricsRegistry.Register(hubStatusTransitions)
 }
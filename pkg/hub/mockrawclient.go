@@ -25,6 +25,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/blackducksoftware/hub-client-go/hubapi"
@@ -32,7 +33,10 @@ import (
 )
 
 // MockRawClient ...
+// Tests flip ShouldFail while the hub's timers are calling in, so after
+// construction it should only be changed through SetShouldFail.
 type MockRawClient struct {
+	mu            sync.Mutex
 	IsLoggedIn    bool
 	ShouldFail    bool
 	CodeLocations map[string]ScanStage
@@ -51,7 +55,28 @@ func NewMockRawClient(shouldFail bool, initialCodeLocationNames []string) *MockR
 	}
 }
 
+// SetShouldFail ...
+func (mhc *MockRawClient) SetShouldFail(shouldFail bool) {
+	mhc.mu.Lock()
+	defer mhc.mu.Unlock()
+	mhc.ShouldFail = shouldFail
+}
+
+func (mhc *MockRawClient) shouldFail() bool {
+	mhc.mu.Lock()
+	defer mhc.mu.Unlock()
+	return mhc.ShouldFail
+}
+
+func (mhc *MockRawClient) isLoggedIn() bool {
+	mhc.mu.Lock()
+	defer mhc.mu.Unlock()
+	return mhc.IsLoggedIn
+}
+
 func (mhc *MockRawClient) addCodeLocation(name string, stage ScanStage) error {
+	mhc.mu.Lock()
+	defer mhc.mu.Unlock()
 	if _, ok := mhc.CodeLocations[name]; ok {
 		return fmt.Errorf("code location %s already found", name)
 	}
@@ -60,6 +85,8 @@ func (mhc *MockRawClient) addCodeLocation(name string, stage ScanStage) error {
 }
 
 func (mhc *MockRawClient) setCodeLocationStage(name string, stage ScanStage) error {
+	mhc.mu.Lock()
+	defer mhc.mu.Unlock()
 	if _, ok := mhc.CodeLocations[name]; !ok {
 		return fmt.Errorf("code location %s not found", name)
 	}
@@ -69,13 +96,15 @@ func (mhc *MockRawClient) setCodeLocationStage(name string, stage ScanStage) err
 
 // ListAllCodeLocations ...
 func (mhc *MockRawClient) ListAllCodeLocations(options *hubapi.GetListOptions) (*hubapi.CodeLocationList, error) {
-	if !mhc.IsLoggedIn {
+	if !mhc.isLoggedIn() {
 		return nil, fmt.Errorf("not logged in")
 	}
-	if mhc.ShouldFail {
+	if mhc.shouldFail() {
 		return nil, fmt.Errorf("unable to fetch code locations list")
 	}
 	cls := []hubapi.CodeLocation{}
+	mhc.mu.Lock()
+	defer mhc.mu.Unlock()
 	for name := range mhc.CodeLocations {
 		jsonBytes, err := json.Marshal(options)
 		shouldAdd := (options != nil && options.Q != nil && strings.Contains(name, (*options.Q)[5:])) || options == nil || options.Q == nil
@@ -109,7 +138,7 @@ func (mhc *MockRawClient) ListAllCodeLocations(options *hubapi.GetListOptions) (
 
 // CurrentVersion ...
 func (mhc *MockRawClient) CurrentVersion() (*hubapi.CurrentVersion, error) {
-	if mhc.ShouldFail {
+	if mhc.shouldFail() {
 		return nil, fmt.Errorf("unable to fetch current version")
 	}
 	return &hubapi.CurrentVersion{
@@ -120,10 +149,10 @@ func (mhc *MockRawClient) CurrentVersion() (*hubapi.CurrentVersion, error) {
 
 // ListProjects ...
 func (mhc *MockRawClient) ListProjects(options *hubapi.GetListOptions) (*hubapi.ProjectList, error) {
-	if !mhc.IsLoggedIn {
+	if !mhc.isLoggedIn() {
 		return nil, fmt.Errorf("not logged in")
 	}
-	if mhc.ShouldFail {
+	if mhc.shouldFail() {
 		return nil, fmt.Errorf("unable to fetch project list")
 	}
 	return &hubapi.ProjectList{}, nil
@@ -131,10 +160,10 @@ func (mhc *MockRawClient) ListProjects(options *hubapi.GetListOptions) (*hubapi.
 
 // DeleteCodeLocation ...
 func (mhc *MockRawClient) DeleteCodeLocation(scanName string) error {
-	if !mhc.IsLoggedIn {
+	if !mhc.isLoggedIn() {
 		return fmt.Errorf("not logged in")
 	}
-	if mhc.ShouldFail {
+	if mhc.shouldFail() {
 		return fmt.Errorf("unable to delete code location %s", scanName)
 	}
 	return nil
@@ -142,10 +171,10 @@ func (mhc *MockRawClient) DeleteCodeLocation(scanName string) error {
 
 // DeleteProjectVersion ...
 func (mhc *MockRawClient) DeleteProjectVersion(name string) error {
-	if !mhc.IsLoggedIn {
+	if !mhc.isLoggedIn() {
 		return fmt.Errorf("not logged in")
 	}
-	if mhc.ShouldFail {
+	if mhc.shouldFail() {
 		return fmt.Errorf("unable to delete project %s", name)
 	}
 	return nil
@@ -153,10 +182,10 @@ func (mhc *MockRawClient) DeleteProjectVersion(name string) error {
 
 // GetProject ...
 func (mhc *MockRawClient) GetProject(link hubapi.ResourceLink) (*hubapi.Project, error) {
-	if !mhc.IsLoggedIn {
+	if !mhc.isLoggedIn() {
 		return nil, fmt.Errorf("not logged in")
 	}
-	if mhc.ShouldFail {
+	if mhc.shouldFail() {
 		return nil, fmt.Errorf("unable to fetch project")
 	}
 	return &hubapi.Project{}, nil
@@ -164,10 +193,10 @@ func (mhc *MockRawClient) GetProject(link hubapi.ResourceLink) (*hubapi.Project,
 
 // GetProjectVersion ...
 func (mhc *MockRawClient) GetProjectVersion(link hubapi.ResourceLink) (*hubapi.ProjectVersion, error) {
-	if !mhc.IsLoggedIn {
+	if !mhc.isLoggedIn() {
 		return nil, fmt.Errorf("not logged in")
 	}
-	if mhc.ShouldFail {
+	if mhc.shouldFail() {
 		return nil, fmt.Errorf("unable to fetch project version")
 	}
 	return &hubapi.ProjectVersion{
@@ -189,10 +218,10 @@ func (mhc *MockRawClient) GetProjectVersion(link hubapi.ResourceLink) (*hubapi.P
 
 // ListScanSummaries ...
 func (mhc *MockRawClient) ListScanSummaries(link hubapi.ResourceLink) (*hubapi.ScanSummaryList, error) {
-	if !mhc.IsLoggedIn {
+	if !mhc.isLoggedIn() {
 		return nil, fmt.Errorf("not logged in")
 	}
-	if mhc.ShouldFail {
+	if mhc.shouldFail() {
 		return nil, fmt.Errorf("unable to fetch scan summary list")
 	}
 	scanSummaries := []hubapi.ScanSummary{
@@ -212,6 +241,8 @@ func (mhc *MockRawClient) ListScanSummaries(link hubapi.ResourceLink) (*hubapi.S
 
 // Login ...
 func (mhc *MockRawClient) Login(username string, password string) error {
+	mhc.mu.Lock()
+	defer mhc.mu.Unlock()
 	if mhc.ShouldFail {
 		mhc.IsLoggedIn = false
 		return fmt.Errorf("unable to login")
@@ -225,10 +256,10 @@ func (mhc *MockRawClient) SetTimeout(timeout time.Duration) {}
 
 // GetProjectVersionRiskProfile ...
 func (mhc *MockRawClient) GetProjectVersionRiskProfile(link hubapi.ResourceLink) (*hubapi.ProjectVersionRiskProfile, error) {
-	if !mhc.IsLoggedIn {
+	if !mhc.isLoggedIn() {
 		return nil, fmt.Errorf("not logged in")
 	}
-	if mhc.ShouldFail {
+	if mhc.shouldFail() {
 		return nil, fmt.Errorf("unable to fetch project version risk profile")
 	}
 	return &hubapi.ProjectVersionRiskProfile{}, nil
@@ -236,10 +267,10 @@ func (mhc *MockRawClient) GetProjectVersionRiskProfile(link hubapi.ResourceLink)
 
 // GetProjectVersionPolicyStatus ...
 func (mhc *MockRawClient) GetProjectVersionPolicyStatus(link hubapi.ResourceLink) (*hubapi.ProjectVersionPolicyStatus, error) {
-	if !mhc.IsLoggedIn {
+	if !mhc.isLoggedIn() {
 		return nil, fmt.Errorf("not logged in")
 	}
-	if mhc.ShouldFail {
+	if mhc.shouldFail() {
 		return nil, fmt.Errorf("unable to fetch project version policy status")
 	}
 	return &hubapi.ProjectVersionPolicyStatus{
@@ -0,0 +1,102 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff computes exponentially growing waits: `initial`, then multiplied
+// by `multiplier` after each attempt, capped at `max`.  With full jitter,
+// each wait is instead picked uniformly between zero and that value.
+// It isn't safe for concurrent use.
+type Backoff struct {
+	initial    time.Duration
+	max        time.Duration
+	multiplier float64
+	fullJitter bool
+	attempts   int
+	random     func() float64
+}
+
+// NewBackoff .....
+func NewBackoff(initial time.Duration, max time.Duration, multiplier float64, fullJitter bool) *Backoff {
+	if initial <= 0 || max < initial {
+		panic(fmt.Errorf("invalid backoff durations: need 0 < initial <= max, got %s and %s", initial, max))
+	}
+	if multiplier < 1 {
+		panic(fmt.Errorf("invalid backoff multiplier: must be at least 1, was %f", multiplier))
+	}
+	return &Backoff{
+		initial:    initial,
+		max:        max,
+		multiplier: multiplier,
+		fullJitter: fullJitter,
+		attempts:   0,
+		random:     rand.Float64,
+	}
+}
+
+// Next returns how long to wait after another failed attempt.
+func (b *Backoff) Next() time.Duration {
+	wait := b.ceiling(b.attempts)
+	b.attempts++
+	if b.fullJitter {
+		return time.Duration(b.random() * float64(wait))
+	}
+	return wait
+}
+
+// Reset goes back to the initial wait, after a success.
+func (b *Backoff) Reset() {
+	b.attempts = 0
+}
+
+// Attempts is the number of waits handed out since the last reset.
+func (b *Backoff) Attempts() int {
+	return b.attempts
+}
+
+// ceiling is the wait after `attempts` earlier ones, before any jitter.
+func (b *Backoff) ceiling(attempts int) time.Duration {
+	wait := float64(b.initial) * math.Pow(b.multiplier, float64(attempts))
+	if wait >= float64(b.max) {
+		return b.max
+	}
+	return time.Duration(wait)
+}
+
+// Retry calls `fn` up to `attempts` times, waiting according to `backoff`
+// between calls.  It stops early, returning the error, once `fn` succeeds,
+// `isRetryable` says its error isn't worth retrying, or `ctx` is done.
+// A nil `isRetryable` retries every error.
+func Retry(ctx context.Context, backoff *Backoff, attempts int, fn func() error, isRetryable func(error) bool) error {
+	err := RetryWithPolicy(ctx, &RetryPolicy{Backoff: backoff, MaxAttempts: attempts, IsRetryable: isRetryable}, fn)
+	if retryErr, ok := err.(*RetryError); ok {
+		return retryErr.Err
+	}
+	return err
+}
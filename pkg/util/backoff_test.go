@@ -0,0 +1,136 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Backoff", func() {
+	It("grows by the multiplier up to the max", func() {
+		backoff := NewBackoff(time.Second, 10*time.Second, 2, false)
+		waits := []time.Duration{}
+		for i := 0; i < 6; i++ {
+			waits = append(waits, backoff.Next())
+		}
+		Expect(waits).To(Equal([]time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}))
+		Expect(backoff.Attempts()).To(Equal(6))
+	})
+
+	It("supports fractional multipliers", func() {
+		backoff := NewBackoff(time.Second, time.Minute, 1.5, false)
+		Expect(backoff.Next()).To(Equal(1 * time.Second))
+		Expect(backoff.Next()).To(Equal(1500 * time.Millisecond))
+		Expect(backoff.Next()).To(Equal(2250 * time.Millisecond))
+	})
+
+	It("doesn't overflow after many attempts", func() {
+		backoff := NewBackoff(time.Second, time.Hour, 2, false)
+		for i := 0; i < 500; i++ {
+			backoff.Next()
+		}
+		Expect(backoff.Next()).To(Equal(time.Hour))
+	})
+
+	It("starts over after a reset", func() {
+		backoff := NewBackoff(time.Second, time.Minute, 2, false)
+		backoff.Next()
+		backoff.Next()
+		backoff.Reset()
+		Expect(backoff.Attempts()).To(Equal(0))
+		Expect(backoff.Next()).To(Equal(time.Second))
+	})
+
+	It("keeps fully jittered waits between zero and the ceiling", func() {
+		backoff := NewBackoff(time.Second, 10*time.Second, 2, true)
+		backoff.random = rand.New(rand.NewSource(1)).Float64
+		distinct := map[time.Duration]bool{}
+		for i := 0; i < 50; i++ {
+			ceiling := backoff.ceiling(backoff.Attempts())
+			wait := backoff.Next()
+			Expect(wait).To(BeNumerically(">=", 0))
+			Expect(wait).To(BeNumerically("<=", ceiling))
+			distinct[wait] = true
+		}
+		Expect(len(distinct)).To(BeNumerically(">", 1))
+	})
+
+	It("rejects invalid parameters", func() {
+		Expect(func() { NewBackoff(0, time.Second, 2, false) }).To(Panic())
+		Expect(func() { NewBackoff(time.Minute, time.Second, 2, false) }).To(Panic())
+		Expect(func() { NewBackoff(time.Second, time.Minute, 0.5, false) }).To(Panic())
+	})
+})
+
+var _ = Describe("Retry", func() {
+	It("stops as soon as the function succeeds", func() {
+		calls := 0
+		err := Retry(context.Background(), NewBackoff(time.Millisecond, time.Millisecond, 1, false), 5, func() error {
+			calls++
+			if calls < 3 {
+				return fmt.Errorf("planned failure")
+			}
+			return nil
+		}, nil)
+		Expect(err).To(BeNil())
+		Expect(calls).To(Equal(3))
+	})
+
+	It("returns the last error once the attempts run out", func() {
+		calls := 0
+		err := Retry(context.Background(), NewBackoff(time.Millisecond, time.Millisecond, 1, false), 4, func() error {
+			calls++
+			return fmt.Errorf("failure %d", calls)
+		}, nil)
+		Expect(err).To(MatchError("failure 4"))
+		Expect(calls).To(Equal(4))
+	})
+
+	It("doesn't retry non-retryable errors", func() {
+		calls := 0
+		fatal := fmt.Errorf("fatal")
+		err := Retry(context.Background(), NewBackoff(time.Millisecond, time.Millisecond, 1, false), 4, func() error {
+			calls++
+			return fatal
+		}, func(err error) bool { return err != fatal })
+		Expect(err).To(Equal(fatal))
+		Expect(calls).To(Equal(1))
+	})
+
+	It("stops waiting once the context is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		err := Retry(ctx, NewBackoff(time.Hour, time.Hour, 1, false), 4, func() error {
+			calls++
+			cancel()
+			return fmt.Errorf("planned failure")
+		}, nil)
+		Expect(err).To(Equal(context.Canceled))
+		Expect(calls).To(Equal(1))
+	})
+})
@@ -0,0 +1,91 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// CappedLabelSet bounds the values of a metric label which clients choose,
+// such as their self-reported names.  Once `maxValues` values are tracked,
+// new ones are replaced by `overflow`.  Values which haven't been labeled for
+// `idleTimeout` are dropped, and passed to `forget` so that their series can
+// be deleted.  It's safe for concurrent use.
+type CappedLabelSet struct {
+	mutex       sync.Mutex
+	maxValues   int
+	overflow    string
+	idleTimeout time.Duration
+	forget      func(value string)
+	lastSeen    map[string]time.Time
+	lastSweep   time.Time
+	now         func() time.Time
+}
+
+// NewCappedLabelSet .....
+func NewCappedLabelSet(maxValues int, overflow string, idleTimeout time.Duration, forget func(value string)) *CappedLabelSet {
+	return NewCappedLabelSetWithClock(maxValues, overflow, idleTimeout, forget, time.Now)
+}
+
+// NewCappedLabelSetWithClock is NewCappedLabelSet, reading the time from `now`.
+func NewCappedLabelSetWithClock(maxValues int, overflow string, idleTimeout time.Duration, forget func(value string), now func() time.Time) *CappedLabelSet {
+	return &CappedLabelSet{
+		maxValues:   maxValues,
+		overflow:    overflow,
+		idleTimeout: idleTimeout,
+		forget:      forget,
+		lastSeen:    map[string]time.Time{},
+		lastSweep:   now(),
+		now:         now,
+	}
+}
+
+// Label returns the label value to use for `value`.
+func (cls *CappedLabelSet) Label(value string) string {
+	cls.mutex.Lock()
+	defer cls.mutex.Unlock()
+	now := cls.now()
+	if now.Sub(cls.lastSweep) > cls.idleTimeout {
+		for tracked, lastSeen := range cls.lastSeen {
+			if now.Sub(lastSeen) > cls.idleTimeout {
+				delete(cls.lastSeen, tracked)
+				if cls.forget != nil {
+					cls.forget(tracked)
+				}
+			}
+		}
+		cls.lastSweep = now
+	}
+	if _, ok := cls.lastSeen[value]; !ok && len(cls.lastSeen) >= cls.maxValues {
+		value = cls.overflow
+	}
+	cls.lastSeen[value] = now
+	return value
+}
+
+// Len returns the number of values currently being tracked.
+func (cls *CappedLabelSet) Len() int {
+	cls.mutex.Lock()
+	defer cls.mutex.Unlock()
+	return len(cls.lastSeen)
+}
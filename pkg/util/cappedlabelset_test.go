@@ -0,0 +1,54 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Capped label set", func() {
+	It("should replace values beyond the cap with the overflow value", func() {
+		labels := NewCappedLabelSet(2, "(other)", time.Hour, nil)
+		Expect(labels.Label("a")).To(Equal("a"))
+		Expect(labels.Label("b")).To(Equal("b"))
+		Expect(labels.Label("c")).To(Equal("(other)"))
+		Expect(labels.Label("a")).To(Equal("a"))
+	})
+
+	It("should forget idle values, making room for new ones", func() {
+		now := time.Now()
+		forgotten := []string{}
+		labels := NewCappedLabelSetWithClock(2, "(other)", time.Hour, func(value string) {
+			forgotten = append(forgotten, value)
+		}, func() time.Time { return now })
+		labels.Label("a")
+		now = now.Add(30 * time.Minute)
+		labels.Label("b")
+		now = now.Add(45 * time.Minute)
+		Expect(labels.Label("c")).To(Equal("c"))
+		Expect(forgotten).To(Equal([]string{"a"}))
+		Expect(labels.Len()).To(Equal(2))
+	})
+})
@@ -0,0 +1,108 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"sync"
+)
+
+// LockedPriorityQueue wraps a PriorityQueue with a mutex, for when it's
+// shared between goroutines instead of being owned by just one.
+type LockedPriorityQueue struct {
+	mutex sync.Mutex
+	pq    *PriorityQueue
+}
+
+// NewLockedPriorityQueue .....
+func NewLockedPriorityQueue() *LockedPriorityQueue {
+	return &LockedPriorityQueue{pq: NewPriorityQueue()}
+}
+
+// Add adds an element.  'key' must be unique.
+func (lpq *LockedPriorityQueue) Add(key string, priority int, value interface{}) error {
+	lpq.mutex.Lock()
+	defer lpq.mutex.Unlock()
+	return lpq.pq.Add(key, priority, value)
+}
+
+// Peek returns the highest priority item, or nil if empty.
+func (lpq *LockedPriorityQueue) Peek() interface{} {
+	lpq.mutex.Lock()
+	defer lpq.mutex.Unlock()
+	return lpq.pq.Peek()
+}
+
+// Pop removes the highest priority element, returning an error if empty.
+func (lpq *LockedPriorityQueue) Pop() (interface{}, error) {
+	lpq.mutex.Lock()
+	defer lpq.mutex.Unlock()
+	return lpq.pq.Pop()
+}
+
+// Size returns the number of elements in the queue.
+func (lpq *LockedPriorityQueue) Size() int {
+	lpq.mutex.Lock()
+	defer lpq.mutex.Unlock()
+	return lpq.pq.Size()
+}
+
+// IsEmpty .....
+func (lpq *LockedPriorityQueue) IsEmpty() bool {
+	lpq.mutex.Lock()
+	defer lpq.mutex.Unlock()
+	return lpq.pq.IsEmpty()
+}
+
+// Set changes the priority of the item for 'key', returning an error if it can't be found.
+func (lpq *LockedPriorityQueue) Set(key string, priority int) error {
+	lpq.mutex.Lock()
+	defer lpq.mutex.Unlock()
+	return lpq.pq.Set(key, priority)
+}
+
+// HasKey returns whether the priority queue has the key.
+func (lpq *LockedPriorityQueue) HasKey(key string) bool {
+	lpq.mutex.Lock()
+	defer lpq.mutex.Unlock()
+	return lpq.pq.HasKey(key)
+}
+
+// Rank returns the number of items which will be popped before the item for 'key'.
+func (lpq *LockedPriorityQueue) Rank(key string) (int, error) {
+	lpq.mutex.Lock()
+	defer lpq.mutex.Unlock()
+	return lpq.pq.Rank(key)
+}
+
+// Remove removes the value associated with the key, returning an error if it can't be found.
+func (lpq *LockedPriorityQueue) Remove(key string) (interface{}, error) {
+	lpq.mutex.Lock()
+	defer lpq.mutex.Unlock()
+	return lpq.pq.Remove(key)
+}
+
+// Values should only be used for debugging.
+func (lpq *LockedPriorityQueue) Values() []interface{} {
+	lpq.mutex.Lock()
+	defer lpq.mutex.Unlock()
+	return lpq.pq.Values()
+}
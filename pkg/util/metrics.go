@@ -0,0 +1,111 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metricsRegistry = NewMetricsRegistry()
+
+func init() {
+	setupMetrics()
+}
+
+// ConfigureMetrics moves the util package's metrics to the registry and
+// namespace in `config`.  It must be called before anything records metrics.
+func ConfigureMetrics(config *MetricsConfig) {
+	metricsRegistry.Configure(config)
+	setupMetrics()
+}
+
+var timerRunDuration *prometheus.HistogramVec
+var timerPanics *prometheus.CounterVec
+var actionQueueDepth *prometheus.GaugeVec
+var actionQueueWait *prometheus.HistogramVec
+var slowEnqueues *prometheus.CounterVec
+
+func recordTimerRunDuration(name string, duration time.Duration) {
+	timerRunDuration.With(prometheus.Labels{"name": name}).Observe(duration.Seconds())
+}
+
+func recordTimerPanic(name string) {
+	timerPanics.With(prometheus.Labels{"name": name}).Inc()
+}
+
+func recordActionQueueDepth(loop string, depth int64) {
+	actionQueueDepth.With(prometheus.Labels{"loop": loop}).Set(float64(depth))
+}
+
+func recordActionQueueWait(loop string, duration time.Duration) {
+	actionQueueWait.With(prometheus.Labels{"loop": loop}).Observe(duration.Seconds())
+}
+
+func recordSlowEnqueue(loop string) {
+	slowEnqueues.With(prometheus.Labels{"loop": loop}).Inc()
+}
+
+func setupMetrics() {
+	timerRunDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "timer_run_duration_seconds",
+		Help:      "how long each run of a timer's action took, in seconds",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 20),
+	}, []string{"name"})
+	metricsRegistry.Register(timerRunDuration)
+
+	timerPanics = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "timer_panics",
+		Help:      "panics recovered from while running a timer's action",
+	}, []string{"name"})
+	metricsRegistry.Register(timerPanics)
+
+	actionQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "action_queue_depth",
+		Help:      "actions waiting for a serialized loop, including senders blocked on its channel",
+	}, []string{"loop"})
+	metricsRegistry.Register(actionQueueDepth)
+
+	actionQueueWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "action_queue_wait_seconds",
+		Help:      "time from enqueueing an action to its loop starting on it, in seconds",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 24),
+	}, []string{"loop"})
+	metricsRegistry.Register(actionQueueWait)
+
+	slowEnqueues = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsRegistry.Namespace(),
+		Subsystem: "core",
+		Name:      "action_queue_slow_enqueues",
+		Help:      "enqueues which were blocked for longer than their loop's threshold",
+	}, []string{"loop"})
+	metricsRegistry.Register(slowEnqueues)
+}
@@ -0,0 +1,119 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultMetricsNamespace prefixes the names of all of perceptor's metrics,
+// unless configured otherwise.
+const DefaultMetricsNamespace = "perceptor"
+
+// MetricsConfig says which registry a package's metrics are registered
+// with, and the namespace their names start with.  Empty fields take the
+// defaults: the global registry and DefaultMetricsNamespace.
+type MetricsConfig struct {
+	Registerer prometheus.Registerer
+	Namespace  string
+}
+
+func (config *MetricsConfig) withDefaults() *MetricsConfig {
+	withDefaults := &MetricsConfig{Registerer: prometheus.DefaultRegisterer, Namespace: DefaultMetricsNamespace}
+	if config == nil {
+		return withDefaults
+	}
+	if config.Registerer != nil {
+		withDefaults.Registerer = config.Registerer
+	}
+	if config.Namespace != "" {
+		withDefaults.Namespace = config.Namespace
+	}
+	return withDefaults
+}
+
+// MetricsRegistry keeps track of the collectors a package has registered,
+// so that they can be moved to another registry.  It's safe for concurrent
+// use.
+type MetricsRegistry struct {
+	mutex      sync.Mutex
+	config     *MetricsConfig
+	collectors []prometheus.Collector
+}
+
+// NewMetricsRegistry starts out with the default configuration.
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{config: (*MetricsConfig)(nil).withDefaults()}
+}
+
+// Namespace is what the package's metric names should start with.
+func (registry *MetricsRegistry) Namespace() string {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	return registry.config.Namespace
+}
+
+// Gatherer is the configured registerer, if it can also gather metrics, and
+// the global gatherer otherwise.
+func (registry *MetricsRegistry) Gatherer() prometheus.Gatherer {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if gatherer, ok := registry.config.Registerer.(prometheus.Gatherer); ok {
+		return gatherer
+	}
+	return prometheus.DefaultGatherer
+}
+
+// Register registers the collectors.  Unlike prometheus.MustRegister, it
+// doesn't panic if an equivalent collector is already registered -- for
+// instance by a program embedding perceptor's packages -- but logs it, and
+// leaves the existing one in place.
+func (registry *MetricsRegistry) Register(collectors ...prometheus.Collector) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	for _, collector := range collectors {
+		err := registry.config.Registerer.Register(collector)
+		switch err.(type) {
+		case nil:
+			registry.collectors = append(registry.collectors, collector)
+		case prometheus.AlreadyRegisteredError:
+			log.Warnf("not registering metrics collector: an equivalent one is already registered")
+		default:
+			panic(err)
+		}
+	}
+}
+
+// Configure unregisters every collector, and switches to `config`.  The
+// package should then create and register its collectors again.
+func (registry *MetricsRegistry) Configure(config *MetricsConfig) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	for _, collector := range registry.collectors {
+		registry.config.Registerer.Unregister(collector)
+	}
+	registry.collectors = nil
+	registry.config = config.withDefaults()
+}
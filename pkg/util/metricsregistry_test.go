@@ -0,0 +1,87 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func gatheredNames(gatherer prometheus.Gatherer) []string {
+	families, err := gatherer.Gather()
+	Expect(err).To(BeNil())
+	names := []string{}
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+	return names
+}
+
+var _ = Describe("Metrics registry", func() {
+	newCounter := func(registry *MetricsRegistry) prometheus.Counter {
+		counter := prometheus.NewCounter(prometheus.CounterOpts{Namespace: registry.Namespace(), Subsystem: "test", Name: "things", Help: "things"})
+		registry.Register(counter)
+		counter.Inc()
+		return counter
+	}
+
+	It("defaults to the global registry and namespace", func() {
+		registry := NewMetricsRegistry()
+		Expect(registry.Namespace()).To(Equal(DefaultMetricsNamespace))
+		Expect(registry.Gatherer()).To(Equal(prometheus.DefaultGatherer))
+	})
+
+	It("moves its collectors to another registry and namespace", func() {
+		first := prometheus.NewRegistry()
+		registry := NewMetricsRegistry()
+		registry.Configure(&MetricsConfig{Registerer: first})
+		newCounter(registry)
+		Expect(gatheredNames(first)).To(Equal([]string{"perceptor_test_things"}))
+
+		second := prometheus.NewRegistry()
+		registry.Configure(&MetricsConfig{Registerer: second, Namespace: "embedded"})
+		newCounter(registry)
+		Expect(gatheredNames(first)).To(BeEmpty())
+		Expect(gatheredNames(second)).To(Equal([]string{"embedded_test_things"}))
+		Expect(registry.Gatherer()).To(Equal(second))
+	})
+
+	It("survives being set up repeatedly", func() {
+		custom := prometheus.NewRegistry()
+		registry := NewMetricsRegistry()
+		for i := 0; i < 3; i++ {
+			registry.Configure(&MetricsConfig{Registerer: custom})
+			newCounter(registry)
+		}
+		Expect(gatheredNames(custom)).To(Equal([]string{"perceptor_test_things"}))
+	})
+
+	It("doesn't panic when a collector is already registered elsewhere", func() {
+		custom := prometheus.NewRegistry()
+		Expect(custom.Register(prometheus.NewCounter(prometheus.CounterOpts{Namespace: "perceptor", Subsystem: "test", Name: "things", Help: "things"}))).To(BeNil())
+		registry := NewMetricsRegistry()
+		registry.Configure(&MetricsConfig{Registerer: custom})
+		Expect(func() { newCounter(registry) }).NotTo(Panic())
+		Expect(gatheredNames(custom)).To(Equal([]string{"perceptor_test_things"}))
+	})
+})
@@ -22,6 +22,7 @@ under the License.
 package util
 
 import (
+	"container/heap"
 	"encoding/json"
 	"fmt"
 )
@@ -30,13 +31,26 @@ type node struct {
 	key      string
 	priority int
 	value    interface{}
+	// seq orders items of equal priority by when they were added
+	seq uint64
+}
+
+// isBefore returns whether `n` should be popped before `other`.
+func (n *node) isBefore(other *node) bool {
+	if n.priority != other.priority {
+		return n.priority > other.priority
+	}
+	return n.seq < other.seq
 }
 
 // PriorityQueue uses a max heap, and provides efficient changing of priority.
+// Items of equal priority are popped in the order they were added.
+// It isn't safe for concurrent use; see LockedPriorityQueue for that.
 type PriorityQueue struct {
 	items      []*node
 	size       int
 	keyToIndex map[string]int
+	nextSeq    uint64
 }
 
 // NewPriorityQueue .....
@@ -104,7 +118,8 @@ func (pq *PriorityQueue) Add(key string, priority int, value interface{}) error
 		return fmt.Errorf("cannot add key %s: key already in map", key)
 	}
 	pq.resizeIfNecessary()
-	pq.items[pq.size] = &node{key: key, priority: priority, value: value}
+	pq.items[pq.size] = &node{key: key, priority: priority, value: value, seq: pq.nextSeq}
+	pq.nextSeq++
 	pq.keyToIndex[key] = pq.size
 	pq.siftUp(pq.size)
 	pq.size++
@@ -140,6 +155,47 @@ func (pq *PriorityQueue) Pop() (interface{}, error) {
 	return item.value, nil
 }
 
+// Walk calls 'visit' with each value in the order they would be popped,
+// without modifying the queue, until 'visit' returns false.  Stopping after
+// the first k values costs O(k log k).
+func (pq *PriorityQueue) Walk(visit func(value interface{}) bool) {
+	if pq.size == 0 {
+		return
+	}
+	// no child is popped before its parent, so the next value is always
+	// among the children of the values already visited
+	frontier := &walkFrontier{pq: pq, indices: []int{0}}
+	for frontier.Len() > 0 {
+		index := heap.Pop(frontier).(int)
+		if !visit(pq.items[index].value) {
+			return
+		}
+		for _, child := range []int{leftChild(index), rightChild(index)} {
+			if child < pq.size {
+				heap.Push(frontier, child)
+			}
+		}
+	}
+}
+
+// walkFrontier is a heap of indices into a PriorityQueue's items.
+type walkFrontier struct {
+	pq      *PriorityQueue
+	indices []int
+}
+
+func (f *walkFrontier) Len() int { return len(f.indices) }
+func (f *walkFrontier) Less(i, j int) bool {
+	return f.pq.items[f.indices[i]].isBefore(f.pq.items[f.indices[j]])
+}
+func (f *walkFrontier) Swap(i, j int)      { f.indices[i], f.indices[j] = f.indices[j], f.indices[i] }
+func (f *walkFrontier) Push(x interface{}) { f.indices = append(f.indices, x.(int)) }
+func (f *walkFrontier) Pop() interface{} {
+	last := f.indices[len(f.indices)-1]
+	f.indices = f.indices[:len(f.indices)-1]
+	return last
+}
+
 // Size returns the number of elements in the queue.
 func (pq *PriorityQueue) Size() int {
 	return pq.size
@@ -151,6 +207,7 @@ func (pq *PriorityQueue) IsEmpty() bool {
 }
 
 // Set changes the priority of 'value' if it can be found, and returns an error if not.
+// The item keeps its place behind items of the same priority which were added before it.
 func (pq *PriorityQueue) Set(key string, priority int) error {
 	index, ok := pq.keyToIndex[key]
 	if !ok {
@@ -169,6 +226,23 @@ func (pq *PriorityQueue) HasKey(key string) bool {
 	return ok
 }
 
+// Rank returns the number of items which will be popped before the item for
+// 'key', returning an error if the key can't be found.
+func (pq *PriorityQueue) Rank(key string) (int, error) {
+	index, ok := pq.keyToIndex[key]
+	if !ok {
+		return 0, fmt.Errorf("cannot find rank of key %s, key not found", key)
+	}
+	item := pq.items[index]
+	rank := 0
+	for i := 0; i < pq.size; i++ {
+		if pq.items[i].isBefore(item) {
+			rank++
+		}
+	}
+	return rank, nil
+}
+
 // Remove removes the value associated with the key from the priority queue,
 // returning an error if it can't be found.
 func (pq *PriorityQueue) Remove(key string) (interface{}, error) {
@@ -208,7 +282,7 @@ func (pq *PriorityQueue) CheckValidity() []string {
 		}
 		curr := pq.items[i]
 		left := pq.items[lc]
-		if left.priority > curr.priority {
+		if left.isBefore(curr) {
 			errors = append(errors, fmt.Sprintf("parent %d(%d) has lower priority than left child %d(%d)", i, curr.priority, lc, left.priority))
 		}
 		rc := rightChild(i)
@@ -216,7 +290,7 @@ func (pq *PriorityQueue) CheckValidity() []string {
 			break
 		}
 		right := pq.items[rc]
-		if right.priority > curr.priority {
+		if right.isBefore(curr) {
 			errors = append(errors, fmt.Sprintf("parent %d(%d) has lower priority than right child %d(%d)", i, curr.priority, rc, right.priority))
 		}
 	}
@@ -264,14 +338,14 @@ func (pq *PriorityQueue) siftDown(index int) {
 		}
 		p := pq.items[ip]
 		lc := pq.items[ilc]
-		if p.priority < lc.priority {
+		if lc.isBefore(p) {
 			inext = ilc
 		}
 
 		irc := rightChild(ip)
 		if irc < pq.size {
 			rc := pq.items[irc]
-			if pq.items[inext].priority < rc.priority {
+			if rc.isBefore(pq.items[inext]) {
 				inext = irc
 			}
 		}
@@ -291,7 +365,7 @@ func (pq *PriorityQueue) siftUp(index int) {
 		}
 		p := pq.items[ip]
 		c := pq.items[ic]
-		if c.priority > p.priority {
+		if c.isBefore(p) {
 			pq.swap(ic, ip)
 		}
 		if ic <= 0 {
@@ -137,6 +137,33 @@ var _ = Describe("Priority queue", func() {
 		})
 	})
 
+	Describe("Rank", func() {
+		It("should count the items which will be popped first", func() {
+			pq := newPriorityQueueWithInitialCapacity(5)
+			pq.Add("one", 1, 111)
+			pq.Add("three", 3, 333)
+			pq.Add("zero", 0, -10)
+			pq.Add("other-three", 3, 3333)
+			rank, err := pq.Rank("three")
+			Expect(err).To(BeNil())
+			Expect(rank).To(Equal(0))
+			rank, err = pq.Rank("other-three")
+			Expect(err).To(BeNil())
+			Expect(rank).To(Equal(1))
+			rank, err = pq.Rank("one")
+			Expect(err).To(BeNil())
+			Expect(rank).To(Equal(2))
+			rank, err = pq.Rank("zero")
+			Expect(err).To(BeNil())
+			Expect(rank).To(Equal(3))
+		})
+		It("should return an error for a missing key", func() {
+			pq := newPriorityQueueWithInitialCapacity(5)
+			_, err := pq.Rank("missing")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
 	// add
 	Describe("Add and Pop", func() {
 		pq := NewPriorityQueue()
@@ -330,3 +357,172 @@ func checkArrayForSortedness(arr []int) [][3]int {
 	}
 	return problems
 }
+
+// referenceQueue is a naive priority queue: a slice kept sorted by
+// descending priority, and by insertion order within a priority.
+type referenceQueue struct {
+	items   []*node
+	nextSeq uint64
+}
+
+func (rq *referenceQueue) indexOf(key string) int {
+	for i, item := range rq.items {
+		if item.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func (rq *referenceQueue) sort() {
+	sort.SliceStable(rq.items, func(i, j int) bool { return rq.items[i].isBefore(rq.items[j]) })
+}
+
+func (rq *referenceQueue) add(key string, priority int) {
+	rq.items = append(rq.items, &node{key: key, priority: priority, value: key, seq: rq.nextSeq})
+	rq.nextSeq++
+	rq.sort()
+}
+
+func (rq *referenceQueue) pop() string {
+	item := rq.items[0]
+	rq.items = rq.items[1:]
+	return item.key
+}
+
+func (rq *referenceQueue) set(key string, priority int) {
+	rq.items[rq.indexOf(key)].priority = priority
+	rq.sort()
+}
+
+func (rq *referenceQueue) remove(key string) {
+	i := rq.indexOf(key)
+	rq.items = append(rq.items[:i], rq.items[i+1:]...)
+}
+
+var _ = Describe("Priority queue, compared to a sorted slice", func() {
+	It("should pop equal priorities in insertion order", func() {
+		pq := NewPriorityQueue()
+		for i := 0; i < 20; i++ {
+			Expect(pq.Add(fmt.Sprintf("k%d", i), i%2, i)).To(BeNil())
+		}
+		popped := []interface{}{}
+		for !pq.IsEmpty() {
+			value, err := pq.Pop()
+			Expect(err).To(BeNil())
+			popped = append(popped, value)
+		}
+		Expect(popped).To(Equal([]interface{}{1, 3, 5, 7, 9, 11, 13, 15, 17, 19, 0, 2, 4, 6, 8, 10, 12, 14, 16, 18}))
+	})
+
+	It("should walk the items in pop order without removing them", func() {
+		random := rand.New(rand.NewSource(1))
+		pq := newPriorityQueueWithInitialCapacity(2)
+		for i := 0; i < 200; i++ {
+			Expect(pq.Add(fmt.Sprintf("k%d", i), random.Intn(8), i)).To(BeNil())
+		}
+		walked := []interface{}{}
+		pq.Walk(func(value interface{}) bool {
+			walked = append(walked, value)
+			return true
+		})
+		Expect(pq.Size()).To(Equal(200))
+		Expect(pq.CheckValidity()).To(BeEmpty())
+
+		first := []interface{}{}
+		pq.Walk(func(value interface{}) bool {
+			first = append(first, value)
+			return len(first) < 3
+		})
+		Expect(first).To(Equal(walked[:3]))
+
+		popped := []interface{}{}
+		for !pq.IsEmpty() {
+			value, err := pq.Pop()
+			Expect(err).To(BeNil())
+			popped = append(popped, value)
+		}
+		Expect(walked).To(Equal(popped))
+	})
+
+	It("should keep an item's place among equal priorities when its priority changes", func() {
+		pq := NewPriorityQueue()
+		pq.Add("a", 1, "a")
+		pq.Add("b", 5, "b")
+		pq.Add("c", 1, "c")
+		Expect(pq.Set("b", 1)).To(BeNil())
+		rank, err := pq.Rank("b")
+		Expect(err).To(BeNil())
+		Expect(rank).To(Equal(1))
+		for _, expected := range []string{"a", "b", "c"} {
+			Expect(pq.Pop()).To(Equal(expected))
+		}
+	})
+
+	for _, seed := range []int64{1, 2, 3, 4, 5} {
+		seed := seed
+		It(fmt.Sprintf("should agree with the reference after random operations (seed %d)", seed), func() {
+			random := rand.New(rand.NewSource(seed))
+			pq := newPriorityQueueWithInitialCapacity(2)
+			reference := &referenceQueue{}
+			keys := 0
+			for op := 0; op < 2000; op++ {
+				switch choice := random.Intn(10); {
+				case choice < 4 || len(reference.items) == 0:
+					key := fmt.Sprintf("key-%d", keys)
+					keys++
+					priority := random.Intn(8)
+					Expect(pq.Add(key, priority, key)).To(BeNil())
+					reference.add(key, priority)
+				case choice < 6:
+					value, err := pq.Pop()
+					Expect(err).To(BeNil())
+					Expect(value).To(Equal(reference.pop()))
+				case choice < 8:
+					key := reference.items[random.Intn(len(reference.items))].key
+					priority := random.Intn(8)
+					Expect(pq.Set(key, priority)).To(BeNil())
+					reference.set(key, priority)
+				default:
+					key := reference.items[random.Intn(len(reference.items))].key
+					value, err := pq.Remove(key)
+					Expect(err).To(BeNil())
+					Expect(value).To(Equal(key))
+					reference.remove(key)
+				}
+				Expect(pq.Size()).To(Equal(len(reference.items)))
+				Expect(pq.CheckValidity()).To(BeEmpty())
+				if len(reference.items) > 0 {
+					Expect(pq.Peek()).To(Equal(reference.items[0].key))
+					i := random.Intn(len(reference.items))
+					Expect(pq.Rank(reference.items[i].key)).To(Equal(i))
+				}
+			}
+		})
+	}
+
+	It("should be usable from several goroutines when locked", func() {
+		lpq := NewLockedPriorityQueue()
+		done := make(chan bool)
+		for g := 0; g < 4; g++ {
+			go func(g int) {
+				defer GinkgoRecover()
+				for i := 0; i < 250; i++ {
+					Expect(lpq.Add(fmt.Sprintf("%d-%d", g, i), i, i)).To(BeNil())
+				}
+				done <- true
+			}(g)
+		}
+		for g := 0; g < 4; g++ {
+			<-done
+		}
+		Expect(lpq.Size()).To(Equal(1000))
+		previous := 1000
+		for !lpq.IsEmpty() {
+			value, err := lpq.Pop()
+			Expect(err).To(BeNil())
+			Expect(value.(int)).To(BeNumerically("<=", previous))
+			previous = value.(int)
+		}
+	})
+})
@@ -0,0 +1,76 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// QueueMonitor instruments the action queue of a serialized loop: how many
+// actions are waiting -- including senders blocked on a full channel -- how
+// long each waits before the loop starts on it, and how many senders were
+// blocked for longer than `slowEnqueueThreshold`.  It's safe for concurrent
+// use.
+type QueueMonitor struct {
+	depth                int64
+	loop                 string
+	slowEnqueueThreshold time.Duration
+}
+
+// NewQueueMonitor .....
+func NewQueueMonitor(loop string, slowEnqueueThreshold time.Duration) *QueueMonitor {
+	monitor := &QueueMonitor{loop: loop, slowEnqueueThreshold: slowEnqueueThreshold}
+	recordActionQueueDepth(loop, 0)
+	return monitor
+}
+
+// WillEnqueue is called just before sending an action, and returns the time
+// to hand to DidEnqueue and DidDequeue.
+func (monitor *QueueMonitor) WillEnqueue() time.Time {
+	recordActionQueueDepth(monitor.loop, atomic.AddInt64(&monitor.depth, 1))
+	return time.Now()
+}
+
+// DidEnqueue is called once the send has gone through.
+func (monitor *QueueMonitor) DidEnqueue(enqueuedAt time.Time) {
+	if time.Since(enqueuedAt) > monitor.slowEnqueueThreshold {
+		recordSlowEnqueue(monitor.loop)
+	}
+}
+
+// AbandonEnqueue is called instead of DidEnqueue when the send was given up on.
+func (monitor *QueueMonitor) AbandonEnqueue() {
+	recordActionQueueDepth(monitor.loop, atomic.AddInt64(&monitor.depth, -1))
+}
+
+// DidDequeue is called by the loop when it starts processing an action.
+func (monitor *QueueMonitor) DidDequeue(enqueuedAt time.Time) {
+	recordActionQueueDepth(monitor.loop, atomic.AddInt64(&monitor.depth, -1))
+	recordActionQueueWait(monitor.loop, time.Since(enqueuedAt))
+}
+
+// Depth is the number of actions which have been enqueued, or are being
+// enqueued, but which the loop hasn't started on.
+func (monitor *QueueMonitor) Depth() int {
+	return int(atomic.LoadInt64(&monitor.depth))
+}
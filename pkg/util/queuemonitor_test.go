@@ -0,0 +1,97 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func queueMetrics(loop string) (depth float64, waitCount uint64, waitSum float64, slowEnqueueCount float64) {
+	metric := &dto.Metric{}
+	Expect(actionQueueDepth.With(prometheus.Labels{"loop": loop}).Write(metric)).To(BeNil())
+	depth = metric.GetGauge().GetValue()
+	metric = &dto.Metric{}
+	Expect(actionQueueWait.With(prometheus.Labels{"loop": loop}).(prometheus.Histogram).Write(metric)).To(BeNil())
+	waitCount = metric.GetHistogram().GetSampleCount()
+	waitSum = metric.GetHistogram().GetSampleSum()
+	metric = &dto.Metric{}
+	Expect(slowEnqueues.With(prometheus.Labels{"loop": loop}).Write(metric)).To(BeNil())
+	slowEnqueueCount = metric.GetCounter().GetValue()
+	return
+}
+
+var _ = Describe("Queue monitor", func() {
+	It("tracks depth across enqueues, abandoned enqueues and dequeues", func() {
+		monitor := NewQueueMonitor("test-depth", time.Hour)
+		first := monitor.WillEnqueue()
+		monitor.DidEnqueue(first)
+		monitor.WillEnqueue()
+		monitor.AbandonEnqueue()
+		monitor.WillEnqueue()
+		Expect(monitor.Depth()).To(Equal(2))
+		monitor.DidDequeue(first)
+		Expect(monitor.Depth()).To(Equal(1))
+		depth, waitCount, _, slowEnqueueCount := queueMetrics("test-depth")
+		Expect(depth).To(Equal(float64(1)))
+		Expect(waitCount).To(Equal(uint64(1)))
+		Expect(slowEnqueueCount).To(Equal(float64(0)))
+	})
+
+	It("reflects a slow consumer", func() {
+		monitor := NewQueueMonitor("test-load", 50*time.Millisecond)
+		queue := make(chan time.Time, 2)
+		producers := 10
+		go func() {
+			for i := 0; i < producers; i++ {
+				enqueuedAt := <-queue
+				monitor.DidDequeue(enqueuedAt)
+				time.Sleep(20 * time.Millisecond)
+			}
+		}()
+		var wg sync.WaitGroup
+		for i := 0; i < producers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				enqueuedAt := monitor.WillEnqueue()
+				queue <- enqueuedAt
+				monitor.DidEnqueue(enqueuedAt)
+			}()
+		}
+		Eventually(monitor.Depth).Should(BeNumerically(">=", 5))
+		wg.Wait()
+		Eventually(monitor.Depth).Should(Equal(0))
+
+		depth, waitCount, waitSum, slowEnqueueCount := queueMetrics("test-load")
+		Expect(depth).To(Equal(float64(0)))
+		Expect(waitCount).To(Equal(uint64(producers)))
+		// with 20ms per action, the later actions wait for most of the 200ms
+		Expect(waitSum).To(BeNumerically(">", 0.3))
+		Expect(slowEnqueueCount).To(BeNumerically(">=", 1))
+	})
+})
@@ -0,0 +1,179 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket: it holds up to `burst` tokens, refilled at
+// `rate` tokens per second, and each event takes one.  It's safe for
+// concurrent use.
+type RateLimiter struct {
+	mutex    sync.Mutex
+	rate     float64
+	burst    float64
+	tokens   float64
+	lastSeen time.Time
+	now      func() time.Time
+}
+
+// NewRateLimiter returns a RateLimiter which starts out with a full bucket.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return NewRateLimiterWithClock(rate, burst, time.Now)
+}
+
+// NewRateLimiterWithClock is NewRateLimiter, reading the time from `now`.
+func NewRateLimiterWithClock(rate float64, burst int, now func() time.Time) *RateLimiter {
+	if rate < 0 || burst < 1 {
+		panic(fmt.Errorf("invalid rate limit: need rate >= 0 and burst >= 1, got %f and %d", rate, burst))
+	}
+	return &RateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), lastSeen: now(), now: now}
+}
+
+// Take takes a token and returns 0 if one is available, and otherwise
+// returns how long until one will be.
+func (rl *RateLimiter) Take() time.Duration {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	now := rl.now()
+	rl.tokens = math.Min(rl.burst, rl.tokens+now.Sub(rl.lastSeen).Seconds()*rl.rate)
+	rl.lastSeen = now
+	if rl.tokens >= 1 {
+		rl.tokens--
+		return 0
+	}
+	if rl.rate <= 0 {
+		return time.Hour
+	}
+	return time.Duration((1 - rl.tokens) / rl.rate * float64(time.Second))
+}
+
+// Allow takes a token if one is available, without waiting.
+func (rl *RateLimiter) Allow() bool {
+	return rl.Take() == 0
+}
+
+// Wait blocks until a token is available and takes it, returning the
+// context's error if it's done first.
+func (rl *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		wait := rl.Take()
+		if wait == 0 {
+			return nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+func (rl *RateLimiter) idleSince() time.Time {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+	return rl.lastSeen
+}
+
+// KeyedRateLimiter keeps a separate RateLimiter for each key, all with the
+// same rate and burst.  Limiters for keys which have been idle for longer
+// than `idleTimeout` are dropped.  It's safe for concurrent use.
+type KeyedRateLimiter struct {
+	mutex       sync.Mutex
+	rate        float64
+	burst       int
+	limiters    map[string]*RateLimiter
+	idleTimeout time.Duration
+	lastSweep   time.Time
+	now         func() time.Time
+}
+
+// NewKeyedRateLimiter .....
+func NewKeyedRateLimiter(rate float64, burst int, idleTimeout time.Duration) *KeyedRateLimiter {
+	return NewKeyedRateLimiterWithClock(rate, burst, idleTimeout, time.Now)
+}
+
+// NewKeyedRateLimiterWithClock is NewKeyedRateLimiter, reading the time from `now`.
+func NewKeyedRateLimiterWithClock(rate float64, burst int, idleTimeout time.Duration, now func() time.Time) *KeyedRateLimiter {
+	return &KeyedRateLimiter{
+		rate:        rate,
+		burst:       burst,
+		limiters:    map[string]*RateLimiter{},
+		idleTimeout: idleTimeout,
+		lastSweep:   now(),
+		now:         now,
+	}
+}
+
+func (krl *KeyedRateLimiter) limiter(key string) *RateLimiter {
+	krl.mutex.Lock()
+	defer krl.mutex.Unlock()
+	now := krl.now()
+	if now.Sub(krl.lastSweep) > krl.idleTimeout {
+		for limiterKey, limiter := range krl.limiters {
+			if now.Sub(limiter.idleSince()) > krl.idleTimeout {
+				delete(krl.limiters, limiterKey)
+			}
+		}
+		krl.lastSweep = now
+	}
+	limiter, ok := krl.limiters[key]
+	if !ok {
+		limiter = NewRateLimiterWithClock(krl.rate, krl.burst, krl.now)
+		krl.limiters[key] = limiter
+	}
+	return limiter
+}
+
+// Take is RateLimiter.Take for `key`'s limiter.
+func (krl *KeyedRateLimiter) Take(key string) time.Duration {
+	return krl.limiter(key).Take()
+}
+
+// Allow is RateLimiter.Allow for `key`'s limiter.
+func (krl *KeyedRateLimiter) Allow(key string) bool {
+	return krl.limiter(key).Allow()
+}
+
+// Wait is RateLimiter.Wait for `key`'s limiter.
+func (krl *KeyedRateLimiter) Wait(ctx context.Context, key string) error {
+	return krl.limiter(key).Wait(ctx)
+}
+
+// Len returns the number of keys currently being tracked.
+func (krl *KeyedRateLimiter) Len() int {
+	krl.mutex.Lock()
+	defer krl.mutex.Unlock()
+	return len(krl.limiters)
+}
+
+// IdleTimeout .....
+func (krl *KeyedRateLimiter) IdleTimeout() time.Duration {
+	return krl.idleTimeout
+}
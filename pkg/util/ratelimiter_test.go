@@ -0,0 +1,110 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rate limiter", func() {
+	var now time.Time
+	clock := func() time.Time { return now }
+	BeforeEach(func() {
+		now = time.Now()
+	})
+
+	It("allows a burst, then rejects", func() {
+		rl := NewRateLimiterWithClock(1, 3, clock)
+		for i := 0; i < 3; i++ {
+			Expect(rl.Allow()).To(BeTrue())
+		}
+		Expect(rl.Allow()).To(BeFalse())
+		Expect(rl.Take()).To(Equal(time.Second))
+	})
+
+	It("refills at the sustained rate, up to the burst", func() {
+		rl := NewRateLimiterWithClock(4, 2, clock)
+		Expect(rl.Allow()).To(BeTrue())
+		Expect(rl.Allow()).To(BeTrue())
+		Expect(rl.Allow()).To(BeFalse())
+		now = now.Add(250 * time.Millisecond)
+		Expect(rl.Allow()).To(BeTrue())
+		Expect(rl.Allow()).To(BeFalse())
+		// a long wait only refills up to the burst
+		now = now.Add(time.Hour)
+		allowed := 0
+		for rl.Allow() {
+			allowed++
+		}
+		Expect(allowed).To(Equal(2))
+	})
+
+	It("sustains roughly its rate while waiting", func() {
+		rl := NewRateLimiter(50, 1)
+		start := time.Now()
+		for i := 0; i < 11; i++ {
+			Expect(rl.Wait(context.Background())).To(BeNil())
+		}
+		// 10 waits of 20ms, with plenty of slack for a busy machine
+		elapsed := time.Now().Sub(start)
+		Expect(elapsed).To(BeNumerically(">=", 180*time.Millisecond))
+		Expect(elapsed).To(BeNumerically("<", 2*time.Second))
+	})
+
+	It("stops waiting once the context is cancelled", func() {
+		rl := NewRateLimiter(0.001, 1)
+		Expect(rl.Allow()).To(BeTrue())
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		Expect(rl.Wait(ctx)).To(Equal(context.DeadlineExceeded))
+	})
+
+	It("limits each key separately", func() {
+		krl := NewKeyedRateLimiterWithClock(1, 1, time.Minute, clock)
+		Expect(krl.Allow("a")).To(BeTrue())
+		Expect(krl.Allow("a")).To(BeFalse())
+		Expect(krl.Allow("b")).To(BeTrue())
+		Expect(krl.Len()).To(Equal(2))
+	})
+
+	It("drops keys which have been idle", func() {
+		krl := NewKeyedRateLimiterWithClock(1, 1, time.Minute, clock)
+		krl.Allow("idle")
+		now = now.Add(30 * time.Second)
+		krl.Allow("active")
+		now = now.Add(45 * time.Second)
+		krl.Allow("active")
+		Expect(krl.Len()).To(Equal(1))
+		// an expired key starts over with a full bucket
+		Expect(krl.Allow("idle")).To(BeTrue())
+		Expect(krl.Len()).To(Equal(2))
+	})
+
+	It("rejects invalid limits", func() {
+		Expect(func() { NewRateLimiter(-1, 1) }).To(Panic())
+		Expect(func() { NewRateLimiter(1, 0) }).To(Panic())
+	})
+})
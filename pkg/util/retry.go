@@ -0,0 +1,133 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// RetryPredicate says whether a failed attempt is worth retrying.
+type RetryPredicate func(err error) bool
+
+// RetryOn retries errors whose cause is one of `targets`.
+func RetryOn(targets ...error) RetryPredicate {
+	return func(err error) bool {
+		cause := errors.Cause(err)
+		for _, target := range targets {
+			if err == target || cause == target {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RetryAlways retries every error.
+func RetryAlways(err error) bool {
+	return true
+}
+
+// Or retries errors which either predicate retries.
+func (p RetryPredicate) Or(other RetryPredicate) RetryPredicate {
+	return func(err error) bool {
+		return p(err) || other(err)
+	}
+}
+
+// And retries errors which both predicates retry.
+func (p RetryPredicate) And(other RetryPredicate) RetryPredicate {
+	return func(err error) bool {
+		return p(err) && other(err)
+	}
+}
+
+// Not retries the errors which `p` doesn't.
+func (p RetryPredicate) Not() RetryPredicate {
+	return func(err error) bool {
+		return !p(err)
+	}
+}
+
+// RetryPolicy describes how RetryWithPolicy retries.  Its backoff is copied
+// for each call, so that one policy can be shared.
+type RetryPolicy struct {
+	Backoff     *Backoff
+	MaxAttempts int
+	// IsRetryable, if nil, retries every error
+	IsRetryable RetryPredicate
+}
+
+// RetryError is the last error of a call which RetryWithPolicy gave up on.
+type RetryError struct {
+	Attempts int
+	// Err is the last attempt's error, or the context's if it was done first
+	Err error
+}
+
+func (err *RetryError) Error() string {
+	return fmt.Sprintf("failed after %d attempts: %s", err.Attempts, err.Err.Error())
+}
+
+// Cause lets errors.Cause see through to the last attempt's error.
+func (err *RetryError) Cause() error {
+	return errors.Cause(err.Err)
+}
+
+// Unwrap .....
+func (err *RetryError) Unwrap() error {
+	return err.Err
+}
+
+// RetryWithPolicy calls `fn` until it succeeds, its error isn't retryable,
+// `policy.MaxAttempts` calls have been made, or `ctx` is done, waiting
+// according to the policy's backoff between calls.  Failures come back as
+// a *RetryError.
+func RetryWithPolicy(ctx context.Context, policy *RetryPolicy, fn func() error) error {
+	backoff := *policy.Backoff
+	backoff.Reset()
+	isRetryable := policy.IsRetryable
+	if isRetryable == nil {
+		isRetryable = RetryAlways
+	}
+	attempt := 0
+	for {
+		attempt++
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if attempt >= policy.MaxAttempts || !isRetryable(err) {
+			return &RetryError{Attempts: attempt, Err: err}
+		}
+		wait := time.NewTimer(backoff.Next())
+		select {
+		case <-wait.C:
+		case <-ctx.Done():
+			wait.Stop()
+			return &RetryError{Attempts: attempt, Err: ctx.Err()}
+		}
+	}
+}
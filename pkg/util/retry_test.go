@@ -0,0 +1,132 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/juju/errors"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retry policy", func() {
+	errA := fmt.Errorf("a")
+	errB := fmt.Errorf("b")
+	errC := fmt.Errorf("c")
+	fastPolicy := func(attempts int, isRetryable RetryPredicate) *RetryPolicy {
+		return &RetryPolicy{Backoff: NewBackoff(time.Millisecond, time.Millisecond, 1, false), MaxAttempts: attempts, IsRetryable: isRetryable}
+	}
+
+	It("matches errors by their cause", func() {
+		isRetryable := RetryOn(errA, errB)
+		Expect(isRetryable(errA)).To(BeTrue())
+		Expect(isRetryable(errors.Trace(errB))).To(BeTrue())
+		Expect(isRetryable(errors.Annotate(errA, "annotated"))).To(BeTrue())
+		Expect(isRetryable(errC)).To(BeFalse())
+		Expect(isRetryable(fmt.Errorf("a"))).To(BeFalse())
+	})
+
+	It("combines predicates", func() {
+		either := RetryOn(errA).Or(RetryOn(errB))
+		Expect(either(errA)).To(BeTrue())
+		Expect(either(errB)).To(BeTrue())
+		Expect(either(errC)).To(BeFalse())
+
+		allButB := RetryPredicate(RetryAlways).And(RetryOn(errB).Not())
+		Expect(allButB(errA)).To(BeTrue())
+		Expect(allButB(errB)).To(BeFalse())
+		Expect(allButB(errC)).To(BeTrue())
+
+		neither := either.Not()
+		Expect(neither(errA)).To(BeFalse())
+		Expect(neither(errC)).To(BeTrue())
+	})
+
+	It("retries until the function succeeds", func() {
+		calls := 0
+		err := RetryWithPolicy(context.Background(), fastPolicy(5, RetryOn(errA)), func() error {
+			calls++
+			if calls < 4 {
+				return errA
+			}
+			return nil
+		})
+		Expect(err).To(BeNil())
+		Expect(calls).To(Equal(4))
+	})
+
+	It("wraps the last error with the attempt count once the attempts run out", func() {
+		calls := 0
+		err := RetryWithPolicy(context.Background(), fastPolicy(3, nil), func() error {
+			calls++
+			return errors.Annotatef(errA, "call %d", calls)
+		})
+		Expect(calls).To(Equal(3))
+		Expect(err).To(MatchError("failed after 3 attempts: call 3: a"))
+		Expect(err.(*RetryError).Attempts).To(Equal(3))
+		Expect(errors.Cause(err)).To(Equal(errA))
+	})
+
+	It("gives up at the first error which isn't retryable", func() {
+		calls := 0
+		err := RetryWithPolicy(context.Background(), fastPolicy(5, RetryOn(errA)), func() error {
+			calls++
+			if calls == 1 {
+				return errA
+			}
+			return errB
+		})
+		Expect(calls).To(Equal(2))
+		Expect(err).To(Equal(&RetryError{Attempts: 2, Err: errB}))
+	})
+
+	It("stops when the context is cancelled in the middle of a backoff", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		policy := &RetryPolicy{Backoff: NewBackoff(time.Hour, time.Hour, 1, false), MaxAttempts: 5}
+		calls := 0
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+		}()
+		start := time.Now()
+		err := RetryWithPolicy(ctx, policy, func() error {
+			calls++
+			return errA
+		})
+		Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+		Expect(calls).To(Equal(1))
+		Expect(err).To(Equal(&RetryError{Attempts: 1, Err: context.Canceled}))
+	})
+
+	It("can be shared, since each call gets its own backoff", func() {
+		policy := &RetryPolicy{Backoff: NewBackoff(time.Millisecond, time.Second, 10, false), MaxAttempts: 2}
+		for i := 0; i < 3; i++ {
+			start := time.Now()
+			RetryWithPolicy(context.Background(), policy, func() error { return errA })
+			Expect(time.Since(start)).To(BeNumerically("<", 500*time.Millisecond))
+		}
+		Expect(policy.Backoff.Attempts()).To(Equal(0))
+	})
+})
@@ -0,0 +1,108 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RingEntry is a value held by a RingBuffer.  Time is zero unless the value
+// was appended with one.
+type RingEntry struct {
+	Value interface{}
+	Time  time.Time
+}
+
+// RingBuffer holds the most recent `capacity` values appended to it,
+// dropping the oldest ones to make room.  It's meant for one writer, with
+// any number of concurrent readers: reads return copies.
+type RingBuffer struct {
+	mutex   sync.RWMutex
+	entries []RingEntry
+	// next is the index the next append goes to
+	next int
+	size int
+}
+
+// NewRingBuffer .....
+func NewRingBuffer(capacity int) *RingBuffer {
+	if capacity <= 0 {
+		panic(fmt.Errorf("invalid ring buffer capacity: must be positive, was %d", capacity))
+	}
+	return &RingBuffer{entries: make([]RingEntry, capacity)}
+}
+
+// Append adds a value without a timestamp.
+func (rb *RingBuffer) Append(value interface{}) {
+	rb.append(RingEntry{Value: value})
+}
+
+// AppendWithTime adds a value along with a timestamp, such as when it happened.
+func (rb *RingBuffer) AppendWithTime(value interface{}, t time.Time) {
+	rb.append(RingEntry{Value: value, Time: t})
+}
+
+func (rb *RingBuffer) append(entry RingEntry) {
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+	rb.entries[rb.next] = entry
+	rb.next = (rb.next + 1) % len(rb.entries)
+	if rb.size < len(rb.entries) {
+		rb.size++
+	}
+}
+
+// Snapshot returns a copy of the entries, oldest first.
+func (rb *RingBuffer) Snapshot() []RingEntry {
+	rb.mutex.RLock()
+	defer rb.mutex.RUnlock()
+	snapshot := make([]RingEntry, rb.size)
+	start := (rb.next - rb.size + len(rb.entries)) % len(rb.entries)
+	for i := 0; i < rb.size; i++ {
+		snapshot[i] = rb.entries[(start+i)%len(rb.entries)]
+	}
+	return snapshot
+}
+
+// Values returns a copy of the values, oldest first.
+func (rb *RingBuffer) Values() []interface{} {
+	snapshot := rb.Snapshot()
+	values := make([]interface{}, len(snapshot))
+	for i, entry := range snapshot {
+		values[i] = entry.Value
+	}
+	return values
+}
+
+// Len returns the number of entries held, which is at most the capacity.
+func (rb *RingBuffer) Len() int {
+	rb.mutex.RLock()
+	defer rb.mutex.RUnlock()
+	return rb.size
+}
+
+// Capacity .....
+func (rb *RingBuffer) Capacity() int {
+	return len(rb.entries)
+}
@@ -0,0 +1,105 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ring buffer", func() {
+	It("should start out empty", func() {
+		rb := NewRingBuffer(3)
+		Expect(rb.Len()).To(Equal(0))
+		Expect(rb.Capacity()).To(Equal(3))
+		Expect(rb.Snapshot()).To(BeEmpty())
+	})
+
+	It("should keep values in the order they were appended", func() {
+		rb := NewRingBuffer(3)
+		rb.Append("a")
+		rb.Append("b")
+		Expect(rb.Values()).To(Equal([]interface{}{"a", "b"}))
+		Expect(rb.Len()).To(Equal(2))
+	})
+
+	It("should drop the oldest values once it wraps around", func() {
+		rb := NewRingBuffer(3)
+		for i := 0; i < 8; i++ {
+			rb.Append(i)
+		}
+		Expect(rb.Len()).To(Equal(3))
+		Expect(rb.Values()).To(Equal([]interface{}{5, 6, 7}))
+		rb.Append(8)
+		Expect(rb.Values()).To(Equal([]interface{}{6, 7, 8}))
+	})
+
+	It("should keep timestamps when they're given", func() {
+		rb := NewRingBuffer(2)
+		now := time.Now()
+		rb.AppendWithTime("a", now)
+		rb.Append("b")
+		snapshot := rb.Snapshot()
+		Expect(snapshot[0]).To(Equal(RingEntry{Value: "a", Time: now}))
+		Expect(snapshot[1].Time.IsZero()).To(BeTrue())
+	})
+
+	It("should return snapshots which later appends don't change", func() {
+		rb := NewRingBuffer(2)
+		rb.Append("a")
+		snapshot := rb.Snapshot()
+		rb.Append("b")
+		rb.Append("c")
+		Expect(snapshot).To(Equal([]RingEntry{{Value: "a"}}))
+	})
+
+	It("should always give readers a consistent snapshot while being written to", func() {
+		rb := NewRingBuffer(10)
+		done := make(chan bool)
+		go func() {
+			for i := 0; i < 10000; i++ {
+				rb.Append(i)
+			}
+			close(done)
+		}()
+		for reading := true; reading; {
+			select {
+			case <-done:
+				reading = false
+			default:
+			}
+			values := rb.Values()
+			Expect(len(values)).To(BeNumerically("<=", 10))
+			for i := 1; i < len(values); i++ {
+				Expect(values[i]).To(Equal(values[i-1].(int)+1), fmt.Sprintf("snapshot %v", values))
+			}
+		}
+		Expect(rb.Values()).To(Equal([]interface{}{9990, 9991, 9992, 9993, 9994, 9995, 9996, 9997, 9998, 9999}))
+	})
+
+	It("should reject a non-positive capacity", func() {
+		Expect(func() { NewRingBuffer(0) }).To(Panic())
+	})
+})
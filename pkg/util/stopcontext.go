@@ -0,0 +1,54 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"time"
+)
+
+// stopContext adapts a stop channel into a context, which is cancelled once
+// the channel is closed.
+type stopContext struct {
+	stop <-chan struct{}
+}
+
+func (ctx stopContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (ctx stopContext) Done() <-chan struct{} {
+	return ctx.stop
+}
+
+func (ctx stopContext) Err() error {
+	select {
+	case <-ctx.stop:
+		return context.Canceled
+	default:
+		return nil
+	}
+}
+
+func (ctx stopContext) Value(key interface{}) interface{} {
+	return nil
+}
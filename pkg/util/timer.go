@@ -22,14 +22,24 @@ under the License.
 package util
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// TimerState describes the state of a timer
-type TimerState int
+// ErrTimerStopped is returned when trying to control a timer which has
+// already been stopped.
+var ErrTimerStopped = fmt.Errorf("timer is stopped")
+
+// TimerState describes the state of a timer: TimerStateReady and
+// TimerStateRunningAction both mean that it's running.
+type TimerState int32
 
 // .....
 const (
@@ -54,167 +64,428 @@ func (state TimerState) String() string {
 	panic(fmt.Errorf("invalid TimerState value: %d", state))
 }
 
+// TimerOverlapPolicy decides what happens to a tick which arrives while the
+// action is still running.  Either way, the action never runs concurrently
+// with itself.
+type TimerOverlapPolicy int32
+
+// .....
+const (
+	// TimerOverlapSkip drops the tick
+	TimerOverlapSkip TimerOverlapPolicy = iota
+	// TimerOverlapCoalesce folds any number of such ticks into a single run,
+	// started as soon as the current one finishes
+	TimerOverlapCoalesce TimerOverlapPolicy = iota
+)
+
+// String .....
+func (policy TimerOverlapPolicy) String() string {
+	switch policy {
+	case TimerOverlapSkip:
+		return "TimerOverlapSkip"
+	case TimerOverlapCoalesce:
+		return "TimerOverlapCoalesce"
+	}
+	panic(fmt.Errorf("invalid TimerOverlapPolicy value: %d", policy))
+}
+
 type resume struct {
 	runImmediately bool
 	err            chan error
 }
 
+// afterFunc starts a one-shot timer for `d`, returning its channel and a
+// function which cancels it.
+type afterFunc func(d time.Duration) (<-chan time.Time, func() bool)
+
+func realAfter(d time.Duration) (<-chan time.Time, func() bool) {
+	t := time.NewTimer(d)
+	return t.C, t.Stop
+}
+
 // Timer periodically executes `action`, waiting `delay` between invocation starts.
-// If `action` takes longer than `delay`, invocations will be dropped.
+// The action never runs concurrently with itself: if it takes longer than
+// `delay`, ticks are dropped or coalesced according to the overlap policy.
 // It stops when receiving an event on `stop`.
 // It's basically a time.Ticker with additional functionality for pausing and resuming.
+// With a non-zero `jitter`, each pause is randomly adjusted by up to that
+// fraction of `delay`, so that timers started together drift apart.
 type Timer struct {
-	name   string
-	state  TimerState
-	delay  time.Duration
-	action func()
+	// delay is in nanoseconds, and accessed atomically so that it can be read
+	// and set at any time; it comes first to keep it 64-bit aligned
+	delay int64
+	name  string
+	// state is a TimerState, accessed atomically
+	state  int32
+	jitter float64
+	// action gets a context for each run, which is cancelled along with ctx
+	action func(ctx context.Context)
+	ctx    context.Context
+	// overlapPolicy is a TimerOverlapPolicy, accessed atomically
+	overlapPolicy int32
+	// panicsFatal is 1 if panics in the action should crash, accessed atomically
+	panicsFatal int32
+	// clock and randomness, replaceable for testing
+	after  afterFunc
+	random func() float64
+	// execution statistics, shared with the goroutines running the action
+	statsMutex sync.RWMutex
+	stats      TimerStats
 	// channels
-	pause    chan chan error
-	resume   chan *resume
-	stop     <-chan struct{}
-	setDelay chan time.Duration
+	pause  chan chan error
+	resume chan *resume
+	runNow chan chan error
+	stop   <-chan struct{}
 }
 
 // NewRunningTimer creates a new timer which is running
 func NewRunningTimer(name string, delay time.Duration, stop <-chan struct{}, runImmediately bool, action func()) *Timer {
-	s := NewTimer(name, delay, stop, action)
+	return NewJitteredRunningTimer(name, delay, 0, stop, runImmediately, action)
+}
+
+// NewJitteredRunningTimer creates a new timer which is running, and whose
+// pauses vary by up to +/- `jitter` (a fraction between 0 and 1) of `delay`.
+func NewJitteredRunningTimer(name string, delay time.Duration, jitter float64, stop <-chan struct{}, runImmediately bool, action func()) *Timer {
+	return resumeNewTimer(NewJitteredTimer(name, delay, jitter, stop, action), runImmediately)
+}
+
+// NewRunningTimerWithContext creates a new timer which is running, until
+// `ctx` is cancelled.
+func NewRunningTimerWithContext(ctx context.Context, name string, delay time.Duration, runImmediately bool, action func(ctx context.Context)) *Timer {
+	return resumeNewTimer(NewTimerWithContext(ctx, name, delay, action), runImmediately)
+}
+
+func resumeNewTimer(s *Timer, runImmediately bool) *Timer {
 	err := s.Resume(runImmediately)
 	if err != nil {
 		// TODO somehow handle error?
-		log.Errorf("timer %s: %s", name, err.Error())
+		log.Errorf("timer %s: %s", s.name, err.Error())
 	} else {
-		log.Debugf("timer %s started timer successfully", name)
+		log.Debugf("timer %s started timer successfully", s.name)
 	}
 	return s
 }
 
 // NewTimer creates a new timer which is paused
 func NewTimer(name string, delay time.Duration, stop <-chan struct{}, action func()) *Timer {
-	if delay <= 0 {
-		panic(fmt.Errorf("invalid delay for timer %s: must be positive, was %s", name, delay))
+	return NewJitteredTimer(name, delay, 0, stop, action)
+}
+
+// NewJitteredTimer creates a new timer which is paused, and whose pauses
+// vary by up to +/- `jitter` (a fraction between 0 and 1) of `delay`.
+func NewJitteredTimer(name string, delay time.Duration, jitter float64, stop <-chan struct{}, action func()) *Timer {
+	return newTimer(name, delay, jitter, stop, realAfter, rand.Float64, action)
+}
+
+// NewTimerWithContext creates a new timer which is paused, and which stops
+// once `ctx` is cancelled.  Each run of `action` gets a context derived from
+// `ctx`, so that a long-running action can notice the timer stopping.
+func NewTimerWithContext(ctx context.Context, name string, delay time.Duration, action func(ctx context.Context)) *Timer {
+	return newContextTimer(ctx, name, delay, 0, realAfter, rand.Float64, action)
+}
+
+func newTimer(name string, delay time.Duration, jitter float64, stop <-chan struct{}, after afterFunc, random func() float64, action func()) *Timer {
+	return newContextTimer(stopContext{stop: stop}, name, delay, jitter, after, random, func(ctx context.Context) { action() })
+}
+
+func newContextTimer(ctx context.Context, name string, delay time.Duration, jitter float64, after afterFunc, random func() float64, action func(ctx context.Context)) *Timer {
+	if err := validateDelay(name, delay); err != nil {
+		panic(err)
+	}
+	if jitter < 0 || jitter > 1 {
+		panic(fmt.Errorf("invalid jitter for timer %s: must be between 0 and 1, was %f", name, jitter))
 	}
 	timer := &Timer{
-		name:     name,
-		state:    TimerStatePaused,
-		delay:    delay,
-		action:   action,
-		pause:    make(chan chan error),
-		resume:   make(chan *resume),
-		stop:     stop,
-		setDelay: make(chan time.Duration)}
+		name:   name,
+		state:  int32(TimerStatePaused),
+		delay:  int64(delay),
+		jitter: jitter,
+		action: action,
+		ctx:    ctx,
+		after:  after,
+		random: random,
+		pause:  make(chan chan error),
+		resume: make(chan *resume),
+		runNow: make(chan chan error),
+		stop:   ctx.Done()}
 	go timer.start()
 	return timer
 }
 
+// runAction runs the action once, returning false if it panicked.  Panics
+// are logged and swallowed, so that the timer keeps going, unless they've
+// been made fatal.
+func (timer *Timer) runAction() (completed bool) {
+	runCtx, cancel := context.WithCancel(timer.ctx)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			if timer.ArePanicsFatal() {
+				panic(r)
+			}
+			log.Errorf("timer %s: recovered from panic in action: %v\n%s", timer.name, r, debug.Stack())
+			completed = false
+		}
+	}()
+	timer.action(runCtx)
+	return true
+}
+
+// nextDelay returns how long to wait before the next tick: `delay`, moved
+// by a random amount of at most `jitter * delay` in either direction.
+func (timer *Timer) nextDelay() time.Duration {
+	delay := timer.Delay()
+	if timer.jitter == 0 {
+		return delay
+	}
+	offset := (2*timer.random() - 1) * timer.jitter * float64(delay)
+	next := delay + time.Duration(offset)
+	if next < 0 {
+		return 0
+	}
+	return next
+}
+
 func (timer *Timer) start() {
-	var baseTimer *time.Ticker
+	var cancel func() bool
 	var c <-chan time.Time
 	startTimer := func() {
-		baseTimer = time.NewTicker(timer.delay)
-		c = baseTimer.C
+		c, cancel = timer.after(timer.nextDelay())
 	}
 	stopTimer := func() {
-		baseTimer.Stop()
+		if cancel != nil {
+			cancel()
+		}
 		c = nil
 	}
 	didFinishAction := make(chan bool)
 	var shouldPauseAfterRunningAction bool
+	// whether a tick was coalesced while the action was running
+	var shouldRunAfterRunningAction bool
 	executeAction := func() {
-		timer.state = TimerStateRunningAction
+		timer.setState(TimerStateRunningAction)
 		shouldPauseAfterRunningAction = false
+		shouldRunAfterRunningAction = false
+		start := time.Now()
+		timer.didStartRun(start)
 		go func() {
-			timer.action()
+			if timer.runAction() {
+				timer.didFinishRun(start)
+			} else {
+				timer.didPanic()
+			}
 			select {
 			case didFinishAction <- true:
 			case <-timer.stop:
 			}
 		}()
 	}
+	// runNow executes the action out of cycle.  A running timer's next tick
+	// is pushed back a full pause; a paused timer goes back to being paused.
+	runNow := func() error {
+		switch timer.State() {
+		case TimerStateReady:
+			stopTimer()
+			startTimer()
+			executeAction()
+			return nil
+		case TimerStatePaused:
+			executeAction()
+			shouldPauseAfterRunningAction = true
+			return nil
+		default:
+			return fmt.Errorf("cannot run timer %s now while in state %s", timer.name, timer.State().String())
+		}
+	}
 	for {
 		select {
 		case <-didFinishAction:
-			//			log.Debugf("timer %s: didFinishAction, state %s, shouldPause %t", timer.name, timer.state, shouldPauseAfterRunningAction)
+			//			log.Debugf("timer %s: didFinishAction, state %s, shouldPause %t", timer.name, timer.State(), shouldPauseAfterRunningAction)
 			if shouldPauseAfterRunningAction {
-				timer.state = TimerStatePaused
+				timer.setState(TimerStatePaused)
 				stopTimer()
+			} else if shouldRunAfterRunningAction {
+				executeAction()
 			} else {
-				timer.state = TimerStateReady
+				timer.setState(TimerStateReady)
 			}
 		case <-c:
 			//			log.Debugf("timer %s: timer.C", timer.name)
-			switch timer.state {
+			startTimer()
+			switch timer.State() {
 			case TimerStateReady:
 				executeAction()
 			case TimerStateRunningAction:
-				log.Warnf("timer %s: backpressuring!  cannot run timer action, action already in progress", timer.name)
+				if timer.OverlapPolicy() == TimerOverlapCoalesce {
+					log.Debugf("timer %s: action already in progress, will run again once it finishes", timer.name)
+					shouldRunAfterRunningAction = true
+					timer.didCoalesceTick()
+				} else {
+					log.Warnf("timer %s: backpressuring!  cannot run timer action, action already in progress", timer.name)
+					timer.didSkipTick()
+				}
 			default:
-				log.Errorf("timer %s: cannot run action from state %s", timer.name, timer.state)
+				log.Errorf("timer %s: cannot run action from state %s", timer.name, timer.State())
 			}
 		case ch := <-timer.pause:
-			//			log.Debugf("timer %s: pause (state %s)", timer.name, timer.state)
-			switch timer.state {
+			//			log.Debugf("timer %s: pause (state %s)", timer.name, timer.State())
+			switch timer.State() {
 			case TimerStateReady:
-				timer.state = TimerStatePaused
+				timer.setState(TimerStatePaused)
 				stopTimer()
 				ch <- nil
 			case TimerStateRunningAction:
-				if shouldPauseAfterRunningAction {
-					ch <- fmt.Errorf("cannot pause timer %s: pause already queued up", timer.name)
-					break
-				}
 				shouldPauseAfterRunningAction = true
 				ch <- nil
+			case TimerStatePaused:
+				ch <- nil
 			default:
-				ch <- fmt.Errorf("cannot pause timer %s while in state %s", timer.name, timer.state.String())
+				ch <- fmt.Errorf("cannot pause timer %s while in state %s", timer.name, timer.State().String())
 			}
 		case action := <-timer.resume:
 			//			log.Debugf("timer %s: resume", timer.name)
-			switch timer.state {
+			switch timer.State() {
 			case TimerStatePaused:
-				action.err <- nil
-				startTimer()
+				timer.setState(TimerStateReady)
 				if action.runImmediately {
-					executeAction()
+					action.err <- runNow()
 				} else {
-					timer.state = TimerStateReady
+					startTimer()
+					action.err <- nil
 				}
+			case TimerStateReady:
+				action.err <- nil
+			case TimerStateRunningAction:
+				// undo a pause queued up behind the action, restarting the
+				// ticks if the action was run while paused
+				if shouldPauseAfterRunningAction {
+					shouldPauseAfterRunningAction = false
+					if c == nil {
+						startTimer()
+					}
+				}
+				action.err <- nil
 			default:
-				action.err <- fmt.Errorf("cannot resume timer %s while in state %s", timer.name, timer.state.String())
+				action.err <- fmt.Errorf("cannot resume timer %s while in state %s", timer.name, timer.State().String())
 			}
+		case ch := <-timer.runNow:
+			//			log.Debugf("timer %s: runNow", timer.name)
+			ch <- runNow()
 		case <-timer.stop:
-			//			log.Debugf("timer %s: stop, state %s", timer.name, timer.state)
-			switch timer.state {
+			//			log.Debugf("timer %s: stop, state %s", timer.name, timer.State())
+			switch timer.State() {
 			case TimerStateReady:
 				stopTimer()
 			case TimerStateStopped:
 				// ??? not sure how this would happen
 				log.Warnf("ignoring stop signal: timer %s already stopped", timer.name)
 			}
-			timer.state = TimerStateStopped
+			timer.setState(TimerStateStopped)
 			return
-		case delay := <-timer.setDelay:
-			//			log.Debugf("timer %s: setDelay", timer.name)
-			timer.delay = delay
 		}
 	}
 }
 
-// Pause temporarily stops the timer.
-// It returns an error if the timer could not be paused.
+// Pause temporarily stops the timer.  A running action is left to finish.
+// Pausing a paused timer does nothing; pausing a stopped one returns
+// ErrTimerStopped.
 func (timer *Timer) Pause() error {
 	ch := make(chan error)
-	timer.pause <- ch
-	return <-ch
+	select {
+	case timer.pause <- ch:
+		return <-ch
+	case <-timer.stop:
+		return ErrTimerStopped
+	}
 }
 
 // Resume resumes the timer with the option of immediately running the action.
+// Resuming a running timer does nothing, beyond cancelling a pause which was
+// waiting on the action to finish; resuming a stopped one returns
+// ErrTimerStopped.
 func (timer *Timer) Resume(runImmediately bool) error {
 	action := &resume{runImmediately: runImmediately, err: make(chan error)}
-	timer.resume <- action
-	return <-action.err
+	select {
+	case timer.resume <- action:
+		return <-action.err
+	case <-timer.stop:
+		return ErrTimerStopped
+	}
 }
 
-// SetDelay sets the delay
-func (timer *Timer) SetDelay(delay time.Duration) {
-	timer.setDelay <- delay
+// State returns the timer's current state.  It's safe to call at any time.
+func (timer *Timer) State() TimerState {
+	return TimerState(atomic.LoadInt32(&timer.state))
+}
+
+func (timer *Timer) setState(state TimerState) {
+	atomic.StoreInt32(&timer.state, int32(state))
+}
+
+// RunNow executes the action immediately, and pushes the next scheduled run
+// back by a full pause.  It returns an error if the action is already
+// running, or ErrTimerStopped if the timer has been stopped.
+func (timer *Timer) RunNow() error {
+	ch := make(chan error)
+	select {
+	case timer.runNow <- ch:
+		return <-ch
+	case <-timer.stop:
+		return ErrTimerStopped
+	}
+}
+
+// SetDelay changes the delay, whether the timer is running or paused.  It
+// applies from the next scheduled run on: a pause already under way is
+// neither shortened nor lengthened.  It returns an error if the delay isn't
+// positive, or ErrTimerStopped if the timer has been stopped.
+func (timer *Timer) SetDelay(delay time.Duration) error {
+	if err := validateDelay(timer.name, delay); err != nil {
+		return err
+	}
+	select {
+	case <-timer.stop:
+		return ErrTimerStopped
+	default:
+	}
+	atomic.StoreInt64(&timer.delay, int64(delay))
+	return nil
+}
+
+// SetOverlapPolicy decides what happens to ticks which arrive while the
+// action is running, from now on.
+func (timer *Timer) SetOverlapPolicy(policy TimerOverlapPolicy) {
+	atomic.StoreInt32(&timer.overlapPolicy, int32(policy))
+}
+
+// SetPanicsFatal decides whether a panic in the action crashes the process
+// (true), or is logged and counted in the stats (false, the default).
+func (timer *Timer) SetPanicsFatal(fatal bool) {
+	value := int32(0)
+	if fatal {
+		value = 1
+	}
+	atomic.StoreInt32(&timer.panicsFatal, value)
+}
+
+// ArePanicsFatal .....
+func (timer *Timer) ArePanicsFatal() bool {
+	return atomic.LoadInt32(&timer.panicsFatal) == 1
+}
+
+// OverlapPolicy returns the current overlap policy.
+func (timer *Timer) OverlapPolicy() TimerOverlapPolicy {
+	return TimerOverlapPolicy(atomic.LoadInt32(&timer.overlapPolicy))
+}
+
+// Delay returns the current delay.
+func (timer *Timer) Delay() time.Duration {
+	return time.Duration(atomic.LoadInt64(&timer.delay))
+}
+
+func validateDelay(name string, delay time.Duration) error {
+	if delay <= 0 {
+		return fmt.Errorf("invalid delay for timer %s: must be positive, was %s", name, delay)
+	}
+	return nil
 }
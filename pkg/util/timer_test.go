@@ -22,6 +22,9 @@ under the License.
 package util
 
 import (
+	"context"
+	"math/rand"
+	"sync/atomic"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -29,6 +32,38 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// fakeClock hands out a shared tick channel, and records the delay each
+// tick was requested for.
+type fakeClock struct {
+	ticks  chan time.Time
+	delays chan time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{ticks: make(chan time.Time), delays: make(chan time.Duration, 100)}
+}
+
+func (clock *fakeClock) after(d time.Duration) (<-chan time.Time, func() bool) {
+	clock.delays <- d
+	return clock.ticks, func() bool { return true }
+}
+
+// runTicks resumes the timer, fires `count` ticks, and returns the delay
+// requested before each one and after the last.
+func runTicks(timer *Timer, clock *fakeClock, count int) []time.Duration {
+	Expect(timer.Resume(false)).To(BeNil())
+	for i := 0; i < count; i++ {
+		clock.ticks <- time.Now()
+	}
+	Expect(timer.Pause()).To(BeNil())
+	delays := []time.Duration{}
+	for len(clock.delays) > 0 {
+		delays = append(delays, <-clock.delays)
+	}
+	Expect(delays).To(HaveLen(count + 1))
+	return delays
+}
+
 var _ = Describe("Timer", func() {
 	It("Pause before completion", func() {
 		stop := make(chan struct{})
@@ -54,18 +89,163 @@ var _ = Describe("Timer", func() {
 
 	It("Pause after stop", func() {
 		stop := make(chan struct{})
-		defer close(stop)
 		x := 0
 		timer := NewRunningTimer("test3", 1*time.Second, stop, false, func() { x++ })
 		log.Debugf("started timer: %+v", timer)
 		time.Sleep(1500 * time.Millisecond)
-		Skip("Pausing after stopping is currently not supported")
+		close(stop)
+		Expect(timer.Pause()).To(Equal(ErrTimerStopped))
+		Expect(x).To(Equal(1))
 	})
 
 	It("Stop", func() {
 		// TODO
 	})
 
+	It("Pause and Resume are no-ops when already in the requested state", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		clock := newFakeClock()
+		timer := newTimer("test26", 10*time.Second, 0, stop, clock.after, rand.Float64, func() {})
+		Expect(timer.State()).To(Equal(TimerStatePaused))
+		Expect(timer.Pause()).To(BeNil())
+		Expect(timer.State()).To(Equal(TimerStatePaused))
+		Expect(timer.Resume(false)).To(BeNil())
+		Expect(timer.Resume(false)).To(BeNil())
+		Expect(timer.State()).To(Equal(TimerStateReady))
+		// resuming again didn't restart the ticks
+		Expect(clock.delays).To(HaveLen(1))
+		Expect(timer.Pause()).To(BeNil())
+		Expect(timer.Pause()).To(BeNil())
+		Expect(timer.State()).To(Equal(TimerStatePaused))
+	})
+
+	It("Resume cancels a pause waiting on a running action", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		clock := newFakeClock()
+		release := make(chan bool)
+		timer := newTimer("test27", 10*time.Second, 0, stop, clock.after, rand.Float64, func() { <-release })
+		Expect(timer.Resume(false)).To(BeNil())
+		clock.ticks <- time.Now()
+		Expect(timer.Pause()).To(BeNil())
+		Expect(timer.Pause()).To(BeNil())
+		Expect(timer.Resume(true)).To(BeNil())
+		release <- true
+		Eventually(timer.State).Should(Equal(TimerStateReady))
+	})
+
+	It("Resume restarts the ticks after RunNow on a paused timer", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		clock := newFakeClock()
+		release := make(chan bool)
+		timer := newTimer("test28", 10*time.Second, 0, stop, clock.after, rand.Float64, func() { <-release })
+		Expect(timer.RunNow()).To(BeNil())
+		Expect(timer.Resume(false)).To(BeNil())
+		Expect(<-clock.delays).To(Equal(10 * time.Second))
+		release <- true
+		Eventually(timer.State).Should(Equal(TimerStateReady))
+	})
+
+	It("stops once its context is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		x := int32(0)
+		timer := NewRunningTimerWithContext(ctx, "test30", 250*time.Millisecond, true, func(ctx context.Context) { atomic.AddInt32(&x, 1) })
+		Eventually(func() int32 { return atomic.LoadInt32(&x) }).Should(Equal(int32(1)))
+		cancel()
+		Eventually(timer.State).Should(Equal(TimerStateStopped))
+		Expect(timer.Pause()).To(Equal(ErrTimerStopped))
+		Consistently(func() int32 { return atomic.LoadInt32(&x) }, 600*time.Millisecond).Should(Equal(int32(1)))
+	})
+
+	It("lets a running action observe cancellation through its context", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		observed := make(chan error, 1)
+		timer := NewRunningTimerWithContext(ctx, "test31", 10*time.Second, true, func(runCtx context.Context) {
+			<-runCtx.Done()
+			observed <- runCtx.Err()
+		})
+		Eventually(timer.State).Should(Equal(TimerStateRunningAction))
+		cancel()
+		Eventually(observed).Should(Receive(Equal(context.Canceled)))
+		Eventually(timer.State).Should(Equal(TimerStateStopped))
+	})
+
+	It("gives each run its own context", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		contexts := make(chan context.Context, 10)
+		timer := NewTimerWithContext(stopContext{stop: stop}, "test32", 10*time.Second, func(ctx context.Context) { contexts <- ctx })
+		Expect(timer.RunNow()).To(BeNil())
+		first := <-contexts
+		// the first run's context is cancelled once it's done, but the timer isn't
+		Eventually(first.Done()).Should(BeClosed())
+		Eventually(timer.State).Should(Equal(TimerStatePaused))
+		Expect(timer.RunNow()).To(BeNil())
+		second := <-contexts
+		Expect(second).NotTo(BeIdenticalTo(first))
+	})
+
+	It("releases its goroutines promptly when cancelled during a pause", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		timers := []*Timer{}
+		for i := 0; i < 50; i++ {
+			timer := NewTimerWithContext(ctx, "leak", time.Hour, func(ctx context.Context) {})
+			Expect(timer.Pause()).To(BeNil())
+			timers = append(timers, timer)
+		}
+		cancel()
+		// the loop sets the stopped state as it returns
+		for _, timer := range timers {
+			Eventually(timer.State, time.Second).Should(Equal(TimerStateStopped))
+			Expect(timer.Pause()).To(Equal(ErrTimerStopped))
+		}
+	})
+
+	It("survives a panicking action, and runs it again", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		clock := newFakeClock()
+		var runs int32
+		timer := newTimer("test33", 10*time.Second, 0, stop, clock.after, rand.Float64, func() {
+			if atomic.AddInt32(&runs, 1) == 1 {
+				var m map[string]int
+				m["boom"]++
+			}
+		})
+		Expect(timer.Resume(true)).To(BeNil())
+		Eventually(func() int { return timer.Stats().Panics }).Should(Equal(1))
+		Eventually(timer.State).Should(Equal(TimerStateReady))
+		Expect(timer.Stats().LastRunCompleted).To(BeFalse())
+		clock.ticks <- time.Now()
+		Eventually(func() bool { return timer.Stats().LastRunCompleted }).Should(BeTrue())
+		Expect(atomic.LoadInt32(&runs)).To(Equal(int32(2)))
+		Expect(timer.Stats().Runs).To(Equal(2))
+		Expect(timer.Stats().Panics).To(Equal(1))
+	})
+
+	It("re-panics when panics are fatal", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		timer := NewTimer("test34", 10*time.Second, stop, func() { panic("planned panic") })
+		Expect(timer.ArePanicsFatal()).To(BeFalse())
+		Expect(timer.runAction()).To(BeFalse())
+		timer.SetPanicsFatal(true)
+		Expect(func() { timer.runAction() }).To(Panic())
+	})
+
+	It("Pause and Resume return ErrTimerStopped once stopped", func() {
+		stop := make(chan struct{})
+		timer := NewTimer("test29", 10*time.Second, stop, func() {})
+		close(stop)
+		Eventually(timer.State).Should(Equal(TimerStateStopped))
+		Expect(timer.Pause()).To(Equal(ErrTimerStopped))
+		Expect(timer.Resume(true)).To(Equal(ErrTimerStopped))
+		Expect(timer.RunNow()).To(Equal(ErrTimerStopped))
+		Expect(timer.SetDelay(time.Second)).To(Equal(ErrTimerStopped))
+	})
+
 	It("Don't run immediately", func() {
 		stop := make(chan struct{})
 		defer close(stop)
@@ -125,10 +305,10 @@ var _ = Describe("Timer", func() {
 			x++
 		})
 		time.Sleep(500 * time.Millisecond)
-		Expect(timer.state).To(Equal(TimerStateRunningAction))
+		Expect(timer.State()).To(Equal(TimerStateRunningAction))
 		Expect(x).To(Equal(0))
 		time.Sleep(1 * time.Second)
-		Expect(timer.state).To(Equal(TimerStateReady))
+		Expect(timer.State()).To(Equal(TimerStateReady))
 		Expect(x).To(Equal(1))
 	})
 
@@ -142,14 +322,14 @@ var _ = Describe("Timer", func() {
 		time.Sleep(250 * time.Millisecond)
 		close(stop)
 		time.Sleep(10 * time.Millisecond)
-		Expect(timer.state).To(Equal(TimerStateStopped))
+		Expect(timer.State()).To(Equal(TimerStateStopped))
 		time.Sleep(2 * time.Second)
 		Expect(x).To(Equal(0))
-		Expect(timer.state).To(Equal(TimerStateStopped))
+		Expect(timer.State()).To(Equal(TimerStateStopped))
 		// wait a bit longer to make sure it's not running
 		time.Sleep(2 * time.Second)
 		Expect(x).To(Equal(0))
-		Expect(timer.state).To(Equal(TimerStateStopped))
+		Expect(timer.State()).To(Equal(TimerStateStopped))
 	})
 
 	It("can be stopped while running action -- action will complete, then it will stop", func() {
@@ -162,22 +342,22 @@ var _ = Describe("Timer", func() {
 			afterSleep++
 		})
 		time.Sleep(1 * time.Millisecond)
-		Expect(timer.state).To(Equal(TimerStateRunningAction))
+		Expect(timer.State()).To(Equal(TimerStateRunningAction))
 		Expect(beforeSleep).To(Equal(1))
 		Expect(afterSleep).To(Equal(0))
 		// now, cancel it while the action is running
 		time.Sleep(500 * time.Millisecond)
-		Expect(timer.state).To(Equal(TimerStateRunningAction))
+		Expect(timer.State()).To(Equal(TimerStateRunningAction))
 		close(stop)
-		Expect(timer.state).To(Equal(TimerStateRunningAction))
+		Expect(timer.State()).To(Equal(TimerStateRunningAction))
 		// wait for the action to complete ... even though that shouldn't make a difference
 		time.Sleep(750 * time.Millisecond)
-		Expect(timer.state).To(Equal(TimerStateStopped))
+		Expect(timer.State()).To(Equal(TimerStateStopped))
 		Expect(beforeSleep).To(Equal(1))
 		Expect(afterSleep).To(Equal(1))
 		// wait a bit longer to make sure it's not running
 		time.Sleep(2 * time.Second)
-		Expect(timer.state).To(Equal(TimerStateStopped))
+		Expect(timer.State()).To(Equal(TimerStateStopped))
 		Expect(beforeSleep).To(Equal(1))
 		Expect(afterSleep).To(Equal(1))
 	})
@@ -192,19 +372,245 @@ var _ = Describe("Timer", func() {
 			afterSleep++
 		})
 		time.Sleep(1 * time.Millisecond)
-		Expect(timer.state).To(Equal(TimerStateRunningAction))
+		Expect(timer.State()).To(Equal(TimerStateRunningAction))
 		Expect(beforeSleep).To(Equal(1))
 		Expect(afterSleep).To(Equal(0))
 		// now, cancel it while the action is running
 		time.Sleep(250 * time.Millisecond)
-		Expect(timer.state).To(Equal(TimerStateRunningAction))
+		Expect(timer.State()).To(Equal(TimerStateRunningAction))
 		close(stop)
 		time.Sleep(3 * time.Millisecond)
-		Expect(timer.state).To(Equal(TimerStateStopped))
+		Expect(timer.State()).To(Equal(TimerStateStopped))
 		// wait a bit longer to make sure it's not running
 		time.Sleep(3 * time.Second)
-		Expect(timer.state).To(Equal(TimerStateStopped))
+		Expect(timer.State()).To(Equal(TimerStateStopped))
 		Expect(beforeSleep).To(Equal(1))
 		Expect(afterSleep).To(Equal(1))
 	})
+
+	It("keeps a fixed pause without jitter", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		clock := newFakeClock()
+		timer := newTimer("test11", 10*time.Second, 0, stop, clock.after, rand.New(rand.NewSource(1)).Float64, func() {})
+		for _, delay := range runTicks(timer, clock, 20) {
+			Expect(delay).To(Equal(10 * time.Second))
+		}
+	})
+
+	It("varies pauses within the jitter bounds", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		clock := newFakeClock()
+		timer := newTimer("test12", 10*time.Second, 0.2, stop, clock.after, rand.New(rand.NewSource(1)).Float64, func() {})
+		delays := runTicks(timer, clock, 20)
+		distinct := map[time.Duration]bool{}
+		for _, delay := range delays {
+			Expect(delay).To(BeNumerically(">=", 8*time.Second))
+			Expect(delay).To(BeNumerically("<=", 12*time.Second))
+			distinct[delay] = true
+		}
+		Expect(len(distinct)).To(BeNumerically(">", 1))
+	})
+
+	It("never waits a negative amount, even with full jitter", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		clock := newFakeClock()
+		extremes := []float64{0, 1}
+		calls := 0
+		random := func() float64 {
+			calls++
+			return extremes[calls%2]
+		}
+		timer := newTimer("test13", 10*time.Second, 1, stop, clock.after, random, func() {})
+		for _, delay := range runTicks(timer, clock, 20) {
+			Expect(delay).To(BeNumerically(">=", 0))
+			Expect(delay).To(BeNumerically("<=", 20*time.Second))
+		}
+	})
+
+	It("rejects jitter outside of [0, 1]", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		Expect(func() { NewJitteredTimer("test14", time.Second, 1.5, stop, func() {}) }).To(Panic())
+		Expect(func() { NewJitteredTimer("test15", time.Second, -0.1, stop, func() {}) }).To(Panic())
+	})
+
+	It("RunNow runs the action and pushes back the next tick", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		clock := newFakeClock()
+		ran := make(chan bool, 10)
+		timer := newTimer("test16", 10*time.Second, 0, stop, clock.after, rand.Float64, func() { ran <- true })
+		Expect(timer.Resume(false)).To(BeNil())
+		Expect(<-clock.delays).To(Equal(10 * time.Second))
+		Expect(timer.RunNow()).To(BeNil())
+		Eventually(ran).Should(Receive())
+		// the interval was restarted
+		Expect(<-clock.delays).To(Equal(10 * time.Second))
+		Eventually(func() TimerState { return timer.State() }).Should(Equal(TimerStateReady))
+	})
+
+	It("RunNow while paused runs the action once, and stays paused", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		clock := newFakeClock()
+		ran := make(chan bool, 10)
+		timer := newTimer("test17", 10*time.Second, 0, stop, clock.after, rand.Float64, func() { ran <- true })
+		Expect(timer.RunNow()).To(BeNil())
+		Eventually(ran).Should(Receive())
+		Eventually(func() TimerState { return timer.State() }).Should(Equal(TimerStatePaused))
+		Expect(clock.delays).To(BeEmpty())
+		Expect(timer.Resume(false)).To(BeNil())
+	})
+
+	It("RunNow fails once stopped", func() {
+		stop := make(chan struct{})
+		timer := NewTimer("test18", 10*time.Second, stop, func() {})
+		close(stop)
+		Eventually(func() TimerState { return timer.State() }).Should(Equal(TimerStateStopped))
+		Expect(timer.RunNow()).ToNot(BeNil())
+	})
+
+	It("RunNow racing a scheduled tick never runs the action concurrently", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		clock := newFakeClock()
+		release := make(chan bool)
+		var running int32
+		maxRunning := int32(0)
+		timer := newTimer("test19", 10*time.Second, 0, stop, clock.after, rand.Float64, func() {
+			if n := atomic.AddInt32(&running, 1); n > atomic.LoadInt32(&maxRunning) {
+				atomic.StoreInt32(&maxRunning, n)
+			}
+			<-release
+			atomic.AddInt32(&running, -1)
+		})
+		Expect(timer.Resume(false)).To(BeNil())
+		clock.ticks <- time.Now()
+		Expect(timer.RunNow()).ToNot(BeNil())
+		close(release)
+		Eventually(func() TimerState { return timer.State() }).Should(Equal(TimerStateReady))
+		Expect(timer.RunNow()).To(BeNil())
+		Eventually(func() TimerState { return timer.State() }).Should(Equal(TimerStateReady))
+		Expect(atomic.LoadInt32(&maxRunning)).To(Equal(int32(1)))
+	})
+
+	It("SetDelay lengthens and shortens the pauses after the one in flight", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		clock := newFakeClock()
+		timer := newTimer("test20", 10*time.Second, 0, stop, clock.after, rand.Float64, func() {})
+		Expect(timer.Resume(false)).To(BeNil())
+		Expect(<-clock.delays).To(Equal(10 * time.Second))
+		// lengthen: the pending tick isn't rescheduled
+		Expect(timer.SetDelay(30 * time.Second)).To(BeNil())
+		Expect(timer.Delay()).To(Equal(30 * time.Second))
+		Expect(clock.delays).To(BeEmpty())
+		clock.ticks <- time.Now()
+		Expect(<-clock.delays).To(Equal(30 * time.Second))
+		// shorten
+		Expect(timer.SetDelay(5 * time.Second)).To(BeNil())
+		Expect(clock.delays).To(BeEmpty())
+		clock.ticks <- time.Now()
+		Expect(<-clock.delays).To(Equal(5 * time.Second))
+	})
+
+	It("SetDelay applies once a paused timer resumes", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		clock := newFakeClock()
+		timer := newTimer("test21", 10*time.Second, 0, stop, clock.after, rand.Float64, func() {})
+		Expect(timer.SetDelay(2 * time.Second)).To(BeNil())
+		Expect(timer.Resume(false)).To(BeNil())
+		Expect(<-clock.delays).To(Equal(2 * time.Second))
+	})
+
+	It("Stats counts runs and ticks skipped while the action is running", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		clock := newFakeClock()
+		release := make(chan bool)
+		timer := newTimer("test23", 10*time.Second, 0, stop, clock.after, rand.Float64, func() { <-release })
+		Expect(timer.Stats()).To(Equal(TimerStats{}))
+		Expect(timer.Resume(false)).To(BeNil())
+		clock.ticks <- time.Now()
+		clock.ticks <- time.Now()
+		clock.ticks <- time.Now()
+		Eventually(func() int { return timer.Stats().SkippedTicks }).Should(Equal(2))
+		stats := timer.Stats()
+		Expect(stats.Runs).To(Equal(1))
+		Expect(stats.LastRunStart).NotTo(BeNil())
+		Expect(stats.LastRunCompleted).To(BeFalse())
+		release <- true
+		Eventually(func() bool { return timer.Stats().LastRunCompleted }).Should(BeTrue())
+		Expect(timer.Stats().LastRunDuration).To(BeNumerically(">", 0))
+	})
+
+	It("skips ticks which arrive during a slow action, by default", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		clock := newFakeClock()
+		release := make(chan bool)
+		var running, overlapped int32
+		timer := newTimer("test24", 10*time.Second, 0, stop, clock.after, rand.Float64, func() {
+			if atomic.AddInt32(&running, 1) > 1 {
+				atomic.StoreInt32(&overlapped, 1)
+			}
+			<-release
+			atomic.AddInt32(&running, -1)
+		})
+		Expect(timer.OverlapPolicy()).To(Equal(TimerOverlapSkip))
+		Expect(timer.Resume(false)).To(BeNil())
+		for i := 0; i < 4; i++ {
+			clock.ticks <- time.Now()
+		}
+		release <- true
+		Eventually(func() TimerState { return timer.State() }).Should(Equal(TimerStateReady))
+		Consistently(func() int { return timer.Stats().Runs }).Should(Equal(1))
+		Expect(timer.Stats().SkippedTicks).To(Equal(3))
+		Expect(timer.Stats().CoalescedTicks).To(Equal(0))
+		Expect(atomic.LoadInt32(&overlapped)).To(Equal(int32(0)))
+	})
+
+	It("coalesces ticks which arrive during a slow action into one follow-up run", func() {
+		stop := make(chan struct{})
+		defer close(stop)
+		clock := newFakeClock()
+		release := make(chan bool)
+		var running, overlapped int32
+		timer := newTimer("test25", 10*time.Second, 0, stop, clock.after, rand.Float64, func() {
+			if atomic.AddInt32(&running, 1) > 1 {
+				atomic.StoreInt32(&overlapped, 1)
+			}
+			<-release
+			atomic.AddInt32(&running, -1)
+		})
+		timer.SetOverlapPolicy(TimerOverlapCoalesce)
+		Expect(timer.Resume(false)).To(BeNil())
+		for i := 0; i < 4; i++ {
+			clock.ticks <- time.Now()
+		}
+		release <- true
+		// the follow-up run starts right away
+		Eventually(func() int { return timer.Stats().Runs }).Should(Equal(2))
+		release <- true
+		Eventually(func() TimerState { return timer.State() }).Should(Equal(TimerStateReady))
+		Consistently(func() int { return timer.Stats().Runs }).Should(Equal(2))
+		Expect(timer.Stats().SkippedTicks).To(Equal(0))
+		Expect(timer.Stats().CoalescedTicks).To(Equal(3))
+		Expect(atomic.LoadInt32(&overlapped)).To(Equal(int32(0)))
+	})
+
+	It("SetDelay rejects invalid delays and stopped timers", func() {
+		stop := make(chan struct{})
+		timer := NewTimer("test22", 10*time.Second, stop, func() {})
+		Expect(timer.SetDelay(0)).ToNot(BeNil())
+		Expect(timer.SetDelay(-time.Second)).ToNot(BeNil())
+		Expect(timer.Delay()).To(Equal(10 * time.Second))
+		close(stop)
+		Expect(timer.SetDelay(time.Second)).ToNot(BeNil())
+		Expect(timer.Delay()).To(Equal(10 * time.Second))
+	})
 })
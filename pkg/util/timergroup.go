@@ -0,0 +1,148 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TimerGroupError collects the errors from applying an operation to each
+// timer in a group, by timer name.
+type TimerGroupError struct {
+	Errors map[string]error
+}
+
+func (err *TimerGroupError) Error() string {
+	names := make([]string, 0, len(err.Errors))
+	for name := range err.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	messages := make([]string, len(names))
+	for i, name := range names {
+		messages[i] = fmt.Sprintf("%s: %s", name, err.Errors[name].Error())
+	}
+	return fmt.Sprintf("%d timer(s) failed: %s", len(names), strings.Join(messages, "; "))
+}
+
+// TimerGroup manages a named set of related timers together.  Members should
+// be created with the group's Done channel as their stop channel, so that
+// StopAll stops them.
+type TimerGroup struct {
+	mutex  sync.Mutex
+	timers map[string]*Timer
+	ctx    context.Context
+	cancel func()
+}
+
+// NewTimerGroup returns an empty group, which stops along with `stop`.
+func NewTimerGroup(stop <-chan struct{}) *TimerGroup {
+	ctx, cancel := context.WithCancel(stopContext{stop: stop})
+	return &TimerGroup{timers: map[string]*Timer{}, ctx: ctx, cancel: cancel}
+}
+
+// Done is closed once the group is stopped.
+func (group *TimerGroup) Done() <-chan struct{} {
+	return group.ctx.Done()
+}
+
+// Add adds a timer under `name`, which must be unique within the group.
+func (group *TimerGroup) Add(name string, timer *Timer) error {
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+	if _, ok := group.timers[name]; ok {
+		return fmt.Errorf("cannot add timer %s to group: name already in use", name)
+	}
+	group.timers[name] = timer
+	return nil
+}
+
+// Get returns the timer added under `name`, or nil if there isn't one.
+func (group *TimerGroup) Get(name string) *Timer {
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+	return group.timers[name]
+}
+
+// Names returns the names of the group's timers, sorted.
+func (group *TimerGroup) Names() []string {
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+	names := make([]string, 0, len(group.timers))
+	for name := range group.timers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PauseAll pauses every timer, returning a TimerGroupError if any failed to.
+func (group *TimerGroup) PauseAll() error {
+	return group.applyToAll(func(timer *Timer) error { return timer.Pause() })
+}
+
+// ResumeAll resumes every timer, returning a TimerGroupError if any failed to.
+func (group *TimerGroup) ResumeAll(runImmediately bool) error {
+	return group.applyToAll(func(timer *Timer) error { return timer.Resume(runImmediately) })
+}
+
+// StopAll stops every timer created with the group's Done channel.
+func (group *TimerGroup) StopAll() {
+	group.cancel()
+}
+
+// Stats returns each timer's stats, by name.
+func (group *TimerGroup) Stats() map[string]TimerStats {
+	stats := map[string]TimerStats{}
+	for name, timer := range group.members() {
+		stats[name] = timer.Stats()
+	}
+	return stats
+}
+
+func (group *TimerGroup) members() map[string]*Timer {
+	group.mutex.Lock()
+	defer group.mutex.Unlock()
+	members := make(map[string]*Timer, len(group.timers))
+	for name, timer := range group.timers {
+		members[name] = timer
+	}
+	return members
+}
+
+// applyToAll applies `op` to every timer, even after one of them fails.
+func (group *TimerGroup) applyToAll(op func(timer *Timer) error) error {
+	errors := map[string]error{}
+	for name, timer := range group.members() {
+		if err := op(timer); err != nil {
+			errors[name] = err
+		}
+	}
+	if len(errors) > 0 {
+		return &TimerGroupError{Errors: errors}
+	}
+	return nil
+}
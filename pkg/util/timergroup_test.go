@@ -0,0 +1,103 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Timer group", func() {
+	var stop chan struct{}
+	var group *TimerGroup
+	BeforeEach(func() {
+		stop = make(chan struct{})
+		group = NewTimerGroup(stop)
+		Expect(group.Add("a", NewTimer("group-a", time.Hour, group.Done(), func() {}))).To(BeNil())
+		Expect(group.Add("b", NewTimer("group-b", time.Hour, group.Done(), func() {}))).To(BeNil())
+	})
+	AfterEach(func() {
+		close(stop)
+	})
+
+	It("gives access to its timers by name", func() {
+		Expect(group.Names()).To(Equal([]string{"a", "b"}))
+		Expect(group.Get("a")).NotTo(BeNil())
+		Expect(group.Get("missing")).To(BeNil())
+		Expect(group.Add("a", NewTimer("group-a2", time.Hour, group.Done(), func() {}))).NotTo(BeNil())
+	})
+
+	It("pauses and resumes every timer", func() {
+		Expect(group.ResumeAll(false)).To(BeNil())
+		for _, name := range group.Names() {
+			Expect(group.Get(name).State()).To(Equal(TimerStateReady))
+		}
+		Expect(group.PauseAll()).To(BeNil())
+		for _, name := range group.Names() {
+			Expect(group.Get(name).State()).To(Equal(TimerStatePaused))
+		}
+	})
+
+	It("runs every timer when resuming immediately, and aggregates their stats", func() {
+		Expect(group.ResumeAll(true)).To(BeNil())
+		Eventually(func() int {
+			runs := 0
+			for _, stats := range group.Stats() {
+				runs += stats.Runs
+			}
+			return runs
+		}).Should(Equal(2))
+		Expect(group.Stats()).To(HaveLen(2))
+	})
+
+	It("stops every timer", func() {
+		group.StopAll()
+		for _, name := range group.Names() {
+			Eventually(group.Get(name).State).Should(Equal(TimerStateStopped))
+		}
+		Expect(group.Done()).To(BeClosed())
+	})
+
+	It("stops along with its stop channel", func() {
+		otherStop := make(chan struct{})
+		other := NewTimerGroup(otherStop)
+		Expect(other.Add("c", NewTimer("group-c", time.Hour, other.Done(), func() {}))).To(BeNil())
+		close(otherStop)
+		Eventually(other.Get("c").State).Should(Equal(TimerStateStopped))
+	})
+
+	It("applies operations to every member and aggregates the errors", func() {
+		otherStop := make(chan struct{})
+		Expect(group.Add("stopped", NewTimer("group-stopped", time.Hour, otherStop, func() {}))).To(BeNil())
+		close(otherStop)
+		Eventually(group.Get("stopped").State).Should(Equal(TimerStateStopped))
+		err := group.ResumeAll(false)
+		Expect(err).To(BeAssignableToTypeOf(&TimerGroupError{}))
+		Expect(err.(*TimerGroupError).Errors).To(Equal(map[string]error{"stopped": ErrTimerStopped}))
+		Expect(err.Error()).To(ContainSubstring("stopped: timer is stopped"))
+		// the others were still resumed
+		Expect(group.Get("a").State()).To(Equal(TimerStateReady))
+		Expect(group.Get("b").State()).To(Equal(TimerStateReady))
+	})
+})
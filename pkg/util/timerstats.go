@@ -0,0 +1,96 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"time"
+)
+
+// TimerStats describes how a timer's action has been running.
+type TimerStats struct {
+	// Runs is the number of times the action was started.
+	Runs int
+	// SkippedTicks is the number of ticks dropped because the action was
+	// still running.
+	SkippedTicks int
+	// CoalescedTicks is the number of ticks which arrived while the action
+	// was running, and were folded into a follow-up run.
+	CoalescedTicks int
+	// Panics is the number of runs which panicked.
+	Panics int
+	// LastRunStart is nil if the action has never run.
+	LastRunStart *time.Time
+	// LastRunDuration is only meaningful once LastRunCompleted is true; a run
+	// which panicked never completes.
+	LastRunDuration  time.Duration
+	LastRunCompleted bool
+}
+
+// Stats returns a snapshot of the timer's execution statistics.  It's safe
+// to call at any time.
+func (timer *Timer) Stats() TimerStats {
+	timer.statsMutex.RLock()
+	defer timer.statsMutex.RUnlock()
+	stats := timer.stats
+	if stats.LastRunStart != nil {
+		start := *stats.LastRunStart
+		stats.LastRunStart = &start
+	}
+	return stats
+}
+
+func (timer *Timer) didStartRun(start time.Time) {
+	timer.statsMutex.Lock()
+	defer timer.statsMutex.Unlock()
+	timer.stats.Runs++
+	timer.stats.LastRunStart = &start
+	timer.stats.LastRunDuration = 0
+	timer.stats.LastRunCompleted = false
+}
+
+func (timer *Timer) didFinishRun(start time.Time) {
+	duration := time.Now().Sub(start)
+	recordTimerRunDuration(timer.name, duration)
+	timer.statsMutex.Lock()
+	defer timer.statsMutex.Unlock()
+	timer.stats.LastRunDuration = duration
+	timer.stats.LastRunCompleted = true
+}
+
+func (timer *Timer) didPanic() {
+	recordTimerPanic(timer.name)
+	timer.statsMutex.Lock()
+	defer timer.statsMutex.Unlock()
+	timer.stats.Panics++
+}
+
+func (timer *Timer) didSkipTick() {
+	timer.statsMutex.Lock()
+	defer timer.statsMutex.Unlock()
+	timer.stats.SkippedTicks++
+}
+
+func (timer *Timer) didCoalesceTick() {
+	timer.statsMutex.Lock()
+	defer timer.statsMutex.Unlock()
+	timer.stats.CoalescedTicks++
+}
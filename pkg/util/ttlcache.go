@@ -0,0 +1,210 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TTLCacheStats counts how a TTLCache has been used.
+type TTLCacheStats struct {
+	Hits   int
+	Misses int
+	// Evictions counts entries dropped to make room, not ones which expired
+	Evictions int
+}
+
+type ttlCacheEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// ttlCacheCall is a computation in progress, shared by everyone asking for
+// the same key.
+type ttlCacheCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// TTLCache holds values until their TTL runs out, and at most `maxEntries`
+// of them, evicting the least recently used first.  It's safe for
+// concurrent use.
+type TTLCache struct {
+	mutex      sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	// order has the most recently used entry at the front
+	order    *list.List
+	inFlight map[string]*ttlCacheCall
+	stats    TTLCacheStats
+	now      func() time.Time
+}
+
+// NewTTLCache .....
+func NewTTLCache(maxEntries int) *TTLCache {
+	return NewTTLCacheWithClock(maxEntries, time.Now)
+}
+
+// NewTTLCacheWithClock is NewTTLCache, reading the time from `now`.
+func NewTTLCacheWithClock(maxEntries int, now func() time.Time) *TTLCache {
+	if maxEntries <= 0 {
+		panic(fmt.Errorf("invalid TTL cache size: must be positive, was %d", maxEntries))
+	}
+	return &TTLCache{
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		order:      list.New(),
+		inFlight:   map[string]*ttlCacheCall{},
+		now:        now,
+	}
+}
+
+// Get returns the value for `key`, if there's one which hasn't expired.
+func (cache *TTLCache) Get(key string) (interface{}, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	value, ok := cache.get(key)
+	if ok {
+		cache.stats.Hits++
+	} else {
+		cache.stats.Misses++
+	}
+	return value, ok
+}
+
+// Set stores `value` for `ttl`, replacing any existing value for `key`.
+func (cache *TTLCache) Set(key string, value interface{}, ttl time.Duration) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.set(key, value, ttl)
+}
+
+// Delete drops the value for `key`, if there is one.
+func (cache *TTLCache) Delete(key string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if element, ok := cache.entries[key]; ok {
+		cache.remove(element)
+	}
+}
+
+// GetOrCompute returns the value for `key` if there's one, and otherwise
+// calls `compute` and caches what it returns for `ttl`.  Concurrent calls
+// for the same key share a single computation.  Errors aren't cached.
+// If `compute` panics, the panic is passed on to the caller which ran it,
+// and the callers waiting on it get an error.
+func (cache *TTLCache) GetOrCompute(key string, ttl time.Duration, compute func() (interface{}, error)) (interface{}, error) {
+	cache.mutex.Lock()
+	if value, ok := cache.get(key); ok {
+		cache.stats.Hits++
+		cache.mutex.Unlock()
+		return value, nil
+	}
+	cache.stats.Misses++
+	if call, ok := cache.inFlight[key]; ok {
+		cache.mutex.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &ttlCacheCall{done: make(chan struct{})}
+	cache.inFlight[key] = call
+	cache.mutex.Unlock()
+
+	returned := false
+	defer func() {
+		r := recover()
+		if !returned {
+			call.value = nil
+			call.err = fmt.Errorf("computing cache entry %s failed: %v", key, r)
+		}
+		cache.mutex.Lock()
+		delete(cache.inFlight, key)
+		if call.err == nil {
+			cache.set(key, call.value, ttl)
+		}
+		cache.mutex.Unlock()
+		close(call.done)
+		if r != nil {
+			panic(r)
+		}
+	}()
+	call.value, call.err = compute()
+	returned = true
+	return call.value, call.err
+}
+
+// Len returns the number of entries, including any which have expired but
+// haven't been dropped yet.
+func (cache *TTLCache) Len() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return cache.order.Len()
+}
+
+// Stats returns a snapshot of the cache's counters.
+func (cache *TTLCache) Stats() TTLCacheStats {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return cache.stats
+}
+
+// Implementation details: these must be called with the mutex held.
+
+func (cache *TTLCache) get(key string) (interface{}, bool) {
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*ttlCacheEntry)
+	if !cache.now().Before(entry.expires) {
+		cache.remove(element)
+		return nil, false
+	}
+	cache.order.MoveToFront(element)
+	return entry.value, true
+}
+
+func (cache *TTLCache) set(key string, value interface{}, ttl time.Duration) {
+	expires := cache.now().Add(ttl)
+	if element, ok := cache.entries[key]; ok {
+		entry := element.Value.(*ttlCacheEntry)
+		entry.value = value
+		entry.expires = expires
+		cache.order.MoveToFront(element)
+		return
+	}
+	cache.entries[key] = cache.order.PushFront(&ttlCacheEntry{key: key, value: value, expires: expires})
+	for cache.order.Len() > cache.maxEntries {
+		cache.remove(cache.order.Back())
+		cache.stats.Evictions++
+	}
+}
+
+func (cache *TTLCache) remove(element *list.Element) {
+	cache.order.Remove(element)
+	delete(cache.entries, element.Value.(*ttlCacheEntry).key)
+}
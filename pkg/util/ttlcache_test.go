@@ -0,0 +1,168 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// getValue returns the cached value for `key`, or nil if there isn't one.
+func getValue(cache *TTLCache, key string) interface{} {
+	value, _ := cache.Get(key)
+	return value
+}
+
+var _ = Describe("TTL cache", func() {
+	var now time.Time
+	clock := func() time.Time { return now }
+	BeforeEach(func() {
+		now = time.Now()
+	})
+
+	It("should return values until they expire", func() {
+		cache := NewTTLCacheWithClock(10, clock)
+		cache.Set("short", 1, time.Minute)
+		cache.Set("long", 2, time.Hour)
+		Expect(getValue(cache, "short")).To(Equal(1))
+		now = now.Add(time.Minute)
+		_, ok := cache.Get("short")
+		Expect(ok).To(BeFalse())
+		Expect(getValue(cache, "long")).To(Equal(2))
+		Expect(cache.Len()).To(Equal(1))
+		Expect(cache.Stats()).To(Equal(TTLCacheStats{Hits: 2, Misses: 1, Evictions: 0}))
+	})
+
+	It("should evict the least recently used entry when full", func() {
+		cache := NewTTLCacheWithClock(3, clock)
+		cache.Set("a", 1, time.Hour)
+		cache.Set("b", 2, time.Hour)
+		cache.Set("c", 3, time.Hour)
+		// a is now more recently used than b
+		cache.Get("a")
+		cache.Set("d", 4, time.Hour)
+		_, ok := cache.Get("b")
+		Expect(ok).To(BeFalse())
+		cache.Set("e", 5, time.Hour)
+		_, ok = cache.Get("c")
+		Expect(ok).To(BeFalse())
+		for _, key := range []string{"a", "d", "e"} {
+			_, ok := cache.Get(key)
+			Expect(ok).To(BeTrue(), key)
+		}
+		Expect(cache.Stats().Evictions).To(Equal(2))
+	})
+
+	It("should replace values and refresh their TTL", func() {
+		cache := NewTTLCacheWithClock(2, clock)
+		cache.Set("a", 1, time.Minute)
+		now = now.Add(50 * time.Second)
+		cache.Set("a", 2, time.Minute)
+		now = now.Add(50 * time.Second)
+		Expect(getValue(cache, "a")).To(Equal(2))
+		Expect(cache.Len()).To(Equal(1))
+		cache.Delete("a")
+		Expect(cache.Len()).To(Equal(0))
+	})
+
+	It("should compute missing values once, and not cache errors", func() {
+		cache := NewTTLCacheWithClock(2, clock)
+		calls := 0
+		compute := func() (interface{}, error) {
+			calls++
+			if calls == 1 {
+				return nil, fmt.Errorf("planned failure")
+			}
+			return calls, nil
+		}
+		_, err := cache.GetOrCompute("a", time.Minute, compute)
+		Expect(err).NotTo(BeNil())
+		Expect(cache.GetOrCompute("a", time.Minute, compute)).To(Equal(2))
+		Expect(cache.GetOrCompute("a", time.Minute, compute)).To(Equal(2))
+		Expect(calls).To(Equal(2))
+		now = now.Add(time.Minute)
+		Expect(cache.GetOrCompute("a", time.Minute, compute)).To(Equal(3))
+	})
+
+	It("should share one computation between parallel callers", func() {
+		cache := NewTTLCache(10)
+		var calls int32
+		release := make(chan bool)
+		compute := func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return "digest", nil
+		}
+		var wg sync.WaitGroup
+		results := make(chan interface{}, 20)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				value, err := cache.GetOrCompute("tag", time.Minute, compute)
+				Expect(err).To(BeNil())
+				results <- value
+			}()
+		}
+		Eventually(func() int { return cache.Stats().Misses }).Should(Equal(20))
+		close(release)
+		wg.Wait()
+		close(results)
+		for value := range results {
+			Expect(value).To(Equal("digest"))
+		}
+		Expect(atomic.LoadInt32(&calls)).To(Equal(int32(1)))
+	})
+
+	It("should recover from a computation which panics", func() {
+		cache := NewTTLCache(10)
+		waiterErr := make(chan error, 1)
+		go func() {
+			defer GinkgoRecover()
+			Eventually(func() int { return cache.Stats().Misses }).Should(Equal(1))
+			_, err := cache.GetOrCompute("tag", time.Minute, func() (interface{}, error) {
+				return "unexpected", nil
+			})
+			waiterErr <- err
+		}()
+		Expect(func() {
+			cache.GetOrCompute("tag", time.Minute, func() (interface{}, error) {
+				// the second caller is now waiting on this computation
+				Eventually(func() int { return cache.Stats().Misses }).Should(Equal(2))
+				panic("planned panic")
+			})
+		}).To(Panic())
+		Eventually(waiterErr).Should(Receive(HaveOccurred()))
+
+		_, ok := cache.Get("tag")
+		Expect(ok).To(BeFalse())
+		Expect(cache.GetOrCompute("tag", time.Minute, func() (interface{}, error) {
+			return "digest", nil
+		})).To(Equal("digest"))
+	})
+})